@@ -13,16 +13,18 @@ const LiveLockTTL = time.Second * 7
 var NoLockServerError = fmt.Errorf("no lock server found")
 
 type DistributedLockManager struct {
-	lockManager *LockManager
-	LockRing    *LockRing
-	Host        pb.ServerAddress
+	lockManager      *LockManager
+	rangeLockManager *RangeLockManager
+	LockRing         *LockRing
+	Host             pb.ServerAddress
 }
 
 func NewDistributedLockManager(host pb.ServerAddress) *DistributedLockManager {
 	return &DistributedLockManager{
-		lockManager: NewLockManager(),
-		LockRing:    NewLockRing(time.Second * 5),
-		Host:        host,
+		lockManager:      NewLockManager(),
+		rangeLockManager: NewRangeLockManager(),
+		LockRing:         NewLockRing(time.Second * 5),
+		Host:             host,
 	}
 }
 
@@ -101,3 +103,48 @@ func (dlm *DistributedLockManager) IsLocal(key string) bool {
 	}
 	return hashKeyToServer(key, servers) == dlm.Host
 }
+
+// RangeLock acquires a byte range lock, or only checks for a conflicting lock if isTest is set
+func (dlm *DistributedLockManager) RangeLock(key string, lock *RangeLock, isTest bool) (conflict *RangeLock, movedTo pb.ServerAddress, err error) {
+	movedTo, err = dlm.findLockOwningFiler(key)
+	if err != nil || movedTo != dlm.Host {
+		return
+	}
+	if isTest {
+		conflict = dlm.rangeLockManager.Test(key, lock)
+	} else {
+		conflict = dlm.rangeLockManager.Lock(key, lock)
+	}
+	return
+}
+
+func (dlm *DistributedLockManager) RangeUnlock(key string, session string, owner uint64, allOwners bool, start, end uint64) (sessionHasLocks bool, movedTo pb.ServerAddress, err error) {
+	movedTo, err = dlm.findLockOwningFiler(key)
+	if err != nil || movedTo != dlm.Host {
+		return
+	}
+	sessionHasLocks = dlm.rangeLockManager.Unlock(key, session, owner, allOwners, start, end)
+	return
+}
+
+// RenewRangeLocks extends the range locks of the session on the key, if the key is owned by this filer.
+// The locks are lost if the key is owned by this filer but not renewed.
+func (dlm *DistributedLockManager) RenewRangeLocks(key string, session string, expiredAtNs int64) (renewed bool, movedTo pb.ServerAddress, err error) {
+	movedTo, err = dlm.findLockOwningFiler(key)
+	if err != nil || movedTo != dlm.Host {
+		return
+	}
+	renewed = dlm.rangeLockManager.Renew(key, session, expiredAtNs)
+	return
+}
+
+func (dlm *DistributedLockManager) InsertRangeLock(key string, lock *RangeLock) {
+	dlm.rangeLockManager.InsertLock(key, lock)
+}
+
+func (dlm *DistributedLockManager) SelectNotOwnedRangeLocks(servers []pb.ServerAddress) (locks []*RangeLock) {
+	return dlm.rangeLockManager.SelectLocks(func(key string) bool {
+		server := hashKeyToServer(key, servers)
+		return server != dlm.Host
+	})
+}
@@ -0,0 +1,236 @@
+package lock_manager
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+// RangeLock is a shared or exclusive lock on a byte range of a file, for posix record locks and flock.
+// The owner is identified by the session of the client and the lock owner within the session.
+type RangeLock struct {
+	Session     string
+	Owner       uint64
+	Pid         uint32
+	Start       uint64
+	End         uint64 // inclusive
+	IsExclusive bool
+	ExpiredAtNs int64
+	Key         string // only used for moving locks
+}
+
+func (l *RangeLock) isSameOwner(other *RangeLock) bool {
+	return l.Session == other.Session && l.Owner == other.Owner
+}
+
+func (l *RangeLock) overlaps(start, end uint64) bool {
+	return l.Start <= end && start <= l.End
+}
+
+func (l *RangeLock) conflictsWith(other *RangeLock) bool {
+	if l.isSameOwner(other) || !l.overlaps(other.Start, other.End) {
+		return false
+	}
+	return l.IsExclusive || other.IsExclusive
+}
+
+// RangeLockManager keeps the range locks of the keys, used by distributed lock manager.
+// The locks of a session expire together unless the session renews them.
+type RangeLockManager struct {
+	locks      map[string][]*RangeLock
+	accessLock sync.Mutex
+}
+
+func NewRangeLockManager() *RangeLockManager {
+	m := &RangeLockManager{
+		locks: make(map[string][]*RangeLock),
+	}
+	go m.CleanUp()
+	return m
+}
+
+// removeExpired must be called with the access lock held
+func (m *RangeLockManager) removeExpired(key string, now int64) []*RangeLock {
+	locks := m.locks[key]
+	live := locks[:0]
+	for _, l := range locks {
+		if l.ExpiredAtNs > 0 && l.ExpiredAtNs < now {
+			glog.V(4).Infof("range lock %s [%d,%d] of %s expired", key, l.Start, l.End, l.Session)
+			continue
+		}
+		live = append(live, l)
+	}
+	if len(live) == 0 {
+		delete(m.locks, key)
+		return nil
+	}
+	m.locks[key] = live
+	return live
+}
+
+// Test returns the first lock conflicting with the lock, or nil
+func (m *RangeLockManager) Test(key string, lock *RangeLock) (conflict *RangeLock) {
+	m.accessLock.Lock()
+	defer m.accessLock.Unlock()
+
+	for _, l := range m.removeExpired(key, time.Now().UnixNano()) {
+		if l.conflictsWith(lock) {
+			return l
+		}
+	}
+	return nil
+}
+
+// Lock acquires the lock, replacing the overlapping ranges of the same owner.
+// It returns the conflicting lock if the lock can not be acquired.
+func (m *RangeLockManager) Lock(key string, lock *RangeLock) (conflict *RangeLock) {
+	m.accessLock.Lock()
+	defer m.accessLock.Unlock()
+
+	locks := m.removeExpired(key, time.Now().UnixNano())
+	for _, l := range locks {
+		if l.conflictsWith(lock) {
+			return l
+		}
+	}
+	locks = subtractRange(locks, lock.Session, lock.Owner, lock.Start, lock.End)
+	m.locks[key] = mergeRange(locks, lock)
+	return nil
+}
+
+// Unlock releases the range of the owner, or of all owners in the session if allOwners is set.
+// It returns whether the session still holds locks on the key.
+func (m *RangeLockManager) Unlock(key string, session string, owner uint64, allOwners bool, start, end uint64) (sessionHasLocks bool) {
+	m.accessLock.Lock()
+	defer m.accessLock.Unlock()
+
+	locks := m.removeExpired(key, time.Now().UnixNano())
+	if allOwners {
+		var remaining []*RangeLock
+		for _, l := range locks {
+			if l.Session != session {
+				remaining = append(remaining, l)
+			}
+		}
+		locks = remaining
+	} else {
+		locks = subtractRange(locks, session, owner, start, end)
+	}
+	if len(locks) == 0 {
+		delete(m.locks, key)
+		return false
+	}
+	m.locks[key] = locks
+	for _, l := range locks {
+		if l.Session == session {
+			return true
+		}
+	}
+	return false
+}
+
+// Renew extends the locks of the session on the key.
+// It returns false if the session holds no lock on the key, e.g., the locks expired before being renewed.
+func (m *RangeLockManager) Renew(key string, session string, expiredAtNs int64) (renewed bool) {
+	m.accessLock.Lock()
+	defer m.accessLock.Unlock()
+
+	for _, l := range m.removeExpired(key, time.Now().UnixNano()) {
+		if l.Session == session {
+			l.ExpiredAtNs = expiredAtNs
+			renewed = true
+		}
+	}
+	return
+}
+
+func (m *RangeLockManager) CleanUp() {
+	for {
+		time.Sleep(1 * time.Minute)
+		now := time.Now().UnixNano()
+
+		m.accessLock.Lock()
+		for key := range m.locks {
+			m.removeExpired(key, now)
+		}
+		m.accessLock.Unlock()
+	}
+}
+
+// SelectLocks takes out the locks of the selected keys
+func (m *RangeLockManager) SelectLocks(selectFn func(key string) bool) (locks []*RangeLock) {
+	m.accessLock.Lock()
+	defer m.accessLock.Unlock()
+
+	now := time.Now().UnixNano()
+	for key := range m.locks {
+		live := m.removeExpired(key, now)
+		if len(live) == 0 || !selectFn(key) {
+			continue
+		}
+		delete(m.locks, key)
+		for _, l := range live {
+			l.Key = key
+			locks = append(locks, l)
+		}
+	}
+	return
+}
+
+// InsertLock inserts a lock unconditionally
+func (m *RangeLockManager) InsertLock(key string, lock *RangeLock) {
+	m.accessLock.Lock()
+	defer m.accessLock.Unlock()
+
+	lock.Key = ""
+	m.locks[key] = append(m.locks[key], lock)
+}
+
+// subtractRange removes the range [start, end] from the locks of the owner, splitting the locks if needed
+func subtractRange(locks []*RangeLock, session string, owner uint64, start, end uint64) (remaining []*RangeLock) {
+	for _, l := range locks {
+		if l.Session != session || l.Owner != owner || !l.overlaps(start, end) {
+			remaining = append(remaining, l)
+			continue
+		}
+		if l.Start < start {
+			head := *l
+			head.End = start - 1
+			remaining = append(remaining, &head)
+		}
+		if end < l.End {
+			tail := *l
+			tail.Start = end + 1
+			remaining = append(remaining, &tail)
+		}
+	}
+	return
+}
+
+// mergeRange adds the lock, coalescing adjacent locks of the same owner and type
+func mergeRange(locks []*RangeLock, lock *RangeLock) []*RangeLock {
+	merged := *lock
+	var remaining []*RangeLock
+	for _, l := range locks {
+		if l.isSameOwner(&merged) && l.IsExclusive == merged.IsExclusive &&
+			(l.overlaps(merged.Start, merged.End) || isAdjacent(l.End, merged.Start) || isAdjacent(merged.End, l.Start)) {
+			if l.Start < merged.Start {
+				merged.Start = l.Start
+			}
+			if l.End > merged.End {
+				merged.End = l.End
+			}
+			continue
+		}
+		remaining = append(remaining, l)
+	}
+	return append(remaining, &merged)
+}
+
+// isAdjacent tells whether the range ending at end is followed by the range starting at start,
+// without wrapping around for the ranges open to the end of the file
+func isAdjacent(end, start uint64) bool {
+	return end != math.MaxUint64 && end+1 == start
+}
@@ -0,0 +1,91 @@
+package lock_manager
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRangeLockConflicts(t *testing.T) {
+	m := &RangeLockManager{locks: make(map[string][]*RangeLock)}
+	expiredAtNs := time.Now().Add(time.Minute).UnixNano()
+
+	shared := func(session string, start, end uint64) *RangeLock {
+		return &RangeLock{Session: session, Owner: 1, Start: start, End: end, ExpiredAtNs: expiredAtNs}
+	}
+	exclusive := func(session string, start, end uint64) *RangeLock {
+		l := shared(session, start, end)
+		l.IsExclusive = true
+		return l
+	}
+
+	assert.Nil(t, m.Lock("f", shared("a", 0, 99)))
+	assert.Nil(t, m.Lock("f", shared("b", 50, 149)), "shared locks do not conflict")
+	assert.NotNil(t, m.Lock("f", exclusive("c", 120, 129)))
+	assert.Nil(t, m.Lock("f", exclusive("c", 150, math.MaxUint64)))
+
+	// upgrade a part of the own shared lock
+	assert.NotNil(t, m.Lock("f", exclusive("a", 40, 59)), "conflicts with b")
+	assert.Nil(t, m.Lock("f", exclusive("a", 10, 19)))
+	assert.Equal(t, 3, countSession(m, "f", "a"), "split into [0,9] [10,19] [20,99]")
+
+	assert.True(t, m.Unlock("f", "a", 1, false, 0, 9))
+	assert.Nil(t, m.Test("f", exclusive("d", 0, 9)))
+	assert.NotNil(t, m.Test("f", shared("d", 15, 15)))
+
+	assert.False(t, m.Unlock("f", "a", 0, true, 0, 0))
+	assert.Nil(t, m.Test("f", exclusive("d", 0, 49)))
+
+	// expired sessions release their locks
+	m.Renew("f", "b", time.Now().Add(-time.Second).UnixNano())
+	assert.Nil(t, m.Test("f", exclusive("d", 0, 149)))
+}
+
+func TestRangeLockMerge(t *testing.T) {
+	m := &RangeLockManager{locks: make(map[string][]*RangeLock)}
+	lock := func(start, end uint64) *RangeLock {
+		return &RangeLock{Session: "a", Owner: 1, Start: start, End: end, IsExclusive: true}
+	}
+	assert.Nil(t, m.Lock("f", lock(0, 9)))
+	assert.Nil(t, m.Lock("f", lock(10, 19)))
+	assert.Nil(t, m.Lock("f", lock(30, 39)))
+	assert.Equal(t, 2, len(m.locks["f"]))
+	assert.Nil(t, m.Lock("f", lock(15, 34)))
+	assert.Equal(t, 1, len(m.locks["f"]))
+	assert.Equal(t, uint64(0), m.locks["f"][0].Start)
+	assert.Equal(t, uint64(39), m.locks["f"][0].End)
+
+	// a lock to the end of the file is not adjacent to a lock at the start of the file
+	assert.Nil(t, m.Lock("g", lock(100, math.MaxUint64)))
+	assert.Nil(t, m.Lock("g", lock(0, 9)))
+	assert.Equal(t, 2, len(m.locks["g"]))
+}
+
+func countSession(m *RangeLockManager, key, session string) (count int) {
+	for _, l := range m.locks[key] {
+		if l.Session == session {
+			count++
+		}
+	}
+	return
+}
+
+func TestRangeLockRenewReportsLostLocks(t *testing.T) {
+	m := &RangeLockManager{locks: make(map[string][]*RangeLock)}
+	lock := func(session string, expiredAtNs int64) *RangeLock {
+		return &RangeLock{Session: session, Owner: 1, Start: 0, End: 9, IsExclusive: true, ExpiredAtNs: expiredAtNs}
+	}
+	later := time.Now().Add(time.Minute).UnixNano()
+
+	assert.Nil(t, m.Lock("f", lock("a", later)))
+	assert.True(t, m.Renew("f", "a", later))
+	assert.False(t, m.Renew("g", "a", later), "never locked")
+
+	// the lock expires, and is taken by another session before being renewed
+	assert.Nil(t, m.Lock("h", lock("a", time.Now().Add(-time.Second).UnixNano())))
+	assert.Nil(t, m.Lock("h", lock("b", later)))
+	assert.False(t, m.Renew("h", "a", later))
+	assert.Equal(t, 0, countSession(m, "h", "a"))
+}
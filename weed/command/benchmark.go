@@ -43,6 +43,7 @@ type BenchmarkOptions struct {
 	grpcDialOption   grpc.DialOption
 	masterClient     *wdclient.MasterClient
 	fsync            *bool
+	diskIoDir        *string
 }
 
 var (
@@ -69,6 +70,7 @@ func init() {
 	b.cpuprofile = cmdBenchmark.Flag.String("cpuprofile", "", "cpu profile output file")
 	b.maxCpu = cmdBenchmark.Flag.Int("maxCpu", 0, "maximum number of CPUs. 0 means all available CPUs")
 	b.fsync = cmdBenchmark.Flag.Bool("fsync", false, "flush data to disk after write")
+	b.diskIoDir = cmdBenchmark.Flag.String("diskIoDir", "", "benchmark the volume file IO in this local directory without servers, with the regular system calls and with io_uring")
 	sharedBytes = make([]byte, 1024)
 }
 
@@ -101,6 +103,10 @@ var cmdBenchmark = &Command{
   After benchmarking, you can clean up the written data by deleting the benchmark collection
     http://localhost:9333/col/delete?collection=benchmark
 
+  To compare the volume file IO paths of the volume server, the regular system calls and io_uring,
+  write and read a local volume without any servers:
+    weed benchmark -diskIoDir=/data -c=64 -n=100000
+
   `,
 }
 
@@ -129,6 +135,11 @@ func runBenchmark(cmd *Command, args []string) bool {
 		defer pprof.StopCPUProfile()
 	}
 
+	if *b.diskIoDir != "" {
+		benchDiskIo(*b.diskIoDir)
+		return true
+	}
+
 	b.masterClient = wdclient.NewMasterClient(b.grpcDialOption, "", "client", "", "", "", *pb.ServerAddresses(*b.masters).ToServiceDiscovery())
 	ctx := context.Background()
 	go b.masterClient.KeepConnectedToMaster(ctx)
@@ -0,0 +1,141 @@
+package command
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// benchDiskIo writes and reads needles of a local volume in the directory, without the master and volume servers,
+// first with the regular system calls and then with io_uring, to compare the volume file IO paths.
+func benchDiskIo(dir string) {
+	for _, useIoUring := range []bool{false, true} {
+		ioPath := "System Calls"
+		if useIoUring {
+			if err := backend.EnableIoUring(backend.IoUringDefaultEntries); err != nil {
+				fmt.Printf("\nskip the io_uring benchmark: %v\n", err)
+				continue
+			}
+			ioPath = "io_uring"
+		}
+		if err := benchDiskIoPath(dir, ioPath); err != nil {
+			fmt.Printf("%s benchmark: %v\n", ioPath, err)
+		}
+		if useIoUring {
+			backend.DisableIoUring()
+		}
+	}
+}
+
+func benchDiskIoPath(dir string, ioPath string) error {
+	volumeDir, err := os.MkdirTemp(dir, "benchmark")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(volumeDir)
+
+	store := storage.NewStore(nil, "localhost", 0, 0, "", []string{volumeDir}, []int32{1},
+		[]util.MinFreeSpace{{}}, "", storage.NeedleMapInMemory, []types.DiskType{types.HardDriveType}, 0)
+	defer store.Close()
+	vid := needle.VolumeId(1)
+	if err = store.AddVolume(vid, "", storage.NeedleMapInMemory, "000", "", 0, 0, types.HardDriveType, 0); err != nil {
+		return err
+	}
+
+	if *b.write {
+		ids := make(chan int)
+		finishChan := make(chan bool)
+		writeStats = newStats(*b.concurrency)
+		for i := 0; i < *b.concurrency; i++ {
+			wait.Add(1)
+			go writeDiskIoNeedles(store, vid, ids, &writeStats.localStats[i])
+		}
+		writeStats.start = time.Now()
+		writeStats.total = *b.numberOfFiles
+		go writeStats.checkProgress(ioPath+" Writing Benchmark", finishChan)
+		for i := 1; i <= *b.numberOfFiles; i++ {
+			ids <- i
+		}
+		close(ids)
+		wait.Wait()
+		writeStats.end = time.Now()
+		wait.Add(1)
+		finishChan <- true
+		wait.Wait()
+		writeStats.printStats()
+	}
+
+	if *b.read {
+		ids := make(chan int)
+		finishChan := make(chan bool)
+		readStats = newStats(*b.concurrency)
+		for i := 0; i < *b.concurrency; i++ {
+			wait.Add(1)
+			go readDiskIoNeedles(store, vid, ids, &readStats.localStats[i])
+		}
+		readStats.start = time.Now()
+		readStats.total = *b.numberOfFiles
+		go readStats.checkProgress(ioPath+" Randomly Reading Benchmark", finishChan)
+		random := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for i := 0; i < *b.numberOfFiles; i++ {
+			if *b.sequentialRead {
+				ids <- i + 1
+			} else {
+				ids <- random.Intn(*b.numberOfFiles) + 1
+			}
+		}
+		close(ids)
+		wait.Wait()
+		readStats.end = time.Now()
+		wait.Add(1)
+		finishChan <- true
+		wait.Wait()
+		readStats.printStats()
+	}
+	return nil
+}
+
+func writeDiskIoNeedles(store *storage.Store, vid needle.VolumeId, ids chan int, s *stat) {
+	defer wait.Done()
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for id := range ids {
+		start := time.Now()
+		n := &needle.Needle{
+			Id:   types.NeedleId(id),
+			Data: make([]byte, *b.fileSize+random.Intn(64)),
+		}
+		n.Checksum = needle.NewCRC(n.Data)
+		if _, err := store.WriteVolumeNeedle(vid, n, false, *b.fsync, 0); err != nil {
+			s.failed++
+			fmt.Printf("Failed to write needle %d: %v\n", id, err)
+			continue
+		}
+		s.completed++
+		s.transferred += int64(len(n.Data))
+		writeStats.addSample(time.Now().Sub(start))
+	}
+}
+
+func readDiskIoNeedles(store *storage.Store, vid needle.VolumeId, ids chan int, s *stat) {
+	defer wait.Done()
+	readOption := &storage.ReadOption{HasSlowRead: true, ReadBufferSize: 4 * 1024 * 1024}
+	for id := range ids {
+		start := time.Now()
+		n := &needle.Needle{Id: types.NeedleId(id)}
+		if _, err := store.ReadVolumeNeedle(vid, n, readOption, nil); err != nil {
+			s.failed++
+			fmt.Printf("Failed to read needle %d: %v\n", id, err)
+			continue
+		}
+		s.completed++
+		s.transferred += int64(len(n.Data))
+		readStats.addSample(time.Now().Sub(start))
+	}
+}
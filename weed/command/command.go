@@ -32,7 +32,9 @@ var Commands = []*Command{
 	cmdMaster,
 	cmdMasterFollower,
 	cmdMount,
+	cmdMountCtl,
 	cmdMqBroker,
+	cmdNfs,
 	cmdS3,
 	cmdScaffold,
 	cmdServer,
@@ -45,6 +45,7 @@ type VolumeFileScanner4Fix struct {
 
 func (scanner *VolumeFileScanner4Fix) VisitSuperBlock(superBlock super_block.SuperBlock) error {
 	scanner.version = superBlock.Version
+	glog.V(1).Infof("needle version %d", superBlock.Version)
 	return nil
 }
 
@@ -1,8 +1,13 @@
 package command
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 type MountOptions struct {
@@ -20,6 +25,8 @@ type MountOptions struct {
 	cacheDirForRead    *string
 	cacheDirForWrite   *string
 	cacheSizeMBForRead *int64
+	cacheSizeMBForPin  *int64
+	readAheadMemoryMB  *int64
 	dataCenter         *string
 	allowOthers        *bool
 	umaskString        *string
@@ -32,6 +39,10 @@ type MountOptions struct {
 	debugPort          *int
 	localSocket        *string
 	disableXAttr       *bool
+	enableLocks        *bool
+	offlineWriteBack   *bool
+	consistency        *string
+	zeroCopyRead       *bool
 	extraOptions       []string
 }
 
@@ -57,6 +68,8 @@ func init() {
 	mountOptions.concurrentWriters = cmdMount.Flag.Int("concurrentWriters", 32, "limit concurrent goroutine writers")
 	mountOptions.cacheDirForRead = cmdMount.Flag.String("cacheDir", os.TempDir(), "local cache directory for file chunks and meta data")
 	mountOptions.cacheSizeMBForRead = cmdMount.Flag.Int64("cacheCapacityMB", 128, "file chunk read cache capacity in MB")
+	mountOptions.cacheSizeMBForPin = cmdMount.Flag.Int64("cacheCapacityMBForPinned", 0, "capacity in MB of the chunk cache region for the pinned directories, not evicted by the read cache")
+	mountOptions.readAheadMemoryMB = cmdMount.Flag.Int64("readAheadMemoryMB", filer.DefaultReadAheadMemoryMB, "memory in MB of the chunks read ahead, shared by all the open files")
 	mountOptions.cacheDirForWrite = cmdMount.Flag.String("cacheDirWrite", "", "buffer writes mostly for large files")
 	mountOptions.dataCenter = cmdMount.Flag.String("dataCenter", "", "prefer to write to the data center")
 	mountOptions.allowOthers = cmdMount.Flag.Bool("allowOthers", true, "allows other users to access the file system")
@@ -70,6 +83,10 @@ func init() {
 	mountOptions.debugPort = cmdMount.Flag.Int("debug.port", 6061, "http port for debugging")
 	mountOptions.localSocket = cmdMount.Flag.String("localSocket", "", "default to /tmp/seaweedfs-mount-<mount_dir_hash>.sock")
 	mountOptions.disableXAttr = cmdMount.Flag.Bool("disableXAttr", false, "disable xattr")
+	mountOptions.enableLocks = cmdMount.Flag.Bool("locks", false, "support posix record locks and flock across mounts, kept by the filers")
+	mountOptions.consistency = cmdMount.Flag.String("consistency", "relaxed", "[relaxed|close-to-open] close-to-open revalidates files with the filer on open, and invalidates the kernel caches on changes by other clients")
+	mountOptions.offlineWriteBack = cmdMount.Flag.Bool("offline", false, "journal the changes locally when the filer is unreachable, and replay them on reconnect")
+	mountOptions.zeroCopyRead = cmdMount.Flag.Bool("zeroCopyRead", true, "serve the reads from the chunk cache without copying, spliced from the cache files where supported")
 
 	mountCpuProfile = cmdMount.Flag.String("cpuprofile", "", "cpu profile output file")
 	mountMemProfile = cmdMount.Flag.String("memprofile", "", "memory profile output file")
@@ -93,3 +110,19 @@ var cmdMount = &Command{
 
   `,
 }
+
+// mountLocalSocket is the default control socket of the mount, hashed from the absolute mount directory
+// with its symbolic links resolved, so the mount and weed mount.ctl agree on it for any path to the directory
+func mountLocalSocket(dir string) string {
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+	if realDir, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = realDir
+	}
+	mountDirHash := util.HashToInt32([]byte(dir))
+	if mountDirHash < 0 {
+		mountDirHash = -mountDirHash
+	}
+	return fmt.Sprintf("/tmp/seaweedfs-mount-%d.sock", mountDirHash)
+}
@@ -0,0 +1,322 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/mount_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/resolver/passthrough"
+)
+
+var (
+	mountCtlOptions MountCtlOptions
+)
+
+type MountCtlOptions struct {
+	dir         *string
+	localSocket *string
+	timeout     *time.Duration
+}
+
+func init() {
+	cmdMountCtl.Run = runMountCtl // break init cycle
+	mountCtlOptions.dir = cmdMountCtl.Flag.String("dir", ".", "the mount directory, same as how \"weed mount -dir=<mount_directory>\" was started")
+	mountCtlOptions.localSocket = cmdMountCtl.Flag.String("localSocket", "", "the mount local socket, if \"weed mount\" was started with \"-localSocket\"")
+	mountCtlOptions.timeout = cmdMountCtl.Flag.Duration("timeout", 10*time.Minute, "timeout of the request, flushing and pinning can take a while")
+}
+
+var cmdMountCtl = &Command{
+	UsageLine: "mount.ctl -dir=<mount_directory> <command> [args]",
+	Short:     "inspect and control a running mount",
+	Long: `inspect and control a running "weed mount" via its local unix socket
+
+	The commands are:
+
+		handles [path]         list the open files and their dirty bytes
+		flush [path]           upload the dirty data and metadata of the open files
+		drop <path>            drop the cached metadata, the cached chunks and the kernel page cache
+		cachestats             show the chunk cache hit ratios and usage
+		readers [path]         show the read pattern and the read ahead of the open files
+		tune [-concurrentWriters=n] [-cacheCapacityMB=n] [-cacheCapacityMBForPinned=n]
+		                       change the upload concurrency and the chunk cache sizes
+		pin [-wait] <path>     load the metadata of the directory tree, and keep it for offline use,
+		                       and warm its chunks into the pinned chunk cache region
+		unpin <path>           release a pinned directory tree
+		pins                   list the pinned directories and their warmed chunks
+
+	The paths can be either relative to the mount directory, or the local paths under the mount directory.
+
+	The directories can also be pinned by the "user.pin" extended attribute, which is kept in the filer,
+	so they are pinned again when looked up after the mount restarts:
+
+		setfattr -n user.pin -v 1 <mount_directory>/path/to/dir
+		setfattr -x user.pin <mount_directory>/path/to/dir
+
+`,
+}
+
+func runMountCtl(cmd *Command, args []string) bool {
+
+	if len(args) == 0 {
+		return false
+	}
+
+	dir := util.ResolvePath(*mountCtlOptions.dir)
+	if absDir, err := filepath.Abs(dir); err == nil {
+		dir = absDir
+	}
+
+	localSocket := *mountCtlOptions.localSocket
+	if localSocket == "" {
+		localSocket = mountLocalSocket(dir)
+	}
+
+	clientConn, err := grpc.Dial("passthrough:///unix://"+localSocket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fmt.Printf("connect to mount %s via %s: %v\n", dir, localSocket, err)
+		return true
+	}
+	defer clientConn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *mountCtlOptions.timeout)
+	defer cancel()
+
+	ctl := &mountCtl{
+		dir:    dir,
+		client: mount_pb.NewSeaweedMountClient(clientConn),
+	}
+	if err = ctl.run(ctx, args[0], args[1:]); err != nil {
+		fmt.Printf("%s: %v\n", args[0], err)
+	}
+
+	return true
+}
+
+type mountCtl struct {
+	dir    string
+	client mount_pb.SeaweedMountClient
+}
+
+func (ctl *mountCtl) run(ctx context.Context, command string, args []string) error {
+	switch command {
+	case "handles":
+		return ctl.handles(ctx, args)
+	case "flush":
+		return ctl.flush(ctx, args)
+	case "drop":
+		return ctl.drop(ctx, args)
+	case "cachestats":
+		return ctl.cacheStats(ctx)
+	case "readers":
+		return ctl.readers(ctx, args)
+	case "tune":
+		return ctl.tune(ctx, args)
+	case "pin":
+		return ctl.pin(ctx, args)
+	case "unpin":
+		return ctl.unpin(ctx, args)
+	case "pins":
+		return ctl.pins(ctx)
+	}
+	return fmt.Errorf("unknown command, see \"weed help mount.ctl\"")
+}
+
+// mountPath converts the local path under the mount directory to the path relative to the mount directory
+func (ctl *mountCtl) mountPath(p string) string {
+	if p == ctl.dir {
+		return "/"
+	}
+	if strings.HasPrefix(p, ctl.dir+"/") {
+		return strings.TrimPrefix(p, ctl.dir)
+	}
+	return "/" + strings.TrimPrefix(p, "/")
+}
+
+func (ctl *mountCtl) optionalPath(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return ctl.mountPath(args[0])
+}
+
+func (ctl *mountCtl) requiredPath(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("missing the path")
+	}
+	return ctl.mountPath(args[0]), nil
+}
+
+func (ctl *mountCtl) handles(ctx context.Context, args []string) error {
+	resp, err := ctl.client.ListHandles(ctx, &mount_pb.ListHandlesRequest{
+		PathPrefix: ctl.optionalPath(args),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-10s %-10s %-6s %12s %12s %-6s %s\n", "HANDLE", "INODE", "OPENS", "SIZE", "DIRTY", "META", "PATH")
+	for _, h := range resp.Handles {
+		fmt.Printf("%-10d %-10d %-6d %12d %12d %-6v %s\n", h.HandleId, h.Inode, h.OpenCount, h.FileSize, h.DirtyBytes, h.DirtyMetadata, h.Path)
+	}
+	fmt.Printf("%d open files, %s dirty\n", len(resp.Handles), util.BytesToHumanReadable(uint64(resp.TotalDirtyBytes)))
+	return nil
+}
+
+func (ctl *mountCtl) flush(ctx context.Context, args []string) error {
+	resp, err := ctl.client.FlushAll(ctx, &mount_pb.FlushAllRequest{
+		PathPrefix: ctl.optionalPath(args),
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range resp.Errors {
+		fmt.Printf("failed to flush %s\n", e)
+	}
+	fmt.Printf("flushed %d open files\n", resp.Flushed)
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("%d open files failed to flush", len(resp.Errors))
+	}
+	return nil
+}
+
+func (ctl *mountCtl) drop(ctx context.Context, args []string) error {
+	p, err := ctl.requiredPath(args)
+	if err != nil {
+		return err
+	}
+	resp, err := ctl.client.DropCaches(ctx, &mount_pb.DropCachesRequest{
+		Path: p,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("dropped %d cached directories and %d cached chunks under %s\n", resp.Directories, resp.Chunks, p)
+	return nil
+}
+
+func (ctl *mountCtl) cacheStats(ctx context.Context) error {
+	resp, err := ctl.client.GetCacheStats(ctx, &mount_pb.GetCacheStatsRequest{})
+	if err != nil {
+		return err
+	}
+	total := resp.MemoryHits + resp.DiskHits + resp.PinnedHits + resp.Misses
+	ratio := func(n int64) float64 {
+		if total == 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(total)
+	}
+	fmt.Printf("chunk reads:  %d\n", total)
+	fmt.Printf("memory hits:  %d (%.1f%%)\n", resp.MemoryHits, ratio(resp.MemoryHits))
+	fmt.Printf("disk hits:    %d (%.1f%%)\n", resp.DiskHits, ratio(resp.DiskHits))
+	fmt.Printf("pinned hits:  %d (%.1f%%)\n", resp.PinnedHits, ratio(resp.PinnedHits))
+	fmt.Printf("misses:       %d (%.1f%%)\n", resp.Misses, ratio(resp.Misses))
+	fmt.Printf("memory:       %d chunks\n", resp.MemoryEntries)
+	fmt.Printf("disk:         %s / %s\n", util.BytesToHumanReadable(uint64(resp.DiskUsedBytes)), util.BytesToHumanReadable(uint64(resp.DiskCapacityBytes)))
+	fmt.Printf("pinned:       %d chunks, %s / %s\n", resp.PinnedChunks, util.BytesToHumanReadable(uint64(resp.PinnedUsedBytes)), util.BytesToHumanReadable(uint64(resp.PinnedCapacityBytes)))
+	fmt.Printf("open files:   %d\n", resp.OpenHandles)
+	return nil
+}
+
+func (ctl *mountCtl) readers(ctx context.Context, args []string) error {
+	resp, err := ctl.client.GetReaderStats(ctx, &mount_pb.GetReaderStatsRequest{
+		PathPrefix: ctl.optionalPath(args),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-10s %-10s %10s %12s %8s %8s %8s %6s %12s %s\n", "HANDLE", "MODE", "READS", "BYTES", "HITS", "MISSES", "AHEAD", "WINDOW", "THROUGHPUT", "PATH")
+	for _, r := range resp.Handles {
+		mode := "sequential"
+		if r.IsRandomMode {
+			mode = "random"
+		}
+		fmt.Printf("%-10d %-10s %10d %12d %8d %8d %8d %6d %10s/s %s\n", r.HandleId, mode, r.Reads, r.BytesRead, r.ChunkHits, r.ChunkMisses,
+			r.PrefetchedChunks, r.ReadAheadWindow, util.BytesToHumanReadable(uint64(r.Throughput)), r.Path)
+	}
+	return nil
+}
+
+func (ctl *mountCtl) tune(ctx context.Context, args []string) error {
+	tuneCommand := flag.NewFlagSet("tune", flag.ContinueOnError)
+	concurrentWriters := tuneCommand.Int("concurrentWriters", 0, "limit concurrent goroutine writers, 0 to keep unchanged")
+	cacheCapacityMB := tuneCommand.Int64("cacheCapacityMB", 0, "file chunk read cache capacity in MB, 0 to keep unchanged")
+	pinnedCacheCapacityMB := tuneCommand.Int64("cacheCapacityMBForPinned", 0, "pinned chunk cache region capacity in MB, 0 to keep unchanged")
+	if err := tuneCommand.Parse(args); err != nil {
+		return err
+	}
+	resp, err := ctl.client.Tune(ctx, &mount_pb.TuneRequest{
+		ConcurrentWriters:     int32(*concurrentWriters),
+		CacheCapacityMb:       *cacheCapacityMB,
+		PinnedCacheCapacityMb: *pinnedCacheCapacityMB,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("concurrentWriters: %d\n", resp.ConcurrentWriters)
+	fmt.Printf("cacheCapacityMB:   %d\n", resp.CacheCapacityMb)
+	fmt.Printf("cacheCapacityMBForPinned: %d\n", resp.PinnedCacheCapacityMb)
+	return nil
+}
+
+func (ctl *mountCtl) pin(ctx context.Context, args []string) error {
+	pinCommand := flag.NewFlagSet("pin", flag.ContinueOnError)
+	wait := pinCommand.Bool("wait", false, "wait until the chunks are warmed")
+	if err := pinCommand.Parse(args); err != nil {
+		return err
+	}
+	p, err := ctl.requiredPath(pinCommand.Args())
+	if err != nil {
+		return err
+	}
+	resp, err := ctl.client.Pin(ctx, &mount_pb.PinRequest{
+		Path:           p,
+		WaitForWarming: *wait,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pinned %s with %d directories and %d files\n", p, resp.Directories, resp.Files)
+	if *wait {
+		fmt.Printf("warmed %d chunks of %s\n", resp.Chunks, util.BytesToHumanReadable(uint64(resp.Bytes)))
+	}
+	return nil
+}
+
+func (ctl *mountCtl) unpin(ctx context.Context, args []string) error {
+	p, err := ctl.requiredPath(args)
+	if err != nil {
+		return err
+	}
+	if _, err = ctl.client.Unpin(ctx, &mount_pb.UnpinRequest{
+		Path: p,
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("unpinned %s\n", p)
+	return nil
+}
+
+func (ctl *mountCtl) pins(ctx context.Context) error {
+	resp, err := ctl.client.ListPins(ctx, &mount_pb.ListPinsRequest{})
+	if err != nil {
+		return err
+	}
+	for _, pinned := range resp.Pins {
+		state := "warmed"
+		if pinned.Warming {
+			state = "warming"
+		}
+		fmt.Printf("%-8s %8d chunks %10s  %s\n", state, pinned.Chunks, util.BytesToHumanReadable(uint64(pinned.Bytes)), pinned.Path)
+		if pinned.Error != "" {
+			fmt.Printf("         last error: %s\n", pinned.Error)
+		}
+	}
+	return nil
+}
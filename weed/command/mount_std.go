@@ -49,6 +49,11 @@ func runMount(cmd *Command, args []string) bool {
 		return false
 	}
 
+	if c := *mountOptions.consistency; c != mount.ConsistencyRelaxed && c != mount.ConsistencyCloseToOpen {
+		fmt.Printf("unknown consistency %s\n", c)
+		return false
+	}
+
 	if len(args) > 0 {
 		return false
 	}
@@ -104,11 +109,7 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 
 	// start on local unix socket
 	if *option.localSocket == "" {
-		mountDirHash := util.HashToInt32([]byte(dir))
-		if mountDirHash < 0 {
-			mountDirHash = -mountDirHash
-		}
-		*option.localSocket = fmt.Sprintf("/tmp/seaweedfs-mount-%d.sock", mountDirHash)
+		*option.localSocket = mountLocalSocket(dir)
 	}
 	if err := os.Remove(*option.localSocket); err != nil && !os.IsNotExist(err) {
 		glog.Fatalf("Failed to remove %s, error: %s", *option.localSocket, err.Error())
@@ -178,7 +179,7 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 		SingleThreaded:           false,
 		DisableXAttrs:            *option.disableXAttr,
 		Debug:                    *option.debug,
-		EnableLocks:              false,
+		EnableLocks:              *option.enableLocks,
 		ExplicitDataCacheControl: false,
 		DirectMount:              true,
 		DirectMountFlags:         0,
@@ -235,6 +236,8 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 		ConcurrentWriters:  *option.concurrentWriters,
 		CacheDirForRead:    *option.cacheDirForRead,
 		CacheSizeMBForRead: *option.cacheSizeMBForRead,
+		CacheSizeMBForPin:  *option.cacheSizeMBForPin,
+		ReadAheadMemoryMB:  *option.readAheadMemoryMB,
 		CacheDirForWrite:   cacheDirForWrite,
 		DataCenter:         *option.dataCenter,
 		Quota:              int64(*option.collectionQuota) * 1024 * 1024,
@@ -248,6 +251,10 @@ func RunMount(option *MountOptions, umask os.FileMode) bool {
 		Cipher:             cipher,
 		UidGidMapper:       uidGidMapper,
 		DisableXAttr:       *option.disableXAttr,
+		EnableLocks:        *option.enableLocks,
+		OfflineWriteBack:   *option.offlineWriteBack,
+		Consistency:        *option.consistency,
+		ZeroCopyRead:       *option.zeroCopyRead,
 	})
 
 	// create mount root
@@ -0,0 +1,34 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMountLocalSocket(t *testing.T) {
+	dir := t.TempDir()
+	mountDir := filepath.Join(dir, "mnt")
+	if err := os.Mkdir(mountDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(mountDir, link); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	socket := mountLocalSocket(mountDir)
+	for _, p := range []string{"mnt", "./mnt/", link, "link"} {
+		if got := mountLocalSocket(p); got != socket {
+			t.Errorf("socket of %s: %s, want %s", p, got, socket)
+		}
+	}
+}
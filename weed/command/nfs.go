@@ -0,0 +1,165 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/nfs"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/security"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+var (
+	nfsStandaloneOptions NfsOption
+)
+
+type NfsOption struct {
+	filer       *string
+	ipBind      *string
+	port        *int
+	portmapper  *bool
+	exports     *string
+	collection  *string
+	replication *string
+	disk        *string
+	cacheDir    *string
+	cacheSizeMB *int64
+}
+
+func init() {
+	cmdNfs.Run = runNfs // break init cycle
+	nfsStandaloneOptions.filer = cmdNfs.Flag.String("filer", "localhost:8888", "filer server address")
+	nfsStandaloneOptions.ipBind = cmdNfs.Flag.String("ip.bind", "", "ip address to bind to. Default listen to all.")
+	nfsStandaloneOptions.port = cmdNfs.Flag.Int("port", 2049, "nfs server listen port, serving the MOUNT, NFS and NLM programs")
+	nfsStandaloneOptions.portmapper = cmdNfs.Flag.Bool("portmapper", false, "also serve the port mapper on port 111, so the clients can find the programs without the port options")
+	nfsStandaloneOptions.exports = cmdNfs.Flag.String("exports", "", "the exports file in the /etc/exports format. Default to export the whole filer to all clients read-only, with root squashed.")
+	nfsStandaloneOptions.collection = cmdNfs.Flag.String("collection", "", "collection to create the files")
+	nfsStandaloneOptions.replication = cmdNfs.Flag.String("replication", "", "replication to create the files")
+	nfsStandaloneOptions.disk = cmdNfs.Flag.String("disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
+	nfsStandaloneOptions.cacheDir = cmdNfs.Flag.String("cacheDir", os.TempDir(), "local cache directory for file chunks, and for the file handles kept across restarts")
+	nfsStandaloneOptions.cacheSizeMB = cmdNfs.Flag.Int64("cacheCapacityMB", 0, "local cache capacity in MB")
+}
+
+var cmdNfs = &Command{
+	UsageLine: "nfs -port=2049 -filer=<ip:port> -exports=/etc/seaweedfs/exports",
+	Short:     "start a NFSv3 server that is backed by a filer",
+	Long: `start a userspace NFS version 3 server that is backed by a filer.
+
+	The MOUNT, NFS and NLM programs are all served on the same port.
+	The filer directories are shared to the clients by the exports file, in the /etc/exports format:
+
+		/buckets/shared  10.0.0.0/8(rw,no_root_squash) 192.168.1.7(ro)
+		/                127.0.0.1(rw)
+
+	The supported options are ro, rw, root_squash, no_root_squash, all_squash, anonuid and anongid.
+
+	To mount without the port mapper:
+
+		mount -t nfs -o vers=3,proto=tcp,port=2049,mountport=2049,nolock <host>:/buckets/shared /mnt
+
+	With "-portmapper", the "nolock" option can be left out to use the NLM locks,
+	which are shared with the locks of "weed mount".
+
+`,
+}
+
+func runNfs(cmd *Command, args []string) bool {
+
+	util.LoadSecurityConfiguration()
+
+	glog.V(0).Infof("Starting Seaweed NFS Server %s at port %d", util.Version(), *nfsStandaloneOptions.port)
+
+	return nfsStandaloneOptions.startNfsServer()
+
+}
+
+func (no *NfsOption) startNfsServer() bool {
+
+	var exports *nfs.Exports
+	var err error
+	if *no.exports == "" {
+		exports, err = nfs.ParseExports(nfs.DefaultExports)
+	} else {
+		exports, err = nfs.LoadExports(*no.exports)
+	}
+	if err != nil {
+		glog.Fatalf("load exports %s: %v", *no.exports, err)
+	}
+	for _, export := range exports.List() {
+		glog.V(0).Infof("export %s to %d client rules", export.Path, len(export.Rules))
+	}
+
+	// parse filer grpc address
+	filerAddress := pb.ServerAddress(*no.filer)
+
+	grpcDialOption := security.LoadClientTLS(util.GetViper(), "grpc.client")
+
+	var cipher bool
+	// connect to filer
+	for {
+		err := pb.WithGrpcFilerClient(false, 0, filerAddress, grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+			resp, err := client.GetFilerConfiguration(context.Background(), &filer_pb.GetFilerConfigurationRequest{})
+			if err != nil {
+				return fmt.Errorf("get filer %s configuration: %v", filerAddress, err)
+			}
+			cipher = resp.Cipher
+			return nil
+		})
+		if err != nil {
+			glog.V(0).Infof("wait to connect to filer %s grpc address %s", *no.filer, filerAddress.ToGrpcAddress())
+			time.Sleep(time.Second)
+		} else {
+			glog.V(0).Infof("connected to filer %s grpc address %s", *no.filer, filerAddress.ToGrpcAddress())
+			break
+		}
+	}
+
+	nfsServer, err := nfs.NewNfsServer(&nfs.NfsOption{
+		Filer:          filerAddress,
+		GrpcDialOption: grpcDialOption,
+		Port:           *no.port,
+		Exports:        exports,
+		Collection:     *no.collection,
+		Replication:    *no.replication,
+		DiskType:       *no.disk,
+		Cipher:         cipher,
+		CacheDir:       util.ResolvePath(*no.cacheDir),
+		CacheSizeMB:    *no.cacheSizeMB,
+	})
+	if err != nil {
+		glog.Fatalf("NFS Server startup error: %v", err)
+	}
+
+	if *no.portmapper {
+		portmapperAddress := util.JoinHostPort(*no.ipBind, 111)
+		portmapperListener, err := net.Listen("tcp", portmapperAddress)
+		if err != nil {
+			glog.Fatalf("NFS Server port mapper listener on %s error: %v", portmapperAddress, err)
+		}
+		go func() {
+			if err := nfsServer.Serve(portmapperListener); err != nil {
+				glog.Fatalf("NFS Server port mapper fail to serve: %v", err)
+			}
+		}()
+	}
+
+	listenAddress := util.JoinHostPort(*no.ipBind, *no.port)
+	nfsListener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		glog.Fatalf("NFS Server listener on %s error: %v", listenAddress, err)
+	}
+
+	glog.V(0).Infof("Start Seaweed NFS Server %s at %s", util.Version(), listenAddress)
+	if err = nfsServer.Serve(nfsListener); err != nil {
+		glog.Fatalf("NFS Server Fail to serve: %v", err)
+	}
+
+	return true
+
+}
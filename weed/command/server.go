@@ -126,7 +126,7 @@ func init() {
 	serverOptions.v.port = cmdServer.Flag.Int("volume.port", 8080, "volume server http listen port")
 	serverOptions.v.portGrpc = cmdServer.Flag.Int("volume.port.grpc", 0, "volume server grpc listen port")
 	serverOptions.v.publicPort = cmdServer.Flag.Int("volume.port.public", 0, "volume server public port")
-	serverOptions.v.indexType = cmdServer.Flag.String("volume.index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge] mode for memory~performance balance.")
+	serverOptions.v.indexType = cmdServer.Flag.String("volume.index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge|lsm] mode for memory~performance balance. lsm shares one index per disk, for disks with many small files.")
 	serverOptions.v.diskType = cmdServer.Flag.String("volume.disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
 	serverOptions.v.fixJpgOrientation = cmdServer.Flag.Bool("volume.images.fix.orientation", false, "Adjust jpg orientation when uploading.")
 	serverOptions.v.readMode = cmdServer.Flag.String("volume.readMode", "proxy", "[local|proxy|redirect] how to deal with non-local volume: 'not found|read in remote node|redirect volume location'.")
@@ -139,9 +139,15 @@ func init() {
 	serverOptions.v.preStopSeconds = cmdServer.Flag.Int("volume.preStopSeconds", 10, "number of seconds between stop send heartbeats and stop volume server")
 	serverOptions.v.pprof = cmdServer.Flag.Bool("volume.pprof", false, "enable pprof http handlers. precludes --memprofile and --cpuprofile")
 	serverOptions.v.idxFolder = cmdServer.Flag.String("volume.dir.idx", "", "directory to store .idx files")
+	serverOptions.v.blockDevices = cmdServer.Flag.String("volume.dir.blockDevice", "", "comma separated block devices or preallocated files to store the .dat files of each volume directory, empty to use the directory")
+	serverOptions.v.blockDevicesFormat = cmdServer.Flag.Bool("volume.dir.blockDevice.format", false, "format the block devices of -volume.dir.blockDevice whose first 4KB is empty, erasing them")
 	serverOptions.v.inflightUploadDataTimeout = cmdServer.Flag.Duration("volume.inflightUploadDataTimeout", 60*time.Second, "inflight upload data wait timeout of volume servers")
 	serverOptions.v.hasSlowRead = cmdServer.Flag.Bool("volume.hasSlowRead", true, "<experimental> if true, this prevents slow reads from blocking other requests, but large file read P99 latency will increase.")
+	serverOptions.v.ioUring = cmdServer.Flag.Bool("volume.ioUring", false, "<experimental> batch the volume file reads, writes and syncs with io_uring on linux, falling back to the regular system calls if the kernel does not support it")
 	serverOptions.v.readBufferSizeMB = cmdServer.Flag.Int("volume.readBufferSizeMB", 4, "<experimental> larger values can optimize query performance but will increase some memory usage,Use with hasSlowRead normally")
+	serverOptions.v.scrubMBPerSecond = cmdServer.Flag.Int("volume.scrubMBps", 0, "verify the needle CRCs and ec parity in the background, reading each disk at most this many mega bytes per second. 0 disables the scrubbing.")
+	serverOptions.v.scrubInterval = cmdServer.Flag.Duration("volume.scrubInterval", 7*24*time.Hour, "wait time between two scrubbing passes of a disk")
+	serverOptions.v.scrubRepair = cmdServer.Flag.Bool("volume.scrubRepair", true, "repair the corrupt needles found by the scrubbing from the other replicas or ec shards")
 
 	s3Options.port = cmdServer.Flag.Int("s3.port", 8333, "s3 server http listen port")
 	s3Options.portHttps = cmdServer.Flag.Int("s3.port.https", 0, "s3 server https listen port")
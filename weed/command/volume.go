@@ -29,6 +29,7 @@ import (
 	weed_server "github.com/seaweedfs/seaweedfs/weed/server"
 	stats_collect "github.com/seaweedfs/seaweedfs/weed/stats"
 	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
@@ -43,6 +44,8 @@ type VolumeServerOptions struct {
 	folders                   []string
 	folderMaxLimits           []int32
 	idxFolder                 *string
+	blockDevices              *string
+	blockDevicesFormat        *bool
 	ip                        *string
 	publicUrl                 *string
 	bindIp                    *string
@@ -69,8 +72,12 @@ type VolumeServerOptions struct {
 	// pulseSeconds          *int
 	inflightUploadDataTimeout *time.Duration
 	hasSlowRead               *bool
+	ioUring                   *bool
 	readBufferSizeMB          *int
 	ldbTimeout                *int64
+	scrubMBPerSecond          *int
+	scrubInterval             *time.Duration
+	scrubRepair               *bool
 }
 
 func init() {
@@ -87,7 +94,7 @@ func init() {
 	v.idleConnectionTimeout = cmdVolume.Flag.Int("idleTimeout", 30, "connection idle seconds")
 	v.dataCenter = cmdVolume.Flag.String("dataCenter", "", "current volume server's data center name")
 	v.rack = cmdVolume.Flag.String("rack", "", "current volume server's rack name")
-	v.indexType = cmdVolume.Flag.String("index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge] mode for memory~performance balance.")
+	v.indexType = cmdVolume.Flag.String("index", "memory", "Choose [memory|leveldb|leveldbMedium|leveldbLarge|lsm] mode for memory~performance balance. lsm shares one index per disk, for disks with many small files.")
 	v.diskType = cmdVolume.Flag.String("disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
 	v.fixJpgOrientation = cmdVolume.Flag.Bool("images.fix.orientation", false, "Adjust jpg orientation when uploading.")
 	v.readMode = cmdVolume.Flag.String("readMode", "proxy", "[local|proxy|redirect] how to deal with non-local volume: 'not found|proxy to remote node|redirect volume location'.")
@@ -102,9 +109,15 @@ func init() {
 	v.metricsHttpPort = cmdVolume.Flag.Int("metricsPort", 0, "Prometheus metrics listen port")
 	v.metricsHttpIp = cmdVolume.Flag.String("metricsIp", "", "metrics listen ip. If empty, default to same as -ip.bind option.")
 	v.idxFolder = cmdVolume.Flag.String("dir.idx", "", "directory to store .idx files")
+	v.blockDevices = cmdVolume.Flag.String("dir.blockDevice", "", "comma separated block devices or preallocated files to store the .dat files of each -dir, e.g. /dev/sdb,,/data3/volumes.img:2TiB, empty to use the directory")
+	v.blockDevicesFormat = cmdVolume.Flag.Bool("dir.blockDevice.format", false, "format the block devices of -dir.blockDevice whose first 4KB is empty, erasing them")
 	v.inflightUploadDataTimeout = cmdVolume.Flag.Duration("inflightUploadDataTimeout", 60*time.Second, "inflight upload data wait timeout of volume servers")
 	v.hasSlowRead = cmdVolume.Flag.Bool("hasSlowRead", true, "<experimental> if true, this prevents slow reads from blocking other requests, but large file read P99 latency will increase.")
+	v.ioUring = cmdVolume.Flag.Bool("ioUring", false, "<experimental> batch the volume file reads, writes and syncs with io_uring on linux, falling back to the regular system calls if the kernel does not support it")
 	v.readBufferSizeMB = cmdVolume.Flag.Int("readBufferSizeMB", 4, "<experimental> larger values can optimize query performance but will increase some memory usage,Use with hasSlowRead normally.")
+	v.scrubMBPerSecond = cmdVolume.Flag.Int("scrubMBps", 0, "verify the needle CRCs and ec parity in the background, reading each disk at most this many mega bytes per second. 0 disables the scrubbing.")
+	v.scrubInterval = cmdVolume.Flag.Duration("scrubInterval", 7*24*time.Hour, "wait time between two scrubbing passes of a disk")
+	v.scrubRepair = cmdVolume.Flag.Bool("scrubRepair", true, "repair the corrupt needles found by the scrubbing from the other replicas or ec shards")
 }
 
 var cmdVolume = &Command{
@@ -202,6 +215,29 @@ func (v VolumeServerOptions) startVolumeServer(volumeFolders, maxVolumeCounts, v
 		glog.Fatalf("%d directories by -dir, but only %d disk types is set by -disk", len(v.folders), len(diskTypes))
 	}
 
+	if *v.ioUring {
+		if err := backend.EnableIoUring(backend.IoUringDefaultEntries); err != nil {
+			glog.Warningf("io_uring is not available, using the regular system calls: %v", err)
+		} else {
+			glog.V(0).Infof("volume file IO uses io_uring")
+		}
+	}
+
+	if *v.blockDevices != "" {
+		blockDevices := strings.Split(*v.blockDevices, ",")
+		if len(v.folders) != len(blockDevices) {
+			glog.Fatalf("%d directories by -dir, but only %d block devices is set by -dir.blockDevice", len(v.folders), len(blockDevices))
+		}
+		for i, blockDevice := range blockDevices {
+			if blockDevice == "" {
+				continue
+			}
+			if _, err := backend.AttachBlockDevice(v.folders[i], blockDevice, *v.blockDevicesFormat); err != nil {
+				glog.Fatalf("attach block device %s to %s: %v", blockDevice, v.folders[i], err)
+			}
+		}
+	}
+
 	// security related white list configuration
 	v.whiteList = util.StringSplit(volumeWhiteListOption, ",")
 
@@ -237,14 +273,9 @@ func (v VolumeServerOptions) startVolumeServer(volumeFolders, maxVolumeCounts, v
 		volumeMux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
 	}
 
-	volumeNeedleMapKind := storage.NeedleMapInMemory
-	switch *v.indexType {
-	case "leveldb":
-		volumeNeedleMapKind = storage.NeedleMapLevelDb
-	case "leveldbMedium":
-		volumeNeedleMapKind = storage.NeedleMapLevelDbMedium
-	case "leveldbLarge":
-		volumeNeedleMapKind = storage.NeedleMapLevelDbLarge
+	volumeNeedleMapKind, err := storage.ParseNeedleMapKind(*v.indexType)
+	if err != nil {
+		glog.Fatalf("-index: %v", err)
 	}
 
 	volumeServer := weed_server.NewVolumeServer(volumeMux, publicVolumeMux,
@@ -263,6 +294,9 @@ func (v VolumeServerOptions) startVolumeServer(volumeFolders, maxVolumeCounts, v
 		*v.hasSlowRead,
 		*v.readBufferSizeMB,
 		*v.ldbTimeout,
+		*v.scrubMBPerSecond,
+		*v.scrubInterval,
+		*v.scrubRepair,
 	)
 	// starting grpc server
 	grpcS := v.startGrpcService(volumeServer)
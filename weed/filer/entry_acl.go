@@ -0,0 +1,144 @@
+package filer
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	XattrPrefix          = "xattr-" // same as mount.XATTR_PREFIX
+	PosixAclAccessXattr  = "system.posix_acl_access"
+	PosixAclDefaultXattr = "system.posix_acl_default"
+	Nfs4AclXattr         = "system.nfs4_acl"
+
+	AccessRead    = 4
+	AccessWrite   = 2
+	AccessExecute = 1
+)
+
+// AclIdentity is the caller checked against entry permissions
+type AclIdentity struct {
+	Uid  uint32
+	Gids []uint32
+}
+
+func (identity *AclIdentity) InGroup(gid uint32) bool {
+	for _, g := range identity.Gids {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAcl is true if the entry carries a POSIX access acl or an NFSv4 acl.
+// Only such entries are checked by the filer HTTP and WebDAV paths.
+func HasAcl(extended map[string][]byte) bool {
+	if extended == nil {
+		return false
+	}
+	_, hasPosixAcl := extended[XattrPrefix+PosixAclAccessXattr]
+	_, hasNfs4Acl := extended[XattrPrefix+Nfs4AclXattr]
+	return hasPosixAcl || hasNfs4Acl
+}
+
+// CheckAccess evaluates the NFSv4 acl if present, then the POSIX access acl, then the mode bits.
+// want is a combination of AccessRead, AccessWrite and AccessExecute.
+func CheckAccess(mode os.FileMode, ownerUid, ownerGid uint32, extended map[string][]byte, identity *AclIdentity, want uint32) bool {
+	if identity.Uid == 0 {
+		// root may execute only if anyone may
+		return want&AccessExecute == 0 || mode.IsDir() || mode&0111 != 0
+	}
+	if data, found := extended[XattrPrefix+Nfs4AclXattr]; found {
+		acl, err := ParseNfs4Acl(data)
+		if err == nil {
+			return acl.Allows(ownerUid, ownerGid, identity, want)
+		}
+		glog.V(1).Infof("ignore invalid nfs4 acl: %v", err)
+	}
+	if data, found := extended[XattrPrefix+PosixAclAccessXattr]; found {
+		acl, err := ParsePosixAcl(data)
+		if err == nil {
+			return acl.Allows(ownerUid, ownerGid, identity, want)
+		}
+		glog.V(1).Infof("ignore invalid posix acl: %v", err)
+	}
+	perm := uint32(mode.Perm())
+	switch {
+	case identity.Uid == ownerUid:
+		perm >>= 6
+	case identity.InGroup(ownerGid):
+		perm >>= 3
+	}
+	return perm&want == want
+}
+
+func CheckPbEntryAccess(entry *filer_pb.Entry, identity *AclIdentity, want uint32) bool {
+	if entry.Attributes == nil {
+		return true
+	}
+	return CheckAccess(os.FileMode(entry.Attributes.FileMode), entry.Attributes.Uid, entry.Attributes.Gid, entry.Extended, identity, want)
+}
+
+func (entry *Entry) CheckAccess(identity *AclIdentity, want uint32) bool {
+	return CheckAccess(entry.Mode, entry.Uid, entry.Gid, entry.Extended, identity, want)
+}
+
+// InheritAcl applies the parent directory default POSIX acl and inheritable NFSv4 aces to a new entry.
+// It returns the adjusted mode; extended is updated in place and is allocated if nil.
+func InheritAcl(parentExtended map[string][]byte, isDirectory bool, mode os.FileMode, extended map[string][]byte) (os.FileMode, map[string][]byte) {
+	if parentExtended == nil {
+		return mode, extended
+	}
+	if extended == nil {
+		extended = make(map[string][]byte)
+	}
+
+	if data, found := parentExtended[XattrPrefix+PosixAclDefaultXattr]; found {
+		if defaultAcl, err := ParsePosixAcl(data); err == nil {
+			accessAcl, newMode := defaultAcl.inheritAccessAcl(uint32(mode.Perm()))
+			mode = (mode &^ os.ModePerm) | os.FileMode(newMode&0777)
+			extended[XattrPrefix+PosixAclAccessXattr] = accessAcl.Bytes()
+			if isDirectory {
+				extended[XattrPrefix+PosixAclDefaultXattr] = data
+			}
+		}
+	}
+
+	if data, found := parentExtended[XattrPrefix+Nfs4AclXattr]; found {
+		if parentAcl, err := ParseNfs4Acl(data); err == nil {
+			if inherited := parentAcl.inherit(isDirectory); len(inherited) > 0 {
+				extended[XattrPrefix+Nfs4AclXattr] = inherited.Bytes()
+			}
+		}
+	}
+
+	return mode, extended
+}
+
+func hasInheritableAcl(extended map[string][]byte) bool {
+	if extended == nil {
+		return false
+	}
+	_, hasDefaultAcl := extended[XattrPrefix+PosixAclDefaultXattr]
+	_, hasNfs4Acl := extended[XattrPrefix+Nfs4AclXattr]
+	return hasDefaultAcl || hasNfs4Acl
+}
+
+// maybeInheritAcl lets a new entry without its own acl inherit the acl of its parent directory
+func (f *Filer) maybeInheritAcl(ctx context.Context, entry *Entry) {
+	if HasAcl(entry.Extended) || strings.HasPrefix(string(entry.FullPath), SystemLogDir) {
+		return
+	}
+	dir, _ := entry.FullPath.DirAndName()
+	parent, err := f.FindEntry(ctx, util.FullPath(dir))
+	if err != nil || !hasInheritableAcl(parent.Extended) {
+		return
+	}
+	entry.Mode, entry.Extended = InheritAcl(parent.Extended, entry.IsDirectory(), entry.Mode, entry.Extended)
+}
@@ -0,0 +1,100 @@
+package filer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPosixAclAllows(t *testing.T) {
+
+	acl := PosixAcl{
+		{Tag: PosixAclUserObj, Perm: 7},
+		{Tag: PosixAclUser, Perm: 7, Id: 1001},
+		{Tag: PosixAclGroupObj, Perm: 5},
+		{Tag: PosixAclGroup, Perm: 6, Id: 200},
+		{Tag: PosixAclMask, Perm: 5},
+		{Tag: PosixAclOther, Perm: 0},
+	}
+
+	parsed, err := ParsePosixAcl(acl.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, acl, parsed)
+
+	owner := &AclIdentity{Uid: 1000, Gids: []uint32{100}}
+	namedUser := &AclIdentity{Uid: 1001, Gids: []uint32{300}}
+	namedGroup := &AclIdentity{Uid: 1002, Gids: []uint32{200}}
+	other := &AclIdentity{Uid: 1003, Gids: []uint32{300}}
+
+	assert.True(t, acl.Allows(1000, 100, owner, AccessRead|AccessWrite))
+	assert.True(t, acl.Allows(1000, 100, namedUser, AccessRead|AccessExecute))
+	assert.False(t, acl.Allows(1000, 100, namedUser, AccessWrite), "masked")
+	assert.True(t, acl.Allows(1000, 100, namedGroup, AccessRead))
+	assert.False(t, acl.Allows(1000, 100, namedGroup, AccessWrite), "masked")
+	assert.False(t, acl.Allows(1000, 100, other, AccessRead))
+
+	assert.Equal(t, uint32(0750), acl.Mode())
+	assert.Equal(t, uint16(6), acl.Chmod(0760).find(PosixAclMask).Perm)
+	assert.Equal(t, uint16(5), acl.Chmod(0760).find(PosixAclGroupObj).Perm)
+
+	_, err = ParsePosixAcl(PosixAcl{{Tag: PosixAclUserObj, Perm: 7}, {Tag: PosixAclUser, Perm: 7, Id: 1}, {Tag: PosixAclGroupObj, Perm: 5}, {Tag: PosixAclOther}}.Bytes())
+	assert.NotNil(t, err, "missing mask")
+}
+
+func TestNfs4AclAllows(t *testing.T) {
+
+	acl := Nfs4Acl{
+		{Type: Nfs4AceAccessDenied, AccessMask: Nfs4AceWriteData, Who: "1001"},
+		{Type: Nfs4AceAccessAllowed, AccessMask: Nfs4AceReadData | Nfs4AceWriteData, Who: Nfs4AceEveryone},
+		{Type: Nfs4AceAccessAllowed, Flag: Nfs4AceFileInherit | Nfs4AceInheritOnly, AccessMask: Nfs4AceExecute, Who: "200"},
+	}
+
+	parsed, err := ParseNfs4Acl(acl.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, acl, parsed)
+
+	assert.True(t, acl.Allows(1000, 100, &AclIdentity{Uid: 1002}, AccessRead|AccessWrite))
+	assert.False(t, acl.Allows(1000, 100, &AclIdentity{Uid: 1001}, AccessRead|AccessWrite))
+	assert.True(t, acl.Allows(1000, 100, &AclIdentity{Uid: 1001}, AccessRead))
+	assert.False(t, acl.Allows(1000, 100, &AclIdentity{Uid: 1002, Gids: []uint32{200}}, AccessExecute), "inherit only")
+
+	fileAcl := acl.inherit(false)
+	assert.Equal(t, 1, len(fileAcl))
+	assert.Equal(t, uint32(Nfs4AceInherited), fileAcl[0].Flag)
+	dirAcl := acl.inherit(true)
+	assert.Equal(t, 1, len(dirAcl))
+	assert.Equal(t, uint32(Nfs4AceFileInherit|Nfs4AceInheritOnly|Nfs4AceInherited), dirAcl[0].Flag, "passed on to files only")
+}
+
+func TestInheritAcl(t *testing.T) {
+
+	defaultAcl := PosixAcl{
+		{Tag: PosixAclUserObj, Perm: 7},
+		{Tag: PosixAclUser, Perm: 7, Id: 1001},
+		{Tag: PosixAclGroupObj, Perm: 5},
+		{Tag: PosixAclMask, Perm: 7},
+		{Tag: PosixAclOther, Perm: 5},
+	}
+	parentExtended := map[string][]byte{
+		XattrPrefix + PosixAclDefaultXattr: defaultAcl.Bytes(),
+	}
+
+	mode, extended := InheritAcl(parentExtended, false, 0640, nil)
+	assert.Equal(t, os.FileMode(0640), mode)
+	accessAcl, err := ParsePosixAcl(extended[XattrPrefix+PosixAclAccessXattr])
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(4), accessAcl.find(PosixAclMask).Perm)
+	_, found := extended[XattrPrefix+PosixAclDefaultXattr]
+	assert.False(t, found, "files have no default acl")
+
+	mode, extended = InheritAcl(parentExtended, true, os.ModeDir|0777, nil)
+	assert.Equal(t, os.ModeDir|0775, mode)
+	_, found = extended[XattrPrefix+PosixAclDefaultXattr]
+	assert.True(t, found, "directories keep the default acl")
+
+	assert.True(t, CheckAccess(0600, 1000, 100, extended, &AclIdentity{Uid: 1001}, AccessWrite))
+	assert.False(t, CheckAccess(0600, 1000, 100, nil, &AclIdentity{Uid: 1001}, AccessRead))
+	assert.True(t, CheckAccess(0600, 1000, 100, nil, &AclIdentity{Uid: 0}, AccessRead|AccessWrite))
+	assert.False(t, CheckAccess(0600, 1000, 100, nil, &AclIdentity{Uid: 0}, AccessExecute))
+}
@@ -10,23 +10,30 @@ import (
 )
 
 type ChunkGroup struct {
-	lookupFn     wdclient.LookupFileIdFunctionType
-	sections     map[SectionIndex]*FileChunkSection
-	sectionsLock sync.RWMutex
-	readerCache  *ReaderCache
+	lookupFn      wdclient.LookupFileIdFunctionType
+	sections      map[SectionIndex]*FileChunkSection
+	sectionsLock  sync.RWMutex
+	readerCache   *ReaderCache
+	readerPattern *ReaderPattern
 }
 
 func NewChunkGroup(lookupFn wdclient.LookupFileIdFunctionType, chunkCache chunk_cache.ChunkCache, chunks []*filer_pb.FileChunk) (*ChunkGroup, error) {
 	group := &ChunkGroup{
-		lookupFn:    lookupFn,
-		sections:    make(map[SectionIndex]*FileChunkSection),
-		readerCache: NewReaderCache(32, chunkCache, lookupFn),
+		lookupFn:      lookupFn,
+		sections:      make(map[SectionIndex]*FileChunkSection),
+		readerCache:   NewReaderCache(32, chunkCache, lookupFn),
+		readerPattern: NewReaderPattern(),
 	}
 
 	err := group.SetChunks(chunks)
 	return group, err
 }
 
+// Destroy frees the chunks read ahead, when the group is not read any more
+func (group *ChunkGroup) Destroy() {
+	group.readerCache.destroy()
+}
+
 func (group *ChunkGroup) AddChunk(chunk *filer_pb.FileChunk) error {
 
 	group.sectionsLock.Lock()
@@ -74,6 +81,31 @@ func (group *ChunkGroup) ReadDataAt(fileSize int64, buff []byte, offset int64) (
 	return
 }
 
+// LocateDataAt finds the range [offset, offset+size) in the chunk cache, if the range is in one cached chunk
+func (group *ChunkGroup) LocateDataAt(fileSize int64, offset int64, size int) (ref chunk_cache.CachedChunkRef, tsNs int64, found bool) {
+	if offset+int64(size) > fileSize {
+		return
+	}
+
+	group.sectionsLock.RLock()
+	defer group.sectionsLock.RUnlock()
+
+	si := SectionIndex(offset / SectionSize)
+	if offset+int64(size) > int64(si+1)*SectionSize {
+		return
+	}
+	section, found := group.sections[si]
+	if !found {
+		return
+	}
+	return section.locateDataAt(group, fileSize, offset, size)
+}
+
+// ReaderStats reports the read pattern and the read ahead of the file
+func (group *ChunkGroup) ReaderStats() ReaderStats {
+	return group.readerPattern.Stats()
+}
+
 func (group *ChunkGroup) SetChunks(chunks []*filer_pb.FileChunk) error {
 	group.sectionsLock.RLock()
 	defer group.sectionsLock.RUnlock()
@@ -115,7 +147,7 @@ func (group *ChunkGroup) SetChunks(chunks []*filer_pb.FileChunk) error {
 const (
 	// see weedfs_file_lseek.go
 	SEEK_DATA uint32 = 3 // seek to next data after the offset
-	// SEEK_HOLE uint32 = 4 // seek to next hole after the offset
+	SEEK_HOLE uint32 = 4 // seek to next hole after the offset
 )
 
 // FIXME: needa tests
@@ -144,16 +176,18 @@ func (group *ChunkGroup) doSearchChunks(offset, fileSize int64, whence uint32) (
 		return false, 0
 	} else {
 		// whence == SEEK_HOLE
-		for si := sectionIndex; si < maxSectionIndex; si++ {
+		for si := sectionIndex; si <= maxSectionIndex; si++ {
 			section, foundSection := group.sections[si]
 			if !foundSection {
 				return true, offset
 			}
 			holeStart := section.NextStopOffset(group, offset, fileSize)
-			if holeStart%SectionSize == 0 {
+			if holeStart > offset && holeStart%SectionSize == 0 {
+				// the data continues to the next section
+				offset = holeStart
 				continue
 			}
-			return true, holeStart
+			return true, min(holeStart, fileSize)
 		}
 		return true, fileSize
 	}
@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util/chunk_cache"
 )
 
 const SectionSize = 2 * 1024 * 1024 * 32 // 64MiB
@@ -36,8 +37,8 @@ func (section *FileChunkSection) addChunk(chunk *filer_pb.FileChunk) error {
 		section.visibleIntervals = readResolvedChunks(section.chunks, int64(section.sectionIndex)*SectionSize, (int64(section.sectionIndex)+1)*SectionSize)
 	} else {
 		MergeIntoVisibles(section.visibleIntervals, start, stop, chunk)
-		garbageFileIds := FindGarbageChunks(section.visibleIntervals, start, stop)
-		removeGarbageChunks(section, garbageFileIds)
+		garbageChunks := FindGarbageChunks(section.visibleIntervals, section.chunks, start, stop)
+		removeGarbageChunks(section, garbageChunks)
 	}
 
 	if section.chunkViews != nil {
@@ -47,11 +48,11 @@ func (section *FileChunkSection) addChunk(chunk *filer_pb.FileChunk) error {
 	return nil
 }
 
-func removeGarbageChunks(section *FileChunkSection, garbageFileIds map[string]struct{}) {
+func removeGarbageChunks(section *FileChunkSection, garbageChunks map[string]struct{}) {
 	for i := 0; i < len(section.chunks); {
 		t := section.chunks[i]
 		length := len(section.chunks)
-		if _, found := garbageFileIds[t.FileId]; found {
+		if _, found := garbageChunks[chunkIdentity(t.GetFileIdString(), t.Offset)]; found {
 			if i < length-1 {
 				section.chunks[i] = section.chunks[length-1]
 			}
@@ -84,7 +85,7 @@ func (section *FileChunkSection) setupForRead(group *ChunkGroup, fileSize int64)
 	}
 
 	if section.reader == nil {
-		section.reader = NewChunkReaderAtFromClient(group.readerCache, section.chunkViews, min(int64(section.sectionIndex+1)*SectionSize, fileSize))
+		section.reader = newChunkReaderAt(group.readerCache, section.chunkViews, min(int64(section.sectionIndex+1)*SectionSize, fileSize), group.readerPattern)
 	}
 
 	section.isPrepared = true
@@ -100,6 +101,15 @@ func (section *FileChunkSection) readDataAt(group *ChunkGroup, fileSize int64, b
 	return section.reader.ReadAtWithTime(buff, offset)
 }
 
+func (section *FileChunkSection) locateDataAt(group *ChunkGroup, fileSize int64, offset int64, size int) (ref chunk_cache.CachedChunkRef, tsNs int64, found bool) {
+
+	section.setupForRead(group, fileSize)
+	section.lock.RLock()
+	defer section.lock.RUnlock()
+
+	return section.reader.LocateAt(offset, size)
+}
+
 func (section *FileChunkSection) DataStartOffset(group *ChunkGroup, offset int64, fileSize int64) int64 {
 
 	section.setupForRead(group, fileSize)
@@ -112,7 +122,7 @@ func (section *FileChunkSection) DataStartOffset(group *ChunkGroup, offset int64
 			continue
 		}
 		if offset < visible.start {
-			return offset
+			return visible.start
 		}
 		return offset
 	}
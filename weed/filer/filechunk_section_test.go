@@ -2,6 +2,7 @@ package filer
 
 import (
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"golang.org/x/exp/slices"
 	"testing"
 )
 
@@ -37,12 +38,33 @@ func Test_removeGarbageChunks(t *testing.T) {
 		Size:         1,
 		ModifiedTsNs: 4,
 	})
-	garbageFileIds := make(map[string]struct{})
-	garbageFileIds["0"] = struct{}{}
-	garbageFileIds["2"] = struct{}{}
-	garbageFileIds["4"] = struct{}{}
-	removeGarbageChunks(section, garbageFileIds)
+	garbageChunks := make(map[string]struct{})
+	garbageChunks[chunkIdentity("0", 0)] = struct{}{}
+	garbageChunks[chunkIdentity("2", 2)] = struct{}{}
+	garbageChunks[chunkIdentity("4", 4)] = struct{}{}
+	removeGarbageChunks(section, garbageChunks)
 	if len(section.chunks) != 2 {
 		t.Errorf("remove chunk 2 failed")
 	}
 }
+
+func Test_removeGarbageChunksOfPunchedHole(t *testing.T) {
+	section := NewFileChunkSection(0)
+	pieces, _ := PunchHole([]*filer_pb.FileChunk{
+		{FileId: "1,a", Offset: 0, Size: 100, ModifiedTsNs: 1},
+	}, 40, 60)
+	for _, piece := range pieces {
+		section.addChunk(piece)
+	}
+
+	// overwrite the tail piece, which shares the file id with the head piece
+	section.addChunk(&filer_pb.FileChunk{FileId: "2,b", Offset: 60, Size: 40, ModifiedTsNs: 2})
+
+	var kept []string
+	for _, chunk := range section.chunks {
+		kept = append(kept, chunkIdentity(chunk.FileId, chunk.Offset))
+	}
+	if len(kept) != 2 || !slices.Contains(kept, chunkIdentity("1,a", 0)) || !slices.Contains(kept, chunkIdentity("2,b", 60)) {
+		t.Errorf("unexpected chunks %v", kept)
+	}
+}
@@ -86,18 +86,31 @@ func SeparateGarbageChunks(visibles *IntervalList[*VisibleInterval], chunks []*f
 	return compacted, garbage
 }
 
-func FindGarbageChunks(visibles *IntervalList[*VisibleInterval], start int64, stop int64) (garbageFileIds map[string]struct{}) {
-	garbageFileIds = make(map[string]struct{})
+// FindGarbageChunks returns the chunks overlapping [start, stop) which have no visible intervals left.
+// The pieces of a chunk split by PunchHole share the file id, so the chunks are identified by the file id and the offset.
+func FindGarbageChunks(visibles *IntervalList[*VisibleInterval], chunks []*filer_pb.FileChunk, start int64, stop int64) (garbageChunks map[string]struct{}) {
+	visibleChunks := make(map[string]struct{})
 	for x := visibles.Front(); x != nil; x = x.Next {
 		interval := x.Value
-		offset := interval.start - interval.offsetInChunk
-		if start <= offset && offset+int64(interval.chunkSize) <= stop {
-			garbageFileIds[interval.fileId] = struct{}{}
+		visibleChunks[chunkIdentity(interval.fileId, interval.start-interval.offsetInChunk)] = struct{}{}
+	}
+	garbageChunks = make(map[string]struct{})
+	for _, chunk := range chunks {
+		if chunk.Offset+int64(chunk.Size) <= start || stop <= chunk.Offset {
+			continue
+		}
+		key := chunkIdentity(chunk.GetFileIdString(), chunk.Offset)
+		if _, found := visibleChunks[key]; !found {
+			garbageChunks[key] = struct{}{}
 		}
 	}
 	return
 }
 
+func chunkIdentity(fileId string, offset int64) string {
+	return fmt.Sprintf("%s@%d", fileId, offset)
+}
+
 func MinusChunks(lookupFileIdFn wdclient.LookupFileIdFunctionType, as, bs []*filer_pb.FileChunk) (delta []*filer_pb.FileChunk, err error) {
 
 	aData, aMeta, aErr := ResolveChunkManifest(lookupFileIdFn, as, 0, math.MaxInt64)
@@ -207,10 +220,10 @@ func ViewFromVisibleIntervals(visibles *IntervalList[*VisibleInterval], offset i
 		if chunkStart < chunkStop {
 			chunkView := &ChunkView{
 				FileId:        chunk.fileId,
-				OffsetInChunk: chunkStart - chunk.start + chunk.offsetInChunk,
+				OffsetInChunk: chunkStart - chunk.start + chunk.offsetInChunk + chunk.packedOffset,
 				ViewSize:      uint64(chunkStop - chunkStart),
 				ViewOffset:    chunkStart,
-				ChunkSize:     chunk.chunkSize,
+				ChunkSize:     chunk.needleSize,
 				CipherKey:     chunk.cipherKey,
 				IsGzipped:     chunk.isGzipped,
 				ModifiedTsNs:  chunk.modifiedTsNs,
@@ -241,6 +254,8 @@ func MergeIntoVisibles(visibles *IntervalList[*VisibleInterval], start int64, st
 		chunkSize:     chunk.Size,           // size of the chunk
 		cipherKey:     chunk.CipherKey,
 		isGzipped:     chunk.IsCompressed,
+		packedOffset:  chunk.DataOffset(),
+		needleSize:    chunk.NeedleSize(),
 	}
 
 	visibles.InsertInterval(start, stop, chunk.ModifiedTsNs, newV)
@@ -250,10 +265,10 @@ func MergeIntoChunkViews(chunkViews *IntervalList[*ChunkView], start int64, stop
 
 	chunkView := &ChunkView{
 		FileId:        chunk.GetFileIdString(),
-		OffsetInChunk: start - chunk.Offset,
+		OffsetInChunk: start - chunk.Offset + chunk.DataOffset(),
 		ViewSize:      uint64(stop - start),
 		ViewOffset:    start,
-		ChunkSize:     chunk.Size,
+		ChunkSize:     chunk.NeedleSize(),
 		CipherKey:     chunk.CipherKey,
 		IsGzipped:     chunk.IsCompressed,
 		ModifiedTsNs:  chunk.ModifiedTsNs,
@@ -288,6 +303,8 @@ type VisibleInterval struct {
 	chunkSize     uint64
 	cipherKey     []byte
 	isGzipped     bool
+	packedOffset  int64  // the data offset in the stored chunk, for packed small files or trimmed chunks
+	needleSize    uint64 // size of the stored chunk, or the container chunk
 }
 
 func (v *VisibleInterval) SetStartStop(start, stop int64) {
@@ -304,6 +321,8 @@ func (v *VisibleInterval) Clone() IntervalValue {
 		chunkSize:     v.chunkSize,
 		cipherKey:     v.cipherKey,
 		isGzipped:     v.isGzipped,
+		packedOffset:  v.packedOffset,
+		needleSize:    v.needleSize,
 	}
 }
 
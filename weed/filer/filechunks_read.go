@@ -106,6 +106,8 @@ func addToVisibles(visibles *IntervalList[*VisibleInterval], prevX int64, startP
 			chunkSize:     chunk.Size,
 			cipherKey:     chunk.CipherKey,
 			isGzipped:     chunk.IsCompressed,
+			packedOffset:  chunk.DataOffset(),
+			needleSize:    chunk.NeedleSize(),
 		}
 		appendVisibleInterfal(visibles, visible)
 	}
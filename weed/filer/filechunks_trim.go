@@ -0,0 +1,42 @@
+package filer
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+// PunchHole removes the data in [start, stop) from the data chunks. The chunks overlapping the range,
+// including the ones covered by newer chunks, are trimmed or split, so the range reads as zeros.
+// The stored chunks are not changed, and the pieces refer to their data ranges in the stored chunks.
+func PunchHole(dataChunks []*filer_pb.FileChunk, start, stop int64) (chunks []*filer_pb.FileChunk, changed bool) {
+	for _, chunk := range dataChunks {
+		chunkStart, chunkStop := chunk.Offset, chunk.Offset+int64(chunk.Size)
+		if chunkStop <= start || stop <= chunkStart {
+			chunks = append(chunks, chunk)
+			continue
+		}
+		changed = true
+		if chunkStart < start {
+			head := trimmedChunk(chunk)
+			head.Size = uint64(start - chunkStart)
+			chunks = append(chunks, head)
+		}
+		if stop < chunkStop {
+			tail := trimmedChunk(chunk)
+			tail.TrimmedOffset += stop - chunkStart
+			tail.Offset = stop
+			tail.Size = uint64(chunkStop - stop)
+			chunks = append(chunks, tail)
+		}
+	}
+	return
+}
+
+func trimmedChunk(chunk *filer_pb.FileChunk) *filer_pb.FileChunk {
+	trimmed := proto.Clone(chunk).(*filer_pb.FileChunk)
+	if !trimmed.IsPacked() && trimmed.StoredSize == 0 {
+		trimmed.StoredSize = chunk.Size
+	}
+	return trimmed
+}
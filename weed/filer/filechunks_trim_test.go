@@ -0,0 +1,84 @@
+package filer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestPunchHole(t *testing.T) {
+	chunks := []*filer_pb.FileChunk{
+		{FileId: "1,a", Offset: 0, Size: 100, ModifiedTsNs: 1},
+		{FileId: "2,b", Offset: 100, Size: 100, ModifiedTsNs: 2},
+		{FileId: "3,c", Offset: 150, Size: 100, ModifiedTsNs: 3},
+	}
+
+	punched, changed := PunchHole(chunks, 50, 160)
+	assert.True(t, changed)
+	if assert.Equal(t, 3, len(punched)) {
+		assert.True(t, proto.Equal(&filer_pb.FileChunk{FileId: "1,a", Offset: 0, Size: 50, ModifiedTsNs: 1, StoredSize: 100}, punched[0]), "%v", punched[0])
+		assert.True(t, proto.Equal(&filer_pb.FileChunk{FileId: "2,b", Offset: 160, Size: 40, ModifiedTsNs: 2, TrimmedOffset: 60, StoredSize: 100}, punched[1]), "%v", punched[1])
+		assert.True(t, proto.Equal(&filer_pb.FileChunk{FileId: "3,c", Offset: 160, Size: 90, ModifiedTsNs: 3, TrimmedOffset: 10, StoredSize: 100}, punched[2]), "%v", punched[2])
+	}
+	assert.Equal(t, uint64(100), chunks[0].Size, "the original chunks are not modified")
+
+	views := ViewFromChunks(nil, punched, 0, 250)
+	var viewList []*ChunkView
+	for x := views.Front(); x != nil; x = x.Next {
+		viewList = append(viewList, x.Value)
+	}
+	if assert.Equal(t, 2, len(viewList)) {
+		assert.Equal(t, int64(0), viewList[0].OffsetInChunk)
+		assert.Equal(t, uint64(50), viewList[0].ViewSize)
+		assert.False(t, viewList[0].IsFullChunk())
+		assert.Equal(t, "3,c", viewList[1].FileId)
+		assert.Equal(t, int64(160), viewList[1].ViewOffset)
+		assert.Equal(t, int64(10), viewList[1].OffsetInChunk)
+	}
+
+	// split again within a trimmed chunk
+	punched, _ = PunchHole(punched, 200, 210)
+	views = ViewFromChunks(nil, punched, 210, 40)
+	if x := views.Front(); assert.NotNil(t, x) {
+		assert.Equal(t, "3,c", x.Value.FileId)
+		assert.Equal(t, int64(60), x.Value.OffsetInChunk)
+	}
+
+	_, changed = PunchHole(punched, 1000, 2000)
+	assert.False(t, changed)
+}
+
+func TestSearchChunksWithHoles(t *testing.T) {
+	chunks := []*filer_pb.FileChunk{
+		{FileId: "1,a", Offset: 0, Size: 100, ModifiedTsNs: 1},
+		{FileId: "2,b", Offset: SectionSize - 50, Size: 100, ModifiedTsNs: 1},
+	}
+	chunks, _ = PunchHole(chunks, 20, 40)
+	fileSize := int64(SectionSize + 200)
+
+	group, err := NewChunkGroup(nil, nil, chunks)
+	assert.Nil(t, err)
+
+	found, offset := group.SearchChunks(0, fileSize, SEEK_HOLE)
+	assert.True(t, found)
+	assert.Equal(t, int64(20), offset)
+
+	found, offset = group.SearchChunks(20, fileSize, SEEK_DATA)
+	assert.True(t, found)
+	assert.Equal(t, int64(40), offset)
+
+	found, offset = group.SearchChunks(100, fileSize, SEEK_DATA)
+	assert.True(t, found)
+	assert.Equal(t, int64(SectionSize-50), offset)
+
+	found, offset = group.SearchChunks(SectionSize-50, fileSize, SEEK_HOLE)
+	assert.True(t, found)
+	assert.Equal(t, int64(SectionSize+50), offset, "the data continues into the next section")
+
+	found, _ = group.SearchChunks(SectionSize+50, fileSize, SEEK_DATA)
+	assert.False(t, found)
+
+}
@@ -49,8 +49,12 @@ type Filer struct {
 	MetaAggregator      *MetaAggregator
 	Signature           int32
 	FilerConf           *FilerConf
+	Quota               *FilerQuota
+	Packer              *FilePacker
+	Deduper             *ChunkDeduper
 	RemoteStorage       *FilerRemoteStorage
 	Dlm                 *lock_manager.DistributedLockManager
+	entryLocks          *util.LockTable[util.FullPath]
 	MaxFilenameLength   uint32
 }
 
@@ -60,14 +64,18 @@ func NewFiler(masters pb.ServerDiscovery, grpcDialOption grpc.DialOption, filerH
 		fileIdDeletionQueue: util.NewUnboundedQueue(),
 		GrpcDialOption:      grpcDialOption,
 		FilerConf:           NewFilerConf(),
+		Quota:               NewFilerQuota(),
 		RemoteStorage:       NewFilerRemoteStorage(),
 		UniqueFilerId:       util.RandomInt32(),
 		Dlm:                 lock_manager.NewDistributedLockManager(filerHost),
+		entryLocks:          util.NewLockTable[util.FullPath](),
 		MaxFilenameLength:   maxFilenameLength,
 	}
 	if f.UniqueFilerId < 0 {
 		f.UniqueFilerId = -f.UniqueFilerId
 	}
+	f.Packer = NewFilePacker(f)
+	f.Deduper = NewChunkDeduper(f)
 
 	f.LocalMetaLogBuffer = log_buffer.NewLogBuffer("local", LogFlushInterval, f.logFlushFunc, nil, notifyFn)
 	f.metaLogCollection = collection
@@ -205,6 +213,12 @@ func (f *Filer) CreateEntry(ctx context.Context, entry *Entry, o_excl bool, isFr
 		}
 	*/
 
+	if ctx.Value("OP") != "MV" {
+		if err := f.CheckQuota(ctx, oldEntry, entry); err != nil {
+			return err
+		}
+	}
+
 	if oldEntry == nil {
 
 		if !skipCreateParentDir {
@@ -214,6 +228,10 @@ func (f *Filer) CreateEntry(ctx context.Context, entry *Entry, o_excl bool, isFr
 			}
 		}
 
+		if !isFromOtherCluster && ctx.Value("OP") != "MV" {
+			f.maybeInheritAcl(ctx, entry)
+		}
+
 		glog.V(4).Infof("InsertEntry %s: new entry: %v", entry.FullPath, entry.Name())
 		if err := f.Store.InsertEntry(ctx, entry); err != nil {
 			glog.Errorf("insert entry %s: %v", entry.FullPath, err)
@@ -277,6 +295,10 @@ func (f *Filer) ensureParentDirectoryEntry(ctx context.Context, entry *Entry, di
 			},
 		}
 
+		if !isFromOtherCluster {
+			f.maybeInheritAcl(ctx, dirEntry)
+		}
+
 		glog.V(2).Infof("create directory: %s %v", dirPath, dirEntry.Mode)
 		mkdirErr := f.Store.InsertEntry(ctx, dirEntry)
 		if mkdirErr != nil {
@@ -309,6 +331,7 @@ func (f *Filer) UpdateEntry(ctx context.Context, oldEntry, entry *Entry) (err er
 			glog.Errorf("existing %s is a file", oldEntry.FullPath)
 			return fmt.Errorf("existing %s is a file", oldEntry.FullPath)
 		}
+		keepChunkFingerprints(oldEntry.GetChunks(), entry.GetChunks())
 	}
 	return f.Store.UpdateEntry(ctx, entry)
 }
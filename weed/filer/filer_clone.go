@@ -0,0 +1,89 @@
+package filer
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+// Cloned entries reference the chunks of the source entry instead of copying the data.
+// The shared chunks are counted in the same index as the deduplicated chunks,
+// keyed by the file id instead of the content hash, so the data is deleted after the last reference is gone.
+const cloneFingerprintPrefix = "clone:"
+
+func CloneFingerprint(fileId string) []byte {
+	return []byte(cloneFingerprintPrefix + fileId)
+}
+
+// ShareChunkRange marks the data chunks fully visible within [start, stop) as shared.
+// It returns the updated data chunks of the entry, and the shared chunks which can be referenced by other entries.
+func ShareChunkRange(dataChunks []*filer_pb.FileChunk, start, stop int64) (updated, shared []*filer_pb.FileChunk, changed bool) {
+	type chunkStart struct {
+		fileId string
+		offset int64
+	}
+	fullyVisible := make(map[chunkStart]bool)
+	visibles := readResolvedChunks(dataChunks, 0, math.MaxInt64)
+	for x := visibles.Front(); x != nil; x = x.Next {
+		v := x.Value
+		if v.offsetInChunk == 0 && v.stop-v.start == int64(v.chunkSize) {
+			fullyVisible[chunkStart{v.fileId, v.start}] = true
+		}
+	}
+	return shareChunks(dataChunks, func(chunk *filer_pb.FileChunk) bool {
+		// packed chunks are counted by the referencing paths, which is ambiguous when cloning within the same file
+		return !chunk.IsPacked() && start <= chunk.Offset && chunk.Offset+int64(chunk.Size) <= stop &&
+			fullyVisible[chunkStart{chunk.GetFileIdString(), chunk.Offset}]
+	})
+}
+
+// ShareAllChunks marks all the visible data chunks as shared, for cloning the whole entry
+func ShareAllChunks(dataChunks []*filer_pb.FileChunk) (updated, shared []*filer_pb.FileChunk, changed bool) {
+	visibleFileIds := make(map[string]bool)
+	visibles := readResolvedChunks(dataChunks, 0, math.MaxInt64)
+	for x := visibles.Front(); x != nil; x = x.Next {
+		visibleFileIds[x.Value.fileId] = true
+	}
+	return shareChunks(dataChunks, func(chunk *filer_pb.FileChunk) bool {
+		return visibleFileIds[chunk.GetFileIdString()]
+	})
+}
+
+func shareChunks(dataChunks []*filer_pb.FileChunk, selectFn func(chunk *filer_pb.FileChunk) bool) (updated, shared []*filer_pb.FileChunk, changed bool) {
+	for _, chunk := range dataChunks {
+		if selectFn(chunk) {
+			// packed and deduplicated chunks are already reference counted
+			if !chunk.IsPacked() && !chunk.IsDeduplicated() {
+				chunk = proto.Clone(chunk).(*filer_pb.FileChunk)
+				chunk.Fingerprint = CloneFingerprint(chunk.GetFileIdString())
+				changed = true
+			}
+			shared = append(shared, chunk)
+		}
+		updated = append(updated, chunk)
+	}
+	return
+}
+
+// keepChunkFingerprints keeps the chunks shared if a client updates the entry with the chunks read before they were shared
+func keepChunkFingerprints(oldChunks, newChunks []*filer_pb.FileChunk) {
+	var fingerprints map[string][]byte
+	for _, chunk := range oldChunks {
+		if chunk.IsDeduplicated() {
+			if fingerprints == nil {
+				fingerprints = make(map[string][]byte)
+			}
+			fingerprints[chunk.GetFileIdString()] = chunk.Fingerprint
+		}
+	}
+	if fingerprints == nil {
+		return
+	}
+	for _, chunk := range newChunks {
+		if fingerprint, found := fingerprints[chunk.GetFileIdString()]; found && !chunk.IsDeduplicated() && !chunk.IsChunkManifest {
+			chunk.Fingerprint = fingerprint
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package filer
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareChunkRange(t *testing.T) {
+
+	chunks := []*filer_pb.FileChunk{
+		{FileId: "1,a", Offset: 0, Size: 100, ModifiedTsNs: 1},
+		{FileId: "2,b", Offset: 100, Size: 100, ModifiedTsNs: 1},
+		{FileId: "3,c", Offset: 200, Size: 100, ModifiedTsNs: 1},
+		{FileId: "4,d", Offset: 250, Size: 10, ModifiedTsNs: 2}, // overwrites a part of 3,c
+		{FileId: "5,e", Offset: 300, Size: 100, ModifiedTsNs: 1, PackedContainerSize: 1000},
+	}
+
+	updated, shared, changed := ShareChunkRange(chunks, 50, 400)
+	assert.True(t, changed)
+	assert.Equal(t, len(chunks), len(updated))
+	if assert.Equal(t, 2, len(shared)) {
+		assert.Equal(t, "2,b", shared[0].GetFileIdString())
+		assert.Equal(t, "4,d", shared[1].GetFileIdString())
+	}
+	assert.False(t, updated[0].IsDeduplicated(), "partially in the range")
+	assert.True(t, updated[1].IsDeduplicated())
+	assert.False(t, updated[2].IsDeduplicated(), "partially visible")
+	assert.False(t, updated[4].IsDeduplicated(), "packed")
+	assert.False(t, chunks[1].IsDeduplicated(), "the original chunks are not modified")
+
+	_, _, changed = ShareChunkRange(updated, 50, 400)
+	assert.False(t, changed, "already shared")
+}
+
+func TestShareAllChunks(t *testing.T) {
+
+	chunks := []*filer_pb.FileChunk{
+		{FileId: "1,a", Offset: 0, Size: 100, ModifiedTsNs: 1},
+		{FileId: "2,b", Offset: 0, Size: 100, ModifiedTsNs: 2}, // overwrites 1,a
+		{FileId: "3,c", Offset: 50, Size: 100, ModifiedTsNs: 3},
+	}
+
+	updated, shared, changed := ShareAllChunks(chunks)
+	assert.True(t, changed)
+	assert.Equal(t, 3, len(updated))
+	if assert.Equal(t, 2, len(shared)) {
+		assert.Equal(t, "2,b", shared[0].GetFileIdString())
+		assert.Equal(t, "3,c", shared[1].GetFileIdString())
+	}
+	assert.False(t, updated[0].IsDeduplicated())
+}
+
+func TestKeepChunkFingerprints(t *testing.T) {
+
+	oldChunks := []*filer_pb.FileChunk{
+		{FileId: "1,a", Offset: 0, Size: 100, Fingerprint: CloneFingerprint("1,a")},
+		{FileId: "2,b", Offset: 100, Size: 100},
+	}
+	newChunks := []*filer_pb.FileChunk{
+		{FileId: "1,a", Offset: 0, Size: 100},
+		{FileId: "2,b", Offset: 100, Size: 100},
+		{FileId: "3,c", Offset: 200, Size: 100},
+	}
+
+	keepChunkFingerprints(oldChunks, newChunks)
+	assert.Equal(t, CloneFingerprint("1,a"), newChunks[0].Fingerprint)
+	assert.False(t, newChunks[1].IsDeduplicated())
+	assert.False(t, newChunks[2].IsDeduplicated())
+}
@@ -178,6 +178,9 @@ func mergePathConf(a, b *filer_pb.FilerConf_PathConf) {
 	a.Ttl = util.Nvl(b.Ttl, a.Ttl)
 	a.DiskType = util.Nvl(b.DiskType, a.DiskType)
 	a.Fsync = b.Fsync || a.Fsync
+	if b.GroupCommitMs > 0 {
+		a.GroupCommitMs = b.GroupCommitMs
+	}
 	if b.VolumeGrowthCount > 0 {
 		a.VolumeGrowthCount = b.VolumeGrowthCount
 	}
@@ -190,6 +193,13 @@ func mergePathConf(a, b *filer_pb.FilerConf_PathConf) {
 	a.DataNode = util.Nvl(b.DataNode, a.DataNode)
 	a.DisableChunkDeletion = b.DisableChunkDeletion || a.DisableChunkDeletion
 	a.Worm = b.Worm || a.Worm
+	a.PackSmallFiles = b.PackSmallFiles || a.PackSmallFiles
+	if b.PackMaxFileSize > 0 {
+		a.PackMaxFileSize = b.PackMaxFileSize
+	}
+	a.Dedup = b.Dedup || a.Dedup
+	a.Compression = util.Nvl(b.Compression, a.Compression)
+	a.EcScheme = util.Nvl(b.EcScheme, a.EcScheme)
 }
 
 func (fc *FilerConf) ToProto() *filer_pb.FilerConf {
@@ -0,0 +1,414 @@
+package filer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Uploads under a path configured with dedup are split into content-defined chunks.
+// A fingerprint index in the filer store maps the content hash of each chunk to its file id,
+// so identical chunks are stored only once. The index counts the entry chunks referencing
+// each stored chunk, and the data is deleted after the last reference is gone.
+// An upload holds a pending reference on each chunk it registers or reuses, until its entry
+// is saved or the upload fails, so the chunks are not deleted while the upload is in progress.
+//
+// The index is shared by the filers, and each record is changed under a distributed lock.
+// The reference changes of the saved entries are collected in memory, and applied in batches,
+// so saving an entry does not wait for the locks. The released pending references go through
+// the same batches, after the references of the entries saved before.
+// The chunks without references are queued, and deleted by one filer at a time.
+const (
+	DedupMinChunkSize = 256 * 1024
+	DedupAvgChunkSize = 1024 * 1024
+	DedupMaxChunkSize = 4 * 1024 * 1024
+
+	dedupKeyPrefix          = "dedup.chunk."
+	dedupRecordLockName     = "dedup.records"
+	dedupRecordLockCount    = 64
+	dedupFlushInterval      = time.Second
+	dedupReleaseQueueName   = "dedup.release"
+	dedupReleaseLockName    = "dedup.release"
+	dedupReleaseInterval    = 5 * time.Minute
+	dedupReleaseBatchSize   = 1024
+	dedupReleaseGracePeriod = time.Minute // for the reference changes pending on the other filers
+)
+
+var errNegativeDedupCount = errors.New("negative dedup reference count")
+
+type ChunkDeduper struct {
+	filer        *Filer
+	records      *recordLocks
+	releaseQueue *kvQueue
+	deltaLock    sync.Mutex
+	deltas       map[string]*dedupDelta
+}
+
+// dedupDelta is the change of the references to a chunk, not applied to its record yet
+type dedupDelta struct {
+	chunk    *filer_pb.FileChunk
+	fullpath util.FullPath // to find the collection of a chunk not in the index
+	refs     int64
+	pending  int64
+}
+
+func NewChunkDeduper(f *Filer) *ChunkDeduper {
+	return &ChunkDeduper{
+		filer:        f,
+		records:      newRecordLocks(f, dedupRecordLockName, dedupRecordLockCount),
+		releaseQueue: newKvQueue(f, dedupReleaseQueueName),
+		deltas:       make(map[string]*dedupDelta),
+	}
+}
+
+func DedupFingerprint(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func dedupKey(fingerprint []byte) []byte {
+	return []byte(dedupKeyPrefix + hex.EncodeToString(fingerprint))
+}
+
+func (d *ChunkDeduper) getRecord(ctx context.Context, fingerprint []byte) (*filer_pb.DedupChunk, error) {
+	data, err := d.filer.Store.KvGet(ctx, dedupKey(fingerprint))
+	if err == ErrKvNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &filer_pb.DedupChunk{}
+	if err = proto.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("unmarshal dedup chunk %x: %v", fingerprint, err)
+	}
+	return record, nil
+}
+
+func (d *ChunkDeduper) putRecord(ctx context.Context, record *filer_pb.DedupChunk) error {
+	data, err := proto.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return d.filer.Store.KvPut(ctx, dedupKey(record.Chunk.Fingerprint), data)
+}
+
+// Lookup returns a chunk with the same content in the collection, or nil if not found.
+// The returned chunk is at offset 0, and is held by a pending reference until ReleasePending.
+func (d *ChunkDeduper) Lookup(ctx context.Context, fingerprint []byte, collection string) (*filer_pb.FileChunk, error) {
+	unlock := d.records.lock(hex.EncodeToString(fingerprint))
+	defer unlock()
+
+	record, err := d.getRecord(ctx, fingerprint)
+	if err != nil || record == nil || record.Collection != collection {
+		return nil, err
+	}
+	// keep the chunk from being released before the entry is saved
+	record.PendingCount++
+	record.LastUsedTsNs = time.Now().UnixNano()
+	if err = d.putRecord(ctx, record); err != nil {
+		return nil, err
+	}
+	return proto.Clone(record.Chunk).(*filer_pb.FileChunk), nil
+}
+
+// Register adds a newly uploaded chunk to the index. If a chunk with the same content
+// was registered in the meantime, the existing chunk is returned instead.
+// The registered chunk is held by a pending reference until ReleasePending.
+func (d *ChunkDeduper) Register(ctx context.Context, chunk *filer_pb.FileChunk, collection string) (registered *filer_pb.FileChunk, isNew bool, err error) {
+	unlock := d.records.lock(hex.EncodeToString(chunk.Fingerprint))
+	defer unlock()
+
+	record, err := d.getRecord(ctx, chunk.Fingerprint)
+	if err != nil {
+		return nil, false, err
+	}
+	if record != nil && record.Collection == collection {
+		record.PendingCount++
+		record.LastUsedTsNs = time.Now().UnixNano()
+		if err = d.putRecord(ctx, record); err != nil {
+			return nil, false, err
+		}
+		return proto.Clone(record.Chunk).(*filer_pb.FileChunk), false, nil
+	}
+	if record != nil {
+		// the same content in another collection is stored separately
+		return nil, false, nil
+	}
+
+	stored := proto.Clone(chunk).(*filer_pb.FileChunk)
+	stored.Offset = 0
+	if err = d.putRecord(ctx, &filer_pb.DedupChunk{
+		Chunk:        stored,
+		LastUsedTsNs: time.Now().UnixNano(),
+		Collection:   collection,
+		PendingCount: 1,
+	}); err != nil {
+		return nil, false, err
+	}
+	return chunk, true, nil
+}
+
+// ReleasePending drops the pending references held by an upload, after its entry is saved or failed to be saved.
+// The chunks not referenced by any entry are queued to be deleted.
+func (d *ChunkDeduper) ReleasePending(ctx context.Context, chunks []*filer_pb.FileChunk) {
+	for _, chunk := range chunks {
+		if chunk.IsDeduplicated() {
+			d.addDelta(chunk, "", 0, -1)
+		}
+	}
+}
+
+func (d *ChunkDeduper) addDelta(chunk *filer_pb.FileChunk, fullpath util.FullPath, refs, pending int64) {
+	d.deltaLock.Lock()
+	defer d.deltaLock.Unlock()
+	key := hex.EncodeToString(chunk.Fingerprint)
+	delta, found := d.deltas[key]
+	if !found {
+		delta = &dedupDelta{chunk: chunk}
+		d.deltas[key] = delta
+	}
+	if fullpath != "" {
+		delta.fullpath = fullpath
+	}
+	delta.refs += refs
+	delta.pending += pending
+}
+
+func (d *ChunkDeduper) takeDeltas() map[string]*dedupDelta {
+	d.deltaLock.Lock()
+	defer d.deltaLock.Unlock()
+	deltas := d.deltas
+	d.deltas = make(map[string]*dedupDelta)
+	return deltas
+}
+
+type dedupRef struct {
+	chunk *filer_pb.FileChunk
+	count int64
+}
+
+// collectDedupRefs counts the deduplicated chunks of the entry, including the ones in chunk manifests
+func (f *Filer) collectDedupRefs(entry *Entry) map[string]*dedupRef {
+	if entry == nil || entry.IsDirectory() {
+		return nil
+	}
+	chunks := entry.GetChunks()
+	if HasChunkManifest(chunks) {
+		dataChunks, _, err := ResolveChunkManifest(f.MasterClient.GetLookupFileIdFunction(), chunks, 0, math.MaxInt64)
+		if err != nil {
+			glog.Warningf("resolve chunk manifests of %s: %v", entry.FullPath, err)
+		}
+		chunks = dataChunks
+	}
+	return countDedupRefs(chunks)
+}
+
+// countDedupRefs counts the deduplicated chunks by fingerprint
+func countDedupRefs(chunks []*filer_pb.FileChunk) map[string]*dedupRef {
+	var refs map[string]*dedupRef
+	for _, chunk := range chunks {
+		if !chunk.IsDeduplicated() {
+			continue
+		}
+		if refs == nil {
+			refs = make(map[string]*dedupRef)
+		}
+		key := string(chunk.Fingerprint)
+		if ref, found := refs[key]; found {
+			ref.count++
+		} else {
+			refs[key] = &dedupRef{chunk: chunk, count: 1}
+		}
+	}
+	return refs
+}
+
+func diffDedupRefs(oldRefs, newRefs map[string]*dedupRef) map[string]*dedupRef {
+	deltas := make(map[string]*dedupRef)
+	for key, ref := range newRefs {
+		deltas[key] = &dedupRef{chunk: ref.chunk, count: ref.count}
+	}
+	for key, ref := range oldRefs {
+		if delta, found := deltas[key]; found {
+			delta.count -= ref.count
+		} else {
+			deltas[key] = &dedupRef{chunk: ref.chunk, count: -ref.count}
+		}
+	}
+	return deltas
+}
+
+// updateDedupRefs counts the references to the deduplicated chunks. Renamed entries are
+// created at the new path before being deleted at the old path, so the count stays positive.
+func (f *Filer) updateDedupRefs(ctx context.Context, oldEntry, newEntry *Entry) {
+	oldRefs, newRefs := f.collectDedupRefs(oldEntry), f.collectDedupRefs(newEntry)
+	if len(oldRefs) == 0 && len(newRefs) == 0 {
+		return
+	}
+
+	var fullpath util.FullPath
+	if newEntry != nil {
+		fullpath = newEntry.FullPath
+	} else {
+		fullpath = oldEntry.FullPath
+	}
+
+	for _, delta := range diffDedupRefs(oldRefs, newRefs) {
+		if delta.count != 0 {
+			f.Deduper.addDelta(delta.chunk, fullpath, delta.count, 0)
+		}
+	}
+}
+
+// LoopFlushRefs applies the reference changes of this filer to the index
+func (d *ChunkDeduper) LoopFlushRefs() {
+	for {
+		time.Sleep(dedupFlushInterval)
+		if deltas := d.takeDeltas(); len(deltas) > 0 {
+			d.flushDeltas(context.Background(), deltas)
+		}
+	}
+}
+
+func (d *ChunkDeduper) flushDeltas(ctx context.Context, deltas map[string]*dedupDelta) {
+	keys := make([]string, 0, len(deltas))
+	for key := range deltas {
+		keys = append(keys, key)
+	}
+	for _, group := range d.records.groupByLock(keys) {
+		unlock := d.records.lock(group...)
+		for _, key := range group {
+			delta := deltas[key]
+			err := d.applyDelta(ctx, key, delta)
+			if errors.Is(err, errNegativeDedupCount) {
+				glog.Errorf("update dedup chunk %s: %v", key, err)
+			} else if err != nil {
+				// retried with the next flush
+				glog.Warningf("update dedup chunk %s: %v", key, err)
+				d.addDelta(delta.chunk, delta.fullpath, delta.refs, delta.pending)
+			}
+		}
+		unlock()
+	}
+}
+
+// applyDelta changes the counts of the chunk record, with the record locked
+func (d *ChunkDeduper) applyDelta(ctx context.Context, key string, delta *dedupDelta) error {
+	if delta.refs == 0 && delta.pending == 0 {
+		return nil
+	}
+	record, err := d.getRecord(ctx, delta.chunk.Fingerprint)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		if delta.refs <= 0 {
+			return nil
+		}
+		// e.g., entries replicated from another cluster
+		stored := proto.Clone(delta.chunk).(*filer_pb.FileChunk)
+		stored.Offset = 0
+		collection := d.filer.FilerConf.MatchStorageRule(string(delta.fullpath)).Collection
+		if strings.HasPrefix(string(delta.fullpath), d.filer.DirBucketsPath+"/") {
+			collection = d.filer.DetectBucket(delta.fullpath)
+		}
+		record = &filer_pb.DedupChunk{
+			Chunk:      stored,
+			Collection: collection,
+		}
+	}
+	refCount, pendingCount := record.RefCount+delta.refs, record.PendingCount+delta.pending
+	if refCount < 0 || pendingCount < 0 {
+		return fmt.Errorf("%w: chunk %s with %d references and %d pending changed by %d and %d",
+			errNegativeDedupCount, record.Chunk.GetFileIdString(), record.RefCount, record.PendingCount, delta.refs, delta.pending)
+	}
+	record.RefCount, record.PendingCount = refCount, pendingCount
+	record.LastUsedTsNs = time.Now().UnixNano()
+	if err = d.putRecord(ctx, record); err != nil {
+		return err
+	}
+	if record.RefCount == 0 && record.PendingCount == 0 {
+		return d.releaseQueue.Enqueue(ctx, key)
+	}
+	return nil
+}
+
+// LoopRelease deletes the queued chunks on the filer holding the release lock
+func (d *ChunkDeduper) LoopRelease() {
+	self := string(d.filer.Dlm.Host)
+	var isReleaser atomic.Bool
+	cluster.NewLockClient(d.filer.GrpcDialOption, d.filer.Dlm.Host).StartLongLivedLock(dedupReleaseLockName, self, func(newLockOwner string) {
+		isReleaser.Store(newLockOwner == self)
+	})
+	for {
+		time.Sleep(dedupReleaseInterval)
+		if !isReleaser.Load() {
+			continue
+		}
+		if err := d.releaseChunks(context.Background()); err != nil {
+			glog.Warningf("release dedup chunks: %v", err)
+		}
+	}
+}
+
+// releaseChunks deletes the queued chunks no longer referenced by any entry
+func (d *ChunkDeduper) releaseChunks(ctx context.Context) error {
+	items, err := d.releaseQueue.Peek(ctx, dedupReleaseBatchSize)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	done := make([]bool, len(items))
+	for i, item := range items {
+		if item.value == "" {
+			done[i] = true
+			continue
+		}
+		var releaseErr error
+		done[i], releaseErr = d.releaseChunk(ctx, item.value)
+		if releaseErr != nil {
+			glog.Warningf("release dedup chunk %s: %v", item.value, releaseErr)
+		}
+	}
+	return d.releaseQueue.Advance(ctx, items, done)
+}
+
+func (d *ChunkDeduper) releaseChunk(ctx context.Context, hexFingerprint string) (done bool, err error) {
+	fingerprint, err := hex.DecodeString(hexFingerprint)
+	if err != nil {
+		return true, err
+	}
+	unlock := d.records.lock(hexFingerprint)
+	defer unlock()
+	record, err := d.getRecord(ctx, fingerprint)
+	if err != nil || record == nil {
+		return err == nil, err
+	}
+	if record.RefCount > 0 || record.PendingCount > 0 {
+		// queued again when the last reference is gone
+		return true, nil
+	}
+	if time.Since(time.Unix(0, record.LastUsedTsNs)) < dedupReleaseGracePeriod {
+		return false, nil
+	}
+	if err = d.filer.Store.KvDelete(ctx, dedupKey(fingerprint)); err != nil {
+		return false, err
+	}
+	glog.V(1).Infof("delete dedup chunk %s", record.Chunk.GetFileIdString())
+	d.filer.fileIdDeletionQueue.EnQueue(record.Chunk.GetFileIdString())
+	return true, nil
+}
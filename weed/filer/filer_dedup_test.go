@@ -0,0 +1,65 @@
+package filer
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDedupRefs(t *testing.T) {
+
+	a, b, c := DedupFingerprint([]byte("a")), DedupFingerprint([]byte("b")), DedupFingerprint([]byte("c"))
+
+	oldRefs := countDedupRefs([]*filer_pb.FileChunk{
+		{FileId: "1,a", Offset: 0, Size: 1, Fingerprint: a},
+		{FileId: "1,a", Offset: 1, Size: 1, Fingerprint: a},
+		{FileId: "2,b", Offset: 2, Size: 1, Fingerprint: b},
+		{FileId: "3,normal", Offset: 3, Size: 1},
+	})
+	assert.Equal(t, 2, len(oldRefs))
+	assert.Equal(t, int64(2), oldRefs[string(a)].count)
+
+	newRefs := countDedupRefs([]*filer_pb.FileChunk{
+		{FileId: "1,a", Offset: 0, Size: 1, Fingerprint: a},
+		{FileId: "2,b", Offset: 1, Size: 1, Fingerprint: b},
+		{FileId: "4,c", Offset: 2, Size: 1, Fingerprint: c},
+	})
+
+	deltas := diffDedupRefs(oldRefs, newRefs)
+	assert.Equal(t, int64(-1), deltas[string(a)].count)
+	assert.Equal(t, int64(0), deltas[string(b)].count, "overwritten with the same content")
+	assert.Equal(t, int64(1), deltas[string(c)].count)
+
+	deleted := diffDedupRefs(newRefs, nil)
+	for _, delta := range deleted {
+		assert.Equal(t, int64(-1), delta.count)
+	}
+}
+
+func TestDedupDeltas(t *testing.T) {
+
+	d := NewChunkDeduper(nil)
+	a, b := &filer_pb.FileChunk{FileId: "1,a", Fingerprint: DedupFingerprint([]byte("a"))}, &filer_pb.FileChunk{FileId: "2,b", Fingerprint: DedupFingerprint([]byte("b"))}
+
+	// an upload saving its entry, and a rename of another entry
+	d.addDelta(a, "/dir/file", 1, 0)
+	d.ReleasePending(context.Background(), []*filer_pb.FileChunk{a, {FileId: "3,normal"}})
+	d.addDelta(b, "/new/name", 1, 0)
+	d.addDelta(b, "/old/name", -1, 0)
+
+	deltas := d.takeDeltas()
+	assert.Equal(t, 2, len(deltas))
+	deltaA := deltas[hex.EncodeToString(a.Fingerprint)]
+	assert.Equal(t, int64(1), deltaA.refs)
+	assert.Equal(t, int64(-1), deltaA.pending, "the pending reference is released with the reference of the saved entry")
+	assert.Equal(t, util.FullPath("/dir/file"), deltaA.fullpath)
+	deltaB := deltas[hex.EncodeToString(b.Fingerprint)]
+	assert.Equal(t, int64(0), deltaB.refs)
+	assert.NoError(t, d.applyDelta(context.Background(), hex.EncodeToString(b.Fingerprint), deltaB), "nothing to change")
+
+	assert.Equal(t, 0, len(d.takeDeltas()))
+}
@@ -86,6 +86,10 @@ func (f *Filer) DeleteChunks(fullpath util.FullPath, chunks []*filer_pb.FileChun
 
 func (f *Filer) doDeleteChunks(chunks []*filer_pb.FileChunk) {
 	for _, chunk := range chunks {
+		if chunk.IsPacked() || chunk.IsDeduplicated() {
+			// the shared data is deleted after all its references are gone
+			continue
+		}
 		if !chunk.IsChunkManifest {
 			f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
 			continue
@@ -95,6 +99,9 @@ func (f *Filer) doDeleteChunks(chunks []*filer_pb.FileChunk) {
 			glog.V(0).Infof("failed to resolve manifest %s: %v", chunk.FileId, manifestResolveErr)
 		}
 		for _, dChunk := range dataChunks {
+			if dChunk.IsPacked() || dChunk.IsDeduplicated() {
+				continue
+			}
 			f.fileIdDeletionQueue.EnQueue(dChunk.GetFileIdString())
 		}
 		f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
@@ -103,6 +110,9 @@ func (f *Filer) doDeleteChunks(chunks []*filer_pb.FileChunk) {
 
 func (f *Filer) DeleteChunksNotRecursive(chunks []*filer_pb.FileChunk) {
 	for _, chunk := range chunks {
+		if chunk.IsPacked() || chunk.IsDeduplicated() {
+			continue
+		}
 		f.fileIdDeletionQueue.EnQueue(chunk.GetFileIdString())
 	}
 }
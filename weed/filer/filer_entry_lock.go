@@ -0,0 +1,44 @@
+package filer
+
+import (
+	"sort"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// LockEntries serializes the read-modify-write of the file entries, across the filers sharing the store.
+// A clone marks the chunks of the source entry as shared, so the writers saving the entry must read the
+// existing entry after the clone, to keep the shared chunks, and the clone must not overwrite their chunks.
+// A distributed lock can not be taken twice by the same owner, so a local lock per path serializes
+// the goroutines of this filer. The paths are locked in ascending order, so the lockers do not deadlock.
+func (f *Filer) LockEntries(fullpaths ...util.FullPath) (unlock func()) {
+	sorted := make([]util.FullPath, 0, len(fullpaths))
+	seen := make(map[util.FullPath]bool)
+	for _, fullpath := range fullpaths {
+		if !seen[fullpath] {
+			seen[fullpath] = true
+			sorted = append(sorted, fullpath)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	lockClient := cluster.NewLockClient(f.GrpcDialOption, f.Dlm.Host)
+	var localLocks []*util.ActiveLock
+	var locks []*cluster.LiveLock
+	for _, fullpath := range sorted {
+		localLocks = append(localLocks, f.entryLocks.AcquireLock("entry", fullpath, util.ExclusiveLock))
+		locks = append(locks, lockClient.NewShortLivedLock(string(fullpath), string(f.Dlm.Host)))
+	}
+	return func() {
+		for i := len(sorted) - 1; i >= 0; i-- {
+			if err := locks[i].StopShortLivedLock(); err != nil {
+				glog.Warningf("unlock %s: %v", sorted[i], err)
+			}
+			f.entryLocks.ReleaseLock(sorted[i], localLocks[i])
+		}
+	}
+}
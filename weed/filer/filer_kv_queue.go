@@ -0,0 +1,149 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// kvQueue is a queue of unique items in the filer store kv, shared by the filers.
+// Each item has its own key between a head and a tail counter, so the filers never overwrite the items
+// queued by each other. The counters are changed under a distributed lock:
+//
+//	<name>.head, <name>.tail => the sequence numbers of the queue
+//	<name>.item.<sequence> => the queued item
+//	<name>.queued.<item> => the sequence number, to queue an item only once
+type kvQueue struct {
+	filer     *Filer
+	name      string
+	localLock sync.Mutex // serializes the distributed lock within this filer
+}
+
+type kvQueueItem struct {
+	sequence uint64
+	value    string // empty for a missing item
+}
+
+func newKvQueue(f *Filer, name string) *kvQueue {
+	return &kvQueue{
+		filer: f,
+		name:  name,
+	}
+}
+
+func (q *kvQueue) lock() (unlock func()) {
+	q.localLock.Lock()
+	lockName := q.name + ".lock"
+	lock := cluster.NewLockClient(q.filer.GrpcDialOption, q.filer.Dlm.Host).NewShortLivedLock(lockName, string(q.filer.Dlm.Host))
+	return func() {
+		if err := lock.StopShortLivedLock(); err != nil {
+			glog.Warningf("unlock %s: %v", lockName, err)
+		}
+		q.localLock.Unlock()
+	}
+}
+
+func (q *kvQueue) getCounter(ctx context.Context, key string) (uint64, error) {
+	data, err := q.filer.Store.KvGet(ctx, []byte(key))
+	if err == ErrKvNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("%s has %d bytes", key, len(data))
+	}
+	return util.BytesToUint64(data), nil
+}
+
+func (q *kvQueue) putCounter(ctx context.Context, key string, value uint64) error {
+	data := make([]byte, 8)
+	util.Uint64toBytes(data, value)
+	return q.filer.Store.KvPut(ctx, []byte(key), data)
+}
+
+func (q *kvQueue) itemKey(sequence uint64) []byte {
+	return []byte(fmt.Sprintf("%s.item.%016x", q.name, sequence))
+}
+
+func (q *kvQueue) queuedKey(value string) string {
+	return q.name + ".queued." + value
+}
+
+// append adds the item at the tail, with the queue locked
+func (q *kvQueue) append(ctx context.Context, value string) error {
+	tail, err := q.getCounter(ctx, q.name+".tail")
+	if err != nil {
+		return err
+	}
+	if err = q.filer.Store.KvPut(ctx, q.itemKey(tail), []byte(value)); err != nil {
+		return err
+	}
+	if err = q.putCounter(ctx, q.name+".tail", tail+1); err != nil {
+		return err
+	}
+	return q.putCounter(ctx, q.queuedKey(value), tail)
+}
+
+// Enqueue adds the item unless it is queued already
+func (q *kvQueue) Enqueue(ctx context.Context, value string) error {
+	unlock := q.lock()
+	defer unlock()
+	if _, err := q.filer.Store.KvGet(ctx, []byte(q.queuedKey(value))); err != ErrKvNotFound {
+		return err
+	}
+	return q.append(ctx, value)
+}
+
+// Peek reads at most limit items from the head, without removing them
+func (q *kvQueue) Peek(ctx context.Context, limit int) (items []kvQueueItem, err error) {
+	unlock := q.lock()
+	defer unlock()
+	head, err := q.getCounter(ctx, q.name+".head")
+	if err != nil {
+		return nil, err
+	}
+	tail, err := q.getCounter(ctx, q.name+".tail")
+	if err != nil {
+		return nil, err
+	}
+	for sequence := head; sequence < tail && len(items) < limit; sequence++ {
+		data, getErr := q.filer.Store.KvGet(ctx, q.itemKey(sequence))
+		if getErr != nil && getErr != ErrKvNotFound {
+			return nil, getErr
+		}
+		items = append(items, kvQueueItem{sequence: sequence, value: string(data)})
+	}
+	return items, nil
+}
+
+// Advance removes the peeked items from the head, and queues the unfinished ones again at the tail
+func (q *kvQueue) Advance(ctx context.Context, items []kvQueueItem, done []bool) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+	unlock := q.lock()
+	defer unlock()
+	for i, item := range items {
+		if err = q.filer.Store.KvDelete(ctx, q.itemKey(item.sequence)); err != nil {
+			return err
+		}
+		if item.value == "" {
+			continue
+		}
+		if done[i] {
+			err = q.filer.Store.KvDelete(ctx, []byte(q.queuedKey(item.value)))
+		} else {
+			err = q.append(ctx, item.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return q.putCounter(ctx, q.name+".head", items[len(items)-1].sequence+1)
+}
@@ -63,6 +63,11 @@ func (f *Filer) NotifyUpdateEvent(ctx context.Context, oldEntry, newEntry *Entry
 		}
 	}
 
+	f.updateQuotaUsage(ctx, oldEntry, newEntry)
+	f.updatePackedRefs(ctx, oldEntry, newEntry)
+	f.Packer.scheduleAdoption(newEntry, isFromOtherCluster)
+	f.updateDedupRefs(ctx, oldEntry, newEntry)
+
 	f.logMetaEvent(ctx, fullpath, eventNotification)
 
 }
@@ -47,6 +47,9 @@ func (f *Filer) maybeReloadFilerConfiguration(event *filer_pb.SubscribeMetadataR
 	if entry.Name == FilerConfName {
 		f.reloadFilerConfiguration(entry)
 	}
+	if entry.Name == QuotaConfName {
+		f.reloadQuotaConfiguration(entry)
+	}
 }
 
 func (f *Filer) readEntry(chunks []*filer_pb.FileChunk, size uint64) ([]byte, error) {
@@ -0,0 +1,651 @@
+package filer
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// Small files under a path configured with pack_small_files are appended into shared container chunks.
+// Each entry refers to its data range in the container. The filer keeps a record of the entries
+// still referencing each container, moves the remaining files out of containers that are mostly
+// deleted, and deletes containers no longer referenced.
+//
+// The files uploaded through the http autochunk path are packed directly. The small files written
+// by the other clients, e.g. the mount, nfs or grpc clients, are moved into containers a while after
+// their last change.
+//
+// The records are kept in the filer store kv as pack.container.<file id>, shared by the filers,
+// and each record is changed under a distributed lock. The reference changes of the saved entries
+// are collected in memory and applied in batches, so saving an entry does not wait for the locks.
+// The containers to compact are kept in a queue in the kv, and compacted by one filer at a time.
+const (
+	PackDefaultMaxFileSize = 64 * 1024
+
+	packContainerSizeLimit    = 4 * 1024 * 1024
+	packFlushInterval         = 50 * time.Millisecond
+	packContainerKeyPrefix    = "pack.container."
+	packRecordLockName        = "pack.records"
+	packRecordLockCount       = 64
+	packRefFlushInterval      = time.Second
+	packCompactionQueueName   = "pack.compaction"
+	packCompactionLockName    = "pack.compaction"
+	packCompactionInterval    = 5 * time.Minute
+	packCompactionBatchSize   = 1024
+	packCompactionGracePeriod = time.Minute // for the entries of a new container to be saved
+	packCompactionConcurrency = 16
+	packAdoptionDelay         = time.Minute // after the last change of a small file written by the other clients
+	packAdoptionInterval      = 10 * time.Second
+	packAdoptionQueueLimit    = 64 * 1024
+)
+
+type FilePacker struct {
+	filer *Filer
+	sync.Mutex
+	openContainers  map[string]*packingContainer
+	records         *recordLocks
+	compactionQueue *kvQueue
+	refChangeLock   sync.Mutex
+	refChanges      map[string][]*packedRefChange // by container file id, in the order of the entry changes
+	adoptionLock    sync.Mutex
+	adoptions       map[util.FullPath]time.Time
+}
+
+type packingContainer struct {
+	so      *operation.StorageOption
+	data    []byte
+	flushed chan struct{}
+	fileId  string
+	err     error
+}
+
+func NewFilePacker(f *Filer) *FilePacker {
+	return &FilePacker{
+		filer:           f,
+		openContainers:  make(map[string]*packingContainer),
+		records:         newRecordLocks(f, packRecordLockName, packRecordLockCount),
+		compactionQueue: newKvQueue(f, packCompactionQueueName),
+		refChanges:      make(map[string][]*packedRefChange),
+		adoptions:       make(map[util.FullPath]time.Time),
+	}
+}
+
+// PackMaxFileSize is the size of the largest file to pack under the path, 0 if the files are not packed
+func PackMaxFileSize(rule *filer_pb.FilerConf_PathConf) int64 {
+	if !rule.PackSmallFiles {
+		return 0
+	}
+	if rule.PackMaxFileSize == 0 {
+		return PackDefaultMaxFileSize
+	}
+	return int64(rule.PackMaxFileSize)
+}
+
+func packingKey(so *operation.StorageOption) string {
+	return strings.Join([]string{so.Collection, so.Replication, so.DiskType, so.DataCenter, so.Rack, so.DataNode}, "|")
+}
+
+// Pack appends the data to a shared container chunk and returns a chunk referring to the data range.
+// It returns after the container is stored, so the entry never refers to unsaved data.
+func (p *FilePacker) Pack(so *operation.StorageOption, data []byte) (*filer_pb.FileChunk, error) {
+	key := packingKey(so)
+
+	p.Lock()
+	c, found := p.openContainers[key]
+	if !found {
+		c = &packingContainer{
+			so:      so,
+			flushed: make(chan struct{}),
+		}
+		p.openContainers[key] = c
+		time.AfterFunc(packFlushInterval, func() {
+			p.flush(key, c)
+		})
+	}
+	offset := int64(len(c.data))
+	c.data = append(c.data, data...)
+	if len(c.data) >= packContainerSizeLimit {
+		go p.flush(key, c)
+	}
+	p.Unlock()
+
+	<-c.flushed
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	md5Hash := md5.Sum(data)
+	fid, _ := filer_pb.ToFileIdObject(c.fileId)
+	return &filer_pb.FileChunk{
+		FileId:              c.fileId,
+		Size:                uint64(len(data)),
+		ModifiedTsNs:        time.Now().UnixNano(),
+		ETag:                util.Base64Encode(md5Hash[:]),
+		Fid:                 fid,
+		PackedOffset:        offset,
+		PackedContainerSize: uint64(len(c.data)),
+	}, nil
+}
+
+func (p *FilePacker) flush(key string, c *packingContainer) {
+	p.Lock()
+	if p.openContainers[key] != c {
+		p.Unlock()
+		return
+	}
+	delete(p.openContainers, key)
+	p.Unlock()
+
+	c.fileId, c.err = p.upload(c.so, c.data)
+	if c.err == nil {
+		unlock := p.records.lock(c.fileId)
+		c.err = p.putRecord(context.Background(), &filer_pb.PackedContainer{
+			FileId:      c.fileId,
+			Size:        uint64(len(c.data)),
+			CreatedTsNs: time.Now().UnixNano(),
+			Collection:  c.so.Collection,
+			Replication: c.so.Replication,
+			DiskType:    c.so.DiskType,
+		})
+		if c.err == nil {
+			// containers whose entries failed to be saved are deleted by the compaction
+			c.err = p.compactionQueue.Enqueue(context.Background(), c.fileId)
+		}
+		unlock()
+	}
+	if c.err != nil {
+		glog.Errorf("flush packed container of %d bytes: %v", len(c.data), c.err)
+	}
+	close(c.flushed)
+}
+
+func (p *FilePacker) upload(so *operation.StorageOption, data []byte) (fileId string, err error) {
+	err = util.Retry("packContainer", func() error {
+		assignRequest, altRequest := so.ToAssignRequests(1)
+		assignResult, assignErr := operation.Assign(p.filer.GetMaster, p.filer.GrpcDialOption, assignRequest, altRequest)
+		if assignErr != nil {
+			return fmt.Errorf("assign volume: %v", assignErr)
+		}
+		if assignResult.Error != "" {
+			return fmt.Errorf("assign volume: %v", assignResult.Error)
+		}
+
+		// the files are read by ranges, so the container is neither compressed nor encrypted
+		uploadOption := &operation.UploadOption{
+			UploadUrl:         "http://" + assignResult.Url + "/" + assignResult.Fid,
+			Filename:          "",
+			Cipher:            false,
+			IsInputCompressed: false,
+			MimeType:          "application/octet-stream",
+			PairMap:           nil,
+			Jwt:               assignResult.Auth,
+		}
+		uploader, uploaderErr := operation.NewUploader()
+		if uploaderErr != nil {
+			return uploaderErr
+		}
+		if _, uploadErr := uploader.UploadData(data, uploadOption); uploadErr != nil {
+			return uploadErr
+		}
+		fileId = assignResult.Fid
+		return nil
+	})
+	return
+}
+
+func (p *FilePacker) getRecord(ctx context.Context, fileId string) (*filer_pb.PackedContainer, error) {
+	data, err := p.filer.Store.KvGet(ctx, []byte(packContainerKeyPrefix+fileId))
+	if err == ErrKvNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &filer_pb.PackedContainer{}
+	if err = proto.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("unmarshal packed container %s: %v", fileId, err)
+	}
+	return record, nil
+}
+
+func (p *FilePacker) putRecord(ctx context.Context, record *filer_pb.PackedContainer) error {
+	data, err := proto.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return p.filer.Store.KvPut(ctx, []byte(packContainerKeyPrefix+record.FileId), data)
+}
+
+type packedRef struct {
+	fileId string
+	path   string
+	offset int64
+}
+
+func collectPackedRefs(entry *Entry) map[packedRef]*filer_pb.FileChunk {
+	if entry == nil || entry.IsDirectory() {
+		return nil
+	}
+	var refs map[packedRef]*filer_pb.FileChunk
+	for _, chunk := range entry.GetChunks() {
+		if !chunk.IsPacked() {
+			continue
+		}
+		if refs == nil {
+			refs = make(map[packedRef]*filer_pb.FileChunk)
+		}
+		refs[packedRef{chunk.GetFileIdString(), string(entry.FullPath), chunk.PackedOffset}] = chunk
+	}
+	return refs
+}
+
+// packedRefChange is a change of the entries referencing a container, not applied to its record yet
+type packedRefChange struct {
+	removed []packedRef
+	added   map[packedRef]*filer_pb.FileChunk
+}
+
+// updatePackedRefs records which entries reference the container chunks, including renamed entries
+func (f *Filer) updatePackedRefs(ctx context.Context, oldEntry, newEntry *Entry) {
+	oldRefs, newRefs := collectPackedRefs(oldEntry), collectPackedRefs(newEntry)
+	if len(oldRefs) == 0 && len(newRefs) == 0 {
+		return
+	}
+
+	changes := make(map[string]*packedRefChange)
+	changeOf := func(fileId string) *packedRefChange {
+		change, found := changes[fileId]
+		if !found {
+			change = &packedRefChange{added: make(map[packedRef]*filer_pb.FileChunk)}
+			changes[fileId] = change
+		}
+		return change
+	}
+	for ref := range oldRefs {
+		if _, found := newRefs[ref]; !found {
+			changeOf(ref.fileId).removed = append(changeOf(ref.fileId).removed, ref)
+		}
+	}
+	for ref, chunk := range newRefs {
+		if _, found := oldRefs[ref]; !found {
+			changeOf(ref.fileId).added[ref] = chunk
+		}
+	}
+
+	p := f.Packer
+	p.refChangeLock.Lock()
+	defer p.refChangeLock.Unlock()
+	for fileId, change := range changes {
+		p.refChanges[fileId] = append(p.refChanges[fileId], change)
+	}
+}
+
+func (p *FilePacker) takeRefChanges() map[string][]*packedRefChange {
+	p.refChangeLock.Lock()
+	defer p.refChangeLock.Unlock()
+	changes := p.refChanges
+	p.refChanges = make(map[string][]*packedRefChange)
+	return changes
+}
+
+// LoopFlushRefs applies the reference changes of this filer to the container records
+func (p *FilePacker) LoopFlushRefs() {
+	for {
+		time.Sleep(packRefFlushInterval)
+		if changes := p.takeRefChanges(); len(changes) > 0 {
+			p.flushRefChanges(context.Background(), changes)
+		}
+	}
+}
+
+func (p *FilePacker) flushRefChanges(ctx context.Context, changes map[string][]*packedRefChange) {
+	fileIds := make([]string, 0, len(changes))
+	for fileId := range changes {
+		fileIds = append(fileIds, fileId)
+	}
+	for _, group := range p.records.groupByLock(fileIds) {
+		unlock := p.records.lock(group...)
+		for _, fileId := range group {
+			for i, change := range changes[fileId] {
+				if err := p.applyRefChange(ctx, fileId, change.removed, change.added); err != nil {
+					// retried with the next flush, before the later changes
+					glog.Warningf("update packed container %s: %v", fileId, err)
+					p.refChangeLock.Lock()
+					p.refChanges[fileId] = append(changes[fileId][i:], p.refChanges[fileId]...)
+					p.refChangeLock.Unlock()
+					break
+				}
+			}
+		}
+		unlock()
+	}
+}
+
+// applyRefChange changes the entries referencing the container, with its record locked
+func (p *FilePacker) applyRefChange(ctx context.Context, fileId string, removed []packedRef, added map[packedRef]*filer_pb.FileChunk) error {
+	record, err := p.getRecord(ctx, fileId)
+	if err != nil {
+		return err
+	}
+	isNewRecord := false
+	if record == nil {
+		if len(added) == 0 {
+			return nil
+		}
+		// e.g., entries replicated from another cluster
+		isNewRecord = true
+		record = &filer_pb.PackedContainer{
+			FileId:      fileId,
+			CreatedTsNs: time.Now().UnixNano(),
+		}
+		for ref, chunk := range added {
+			rule := p.filer.FilerConf.MatchStorageRule(ref.path)
+			record.Size = chunk.PackedContainerSize
+			record.Collection, record.Replication, record.DiskType = rule.Collection, rule.Replication, rule.DiskType
+			break
+		}
+	}
+
+	var refs []*filer_pb.PackedContainer_Ref
+	for _, ref := range record.Refs {
+		isRemoved := false
+		for _, r := range removed {
+			if ref.Path == r.path && ref.Offset == r.offset {
+				isRemoved = true
+				break
+			}
+		}
+		if !isRemoved {
+			refs = append(refs, ref)
+		}
+	}
+	for ref, chunk := range added {
+		refs = append(refs, &filer_pb.PackedContainer_Ref{
+			Path:   ref.path,
+			Offset: ref.offset,
+			Size:   chunk.Size,
+		})
+	}
+	record.Refs = refs
+
+	if err = p.putRecord(ctx, record); err != nil {
+		return err
+	}
+	if isNewRecord || (len(removed) > 0 && needsCompaction(record)) {
+		return p.compactionQueue.Enqueue(ctx, fileId)
+	}
+	return nil
+}
+
+// needsCompaction is true if more than half of the container is deleted
+func needsCompaction(record *filer_pb.PackedContainer) bool {
+	var liveBytes uint64
+	for _, ref := range record.Refs {
+		liveBytes += ref.Size
+	}
+	return liveBytes*2 < record.Size
+}
+
+// LoopCompaction compacts the queued containers on the filer holding the compaction lock
+func (p *FilePacker) LoopCompaction() {
+	self := string(p.filer.Dlm.Host)
+	var isCompactor atomic.Bool
+	cluster.NewLockClient(p.filer.GrpcDialOption, p.filer.Dlm.Host).StartLongLivedLock(packCompactionLockName, self, func(newLockOwner string) {
+		isCompactor.Store(newLockOwner == self)
+	})
+	for {
+		time.Sleep(packCompactionInterval)
+		if !isCompactor.Load() {
+			continue
+		}
+		if err := p.compactContainers(context.Background()); err != nil {
+			glog.Warningf("compact packed containers: %v", err)
+		}
+	}
+}
+
+func (p *FilePacker) compactContainers(ctx context.Context) error {
+	queued, err := p.compactionQueue.Peek(ctx, packCompactionBatchSize)
+	if err != nil || len(queued) == 0 {
+		return err
+	}
+
+	done := make([]bool, len(queued))
+	for i, q := range queued {
+		if q.value == "" {
+			done[i] = true
+			continue
+		}
+		var compactErr error
+		done[i], compactErr = p.compactContainer(ctx, q.value)
+		if compactErr != nil {
+			glog.Warningf("compact packed container %s: %v", q.value, compactErr)
+		}
+	}
+
+	// move the head past the processed ones, and queue the unfinished ones again
+	return p.compactionQueue.Advance(ctx, queued, done)
+}
+
+// compactContainer deletes the container if no entry references it,
+// or moves the remaining files into new containers if it is mostly deleted.
+// The container stays queued until it can be deleted or needs no compaction.
+func (p *FilePacker) compactContainer(ctx context.Context, fileId string) (done bool, err error) {
+	unlock := p.records.lock(fileId)
+	record, err := p.getRecord(ctx, fileId)
+	if err != nil || record == nil {
+		unlock()
+		return err == nil, err
+	}
+	if time.Since(time.Unix(0, record.CreatedTsNs)) < packCompactionGracePeriod {
+		unlock()
+		return false, nil
+	}
+	if len(record.Refs) == 0 {
+		err = p.filer.Store.KvDelete(ctx, []byte(packContainerKeyPrefix+fileId))
+		unlock()
+		if err != nil {
+			return false, err
+		}
+		glog.V(1).Infof("delete packed container %s", fileId)
+		p.filer.fileIdDeletionQueue.EnQueue(fileId)
+		return true, nil
+	}
+	unlock()
+
+	if !needsCompaction(record) {
+		return true, nil
+	}
+
+	glog.V(1).Infof("compact packed container %s with %d files", fileId, len(record.Refs))
+	so := &operation.StorageOption{
+		Collection:  record.Collection,
+		Replication: record.Replication,
+		DiskType:    record.DiskType,
+	}
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	limiter := make(chan struct{}, packCompactionConcurrency)
+	for _, ref := range record.Refs {
+		wg.Add(1)
+		limiter <- struct{}{}
+		go func(ref *filer_pb.PackedContainer_Ref) {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+			if repackErr := p.repack(ctx, fileId, ref, so); repackErr != nil {
+				errLock.Lock()
+				err = repackErr
+				errLock.Unlock()
+			}
+		}(ref)
+	}
+	wg.Wait()
+
+	// the container is deleted in a later round, after all references are moved
+	return false, err
+}
+
+func findPackedChunk(entry *Entry, fileId string, offset int64) int {
+	for i, chunk := range entry.GetChunks() {
+		if chunk.IsPacked() && chunk.GetFileIdString() == fileId && chunk.PackedOffset == offset {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *FilePacker) repack(ctx context.Context, fileId string, ref *filer_pb.PackedContainer_Ref, so *operation.StorageOption) error {
+	fullpath := util.FullPath(ref.Path)
+	entry, err := p.filer.FindEntry(ctx, fullpath)
+	if err != nil && err != filer_pb.ErrNotFound {
+		return err
+	}
+	index := -1
+	if err == nil {
+		index = findPackedChunk(entry, fileId, ref.Offset)
+	}
+	if index < 0 {
+		// missed the change of this entry
+		unlock := p.records.lock(fileId)
+		defer unlock()
+		return p.applyRefChange(ctx, fileId, []packedRef{{fileId, ref.Path, ref.Offset}}, nil)
+	}
+	chunk := entry.GetChunks()[index]
+
+	data := make([]byte, chunk.Size)
+	n, err := fetchChunkRange(data, p.filer.MasterClient.GetLookupFileIdFunction(), fileId, nil, false, chunk.DataOffset())
+	if err != nil {
+		return fmt.Errorf("read %s from %s: %v", fullpath, fileId, err)
+	}
+	if n != len(data) {
+		return fmt.Errorf("read %s from %s: %d of %d bytes", fullpath, fileId, n, len(data))
+	}
+
+	newChunk, err := p.Pack(so, data)
+	if err != nil {
+		return err
+	}
+	newChunk.Offset, newChunk.ModifiedTsNs = chunk.Offset, chunk.ModifiedTsNs
+
+	// the entry could have changed while its data is copied
+	latest, err := p.filer.FindEntry(ctx, fullpath)
+	if err != nil {
+		return err
+	}
+	if index = findPackedChunk(latest, fileId, ref.Offset); index < 0 {
+		return nil
+	}
+	latest.Chunks[index] = newChunk
+	return p.filer.CreateEntry(ctx, latest, false, false, nil, true, p.filer.MaxFilenameLength)
+}
+
+// packableChunk returns the only chunk of a small file that could be moved into a container
+func packableChunk(entry *Entry, rule *filer_pb.FilerConf_PathConf) *filer_pb.FileChunk {
+	maxFileSize := PackMaxFileSize(rule)
+	if maxFileSize == 0 || rule.Ttl != "" || entry == nil || entry.IsDirectory() || len(entry.HardLinkId) > 0 ||
+		entry.Remote != nil || entry.TtlSec > 0 || len(entry.GetChunks()) != 1 {
+		return nil
+	}
+	// packed files are read by ranges of the shared container, so the data can be neither encrypted nor compressed
+	chunk := entry.GetChunks()[0]
+	if chunk.IsChunkManifest || chunk.IsPacked() || chunk.IsDeduplicated() || len(chunk.CipherKey) > 0 || chunk.IsCompressed ||
+		chunk.Offset != 0 || chunk.Size == 0 || int64(chunk.Size) > maxFileSize || chunk.Size != entry.FileSize {
+		return nil
+	}
+	return chunk
+}
+
+// scheduleAdoption remembers a small file not packed by its writer, to move it into a container later
+func (p *FilePacker) scheduleAdoption(entry *Entry, isFromOtherCluster bool) {
+	if isFromOtherCluster || entry == nil || packableChunk(entry, p.filer.FilerConf.MatchStorageRule(string(entry.FullPath))) == nil {
+		return
+	}
+	p.adoptionLock.Lock()
+	defer p.adoptionLock.Unlock()
+	if len(p.adoptions) < packAdoptionQueueLimit {
+		p.adoptions[entry.FullPath] = time.Now().Add(packAdoptionDelay)
+	}
+}
+
+// LoopAdoption moves the scheduled small files into containers, after they are not changed for a while
+func (p *FilePacker) LoopAdoption() {
+	for {
+		time.Sleep(packAdoptionInterval)
+		var due []util.FullPath
+		now := time.Now()
+		p.adoptionLock.Lock()
+		for fullpath, dueTime := range p.adoptions {
+			if now.After(dueTime) {
+				due = append(due, fullpath)
+				delete(p.adoptions, fullpath)
+			}
+		}
+		p.adoptionLock.Unlock()
+		for _, fullpath := range due {
+			if err := p.adopt(context.Background(), fullpath); err != nil {
+				glog.Warningf("pack %s: %v", fullpath, err)
+			}
+		}
+	}
+}
+
+func (p *FilePacker) adopt(ctx context.Context, fullpath util.FullPath) error {
+	entry, err := p.filer.FindEntry(ctx, fullpath)
+	if err == filer_pb.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	rule := p.filer.FilerConf.MatchStorageRule(string(fullpath))
+	chunk := packableChunk(entry, rule)
+	if chunk == nil || time.Since(entry.Attr.Mtime) < packAdoptionDelay {
+		return nil
+	}
+
+	data := make([]byte, chunk.Size)
+	n, err := fetchChunkRange(data, p.filer.MasterClient.GetLookupFileIdFunction(), chunk.GetFileIdString(), nil, false, 0)
+	if err != nil {
+		return fmt.Errorf("read %s: %v", chunk.GetFileIdString(), err)
+	}
+	if n != len(data) {
+		return fmt.Errorf("read %s: %d of %d bytes", chunk.GetFileIdString(), n, len(data))
+	}
+
+	so := &operation.StorageOption{
+		Collection:  util.Nvl(rule.Collection, p.filer.DetectBucket(fullpath)),
+		Replication: rule.Replication,
+		DiskType:    rule.DiskType,
+	}
+	newChunk, err := p.Pack(so, data)
+	if err != nil {
+		return err
+	}
+	newChunk.Offset, newChunk.ModifiedTsNs, newChunk.ETag = chunk.Offset, chunk.ModifiedTsNs, chunk.ETag
+
+	// the entry could have changed while its data is copied, and the unused container is deleted by the compaction
+	latest, err := p.filer.FindEntry(ctx, fullpath)
+	if err != nil {
+		return err
+	}
+	if latestChunk := packableChunk(latest, rule); latestChunk == nil || latestChunk.GetFileIdString() != chunk.GetFileIdString() {
+		return nil
+	}
+	latest.Chunks[0] = newChunk
+	// the chunk of the small file is deleted as the entry no longer references it
+	return p.filer.CreateEntry(ctx, latest, false, false, nil, true, p.filer.MaxFilenameLength)
+}
@@ -0,0 +1,115 @@
+package filer
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackedChunkViews(t *testing.T) {
+
+	chunks := []*filer_pb.FileChunk{
+		{FileId: "1,container", Offset: 0, Size: 100, ModifiedTsNs: 1, PackedOffset: 300, PackedContainerSize: 4096},
+		{FileId: "2,overwrite", Offset: 50, Size: 10, ModifiedTsNs: 2},
+	}
+
+	views := ViewFromChunks(nil, chunks, 0, math.MaxInt64)
+	var got []*ChunkView
+	for x := views.Front(); x != nil; x = x.Next {
+		got = append(got, x.Value)
+	}
+
+	assert.Equal(t, 3, len(got))
+	assert.Equal(t, int64(300), got[0].OffsetInChunk)
+	assert.Equal(t, uint64(50), got[0].ViewSize)
+	assert.Equal(t, uint64(4096), got[0].ChunkSize)
+	assert.False(t, got[0].IsFullChunk())
+	assert.Equal(t, int64(0), got[1].OffsetInChunk)
+	assert.True(t, got[1].IsFullChunk())
+	assert.Equal(t, int64(360), got[2].OffsetInChunk, "resumes in the container after the overwrite")
+	assert.Equal(t, uint64(40), got[2].ViewSize)
+}
+
+func TestCollectPackedRefs(t *testing.T) {
+
+	entry := &Entry{
+		FullPath: "/small/a.txt",
+		Chunks: []*filer_pb.FileChunk{
+			{FileId: "1,container", Size: 100, PackedOffset: 300, PackedContainerSize: 4096},
+			{FileId: "2,normal", Offset: 100, Size: 10},
+		},
+	}
+	refs := collectPackedRefs(entry)
+	assert.Equal(t, 1, len(refs))
+	_, found := refs[packedRef{"1,container", "/small/a.txt", 300}]
+	assert.True(t, found)
+
+	renamed := &Entry{FullPath: "/small/b.txt", Chunks: entry.Chunks}
+	_, found = collectPackedRefs(renamed)[packedRef{"1,container", "/small/a.txt", 300}]
+	assert.False(t, found, "a renamed entry is a new reference")
+
+	record := &filer_pb.PackedContainer{
+		Size: 4096,
+		Refs: []*filer_pb.PackedContainer_Ref{{Path: "/small/a.txt", Offset: 300, Size: 100}},
+	}
+	assert.True(t, needsCompaction(record))
+	record.Refs = append(record.Refs, &filer_pb.PackedContainer_Ref{Path: "/small/c.txt", Offset: 400, Size: 2000})
+	assert.False(t, needsCompaction(record))
+}
+
+func TestUpdatePackedRefs(t *testing.T) {
+
+	f := &Filer{}
+	f.Packer = NewFilePacker(f)
+	chunks := []*filer_pb.FileChunk{{FileId: "1,container", Size: 100, PackedOffset: 300, PackedContainerSize: 4096}}
+	entry := &Entry{FullPath: "/small/a.txt", Chunks: chunks}
+	renamed := &Entry{FullPath: "/small/b.txt", Chunks: chunks}
+
+	// the changes wait for the flush, in the order of the entry changes
+	f.updatePackedRefs(context.Background(), nil, entry)
+	f.updatePackedRefs(context.Background(), entry, renamed)
+	f.updatePackedRefs(context.Background(), renamed, nil)
+
+	changes := f.Packer.takeRefChanges()["1,container"]
+	assert.Equal(t, 3, len(changes))
+	_, found := changes[0].added[packedRef{"1,container", "/small/a.txt", 300}]
+	assert.True(t, found)
+	assert.Equal(t, []packedRef{{"1,container", "/small/a.txt", 300}}, changes[1].removed)
+	_, found = changes[1].added[packedRef{"1,container", "/small/b.txt", 300}]
+	assert.True(t, found, "a rename is one change of the record")
+	assert.Equal(t, []packedRef{{"1,container", "/small/b.txt", 300}}, changes[2].removed)
+	assert.Equal(t, 0, len(f.Packer.takeRefChanges()))
+}
+
+func TestPackableChunk(t *testing.T) {
+
+	rule := &filer_pb.FilerConf_PathConf{PackSmallFiles: true}
+	small := func() *Entry {
+		return &Entry{
+			FullPath: "/small/a.txt",
+			Attr:     Attr{FileSize: 100},
+			Chunks:   []*filer_pb.FileChunk{{FileId: "1,small", Size: 100}},
+		}
+	}
+	assert.NotNil(t, packableChunk(small(), rule), "a small file written by the mount")
+	assert.Nil(t, packableChunk(small(), &filer_pb.FilerConf_PathConf{}), "packing is not configured")
+
+	packed := small()
+	packed.Chunks[0].PackedContainerSize = 4096
+	assert.Nil(t, packableChunk(packed, rule), "already packed")
+
+	encrypted := small()
+	encrypted.Chunks[0].CipherKey = []byte("key")
+	assert.Nil(t, packableChunk(encrypted, rule), "encrypted")
+
+	large := small()
+	large.FileSize, large.Chunks[0].Size = PackDefaultMaxFileSize+1, PackDefaultMaxFileSize+1
+	assert.Nil(t, packableChunk(large, rule), "too large")
+
+	sparse := small()
+	sparse.FileSize = 200
+	assert.Nil(t, packableChunk(sparse, rule), "the chunk is not the whole file")
+}
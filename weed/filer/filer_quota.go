@@ -0,0 +1,589 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	jsonpb "google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	QuotaConfName          = "quota.conf"
+	QuotaExceededErrPrefix = "EDQUOT"
+	quotaUsageKeyPrefix    = "quota.usage."
+	quotaUsageLockName     = "quota.usage"
+	ExtAllocatedSizeKey    = "Seaweed-Allocated-Size" // space preallocated by fallocate, charged to the quota
+
+	quotaUsageFlushInterval = time.Second
+)
+
+// FilerQuota keeps the configured directory, user and group limits.
+// The usage of each limit is tracked incrementally in the filer store kv,
+// so filers sharing one store also share the usage counters. Each filer adds up its usage changes
+// in memory, and applies them to the store every second under a distributed lock.
+type FilerQuota struct {
+	sync.RWMutex
+	limits        map[string]*filer_pb.QuotaConf_Limit
+	calculating   map[string]bool
+	usageLock     sync.Mutex
+	pendingDeltas map[string]*filer_pb.QuotaUsage // not applied to the store yet
+}
+
+func NewFilerQuota() *FilerQuota {
+	return &FilerQuota{
+		limits:        make(map[string]*filer_pb.QuotaConf_Limit),
+		calculating:   make(map[string]bool),
+		pendingDeltas: make(map[string]*filer_pb.QuotaUsage),
+	}
+}
+
+func (q *FilerQuota) addPendingDelta(key string, delta *filer_pb.QuotaUsage) {
+	q.usageLock.Lock()
+	defer q.usageLock.Unlock()
+	pending, found := q.pendingDeltas[key]
+	if !found {
+		pending = &filer_pb.QuotaUsage{}
+		q.pendingDeltas[key] = pending
+	}
+	pending.Bytes += delta.Bytes
+	pending.Inodes += delta.Inodes
+}
+
+func (q *FilerQuota) takePendingDeltas() (deltas map[string]*filer_pb.QuotaUsage) {
+	q.usageLock.Lock()
+	defer q.usageLock.Unlock()
+	deltas = q.pendingDeltas
+	q.pendingDeltas = make(map[string]*filer_pb.QuotaUsage)
+	return
+}
+
+func IsQuotaExceededError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), QuotaExceededErrPrefix+":")
+}
+
+func QuotaLimitKey(limit *filer_pb.QuotaConf_Limit) string {
+	switch limit.Kind {
+	case filer_pb.QuotaConf_USER:
+		return fmt.Sprintf("uid:%d", limit.Id)
+	case filer_pb.QuotaConf_GROUP:
+		return fmt.Sprintf("gid:%d", limit.Id)
+	default:
+		return "dir:" + normalizeQuotaPath(limit.Path)
+	}
+}
+
+func normalizeQuotaPath(p string) string {
+	if p == "" || p == "/" {
+		return "/"
+	}
+	return "/" + strings.Trim(p, "/")
+}
+
+func ParseQuotaConf(data []byte) (*filer_pb.QuotaConf, error) {
+	conf := &filer_pb.QuotaConf{}
+	if len(data) == 0 {
+		return conf, nil
+	}
+	if err := jsonpb.Unmarshal(data, conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func (q *FilerQuota) hasLimits() bool {
+	q.RLock()
+	defer q.RUnlock()
+	return len(q.limits) > 0
+}
+
+func (q *FilerQuota) getLimit(key string) (limit *filer_pb.QuotaConf_Limit, isCalculating bool) {
+	q.RLock()
+	defer q.RUnlock()
+	return q.limits[key], q.calculating[key]
+}
+
+func (q *FilerQuota) setCalculating(key string, isCalculating bool) {
+	q.Lock()
+	defer q.Unlock()
+	if isCalculating {
+		q.calculating[key] = true
+	} else {
+		delete(q.calculating, key)
+	}
+}
+
+// matchLimits returns the keys of the limits that account for this entry
+func (q *FilerQuota) matchLimits(entry *Entry) (keys []string) {
+	q.RLock()
+	defer q.RUnlock()
+	for key, limit := range q.limits {
+		if isEntryUnderQuotaLimit(limit, entry) {
+			keys = append(keys, key)
+		}
+	}
+	return
+}
+
+func isEntryUnderQuotaLimit(limit *filer_pb.QuotaConf_Limit, entry *Entry) bool {
+	switch limit.Kind {
+	case filer_pb.QuotaConf_USER:
+		return entry.Uid == limit.Id
+	case filer_pb.QuotaConf_GROUP:
+		return entry.Gid == limit.Id
+	default:
+		return isPathUnderQuotaDirectory(normalizeQuotaPath(limit.Path), string(entry.FullPath))
+	}
+}
+
+// isPathUnderQuotaDirectory excludes the quota directory itself
+func isPathUnderQuotaDirectory(dir, p string) bool {
+	if dir == "/" {
+		return p != "/"
+	}
+	return strings.HasPrefix(p, dir+"/")
+}
+
+func quotaCharge(entry *Entry) (bytes, inodes int64) {
+	if entry == nil {
+		return 0, 0
+	}
+	if entry.IsDirectory() {
+		return 0, 1
+	}
+	return max(int64(entry.Size()), AllocatedSize(entry.Extended)), 1
+}
+
+// AllocatedSize is the space preallocated beyond the file data, which is charged even before being written
+func AllocatedSize(extended map[string][]byte) int64 {
+	allocated, _ := strconv.ParseInt(string(extended[ExtAllocatedSizeKey]), 10, 64)
+	return allocated
+}
+
+// quotaDeltas computes the usage change of each affected limit when oldEntry is replaced by newEntry
+func (q *FilerQuota) quotaDeltas(oldEntry, newEntry *Entry) map[string]*filer_pb.QuotaUsage {
+	deltas := make(map[string]*filer_pb.QuotaUsage)
+	apply := func(entry *Entry, sign int64) {
+		if entry == nil {
+			return
+		}
+		bytes, inodes := quotaCharge(entry)
+		for _, key := range q.matchLimits(entry) {
+			delta, found := deltas[key]
+			if !found {
+				delta = &filer_pb.QuotaUsage{}
+				deltas[key] = delta
+			}
+			delta.Bytes += sign * bytes
+			delta.Inodes += sign * inodes
+		}
+	}
+	apply(oldEntry, -1)
+	apply(newEntry, 1)
+	for key, delta := range deltas {
+		if delta.Bytes == 0 && delta.Inodes == 0 {
+			delete(deltas, key)
+		}
+	}
+	return deltas
+}
+
+func describeQuotaLimit(limit *filer_pb.QuotaConf_Limit) string {
+	switch limit.Kind {
+	case filer_pb.QuotaConf_USER:
+		return fmt.Sprintf("user %d", limit.Id)
+	case filer_pb.QuotaConf_GROUP:
+		return fmt.Sprintf("group %d", limit.Id)
+	default:
+		return fmt.Sprintf("directory %s", normalizeQuotaPath(limit.Path))
+	}
+}
+
+// getQuotaUsage returns the usage in the store, with the changes of this filer not applied yet
+func (f *Filer) getQuotaUsage(ctx context.Context, key string) (*filer_pb.QuotaUsage, error) {
+	usage, err := f.getStoredQuotaUsage(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	f.Quota.usageLock.Lock()
+	if pending, found := f.Quota.pendingDeltas[key]; found {
+		usage.Bytes = max(usage.Bytes+pending.Bytes, 0)
+		usage.Inodes = max(usage.Inodes+pending.Inodes, 0)
+	}
+	f.Quota.usageLock.Unlock()
+	return usage, nil
+}
+
+func (f *Filer) getStoredQuotaUsage(ctx context.Context, key string) (*filer_pb.QuotaUsage, error) {
+	usage := &filer_pb.QuotaUsage{}
+	data, err := f.Store.KvGet(ctx, []byte(quotaUsageKeyPrefix+key))
+	if err == ErrKvNotFound {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = proto.Unmarshal(data, usage); err != nil {
+		return nil, fmt.Errorf("unmarshal quota usage %s: %v", key, err)
+	}
+	return usage, nil
+}
+
+func (f *Filer) putQuotaUsage(ctx context.Context, key string, usage *filer_pb.QuotaUsage) error {
+	data, err := proto.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	return f.Store.KvPut(ctx, []byte(quotaUsageKeyPrefix+key), data)
+}
+
+// CheckQuota rejects the change if replacing oldEntry by newEntry would exceed any limit.
+// Limits still being calculated are not enforced.
+func (f *Filer) CheckQuota(ctx context.Context, oldEntry, newEntry *Entry) error {
+	if !f.Quota.hasLimits() {
+		return nil
+	}
+	for key, delta := range f.Quota.quotaDeltas(oldEntry, newEntry) {
+		if delta.Bytes <= 0 && delta.Inodes <= 0 {
+			continue
+		}
+		limit, isCalculating := f.Quota.getLimit(key)
+		if limit == nil || isCalculating {
+			continue
+		}
+		usage, err := f.getQuotaUsage(ctx, key)
+		if err != nil {
+			glog.Warningf("read quota usage %s: %v", key, err)
+			continue
+		}
+		if limit.MaxBytes > 0 && delta.Bytes > 0 && usage.Bytes+delta.Bytes > limit.MaxBytes {
+			return fmt.Errorf("%s: %s exceeds quota of %d bytes", QuotaExceededErrPrefix, describeQuotaLimit(limit), limit.MaxBytes)
+		}
+		if limit.MaxInodes > 0 && delta.Inodes > 0 && usage.Inodes+delta.Inodes > limit.MaxInodes {
+			return fmt.Errorf("%s: %s exceeds quota of %d inodes", QuotaExceededErrPrefix, describeQuotaLimit(limit), limit.MaxInodes)
+		}
+	}
+	return nil
+}
+
+// CheckDirectoryQuota rejects new data under a directory whose byte quota is already used up.
+// It is used before assigning file ids, when the final entry size is still unknown.
+func (f *Filer) CheckDirectoryQuota(ctx context.Context, p util.FullPath) error {
+	if !f.Quota.hasLimits() {
+		return nil
+	}
+	f.Quota.RLock()
+	var limits []*filer_pb.QuotaConf_Limit
+	for key, limit := range f.Quota.limits {
+		if limit.Kind == filer_pb.QuotaConf_DIRECTORY && limit.MaxBytes > 0 && !f.Quota.calculating[key] &&
+			isPathUnderQuotaDirectory(normalizeQuotaPath(limit.Path), string(p)) {
+			limits = append(limits, limit)
+		}
+	}
+	f.Quota.RUnlock()
+
+	for _, limit := range limits {
+		usage, err := f.getQuotaUsage(ctx, QuotaLimitKey(limit))
+		if err != nil {
+			glog.Warningf("read quota usage %s: %v", QuotaLimitKey(limit), err)
+			continue
+		}
+		if usage.Bytes >= limit.MaxBytes {
+			return fmt.Errorf("%s: %s exceeds quota of %d bytes", QuotaExceededErrPrefix, describeQuotaLimit(limit), limit.MaxBytes)
+		}
+	}
+	return nil
+}
+
+// CheckRenameQuota rejects moving the entry into a quota directory it would exceed.
+// The renamed entries are created before the old ones are deleted, so CreateEntry does not check them.
+// A directory is charged by the usage tracked for it if it is a quota directory itself,
+// otherwise only by its own inode, as its sub entries are not walked on every rename.
+// They are charged to the new quota directory when they are moved, and its later writes are rejected once exceeded.
+func (f *Filer) CheckRenameQuota(ctx context.Context, entry *Entry, newPath util.FullPath) error {
+	if !f.Quota.hasLimits() {
+		return nil
+	}
+	keys := f.Quota.renameQuotaLimits(entry.FullPath, newPath)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	bytes, inodes := quotaCharge(entry)
+	if entry.IsDirectory() {
+		sourceKey := QuotaLimitKey(&filer_pb.QuotaConf_Limit{Kind: filer_pb.QuotaConf_DIRECTORY, Path: string(entry.FullPath)})
+		if limit, isCalculating := f.Quota.getLimit(sourceKey); limit != nil && !isCalculating {
+			usage, err := f.getQuotaUsage(ctx, sourceKey)
+			if err != nil {
+				return err
+			}
+			bytes += usage.Bytes
+			inodes += usage.Inodes
+		}
+	} else if target, err := f.FindEntry(ctx, newPath); err == nil && !target.IsDirectory() {
+		// the replaced file is released
+		targetBytes, targetInodes := quotaCharge(target)
+		bytes -= targetBytes
+		inodes -= targetInodes
+	}
+
+	for _, key := range keys {
+		limit, _ := f.Quota.getLimit(key)
+		if limit == nil {
+			// removed by a reload of the quota configuration
+			continue
+		}
+		usage, err := f.getQuotaUsage(ctx, key)
+		if err != nil {
+			glog.Warningf("read quota usage %s: %v", key, err)
+			continue
+		}
+		if limit.MaxBytes > 0 && bytes > 0 && usage.Bytes+bytes > limit.MaxBytes {
+			return fmt.Errorf("%s: %s exceeds quota of %d bytes", QuotaExceededErrPrefix, describeQuotaLimit(limit), limit.MaxBytes)
+		}
+		if limit.MaxInodes > 0 && inodes > 0 && usage.Inodes+inodes > limit.MaxInodes {
+			return fmt.Errorf("%s: %s exceeds quota of %d inodes", QuotaExceededErrPrefix, describeQuotaLimit(limit), limit.MaxInodes)
+		}
+	}
+	return nil
+}
+
+// renameQuotaLimits returns the enforced directory limits the renamed entry newly moves into.
+// The user and group limits are not changed by renaming.
+func (q *FilerQuota) renameQuotaLimits(oldPath, newPath util.FullPath) (keys []string) {
+	q.RLock()
+	defer q.RUnlock()
+	for key, limit := range q.limits {
+		if limit.Kind != filer_pb.QuotaConf_DIRECTORY || q.calculating[key] {
+			continue
+		}
+		dir := normalizeQuotaPath(limit.Path)
+		if isPathUnderQuotaDirectory(dir, string(newPath)) && !isPathUnderQuotaDirectory(dir, string(oldPath)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return
+}
+
+func (f *Filer) updateQuotaUsage(ctx context.Context, oldEntry, newEntry *Entry) {
+	if !f.Quota.hasLimits() {
+		return
+	}
+	for key, delta := range f.Quota.quotaDeltas(oldEntry, newEntry) {
+		if _, isCalculating := f.Quota.getLimit(key); isCalculating {
+			continue
+		}
+		f.Quota.addPendingDelta(key, delta)
+	}
+}
+
+// LoopFlushQuotaUsage applies the usage changes of this filer to the store.
+// The read-modify-write of the counters is under a distributed lock shared by the filers.
+func (f *Filer) LoopFlushQuotaUsage() {
+	lockClient := cluster.NewLockClient(f.GrpcDialOption, f.Dlm.Host)
+	for {
+		time.Sleep(quotaUsageFlushInterval)
+		deltas := f.Quota.takePendingDeltas()
+		if len(deltas) == 0 {
+			continue
+		}
+		lock := lockClient.NewShortLivedLock(quotaUsageLockName, string(f.Dlm.Host))
+		f.flushQuotaUsage(context.Background(), deltas)
+		if err := lock.StopShortLivedLock(); err != nil {
+			glog.Warningf("unlock %s: %v", quotaUsageLockName, err)
+		}
+	}
+}
+
+func (f *Filer) flushQuotaUsage(ctx context.Context, deltas map[string]*filer_pb.QuotaUsage) {
+	for key, delta := range deltas {
+		if limit, _ := f.Quota.getLimit(key); limit == nil {
+			continue
+		}
+		usage, err := f.getStoredQuotaUsage(ctx, key)
+		if err == nil {
+			usage.Bytes = max(usage.Bytes+delta.Bytes, 0)
+			usage.Inodes = max(usage.Inodes+delta.Inodes, 0)
+			err = f.putQuotaUsage(ctx, key, usage)
+		}
+		if err != nil {
+			// retried with the next flush
+			glog.Warningf("update quota usage %s: %v", key, err)
+			f.Quota.addPendingDelta(key, delta)
+		}
+	}
+}
+
+// ListQuotaUsage returns the configured limits sorted by key, with their current usage
+func (f *Filer) ListQuotaUsage(ctx context.Context) (items []*filer_pb.GetQuotaUsageResponse_Item, err error) {
+	f.Quota.RLock()
+	var keys []string
+	for key := range f.Quota.limits {
+		keys = append(keys, key)
+	}
+	f.Quota.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		limit, isCalculating := f.Quota.getLimit(key)
+		if limit == nil {
+			continue
+		}
+		usage, err := f.getQuotaUsage(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &filer_pb.GetQuotaUsageResponse_Item{
+			Limit:         limit,
+			Usage:         usage,
+			IsCalculating: isCalculating,
+		})
+	}
+	return
+}
+
+func (f *Filer) LoadQuotaConf() {
+	var content []byte
+	err := util.Retry("loadQuotaConf", func() error {
+		entry, err := f.FindEntry(context.Background(), util.NewFullPath(DirectoryEtcSeaweedFS, QuotaConfName))
+		if err != nil {
+			if err == filer_pb.ErrNotFound {
+				return nil
+			}
+			return err
+		}
+		content = entry.Content
+		if len(content) == 0 {
+			content, err = f.readEntry(entry.GetChunks(), entry.Size())
+		}
+		return err
+	})
+	if err != nil {
+		glog.Errorf("read quota conf: %v", err)
+		return
+	}
+	f.applyQuotaConfContent(content)
+}
+
+func (f *Filer) reloadQuotaConfiguration(entry *filer_pb.Entry) {
+	content := entry.Content
+	if len(content) == 0 {
+		var err error
+		content, err = f.readEntry(entry.GetChunks(), FileSize(entry))
+		if err != nil {
+			glog.Errorf("read quota conf chunks: %v", err)
+			return
+		}
+	}
+	f.applyQuotaConfContent(content)
+}
+
+func (f *Filer) applyQuotaConfContent(content []byte) {
+	conf, err := ParseQuotaConf(content)
+	if err != nil {
+		glog.Errorf("parse %s/%s: %v", DirectoryEtcSeaweedFS, QuotaConfName, err)
+		return
+	}
+	f.applyQuotaConf(conf)
+}
+
+// applyQuotaConf replaces the limits. Usage of a removed limit is discarded,
+// and usage of a new limit is calculated in the background by walking the namespace.
+func (f *Filer) applyQuotaConf(conf *filer_pb.QuotaConf) {
+	ctx := context.Background()
+	limits := make(map[string]*filer_pb.QuotaConf_Limit)
+	for _, limit := range conf.Limits {
+		limits[QuotaLimitKey(limit)] = limit
+	}
+
+	f.Quota.Lock()
+	oldLimits := f.Quota.limits
+	f.Quota.limits = limits
+	f.Quota.Unlock()
+
+	for key := range oldLimits {
+		if _, found := limits[key]; !found {
+			if err := f.Store.KvDelete(ctx, []byte(quotaUsageKeyPrefix+key)); err != nil {
+				glog.Warningf("delete quota usage %s: %v", key, err)
+			}
+		}
+	}
+
+	for key, limit := range limits {
+		if _, found := oldLimits[key]; found {
+			continue
+		}
+		if _, err := f.Store.KvGet(ctx, []byte(quotaUsageKeyPrefix+key)); err != ErrKvNotFound {
+			continue
+		}
+		f.Quota.setCalculating(key, true)
+		go f.calculateQuotaUsage(key, limit)
+	}
+}
+
+func (f *Filer) calculateQuotaUsage(key string, limit *filer_pb.QuotaConf_Limit) {
+	defer f.Quota.setCalculating(key, false)
+
+	ctx := context.Background()
+	root := util.FullPath("/")
+	if limit.Kind == filer_pb.QuotaConf_DIRECTORY {
+		root = util.FullPath(normalizeQuotaPath(limit.Path))
+	}
+
+	glog.V(0).Infof("calculating quota usage of %s", describeQuotaLimit(limit))
+	usage := &filer_pb.QuotaUsage{}
+	err := f.walkQuotaTree(ctx, root, func(entry *Entry) {
+		if isEntryUnderQuotaLimit(limit, entry) {
+			bytes, inodes := quotaCharge(entry)
+			usage.Bytes += bytes
+			usage.Inodes += inodes
+		}
+	})
+	if err != nil {
+		glog.Errorf("calculate quota usage of %s: %v", describeQuotaLimit(limit), err)
+		return
+	}
+
+	lock := cluster.NewLockClient(f.GrpcDialOption, f.Dlm.Host).NewShortLivedLock(quotaUsageLockName, string(f.Dlm.Host))
+	defer lock.StopShortLivedLock()
+	if err = f.putQuotaUsage(ctx, key, usage); err != nil {
+		glog.Errorf("write quota usage %s: %v", key, err)
+		return
+	}
+	glog.V(0).Infof("quota usage of %s: %d bytes %d inodes", describeQuotaLimit(limit), usage.Bytes, usage.Inodes)
+}
+
+func (f *Filer) walkQuotaTree(ctx context.Context, dir util.FullPath, fn func(entry *Entry)) error {
+	if strings.HasPrefix(string(dir), SystemLogDir) {
+		return nil
+	}
+	lastFileName := ""
+	for {
+		entries, hasMore, err := f.ListDirectoryEntries(ctx, dir, lastFileName, false, PaginationSize, "", "", "")
+		if err != nil {
+			return fmt.Errorf("list %s: %v", dir, err)
+		}
+		for _, entry := range entries {
+			lastFileName = entry.Name()
+			fn(entry)
+			if entry.IsDirectory() {
+				if err = f.walkQuotaTree(ctx, entry.FullPath, fn); err != nil {
+					return err
+				}
+			}
+		}
+		if !hasMore {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package filer
+
+import (
+	"os"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaDeltas(t *testing.T) {
+
+	q := NewFilerQuota()
+	for _, limit := range []*filer_pb.QuotaConf_Limit{
+		{Kind: filer_pb.QuotaConf_DIRECTORY, Path: "/home/a/", MaxBytes: 100},
+		{Kind: filer_pb.QuotaConf_DIRECTORY, Path: "/home", MaxInodes: 10},
+		{Kind: filer_pb.QuotaConf_USER, Id: 1000, MaxBytes: 100},
+		{Kind: filer_pb.QuotaConf_GROUP, Id: 100, MaxBytes: 100},
+	} {
+		q.limits[QuotaLimitKey(limit)] = limit
+	}
+
+	file := &Entry{FullPath: "/home/a/x", Attr: Attr{Uid: 1000, Gid: 100, FileSize: 30}}
+	deltas := q.quotaDeltas(nil, file)
+	assert.Equal(t, 4, len(deltas))
+	assert.Equal(t, int64(30), deltas["dir:/home/a"].Bytes)
+	assert.Equal(t, int64(1), deltas["dir:/home"].Inodes)
+	assert.Equal(t, int64(30), deltas["uid:1000"].Bytes)
+	assert.Equal(t, int64(30), deltas["gid:100"].Bytes)
+
+	grown := &Entry{FullPath: "/home/a/x", Attr: Attr{Uid: 1000, Gid: 100, FileSize: 50}}
+	deltas = q.quotaDeltas(file, grown)
+	assert.Equal(t, int64(20), deltas["dir:/home/a"].Bytes)
+	assert.Equal(t, int64(0), deltas["dir:/home/a"].Inodes)
+
+	chowned := &Entry{FullPath: "/home/a/x", Attr: Attr{Uid: 1001, Gid: 100, FileSize: 50}}
+	deltas = q.quotaDeltas(grown, chowned)
+	assert.Equal(t, 1, len(deltas))
+	assert.Equal(t, int64(-50), deltas["uid:1000"].Bytes)
+	assert.Equal(t, int64(-1), deltas["uid:1000"].Inodes)
+
+	dir := &Entry{FullPath: "/home/a", Attr: Attr{Mode: os.ModeDir, Uid: 0, Gid: 0}}
+	deltas = q.quotaDeltas(nil, dir)
+	assert.Equal(t, 1, len(deltas), "a quota directory does not account for itself")
+	assert.Equal(t, int64(1), deltas["dir:/home"].Inodes)
+
+	sibling := &Entry{FullPath: "/home/ab", Attr: Attr{Mode: os.ModeDir}}
+	deltas = q.quotaDeltas(nil, sibling)
+	_, found := deltas["dir:/home/a"]
+	assert.False(t, found, "/home/ab is not under /home/a")
+}
+
+func TestIsPathUnderQuotaDirectory(t *testing.T) {
+	assert.True(t, isPathUnderQuotaDirectory("/", "/a"))
+	assert.False(t, isPathUnderQuotaDirectory("/", "/"))
+	assert.True(t, isPathUnderQuotaDirectory("/a", "/a/b"))
+	assert.False(t, isPathUnderQuotaDirectory("/a", "/a"))
+	assert.False(t, isPathUnderQuotaDirectory("/a", "/ab"))
+}
+
+func TestRenameQuotaLimits(t *testing.T) {
+
+	q := NewFilerQuota()
+	for _, limit := range []*filer_pb.QuotaConf_Limit{
+		{Kind: filer_pb.QuotaConf_DIRECTORY, Path: "/home/a", MaxBytes: 100},
+		{Kind: filer_pb.QuotaConf_DIRECTORY, Path: "/home", MaxInodes: 10},
+		{Kind: filer_pb.QuotaConf_USER, Id: 1000, MaxBytes: 100},
+	} {
+		q.limits[QuotaLimitKey(limit)] = limit
+	}
+
+	assert.Equal(t, []string{"dir:/home", "dir:/home/a"}, q.renameQuotaLimits("/tmp/x", "/home/a/x"))
+	assert.Equal(t, []string{"dir:/home/a"}, q.renameQuotaLimits("/home/b/x", "/home/a/x"))
+	assert.Empty(t, q.renameQuotaLimits("/home/a/x", "/home/a/y"))
+	assert.Empty(t, q.renameQuotaLimits("/home/a/x", "/tmp/x"), "moving out only releases usage")
+
+	q.pendingDeltas["dir:/home/a"] = &filer_pb.QuotaUsage{Bytes: 10}
+	q.addPendingDelta("dir:/home/a", &filer_pb.QuotaUsage{Bytes: -4, Inodes: 1})
+	deltas := q.takePendingDeltas()
+	assert.Equal(t, int64(6), deltas["dir:/home/a"].Bytes)
+	assert.Equal(t, int64(1), deltas["dir:/home/a"].Inodes)
+	assert.Empty(t, q.pendingDeltas)
+}
@@ -0,0 +1,80 @@
+package filer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+// recordLocks serializes the read-modify-write of the records in the filer store kv, across the filers sharing the store.
+// The records are spread by key over a fixed number of distributed locks, so the changes of unrelated records
+// do not wait for each other. A distributed lock can not be taken twice by the same owner,
+// so a local mutex per lock serializes the goroutines of this filer.
+type recordLocks struct {
+	filer  *Filer
+	name   string
+	locals []sync.Mutex
+}
+
+func newRecordLocks(f *Filer, name string, count int) *recordLocks {
+	return &recordLocks{
+		filer:  f,
+		name:   name,
+		locals: make([]sync.Mutex, count),
+	}
+}
+
+func (l *recordLocks) indexOf(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(l.locals)))
+}
+
+// lock takes the locks of the keys in ascending order, so the lockers of overlapping keys do not deadlock
+func (l *recordLocks) lock(keys ...string) (unlock func()) {
+	var indexes []int
+	seen := make(map[int]bool)
+	for _, key := range keys {
+		if index := l.indexOf(key); !seen[index] {
+			seen[index] = true
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+
+	lockClient := cluster.NewLockClient(l.filer.GrpcDialOption, l.filer.Dlm.Host)
+	var locks []*cluster.LiveLock
+	for _, index := range indexes {
+		l.locals[index].Lock()
+		locks = append(locks, lockClient.NewShortLivedLock(l.lockName(index), string(l.filer.Dlm.Host)))
+	}
+	return func() {
+		for i := len(indexes) - 1; i >= 0; i-- {
+			if err := locks[i].StopShortLivedLock(); err != nil {
+				glog.Warningf("unlock %s: %v", l.lockName(indexes[i]), err)
+			}
+			l.locals[indexes[i]].Unlock()
+		}
+	}
+}
+
+func (l *recordLocks) lockName(index int) string {
+	return fmt.Sprintf("%s.%d", l.name, index)
+}
+
+// groupByLock splits the keys by the lock guarding them, to change a few records at a time
+func (l *recordLocks) groupByLock(keys []string) (groups [][]string) {
+	byIndex := make(map[int][]string)
+	for _, key := range keys {
+		index := l.indexOf(key)
+		byIndex[index] = append(byIndex[index], key)
+	}
+	for _, group := range byIndex {
+		groups = append(groups, group)
+	}
+	return
+}
@@ -0,0 +1,172 @@
+package filer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NFSv4 ACLs are kept in the XDR layout of the system.nfs4_acl xattr,
+// as used by nfs4_getfacl and nfs4_setfacl.
+const (
+	Nfs4AceAccessAllowed = 0
+	Nfs4AceAccessDenied  = 1
+
+	Nfs4AceFileInherit        = 0x01
+	Nfs4AceDirectoryInherit   = 0x02
+	Nfs4AceNoPropagateInherit = 0x04
+	Nfs4AceInheritOnly        = 0x08
+	Nfs4AceIdentifierGroup    = 0x40
+	Nfs4AceInherited          = 0x80
+
+	Nfs4AceReadData    = 0x01 // list directory
+	Nfs4AceWriteData   = 0x02 // add file
+	Nfs4AceAppendData  = 0x04 // add subdirectory
+	Nfs4AceExecute     = 0x20
+	Nfs4AceDeleteChild = 0x40
+
+	Nfs4AceOwner    = "OWNER@"
+	Nfs4AceGroup    = "GROUP@"
+	Nfs4AceEveryone = "EVERYONE@"
+)
+
+type Nfs4Ace struct {
+	Type       uint32
+	Flag       uint32
+	AccessMask uint32
+	Who        string
+}
+
+type Nfs4Acl []Nfs4Ace
+
+func ParseNfs4Acl(data []byte) (Nfs4Acl, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("invalid nfs4 acl size %d", len(data))
+	}
+	count := binary.BigEndian.Uint32(data)
+	offset := 4
+	var acl Nfs4Acl
+	for i := uint32(0); i < count; i++ {
+		if offset+16 > len(data) {
+			return nil, fmt.Errorf("truncated nfs4 acl entry %d", i)
+		}
+		ace := Nfs4Ace{
+			Type:       binary.BigEndian.Uint32(data[offset:]),
+			Flag:       binary.BigEndian.Uint32(data[offset+4:]),
+			AccessMask: binary.BigEndian.Uint32(data[offset+8:]),
+		}
+		whoLen := int(binary.BigEndian.Uint32(data[offset+12:]))
+		offset += 16
+		if whoLen > len(data)-offset {
+			return nil, fmt.Errorf("truncated nfs4 acl who %d", i)
+		}
+		ace.Who = string(data[offset : offset+whoLen])
+		offset += (whoLen + 3) &^ 3
+		if ace.Type != Nfs4AceAccessAllowed && ace.Type != Nfs4AceAccessDenied {
+			return nil, fmt.Errorf("unsupported nfs4 ace type %d", ace.Type)
+		}
+		acl = append(acl, ace)
+	}
+	return acl, nil
+}
+
+func (acl Nfs4Acl) Bytes() []byte {
+	size := 4
+	for _, ace := range acl {
+		size += 16 + (len(ace.Who)+3)&^3
+	}
+	data := make([]byte, size)
+	binary.BigEndian.PutUint32(data, uint32(len(acl)))
+	offset := 4
+	for _, ace := range acl {
+		binary.BigEndian.PutUint32(data[offset:], ace.Type)
+		binary.BigEndian.PutUint32(data[offset+4:], ace.Flag)
+		binary.BigEndian.PutUint32(data[offset+8:], ace.AccessMask)
+		binary.BigEndian.PutUint32(data[offset+12:], uint32(len(ace.Who)))
+		offset += 16
+		copy(data[offset:], ace.Who)
+		offset += (len(ace.Who) + 3) &^ 3
+	}
+	return data
+}
+
+func (ace *Nfs4Ace) matches(ownerUid, ownerGid uint32, identity *AclIdentity) bool {
+	switch ace.Who {
+	case Nfs4AceOwner:
+		return identity.Uid == ownerUid
+	case Nfs4AceGroup:
+		return identity.InGroup(ownerGid)
+	case Nfs4AceEveryone:
+		return true
+	}
+	// numeric ids, optionally followed by @domain
+	idString, _, _ := strings.Cut(ace.Who, "@")
+	id, err := strconv.ParseUint(idString, 10, 32)
+	if err != nil {
+		return false
+	}
+	if ace.Flag&Nfs4AceIdentifierGroup != 0 {
+		return identity.InGroup(uint32(id))
+	}
+	return identity.Uid == uint32(id)
+}
+
+// Allows walks the aces in order until every wanted bit is allowed, or any is denied
+func (acl Nfs4Acl) Allows(ownerUid, ownerGid uint32, identity *AclIdentity, want uint32) bool {
+	remaining := nfs4AccessMask(want)
+	for i := range acl {
+		ace := &acl[i]
+		if ace.Flag&Nfs4AceInheritOnly != 0 || !ace.matches(ownerUid, ownerGid, identity) {
+			continue
+		}
+		if ace.AccessMask&remaining == 0 {
+			continue
+		}
+		if ace.Type == Nfs4AceAccessDenied {
+			return false
+		}
+		remaining &^= ace.AccessMask
+		if remaining == 0 {
+			return true
+		}
+	}
+	return remaining == 0
+}
+
+func nfs4AccessMask(want uint32) (mask uint32) {
+	if want&AccessRead != 0 {
+		mask |= Nfs4AceReadData
+	}
+	if want&AccessWrite != 0 {
+		mask |= Nfs4AceWriteData
+	}
+	if want&AccessExecute != 0 {
+		mask |= Nfs4AceExecute
+	}
+	return
+}
+
+// inherit returns the aces a new entry gets from its parent directory
+func (acl Nfs4Acl) inherit(isDirectory bool) (inherited Nfs4Acl) {
+	for _, ace := range acl {
+		isFileInherit := ace.Flag&Nfs4AceFileInherit != 0
+		isDirectoryInherit := ace.Flag&Nfs4AceDirectoryInherit != 0
+		noPropagate := ace.Flag&Nfs4AceNoPropagateInherit != 0
+		switch {
+		case !isDirectory && isFileInherit:
+			ace.Flag &= Nfs4AceIdentifierGroup
+		case isDirectory && isDirectoryInherit && noPropagate:
+			ace.Flag &= Nfs4AceIdentifierGroup
+		case isDirectory && isDirectoryInherit:
+			ace.Flag &^= Nfs4AceInheritOnly
+		case isDirectory && isFileInherit && !noPropagate:
+			ace.Flag |= Nfs4AceInheritOnly
+		default:
+			continue
+		}
+		ace.Flag |= Nfs4AceInherited
+		inherited = append(inherited, ace)
+	}
+	return
+}
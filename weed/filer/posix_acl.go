@@ -0,0 +1,218 @@
+package filer
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// POSIX ACLs are kept in the same binary layout as the Linux
+// system.posix_acl_access and system.posix_acl_default xattrs:
+// a little endian version header followed by (tag, perm, id) entries.
+const (
+	PosixAclXattrVersion = 2
+
+	PosixAclUserObj  = 0x01
+	PosixAclUser     = 0x02
+	PosixAclGroupObj = 0x04
+	PosixAclGroup    = 0x08
+	PosixAclMask     = 0x10
+	PosixAclOther    = 0x20
+
+	posixAclHeaderSize = 4
+	posixAclEntrySize  = 8
+)
+
+type PosixAclEntry struct {
+	Tag  uint16
+	Perm uint16
+	Id   uint32
+}
+
+type PosixAcl []PosixAclEntry
+
+func ParsePosixAcl(data []byte) (PosixAcl, error) {
+	if len(data) < posixAclHeaderSize || (len(data)-posixAclHeaderSize)%posixAclEntrySize != 0 {
+		return nil, fmt.Errorf("invalid posix acl size %d", len(data))
+	}
+	if version := binary.LittleEndian.Uint32(data); version != PosixAclXattrVersion {
+		return nil, fmt.Errorf("unsupported posix acl version %d", version)
+	}
+	var acl PosixAcl
+	for i := posixAclHeaderSize; i < len(data); i += posixAclEntrySize {
+		acl = append(acl, PosixAclEntry{
+			Tag:  binary.LittleEndian.Uint16(data[i:]),
+			Perm: binary.LittleEndian.Uint16(data[i+2:]),
+			Id:   binary.LittleEndian.Uint32(data[i+4:]),
+		})
+	}
+	return acl, acl.validate()
+}
+
+func (acl PosixAcl) Bytes() []byte {
+	data := make([]byte, posixAclHeaderSize+len(acl)*posixAclEntrySize)
+	binary.LittleEndian.PutUint32(data, PosixAclXattrVersion)
+	for i, e := range acl {
+		offset := posixAclHeaderSize + i*posixAclEntrySize
+		binary.LittleEndian.PutUint16(data[offset:], e.Tag)
+		binary.LittleEndian.PutUint16(data[offset+2:], e.Perm)
+		binary.LittleEndian.PutUint32(data[offset+4:], e.Id)
+	}
+	return data
+}
+
+func (acl PosixAcl) validate() error {
+	var userObj, groupObj, other, mask, named int
+	for _, e := range acl {
+		if e.Perm&^7 != 0 {
+			return fmt.Errorf("invalid posix acl permission %o", e.Perm)
+		}
+		switch e.Tag {
+		case PosixAclUserObj:
+			userObj++
+		case PosixAclGroupObj:
+			groupObj++
+		case PosixAclOther:
+			other++
+		case PosixAclMask:
+			mask++
+		case PosixAclUser, PosixAclGroup:
+			named++
+		default:
+			return fmt.Errorf("invalid posix acl tag %x", e.Tag)
+		}
+	}
+	if userObj != 1 || groupObj != 1 || other != 1 || mask > 1 {
+		return fmt.Errorf("posix acl needs exactly one owner, group and other entry")
+	}
+	if named > 0 && mask == 0 {
+		return fmt.Errorf("posix acl with named entries needs a mask entry")
+	}
+	return nil
+}
+
+func (acl PosixAcl) find(tag uint16) *PosixAclEntry {
+	for i := range acl {
+		if acl[i].Tag == tag {
+			return &acl[i]
+		}
+	}
+	return nil
+}
+
+// Mode returns the permission bits represented by the acl.
+// With a mask entry, the group bits reflect the mask.
+func (acl PosixAcl) Mode() (mode uint32) {
+	for _, e := range acl {
+		switch e.Tag {
+		case PosixAclUserObj:
+			mode |= uint32(e.Perm) << 6
+		case PosixAclOther:
+			mode |= uint32(e.Perm)
+		}
+	}
+	if e := acl.find(PosixAclMask); e != nil {
+		mode |= uint32(e.Perm) << 3
+	} else if e = acl.find(PosixAclGroupObj); e != nil {
+		mode |= uint32(e.Perm) << 3
+	}
+	return
+}
+
+// Chmod returns a copy with the owner, group class and other entries set from the permission bits,
+// which is what chmod does to a file with an access acl.
+func (acl PosixAcl) Chmod(mode uint32) PosixAcl {
+	updated := make(PosixAcl, len(acl))
+	copy(updated, acl)
+	hasMask := acl.find(PosixAclMask) != nil
+	for i := range updated {
+		switch updated[i].Tag {
+		case PosixAclUserObj:
+			updated[i].Perm = uint16(mode>>6) & 7
+		case PosixAclMask:
+			updated[i].Perm = uint16(mode>>3) & 7
+		case PosixAclGroupObj:
+			if !hasMask {
+				updated[i].Perm = uint16(mode>>3) & 7
+			}
+		case PosixAclOther:
+			updated[i].Perm = uint16(mode) & 7
+		}
+	}
+	return updated
+}
+
+// IsMinimal is true if the acl only has the owner, group and other entries, which the mode bits can represent
+func (acl PosixAcl) IsMinimal() bool {
+	return len(acl) == 3
+}
+
+// Allows implements the POSIX.1e access check algorithm
+func (acl PosixAcl) Allows(ownerUid, ownerGid uint32, identity *AclIdentity, want uint32) bool {
+	want &= 7
+	maskPerm := uint16(7)
+	if e := acl.find(PosixAclMask); e != nil {
+		maskPerm = e.Perm
+	}
+
+	if identity.Uid == ownerUid {
+		if e := acl.find(PosixAclUserObj); e != nil {
+			return uint32(e.Perm)&want == want
+		}
+		return false
+	}
+
+	for _, e := range acl {
+		if e.Tag == PosixAclUser && e.Id == identity.Uid {
+			return uint32(e.Perm&maskPerm)&want == want
+		}
+	}
+
+	groupMatched := false
+	for _, e := range acl {
+		var matched bool
+		switch e.Tag {
+		case PosixAclGroupObj:
+			matched = identity.InGroup(ownerGid)
+		case PosixAclGroup:
+			matched = identity.InGroup(e.Id)
+		}
+		if !matched {
+			continue
+		}
+		groupMatched = true
+		if uint32(e.Perm&maskPerm)&want == want {
+			return true
+		}
+	}
+	if groupMatched {
+		return false
+	}
+
+	if e := acl.find(PosixAclOther); e != nil {
+		return uint32(e.Perm)&want == want
+	}
+	return false
+}
+
+// inheritAccessAcl derives the access acl of a new entry from the parent default acl.
+// The create mode limits the owner, group class and other entries.
+func (acl PosixAcl) inheritAccessAcl(mode uint32) (PosixAcl, uint32) {
+	inherited := make(PosixAcl, len(acl))
+	copy(inherited, acl)
+	hasMask := acl.find(PosixAclMask) != nil
+	for i := range inherited {
+		switch inherited[i].Tag {
+		case PosixAclUserObj:
+			inherited[i].Perm &= uint16(mode>>6) & 7
+		case PosixAclMask:
+			inherited[i].Perm &= uint16(mode>>3) & 7
+		case PosixAclGroupObj:
+			if !hasMask {
+				inherited[i].Perm &= uint16(mode>>3) & 7
+			}
+		case PosixAclOther:
+			inherited[i].Perm &= uint16(mode) & 7
+		}
+	}
+	return inherited, (mode &^ 0777) | inherited.Mode()
+}
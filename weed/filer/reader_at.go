@@ -10,6 +10,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
 	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/util/chunk_cache"
 	"github.com/seaweedfs/seaweedfs/weed/wdclient"
 )
 
@@ -89,14 +90,24 @@ func LookupFn(filerClient filer_pb.FilerClient) wdclient.LookupFileIdFunctionTyp
 
 func NewChunkReaderAtFromClient(readerCache *ReaderCache, chunkViews *IntervalList[*ChunkView], fileSize int64) *ChunkReadAt {
 
+	return newChunkReaderAt(readerCache, chunkViews, fileSize, NewReaderPattern())
+}
+
+// newChunkReaderAt shares the reader pattern, so the sections of one file have the same read ahead
+func newChunkReaderAt(readerCache *ReaderCache, chunkViews *IntervalList[*ChunkView], fileSize int64, readerPattern *ReaderPattern) *ChunkReadAt {
+
 	return &ChunkReadAt{
 		chunkViews:    chunkViews,
 		fileSize:      fileSize,
 		readerCache:   readerCache,
-		readerPattern: NewReaderPattern(),
+		readerPattern: readerPattern,
 	}
 }
 
+func (c *ChunkReadAt) Stats() ReaderStats {
+	return c.readerPattern.Stats()
+}
+
 func (c *ChunkReadAt) Close() error {
 	c.readerCache.destroy()
 	return nil
@@ -194,25 +205,69 @@ func (c *ChunkReadAt) readChunkSliceAt(buffer []byte, chunkView *ChunkView, next
 	if c.readerPattern.IsRandomMode() {
 		n, err := c.readerCache.chunkCache.ReadChunkAt(buffer, chunkView.FileId, offset)
 		if n > 0 {
+			c.readerPattern.recordChunkRead(true)
 			return n, err
 		}
+		c.readerPattern.recordChunkRead(false)
 		return fetchChunkRange(buffer, c.readerCache.lookupFileIdFn, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, int64(offset))
 	}
 
 	shouldCache := (uint64(chunkView.ViewOffset) + chunkView.ChunkSize) <= c.readerCache.chunkCache.GetMaxFilePartSizeInCache()
-	n, err = c.readerCache.ReadChunkAt(buffer, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, int64(offset), int(chunkView.ChunkSize), shouldCache)
+	n, isHit, err := c.readerCache.readChunkAt(buffer, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, int64(offset), int(chunkView.ChunkSize), shouldCache)
+	c.readerPattern.recordChunkRead(isHit)
+	c.readAhead(chunkView, nextChunkViews)
+	return
+}
+
+// LocateAt finds the range in one chunk of the chunk cache, to be served without copying.
+// The range is not read at all if not found.
+func (c *ChunkReadAt) LocateAt(offset int64, size int) (ref chunk_cache.CachedChunkRef, ts int64, found bool) {
+
+	zeroCopyCache, ok := c.readerCache.chunkCache.(chunk_cache.ZeroCopyChunkCache)
+	if !ok || size <= 0 || offset+int64(size) > c.fileSize {
+		return
+	}
+
+	c.chunkViews.Lock.RLock()
+	defer c.chunkViews.Lock.RUnlock()
+
+	for x := c.chunkViews.Front(); x != nil; x = x.Next {
+		chunkView := x.Value
+		if chunkView.ViewOffset+int64(chunkView.ViewSize) <= offset {
+			continue
+		}
+		if offset < chunkView.ViewOffset || offset+int64(size) > chunkView.ViewOffset+int64(chunkView.ViewSize) {
+			// a hole or more than one chunk
+			return
+		}
+		bufferOffset := offset - chunkView.ViewOffset + chunkView.OffsetInChunk
+		if ref, found = zeroCopyCache.LocateChunkAt(chunkView.FileId, uint64(bufferOffset), size); !found {
+			return
+		}
+		c.readerPattern.MonitorReadAt(offset, size)
+		c.readerPattern.recordChunkRead(true)
+		if !c.readerPattern.IsRandomMode() {
+			c.readAhead(chunkView, x.Next)
+		}
+		return ref, chunkView.ModifiedTsNs, true
+	}
+	return
+}
+
+func (c *ChunkReadAt) readAhead(chunkView *ChunkView, nextChunkViews *Interval[*ChunkView]) {
 	if c.lastChunkFid != chunkView.FileId {
 		if chunkView.OffsetInChunk == 0 { // start of a new chunk
 			if c.lastChunkFid != "" {
 				c.readerCache.UnCache(c.lastChunkFid)
 			}
 			if nextChunkViews != nil {
-				c.readerCache.MaybeCache(nextChunkViews) // just read the next chunk if at the very beginning
+				// read ahead the next chunks, as many as the sequential throughput needs
+				window := c.readerPattern.ReadAheadWindow(int64(chunkView.ChunkSize))
+				c.readerPattern.recordPrefetch(c.readerCache.MaybeCache(nextChunkViews, window))
 			}
 		}
 	}
 	c.lastChunkFid = chunkView.FileId
-	return
 }
 
 func zero(buffer []byte, start, length int64) int {
@@ -21,6 +21,39 @@ type ReaderCache struct {
 	limit       int
 }
 
+// DefaultReadAheadMemoryMB caps the memory of the read ahead chunks of all the readers in one process
+const DefaultReadAheadMemoryMB = 256
+
+// readAheadMemory is shared by all the reader caches, so many open files can not read ahead without bounds.
+// A prefetch is skipped when the memory is used up, and the memory is returned when the chunk is uncached.
+var readAheadMemory = &memoryBudget{limit: DefaultReadAheadMemoryMB * 1024 * 1024}
+
+type memoryBudget struct {
+	limit int64
+	used  int64
+}
+
+// SetReadAheadMemoryLimit changes the memory limit of the read ahead chunks, in MB
+func SetReadAheadMemoryLimit(limitMB int64) {
+	atomic.StoreInt64(&readAheadMemory.limit, limitMB*1024*1024)
+}
+
+func (b *memoryBudget) tryAcquire(size int64) bool {
+	for {
+		used := atomic.LoadInt64(&b.used)
+		if used+size > atomic.LoadInt64(&b.limit) {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&b.used, used, used+size) {
+			return true
+		}
+	}
+}
+
+func (b *memoryBudget) release(size int64) {
+	atomic.AddInt64(&b.used, -size)
+}
+
 type SingleChunkCacher struct {
 	completedTimeNew int64
 	sync.Mutex
@@ -32,6 +65,7 @@ type SingleChunkCacher struct {
 	isGzipped      bool
 	chunkSize      int
 	shouldCache    bool
+	readAheadSize  int64 // the read ahead memory held by this prefetched chunk
 	wg             sync.WaitGroup
 	cacheStartedCh chan struct{}
 }
@@ -45,7 +79,8 @@ func NewReaderCache(limit int, chunkCache chunk_cache.ChunkCache, lookupFileIdFn
 	}
 }
 
-func (rc *ReaderCache) MaybeCache(chunkViews *Interval[*ChunkView]) {
+// MaybeCache prefetches up to count chunks from the chunk views, and returns the number of started downloads
+func (rc *ReaderCache) MaybeCache(chunkViews *Interval[*ChunkView], count int) (started int) {
 	if rc.lookupFileIdFn == nil {
 		return
 	}
@@ -57,7 +92,7 @@ func (rc *ReaderCache) MaybeCache(chunkViews *Interval[*ChunkView]) {
 		return
 	}
 
-	for x := chunkViews; x != nil; x = x.Next {
+	for x := chunkViews; x != nil && count > 0; x, count = x.Next, count-1 {
 		chunkView := x.Value
 		if _, found := rc.downloaders[chunkView.FileId]; found {
 			continue
@@ -71,14 +106,20 @@ func (rc *ReaderCache) MaybeCache(chunkViews *Interval[*ChunkView]) {
 			// abort when slots are filled
 			return
 		}
+		if !readAheadMemory.tryAcquire(int64(chunkView.ChunkSize)) {
+			// abort when the read ahead memory of all the readers is used up
+			return
+		}
 
 		// glog.V(4).Infof("prefetch %s offset %d", chunkView.FileId, chunkView.ViewOffset)
 		// cache this chunk if not yet
 		shouldCache := (uint64(chunkView.ViewOffset) + chunkView.ChunkSize) <= rc.chunkCache.GetMaxFilePartSizeInCache()
 		cacher := newSingleChunkCacher(rc, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, int(chunkView.ChunkSize), shouldCache)
+		cacher.readAheadSize = int64(chunkView.ChunkSize)
 		go cacher.startCaching()
 		<-cacher.cacheStartedCh
 		rc.downloaders[chunkView.FileId] = cacher
+		started++
 
 	}
 
@@ -86,19 +127,25 @@ func (rc *ReaderCache) MaybeCache(chunkViews *Interval[*ChunkView]) {
 }
 
 func (rc *ReaderCache) ReadChunkAt(buffer []byte, fileId string, cipherKey []byte, isGzipped bool, offset int64, chunkSize int, shouldCache bool) (int, error) {
+	n, _, err := rc.readChunkAt(buffer, fileId, cipherKey, isGzipped, offset, chunkSize, shouldCache)
+	return n, err
+}
+
+// readChunkAt also tells whether the chunk was already downloading or cached
+func (rc *ReaderCache) readChunkAt(buffer []byte, fileId string, cipherKey []byte, isGzipped bool, offset int64, chunkSize int, shouldCache bool) (int, bool, error) {
 	rc.Lock()
 
 	if cacher, found := rc.downloaders[fileId]; found {
 		if n, err := cacher.readChunkAt(buffer, offset); n != 0 && err == nil {
 			rc.Unlock()
-			return n, err
+			return n, true, err
 		}
 	}
-	if shouldCache || rc.lookupFileIdFn == nil {
+	if shouldCache || rc.lookupFileIdFn == nil || rc.chunkCache.IsInCache(fileId, true) {
 		n, err := rc.chunkCache.ReadChunkAt(buffer, fileId, uint64(offset))
 		if n > 0 {
 			rc.Unlock()
-			return n, err
+			return n, true, err
 		}
 	}
 
@@ -126,7 +173,8 @@ func (rc *ReaderCache) ReadChunkAt(buffer []byte, fileId string, cipherKey []byt
 	rc.downloaders[fileId] = cacher
 	rc.Unlock()
 
-	return cacher.readChunkAt(buffer, offset)
+	n, err := cacher.readChunkAt(buffer, offset)
+	return n, false, err
 }
 
 func (rc *ReaderCache) UnCache(fileId string) {
@@ -146,6 +194,7 @@ func (rc *ReaderCache) destroy() {
 	for _, downloader := range rc.downloaders {
 		downloader.destroy()
 	}
+	rc.downloaders = make(map[string]*SingleChunkCacher)
 
 }
 
@@ -198,6 +247,10 @@ func (s *SingleChunkCacher) destroy() {
 	s.Lock()
 	defer s.Unlock()
 
+	if s.readAheadSize > 0 {
+		readAheadMemory.release(s.readAheadSize)
+		s.readAheadSize = 0
+	}
 	if s.data != nil {
 		mem.Free(s.data)
 		s.data = nil
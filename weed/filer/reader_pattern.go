@@ -1,23 +1,56 @@
 package filer
 
 import (
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type ReaderPattern struct {
 	isSequentialCounter int64
 	lastReadStopOffset  int64
+
+	// adaptive read ahead, sized by the observed sequential throughput
+	readAheadLock   sync.Mutex
+	sampleStartTime time.Time
+	sampleBytes     int64
+	throughput      int64 // bytes per second
+	readAheadWindow int
+
+	stats ReaderStats
+}
+
+// ReaderStats are the counters of one reader, for the introspection of the mount
+type ReaderStats struct {
+	Reads            int64
+	SequentialReads  int64
+	BytesRead        int64
+	ChunkHits        int64 // chunk reads served by the prefetched or cached chunks
+	ChunkMisses      int64 // chunk reads waiting for the volume servers
+	PrefetchedChunks int64
+	ReadAheadWindow  int   // the number of chunks read ahead
+	Throughput       int64 // the sequential read throughput, in bytes per second
+	IsRandomMode     bool
 }
 
 const ModeChangeLimit = 3
 
-// For streaming read: only cache the first chunk
+const (
+	// read ahead enough chunks to serve the sequential reads for this long
+	ReadAheadHorizon = 2 * time.Second
+	// MaxReadAheadChunks is kept below the downloader limit of the ReaderCache
+	MaxReadAheadChunks    = 16
+	readAheadSampleWindow = 250 * time.Millisecond
+)
+
+// For streaming read: cache the next chunks, as many as the read ahead window
 // For random read: only fetch the requested range, instead of the whole chunk
 
 func NewReaderPattern() *ReaderPattern {
 	return &ReaderPattern{
 		isSequentialCounter: 0,
 		lastReadStopOffset:  0,
+		readAheadWindow:     1,
 	}
 }
 
@@ -25,17 +58,106 @@ func (rp *ReaderPattern) MonitorReadAt(offset int64, size int) {
 	lastOffset := atomic.SwapInt64(&rp.lastReadStopOffset, offset+int64(size))
 	counter := atomic.LoadInt64(&rp.isSequentialCounter)
 
+	atomic.AddInt64(&rp.stats.Reads, 1)
+	atomic.AddInt64(&rp.stats.BytesRead, int64(size))
+
 	if lastOffset == offset {
 		if counter < ModeChangeLimit {
 			atomic.AddInt64(&rp.isSequentialCounter, 1)
 		}
+		atomic.AddInt64(&rp.stats.SequentialReads, 1)
+		rp.sampleThroughput(int64(size))
 	} else {
 		if counter > -ModeChangeLimit {
 			atomic.AddInt64(&rp.isSequentialCounter, -1)
 		}
+		if rp.IsRandomMode() {
+			rp.resetThroughput()
+		}
 	}
 }
 
 func (rp *ReaderPattern) IsRandomMode() bool {
 	return atomic.LoadInt64(&rp.isSequentialCounter) < 0
 }
+
+// sampleThroughput averages the sequential read rate over the sample windows
+func (rp *ReaderPattern) sampleThroughput(size int64) {
+	rp.readAheadLock.Lock()
+	defer rp.readAheadLock.Unlock()
+
+	now := time.Now()
+	if rp.sampleStartTime.IsZero() {
+		rp.sampleStartTime = now
+	}
+	rp.sampleBytes += size
+	elapsed := now.Sub(rp.sampleStartTime)
+	if elapsed < readAheadSampleWindow {
+		return
+	}
+	rate := int64(float64(rp.sampleBytes) / elapsed.Seconds())
+	if rp.throughput == 0 {
+		rp.throughput = rate
+	} else {
+		rp.throughput = (rp.throughput + rate) / 2
+	}
+	rp.sampleStartTime, rp.sampleBytes = now, 0
+}
+
+func (rp *ReaderPattern) resetThroughput() {
+	rp.readAheadLock.Lock()
+	defer rp.readAheadLock.Unlock()
+
+	rp.sampleStartTime, rp.sampleBytes = time.Time{}, 0
+	rp.throughput = 0
+	rp.readAheadWindow = 1
+}
+
+// ReadAheadWindow adjusts the number of chunks to prefetch.
+// The window follows the throughput, but at most doubles each time, so it grows
+// as long as the prefetching keeps up with the reader.
+func (rp *ReaderPattern) ReadAheadWindow(chunkSize int64) int {
+	if rp.IsRandomMode() {
+		return 0
+	}
+
+	rp.readAheadLock.Lock()
+	defer rp.readAheadLock.Unlock()
+
+	if rp.throughput > 0 && chunkSize > 0 {
+		bytesAhead := int64(float64(rp.throughput) * ReadAheadHorizon.Seconds())
+		window := min(min((bytesAhead+chunkSize-1)/chunkSize, int64(2*rp.readAheadWindow)), MaxReadAheadChunks)
+		rp.readAheadWindow = int(max(window, 1))
+	}
+	return rp.readAheadWindow
+}
+
+func (rp *ReaderPattern) Stats() ReaderStats {
+	rp.readAheadLock.Lock()
+	window, throughput := rp.readAheadWindow, rp.throughput
+	rp.readAheadLock.Unlock()
+
+	return ReaderStats{
+		Reads:            atomic.LoadInt64(&rp.stats.Reads),
+		SequentialReads:  atomic.LoadInt64(&rp.stats.SequentialReads),
+		BytesRead:        atomic.LoadInt64(&rp.stats.BytesRead),
+		ChunkHits:        atomic.LoadInt64(&rp.stats.ChunkHits),
+		ChunkMisses:      atomic.LoadInt64(&rp.stats.ChunkMisses),
+		PrefetchedChunks: atomic.LoadInt64(&rp.stats.PrefetchedChunks),
+		ReadAheadWindow:  window,
+		Throughput:       throughput,
+		IsRandomMode:     rp.IsRandomMode(),
+	}
+}
+
+func (rp *ReaderPattern) recordChunkRead(isHit bool) {
+	if isHit {
+		atomic.AddInt64(&rp.stats.ChunkHits, 1)
+	} else {
+		atomic.AddInt64(&rp.stats.ChunkMisses, 1)
+	}
+}
+
+func (rp *ReaderPattern) recordPrefetch(count int) {
+	atomic.AddInt64(&rp.stats.PrefetchedChunks, int64(count))
+}
@@ -0,0 +1,113 @@
+package filer
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReadAheadWindowGrowsWithThroughput(t *testing.T) {
+	rp := NewReaderPattern()
+	chunkSize := int64(4 * 1024 * 1024)
+
+	if window := rp.ReadAheadWindow(chunkSize); window != 1 {
+		t.Fatalf("initial window %d", window)
+	}
+
+	// enough throughput for 10 chunks in the read ahead horizon
+	rp.throughput = int64(float64(10*chunkSize) / ReadAheadHorizon.Seconds())
+	var windows []int
+	for i := 0; i < 5; i++ {
+		windows = append(windows, rp.ReadAheadWindow(chunkSize))
+	}
+	expected := []int{2, 4, 8, 10, 10}
+	for i := range expected {
+		if windows[i] != expected[i] {
+			t.Fatalf("windows %v, expected %v", windows, expected)
+		}
+	}
+
+	// capped by the maximum
+	rp.throughput = int64(float64(1000*chunkSize) / ReadAheadHorizon.Seconds())
+	for i := 0; i < 5; i++ {
+		rp.ReadAheadWindow(chunkSize)
+	}
+	if window := rp.ReadAheadWindow(chunkSize); window != MaxReadAheadChunks {
+		t.Errorf("window %d, expected the maximum %d", window, MaxReadAheadChunks)
+	}
+
+	// shrinks right away when the reader slows down
+	rp.throughput = 1
+	if window := rp.ReadAheadWindow(chunkSize); window != 1 {
+		t.Errorf("window %d after slowing down", window)
+	}
+}
+
+func TestReadAheadWindowRandomMode(t *testing.T) {
+	rp := NewReaderPattern()
+	chunkSize := int64(1024)
+
+	for i := int64(0); i < 4; i++ {
+		rp.MonitorReadAt(i*100, 100)
+	}
+	rp.throughput = 100 * chunkSize
+	if window := rp.ReadAheadWindow(chunkSize); window != 2 {
+		t.Fatalf("sequential window %d", window)
+	}
+
+	for i := int64(0); i < 2*ModeChangeLimit; i++ {
+		rp.MonitorReadAt(i*10000, 100)
+	}
+	if !rp.IsRandomMode() {
+		t.Fatalf("expected random mode")
+	}
+	if window := rp.ReadAheadWindow(chunkSize); window != 0 {
+		t.Errorf("random mode window %d", window)
+	}
+
+	stats := rp.Stats()
+	if stats.Reads != 4+2*ModeChangeLimit || stats.SequentialReads != 4 || stats.Throughput != 0 || !stats.IsRandomMode {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestReadAheadMemoryIsSharedByReaders(t *testing.T) {
+	SetReadAheadMemoryLimit(2)
+	defer SetReadAheadMemoryLimit(DefaultReadAheadMemoryMB)
+
+	chunkSize := uint64(1024 * 1024)
+	newChunkViews := func(prefix string) *Interval[*ChunkView] {
+		chunkViews := NewIntervalList[*ChunkView]()
+		for i := 0; i < 3; i++ {
+			offset := int64(i) * int64(chunkSize)
+			chunkViews.InsertInterval(offset, offset+int64(chunkSize), 1, &ChunkView{
+				FileId:     fmt.Sprintf("%s,%d", prefix, i),
+				ViewSize:   chunkSize,
+				ViewOffset: offset,
+				ChunkSize:  chunkSize,
+			})
+		}
+		return chunkViews.Front()
+	}
+	lookupFn := func(fileId string) ([]string, error) {
+		return nil, fmt.Errorf("not found")
+	}
+
+	rc1 := NewReaderCache(32, &mockChunkCache{}, lookupFn)
+	rc2 := NewReaderCache(32, &mockChunkCache{}, lookupFn)
+	if started := rc1.MaybeCache(newChunkViews("1"), 3); started != 2 {
+		t.Fatalf("first reader prefetched %d chunks", started)
+	}
+	if started := rc2.MaybeCache(newChunkViews("2"), 3); started != 0 {
+		t.Fatalf("second reader prefetched %d chunks over the limit", started)
+	}
+
+	rc1.UnCache("1,0")
+	if started := rc2.MaybeCache(newChunkViews("2"), 3); started != 1 {
+		t.Fatalf("second reader prefetched %d chunks after one is uncached", started)
+	}
+	rc1.destroy()
+	rc2.destroy()
+	if used := readAheadMemory.used; used != 0 {
+		t.Fatalf("read ahead memory %d is not returned", used)
+	}
+}
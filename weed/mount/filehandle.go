@@ -1,12 +1,15 @@
 package mount
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 	"os"
 	"sync"
+	"sync/atomic"
 )
 
 type FileHandleId uint64
@@ -29,6 +32,8 @@ type FileHandle struct {
 	contentType   string
 
 	isDeleted bool
+	// the file locks of this mount on the file were lost while it was open, so its reads and writes fail
+	isLockLost atomic.Bool
 
 	// for debugging
 	mirrorFile *os.File
@@ -75,7 +80,7 @@ func (fh *FileHandle) SetEntry(entry *filer_pb.Entry) {
 		fileSize := filer.FileSize(entry)
 		entry.Attributes.FileSize = fileSize
 		var resolveManifestErr error
-		fh.entryChunkGroup, resolveManifestErr = filer.NewChunkGroup(fh.wfs.LookupFn(), fh.wfs.chunkCache, entry.Chunks)
+		fh.entryChunkGroup, resolveManifestErr = filer.NewChunkGroup(fh.wfs.LookupFn(), fh.wfs.readChunkCache(), entry.Chunks)
 		if resolveManifestErr != nil {
 			glog.Warningf("failed to resolve manifest chunks in %+v", entry)
 		}
@@ -93,12 +98,32 @@ func (fh *FileHandle) AddChunks(chunks []*filer_pb.FileChunk) {
 	fh.entry.AppendChunks(chunks)
 }
 
+// writeDirect uploads the data as one chunk, after the buffered writes, so it is applied on top of them
+func (fh *FileHandle) writeDirect(data []byte, offset int64, tsNs int64) error {
+	if fh.dirtyPages.DirtySize() > 0 {
+		if err := fh.dirtyPages.FlushData(); err != nil {
+			return fmt.Errorf("flush buffered writes: %v", err)
+		}
+	}
+	fileFullPath := fh.FullPath()
+	chunk, err := fh.wfs.saveDataAsChunkOrJournal(fileFullPath)(bytes.NewReader(data), fileFullPath.Name(), offset, tsNs)
+	if err != nil {
+		return err
+	}
+	fh.AddChunks([]*filer_pb.FileChunk{chunk})
+	fh.entryChunkGroup.AddChunk(chunk)
+	return nil
+}
+
 func (fh *FileHandle) ReleaseHandle() {
 
 	fhActiveLock := fh.wfs.fhLockTable.AcquireLock("ReleaseHandle", fh.fh, util.ExclusiveLock)
 	defer fh.wfs.fhLockTable.ReleaseLock(fh.fh, fhActiveLock)
 
 	fh.dirtyPages.Destroy()
+	if fh.entryChunkGroup != nil {
+		fh.entryChunkGroup.Destroy()
+	}
 	if IsDebugFileReadWrite {
 		fh.mirrorFile.Close()
 	}
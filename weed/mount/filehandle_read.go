@@ -8,6 +8,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util/chunk_cache"
 )
 
 func (fh *FileHandle) lockForRead(startOffset int64, size int) {
@@ -22,6 +23,29 @@ func (fh *FileHandle) readFromDirtyPages(buff []byte, startOffset int64, tsNs in
 	return
 }
 
+// locateInChunks finds the range in the chunk cache, if the whole range is in one cached chunk
+func (fh *FileHandle) locateInChunks(offset int64, size int) (ref chunk_cache.CachedChunkRef, found bool) {
+	fh.entryLock.RLock()
+	defer fh.entryLock.RUnlock()
+
+	entry := fh.GetEntry()
+	if entry == nil || entry.IsInRemoteOnly() || len(entry.Content) > 0 || fh.entryChunkGroup == nil {
+		return
+	}
+
+	fileSize := int64(entry.Attributes.FileSize)
+	if fileSize == 0 {
+		fileSize = int64(filer.FileSize(entry.GetEntry()))
+	}
+	size = int(min(int64(size), fileSize-offset))
+	if size <= 0 {
+		return
+	}
+
+	ref, _, found = fh.entryChunkGroup.LocateDataAt(fileSize, offset, size)
+	return
+}
+
 func (fh *FileHandle) readFromChunks(buff []byte, offset int64) (int64, int64, error) {
 	fh.entryLock.RLock()
 	defer fh.entryLock.RUnlock()
@@ -173,6 +173,35 @@ func (i *InodeToPath) IsChildrenCached(fullpath util.FullPath) bool {
 	return false
 }
 
+// UncacheChildren clears the cached flag of the directories at or under the path,
+// and returns the directories that were cached
+func (i *InodeToPath) UncacheChildren(fullpath util.FullPath) (dirs []util.FullPath) {
+	i.Lock()
+	defer i.Unlock()
+	for p, inode := range i.path2inode {
+		if p != fullpath && !p.IsUnder(fullpath) {
+			continue
+		}
+		if entry, found := i.inode2path[inode]; found && entry.isChildrenCached {
+			entry.isChildrenCached = false
+			dirs = append(dirs, p)
+		}
+	}
+	return
+}
+
+// InodesUnder returns the known inodes at or under the path
+func (i *InodeToPath) InodesUnder(fullpath util.FullPath) (inodes []uint64) {
+	i.RLock()
+	defer i.RUnlock()
+	for p, inode := range i.path2inode {
+		if p == fullpath || p.IsUnder(fullpath) {
+			inodes = append(inodes, inode)
+		}
+	}
+	return
+}
+
 func (i *InodeToPath) HasInode(inode uint64) bool {
 	if inode == 1 {
 		return true
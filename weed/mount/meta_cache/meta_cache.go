@@ -23,19 +23,19 @@ type MetaCache struct {
 	uidGidMapper   *UidGidMapper
 	markCachedFn   func(fullpath util.FullPath)
 	isCachedFn     func(fullpath util.FullPath) bool
-	invalidateFunc func(fullpath util.FullPath, entry *filer_pb.Entry)
+	invalidateFunc func(fullpath util.FullPath, oldEntry, newEntry *filer_pb.Entry)
 }
 
 func NewMetaCache(dbFolder string, uidGidMapper *UidGidMapper, root util.FullPath,
-	markCachedFn func(path util.FullPath), isCachedFn func(path util.FullPath) bool, invalidateFunc func(util.FullPath, *filer_pb.Entry)) *MetaCache {
+	markCachedFn func(path util.FullPath), isCachedFn func(path util.FullPath) bool, invalidateFunc func(util.FullPath, *filer_pb.Entry, *filer_pb.Entry)) *MetaCache {
 	return &MetaCache{
 		root:         root,
 		localStore:   openMetaStore(dbFolder),
 		markCachedFn: markCachedFn,
 		isCachedFn:   isCachedFn,
 		uidGidMapper: uidGidMapper,
-		invalidateFunc: func(fullpath util.FullPath, entry *filer_pb.Entry) {
-			invalidateFunc(fullpath, entry)
+		invalidateFunc: func(fullpath util.FullPath, oldEntry, newEntry *filer_pb.Entry) {
+			invalidateFunc(fullpath, oldEntry, newEntry)
 		},
 	}
 }
@@ -41,6 +41,16 @@ func EnsureVisited(mc *MetaCache, client filer_pb.FilerClient, dirPath util.Full
 
 func doEnsureVisited(mc *MetaCache, client filer_pb.FilerClient, path util.FullPath) error {
 
+	err := loadDirectory(mc, client, path, nil)
+	if err == nil {
+		mc.markCachedFn(path)
+	}
+	return err
+}
+
+// loadDirectory lists the directory from the filer into the cache
+func loadDirectory(mc *MetaCache, client filer_pb.FilerClient, path util.FullPath, eachEntryFn func(entry *filer.Entry)) error {
+
 	glog.V(4).Infof("ReadDirAllEntries %s ...", path)
 
 	err := util.Retry("ReadDirAllEntries", func() error {
@@ -53,18 +63,42 @@ func doEnsureVisited(mc *MetaCache, client filer_pb.FilerClient, path util.FullP
 				glog.V(0).Infof("read %s: %v", entry.FullPath, err)
 				return err
 			}
+			if eachEntryFn != nil {
+				eachEntryFn(entry)
+			}
 			return nil
 		})
 	})
 
 	if err != nil {
 		err = fmt.Errorf("list %s: %v", path, err)
-	} else {
-		mc.markCachedFn(path)
 	}
 	return err
 }
 
+// LoadTree lists the directory and all its sub directories into the cache.
+// The loaded directories are reported by loadedFn.
+func LoadTree(mc *MetaCache, client filer_pb.FilerClient, dirPath util.FullPath, loadedFn func(dir util.FullPath)) (dirCount, fileCount int, err error) {
+
+	queue := []util.FullPath{dirPath}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if err = loadDirectory(mc, client, dir, func(entry *filer.Entry) {
+			if entry.IsDirectory() {
+				queue = append(queue, entry.FullPath)
+			} else {
+				fileCount++
+			}
+		}); err != nil {
+			return
+		}
+		dirCount++
+		loadedFn(dir)
+	}
+	return
+}
+
 func IsHiddenSystemEntry(dir, name string) bool {
 	return dir == "/" && (name == "topics" || name == "etc")
 }
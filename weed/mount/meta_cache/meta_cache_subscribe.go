@@ -78,16 +78,20 @@ func SubscribeMetaEvents(mc *MetaCache, selfSignature int32, client filer_pb.Fil
 		if err == nil {
 			if message.OldEntry != nil && message.NewEntry != nil {
 				oldKey := util.NewFullPath(resp.Directory, message.OldEntry.Name)
-				mc.invalidateFunc(oldKey, message.OldEntry)
-				if message.OldEntry.Name != message.NewEntry.Name {
-					newKey := util.NewFullPath(dir, message.NewEntry.Name)
-					mc.invalidateFunc(newKey, message.NewEntry)
+				newKey := util.NewFullPath(dir, message.NewEntry.Name)
+				if oldKey == newKey {
+					mc.invalidateFunc(oldKey, message.OldEntry, message.NewEntry)
+				} else {
+					mc.invalidateFunc(oldKey, message.OldEntry, nil)
+					mc.invalidateFunc(newKey, nil, message.NewEntry)
 				}
 			} else if filer_pb.IsCreate(resp) {
-				// no need to invalidate
+				// only the kernel may have cached the entry as missing
+				newKey := util.NewFullPath(dir, message.NewEntry.Name)
+				mc.invalidateFunc(newKey, nil, message.NewEntry)
 			} else if filer_pb.IsDelete(resp) {
 				oldKey := util.NewFullPath(resp.Directory, message.OldEntry.Name)
-				mc.invalidateFunc(oldKey, message.OldEntry)
+				mc.invalidateFunc(oldKey, message.OldEntry, nil)
 			}
 		}
 
@@ -0,0 +1,398 @@
+package mount
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	journalLogFile         = "journal.log"
+	journalOffsetFile      = "journal.offset"
+	journalDataDir         = "data"
+	journalRejectedLogFile = "rejected.log"
+	journalRejectedDataDir = "rejected"
+
+	// the journaled data chunks are on the volume id 0, which is never assigned by the master
+	journalVolumeId = needle.VolumeId(0)
+)
+
+// OfflineJournal keeps the changes made while the filer is unreachable, to replay them on reconnect.
+// The metadata changes are appended to a log, as the same events in the filer metadata log,
+// and the written data is kept in local files, referred to by the chunks on the journal volume id.
+type OfflineJournal struct {
+	sync.Mutex
+	dir            string
+	logFile        *os.File
+	logSize        int64
+	replayedOffset int64
+	pending        []*journalRecord
+	isOffline      bool
+	uploaded       map[string]*filer_pb.FileChunk // journaled file id => uploaded chunk
+	nextKey        uint64
+}
+
+type journalRecord struct {
+	event      *filer_pb.SubscribeMetadataResponse
+	stopOffset int64
+}
+
+func NewOfflineJournal(dir string) (*OfflineJournal, error) {
+	if err := os.MkdirAll(filepath.Join(dir, journalDataDir), 0755); err != nil {
+		return nil, fmt.Errorf("create journal dir %s: %v", dir, err)
+	}
+	j := &OfflineJournal{
+		dir:      dir,
+		uploaded: make(map[string]*filer_pb.FileChunk),
+		nextKey:  uint64(time.Now().UnixNano()),
+	}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	if len(j.pending) == 0 {
+		// the data left over is not referred to any more
+		os.RemoveAll(filepath.Join(dir, journalDataDir))
+		os.MkdirAll(filepath.Join(dir, journalDataDir), 0755)
+	} else {
+		glog.V(0).Infof("offline journal %s has %d changes to replay", dir, len(j.pending))
+	}
+	if stat, err := os.Stat(filepath.Join(dir, journalRejectedLogFile)); err == nil && stat.Size() > 0 {
+		glog.Warningf("offline journal %s has changes rejected by the filer, kept in %s", dir, journalRejectedLogFile)
+	}
+	return j, nil
+}
+
+func (j *OfflineJournal) load() error {
+	if data, err := os.ReadFile(filepath.Join(j.dir, journalOffsetFile)); err == nil && len(data) == 8 {
+		j.replayedOffset = int64(util.BytesToUint64(data))
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(j.dir, journalLogFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("open journal log: %v", err)
+	}
+	content, err := io.ReadAll(logFile)
+	if err != nil {
+		logFile.Close()
+		return fmt.Errorf("read journal log: %v", err)
+	}
+
+	var offset int64
+	for offset+4 <= int64(len(content)) {
+		size := int64(util.BytesToUint32(content[offset : offset+4]))
+		if offset+4+size > int64(len(content)) {
+			break
+		}
+		event := &filer_pb.SubscribeMetadataResponse{}
+		if err := proto.Unmarshal(content[offset+4:offset+4+size], event); err != nil {
+			break
+		}
+		offset += 4 + size
+		if offset > j.replayedOffset {
+			j.pending = append(j.pending, &journalRecord{event: event, stopOffset: offset})
+		}
+	}
+	if offset < int64(len(content)) {
+		// the last change was not completely written
+		glog.Warningf("truncate journal log %s from %d to %d", j.dir, len(content), offset)
+		if err := logFile.Truncate(offset); err != nil {
+			logFile.Close()
+			return fmt.Errorf("truncate journal log: %v", err)
+		}
+	}
+
+	j.logFile = logFile
+	j.logSize = offset
+	return nil
+}
+
+// Journal appends the change built by fn, when the filer is offline or the earlier changes
+// are not replayed yet, so the changes are always replayed in order. fn applies the change locally.
+func (j *OfflineJournal) Journal(fn func() (*filer_pb.SubscribeMetadataResponse, error)) (journaled bool, err error) {
+	if j == nil {
+		return false, nil
+	}
+	j.Lock()
+	defer j.Unlock()
+
+	if !j.isOffline && len(j.pending) == 0 {
+		return false, nil
+	}
+	event, err := fn()
+	if err != nil {
+		return true, err
+	}
+	return true, j.append(event)
+}
+
+func marshalJournalEvent(event *filer_pb.SubscribeMetadataResponse) ([]byte, error) {
+	data, err := proto.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("marshal journal event: %v", err)
+	}
+	buf := make([]byte, 4+len(data))
+	util.Uint32toBytes(buf[0:4], uint32(len(data)))
+	copy(buf[4:], data)
+	return buf, nil
+}
+
+func (j *OfflineJournal) append(event *filer_pb.SubscribeMetadataResponse) error {
+	buf, err := marshalJournalEvent(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err = j.logFile.WriteAt(buf, j.logSize); err != nil {
+		return fmt.Errorf("write journal log: %v", err)
+	}
+	if err = j.logFile.Sync(); err != nil {
+		return fmt.Errorf("sync journal log: %v", err)
+	}
+	j.logSize += int64(len(buf))
+	j.pending = append(j.pending, &journalRecord{event: event, stopOffset: j.logSize})
+	return nil
+}
+
+// Peek returns the oldest change not replayed yet, or nil
+func (j *OfflineJournal) Peek() *journalRecord {
+	j.Lock()
+	defer j.Unlock()
+	if len(j.pending) == 0 {
+		return nil
+	}
+	return j.pending[0]
+}
+
+// Commit marks the oldest change as replayed, and empties the log after all changes are replayed
+func (j *OfflineJournal) Commit(record *journalRecord) error {
+	j.Lock()
+	defer j.Unlock()
+	if len(j.pending) == 0 || j.pending[0] != record {
+		return nil
+	}
+	j.pending = j.pending[1:]
+
+	if len(j.pending) == 0 {
+		j.logSize, j.replayedOffset = 0, 0
+		os.Remove(filepath.Join(j.dir, journalOffsetFile))
+		if err := j.logFile.Truncate(0); err != nil {
+			return fmt.Errorf("truncate journal log: %v", err)
+		}
+		return nil
+	}
+
+	j.replayedOffset = record.stopOffset
+	buf := make([]byte, 8)
+	util.Uint64toBytes(buf, uint64(j.replayedOffset))
+	return os.WriteFile(filepath.Join(j.dir, journalOffsetFile), buf, 0644)
+}
+
+// Reject sets aside the oldest change, which the filer refused, so the later changes can be replayed.
+// The change is appended to the rejected log, in the same format as the journal log, and the local data
+// it refers to is kept in the rejected data dir, to be recovered manually.
+func (j *OfflineJournal) Reject(record *journalRecord) error {
+	buf, err := marshalJournalEvent(record.event)
+	if err != nil {
+		return err
+	}
+	rejectedDataDir := filepath.Join(j.dir, journalRejectedDataDir)
+	if err = os.MkdirAll(rejectedDataDir, 0755); err != nil {
+		return fmt.Errorf("create %s: %v", rejectedDataDir, err)
+	}
+	// the later changes may refer to the same data, so it is linked instead of moved
+	for _, chunk := range record.event.GetEventNotification().GetNewEntry().GetChunks() {
+		fileId := chunk.GetFileIdString()
+		if !IsJournalFileId(fileId) {
+			continue
+		}
+		rejectedPath := filepath.Join(rejectedDataDir, filepath.Base(j.dataPath(fileId)))
+		if err = linkOrCopyFile(j.dataPath(fileId), rejectedPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("keep rejected journal data %s: %v", fileId, err)
+		}
+	}
+
+	rejectedLog, err := os.OpenFile(filepath.Join(j.dir, journalRejectedLogFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open rejected journal log: %v", err)
+	}
+	defer rejectedLog.Close()
+	if _, err = rejectedLog.Write(buf); err != nil {
+		return fmt.Errorf("write rejected journal log: %v", err)
+	}
+	if err = rejectedLog.Sync(); err != nil {
+		return fmt.Errorf("sync rejected journal log: %v", err)
+	}
+
+	return j.Commit(record)
+}
+
+func linkOrCopyFile(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err = io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+func (j *OfflineJournal) PendingCount() int {
+	j.Lock()
+	defer j.Unlock()
+	return len(j.pending)
+}
+
+// SetOffline returns true if the state is changed
+func (j *OfflineJournal) SetOffline(isOffline bool) (changed bool) {
+	j.Lock()
+	defer j.Unlock()
+	changed = j.isOffline != isOffline
+	j.isOffline = isOffline
+	return
+}
+
+func (j *OfflineJournal) IsOffline() bool {
+	if j == nil {
+		return false
+	}
+	j.Lock()
+	defer j.Unlock()
+	return j.isOffline
+}
+
+// IsJournaling tells whether the changes are journaled instead of sent to the filer
+func (j *OfflineJournal) IsJournaling() bool {
+	if j == nil {
+		return false
+	}
+	j.Lock()
+	defer j.Unlock()
+	return j.isOffline || len(j.pending) > 0
+}
+
+// SaveChunk keeps the data in a local file, and returns a chunk referring to it
+func (j *OfflineJournal) SaveChunk(reader io.Reader, offset int64, tsNs int64) (*filer_pb.FileChunk, error) {
+	j.Lock()
+	j.nextKey++
+	key := j.nextKey
+	j.Unlock()
+
+	fileId := needle.NewFileId(journalVolumeId, key, uint32(util.RandomInt32())).String()
+	dst, err := os.OpenFile(j.dataPath(fileId), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("create journal data %s: %v", fileId, err)
+	}
+	defer dst.Close()
+	size, err := io.Copy(dst, reader)
+	if err != nil {
+		return nil, fmt.Errorf("write journal data %s: %v", fileId, err)
+	}
+	if err = dst.Sync(); err != nil {
+		return nil, fmt.Errorf("sync journal data %s: %v", fileId, err)
+	}
+
+	fid, _ := filer_pb.ToFileIdObject(fileId)
+	return &filer_pb.FileChunk{
+		FileId:       fileId,
+		Offset:       offset,
+		Size:         uint64(size),
+		ModifiedTsNs: tsNs,
+		Fid:          fid,
+	}, nil
+}
+
+func (j *OfflineJournal) ReadDataAt(fileId string, p []byte, offset int64) (n int, err error) {
+	f, err := os.Open(j.dataPath(fileId))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err = f.ReadAt(p, offset)
+	if err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+func (j *OfflineJournal) OpenData(fileId string) (*os.File, error) {
+	return os.Open(j.dataPath(fileId))
+}
+
+func (j *OfflineJournal) HasData(fileId string) bool {
+	_, err := os.Stat(j.dataPath(fileId))
+	return err == nil
+}
+
+func (j *OfflineJournal) Uploaded(fileId string) (chunk *filer_pb.FileChunk, found bool) {
+	j.Lock()
+	defer j.Unlock()
+	chunk, found = j.uploaded[fileId]
+	return
+}
+
+func (j *OfflineJournal) SetUploaded(fileId string, chunk *filer_pb.FileChunk) {
+	j.Lock()
+	defer j.Unlock()
+	j.uploaded[fileId] = chunk
+}
+
+// RemoveUploadedData removes the local data already uploaded to the volume servers
+func (j *OfflineJournal) RemoveUploadedData() {
+	j.Lock()
+	defer j.Unlock()
+	for fileId := range j.uploaded {
+		os.Remove(j.dataPath(fileId))
+	}
+}
+
+func (j *OfflineJournal) dataPath(fileId string) string {
+	return filepath.Join(j.dir, journalDataDir, strings.ReplaceAll(fileId, ",", "_"))
+}
+
+func IsJournalFileId(fileId string) bool {
+	return strings.HasPrefix(fileId, journalVolumeId.String()+",")
+}
+
+// isJournalConflict tells whether the entry on the filer was changed since the journaled change was based on it.
+// Directories are merged, and an entry deleted on the filer is created again.
+func isJournalConflict(base, remote, entry *filer_pb.Entry) bool {
+	if remote == nil {
+		return false
+	}
+	if remote.IsDirectory && entry.IsDirectory {
+		return false
+	}
+	if base == nil {
+		return true
+	}
+	return remote.Attributes.GetMtime() != base.Attributes.GetMtime()
+}
+
+// journalConflictName is the name to save the local version of a conflicting entry
+func journalConflictName(name string, ts time.Time) string {
+	return fmt.Sprintf("%s.conflict-%s", name, ts.Format("20060102-150405"))
+}
@@ -0,0 +1,141 @@
+package mount
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func journalTestEvent(name string) func() (*filer_pb.SubscribeMetadataResponse, error) {
+	return func() (*filer_pb.SubscribeMetadataResponse, error) {
+		return &filer_pb.SubscribeMetadataResponse{
+			Directory: "/dir",
+			EventNotification: &filer_pb.EventNotification{
+				NewEntry:      &filer_pb.Entry{Name: name},
+				NewParentPath: "/dir",
+			},
+		}, nil
+	}
+}
+
+func TestOfflineJournalReplayOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	j, err := NewOfflineJournal(dir)
+	assert.Nil(t, err)
+
+	journaled, err := j.Journal(journalTestEvent("a"))
+	assert.False(t, journaled, "not journaled while online")
+	assert.Nil(t, err)
+
+	j.SetOffline(true)
+	for _, name := range []string{"a", "b", "c"} {
+		journaled, err = j.Journal(journalTestEvent(name))
+		assert.True(t, journaled)
+		assert.Nil(t, err)
+	}
+	j.SetOffline(false)
+	journaled, _ = j.Journal(journalTestEvent("d"))
+	assert.True(t, journaled, "journaled until the earlier changes are replayed")
+
+	assert.Nil(t, j.Commit(j.Peek()))
+	j.logFile.Close()
+
+	// reload with the first change replayed, and a partially written change
+	f, _ := os.OpenFile(filepath.Join(dir, journalLogFile), os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte{0, 0, 1, 0, 1, 2})
+	f.Close()
+
+	j, err = NewOfflineJournal(dir)
+	assert.Nil(t, err)
+	assert.True(t, j.IsJournaling())
+	var names []string
+	for record := j.Peek(); record != nil; record = j.Peek() {
+		names = append(names, record.event.EventNotification.NewEntry.Name)
+		assert.Nil(t, j.Commit(record))
+	}
+	assert.Equal(t, []string{"b", "c", "d"}, names)
+	assert.False(t, j.IsJournaling())
+
+	stat, err := os.Stat(filepath.Join(dir, journalLogFile))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), stat.Size(), "emptied after all changes are replayed")
+}
+
+func TestOfflineJournalData(t *testing.T) {
+	j, err := NewOfflineJournal(t.TempDir())
+	assert.Nil(t, err)
+
+	chunk, err := j.SaveChunk(bytes.NewReader([]byte("hello world")), 100, 1)
+	assert.Nil(t, err)
+	assert.True(t, IsJournalFileId(chunk.GetFileIdString()))
+	assert.Equal(t, uint64(11), chunk.Size)
+	assert.Equal(t, int64(100), chunk.Offset)
+
+	buf := make([]byte, 8)
+	n, err := j.ReadDataAt(chunk.FileId, buf, 6)
+	assert.Nil(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+
+	j.SetUploaded(chunk.FileId, &filer_pb.FileChunk{FileId: "3,01637037d6"})
+	j.RemoveUploadedData()
+	assert.False(t, j.HasData(chunk.FileId))
+	assert.False(t, IsJournalFileId("3,01637037d6"))
+}
+
+func TestOfflineJournalReject(t *testing.T) {
+	dir := t.TempDir()
+	j, err := NewOfflineJournal(dir)
+	assert.Nil(t, err)
+	j.SetOffline(true)
+
+	chunk, err := j.SaveChunk(bytes.NewReader([]byte("hello world")), 0, 1)
+	assert.Nil(t, err)
+	_, err = j.Journal(func() (*filer_pb.SubscribeMetadataResponse, error) {
+		return &filer_pb.SubscribeMetadataResponse{
+			Directory: "/dir",
+			EventNotification: &filer_pb.EventNotification{
+				NewEntry:      &filer_pb.Entry{Name: "a", Chunks: []*filer_pb.FileChunk{chunk}},
+				NewParentPath: "/dir",
+			},
+		}, nil
+	})
+	assert.Nil(t, err)
+	_, err = j.Journal(journalTestEvent("b"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, j.Reject(j.Peek()))
+	assert.Equal(t, "b", j.Peek().event.EventNotification.NewEntry.Name, "the later changes are still replayed")
+	assert.Nil(t, j.Commit(j.Peek()))
+	j.logFile.Close()
+
+	// the journal data is removed on the next start, but not the rejected data
+	j, err = NewOfflineJournal(dir)
+	assert.Nil(t, err)
+	assert.False(t, j.HasData(chunk.FileId))
+	data, err := os.ReadFile(filepath.Join(dir, journalRejectedDataDir, filepath.Base(j.dataPath(chunk.FileId))))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world", string(data))
+	stat, err := os.Stat(filepath.Join(dir, journalRejectedLogFile))
+	assert.Nil(t, err)
+	assert.True(t, stat.Size() > 0)
+}
+
+func TestJournalConflict(t *testing.T) {
+	base := &filer_pb.Entry{Name: "f", Attributes: &filer_pb.FuseAttributes{Mtime: 10}}
+	entry := &filer_pb.Entry{Name: "f", Attributes: &filer_pb.FuseAttributes{Mtime: 20}}
+
+	assert.False(t, isJournalConflict(base, nil, entry), "deleted on the filer")
+	assert.False(t, isJournalConflict(base, &filer_pb.Entry{Attributes: &filer_pb.FuseAttributes{Mtime: 10}}, entry))
+	assert.True(t, isJournalConflict(base, &filer_pb.Entry{Attributes: &filer_pb.FuseAttributes{Mtime: 15}}, entry))
+	assert.True(t, isJournalConflict(nil, &filer_pb.Entry{Attributes: &filer_pb.FuseAttributes{Mtime: 15}}, entry), "created on both sides")
+	assert.False(t, isJournalConflict(nil, &filer_pb.Entry{IsDirectory: true}, &filer_pb.Entry{IsDirectory: true}))
+
+	assert.Equal(t, "f.conflict-20240102-030405", journalConflictName("f", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+}
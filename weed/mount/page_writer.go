@@ -74,6 +74,10 @@ func (pw *PageWriter) UnlockForRead(startOffset, stopOffset int64) {
 	pw.randomWriter.UnlockForRead(startOffset, stopOffset)
 }
 
+func (pw *PageWriter) DirtySize() int64 {
+	return pw.randomWriter.DirtySize()
+}
+
 func (pw *PageWriter) Destroy() {
 	pw.randomWriter.Destroy()
 }
@@ -7,6 +7,7 @@ type DirtyPages interface {
 	Destroy()
 	LockForRead(startOffset, stopOffset int64)
 	UnlockForRead(startOffset, stopOffset int64)
+	DirtySize() int64
 }
 
 func max(x, y int64) int64 {
@@ -146,6 +146,20 @@ func (up *UploadPipeline) MaybeReadDataAt(p []byte, off int64, tsNs int64) (maxS
 	return
 }
 
+// DirtySize is the amount of data written but not uploaded yet
+func (up *UploadPipeline) DirtySize() (size int64) {
+	up.chunksLock.Lock()
+	defer up.chunksLock.Unlock()
+
+	for _, pageChunk := range up.writableChunks {
+		size += pageChunk.WrittenSize()
+	}
+	for _, sealedChunk := range up.sealedChunks {
+		size += sealedChunk.chunk.WrittenSize()
+	}
+	return
+}
+
 func (up *UploadPipeline) FlushAll() {
 	up.flushChunks()
 	up.waitForCurrentWritersToComplete()
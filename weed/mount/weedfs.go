@@ -14,6 +14,7 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/mount/meta_cache"
 	"github.com/seaweedfs/seaweedfs/weed/pb"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
@@ -27,6 +28,8 @@ import (
 	"github.com/hanwen/go-fuse/v2/fs"
 )
 
+const DefaultConcurrentWriters = 32
+
 type Option struct {
 	filerIndex         int32 // align memory for atomic read/write
 	FilerAddresses     []pb.ServerAddress
@@ -41,11 +44,17 @@ type Option struct {
 	ConcurrentWriters  int
 	CacheDirForRead    string
 	CacheSizeMBForRead int64
+	CacheSizeMBForPin  int64
+	ReadAheadMemoryMB  int64
 	CacheDirForWrite   string
 	DataCenter         string
 	Umask              os.FileMode
 	Quota              int64
 	DisableXAttr       bool
+	EnableLocks        bool
+	OfflineWriteBack   bool
+	Consistency        string
+	ZeroCopyRead       bool
 
 	MountUid         uint32
 	MountGid         uint32
@@ -60,6 +69,7 @@ type Option struct {
 
 	uniqueCacheDirForRead  string
 	uniqueCacheDirForWrite string
+	offlineJournalDir      string
 }
 
 type WFS struct {
@@ -81,6 +91,9 @@ type WFS struct {
 	IsOverQuota       bool
 	fhLockTable       *util.LockTable[FileHandleId]
 	FilerConf         *filer.FilerConf
+	fileLocks         *fileLocks
+	offlineJournal    *OfflineJournal
+	pins              *pinnedDirs
 }
 
 func NewSeaweedFileSystem(option *Option) *WFS {
@@ -92,6 +105,8 @@ func NewSeaweedFileSystem(option *Option) *WFS {
 		fhMap:         NewFileHandleToInode(),
 		dhMap:         NewDirectoryHandleToInode(),
 		fhLockTable:   util.NewLockTable[FileHandleId](),
+		fileLocks:     newFileLocks(),
+		pins:          newPinnedDirs(),
 	}
 
 	wfs.option.filerIndex = int32(rand.Intn(len(option.FilerAddresses)))
@@ -99,47 +114,78 @@ func NewSeaweedFileSystem(option *Option) *WFS {
 	if option.CacheSizeMBForRead > 0 {
 		wfs.chunkCache = chunk_cache.NewTieredChunkCache(256, option.getUniqueCacheDirForRead(), option.CacheSizeMBForRead, 1024*1024)
 	}
+	if option.ReadAheadMemoryMB > 0 {
+		filer.SetReadAheadMemoryLimit(option.ReadAheadMemoryMB)
+	}
+	if option.CacheSizeMBForPin > 0 {
+		if err := wfs.chunkCache.SetPinnedCapacity(option.CacheSizeMBForPin); err != nil {
+			glog.Warningf("pinned cache region: %v", err)
+		}
+	}
 
 	wfs.metaCache = meta_cache.NewMetaCache(path.Join(option.getUniqueCacheDirForRead(), "meta"), option.UidGidMapper,
 		util.FullPath(option.FilerMountRootPath),
 		func(path util.FullPath) {
+			wfs.pins.markLoaded(path)
 			wfs.inodeToPath.MarkChildrenCached(path)
 		}, func(path util.FullPath) bool {
-			return wfs.inodeToPath.IsChildrenCached(path)
-		}, func(filePath util.FullPath, entry *filer_pb.Entry) {
-			// Find inode if it is not a deleted path
-			if inode, inodeFound := wfs.inodeToPath.GetInode(filePath); inodeFound {
-				// Find open file handle
-				if fh, fhFound := wfs.fhMap.FindFileHandle(inode); fhFound {
-					fhActiveLock := fh.wfs.fhLockTable.AcquireLock("invalidateFunc", fh.fh, util.ExclusiveLock)
-					defer fh.wfs.fhLockTable.ReleaseLock(fh.fh, fhActiveLock)
-
-					// Recreate dirty pages
-					fh.dirtyPages.Destroy()
-					fh.dirtyPages = newPageWriter(fh, wfs.option.ChunkSizeLimit)
-
-					// Update handle entry
-					newEntry, status := wfs.maybeLoadEntry(filePath)
-					if status == fuse.OK {
-						if fh.GetEntry().GetEntry() != newEntry {
-							fh.SetEntry(newEntry)
-						}
-					}
-				}
-			}
+			return wfs.isChildrenCached(path)
+		}, func(filePath util.FullPath, oldEntry, newEntry *filer_pb.Entry) {
+			wfs.invalidateOpenFile(filePath)
+			wfs.notifyKernel(filePath, oldEntry, newEntry)
 		})
 	grace.OnInterrupt(func() {
+		wfs.releaseAllFileLocks()
 		wfs.metaCache.Shutdown()
 		os.RemoveAll(option.getUniqueCacheDirForWrite())
 		os.RemoveAll(option.getUniqueCacheDirForRead())
 	})
 
-	if wfs.option.ConcurrentWriters > 0 {
-		wfs.concurrentWriters = util.NewLimitedConcurrentExecutor(wfs.option.ConcurrentWriters)
+	if wfs.option.EnableLocks {
+		go wfs.loopRenewFileLocks()
+	}
+
+	if wfs.option.OfflineWriteBack {
+		journal, err := NewOfflineJournal(option.getOfflineJournalDir())
+		if err != nil {
+			glog.Fatalf("offline journal: %v", err)
+		}
+		wfs.offlineJournal = journal
+		go wfs.loopReplayJournal()
+	}
+
+	// the uploads of the dirty pages and the Tune rpc always go through the executor
+	if wfs.option.ConcurrentWriters <= 0 {
+		wfs.option.ConcurrentWriters = DefaultConcurrentWriters
 	}
+	wfs.concurrentWriters = util.NewLimitedConcurrentExecutor(wfs.option.ConcurrentWriters)
 	return wfs
 }
 
+// invalidateOpenFile reloads the entry of the open file changed by other clients
+func (wfs *WFS) invalidateOpenFile(filePath util.FullPath) {
+	// Find inode if it is not a deleted path
+	if inode, inodeFound := wfs.inodeToPath.GetInode(filePath); inodeFound {
+		// Find open file handle
+		if fh, fhFound := wfs.fhMap.FindFileHandle(inode); fhFound {
+			fhActiveLock := fh.wfs.fhLockTable.AcquireLock("invalidateFunc", fh.fh, util.ExclusiveLock)
+			defer fh.wfs.fhLockTable.ReleaseLock(fh.fh, fhActiveLock)
+
+			// Recreate dirty pages
+			fh.dirtyPages.Destroy()
+			fh.dirtyPages = newPageWriter(fh, wfs.option.ChunkSizeLimit)
+
+			// Update handle entry
+			newEntry, status := wfs.maybeLoadEntry(filePath)
+			if status == fuse.OK {
+				if fh.GetEntry().GetEntry() != newEntry {
+					fh.SetEntry(newEntry)
+				}
+			}
+		}
+	}
+}
+
 func (wfs *WFS) StartBackgroundTasks() error {
 	follower, err := wfs.subscribeFilerConfEvents()
 	if err != nil {
@@ -228,6 +274,9 @@ func (option *Option) setupUniqueCacheDirectory() {
 	os.MkdirAll(option.uniqueCacheDirForRead, os.FileMode(0777)&^option.Umask)
 	option.uniqueCacheDirForWrite = filepath.Join(path.Join(option.CacheDirForWrite, cacheUniqueId), "swap")
 	os.MkdirAll(option.uniqueCacheDirForWrite, os.FileMode(0777)&^option.Umask)
+	// the journal is kept after unmounting, to replay the changes on the next mount, also after upgrading
+	journalUniqueId := util.Md5String([]byte(option.MountDirectory + string(option.FilerAddresses[0]) + option.FilerMountRootPath))[0:8]
+	option.offlineJournalDir = filepath.Join(option.CacheDirForWrite, "journal", journalUniqueId)
 }
 
 func (option *Option) getUniqueCacheDirForWrite() string {
@@ -237,3 +286,7 @@ func (option *Option) getUniqueCacheDirForWrite() string {
 func (option *Option) getUniqueCacheDirForRead() string {
 	return option.uniqueCacheDirForRead
 }
+
+func (option *Option) getOfflineJournalDir() string {
+	return option.offlineJournalDir
+}
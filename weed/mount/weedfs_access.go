@@ -0,0 +1,170 @@
+package mount
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+/**
+ * Check file access permissions
+ *
+ * This will be called for the access() system call.  If the
+ * 'default_permissions' mount option is given, this method is not
+ * called.
+ *
+ * This method is not called under Linux kernel versions 2.4.x
+ */
+func (wfs *WFS) Access(cancel <-chan struct{}, input *fuse.AccessIn) (code fuse.Status) {
+	_, _, entry, status := wfs.maybeReadEntry(input.NodeId)
+	if status != fuse.OK {
+		return status
+	}
+	if entry == nil {
+		return fuse.ENOENT
+	}
+	if input.Mask == 0 { // F_OK
+		return fuse.OK
+	}
+	if filer.CheckPbEntryAccess(entry, callerIdentity(&input.Caller), input.Mask&7) {
+		return fuse.OK
+	}
+	return fuse.EACCES
+}
+
+// checkAclAccess only rejects access to entries carrying a POSIX or NFSv4 acl.
+// Entries without acls keep the existing behavior.
+func (wfs *WFS) checkAclAccess(caller *fuse.Caller, entry *filer_pb.Entry, want uint32) fuse.Status {
+	if entry == nil || !filer.HasAcl(entry.Extended) {
+		return fuse.OK
+	}
+	if filer.CheckPbEntryAccess(entry, callerIdentity(caller), want) {
+		return fuse.OK
+	}
+	return fuse.EACCES
+}
+
+// checkDirAclAccess checks the permission to add or remove entries in a directory
+func (wfs *WFS) checkDirAclAccess(caller *fuse.Caller, dirPath util.FullPath) fuse.Status {
+	dirEntry, status := wfs.maybeLoadEntry(dirPath)
+	if status != fuse.OK {
+		return status
+	}
+	return wfs.checkAclAccess(caller, dirEntry, filer.AccessWrite|filer.AccessExecute)
+}
+
+// inheritAcl applies the acls of the parent directory to a new entry
+func (wfs *WFS) inheritAcl(dirPath util.FullPath, newEntry *filer_pb.Entry) {
+	dirEntry, status := wfs.maybeLoadEntry(dirPath)
+	if status != fuse.OK || dirEntry == nil || dirEntry.Extended == nil {
+		return
+	}
+	mode, extended := filer.InheritAcl(dirEntry.Extended, newEntry.IsDirectory, os.FileMode(newEntry.Attributes.FileMode), newEntry.Extended)
+	newEntry.Attributes.FileMode = uint32(mode)
+	if len(extended) > 0 {
+		newEntry.Extended = extended
+	}
+}
+
+func openFlagsToAccess(flags uint32) (want uint32) {
+	switch flags & syscall.O_ACCMODE {
+	case syscall.O_RDONLY:
+		want = filer.AccessRead
+	case syscall.O_WRONLY:
+		want = filer.AccessWrite
+	default:
+		want = filer.AccessRead | filer.AccessWrite
+	}
+	if flags&syscall.O_TRUNC != 0 {
+		want |= filer.AccessWrite
+	}
+	return
+}
+
+// callerIdentity reads the supplementary groups of the calling process
+func callerIdentity(caller *fuse.Caller) *filer.AclIdentity {
+	identity := &filer.AclIdentity{
+		Uid:  caller.Uid,
+		Gids: []uint32{caller.Gid},
+	}
+	if caller.Pid == 0 {
+		return identity
+	}
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", caller.Pid))
+	if err != nil {
+		return identity
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "Groups:") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.TrimPrefix(line, "Groups:")) {
+			if gid, err := strconv.ParseUint(field, 10, 32); err == nil {
+				identity.Gids = append(identity.Gids, uint32(gid))
+			}
+		}
+		break
+	}
+	return identity
+}
+
+// applyAclXAttr validates an acl xattr before it is saved, and keeps the mode bits in sync with the access acl.
+// It returns false if the access acl is fully represented by the mode bits and needs not be saved.
+func applyAclXAttr(caller *fuse.Caller, entry *filer_pb.Entry, attr string, data []byte) (save bool, code fuse.Status) {
+	switch attr {
+	case filer.PosixAclAccessXattr, filer.PosixAclDefaultXattr, filer.Nfs4AclXattr:
+	default:
+		return true, fuse.OK
+	}
+	if caller.Uid != 0 && caller.Uid != entry.Attributes.Uid {
+		return false, fuse.EPERM
+	}
+
+	if attr == filer.Nfs4AclXattr {
+		if _, err := filer.ParseNfs4Acl(data); err != nil {
+			return false, fuse.EINVAL
+		}
+		return true, fuse.OK
+	}
+
+	acl, err := filer.ParsePosixAcl(data)
+	if err != nil {
+		return false, fuse.EINVAL
+	}
+	if attr == filer.PosixAclDefaultXattr {
+		if !entry.IsDirectory {
+			return false, fuse.EACCES
+		}
+		return true, fuse.OK
+	}
+	entry.Attributes.FileMode = entry.Attributes.FileMode&^0777 | acl.Mode()
+	if acl.IsMinimal() {
+		delete(entry.Extended, XATTR_PREFIX+attr)
+		return false, fuse.OK
+	}
+	return true, fuse.OK
+}
+
+// chmodAccessAcl updates the access acl after the mode bits are changed
+func chmodAccessAcl(entry *filer_pb.Entry) {
+	data, found := entry.Extended[XATTR_PREFIX+filer.PosixAclAccessXattr]
+	if !found {
+		return
+	}
+	acl, err := filer.ParsePosixAcl(data)
+	if err != nil {
+		return
+	}
+	entry.Extended[XATTR_PREFIX+filer.PosixAclAccessXattr] = acl.Chmod(entry.Attributes.FileMode).Bytes()
+}
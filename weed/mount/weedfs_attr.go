@@ -86,6 +86,7 @@ func (wfs *WFS) SetAttr(cancel <-chan struct{}, input *fuse.SetAttrIn, out *fuse
 	if mode, ok := input.GetMode(); ok {
 		// glog.V(4).Infof("setAttr mode %o", mode)
 		entry.Attributes.FileMode = chmod(entry.Attributes.FileMode, mode)
+		chmodAccessAcl(entry)
 		if input.NodeId == 1 {
 			wfs.option.MountMode = os.FileMode(chmod(uint32(wfs.option.MountMode), mode))
 		}
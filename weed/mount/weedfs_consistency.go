@@ -0,0 +1,160 @@
+package mount
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	// the changes by other clients are visible after the metadata events arrive asynchronously
+	ConsistencyRelaxed = "relaxed"
+	// opening a file sees the changes by other clients closed before
+	ConsistencyCloseToOpen = "close-to-open"
+)
+
+func (option *Option) isCloseToOpen() bool {
+	return option.Consistency == ConsistencyCloseToOpen
+}
+
+// revalidateEntry refreshes the entry from the filer when opening a file,
+// instead of waiting for the metadata events of the changes by other clients
+func (wfs *WFS) revalidateEntry(inode uint64) fuse.Status {
+	if wfs.offlineJournal.IsJournaling() {
+		// the local changes are newer than the filer
+		return fuse.OK
+	}
+	fullPath, status := wfs.inodeToPath.GetPath(inode)
+	if status != fuse.OK {
+		return status
+	}
+	fh, fhFound := wfs.fhMap.FindFileHandle(inode)
+	if fhFound && fh.dirtyMetadata {
+		// the local writes are not flushed yet
+		return fuse.OK
+	}
+
+	ctx := context.Background()
+	remote, err := filer_pb.GetEntry(wfs, fullPath)
+	if err == filer_pb.ErrNotFound {
+		glog.V(3).Infof("revalidate %s: deleted", fullPath)
+		wfs.metaCache.DeleteEntry(ctx, fullPath)
+		return fuse.ENOENT
+	}
+	if err != nil {
+		// keep using the cached entry
+		glog.V(1).Infof("revalidate %s: %v", fullPath, err)
+		return fuse.OK
+	}
+
+	cached, _ := wfs.metaCache.FindEntry(ctx, fullPath)
+	if cached != nil && !isEntryChanged(cached.ToProtoEntry(), remote) {
+		return fuse.OK
+	}
+	glog.V(3).Infof("revalidate %s: changed", fullPath)
+
+	dir, _ := fullPath.DirAndName()
+	if err = wfs.metaCache.InsertEntry(ctx, filer.FromPbEntry(dir, remote)); err != nil {
+		glog.Errorf("revalidate %s: %v", fullPath, err)
+		return fuse.EIO
+	}
+	if fhFound {
+		fhActiveLock := wfs.fhLockTable.AcquireLock("revalidateEntry", fh.fh, util.ExclusiveLock)
+		wfs.mapPbIdFromFilerToLocal(remote)
+		fh.SetEntry(remote)
+		wfs.fhLockTable.ReleaseLock(fh.fh, fhActiveLock)
+	}
+	if wfs.fuseServer != nil {
+		// only the attributes, since the kernel drops the cached pages on open
+		wfs.fuseServer.InodeNotify(inode, -1, 0)
+	}
+	return fuse.OK
+}
+
+// notifyKernel invalidates the kernel caches of an entry changed by other clients.
+// Without the old or the new entry, the entry is created, deleted or renamed, and only the dentry is invalidated.
+func (wfs *WFS) notifyKernel(fullPath util.FullPath, oldEntry, newEntry *filer_pb.Entry) {
+	if wfs.fuseServer == nil || !wfs.option.isCloseToOpen() {
+		return
+	}
+
+	if oldEntry == nil || newEntry == nil {
+		dir, name := fullPath.DirAndName()
+		if parentInode, found := wfs.inodeToPath.GetInode(util.FullPath(dir)); found {
+			status := wfs.fuseServer.EntryNotify(parentInode, name)
+			glog.V(4).Infof("entry notify %s: %v", fullPath, status)
+		}
+		return
+	}
+
+	inode, found := wfs.inodeToPath.GetInode(fullPath)
+	if !found {
+		return
+	}
+	offset, size, changed := changedDataRange(oldEntry, newEntry)
+	if !changed {
+		// only the attributes
+		offset, size = -1, 0
+	}
+	status := wfs.fuseServer.InodeNotify(inode, offset, size)
+	glog.V(4).Infof("inode notify %s [%d,%d): %v", fullPath, offset, offset+size, status)
+}
+
+func isEntryChanged(oldEntry, newEntry *filer_pb.Entry) bool {
+	if oldEntry.Attributes.GetMtime() != newEntry.Attributes.GetMtime() ||
+		oldEntry.Attributes.GetFileMode() != newEntry.Attributes.GetFileMode() {
+		return true
+	}
+	_, _, changed := changedDataRange(oldEntry, newEntry)
+	return changed
+}
+
+// changedDataRange returns the range of the file content that differs between the two entries
+func changedDataRange(oldEntry, newEntry *filer_pb.Entry) (offset, size int64, changed bool) {
+	start, stop := int64(math.MaxInt64), int64(0)
+	include := func(from, to int64) {
+		if from < to {
+			start, stop, changed = min(start, from), max(stop, to), true
+		}
+	}
+
+	oldSize, newSize := int64(filer.FileSize(oldEntry)), int64(filer.FileSize(newEntry))
+	include(min(oldSize, newSize), max(oldSize, newSize))
+
+	if !bytes.Equal(oldEntry.Content, newEntry.Content) {
+		include(0, max(int64(len(oldEntry.Content)), int64(len(newEntry.Content))))
+	}
+
+	oldChunks, newChunks := make(map[string]bool), make(map[string]bool)
+	for _, chunk := range oldEntry.GetChunks() {
+		oldChunks[chunkKey(chunk)] = true
+	}
+	for _, chunk := range newEntry.GetChunks() {
+		newChunks[chunkKey(chunk)] = true
+		if !oldChunks[chunkKey(chunk)] {
+			include(chunk.Offset, chunk.Offset+int64(chunk.Size))
+		}
+	}
+	for _, chunk := range oldEntry.GetChunks() {
+		if !newChunks[chunkKey(chunk)] {
+			include(chunk.Offset, chunk.Offset+int64(chunk.Size))
+		}
+	}
+
+	if !changed {
+		return 0, 0, false
+	}
+	return start, stop - start, true
+}
+
+func chunkKey(chunk *filer_pb.FileChunk) string {
+	return fmt.Sprintf("%s@%d+%d/%d", chunk.GetFileIdString(), chunk.Offset, chunk.Size, chunk.TrimmedOffset)
+}
@@ -0,0 +1,62 @@
+package mount
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestChangedDataRange(t *testing.T) {
+	oldEntry := &filer_pb.Entry{
+		Attributes: &filer_pb.FuseAttributes{FileSize: 300},
+		Chunks: []*filer_pb.FileChunk{
+			{FileId: "1,a", Offset: 0, Size: 100},
+			{FileId: "2,b", Offset: 100, Size: 100},
+			{FileId: "3,c", Offset: 200, Size: 100},
+		},
+	}
+
+	_, _, changed := changedDataRange(oldEntry, oldEntry)
+	assert.False(t, changed)
+
+	// overwrite the middle chunk
+	newEntry := &filer_pb.Entry{
+		Attributes: &filer_pb.FuseAttributes{FileSize: 300},
+		Chunks: []*filer_pb.FileChunk{
+			oldEntry.Chunks[0],
+			oldEntry.Chunks[2],
+			{FileId: "4,d", Offset: 120, Size: 30},
+		},
+	}
+	offset, size, changed := changedDataRange(oldEntry, newEntry)
+	assert.True(t, changed)
+	assert.Equal(t, int64(100), offset)
+	assert.Equal(t, int64(100), size)
+
+	// append
+	newEntry = &filer_pb.Entry{
+		Attributes: &filer_pb.FuseAttributes{FileSize: 350},
+		Chunks:     append(oldEntry.Chunks, &filer_pb.FileChunk{FileId: "5,e", Offset: 300, Size: 50}),
+	}
+	offset, size, changed = changedDataRange(oldEntry, newEntry)
+	assert.True(t, changed)
+	assert.Equal(t, int64(300), offset)
+	assert.Equal(t, int64(50), size)
+
+	// truncate
+	newEntry = &filer_pb.Entry{
+		Attributes: &filer_pb.FuseAttributes{FileSize: 0},
+	}
+	offset, size, changed = changedDataRange(oldEntry, newEntry)
+	assert.True(t, changed)
+	assert.Equal(t, int64(0), offset)
+	assert.Equal(t, int64(300), size)
+
+	// inline content
+	offset, size, changed = changedDataRange(&filer_pb.Entry{Content: []byte("abc")}, &filer_pb.Entry{Content: []byte("abd")})
+	assert.True(t, changed)
+	assert.Equal(t, int64(0), offset)
+	assert.Equal(t, int64(3), size)
+}
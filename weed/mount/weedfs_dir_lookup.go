@@ -56,6 +56,7 @@ func (wfs *WFS) Lookup(cancel <-chan struct{}, header *fuse.InHeader, name strin
 	}
 
 	inode := wfs.inodeToPath.Lookup(fullFilePath, localEntry.Crtime.Unix(), localEntry.IsDirectory(), len(localEntry.HardLinkId) > 0, localEntry.Inode, true)
+	wfs.maybePinByXAttr(fullFilePath, localEntry)
 
 	if fh, found := wfs.fhMap.FindFileHandle(inode); found {
 		fh.entryLock.RLock()
@@ -48,37 +48,46 @@ func (wfs *WFS) Mkdir(cancel <-chan struct{}, in *fuse.MkdirIn, name string, out
 		return
 	}
 
-	entryFullPath := dirFullPath.Child(name)
-
-	err := wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
-
-		wfs.mapPbIdFromLocalToFiler(newEntry)
-		defer wfs.mapPbIdFromFilerToLocal(newEntry)
-
-		request := &filer_pb.CreateEntryRequest{
-			Directory:                string(dirFullPath),
-			Entry:                    newEntry,
-			Signatures:               []int32{wfs.signature},
-			SkipCheckParentDirectory: true,
-		}
-
-		glog.V(1).Infof("mkdir: %v", request)
-		if err := filer_pb.CreateEntry(client, request); err != nil {
-			glog.V(0).Infof("mkdir %s: %v", entryFullPath, err)
-			return err
-		}
+	if code = wfs.checkDirAclAccess(&in.Caller, dirFullPath); code != fuse.OK {
+		return
+	}
+	wfs.inheritAcl(dirFullPath, newEntry)
 
-		if err := wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry)); err != nil {
-			return fmt.Errorf("local mkdir dir %s: %v", entryFullPath, err)
-		}
+	entryFullPath := dirFullPath.Child(name)
 
-		return nil
+	err := wfs.withFilerOrJournal(func() error {
+		return wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+
+			wfs.mapPbIdFromLocalToFiler(newEntry)
+			defer wfs.mapPbIdFromFilerToLocal(newEntry)
+
+			request := &filer_pb.CreateEntryRequest{
+				Directory:                string(dirFullPath),
+				Entry:                    newEntry,
+				Signatures:               []int32{wfs.signature},
+				SkipCheckParentDirectory: true,
+			}
+
+			glog.V(1).Infof("mkdir: %v", request)
+			if err := filer_pb.CreateEntry(client, request); err != nil {
+				glog.V(0).Infof("mkdir %s: %v", entryFullPath, err)
+				return err
+			}
+
+			if err := wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry)); err != nil {
+				return fmt.Errorf("local mkdir dir %s: %v", entryFullPath, err)
+			}
+
+			return nil
+		})
+	}, func() (bool, error) {
+		return wfs.journalEntry(dirFullPath, newEntry)
 	})
 
 	glog.V(3).Infof("mkdir %s: %v", entryFullPath, err)
 
 	if err != nil {
-		return fuse.EIO
+		return quotaAwareStatus(err)
 	}
 
 	inode := wfs.inodeToPath.Lookup(entryFullPath, newEntry.Attributes.Crtime, true, false, 0, true)
@@ -103,11 +112,18 @@ func (wfs *WFS) Rmdir(cancel <-chan struct{}, header *fuse.InHeader, name string
 	if code != fuse.OK {
 		return
 	}
+	if code = wfs.checkDirAclAccess(&header.Caller, dirFullPath); code != fuse.OK {
+		return
+	}
 	entryFullPath := dirFullPath.Child(name)
 
 	glog.V(3).Infof("remove directory: %v", entryFullPath)
 	ignoreRecursiveErr := true // ignore recursion error since the OS should manage it
-	err := filer_pb.Remove(wfs, string(dirFullPath), name, true, false, ignoreRecursiveErr, false, []int32{wfs.signature})
+	err := wfs.withFilerOrJournal(func() error {
+		return filer_pb.Remove(wfs, string(dirFullPath), name, true, false, ignoreRecursiveErr, false, []int32{wfs.signature})
+	}, func() (bool, error) {
+		return wfs.journalDeleteEntry(dirFullPath, name, true)
+	})
 	if err != nil {
 		glog.V(0).Infof("remove %s: %v", entryFullPath, err)
 		if strings.Contains(err.Error(), filer.MsgFailDelNonEmptyFolder) {
@@ -169,6 +169,7 @@ func (wfs *WFS) doReadDirectory(input *fuse.ReadIn, out *fuse.DirEntryList, isPl
 				isEarlyTerminated = true
 				return false
 			}
+			wfs.maybePinByXAttr(dirPath.Child(dirEntry.Name), entry)
 			if fh, found := wfs.fhMap.FindFileHandle(inode); found {
 				glog.V(4).Infof("readdir opened file %s", dirPath.Child(dirEntry.Name))
 				entry = filer.FromPbEntry(string(dirPath), fh.GetEntry().GetEntry())
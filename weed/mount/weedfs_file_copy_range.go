@@ -1,13 +1,17 @@
 package mount
 
 import (
-	"github.com/seaweedfs/seaweedfs/weed/util"
+	"context"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/hanwen/go-fuse/v2/fuse"
 
+	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 // CopyFileRange copies data from one file to another from and to specified offsets.
@@ -44,6 +48,19 @@ func (wfs *WFS) CopyFileRange(cancel <-chan struct{}, in *fuse.CopyFileRangeIn)
 		return 0, fuse.EBADF
 	}
 
+	// chunks can be cloned only after the written data is flushed, and not while the changes are journaled
+	isClone := int64(in.Len) >= wfs.option.ChunkSizeLimit && !wfs.offlineJournal.IsJournaling()
+	if isClone {
+		if code = wfs.doFlush(fhIn, in.Uid, in.Gid); code != fuse.OK {
+			return 0, code
+		}
+		if fhOut.fh != fhIn.fh {
+			if code = wfs.doFlush(fhOut, in.Uid, in.Gid); code != fuse.OK {
+				return 0, code
+			}
+		}
+	}
+
 	// lock source and target file handles
 	fhOutActiveLock := fhOut.wfs.fhLockTable.AcquireLock("CopyFileRange", fhOut.fh, util.ExclusiveLock)
 	defer fhOut.wfs.fhLockTable.ReleaseLock(fhOut.fh, fhOutActiveLock)
@@ -70,8 +87,80 @@ func (wfs *WFS) CopyFileRange(cancel <-chan struct{}, in *fuse.CopyFileRangeIn)
 		in.OffOut, in.OffOut+in.Len,
 	)
 
-	data := make([]byte, in.Len)
-	totalRead, err := readDataByFileHandle(data, fhIn, int64(in.OffIn))
+	// clone the whole chunks in the range by reference, and copy the rest of the data
+	var clonedRanges []*filer_pb.ClonedRange
+	if isClone {
+		clonedRanges = wfs.cloneFileRange(fhIn, fhOut, in)
+	}
+
+	offIn, offOut, stopIn := int64(in.OffIn), int64(in.OffOut), int64(in.OffIn+in.Len)
+	for _, cloned := range append(clonedRanges, &filer_pb.ClonedRange{Offset: stopIn}) {
+		if offIn < cloned.Offset {
+			totalRead, status := copyFileRangeData(fhIn, fhOut, offIn, offOut, cloned.Offset-offIn)
+			if status != fuse.OK {
+				return 0, status
+			}
+			written += uint32(totalRead)
+			if totalRead < cloned.Offset-offIn {
+				break
+			}
+		}
+		written += uint32(cloned.Size)
+		offOut += cloned.Offset + cloned.Size - offIn
+		offIn = cloned.Offset + cloned.Size
+	}
+
+	return written, fuse.OK
+}
+
+// cloneFileRange clones the chunks fully inside the source range into the target file, and returns the cloned ranges
+func (wfs *WFS) cloneFileRange(fhIn, fhOut *FileHandle, in *fuse.CopyFileRangeIn) (clonedRanges []*filer_pb.ClonedRange) {
+	sourceDir, sourceName := fhIn.FullPath().DirAndName()
+	targetDir, targetName := fhOut.FullPath().DirAndName()
+
+	err := wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.CloneEntry(context.Background(), &filer_pb.CloneEntryRequest{
+			SourceDirectory: sourceDir,
+			SourceName:      sourceName,
+			TargetDirectory: targetDir,
+			TargetName:      targetName,
+			IsRange:         true,
+			SourceOffset:    int64(in.OffIn),
+			TargetOffset:    int64(in.OffOut),
+			Length:          int64(in.Len),
+			Signatures:      []int32{wfs.signature},
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("%s", resp.Error)
+		}
+
+		// the source chunks are marked as shared
+		wfs.mapPbIdFromFilerToLocal(resp.SourceEntry)
+		wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(sourceDir, resp.SourceEntry))
+		if fhIn.fh != fhOut.fh {
+			fhIn.SetEntry(resp.SourceEntry)
+		}
+		wfs.mapPbIdFromFilerToLocal(resp.Entry)
+		wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(targetDir, resp.Entry))
+		fhOut.SetEntry(resp.Entry)
+
+		clonedRanges = resp.ClonedRanges
+		return nil
+	})
+	if err != nil {
+		glog.Warningf("clone %s [%d,%d) => %s %d: %v", fhIn.FullPath(), in.OffIn, in.OffIn+in.Len, fhOut.FullPath(), in.OffOut, err)
+		return nil
+	}
+	return
+}
+
+// copyFileRangeData reads the source data and writes it to the target file as dirty pages
+func copyFileRangeData(fhIn, fhOut *FileHandle, offIn, offOut, size int64) (int64, fuse.Status) {
+	data := make([]byte, size)
+	totalRead, err := readDataByFileHandle(data, fhIn, offIn)
 	if err != nil {
 		glog.Warningf("file handle read %s %d: %v", fhIn.FullPath(), totalRead, err)
 		return 0, fuse.EIO
@@ -83,17 +172,16 @@ func (wfs *WFS) CopyFileRange(cancel <-chan struct{}, in *fuse.CopyFileRangeIn)
 	}
 
 	// put data at the specified offset in target file
-	fhOut.dirtyPages.writerPattern.MonitorWriteAt(int64(in.OffOut), int(in.Len))
+	fhOut.dirtyPages.writerPattern.MonitorWriteAt(offOut, int(totalRead))
 	fhOut.entry.Content = nil
-	fhOut.dirtyPages.AddPage(int64(in.OffOut), data, fhOut.dirtyPages.writerPattern.IsSequentialMode(), time.Now().UnixNano())
-	fhOut.entry.Attributes.FileSize = uint64(max(int64(in.OffOut)+totalRead, int64(fhOut.entry.Attributes.FileSize)))
+	fhOut.dirtyPages.AddPage(offOut, data, fhOut.dirtyPages.writerPattern.IsSequentialMode(), time.Now().UnixNano())
+	fhOut.entry.Attributes.FileSize = uint64(max(offOut+totalRead, int64(fhOut.entry.Attributes.FileSize)))
 	fhOut.dirtyMetadata = true
-	written = uint32(totalRead)
 
 	// detect mime type
-	if written > 0 && in.OffOut <= 512 {
+	if offOut <= 512 {
 		fhOut.contentType = http.DetectContentType(data)
 	}
 
-	return written, fuse.OK
+	return totalRead, fuse.OK
 }
@@ -0,0 +1,6 @@
+package mount
+
+// darwin has no O_DIRECT, and the uncached io is set by fcntl F_NOCACHE, which is not passed to the filesystem
+func isDirectIO(openFlags uint32) bool {
+	return false
+}
@@ -0,0 +1,9 @@
+package mount
+
+import (
+	"syscall"
+)
+
+func isDirectIO(openFlags uint32) bool {
+	return openFlags&syscall.O_DIRECT != 0
+}
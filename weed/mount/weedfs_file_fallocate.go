@@ -0,0 +1,135 @@
+package mount
+
+import (
+	"math"
+	"strconv"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	FALLOC_FL_KEEP_SIZE  = 0x01 // allocate without changing the file size
+	FALLOC_FL_PUNCH_HOLE = 0x02 // deallocate the range, together with FALLOC_FL_KEEP_SIZE
+	FALLOC_FL_ZERO_RANGE = 0x10 // zero the range
+)
+
+// https://github.com/libfuse/libfuse/blob/48ae2e72b39b6a31cb2194f6f11786b7ca06aac6/include/fuse.h#L778
+
+/**
+ * Allocates space for an open file
+ *
+ * This function ensures that required space is allocated for specified
+ * file.  If this function returns success then any subsequent write
+ * request to specified range is guaranteed not to fail because of lack
+ * of space on the file system media.
+ */
+func (wfs *WFS) Fallocate(cancel <-chan struct{}, in *fuse.FallocateIn) (code fuse.Status) {
+
+	if in.Mode&^(FALLOC_FL_KEEP_SIZE|FALLOC_FL_PUNCH_HOLE|FALLOC_FL_ZERO_RANGE) != 0 {
+		return fuse.Status(syscall.EOPNOTSUPP)
+	}
+	isPunchHole := in.Mode&FALLOC_FL_PUNCH_HOLE != 0
+	isZeroRange := in.Mode&FALLOC_FL_ZERO_RANGE != 0
+	keepSize := in.Mode&FALLOC_FL_KEEP_SIZE != 0
+	if isPunchHole && (isZeroRange || !keepSize) {
+		return fuse.Status(syscall.EOPNOTSUPP)
+	}
+	start, stop := int64(in.Offset), int64(in.Offset+in.Length)
+	if start < 0 || in.Length == 0 || stop <= start {
+		return fuse.EINVAL
+	}
+	if !isPunchHole && wfs.IsOverQuota {
+		return fuse.Status(syscall.ENOSPC)
+	}
+
+	fh := wfs.GetHandle(FileHandleId(in.Fh))
+	if fh == nil {
+		return fuse.EBADF
+	}
+
+	// the written data is flushed as chunks first, so the chunks can be trimmed
+	if code = wfs.doFlush(fh, in.Uid, in.Gid); code != fuse.OK {
+		return code
+	}
+
+	fhActiveLock := fh.wfs.fhLockTable.AcquireLock("Fallocate", fh.fh, util.ExclusiveLock)
+	entry := fh.GetEntry().GetEntry()
+	if entry == nil || entry.IsDirectory {
+		fh.wfs.fhLockTable.ReleaseLock(fh.fh, fhActiveLock)
+		return fuse.EISDIR
+	}
+	glog.V(4).Infof("Fallocate %s fh %d [%d,%d) mode %x", fh.FullPath(), fh.fh, start, stop, in.Mode)
+
+	saved := proto.Clone(entry).(*filer_pb.Entry)
+	if isPunchHole || isZeroRange {
+		code = wfs.punchHole(fh, start, stop)
+	}
+	if code == fuse.OK && !isPunchHole {
+		fh.UpdateEntry(func(entry *filer_pb.Entry) {
+			allocateRange(entry, stop, keepSize)
+		})
+	}
+	fh.dirtyMetadata = true
+	fh.wfs.fhLockTable.ReleaseLock(fh.fh, fhActiveLock)
+
+	if code == fuse.OK {
+		// the preallocated space is charged to the quota right away
+		code = wfs.doFlush(fh, in.Uid, in.Gid)
+	}
+	if code != fuse.OK {
+		fhActiveLock = fh.wfs.fhLockTable.AcquireLock("Fallocate", fh.fh, util.ExclusiveLock)
+		fh.SetEntry(saved)
+		fh.dirtyMetadata = false
+		fh.wfs.fhLockTable.ReleaseLock(fh.fh, fhActiveLock)
+	}
+	return code
+}
+
+// punchHole removes the data in the range, which then reads as zeros
+func (wfs *WFS) punchHole(fh *FileHandle, start, stop int64) fuse.Status {
+	entry := fh.GetEntry()
+
+	dataChunks, _, err := filer.ResolveChunkManifest(wfs.LookupFn(), entry.GetChunks(), 0, math.MaxInt64)
+	if err != nil {
+		glog.Errorf("punch hole %s: %v", fh.FullPath(), err)
+		return fuse.EIO
+	}
+	chunks, changed := filer.PunchHole(dataChunks, start, stop)
+
+	fh.UpdateEntry(func(entry *filer_pb.Entry) {
+		if start < int64(len(entry.Content)) {
+			content := append([]byte{}, entry.Content...)
+			clear(content[start:min(stop, int64(len(content)))])
+			entry.Content = content
+		}
+		if changed {
+			// the chunk manifests are created again when flushing
+			entry.Chunks = chunks
+		}
+	})
+	if changed {
+		fh.entryChunkGroup.SetChunks(chunks)
+	}
+	return fuse.OK
+}
+
+// allocateRange extends the file size unless keepSize, and reserves the space in the quota
+func allocateRange(entry *filer_pb.Entry, stop int64, keepSize bool) {
+	if !keepSize && uint64(stop) > entry.Attributes.FileSize {
+		entry.Attributes.FileSize = uint64(stop)
+	}
+	if stop <= max(int64(filer.FileSize(entry)), filer.AllocatedSize(entry.Extended)) {
+		return
+	}
+	if entry.Extended == nil {
+		entry.Extended = make(map[string][]byte)
+	}
+	entry.Extended[filer.ExtAllocatedSizeKey] = []byte(strconv.FormatInt(stop, 10))
+}
@@ -61,11 +61,24 @@ import (
 	 * @param fi file information
 */
 func (wfs *WFS) Open(cancel <-chan struct{}, in *fuse.OpenIn, out *fuse.OpenOut) (status fuse.Status) {
+	if wfs.option.isCloseToOpen() {
+		if status = wfs.revalidateEntry(in.NodeId); status != fuse.OK {
+			return status
+		}
+	}
 	var fileHandle *FileHandle
-	fileHandle, status = wfs.AcquireHandle(in.NodeId, in.Flags, in.Uid, in.Gid)
+	fileHandle, status = wfs.AcquireHandle(in.NodeId, in.Flags, &in.Caller)
 	if status == fuse.OK {
 		out.Fh = uint64(fileHandle.fh)
 		out.OpenFlags = in.Flags
+		if isDirectIO(in.Flags) {
+			// the kernel passes the reads and writes through, without the page cache
+			out.OpenFlags |= fuse.FOPEN_DIRECT_IO
+		}
+		if wfs.option.isCloseToOpen() {
+			// the kernel drops the cached pages on open
+			out.OpenFlags &^= fuse.FOPEN_KEEP_CACHE
+		}
 		// TODO https://github.com/libfuse/libfuse/blob/master/include/fuse_common.h#L64
 	}
 	return status
@@ -97,5 +110,6 @@ func (wfs *WFS) Open(cancel <-chan struct{}, in *fuse.OpenIn, out *fuse.OpenOut)
  * @param fi file information
  */
 func (wfs *WFS) Release(cancel <-chan struct{}, in *fuse.ReleaseIn) {
+	wfs.releaseFlock(in)
 	wfs.ReleaseHandle(FileHandleId(in.Fh))
 }
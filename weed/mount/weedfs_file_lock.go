@@ -0,0 +1,352 @@
+package mount
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster/lock_manager"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+// posix record locks and flock are kept as byte range locks by the distributed lock manager of the filers,
+// so the processes on all mounts see the same locks. The locks of a mount are leased together,
+// and are released if the mount stops renewing them.
+// The locks are keyed by the file, not by its path, so renaming a locked file keeps its locks.
+const (
+	posixLockPrefix     = "mount.posix:"
+	flockPrefix         = "mount.flock:"
+	fileLockWaitInitial = 10 * time.Millisecond
+	fileLockWaitMax     = time.Second
+)
+
+type fileLocks struct {
+	sync.Mutex
+	session    string
+	generation uint64
+	names      map[string]uint64 // the files possibly locked by this mount => the generation of the last lock
+	keys       map[uint64]string // inode => lock key, kept while the file is locked, also after it is unlinked
+}
+
+func newFileLocks() *fileLocks {
+	return &fileLocks{
+		session: uuid.New().String(),
+		names:   make(map[string]uint64),
+		keys:    make(map[uint64]string),
+	}
+}
+
+func (fl *fileLocks) add(inode uint64, key, name string) {
+	fl.Lock()
+	defer fl.Unlock()
+	fl.generation++
+	fl.names[name] = fl.generation
+	fl.keys[inode] = key
+}
+
+func (fl *fileLocks) remove(name string) {
+	fl.Lock()
+	defer fl.Unlock()
+	delete(fl.names, name)
+	fl.removeUnlockedKeys()
+}
+
+// lose forgets the locks the filer no longer holds for this mount, unless the file is locked again
+// after the names were listed. It returns the inodes of the files whose locks are lost.
+func (fl *fileLocks) lose(names []string, listedGeneration uint64) (inodes []uint64) {
+	fl.Lock()
+	defer fl.Unlock()
+	lost := make(map[string]bool)
+	for _, name := range names {
+		if generation, found := fl.names[name]; found && generation <= listedGeneration {
+			delete(fl.names, name)
+			lost[name] = true
+		}
+	}
+	for inode, key := range fl.keys {
+		if lost[posixLockPrefix+key] || lost[flockPrefix+key] {
+			inodes = append(inodes, inode)
+		}
+	}
+	fl.removeUnlockedKeys()
+	return
+}
+
+// removeUnlockedKeys must be called with the lock held
+func (fl *fileLocks) removeUnlockedKeys() {
+	for inode, key := range fl.keys {
+		_, hasPosix := fl.names[posixLockPrefix+key]
+		_, hasFlock := fl.names[flockPrefix+key]
+		if !hasPosix && !hasFlock {
+			delete(fl.keys, inode)
+		}
+	}
+}
+
+func (fl *fileLocks) getKey(inode uint64) (key string, found bool) {
+	fl.Lock()
+	defer fl.Unlock()
+	key, found = fl.keys[inode]
+	return
+}
+
+func (fl *fileLocks) has(name string) bool {
+	fl.Lock()
+	defer fl.Unlock()
+	_, found := fl.names[name]
+	return found
+}
+
+func (fl *fileLocks) list() (names []string, generation uint64) {
+	fl.Lock()
+	defer fl.Unlock()
+	for name := range fl.names {
+		names = append(names, name)
+	}
+	return names, fl.generation
+}
+
+func (wfs *WFS) fileLockName(nodeId uint64, isFlock bool) (string, fuse.Status) {
+	key, status := wfs.fileLockKey(nodeId)
+	if status != fuse.OK {
+		return "", status
+	}
+	if isFlock {
+		return flockPrefix + key, fuse.OK
+	}
+	return posixLockPrefix + key, fuse.OK
+}
+
+// fileLockKey is kept for the inode while it is locked, so the locks are released after the file is unlinked
+func (wfs *WFS) fileLockKey(nodeId uint64) (string, fuse.Status) {
+	if key, found := wfs.fileLocks.getKey(nodeId); found {
+		return key, fuse.OK
+	}
+	path, _, entry, status := wfs.maybeReadEntry(nodeId)
+	if status != fuse.OK {
+		return "", status
+	}
+	if entry == nil {
+		return "", fuse.ENOENT
+	}
+	return entry.LockKey(path), fuse.OK
+}
+
+func (wfs *WFS) GetLk(cancel <-chan struct{}, in *fuse.LkIn, out *fuse.LkOut) (code fuse.Status) {
+	name, status := wfs.fileLockName(in.NodeId, in.LkFlags&fuse.FUSE_LK_FLOCK != 0)
+	if status != fuse.OK {
+		return status
+	}
+
+	conflict, err := wfs.tryRangeLock(name, in, true)
+	if err != nil {
+		glog.Errorf("test lock %s: %v", name, err)
+		return fuse.EIO
+	}
+	if conflict == nil {
+		out.Lk = in.Lk
+		out.Lk.Typ = syscall.F_UNLCK
+		return fuse.OK
+	}
+	out.Lk = fuse.FileLock{
+		Start: conflict.Start,
+		End:   conflict.End,
+		Typ:   syscall.F_RDLCK,
+		Pid:   conflict.Pid,
+	}
+	if conflict.IsExclusive {
+		out.Lk.Typ = syscall.F_WRLCK
+	}
+	return fuse.OK
+}
+
+func (wfs *WFS) SetLk(cancel <-chan struct{}, in *fuse.LkIn) (code fuse.Status) {
+	return wfs.setLk(cancel, in, false)
+}
+
+func (wfs *WFS) SetLkw(cancel <-chan struct{}, in *fuse.LkIn) (code fuse.Status) {
+	return wfs.setLk(cancel, in, true)
+}
+
+func (wfs *WFS) setLk(cancel <-chan struct{}, in *fuse.LkIn, wait bool) fuse.Status {
+	key, status := wfs.fileLockKey(in.NodeId)
+	if status != fuse.OK {
+		return status
+	}
+	name := posixLockPrefix + key
+	if in.LkFlags&fuse.FUSE_LK_FLOCK != 0 {
+		name = flockPrefix + key
+	}
+
+	if in.Lk.Typ == syscall.F_UNLCK {
+		if err := wfs.rangeUnlock(name, in.Owner, false, in.Lk.Start, in.Lk.End); err != nil {
+			glog.Errorf("unlock %s: %v", name, err)
+			return fuse.EIO
+		}
+		return fuse.OK
+	}
+	if in.Lk.Typ != syscall.F_RDLCK && in.Lk.Typ != syscall.F_WRLCK {
+		return fuse.EINVAL
+	}
+
+	waitTime := fileLockWaitInitial
+	for {
+		conflict, err := wfs.tryRangeLock(name, in, false)
+		if err != nil {
+			glog.Errorf("lock %s: %v", name, err)
+			return fuse.EIO
+		}
+		if conflict == nil {
+			wfs.fileLocks.add(in.NodeId, key, name)
+			return fuse.OK
+		}
+		if !wait {
+			return fuse.EAGAIN
+		}
+		select {
+		case <-cancel:
+			return fuse.EINTR
+		case <-time.After(waitTime):
+		}
+		if waitTime *= 2; waitTime > fileLockWaitMax {
+			waitTime = fileLockWaitMax
+		}
+	}
+}
+
+func (wfs *WFS) tryRangeLock(name string, in *fuse.LkIn, isTest bool) (conflict *filer_pb.RangeLock, err error) {
+	err = wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.DistributedRangeLock(context.Background(), &filer_pb.RangeLockRequest{
+			Name: name,
+			Lock: &filer_pb.RangeLock{
+				Session:     wfs.fileLocks.session,
+				Owner:       in.Owner,
+				Pid:         in.Lk.Pid,
+				Start:       in.Lk.Start,
+				End:         in.Lk.End,
+				IsExclusive: in.Lk.Typ == syscall.F_WRLCK,
+			},
+			SecondsToLock: int64(lock_manager.LiveLockTTL.Seconds()),
+			IsTest:        isTest,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("%s", resp.Error)
+		}
+		conflict = resp.Conflict
+		return nil
+	})
+	return
+}
+
+func (wfs *WFS) rangeUnlock(name string, owner uint64, allOwners bool, start, end uint64) error {
+	return wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.DistributedRangeUnlock(context.Background(), &filer_pb.RangeUnlockRequest{
+			Name:      name,
+			Session:   wfs.fileLocks.session,
+			Owner:     owner,
+			AllOwners: allOwners,
+			Start:     start,
+			End:       end,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("%s", resp.Error)
+		}
+		if !resp.SessionHasLocks {
+			wfs.fileLocks.remove(name)
+		}
+		return nil
+	})
+}
+
+// releasePosixLocks releases the posix locks of the lock owner when any of its file descriptors is closed
+func (wfs *WFS) releasePosixLocks(nodeId uint64, owner uint64) {
+	if !wfs.option.EnableLocks {
+		return
+	}
+	name, status := wfs.fileLockName(nodeId, false)
+	if status != fuse.OK || !wfs.fileLocks.has(name) {
+		return
+	}
+	if err := wfs.rangeUnlock(name, owner, false, 0, math.MaxUint64); err != nil {
+		glog.Errorf("release posix locks on %s: %v", name, err)
+	}
+}
+
+// releaseFlock releases the flock when the last file descriptor of the open file is closed
+func (wfs *WFS) releaseFlock(in *fuse.ReleaseIn) {
+	if !wfs.option.EnableLocks || in.ReleaseFlags&fuse.FUSE_RELEASE_FLOCK_UNLOCK == 0 {
+		return
+	}
+	name, status := wfs.fileLockName(in.NodeId, true)
+	if status != fuse.OK || !wfs.fileLocks.has(name) {
+		return
+	}
+	if err := wfs.rangeUnlock(name, in.LockOwner, false, 0, math.MaxUint64); err != nil {
+		glog.Errorf("release flock on %s: %v", name, err)
+	}
+}
+
+// loopRenewFileLocks keeps the lease of the locks of this mount
+func (wfs *WFS) loopRenewFileLocks() {
+	for {
+		time.Sleep(lock_manager.RenewInterval)
+		names, generation := wfs.fileLocks.list()
+		if len(names) == 0 {
+			continue
+		}
+		if err := wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+			resp, err := client.RenewRangeLocks(context.Background(), &filer_pb.RenewRangeLocksRequest{
+				Names:         names,
+				Session:       wfs.fileLocks.session,
+				SecondsToLock: int64(lock_manager.LiveLockTTL.Seconds()),
+			})
+			if err != nil {
+				return err
+			}
+			wfs.loseFileLocks(resp.LostNames, generation)
+			if resp.Error != "" {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			return nil
+		}); err != nil {
+			glog.Errorf("renew %d file locks: %v", len(names), err)
+		}
+	}
+}
+
+// loseFileLocks fails the i/o of the open files whose locks expired or were taken by others,
+// so the lock holders do not keep writing as if they still held the locks
+func (wfs *WFS) loseFileLocks(names []string, listedGeneration uint64) {
+	if len(names) == 0 {
+		return
+	}
+	for _, inode := range wfs.fileLocks.lose(names, listedGeneration) {
+		if fh, found := wfs.fhMap.FindFileHandle(inode); found {
+			fh.isLockLost.Store(true)
+		}
+	}
+	glog.Warningf("lost %d file locks: %v", len(names), names)
+}
+
+// releaseAllFileLocks releases the locks of this mount when unmounting
+func (wfs *WFS) releaseAllFileLocks() {
+	names, _ := wfs.fileLocks.list()
+	for _, name := range names {
+		if err := wfs.rangeUnlock(name, 0, true, 0, math.MaxUint64); err != nil {
+			glog.Warningf("release file locks on %s: %v", name, err)
+		}
+	}
+}
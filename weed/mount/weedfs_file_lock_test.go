@@ -0,0 +1,30 @@
+package mount
+
+import "testing"
+
+func TestFileLocksLose(t *testing.T) {
+	fl := newFileLocks()
+	fl.add(1, "k1", posixLockPrefix+"k1")
+	fl.add(2, "k2", flockPrefix+"k2")
+	names, generation := fl.list()
+	if len(names) != 2 {
+		t.Fatalf("listed %v", names)
+	}
+
+	// k2 is locked again while the locks are being renewed
+	fl.add(2, "k2", flockPrefix+"k2")
+
+	inodes := fl.lose([]string{posixLockPrefix + "k1", flockPrefix + "k2"}, generation)
+	if len(inodes) != 1 || inodes[0] != 1 {
+		t.Errorf("lost the locks of %v", inodes)
+	}
+	if fl.has(posixLockPrefix + "k1") {
+		t.Errorf("the lost lock is still renewed")
+	}
+	if _, found := fl.getKey(1); found {
+		t.Errorf("the lock key of an unlocked file is kept")
+	}
+	if !fl.has(flockPrefix + "k2") {
+		t.Errorf("the lock taken after the renewal started is lost")
+	}
+}
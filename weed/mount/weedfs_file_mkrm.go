@@ -50,6 +50,10 @@ func (wfs *WFS) Mknod(cancel <-chan struct{}, in *fuse.MknodIn, name string, out
 		return
 	}
 
+	if code = wfs.checkDirAclAccess(&in.Caller, dirFullPath); code != fuse.OK {
+		return
+	}
+
 	entryFullPath := dirFullPath.Child(name)
 	fileMode := toOsFileMode(in.Mode)
 	now := time.Now().Unix()
@@ -69,36 +73,41 @@ func (wfs *WFS) Mknod(cancel <-chan struct{}, in *fuse.MknodIn, name string, out
 			Inode:    inode,
 		},
 	}
-
-	err := wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
-
-		wfs.mapPbIdFromLocalToFiler(newEntry)
-		defer wfs.mapPbIdFromFilerToLocal(newEntry)
-
-		request := &filer_pb.CreateEntryRequest{
-			Directory:                string(dirFullPath),
-			Entry:                    newEntry,
-			Signatures:               []int32{wfs.signature},
-			SkipCheckParentDirectory: true,
-		}
-
-		glog.V(1).Infof("mknod: %v", request)
-		if err := filer_pb.CreateEntry(client, request); err != nil {
-			glog.V(0).Infof("mknod %s: %v", entryFullPath, err)
-			return err
-		}
-
-		if err := wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry)); err != nil {
-			return fmt.Errorf("local mknod %s: %v", entryFullPath, err)
-		}
-
-		return nil
+	wfs.inheritAcl(dirFullPath, newEntry)
+
+	err := wfs.withFilerOrJournal(func() error {
+		return wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+
+			wfs.mapPbIdFromLocalToFiler(newEntry)
+			defer wfs.mapPbIdFromFilerToLocal(newEntry)
+
+			request := &filer_pb.CreateEntryRequest{
+				Directory:                string(dirFullPath),
+				Entry:                    newEntry,
+				Signatures:               []int32{wfs.signature},
+				SkipCheckParentDirectory: true,
+			}
+
+			glog.V(1).Infof("mknod: %v", request)
+			if err := filer_pb.CreateEntry(client, request); err != nil {
+				glog.V(0).Infof("mknod %s: %v", entryFullPath, err)
+				return err
+			}
+
+			if err := wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry)); err != nil {
+				return fmt.Errorf("local mknod %s: %v", entryFullPath, err)
+			}
+
+			return nil
+		})
+	}, func() (bool, error) {
+		return wfs.journalEntry(dirFullPath, newEntry)
 	})
 
 	glog.V(3).Infof("mknod %s: %v", entryFullPath, err)
 
 	if err != nil {
-		return fuse.EIO
+		return quotaAwareStatus(err)
 	}
 
 	// this is to increase nlookup counter
@@ -134,10 +143,18 @@ func (wfs *WFS) Unlink(cancel <-chan struct{}, header *fuse.InHeader, name strin
 		return fuse.EPERM
 	}
 
+	if code = wfs.checkDirAclAccess(&header.Caller, dirFullPath); code != fuse.OK {
+		return
+	}
+
 	// first, ensure the filer store can correctly delete
 	glog.V(3).Infof("remove file: %v", entryFullPath)
 	isDeleteData := entry != nil && entry.HardLinkCounter <= 1
-	err := filer_pb.Remove(wfs, string(dirFullPath), name, isDeleteData, false, false, false, []int32{wfs.signature})
+	err := wfs.withFilerOrJournal(func() error {
+		return filer_pb.Remove(wfs, string(dirFullPath), name, isDeleteData, false, false, false, []int32{wfs.signature})
+	}, func() (bool, error) {
+		return wfs.journalDeleteEntry(dirFullPath, name, isDeleteData)
+	})
 	if err != nil {
 		glog.V(0).Infof("remove %s: %v", entryFullPath, err)
 		return fuse.OK
@@ -41,11 +41,25 @@ func (wfs *WFS) Read(cancel <-chan struct{}, in *fuse.ReadIn, buff []byte) (fuse
 	if fh == nil {
 		return nil, fuse.ENOENT
 	}
+	if fh.isLockLost.Load() {
+		return nil, fuse.EIO
+	}
 
 	fhActiveLock := fh.wfs.fhLockTable.AcquireLock("Read", fh.fh, util.SharedLock)
 	defer fh.wfs.fhLockTable.ReleaseLock(fh.fh, fhActiveLock)
 
 	offset := int64(in.Offset)
+
+	if wfs.option.ZeroCopyRead && !IsDebugFileReadWrite && fh.dirtyPages.DirtySize() == 0 {
+		if ref, found := fh.locateInChunks(offset, len(buff)); found {
+			if ref.Data != nil {
+				return fuse.ReadResultData(ref.Data), fuse.OK
+			}
+			// spliced from the cache file to the fuse device, or read from the cache file if splice is unavailable
+			return fuse.ReadResultFd(ref.Fd, ref.FdOffset, ref.Size), fuse.OK
+		}
+	}
+
 	totalRead, err := readDataByFileHandle(buff, fh, offset)
 	if err != nil {
 		glog.Warningf("file handle read %s %d: %v", fh.FullPath(), totalRead, err)
@@ -57,6 +57,9 @@ func (wfs *WFS) Flush(cancel <-chan struct{}, in *fuse.FlushIn) fuse.Status {
 		return fuse.ENOENT
 	}
 
+	// close() releases the posix locks of the process on the file
+	wfs.releasePosixLocks(in.NodeId, in.LockOwner)
+
 	return wfs.doFlush(fh, in.Uid, in.Gid)
 }
 
@@ -101,7 +104,7 @@ func (wfs *WFS) doFlush(fh *FileHandle, uid, gid uint32) fuse.Status {
 	if !wfs.IsOverQuota {
 		if err := fh.dirtyPages.FlushData(); err != nil {
 			glog.Errorf("%v doFlush: %v", fileFullPath, err)
-			return fuse.EIO
+			return quotaAwareStatus(err)
 		}
 	}
 
@@ -116,21 +119,55 @@ func (wfs *WFS) doFlush(fh *FileHandle, uid, gid uint32) fuse.Status {
 	fhActiveLock := fh.wfs.fhLockTable.AcquireLock("doFlush", fh.fh, util.ExclusiveLock)
 	defer fh.wfs.fhLockTable.ReleaseLock(fh.fh, fhActiveLock)
 
-	err := wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+	entry := fh.GetEntry()
+	entry.Name = name // this flush may be just after a rename operation
+
+	if entry.Attributes != nil {
+		entry.Attributes.Mime = fh.contentType
+		if entry.Attributes.Uid == 0 {
+			entry.Attributes.Uid = uid
+		}
+		if entry.Attributes.Gid == 0 {
+			entry.Attributes.Gid = gid
+		}
+		entry.Attributes.Mtime = time.Now().Unix()
+	}
+
+	err := wfs.withFilerOrJournal(func() error {
+		return wfs.flushEntry(fh, entry)
+	}, func() (bool, error) {
+		return wfs.journalEntry(util.FullPath(dir), entry.GetEntry())
+	})
+
+	if err == nil {
+		fh.dirtyMetadata = false
+	}
+
+	if err != nil {
+		glog.Errorf("%v fh %d flush: %v", fileFullPath, fh.fh, err)
+		return quotaAwareStatus(err)
+	}
 
-		entry := fh.GetEntry()
-		entry.Name = name // this flush may be just after a rename operation
+	if IsDebugFileReadWrite {
+		fh.mirrorFile.Sync()
+	}
 
-		if entry.Attributes != nil {
-			entry.Attributes.Mime = fh.contentType
-			if entry.Attributes.Uid == 0 {
-				entry.Attributes.Uid = uid
-			}
-			if entry.Attributes.Gid == 0 {
-				entry.Attributes.Gid = gid
-			}
-			entry.Attributes.Mtime = time.Now().Unix()
+	return fuse.OK
+}
+
+func (wfs *WFS) flushEntry(fh *FileHandle, entry *LockedEntry) error {
+
+	fileFullPath := fh.FullPath()
+	dir, _ := fileFullPath.DirAndName()
+
+	return wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+
+		// the data written while offline is uploaded first
+		uploadedChunks, err := wfs.uploadJournalChunks(fileFullPath, entry.GetChunks())
+		if err != nil {
+			return fmt.Errorf("upload journaled data of %s: %v", fileFullPath, err)
 		}
+		entry.Chunks = uploadedChunks
 
 		request := &filer_pb.CreateEntryRequest{
 			Directory:                string(dir),
@@ -166,19 +203,4 @@ func (wfs *WFS) doFlush(fh *FileHandle, uid, gid uint32) fuse.Status {
 
 		return nil
 	})
-
-	if err == nil {
-		fh.dirtyMetadata = false
-	}
-
-	if err != nil {
-		glog.Errorf("%v fh %d flush: %v", fileFullPath, fh.fh, err)
-		return fuse.EIO
-	}
-
-	if IsDebugFileReadWrite {
-		fh.mirrorFile.Sync()
-	}
-
-	return fuse.OK
 }
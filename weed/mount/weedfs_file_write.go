@@ -2,6 +2,7 @@ package mount
 
 import (
 	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 	"net/http"
 	"syscall"
@@ -44,6 +45,9 @@ func (wfs *WFS) Write(cancel <-chan struct{}, in *fuse.WriteIn, data []byte) (wr
 	if fh == nil {
 		return 0, fuse.ENOENT
 	}
+	if fh.isLockLost.Load() {
+		return 0, fuse.EIO
+	}
 
 	fh.dirtyPages.writerPattern.MonitorWriteAt(int64(in.Offset), int(in.Size))
 
@@ -57,12 +61,22 @@ func (wfs *WFS) Write(cancel <-chan struct{}, in *fuse.WriteIn, data []byte) (wr
 		return 0, fuse.OK
 	}
 
-	entry.Content = nil
 	offset := int64(in.Offset)
+	if isDirectIO(in.Flags) {
+		// O_DIRECT bypasses the dirty pages, and the data is uploaded before returning
+		if err := fh.writeDirect(data, offset, tsNs); err != nil {
+			glog.Warningf("direct write %s [%d,%d): %v", fh.FullPath(), offset, offset+int64(len(data)), err)
+			return 0, fuse.EIO
+		}
+	}
+
+	entry.Content = nil
 	entry.Attributes.FileSize = uint64(max(offset+int64(len(data)), int64(entry.Attributes.FileSize)))
 	// glog.V(4).Infof("%v write [%d,%d) %d", fh.f.fullpath(), req.Offset, req.Offset+int64(len(req.Data)), len(req.Data))
 
-	fh.dirtyPages.AddPage(offset, data, fh.dirtyPages.writerPattern.IsSequentialMode(), tsNs)
+	if !isDirectIO(in.Flags) {
+		fh.dirtyPages.AddPage(offset, data, fh.dirtyPages.writerPattern.IsSequentialMode(), tsNs)
+	}
 
 	written = uint32(len(data))
 
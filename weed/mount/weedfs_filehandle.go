@@ -6,7 +6,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
-func (wfs *WFS) AcquireHandle(inode uint64, flags, uid, gid uint32) (fileHandle *FileHandle, status fuse.Status) {
+func (wfs *WFS) AcquireHandle(inode uint64, flags uint32, caller *fuse.Caller) (fileHandle *FileHandle, status fuse.Status) {
 	var entry *filer_pb.Entry
 	var path util.FullPath
 	path, _, entry, status = wfs.maybeReadEntry(inode)
@@ -14,6 +14,9 @@ func (wfs *WFS) AcquireHandle(inode uint64, flags, uid, gid uint32) (fileHandle
 		if wfs.wormEnabledForEntry(path, entry) && flags&fuse.O_ANYWRITE != 0 {
 			return nil, fuse.EPERM
 		}
+		if status = wfs.checkAclAccess(caller, entry, openFlagsToAccess(flags)); status != fuse.OK {
+			return nil, status
+		}
 		// need to AcquireFileHandle again to ensure correct handle counter
 		fileHandle = wfs.fhMap.AcquireFileHandle(wfs, inode, entry)
 	}
@@ -63,6 +63,9 @@ Side effects: increments the lookup count on success
 */
 func (wfs *WFS) Forget(nodeid, nlookup uint64) {
 	wfs.inodeToPath.Forget(nodeid, nlookup, func(dir util.FullPath) {
+		if wfs.pins.isLoaded(dir) {
+			return
+		}
 		wfs.metaCache.DeleteFolderChildren(context.Background(), dir)
 	})
 	wfs.fhMap.ReleaseByInode(nodeid)
@@ -3,8 +3,13 @@ package mount
 import (
 	"context"
 	"fmt"
+	"strings"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/pb/mount_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 func (wfs *WFS) Configure(ctx context.Context, request *mount_pb.ConfigureRequest) (*mount_pb.ConfigureResponse, error) {
@@ -15,3 +20,222 @@ func (wfs *WFS) Configure(ctx context.Context, request *mount_pb.ConfigureReques
 	wfs.option.Quota = request.GetCollectionCapacity()
 	return &mount_pb.ConfigureResponse{}, nil
 }
+
+// GetReaderStats reports the read pattern and the read ahead of the open files
+func (wfs *WFS) GetReaderStats(ctx context.Context, request *mount_pb.GetReaderStatsRequest) (*mount_pb.GetReaderStatsResponse, error) {
+	resp := &mount_pb.GetReaderStatsResponse{}
+	for _, fh := range wfs.openFileHandles() {
+		fullPath := fh.FullPath()
+		if !wfs.isUnderRequestPath(fullPath, request.PathPrefix) {
+			continue
+		}
+		fh.entryLock.RLock()
+		chunkGroup := fh.entryChunkGroup
+		fh.entryLock.RUnlock()
+		if chunkGroup == nil {
+			continue
+		}
+		stats := chunkGroup.ReaderStats()
+		resp.Handles = append(resp.Handles, &mount_pb.ReaderStats{
+			HandleId:         uint64(fh.fh),
+			Inode:            fh.inode,
+			Path:             wfs.relativePath(fullPath),
+			Reads:            stats.Reads,
+			SequentialReads:  stats.SequentialReads,
+			BytesRead:        stats.BytesRead,
+			ChunkHits:        stats.ChunkHits,
+			ChunkMisses:      stats.ChunkMisses,
+			PrefetchedChunks: stats.PrefetchedChunks,
+			ReadAheadWindow:  int32(stats.ReadAheadWindow),
+			Throughput:       stats.Throughput,
+			IsRandomMode:     stats.IsRandomMode,
+		})
+	}
+	return resp, nil
+}
+
+func (wfs *WFS) ListHandles(ctx context.Context, request *mount_pb.ListHandlesRequest) (*mount_pb.ListHandlesResponse, error) {
+	resp := &mount_pb.ListHandlesResponse{}
+	for _, fh := range wfs.openFileHandles() {
+		fullPath := fh.FullPath()
+		if !wfs.isUnderRequestPath(fullPath, request.PathPrefix) {
+			continue
+		}
+		dirtyBytes := fh.dirtyPages.DirtySize()
+		resp.Handles = append(resp.Handles, &mount_pb.OpenHandle{
+			HandleId:      uint64(fh.fh),
+			Inode:         fh.inode,
+			Path:          wfs.relativePath(fullPath),
+			OpenCount:     fh.counter,
+			FileSize:      fh.GetEntry().GetEntry().GetAttributes().GetFileSize(),
+			DirtyBytes:    dirtyBytes,
+			DirtyMetadata: fh.dirtyMetadata,
+		})
+		resp.TotalDirtyBytes += dirtyBytes
+	}
+	return resp, nil
+}
+
+// FlushAll uploads the dirty data and saves the dirty metadata of the open files
+func (wfs *WFS) FlushAll(ctx context.Context, request *mount_pb.FlushAllRequest) (*mount_pb.FlushAllResponse, error) {
+	resp := &mount_pb.FlushAllResponse{}
+	for _, fh := range wfs.openFileHandles() {
+		fullPath := fh.FullPath()
+		if !wfs.isUnderRequestPath(fullPath, request.PathPrefix) {
+			continue
+		}
+		if status := wfs.doFlush(fh, 0, 0); status != fuse.OK {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("%s: %v", wfs.relativePath(fullPath), status))
+			continue
+		}
+		resp.Flushed++
+	}
+	glog.V(0).Infof("flushed %d open files, %d failed", resp.Flushed, len(resp.Errors))
+	return resp, nil
+}
+
+func (wfs *WFS) DropCaches(ctx context.Context, request *mount_pb.DropCachesRequest) (*mount_pb.DropCachesResponse, error) {
+	dirCount, chunkCount := wfs.dropCaches(wfs.filerPath(request.Path))
+	return &mount_pb.DropCachesResponse{
+		Directories: int32(dirCount),
+		Chunks:      int32(chunkCount),
+	}, nil
+}
+
+func (wfs *WFS) GetCacheStats(ctx context.Context, request *mount_pb.GetCacheStatsRequest) (*mount_pb.GetCacheStatsResponse, error) {
+	stats := wfs.chunkCache.Stats()
+	return &mount_pb.GetCacheStatsResponse{
+		MemoryHits:        stats.MemoryHits,
+		DiskHits:          stats.DiskHits,
+		Misses:            stats.Misses,
+		MemoryEntries:     int64(stats.MemoryEntries),
+		DiskUsedBytes:     stats.DiskUsedBytes,
+		DiskCapacityBytes: stats.DiskCapacityBytes,
+		OpenHandles:       int32(len(wfs.openFileHandles())),
+
+		PinnedHits:          stats.PinnedHits,
+		PinnedChunks:        int64(stats.PinnedChunks),
+		PinnedUsedBytes:     stats.PinnedUsedBytes,
+		PinnedCapacityBytes: stats.PinnedCapacityBytes,
+	}, nil
+}
+
+// Tune changes the concurrent writers and the chunk cache sizes of the running mount
+func (wfs *WFS) Tune(ctx context.Context, request *mount_pb.TuneRequest) (*mount_pb.TuneResponse, error) {
+	if request.CacheCapacityMb < 0 || request.ConcurrentWriters < 0 || request.PinnedCacheCapacityMb < 0 {
+		return nil, fmt.Errorf("invalid negative values %+v", request)
+	}
+	if (request.CacheCapacityMb > 0 || request.PinnedCacheCapacityMb > 0) && wfs.chunkCache == nil {
+		return nil, fmt.Errorf("the chunk cache is disabled, mount with a positive -cacheCapacityMB")
+	}
+	if request.ConcurrentWriters > 0 {
+		glog.V(0).Infof("concurrent writers changed from %d to %d", wfs.option.ConcurrentWriters, request.ConcurrentWriters)
+		wfs.option.ConcurrentWriters = int(request.ConcurrentWriters)
+		wfs.concurrentWriters.SetLimit(int(request.ConcurrentWriters))
+	}
+	if request.CacheCapacityMb > 0 {
+		glog.V(0).Infof("chunk cache capacity changed from %dMB to %dMB", wfs.option.CacheSizeMBForRead, request.CacheCapacityMb)
+		wfs.option.CacheSizeMBForRead = request.CacheCapacityMb
+		wfs.chunkCache.SetDiskCapacity(request.CacheCapacityMb)
+	}
+	if request.PinnedCacheCapacityMb > 0 {
+		glog.V(0).Infof("pinned cache capacity changed from %dMB to %dMB", wfs.option.CacheSizeMBForPin, request.PinnedCacheCapacityMb)
+		if err := wfs.chunkCache.SetPinnedCapacity(request.PinnedCacheCapacityMb); err != nil {
+			return nil, fmt.Errorf("set pinned cache capacity: %v", err)
+		}
+		wfs.option.CacheSizeMBForPin = request.PinnedCacheCapacityMb
+	}
+	return &mount_pb.TuneResponse{
+		ConcurrentWriters:     int32(wfs.option.ConcurrentWriters),
+		CacheCapacityMb:       wfs.option.CacheSizeMBForRead,
+		PinnedCacheCapacityMb: wfs.option.CacheSizeMBForPin,
+	}, nil
+}
+
+func (wfs *WFS) Pin(ctx context.Context, request *mount_pb.PinRequest) (*mount_pb.PinResponse, error) {
+	dir := wfs.filerPath(request.Path)
+	entry, status := wfs.maybeLoadEntry(dir)
+	if status != fuse.OK {
+		return nil, fmt.Errorf("pin %s: %v", request.Path, status)
+	}
+	if !entry.IsDirectory {
+		return nil, fmt.Errorf("pin %s: not a directory", request.Path)
+	}
+	dirCount, fileCount, err := wfs.pinDirectory(dir)
+	if err != nil {
+		return nil, fmt.Errorf("pin %s: %v", request.Path, err)
+	}
+	resp := &mount_pb.PinResponse{
+		Directories: int32(dirCount),
+		Files:       int32(fileCount),
+	}
+	if wfs.chunkCache == nil {
+		return resp, nil
+	}
+	if !request.WaitForWarming {
+		go wfs.warmPinnedDirectory(dir)
+		return resp, nil
+	}
+	chunkCount, byteCount, err := wfs.warmPinnedDirectory(dir)
+	if err != nil {
+		return nil, fmt.Errorf("warm %s: %v", request.Path, err)
+	}
+	resp.Chunks, resp.Bytes = int32(chunkCount), byteCount
+	return resp, nil
+}
+
+func (wfs *WFS) Unpin(ctx context.Context, request *mount_pb.UnpinRequest) (*mount_pb.UnpinResponse, error) {
+	if !wfs.unpinDirectory(wfs.filerPath(request.Path)) {
+		return nil, fmt.Errorf("%s is not pinned", request.Path)
+	}
+	return &mount_pb.UnpinResponse{}, nil
+}
+
+func (wfs *WFS) ListPins(ctx context.Context, request *mount_pb.ListPinsRequest) (*mount_pb.ListPinsResponse, error) {
+	resp := &mount_pb.ListPinsResponse{}
+	for _, dir := range wfs.pins.list() {
+		resp.Paths = append(resp.Paths, wfs.relativePath(dir))
+		warming, chunkCount, byteCount, err := wfs.pins.status(dir)
+		pinned := &mount_pb.PinnedDirectory{
+			Path:    wfs.relativePath(dir),
+			Warming: warming,
+			Chunks:  int32(chunkCount),
+			Bytes:   byteCount,
+		}
+		if err != nil {
+			pinned.Error = err.Error()
+		}
+		resp.Pins = append(resp.Pins, pinned)
+	}
+	return resp, nil
+}
+
+func (wfs *WFS) openFileHandles() []*FileHandle {
+	wfs.fhMap.RLock()
+	defer wfs.fhMap.RUnlock()
+	fileHandles := make([]*FileHandle, 0, len(wfs.fhMap.inode2fh))
+	for _, fh := range wfs.fhMap.inode2fh {
+		fileHandles = append(fileHandles, fh)
+	}
+	return fileHandles
+}
+
+// relativePath converts the filer path to the path relative to the mount directory
+func (wfs *WFS) relativePath(fullPath util.FullPath) string {
+	if wfs.option.FilerMountRootPath == "/" {
+		return string(fullPath)
+	}
+	relative := strings.TrimPrefix(string(fullPath), wfs.option.FilerMountRootPath)
+	if relative == "" {
+		return "/"
+	}
+	return relative
+}
+
+func (wfs *WFS) isUnderRequestPath(fullPath util.FullPath, requestPath string) bool {
+	if requestPath == "" {
+		return true
+	}
+	dir := wfs.filerPath(requestPath)
+	return fullPath == dir || fullPath.IsUnder(dir)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 /*
@@ -53,6 +54,10 @@ func (wfs *WFS) Link(cancel <-chan struct{}, in *fuse.LinkIn, name string, out *
 		return fuse.EPERM
 	}
 
+	if status = wfs.checkDirAclAccess(&in.Caller, newParentPath); status != fuse.OK {
+		return status
+	}
+
 	// update old file to hardlink mode
 	if len(oldEntry.HardLinkId) == 0 {
 		oldEntry.HardLinkId = filer.NewHardLinkId()
@@ -83,30 +88,37 @@ func (wfs *WFS) Link(cancel <-chan struct{}, in *fuse.LinkIn, name string, out *
 	}
 
 	// apply changes to the filer, and also apply to local metaCache
-	err := wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+	err := wfs.withFilerOrJournal(func() error {
+		return wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
 
-		wfs.mapPbIdFromLocalToFiler(request.Entry)
-		defer wfs.mapPbIdFromFilerToLocal(request.Entry)
+			wfs.mapPbIdFromLocalToFiler(request.Entry)
+			defer wfs.mapPbIdFromFilerToLocal(request.Entry)
 
-		if err := filer_pb.UpdateEntry(client, updateOldEntryRequest); err != nil {
-			return err
-		}
-		wfs.metaCache.UpdateEntry(context.Background(), filer.FromPbEntry(updateOldEntryRequest.Directory, updateOldEntryRequest.Entry))
+			if err := filer_pb.UpdateEntry(client, updateOldEntryRequest); err != nil {
+				return err
+			}
+			wfs.metaCache.UpdateEntry(context.Background(), filer.FromPbEntry(updateOldEntryRequest.Directory, updateOldEntryRequest.Entry))
 
-		if err := filer_pb.CreateEntry(client, request); err != nil {
-			return err
-		}
+			if err := filer_pb.CreateEntry(client, request); err != nil {
+				return err
+			}
 
-		wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry))
+			wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry))
 
-		return nil
+			return nil
+		})
+	}, func() (bool, error) {
+		if journaled, err := wfs.journalEntry(util.FullPath(oldParentPath), updateOldEntryRequest.Entry); !journaled || err != nil {
+			return journaled, err
+		}
+		return wfs.journalEntry(newParentPath, request.Entry)
 	})
 
 	newEntryPath := newParentPath.Child(name)
 
 	if err != nil {
 		glog.V(0).Infof("Link %v -> %s: %v", oldEntryPath, newEntryPath, err)
-		return fuse.EIO
+		return quotaAwareStatus(err)
 	}
 
 	wfs.inodeToPath.AddPath(oldEntry.Attributes.Inode, newEntryPath)
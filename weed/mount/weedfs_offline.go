@@ -0,0 +1,569 @@
+package mount
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/util/chunk_cache"
+)
+
+const offlineProbeInterval = 5 * time.Second
+
+var (
+	errFilerOffline       = errors.New("filer is offline")
+	errOfflineUnsupported = errors.New("not supported while the filer is offline")
+	errJournalUpload      = errors.New("upload journaled data")
+)
+
+// isFilerUnreachable tells whether the error is from failing to reach the filers
+func isFilerUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, errFilerOffline) {
+		return true
+	}
+	if s, ok := status.FromError(err); ok && (s.Code() == codes.Unavailable || s.Code() == codes.DeadlineExceeded) {
+		return true
+	}
+	// the errors are often wrapped as text
+	msg := err.Error()
+	return strings.Contains(msg, "transport") ||
+		strings.Contains(msg, "code = Unavailable") ||
+		strings.Contains(msg, "code = DeadlineExceeded") ||
+		strings.Contains(msg, errFilerOffline.Error())
+}
+
+// withFilerOrJournal applies a change to the filer with fn. In the offline write-back mode, the change
+// is journaled locally by journalFn instead, when the filer is unreachable or earlier changes are not replayed yet.
+func (wfs *WFS) withFilerOrJournal(fn func() error, journalFn func() (journaled bool, err error)) error {
+	if wfs.offlineJournal == nil {
+		return fn()
+	}
+	for {
+		if journaled, err := journalFn(); journaled || err != nil {
+			return err
+		}
+		err := fn()
+		if !isFilerUnreachable(err) {
+			return err
+		}
+		wfs.goOffline(err)
+	}
+}
+
+func (wfs *WFS) goOffline(err error) {
+	if wfs.offlineJournal.SetOffline(true) {
+		glog.Warningf("filer is unreachable, journal the changes locally: %v", err)
+	}
+}
+
+// journalBaseEntry is the entry known locally before a change, to detect conflicts when replaying the change
+func (wfs *WFS) journalBaseEntry(fullPath util.FullPath) *filer_pb.Entry {
+	entry, err := wfs.metaCache.FindEntry(context.Background(), fullPath)
+	if err != nil || entry == nil {
+		return nil
+	}
+	return &filer_pb.Entry{
+		Name:        entry.Name(),
+		IsDirectory: entry.IsDirectory(),
+		Attributes:  filer.EntryAttributeToPb(entry),
+	}
+}
+
+// journalEntry journals creating or updating an entry, and applies it to the local meta cache
+func (wfs *WFS) journalEntry(dir util.FullPath, entry *filer_pb.Entry) (journaled bool, err error) {
+	return wfs.offlineJournal.Journal(func() (*filer_pb.SubscribeMetadataResponse, error) {
+		fullPath := dir.Child(entry.Name)
+		base := wfs.journalBaseEntry(fullPath)
+
+		wfs.mapPbIdFromLocalToFiler(entry)
+		defer wfs.mapPbIdFromFilerToLocal(entry)
+
+		glog.V(3).Infof("journal %s", fullPath)
+		if err := wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(string(dir), entry)); err != nil {
+			return nil, err
+		}
+		return &filer_pb.SubscribeMetadataResponse{
+			Directory: string(dir),
+			EventNotification: &filer_pb.EventNotification{
+				OldEntry:      base,
+				NewEntry:      proto.Clone(entry).(*filer_pb.Entry),
+				NewParentPath: string(dir),
+			},
+			TsNs: time.Now().UnixNano(),
+		}, nil
+	})
+}
+
+// journalDeleteEntry journals deleting an entry. The callers delete it from the local meta cache.
+func (wfs *WFS) journalDeleteEntry(dir util.FullPath, name string, isDeleteData bool) (journaled bool, err error) {
+	return wfs.offlineJournal.Journal(func() (*filer_pb.SubscribeMetadataResponse, error) {
+		fullPath := dir.Child(name)
+		base := wfs.journalBaseEntry(fullPath)
+		if base == nil {
+			base = &filer_pb.Entry{Name: name}
+		}
+		if base.IsDirectory {
+			hasChildren := false
+			wfs.metaCache.ListDirectoryEntries(context.Background(), fullPath, "", false, 1, func(entry *filer.Entry) bool {
+				hasChildren = true
+				return false
+			})
+			if hasChildren {
+				return nil, errors.New(filer.MsgFailDelNonEmptyFolder)
+			}
+		}
+
+		glog.V(3).Infof("journal delete %s", fullPath)
+		return &filer_pb.SubscribeMetadataResponse{
+			Directory: string(dir),
+			EventNotification: &filer_pb.EventNotification{
+				OldEntry:     base,
+				DeleteChunks: isDeleteData,
+			},
+			TsNs: time.Now().UnixNano(),
+		}, nil
+	})
+}
+
+// journalRenameEntry journals renaming a file, and applies it locally.
+// Renaming directories needs the filer, and the callers fall back to copying.
+func (wfs *WFS) journalRenameEntry(oldDir util.FullPath, oldName string, newDir util.FullPath, newName string, flags uint32) (journaled bool, err error) {
+	return wfs.offlineJournal.Journal(func() (*filer_pb.SubscribeMetadataResponse, error) {
+		ctx := context.Background()
+		oldPath, newPath := oldDir.Child(oldName), newDir.Child(newName)
+		oldEntry, err := wfs.metaCache.FindEntry(ctx, oldPath)
+		if err != nil {
+			return nil, err
+		}
+		if oldEntry.IsDirectory() || flags == RenameExchange {
+			return nil, errOfflineUnsupported
+		}
+		if flags == RenameNoReplace {
+			if _, err := wfs.metaCache.FindEntry(ctx, newPath); err == nil {
+				return nil, os.ErrExist
+			}
+		}
+
+		base := wfs.journalBaseEntry(oldPath)
+		newEntry := oldEntry.ToProtoEntry()
+		newEntry.Name = newName
+		wfs.mapPbIdFromLocalToFiler(newEntry)
+
+		glog.V(3).Infof("journal rename %s => %s", oldPath, newPath)
+		event := &filer_pb.SubscribeMetadataResponse{
+			Directory: string(oldDir),
+			EventNotification: &filer_pb.EventNotification{
+				OldEntry:      base,
+				NewEntry:      newEntry,
+				NewParentPath: string(newDir),
+			},
+			TsNs: time.Now().UnixNano(),
+		}
+		// apply locally in the same two steps as the renaming responses from the filer
+		if err := wfs.handleRenameResponse(ctx, &filer_pb.StreamRenameEntryResponse{
+			Directory:         string(oldDir),
+			EventNotification: event.EventNotification,
+		}); err != nil {
+			return nil, err
+		}
+		if err := wfs.handleRenameResponse(ctx, &filer_pb.StreamRenameEntryResponse{
+			Directory:         string(oldDir),
+			EventNotification: &filer_pb.EventNotification{OldEntry: base},
+		}); err != nil {
+			return nil, err
+		}
+		return event, nil
+	})
+}
+
+// saveDataAsChunkOrJournal saves the data as a local journal chunk when the filer is unreachable
+func (wfs *WFS) saveDataAsChunkOrJournal(fullPath util.FullPath) filer.SaveDataAsChunkFunctionType {
+	saveFn := wfs.saveDataAsChunk(fullPath)
+	if wfs.offlineJournal == nil {
+		return saveFn
+	}
+	return func(reader io.Reader, filename string, offset int64, tsNs int64) (*filer_pb.FileChunk, error) {
+		if !wfs.offlineJournal.IsOffline() {
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				return nil, err
+			}
+			chunk, err := saveFn(bytes.NewReader(data), filename, offset, tsNs)
+			if !isFilerUnreachable(err) {
+				return chunk, err
+			}
+			wfs.goOffline(err)
+			reader = bytes.NewReader(data)
+		}
+		return wfs.offlineJournal.SaveChunk(reader, offset, tsNs)
+	}
+}
+
+// uploadJournalChunks uploads the data of the journal chunks, and returns the chunks referring to the uploaded data
+func (wfs *WFS) uploadJournalChunks(fullPath util.FullPath, chunks []*filer_pb.FileChunk) ([]*filer_pb.FileChunk, error) {
+	if wfs.offlineJournal == nil {
+		return chunks, nil
+	}
+	var uploadedChunks []*filer_pb.FileChunk
+	for i, chunk := range chunks {
+		if !IsJournalFileId(chunk.GetFileIdString()) {
+			continue
+		}
+		uploaded, err := wfs.uploadJournalChunk(fullPath, chunk)
+		if err != nil {
+			return nil, err
+		}
+		if uploadedChunks == nil {
+			uploadedChunks = append([]*filer_pb.FileChunk{}, chunks...)
+		}
+		uploadedChunks[i] = relinkJournalChunk(chunk, uploaded)
+	}
+	if uploadedChunks == nil {
+		return chunks, nil
+	}
+	return uploadedChunks, nil
+}
+
+func (wfs *WFS) uploadJournalChunk(fullPath util.FullPath, chunk *filer_pb.FileChunk) (*filer_pb.FileChunk, error) {
+	fileId := chunk.GetFileIdString()
+	if uploaded, found := wfs.offlineJournal.Uploaded(fileId); found {
+		return uploaded, nil
+	}
+	data, err := wfs.offlineJournal.OpenData(fileId)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+	uploaded, err := wfs.saveDataAsChunk(fullPath)(data, fullPath.Name(), chunk.Offset, chunk.ModifiedTsNs)
+	if err != nil {
+		return nil, err
+	}
+	wfs.offlineJournal.SetUploaded(fileId, uploaded)
+	return uploaded, nil
+}
+
+func hasJournalChunks(chunks []*filer_pb.FileChunk) bool {
+	for _, chunk := range chunks {
+		if IsJournalFileId(chunk.GetFileIdString()) {
+			return true
+		}
+	}
+	return false
+}
+
+// relinkJournalChunk refers the chunk to the uploaded data, keeping its place in the file
+func relinkJournalChunk(chunk, uploaded *filer_pb.FileChunk) *filer_pb.FileChunk {
+	relinked := proto.Clone(chunk).(*filer_pb.FileChunk)
+	relinked.FileId = uploaded.FileId
+	relinked.Fid = uploaded.Fid
+	relinked.ETag = uploaded.ETag
+	relinked.CipherKey = uploaded.CipherKey
+	relinked.IsCompressed = uploaded.IsCompressed
+	return relinked
+}
+
+func (wfs *WFS) loopReplayJournal() {
+	for {
+		time.Sleep(offlineProbeInterval)
+		if !wfs.offlineJournal.IsJournaling() {
+			continue
+		}
+		if err := wfs.pingFilers(); err != nil {
+			glog.V(1).Infof("filer is still offline: %v", err)
+			continue
+		}
+		if wfs.offlineJournal.SetOffline(false) {
+			glog.V(0).Infof("filer is reachable, replay %d journaled changes", wfs.offlineJournal.PendingCount())
+		}
+		if err := wfs.replayJournal(); err != nil {
+			glog.Warningf("replay journal: %v", err)
+			wfs.goOffline(err)
+		}
+	}
+}
+
+// pingFilers bypasses WithFilerClient, which fails right away while offline
+func (wfs *WFS) pingFilers() (err error) {
+	for _, filerAddress := range wfs.option.FilerAddresses {
+		err = pb.WithGrpcClient(false, wfs.signature, func(grpcConnection *grpc.ClientConn) error {
+			client := filer_pb.NewSeaweedFilerClient(grpcConnection)
+			_, err := client.Ping(context.Background(), &filer_pb.PingRequest{})
+			return err
+		}, filerAddress.ToGrpcAddress(), false, wfs.option.GrpcDialOption)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// replayJournal sends the journaled changes to the filer in order.
+// The local version of an entry changed on the filer meanwhile is saved as a conflict copy next to it.
+// The changes the filer refuses are set aside with their data, instead of being dropped.
+func (wfs *WFS) replayJournal() error {
+	conflicts := make(map[util.FullPath]util.FullPath)
+	var touched []util.FullPath
+	for {
+		record := wfs.offlineJournal.Peek()
+		if record == nil {
+			break
+		}
+		event := proto.Clone(record.event).(*filer_pb.SubscribeMetadataResponse)
+		fullPath, err := wfs.replayJournalEvent(event, conflicts)
+		if isFilerUnreachable(err) || errors.Is(err, errJournalUpload) {
+			// retry later, without losing the journaled data
+			return err
+		}
+		if err != nil {
+			// e.g., out of quota or permission denied, which retrying does not fix
+			glog.Errorf("filer rejected journaled change %v, kept in %s/%s: %v", event, wfs.option.getOfflineJournalDir(), journalRejectedLogFile, err)
+			if err = wfs.offlineJournal.Reject(record); err != nil {
+				return err
+			}
+			continue
+		}
+		if fullPath != "" {
+			touched = append(touched, fullPath)
+		}
+		if err = wfs.offlineJournal.Commit(record); err != nil {
+			return err
+		}
+	}
+	wfs.relinkJournalChunks(touched)
+	wfs.offlineJournal.RemoveUploadedData()
+	return nil
+}
+
+func (wfs *WFS) replayJournalEvent(event *filer_pb.SubscribeMetadataResponse, conflicts map[util.FullPath]util.FullPath) (util.FullPath, error) {
+	notification := event.EventNotification
+	switch {
+	case notification.NewEntry == nil:
+		return "", wfs.replayDeleteEntry(util.FullPath(event.Directory), notification, conflicts)
+	case notification.OldEntry != nil && (event.Directory != notification.NewParentPath || notification.OldEntry.Name != notification.NewEntry.Name):
+		return wfs.replayRenameEntry(util.FullPath(event.Directory), notification, conflicts)
+	default:
+		return wfs.replaySaveEntry(util.FullPath(notification.NewParentPath), notification, conflicts)
+	}
+}
+
+func (wfs *WFS) lookupFilerEntry(fullPath util.FullPath) (*filer_pb.Entry, error) {
+	entry, err := filer_pb.GetEntry(wfs, fullPath)
+	if err == filer_pb.ErrNotFound {
+		return nil, nil
+	}
+	return entry, err
+}
+
+func (wfs *WFS) replaySaveEntry(dir util.FullPath, notification *filer_pb.EventNotification, conflicts map[util.FullPath]util.FullPath) (util.FullPath, error) {
+	entry := notification.NewEntry
+	localPath := dir.Child(entry.Name)
+	fullPath := localPath
+
+	if conflictPath, found := conflicts[fullPath]; found {
+		fullPath = conflictPath
+	} else {
+		remote, err := wfs.lookupFilerEntry(fullPath)
+		if err != nil {
+			return "", err
+		}
+		if isJournalConflict(notification.OldEntry, remote, entry) {
+			conflictPath = util.NewFullPath(string(dir), journalConflictName(entry.Name, time.Now()))
+			glog.Warningf("%s is changed on the filer while offline, save the local version as %s", fullPath, conflictPath)
+			conflicts[fullPath] = conflictPath
+			wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(string(dir), remote))
+			fullPath = conflictPath
+		}
+	}
+	entry.Name = fullPath.Name()
+
+	chunks, err := wfs.uploadJournalChunks(fullPath, entry.GetChunks())
+	if err != nil {
+		return "", fmt.Errorf("%w of %s: %v", errJournalUpload, fullPath, err)
+	}
+	entry.Chunks = chunks
+
+	err = wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		return filer_pb.CreateEntry(client, &filer_pb.CreateEntryRequest{
+			Directory:  string(dir),
+			Entry:      entry,
+			Signatures: []int32{wfs.signature},
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	if fullPath != localPath {
+		wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(string(dir), entry))
+	}
+	return fullPath, nil
+}
+
+func (wfs *WFS) replayDeleteEntry(dir util.FullPath, notification *filer_pb.EventNotification, conflicts map[util.FullPath]util.FullPath) error {
+	base := notification.OldEntry
+	fullPath := dir.Child(base.Name)
+	if conflictPath, found := conflicts[fullPath]; found {
+		// the local version was saved as the conflict copy
+		delete(conflicts, fullPath)
+		conflictDir, conflictName := conflictPath.DirAndName()
+		return filer_pb.Remove(wfs, conflictDir, conflictName, notification.DeleteChunks, false, false, false, []int32{wfs.signature})
+	}
+
+	remote, err := wfs.lookupFilerEntry(fullPath)
+	if err != nil || remote == nil {
+		return err
+	}
+	if !remote.IsDirectory && base.Attributes != nil && remote.Attributes.GetMtime() != base.Attributes.GetMtime() {
+		glog.Warningf("%s is changed on the filer while offline, keep it", fullPath)
+		return wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(string(dir), remote))
+	}
+
+	err = filer_pb.Remove(wfs, string(dir), base.Name, notification.DeleteChunks, false, false, false, []int32{wfs.signature})
+	if err != nil && strings.Contains(err.Error(), filer.MsgFailDelNonEmptyFolder) {
+		glog.Warningf("%s has new entries on the filer while offline, keep it", fullPath)
+		return wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(string(dir), remote))
+	}
+	return err
+}
+
+func (wfs *WFS) replayRenameEntry(oldDir util.FullPath, notification *filer_pb.EventNotification, conflicts map[util.FullPath]util.FullPath) (util.FullPath, error) {
+	oldPath := oldDir.Child(notification.OldEntry.Name)
+	newDir := util.FullPath(notification.NewParentPath)
+	newPath := newDir.Child(notification.NewEntry.Name)
+
+	if conflictPath, found := conflicts[oldPath]; found {
+		// move the local version, and leave the filer version in place
+		delete(conflicts, oldPath)
+		wfs.metaCache.DeleteEntry(context.Background(), conflictPath)
+		oldPath = conflictPath
+		conflictDir, _ := conflictPath.DirAndName()
+		oldDir = util.FullPath(conflictDir)
+	} else {
+		remote, err := wfs.lookupFilerEntry(oldPath)
+		if err != nil {
+			return "", err
+		}
+		if remote == nil {
+			glog.Warningf("%s is deleted on the filer while offline, skip renaming it to %s", oldPath, newPath)
+			return "", nil
+		}
+	}
+	delete(conflicts, newPath)
+
+	err := wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		_, err := client.AtomicRenameEntry(context.Background(), &filer_pb.AtomicRenameEntryRequest{
+			OldDirectory: string(oldDir),
+			OldName:      oldPath.Name(),
+			NewDirectory: string(newDir),
+			NewName:      newPath.Name(),
+			Signatures:   []int32{wfs.signature},
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
+
+// relinkJournalChunks refers the cached entries and the open files to the uploaded data,
+// so the local data can be removed
+func (wfs *WFS) relinkJournalChunks(touched []util.FullPath) {
+	ctx := context.Background()
+	for _, fullPath := range touched {
+		entry, err := wfs.metaCache.FindEntry(ctx, fullPath)
+		if err != nil || !hasJournalChunks(entry.GetChunks()) {
+			continue
+		}
+		chunks, err := wfs.uploadJournalChunks(fullPath, entry.GetChunks())
+		if err != nil {
+			continue
+		}
+		entry.Chunks = chunks
+		wfs.metaCache.UpdateEntry(ctx, entry)
+	}
+
+	wfs.fhMap.RLock()
+	var fileHandles []*FileHandle
+	for _, fh := range wfs.fhMap.inode2fh {
+		fileHandles = append(fileHandles, fh)
+	}
+	wfs.fhMap.RUnlock()
+
+	for _, fh := range fileHandles {
+		fhActiveLock := wfs.fhLockTable.AcquireLock("relinkJournalChunks", fh.fh, util.ExclusiveLock)
+		if entry := fh.GetEntry().GetEntry(); entry != nil && hasJournalChunks(entry.GetChunks()) {
+			if chunks, err := wfs.uploadJournalChunks(fh.FullPath(), entry.GetChunks()); err == nil {
+				fh.UpdateEntry(func(entry *filer_pb.Entry) {
+					entry.Chunks = chunks
+				})
+				fh.entryChunkGroup.SetChunks(chunks)
+			}
+		}
+		wfs.fhLockTable.ReleaseLock(fh.fh, fhActiveLock)
+	}
+}
+
+// journalChunkCache reads the journal chunks from the local journal data, and other chunks from the chunk cache
+type journalChunkCache struct {
+	chunk_cache.ChunkCache
+	journal *OfflineJournal
+}
+
+func (wfs *WFS) readChunkCache() chunk_cache.ChunkCache {
+	if wfs.offlineJournal == nil {
+		return wfs.chunkCache
+	}
+	return &journalChunkCache{
+		ChunkCache: wfs.chunkCache,
+		journal:    wfs.offlineJournal,
+	}
+}
+
+func (c *journalChunkCache) ReadChunkAt(data []byte, fileId string, offset uint64) (n int, err error) {
+	if IsJournalFileId(fileId) {
+		return c.journal.ReadDataAt(fileId, data, int64(offset))
+	}
+	return c.ChunkCache.ReadChunkAt(data, fileId, offset)
+}
+
+func (c *journalChunkCache) SetChunk(fileId string, data []byte) {
+	if IsJournalFileId(fileId) {
+		return
+	}
+	c.ChunkCache.SetChunk(fileId, data)
+}
+
+func (c *journalChunkCache) LocateChunkAt(fileId string, offset uint64, size int) (ref chunk_cache.CachedChunkRef, found bool) {
+	if IsJournalFileId(fileId) {
+		return
+	}
+	if zeroCopyCache, ok := c.ChunkCache.(chunk_cache.ZeroCopyChunkCache); ok {
+		return zeroCopyCache.LocateChunkAt(fileId, offset, size)
+	}
+	return
+}
+
+func (c *journalChunkCache) IsInCache(fileId string, lockNeeded bool) (answer bool) {
+	if IsJournalFileId(fileId) {
+		return c.journal.HasData(fileId)
+	}
+	return c.ChunkCache.IsInCache(fileId, lockNeeded)
+}
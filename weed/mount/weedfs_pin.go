@@ -0,0 +1,383 @@
+package mount
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/mount/meta_cache"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/util/chunk_cache"
+	util_http "github.com/seaweedfs/seaweedfs/weed/util/http"
+	"github.com/seaweedfs/seaweedfs/weed/util/mem"
+)
+
+const (
+	// PIN_XATTR on a directory pins it, same as "weed mount.ctl pin"
+	PIN_XATTR             = "user.pin"
+	pinWarmingConcurrency = 8
+)
+
+// pinnedDirs keeps the metadata of the pinned directory trees in the meta cache,
+// so they are not forgotten with the kernel inodes and can be listed while the filer is unreachable.
+// The chunks of the pinned files are warmed into the pinned region of the chunk cache.
+type pinnedDirs struct {
+	sync.RWMutex
+	roots  map[util.FullPath]*pinStatus
+	loaded map[util.FullPath]struct{} // the directories under the roots with the children in the meta cache
+}
+
+type pinStatus struct {
+	warming bool
+	chunks  map[string]int64 // the warmed chunks and their sizes
+	err     error            // the last warming error
+}
+
+func newPinnedDirs() *pinnedDirs {
+	return &pinnedDirs{
+		roots:  make(map[util.FullPath]*pinStatus),
+		loaded: make(map[util.FullPath]struct{}),
+	}
+}
+
+func (p *pinnedDirs) add(root util.FullPath) {
+	p.Lock()
+	defer p.Unlock()
+	if _, found := p.roots[root]; !found {
+		p.roots[root] = &pinStatus{chunks: make(map[string]int64)}
+	}
+}
+
+// remove unpins the root, and returns whether it was pinned,
+// and the warmed chunks not used by the other roots
+func (p *pinnedDirs) remove(root util.FullPath) (released []string, found bool) {
+	p.Lock()
+	defer p.Unlock()
+	status, found := p.roots[root]
+	if !found {
+		return nil, false
+	}
+	delete(p.roots, root)
+	for dir := range p.loaded {
+		if !p.isPinnedLocked(dir) {
+			delete(p.loaded, dir)
+		}
+	}
+	for fileId := range status.chunks {
+		if !p.isChunkPinnedLocked(fileId) {
+			released = append(released, fileId)
+		}
+	}
+	return released, true
+}
+
+func (p *pinnedDirs) isPinnedLocked(dir util.FullPath) bool {
+	for root := range p.roots {
+		if dir == root || dir.IsUnder(root) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pinnedDirs) isChunkPinnedLocked(fileId string) bool {
+	for _, status := range p.roots {
+		if _, found := status.chunks[fileId]; found {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pinnedDirs) isChunkPinned(fileId string) bool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.isChunkPinnedLocked(fileId)
+}
+
+func (p *pinnedDirs) isPinned(dir util.FullPath) bool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.isPinnedLocked(dir)
+}
+
+func (p *pinnedDirs) markLoaded(dir util.FullPath) {
+	p.Lock()
+	defer p.Unlock()
+	if p.isPinnedLocked(dir) {
+		p.loaded[dir] = struct{}{}
+	}
+}
+
+func (p *pinnedDirs) isLoaded(dir util.FullPath) bool {
+	p.RLock()
+	defer p.RUnlock()
+	_, found := p.loaded[dir]
+	return found
+}
+
+// startWarming returns false if the root is not pinned, or is being warmed already
+func (p *pinnedDirs) startWarming(root util.FullPath) bool {
+	p.Lock()
+	defer p.Unlock()
+	status, found := p.roots[root]
+	if !found || status.warming {
+		return false
+	}
+	status.warming, status.err = true, nil
+	return true
+}
+
+func (p *pinnedDirs) finishWarming(root util.FullPath, err error) {
+	p.Lock()
+	defer p.Unlock()
+	if status, found := p.roots[root]; found {
+		status.warming, status.err = false, err
+	}
+}
+
+// addChunk records the warmed chunk, and returns false if the root has been unpinned meanwhile
+func (p *pinnedDirs) addChunk(root util.FullPath, fileId string, size int64) bool {
+	p.Lock()
+	defer p.Unlock()
+	status, found := p.roots[root]
+	if !found {
+		return false
+	}
+	status.chunks[fileId] = size
+	return true
+}
+
+func (p *pinnedDirs) list() (roots []util.FullPath) {
+	p.RLock()
+	defer p.RUnlock()
+	for root := range p.roots {
+		roots = append(roots, root)
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i] < roots[j]
+	})
+	return
+}
+
+func (p *pinnedDirs) status(root util.FullPath) (warming bool, chunkCount int, byteCount int64, err error) {
+	p.RLock()
+	defer p.RUnlock()
+	status, found := p.roots[root]
+	if !found {
+		return
+	}
+	for _, size := range status.chunks {
+		byteCount += size
+	}
+	return status.warming, len(status.chunks), byteCount, status.err
+}
+
+// filerPath converts the path relative to the mount directory to the filer path
+func (wfs *WFS) filerPath(relativePath string) util.FullPath {
+	return util.FullPath(path.Join(wfs.option.FilerMountRootPath, "/", relativePath))
+}
+
+// pinDirectory loads the metadata of the whole directory tree into the meta cache, and keeps it there
+func (wfs *WFS) pinDirectory(dir util.FullPath) (dirCount, fileCount int, err error) {
+	wfs.pins.add(dir)
+	dirCount, fileCount, err = meta_cache.LoadTree(wfs.metaCache, wfs, dir, wfs.pins.markLoaded)
+	if err != nil {
+		wfs.unpinDirectory(dir)
+		return
+	}
+	glog.V(0).Infof("pinned %s with %d directories and %d files", dir, dirCount, fileCount)
+	return
+}
+
+// unpinDirectory releases the metadata and the warmed chunks of the pinned directory tree
+func (wfs *WFS) unpinDirectory(dir util.FullPath) bool {
+	released, found := wfs.pins.remove(dir)
+	for _, fileId := range released {
+		wfs.chunkCache.UnpinChunk(fileId)
+	}
+	return found
+}
+
+// warmPinnedDirectory downloads the chunks of the pinned directory tree into the pinned region of the chunk cache
+func (wfs *WFS) warmPinnedDirectory(root util.FullPath) (chunkCount int, byteCount int64, err error) {
+	if wfs.chunkCache == nil {
+		return 0, 0, chunk_cache.ErrorPinnedRegionDisabled
+	}
+	if !wfs.pins.startWarming(root) {
+		return 0, 0, fmt.Errorf("%s is not pinned or is being warmed", root)
+	}
+	defer func() {
+		wfs.pins.finishWarming(root, err)
+	}()
+
+	var chunks []*filer_pb.FileChunk
+	wfs.walkCachedTree(root, func(entry *filer.Entry) {
+		dataChunks, _, resolveErr := filer.ResolveChunkManifest(wfs.LookupFn(), entry.GetChunks(), 0, math.MaxInt64)
+		if resolveErr != nil {
+			glog.Warningf("warm %s: %v", entry.FullPath, resolveErr)
+			err = resolveErr
+			return
+		}
+		chunks = append(chunks, dataChunks...)
+	})
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	limiter := make(chan struct{}, pinWarmingConcurrency)
+	for _, chunk := range chunks {
+		lock.Lock()
+		stopped := err == chunk_cache.ErrorPinnedRegionFull
+		lock.Unlock()
+		if stopped {
+			break
+		}
+		limiter <- struct{}{}
+		wg.Add(1)
+		go func(chunk *filer_pb.FileChunk) {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+			pinErr := wfs.pinChunk(chunk)
+			lock.Lock()
+			defer lock.Unlock()
+			if pinErr != nil {
+				err = pinErr
+				return
+			}
+			fileId := chunk.GetFileIdString()
+			if !wfs.pins.addChunk(root, fileId, int64(chunk.Size)) {
+				// unpinned while warming
+				if !wfs.pins.isChunkPinned(fileId) {
+					wfs.chunkCache.UnpinChunk(fileId)
+				}
+				return
+			}
+			chunkCount++
+			byteCount += int64(chunk.Size)
+		}(chunk)
+	}
+	wg.Wait()
+
+	if err != nil {
+		glog.Warningf("warmed %s partially with %d chunks: %v", root, chunkCount, err)
+		return
+	}
+	glog.V(0).Infof("warmed %s with %d chunks of %d bytes", root, chunkCount, byteCount)
+	return
+}
+
+func (wfs *WFS) pinChunk(chunk *filer_pb.FileChunk) error {
+	fileId := chunk.GetFileIdString()
+	if wfs.chunkCache.IsPinned(fileId) {
+		return nil
+	}
+	urlStrings, err := wfs.LookupFn()(fileId)
+	if err != nil {
+		return fmt.Errorf("lookup %s: %v", fileId, err)
+	}
+	data := mem.Allocate(int(chunk.Size))
+	defer mem.Free(data)
+	if _, err = util_http.RetriedFetchChunkData(data, urlStrings, chunk.CipherKey, chunk.IsCompressed, true, 0); err != nil {
+		return fmt.Errorf("fetch %s: %v", fileId, err)
+	}
+	return wfs.chunkCache.PinChunk(fileId, data)
+}
+
+// pinAndWarm pins the directory and warms its chunks in the background
+func (wfs *WFS) pinAndWarm(dir util.FullPath) {
+	if _, _, err := wfs.pinDirectory(dir); err != nil {
+		glog.Errorf("pin %s: %v", dir, err)
+		return
+	}
+	if wfs.chunkCache != nil {
+		wfs.warmPinnedDirectory(dir)
+	}
+}
+
+// maybePinByXAttr pins the directories with the pin extended attribute,
+// which is kept in the filer, so they are pinned again after the mount restarts
+func (wfs *WFS) maybePinByXAttr(dir util.FullPath, entry *filer.Entry) {
+	if !entry.IsDirectory() || entry.Extended == nil {
+		return
+	}
+	if _, found := entry.Extended[XATTR_PREFIX+PIN_XATTR]; !found || wfs.pins.isPinned(dir) {
+		return
+	}
+	go wfs.pinAndWarm(dir)
+}
+
+// isChildrenCached checks the directories cached for the kernel and the pinned directories
+func (wfs *WFS) isChildrenCached(dir util.FullPath) bool {
+	return wfs.inodeToPath.IsChildrenCached(dir) || wfs.pins.isLoaded(dir)
+}
+
+// walkCachedTree visits the files of the directory tree with the children in the meta cache
+func (wfs *WFS) walkCachedTree(root util.FullPath, fn func(entry *filer.Entry)) {
+	queue := []util.FullPath{root}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if !wfs.isChildrenCached(dir) {
+			continue
+		}
+		wfs.metaCache.ListDirectoryEntries(context.Background(), dir, "", false, math.MaxInt64, func(entry *filer.Entry) bool {
+			if entry.IsDirectory() {
+				queue = append(queue, entry.FullPath)
+			} else {
+				fn(entry)
+			}
+			return true
+		})
+	}
+}
+
+// dropCaches discards the cached metadata, the cached chunks, and the kernel page cache of the path.
+// The metadata and the chunks of the pinned directories are kept.
+func (wfs *WFS) dropCaches(fullPath util.FullPath) (dirCount, chunkCount int) {
+
+	// the chunks are found by the cached metadata, so they go first
+	var fileIds []string
+	collectFileIds := func(entry *filer.Entry) {
+		for _, chunk := range entry.GetChunks() {
+			fileIds = append(fileIds, chunk.GetFileIdString())
+		}
+	}
+	if entry, err := wfs.metaCache.FindEntry(context.Background(), fullPath); err == nil && !entry.IsDirectory() {
+		collectFileIds(entry)
+	} else {
+		wfs.walkCachedTree(fullPath, collectFileIds)
+	}
+	for _, fileId := range fileIds {
+		if wfs.chunkCache.DeleteChunk(fileId) {
+			chunkCount++
+		}
+	}
+
+	for _, dir := range wfs.inodeToPath.UncacheChildren(fullPath) {
+		if wfs.pins.isLoaded(dir) {
+			continue
+		}
+		if err := wfs.metaCache.DeleteFolderChildren(context.Background(), dir); err != nil {
+			glog.Warningf("drop cached children of %s: %v", dir, err)
+			continue
+		}
+		dirCount++
+	}
+
+	if wfs.fuseServer != nil {
+		for _, inode := range wfs.inodeToPath.InodesUnder(fullPath) {
+			wfs.fuseServer.InodeNotify(inode, 0, 0)
+		}
+	}
+
+	glog.V(0).Infof("dropped caches of %s: %d directories, %d chunks", fullPath, dirCount, chunkCount)
+	return
+}
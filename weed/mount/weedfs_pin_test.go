@@ -0,0 +1,85 @@
+package mount
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestPinnedDirs(t *testing.T) {
+	p := newPinnedDirs()
+	p.add("/data/photos")
+
+	p.markLoaded("/data/photos")
+	p.markLoaded("/data/photos/2023")
+	p.markLoaded("/data/photosets") // not under the pinned root
+
+	if !p.isPinned("/data/photos/2023/summer") {
+		t.Errorf("expected the sub directory to be pinned")
+	}
+	if p.isPinned("/data") {
+		t.Errorf("expected the parent directory not to be pinned")
+	}
+	if !p.isLoaded("/data/photos/2023") || p.isLoaded("/data/photosets") {
+		t.Errorf("unexpected loaded directories %v", p.loaded)
+	}
+
+	if _, found := p.remove("/data"); found {
+		t.Errorf("expected to only remove the pinned roots")
+	}
+	if _, found := p.remove("/data/photos"); !found {
+		t.Errorf("expected to remove the pinned root")
+	}
+	if p.isLoaded("/data/photos/2023") || len(p.list()) != 0 {
+		t.Errorf("expected nothing pinned after unpinning")
+	}
+}
+
+func TestPinnedDirs_sharedChunks(t *testing.T) {
+	p := newPinnedDirs()
+	p.add("/a")
+	p.add("/a/b")
+	p.addChunk("/a", "1,01", 10)
+	p.addChunk("/a", "1,02", 20)
+	p.addChunk("/a/b", "1,02", 20)
+
+	if p.addChunk("/c", "1,03", 30) {
+		t.Errorf("expected not to add chunks to the directories not pinned")
+	}
+	if !p.startWarming("/a") || p.startWarming("/a") {
+		t.Errorf("expected to warm the pinned directory once at a time")
+	}
+	p.finishWarming("/a", nil)
+	if warming, chunkCount, byteCount, _ := p.status("/a"); warming || chunkCount != 2 || byteCount != 30 {
+		t.Errorf("unexpected status %v %d %d", warming, chunkCount, byteCount)
+	}
+
+	released, _ := p.remove("/a")
+	if len(released) != 1 || released[0] != "1,01" {
+		t.Errorf("expected to keep the chunks still pinned by /a/b, released %v", released)
+	}
+	if !p.isChunkPinned("1,02") {
+		t.Errorf("expected 1,02 to be still pinned")
+	}
+}
+
+func TestInodeToPath_UncacheChildren(t *testing.T) {
+	i := NewInodeToPath(util.FullPath("/"))
+	i.Lookup("/a", 1, true, false, 0, true)
+	i.Lookup("/a/b", 1, true, false, 0, true)
+	i.Lookup("/c", 1, true, false, 0, true)
+	i.MarkChildrenCached("/a")
+	i.MarkChildrenCached("/a/b")
+	i.MarkChildrenCached("/c")
+
+	uncached := i.UncacheChildren("/a")
+	if len(uncached) != 2 {
+		t.Errorf("expected 2 uncached directories, got %v", uncached)
+	}
+	if i.IsChildrenCached("/a") || i.IsChildrenCached("/a/b") || !i.IsChildrenCached("/c") {
+		t.Errorf("unexpected cached directories after uncaching /a")
+	}
+	if inodes := i.InodesUnder("/a"); len(inodes) != 2 {
+		t.Errorf("expected 2 inodes under /a, got %v", inodes)
+	}
+}
@@ -3,9 +3,13 @@ package mount
 import (
 	"context"
 	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
-	"time"
 )
 
 func (wfs *WFS) loopCheckQuota() {
@@ -52,3 +56,11 @@ func (wfs *WFS) loopCheckQuota() {
 	}
 
 }
+
+// quotaAwareStatus reports filer directory, user or group quota rejections as EDQUOT
+func quotaAwareStatus(err error) fuse.Status {
+	if filer.IsQuotaExceededError(err) {
+		return fuse.Status(syscall.EDQUOT)
+	}
+	return fuse.EIO
+}
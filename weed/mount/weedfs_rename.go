@@ -2,8 +2,10 @@ package mount
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 	"syscall"
 
@@ -168,51 +170,78 @@ func (wfs *WFS) Rename(cancel <-chan struct{}, in *fuse.RenameIn, oldName string
 		}
 	}
 
+	if code = wfs.checkDirAclAccess(&in.Caller, oldDir); code != fuse.OK {
+		return
+	}
+	if newDir != oldDir {
+		if code = wfs.checkDirAclAccess(&in.Caller, newDir); code != fuse.OK {
+			return
+		}
+	}
+
 	glog.V(4).Infof("dir Rename %s => %s", oldPath, newPath)
 
 	// update remote filer
-	err := wfs.WithFilerClient(true, func(client filer_pb.SeaweedFilerClient) error {
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-
-		request := &filer_pb.StreamRenameEntryRequest{
-			OldDirectory: string(oldDir),
-			OldName:      oldName,
-			NewDirectory: string(newDir),
-			NewName:      newName,
-			Signatures:   []int32{wfs.signature},
-		}
+	err := wfs.withFilerOrJournal(func() error {
+		return wfs.WithFilerClient(true, func(client filer_pb.SeaweedFilerClient) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			request := &filer_pb.StreamRenameEntryRequest{
+				OldDirectory: string(oldDir),
+				OldName:      oldName,
+				NewDirectory: string(newDir),
+				NewName:      newName,
+				Signatures:   []int32{wfs.signature},
+			}
 
-		stream, err := client.StreamRenameEntry(ctx, request)
-		if err != nil {
-			code = fuse.EIO
-			return fmt.Errorf("dir AtomicRenameEntry %s => %s : %v", oldPath, newPath, err)
-		}
+			stream, err := client.StreamRenameEntry(ctx, request)
+			if err != nil {
+				code = fuse.EIO
+				return fmt.Errorf("dir AtomicRenameEntry %s => %s : %v", oldPath, newPath, err)
+			}
 
-		for {
-			resp, recvErr := stream.Recv()
-			if recvErr != nil {
-				if recvErr == io.EOF {
-					break
-				} else {
-					if strings.Contains(recvErr.Error(), "not empty") {
-						code = fuse.Status(syscall.ENOTEMPTY)
-					} else if strings.Contains(recvErr.Error(), "not directory") {
-						code = fuse.ENOTDIR
+			for {
+				resp, recvErr := stream.Recv()
+				if recvErr != nil {
+					if recvErr == io.EOF {
+						break
+					} else {
+						if strings.Contains(recvErr.Error(), "not empty") {
+							code = fuse.Status(syscall.ENOTEMPTY)
+						} else if strings.Contains(recvErr.Error(), "not directory") {
+							code = fuse.ENOTDIR
+						} else if filer.IsQuotaExceededError(recvErr) {
+							code = fuse.Status(syscall.EDQUOT)
+						}
+						return fmt.Errorf("dir Rename %s => %s receive: %v", oldPath, newPath, recvErr)
 					}
-					return fmt.Errorf("dir Rename %s => %s receive: %v", oldPath, newPath, recvErr)
 				}
-			}
 
-			if err = wfs.handleRenameResponse(ctx, resp); err != nil {
-				glog.V(0).Infof("dir Rename %s => %s : %v", oldPath, newPath, err)
-				return err
+				if err = wfs.handleRenameResponse(ctx, resp); err != nil {
+					glog.V(0).Infof("dir Rename %s => %s : %v", oldPath, newPath, err)
+					return err
+				}
+
 			}
 
+			return nil
+
+		})
+	}, func() (bool, error) {
+		journaled, err := wfs.journalRenameEntry(oldDir, oldName, newDir, newName, in.Flags)
+		switch {
+		case errors.Is(err, errOfflineUnsupported):
+			// the directories are copied instead, with the changes journaled
+			code = fuse.Status(syscall.EXDEV)
+		case errors.Is(err, os.ErrExist):
+			code = fuse.Status(syscall.EEXIST)
+		case err == filer_pb.ErrNotFound:
+			code = fuse.ENOENT
+		case err != nil:
+			code = fuse.EIO
 		}
-
-		return nil
-
+		return journaled, err
 	})
 	if err != nil {
 		glog.V(0).Infof("Link: %v", err)
@@ -28,6 +28,9 @@ func (wfs *WFS) Symlink(cancel <-chan struct{}, header *fuse.InHeader, target st
 	if code != fuse.OK {
 		return
 	}
+	if code = wfs.checkDirAclAccess(&header.Caller, dirPath); code != fuse.OK {
+		return
+	}
 	entryFullPath := dirPath.Child(name)
 
 	request := &filer_pb.CreateEntryRequest{
@@ -48,22 +51,26 @@ func (wfs *WFS) Symlink(cancel <-chan struct{}, header *fuse.InHeader, target st
 		SkipCheckParentDirectory: true,
 	}
 
-	err := wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+	err := wfs.withFilerOrJournal(func() error {
+		return wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
 
-		wfs.mapPbIdFromLocalToFiler(request.Entry)
-		defer wfs.mapPbIdFromFilerToLocal(request.Entry)
+			wfs.mapPbIdFromLocalToFiler(request.Entry)
+			defer wfs.mapPbIdFromFilerToLocal(request.Entry)
 
-		if err := filer_pb.CreateEntry(client, request); err != nil {
-			return fmt.Errorf("symlink %s: %v", entryFullPath, err)
-		}
+			if err := filer_pb.CreateEntry(client, request); err != nil {
+				return fmt.Errorf("symlink %s: %v", entryFullPath, err)
+			}
 
-		wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry))
+			wfs.metaCache.InsertEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry))
 
-		return nil
+			return nil
+		})
+	}, func() (bool, error) {
+		return wfs.journalEntry(dirPath, request.Entry)
 	})
 	if err != nil {
 		glog.V(0).Infof("Symlink %s => %s: %v", entryFullPath, target, err)
-		return fuse.EIO
+		return quotaAwareStatus(err)
 	}
 
 	inode := wfs.inodeToPath.Lookup(entryFullPath, request.Entry.Attributes.Crtime, false, false, 0, true)
@@ -120,6 +120,10 @@ func (wfs *WFS) SetXAttr(cancel <-chan struct{}, input *fuse.SetXAttrIn, attr st
 		entry.Extended = make(map[string][]byte)
 	}
 	oldData, _ := entry.Extended[XATTR_PREFIX+attr]
+	save, aclStatus := applyAclXAttr(&input.Caller, entry, attr, data)
+	if aclStatus != fuse.OK {
+		return aclStatus
+	}
 	switch input.Flags {
 	case sys.XATTR_CREATE:
 		if len(oldData) > 0 {
@@ -129,7 +133,9 @@ func (wfs *WFS) SetXAttr(cancel <-chan struct{}, input *fuse.SetXAttrIn, attr st
 	case sys.XATTR_REPLACE:
 		fallthrough
 	default:
-		entry.Extended[XATTR_PREFIX+attr] = data
+		if save {
+			entry.Extended[XATTR_PREFIX+attr] = data
+		}
 	}
 
 	if fh != nil {
@@ -137,7 +143,13 @@ func (wfs *WFS) SetXAttr(cancel <-chan struct{}, input *fuse.SetXAttrIn, attr st
 		return fuse.OK
 	}
 
-	return wfs.saveEntry(path, entry)
+	if status := wfs.saveEntry(path, entry); status != fuse.OK {
+		return status
+	}
+	if attr == PIN_XATTR && entry.IsDirectory && !wfs.pins.isPinned(path) {
+		go wfs.pinAndWarm(path)
+	}
+	return fuse.OK
 
 }
 
@@ -210,5 +222,11 @@ func (wfs *WFS) RemoveXAttr(cancel <-chan struct{}, header *fuse.InHeader, attr
 
 	delete(entry.Extended, XATTR_PREFIX+attr)
 
-	return wfs.saveEntry(path, entry)
+	if status := wfs.saveEntry(path, entry); status != fuse.OK {
+		return status
+	}
+	if attr == PIN_XATTR && entry.IsDirectory {
+		wfs.unpinDirectory(path)
+	}
+	return fuse.OK
 }
@@ -15,6 +15,11 @@ var _ = filer_pb.FilerClient(&WFS{})
 
 func (wfs *WFS) WithFilerClient(streamingMode bool, fn func(filer_pb.SeaweedFilerClient) error) (err error) {
 
+	if wfs.offlineJournal.IsOffline() {
+		// fail fast instead of retrying, until the filer is reachable again
+		return errFilerOffline
+	}
+
 	return util.Retry("filer grpc", func() error {
 
 		i := atomic.LoadInt32(&wfs.option.filerIndex)
@@ -15,32 +15,36 @@ func (wfs *WFS) saveEntry(path util.FullPath, entry *filer_pb.Entry) (code fuse.
 
 	parentDir, _ := path.DirAndName()
 
-	err := wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+	err := wfs.withFilerOrJournal(func() error {
+		return wfs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
 
-		wfs.mapPbIdFromLocalToFiler(entry)
-		defer wfs.mapPbIdFromFilerToLocal(entry)
+			wfs.mapPbIdFromLocalToFiler(entry)
+			defer wfs.mapPbIdFromFilerToLocal(entry)
 
-		request := &filer_pb.UpdateEntryRequest{
-			Directory:  parentDir,
-			Entry:      entry,
-			Signatures: []int32{wfs.signature},
-		}
+			request := &filer_pb.UpdateEntryRequest{
+				Directory:  parentDir,
+				Entry:      entry,
+				Signatures: []int32{wfs.signature},
+			}
 
-		glog.V(1).Infof("save entry: %v", request)
-		_, err := client.UpdateEntry(context.Background(), request)
-		if err != nil {
-			return fmt.Errorf("UpdateEntry dir %s: %v", path, err)
-		}
+			glog.V(1).Infof("save entry: %v", request)
+			_, err := client.UpdateEntry(context.Background(), request)
+			if err != nil {
+				return fmt.Errorf("UpdateEntry dir %s: %v", path, err)
+			}
 
-		if err := wfs.metaCache.UpdateEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry)); err != nil {
-			return fmt.Errorf("UpdateEntry dir %s: %v", path, err)
-		}
+			if err := wfs.metaCache.UpdateEntry(context.Background(), filer.FromPbEntry(request.Directory, request.Entry)); err != nil {
+				return fmt.Errorf("UpdateEntry dir %s: %v", path, err)
+			}
 
-		return nil
+			return nil
+		})
+	}, func() (bool, error) {
+		return wfs.journalEntry(util.FullPath(parentDir), entry)
 	})
 	if err != nil {
 		glog.Errorf("saveEntry %s: %v", path, err)
-		return fuse.EIO
+		return quotaAwareStatus(err)
 	}
 
 	return fuse.OK
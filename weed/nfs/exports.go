@@ -0,0 +1,209 @@
+package nfs
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	nobodyUid = 65534
+	nobodyGid = 65534
+)
+
+// DefaultExports exports the whole filer to all clients read-only
+const DefaultExports = "/ *(ro)"
+
+// Export is a filer directory shared to the clients, in the format of /etc/exports:
+//
+//	/buckets/shared  10.0.0.0/8(rw,no_root_squash) 192.168.1.7(ro) *(ro,all_squash)
+//
+// The supported options are ro, rw, root_squash, no_root_squash, all_squash, no_all_squash,
+// anonuid and anongid. The other options of the kernel nfs server are ignored.
+type Export struct {
+	Path  util.FullPath
+	Rules []*ExportRule
+}
+
+// ExportRule applies to the clients in a subnet
+type ExportRule struct {
+	Network    *net.IPNet // nil for all clients
+	ReadOnly   bool
+	RootSquash bool
+	AllSquash  bool
+	AnonUid    uint32
+	AnonGid    uint32
+}
+
+type Exports struct {
+	exports []*Export // sorted by path length, the longest first
+}
+
+func LoadExports(file string) (*Exports, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseExports(string(data))
+}
+
+func ParseExports(text string) (*Exports, error) {
+	exports := &Exports{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		export, err := parseExport(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		exports.exports = append(exports.exports, export)
+	}
+	if len(exports.exports) == 0 {
+		return nil, fmt.Errorf("no exports")
+	}
+	sort.SliceStable(exports.exports, func(i, j int) bool {
+		return len(exports.exports[i].Path) > len(exports.exports[j].Path)
+	})
+	return exports, nil
+}
+
+func parseExport(fields []string) (*Export, error) {
+	if !strings.HasPrefix(fields[0], "/") {
+		return nil, fmt.Errorf("export path %s is not absolute", fields[0])
+	}
+	export := &Export{
+		Path: util.FullPath(fields[0]),
+	}
+	if export.Path != "/" {
+		export.Path = util.FullPath(strings.TrimSuffix(fields[0], "/"))
+	}
+	if len(fields) == 1 {
+		fields = append(fields, "*")
+	}
+	for _, field := range fields[1:] {
+		rule, err := parseExportRule(field)
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %v", export.Path, err)
+		}
+		export.Rules = append(export.Rules, rule)
+	}
+	return export, nil
+}
+
+func parseExportRule(field string) (*ExportRule, error) {
+	client, options := field, ""
+	if i := strings.Index(field, "("); i >= 0 {
+		if !strings.HasSuffix(field, ")") {
+			return nil, fmt.Errorf("unbalanced parenthesis in %s", field)
+		}
+		client, options = field[:i], field[i+1:len(field)-1]
+	}
+
+	rule := &ExportRule{
+		ReadOnly:   true,
+		RootSquash: true,
+		AnonUid:    nobodyUid,
+		AnonGid:    nobodyGid,
+	}
+	switch {
+	case client == "*" || client == "":
+	case strings.Contains(client, "/"):
+		_, network, err := net.ParseCIDR(client)
+		if err != nil {
+			return nil, err
+		}
+		rule.Network = network
+	default:
+		ip := net.ParseIP(client)
+		if ip == nil {
+			return nil, fmt.Errorf("client %s is not an ip address or a subnet", client)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		rule.Network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+
+	for _, option := range strings.Split(options, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "ro":
+			rule.ReadOnly = true
+		case "rw":
+			rule.ReadOnly = false
+		case "root_squash":
+			rule.RootSquash = true
+		case "no_root_squash":
+			rule.RootSquash = false
+		case "all_squash":
+			rule.AllSquash = true
+		case "no_all_squash":
+			rule.AllSquash = false
+		case "anonuid", "anongid":
+			id, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("option %s: %v", option, err)
+			}
+			if key == "anonuid" {
+				rule.AnonUid = uint32(id)
+			} else {
+				rule.AnonGid = uint32(id)
+			}
+		}
+	}
+	return rule, nil
+}
+
+// Match finds the rule of the export containing the path for the client.
+// Only the innermost export is checked, as the kernel nfs server does.
+func (exports *Exports) Match(path util.FullPath, client net.IP) (*Export, *ExportRule) {
+	for _, export := range exports.exports {
+		if !isUnder(path, export.Path) {
+			continue
+		}
+		for _, rule := range export.Rules {
+			if rule.Network == nil || rule.Network.Contains(client) {
+				return export, rule
+			}
+		}
+		return export, nil
+	}
+	return nil, nil
+}
+
+// List returns the exports and the subnets allowed to mount them
+func (exports *Exports) List() []*Export {
+	return exports.exports
+}
+
+// squash maps the ids of the caller as the rule says
+func (rule *ExportRule) squash(uid, gid uint32, gids []uint32) (uint32, uint32, []uint32) {
+	if rule.AllSquash || rule.RootSquash && uid == 0 {
+		return rule.AnonUid, rule.AnonGid, nil
+	}
+	if rule.RootSquash && gid == 0 {
+		gid = rule.AnonGid
+	}
+	return uid, gid, gids
+}
+
+func isUnder(path, dir util.FullPath) bool {
+	if dir == "/" || path == dir {
+		return true
+	}
+	return strings.HasPrefix(string(path), string(dir)+"/")
+}
@@ -0,0 +1,96 @@
+package nfs
+
+import (
+	"net"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestParseExports(t *testing.T) {
+	exports, err := ParseExports(`
+# shared to the office
+/buckets/shared/  10.0.0.0/8(rw,no_root_squash) 192.168.1.7(ro,all_squash,anonuid=1000,anongid=100)
+/                 *(rw)
+/buckets          2001:db8::/32
+`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(exports.List()) != 3 {
+		t.Fatalf("exports %d", len(exports.List()))
+	}
+
+	tests := []struct {
+		path       string
+		client     string
+		export     string
+		allowed    bool
+		readOnly   bool
+		rootSquash bool
+	}{
+		{"/buckets/shared", "10.1.2.3", "/buckets/shared", true, false, false},
+		{"/buckets/shared/a/b", "192.168.1.7", "/buckets/shared", true, true, true},
+		// only the innermost export applies
+		{"/buckets/shared/a", "192.168.1.8", "/buckets/shared", false, false, false},
+		{"/buckets/other", "2001:db8::1", "/buckets", true, true, true},
+		{"/buckets/other", "10.1.2.3", "/buckets", false, false, false},
+		{"/buckets2", "10.1.2.3", "/", true, false, true},
+		{"/", "172.16.0.1", "/", true, false, true},
+	}
+	for _, tt := range tests {
+		export, rule := exports.Match(util.FullPath(tt.path), net.ParseIP(tt.client))
+		if export == nil || string(export.Path) != tt.export {
+			t.Errorf("%s from %s: export %v, want %s", tt.path, tt.client, export, tt.export)
+			continue
+		}
+		if (rule != nil) != tt.allowed {
+			t.Errorf("%s from %s: allowed %v", tt.path, tt.client, rule != nil)
+			continue
+		}
+		if rule != nil && (rule.ReadOnly != tt.readOnly || rule.RootSquash != tt.rootSquash) {
+			t.Errorf("%s from %s: readOnly %v rootSquash %v", tt.path, tt.client, rule.ReadOnly, rule.RootSquash)
+		}
+	}
+}
+
+func TestExportRuleSquash(t *testing.T) {
+	exports, err := ParseExports("/ 10.0.0.1(rw) 10.0.0.2(all_squash,anonuid=1000,anongid=100) 10.0.0.3(no_root_squash)")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	_, rule := exports.Match("/", net.ParseIP("10.0.0.1"))
+	if uid, gid, gids := rule.squash(0, 0, []uint32{10}); uid != nobodyUid || gid != nobodyGid || gids != nil {
+		t.Errorf("root squash: %d %d %v", uid, gid, gids)
+	}
+	if uid, gid, gids := rule.squash(500, 0, []uint32{10}); uid != 500 || gid != nobodyGid || len(gids) != 1 {
+		t.Errorf("root group squash: %d %d %v", uid, gid, gids)
+	}
+
+	_, rule = exports.Match("/", net.ParseIP("10.0.0.2"))
+	if uid, gid, _ := rule.squash(500, 500, nil); uid != 1000 || gid != 100 {
+		t.Errorf("all squash: %d %d", uid, gid)
+	}
+
+	_, rule = exports.Match("/", net.ParseIP("10.0.0.3"))
+	if uid, gid, _ := rule.squash(0, 0, nil); uid != 0 || gid != 0 {
+		t.Errorf("no root squash: %d %d", uid, gid)
+	}
+}
+
+func TestParseExportsErrors(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"# nothing exported",
+		"buckets *(rw)",
+		"/buckets 10.0.0.0/33(rw)",
+		"/buckets host.example.com(rw)",
+		"/buckets *(rw",
+		"/buckets *(anonuid=nobody)",
+	} {
+		if _, err := ParseExports(text); err == nil {
+			t.Errorf("expect error parsing %q", text)
+		}
+	}
+}
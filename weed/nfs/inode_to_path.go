@@ -0,0 +1,286 @@
+package nfs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	leveldb_util "github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The file handles are the inode ids, allocated from a counter and never reused,
+// so a stale handle can not be resolved to another file.
+// The inodes are kept in a LevelDB under the cache directory, so the handles stay valid after restarts,
+// and the memory does not grow with the number of files:
+//
+//	'i' + inode => access sequence + path
+//	'p' + path => inode
+//	'a' + access sequence => inode
+//	'n' => the next inode or access sequence
+//
+// The least recently accessed inodes are evicted beyond the capacity, and their handles become stale,
+// except the handles of the export roots.
+const (
+	rootInode  = 1
+	handleSize = 8
+
+	inodeKeyPrefix  = 'i'
+	pathKeyPrefix   = 'p'
+	accessKeyPrefix = 'a'
+	nextKey         = "n"
+
+	DefaultMaxHandles = 4 * 1024 * 1024
+)
+
+var inodeDbOptions = &opt.Options{
+	BlockCacheCapacity: 32 * 1024 * 1024, // default value is 8MiB
+}
+
+type InodeToPath struct {
+	sync.Mutex
+	db         *leveldb.DB
+	next       uint64
+	count      int
+	maxHandles int
+	roots      map[uint64]struct{}
+}
+
+func NewInodeToPath(dir string, maxHandles int) (*InodeToPath, error) {
+	db, err := leveldb.OpenFile(dir, inodeDbOptions)
+	if err != nil {
+		return nil, fmt.Errorf("open inode db %s: %v", dir, err)
+	}
+	i := &InodeToPath{
+		db:         db,
+		next:       rootInode + 1,
+		maxHandles: maxHandles,
+		roots:      map[uint64]struct{}{rootInode: {}},
+	}
+	if data, err := db.Get([]byte(nextKey), nil); err == nil && len(data) == 8 {
+		i.next = binary.BigEndian.Uint64(data)
+	}
+	iter := db.NewIterator(leveldb_util.BytesPrefix([]byte{inodeKeyPrefix}), nil)
+	for iter.Next() {
+		i.count++
+	}
+	iter.Release()
+	if err = iter.Error(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("load inode db %s: %v", dir, err)
+	}
+	return i, nil
+}
+
+func (i *InodeToPath) Close() error {
+	return i.db.Close()
+}
+
+// Lookup returns the inode of the path, allocating one if the path is new
+func (i *InodeToPath) Lookup(path util.FullPath) uint64 {
+	if path == "/" {
+		return rootInode
+	}
+	i.Lock()
+	defer i.Unlock()
+	if inode, found := i.getInode(path); found {
+		i.touch(inode, path)
+		return inode
+	}
+
+	inode := i.next
+	batch := new(leveldb.Batch)
+	i.putInode(batch, inode, inode, path)
+	batch.Put(pathKey(path), uint64Bytes(inode))
+	i.next++
+	batch.Put([]byte(nextKey), uint64Bytes(i.next))
+	if err := i.db.Write(batch, nil); err != nil {
+		glog.Errorf("nfs allocate inode of %s: %v", path, err)
+	}
+	i.count++
+	i.evict()
+	return inode
+}
+
+// LookupRoot allocates the inode of an export root, which is never evicted
+func (i *InodeToPath) LookupRoot(path util.FullPath) uint64 {
+	inode := i.Lookup(path)
+	i.Lock()
+	i.roots[inode] = struct{}{}
+	i.Unlock()
+	return inode
+}
+
+func (i *InodeToPath) GetPath(inode uint64) (util.FullPath, bool) {
+	if inode == rootInode {
+		return "/", true
+	}
+	i.Lock()
+	defer i.Unlock()
+	sequence, path, found := i.getPath(inode)
+	if found && i.isStale(sequence) {
+		i.touch(inode, path)
+	}
+	return path, found
+}
+
+func (i *InodeToPath) GetInode(path util.FullPath) (uint64, bool) {
+	if path == "/" {
+		return rootInode, true
+	}
+	i.Lock()
+	defer i.Unlock()
+	return i.getInode(path)
+}
+
+func (i *InodeToPath) RemovePath(path util.FullPath) {
+	i.Lock()
+	defer i.Unlock()
+	if inode, found := i.getInode(path); found && inode != rootInode {
+		batch := new(leveldb.Batch)
+		i.deleteInode(batch, inode, path)
+		if err := i.db.Write(batch, nil); err != nil {
+			glog.Errorf("nfs remove inode of %s: %v", path, err)
+		}
+	}
+}
+
+// MovePath keeps the inodes of the moved entry and its children
+func (i *InodeToPath) MovePath(sourcePath, targetPath util.FullPath) {
+	i.Lock()
+	defer i.Unlock()
+	batch := new(leveldb.Batch)
+	if inode, found := i.getInode(targetPath); found {
+		i.deleteInode(batch, inode, targetPath)
+	}
+	var movedPaths []util.FullPath
+	var movedInodes []uint64
+	if inode, found := i.getInode(sourcePath); found {
+		movedPaths = append(movedPaths, sourcePath)
+		movedInodes = append(movedInodes, inode)
+	}
+	iter := i.db.NewIterator(leveldb_util.BytesPrefix(pathKey(sourcePath+"/")), nil)
+	for iter.Next() {
+		movedPaths = append(movedPaths, util.FullPath(iter.Key()[1:]))
+		movedInodes = append(movedInodes, binary.BigEndian.Uint64(iter.Value()))
+	}
+	iter.Release()
+	for n, path := range movedPaths {
+		inode := movedInodes[n]
+		sequence, _, _ := i.getPath(inode)
+		newPath := targetPath + path[len(sourcePath):]
+		batch.Delete(pathKey(path))
+		batch.Put(pathKey(newPath), uint64Bytes(inode))
+		batch.Put(inodeKey(inode), append(uint64Bytes(sequence), newPath...))
+	}
+	if err := i.db.Write(batch, nil); err != nil {
+		glog.Errorf("nfs move inodes from %s to %s: %v", sourcePath, targetPath, err)
+	}
+}
+
+func (i *InodeToPath) getInode(path util.FullPath) (uint64, bool) {
+	data, err := i.db.Get(pathKey(path), nil)
+	if err != nil || len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+func (i *InodeToPath) getPath(inode uint64) (sequence uint64, path util.FullPath, found bool) {
+	data, err := i.db.Get(inodeKey(inode), nil)
+	if err != nil || len(data) < 8 {
+		return 0, "", false
+	}
+	return binary.BigEndian.Uint64(data), util.FullPath(data[8:]), true
+}
+
+func (i *InodeToPath) putInode(batch *leveldb.Batch, inode, sequence uint64, path util.FullPath) {
+	batch.Put(inodeKey(inode), append(uint64Bytes(sequence), path...))
+	batch.Put(accessKey(sequence), uint64Bytes(inode))
+}
+
+func (i *InodeToPath) deleteInode(batch *leveldb.Batch, inode uint64, path util.FullPath) {
+	if sequence, _, found := i.getPath(inode); found {
+		batch.Delete(accessKey(sequence))
+		batch.Delete(inodeKey(inode))
+		i.count--
+	}
+	batch.Delete(pathKey(path))
+}
+
+// isStale tells whether the access sequence of an inode is old enough to be renewed,
+// so the inodes in use are not evicted, without writing on every access
+func (i *InodeToPath) isStale(sequence uint64) bool {
+	return i.next-sequence > uint64(i.maxHandles/4)
+}
+
+func (i *InodeToPath) touch(inode uint64, path util.FullPath) {
+	sequence, _, found := i.getPath(inode)
+	if !found || !i.isStale(sequence) {
+		return
+	}
+	batch := new(leveldb.Batch)
+	batch.Delete(accessKey(sequence))
+	i.putInode(batch, inode, i.next, path)
+	i.next++
+	batch.Put([]byte(nextKey), uint64Bytes(i.next))
+	if err := i.db.Write(batch, nil); err != nil {
+		glog.Errorf("nfs renew inode of %s: %v", path, err)
+	}
+}
+
+// evict removes the least recently accessed inodes beyond the capacity
+func (i *InodeToPath) evict() {
+	if i.count <= i.maxHandles {
+		return
+	}
+	batch := new(leveldb.Batch)
+	iter := i.db.NewIterator(leveldb_util.BytesPrefix([]byte{accessKeyPrefix}), nil)
+	for i.count > i.maxHandles && iter.Next() {
+		inode := binary.BigEndian.Uint64(iter.Value())
+		if _, isRoot := i.roots[inode]; isRoot {
+			continue
+		}
+		if _, path, found := i.getPath(inode); found {
+			batch.Delete(pathKey(path))
+		}
+		batch.Delete(inodeKey(inode))
+		batch.Delete(append([]byte(nil), iter.Key()...))
+		i.count--
+	}
+	iter.Release()
+	if err := i.db.Write(batch, nil); err != nil {
+		glog.Errorf("nfs evict inodes: %v", err)
+	}
+}
+
+func inodeKey(inode uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte{inodeKeyPrefix}, inode)
+}
+
+func pathKey(path util.FullPath) []byte {
+	return append([]byte{pathKeyPrefix}, path...)
+}
+
+func accessKey(sequence uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte{accessKeyPrefix}, sequence)
+}
+
+func uint64Bytes(n uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, n)
+}
+
+func inodeToHandle(inode uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, inode)
+}
+
+func handleToInode(handle []byte) (uint64, bool) {
+	if len(handle) != handleSize {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(handle), true
+}
@@ -0,0 +1,93 @@
+package nfs
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func newTestInodeToPath(t *testing.T, dir string, maxHandles int) *InodeToPath {
+	i, err := NewInodeToPath(dir, maxHandles)
+	if err != nil {
+		t.Fatalf("new inode to path: %v", err)
+	}
+	return i
+}
+
+func TestInodeToPathMovePath(t *testing.T) {
+	i := newTestInodeToPath(t, t.TempDir(), DefaultMaxHandles)
+	defer i.Close()
+	dir := i.Lookup("/a")
+	file := i.Lookup("/a/f")
+	sibling := i.Lookup("/ab")
+	target := i.Lookup("/b")
+
+	i.MovePath("/a", "/b")
+
+	for inode, want := range map[uint64]util.FullPath{dir: "/b", file: "/b/f", sibling: "/ab"} {
+		if p, found := i.GetPath(inode); !found || p != want {
+			t.Errorf("inode %d: path %s, want %s", inode, p, want)
+		}
+	}
+	if _, found := i.GetPath(target); found {
+		t.Errorf("the replaced target inode %d is still known", target)
+	}
+	if _, found := i.GetInode("/a/f"); found {
+		t.Errorf("the source path is still known")
+	}
+}
+
+func TestInodeToPathRestart(t *testing.T) {
+	dir := t.TempDir()
+	i := newTestInodeToPath(t, dir, DefaultMaxHandles)
+	first := i.Lookup("/x")
+	i.RemovePath("/x")
+	second := i.Lookup("/x")
+	if first == second || second == rootInode {
+		t.Errorf("inode %d is reused", second)
+	}
+	i.Close()
+
+	// the handles stay valid after a restart, and the removed inodes are not reused
+	i = newTestInodeToPath(t, dir, DefaultMaxHandles)
+	defer i.Close()
+	if p, found := i.GetPath(second); !found || p != "/x" {
+		t.Errorf("inode %d: path %s after restart", second, p)
+	}
+	if _, found := i.GetPath(first); found {
+		t.Errorf("the removed inode %d is resolved", first)
+	}
+	if third := i.Lookup("/y"); third <= second {
+		t.Errorf("inode %d is allocated again", third)
+	}
+	if inode, ok := handleToInode(inodeToHandle(second)); !ok || inode != second {
+		t.Errorf("handle round trip %d", inode)
+	}
+}
+
+func TestInodeToPathEviction(t *testing.T) {
+	i := newTestInodeToPath(t, t.TempDir(), 8)
+	defer i.Close()
+	root := i.LookupRoot("/export")
+	used := i.Lookup("/used")
+	var inodes []uint64
+	for n := 0; n < 20; n++ {
+		inodes = append(inodes, i.Lookup(util.FullPath("/f").Child(string(rune('a'+n)))))
+		i.GetPath(used)
+	}
+	if i.count > 8 {
+		t.Errorf("%d inodes kept", i.count)
+	}
+	if _, found := i.GetPath(inodes[0]); found {
+		t.Errorf("the oldest inode is not evicted")
+	}
+	if p, found := i.GetPath(inodes[19]); !found || p != "/f/t" {
+		t.Errorf("the newest inode: path %s", p)
+	}
+	if p, found := i.GetPath(root); !found || p != "/export" {
+		t.Errorf("the export root is evicted")
+	}
+	if p, found := i.GetPath(used); !found || p != "/used" {
+		t.Errorf("the inode in use is evicted")
+	}
+}
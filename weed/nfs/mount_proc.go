@@ -0,0 +1,161 @@
+package nfs
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// the MOUNT protocol version 3, RFC 1813 appendix I
+
+const (
+	mountProgram = 100005
+	mountVersion = 3
+
+	mountProcNull    = 0
+	mountProcMnt     = 1
+	mountProcDump    = 2
+	mountProcUmnt    = 3
+	mountProcUmntAll = 4
+	mountProcExport  = 5
+
+	mnt3Ok        = 0
+	mnt3ErrNoEnt  = 2
+	mnt3ErrIO     = 5
+	mnt3ErrAcces  = 13
+	mnt3ErrNotDir = 20
+
+	mountMaxPathLength = 1024
+)
+
+// mountList records the directories mounted by the clients, for DUMP
+type mountList struct {
+	sync.Mutex
+	mounts map[string]map[util.FullPath]struct{} // client to the mounted directories
+}
+
+func (s *NfsServer) mountProcedures() map[uint32]rpcProcedure {
+	mounts := &mountList{
+		mounts: make(map[string]map[util.FullPath]struct{}),
+	}
+	return map[uint32]rpcProcedure{
+		mountProcNull: func(req *rpcRequest) error {
+			return nil
+		},
+		mountProcMnt: s.mountMnt(mounts),
+		mountProcDump: func(req *rpcRequest) error {
+			mounts.Lock()
+			defer mounts.Unlock()
+			for client, dirs := range mounts.mounts {
+				for dir := range dirs {
+					req.results.Bool(true)
+					req.results.String(client)
+					req.results.String(string(dir))
+				}
+			}
+			req.results.Bool(false)
+			return nil
+		},
+		mountProcUmnt: func(req *rpcRequest) error {
+			dirPath := req.args.String(mountMaxPathLength)
+			if req.args.err != nil {
+				return errGarbageArgs
+			}
+			mounts.Lock()
+			defer mounts.Unlock()
+			client := req.client.String()
+			delete(mounts.mounts[client], cleanMountPath(dirPath))
+			if len(mounts.mounts[client]) == 0 {
+				delete(mounts.mounts, client)
+			}
+			return nil
+		},
+		mountProcUmntAll: func(req *rpcRequest) error {
+			mounts.Lock()
+			defer mounts.Unlock()
+			delete(mounts.mounts, req.client.String())
+			return nil
+		},
+		mountProcExport: s.mountExport,
+	}
+}
+
+func (s *NfsServer) mountMnt(mounts *mountList) rpcProcedure {
+	return func(req *rpcRequest) error {
+		dirPath := req.args.String(mountMaxPathLength)
+		if req.args.err != nil {
+			return errGarbageArgs
+		}
+		fullPath := cleanMountPath(dirPath)
+
+		_, rule := s.option.Exports.Match(fullPath, req.client)
+		if rule == nil {
+			glog.V(0).Infof("nfs client %v is not allowed to mount %s", req.client, fullPath)
+			req.results.Uint32(mnt3ErrAcces)
+			return nil
+		}
+		inode, entry, err := s.lookupPath(fullPath)
+		if err != nil {
+			glog.V(0).Infof("nfs client %v mount %s: %v", req.client, fullPath, err)
+			if toNfsStatus(err) == nfs3ErrNoEnt {
+				req.results.Uint32(mnt3ErrNoEnt)
+			} else {
+				req.results.Uint32(mnt3ErrIO)
+			}
+			return nil
+		}
+		if !entry.IsDirectory {
+			req.results.Uint32(mnt3ErrNotDir)
+			return nil
+		}
+
+		mounts.Lock()
+		client := req.client.String()
+		if mounts.mounts[client] == nil {
+			mounts.mounts[client] = make(map[util.FullPath]struct{})
+		}
+		mounts.mounts[client][fullPath] = struct{}{}
+		mounts.Unlock()
+		glog.V(0).Infof("nfs client %v mounted %s", req.client, fullPath)
+
+		req.results.Uint32(mnt3Ok)
+		req.results.Opaque(inodeToHandle(inode))
+		// the accepted auth flavors
+		req.results.Uint32(1)
+		req.results.Uint32(rpcAuthUnix)
+		return nil
+	}
+}
+
+func (s *NfsServer) mountExport(req *rpcRequest) error {
+	exports := append([]*Export(nil), s.option.Exports.List()...)
+	sort.Slice(exports, func(i, j int) bool {
+		return exports[i].Path < exports[j].Path
+	})
+	for _, export := range exports {
+		req.results.Bool(true)
+		req.results.String(string(export.Path))
+		for _, rule := range export.Rules {
+			req.results.Bool(true)
+			if rule.Network == nil {
+				req.results.String("*")
+			} else {
+				req.results.String(rule.Network.String())
+			}
+		}
+		req.results.Bool(false)
+	}
+	req.results.Bool(false)
+	return nil
+}
+
+func cleanMountPath(dirPath string) util.FullPath {
+	if !strings.HasPrefix(dirPath, "/") {
+		dirPath = "/" + dirPath
+	}
+	return util.FullPath(path.Clean(dirPath))
+}
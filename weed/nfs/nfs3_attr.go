@@ -0,0 +1,344 @@
+package nfs
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+// the status codes of NFS version 3, RFC 1813
+type nfsStatus uint32
+
+const (
+	nfs3Ok             nfsStatus = 0
+	nfs3ErrPerm        nfsStatus = 1
+	nfs3ErrNoEnt       nfsStatus = 2
+	nfs3ErrIO          nfsStatus = 5
+	nfs3ErrAcces       nfsStatus = 13
+	nfs3ErrExist       nfsStatus = 17
+	nfs3ErrXDev        nfsStatus = 18
+	nfs3ErrNotDir      nfsStatus = 20
+	nfs3ErrIsDir       nfsStatus = 21
+	nfs3ErrInval       nfsStatus = 22
+	nfs3ErrNoSpc       nfsStatus = 28
+	nfs3ErrRofs        nfsStatus = 30
+	nfs3ErrNameTooLong nfsStatus = 63
+	nfs3ErrNotEmpty    nfsStatus = 66
+	nfs3ErrDQuot       nfsStatus = 69
+	nfs3ErrStale       nfsStatus = 70
+	nfs3ErrBadHandle   nfsStatus = 10001
+	nfs3ErrNotSync     nfsStatus = 10002
+	nfs3ErrNotSupp     nfsStatus = 10004
+	nfs3ErrTooSmall    nfsStatus = 10005
+	nfs3ErrServerFault nfsStatus = 10006
+	nfs3ErrBadType     nfsStatus = 10007
+)
+
+// file types
+const (
+	nf3Reg  = 1
+	nf3Dir  = 2
+	nf3Blk  = 3
+	nf3Chr  = 4
+	nf3Lnk  = 5
+	nf3Sock = 6
+	nf3Fifo = 7
+)
+
+// access bits
+const (
+	access3Read    = 0x0001
+	access3Lookup  = 0x0002
+	access3Modify  = 0x0004
+	access3Extend  = 0x0008
+	access3Delete  = 0x0010
+	access3Execute = 0x0020
+)
+
+const (
+	timeDontChange    = 0
+	timeSetToServer   = 1
+	timeSetToClient   = 2
+	maxNameLength     = 255
+	blockSize         = 512
+	nfsMaxHandleBytes = 64
+)
+
+var errIsDir = errors.New("is a directory")
+
+func toNfsStatus(err error) nfsStatus {
+	switch {
+	case err == nil:
+		return nfs3Ok
+	case err == filer_pb.ErrNotFound || strings.Contains(err.Error(), filer_pb.ErrNotFound.Error()):
+		return nfs3ErrNoEnt
+	case err == errIsDir:
+		return nfs3ErrIsDir
+	case filer.IsQuotaExceededError(err):
+		return nfs3ErrDQuot
+	case strings.Contains(err.Error(), "EEXIST"):
+		return nfs3ErrExist
+	case strings.Contains(err.Error(), filer.MsgFailDelNonEmptyFolder):
+		return nfs3ErrNotEmpty
+	}
+	return nfs3ErrIO
+}
+
+func fileType(entry *filer_pb.Entry) uint32 {
+	if entry.IsDirectory {
+		return nf3Dir
+	}
+	switch os.FileMode(entry.Attributes.GetFileMode()) & os.ModeType {
+	case os.ModeDir:
+		return nf3Dir
+	case os.ModeSymlink:
+		return nf3Lnk
+	case os.ModeNamedPipe:
+		return nf3Fifo
+	case os.ModeSocket:
+		return nf3Sock
+	case os.ModeDevice:
+		return nf3Blk
+	case os.ModeDevice | os.ModeCharDevice:
+		return nf3Chr
+	}
+	return nf3Reg
+}
+
+// unixMode converts the os.FileMode permission bits to the unix ones
+func unixMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+// chmod sets the unix permission bits, keeping the file type
+func chmod(existing uint32, mode uint32) uint32 {
+	fileMode := os.FileMode(existing)&^(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) | os.FileMode(mode&0777)
+	if mode&04000 != 0 {
+		fileMode |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		fileMode |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		fileMode |= os.ModeSticky
+	}
+	return uint32(fileMode)
+}
+
+func (s *NfsServer) rootEntry() *filer_pb.Entry {
+	return &filer_pb.Entry{
+		Name:        "/",
+		IsDirectory: true,
+		Attributes: &filer_pb.FuseAttributes{
+			FileMode: uint32(os.ModeDir | 0777),
+			Mtime:    s.startTime.Unix(),
+			Crtime:   s.startTime.Unix(),
+		},
+	}
+}
+
+func writeNfsTime(w *xdrWriter, unixTime int64) {
+	w.Uint32(uint32(unixTime))
+	w.Uint32(0)
+}
+
+func readNfsTime(r *xdrReader) int64 {
+	seconds := r.Uint32()
+	r.Uint32() // nanoseconds
+	return int64(seconds)
+}
+
+// writeFattr encodes fattr3
+func (s *NfsServer) writeFattr(w *xdrWriter, inode uint64, entry *filer_pb.Entry) {
+	attr := entry.Attributes
+	if attr == nil {
+		attr = &filer_pb.FuseAttributes{}
+	}
+	size := filer.FileSize(entry)
+	nlink := uint32(1)
+	if entry.IsDirectory {
+		nlink = 2
+		size = 4096
+	} else if entry.HardLinkCounter > 0 {
+		nlink = uint32(entry.HardLinkCounter)
+	}
+	if attr.SymlinkTarget != "" {
+		size = uint64(len(attr.SymlinkTarget))
+	}
+
+	w.Uint32(fileType(entry))
+	w.Uint32(unixMode(os.FileMode(attr.FileMode)))
+	w.Uint32(nlink)
+	w.Uint32(attr.Uid)
+	w.Uint32(attr.Gid)
+	w.Uint64(size)
+	w.Uint64((size + blockSize - 1) / blockSize * blockSize) // used
+	w.Uint32(attr.Rdev >> 8 & 0xfff)                         // specdata1, the major number
+	w.Uint32(attr.Rdev&0xff | attr.Rdev>>12&0xfff00)         // specdata2, the minor number
+	w.Uint64(s.fsid)
+	w.Uint64(inode)
+	writeNfsTime(w, attr.Mtime) // atime
+	writeNfsTime(w, attr.Mtime)
+	writeNfsTime(w, attr.Mtime) // ctime
+}
+
+func (s *NfsServer) writePostOpAttr(w *xdrWriter, inode uint64, entry *filer_pb.Entry) {
+	if entry == nil {
+		w.Bool(false)
+		return
+	}
+	w.Bool(true)
+	s.writeFattr(w, inode, entry)
+}
+
+// writeWccData encodes the weak cache consistency data, with the attributes before and after the change
+func (s *NfsServer) writeWccData(w *xdrWriter, inode uint64, before, after *filer_pb.Entry) {
+	if before == nil {
+		w.Bool(false)
+	} else {
+		w.Bool(true)
+		w.Uint64(filer.FileSize(before))
+		writeNfsTime(w, before.Attributes.GetMtime())
+		writeNfsTime(w, before.Attributes.GetMtime())
+	}
+	s.writePostOpAttr(w, inode, after)
+}
+
+func writePostOpHandle(w *xdrWriter, inode uint64) {
+	w.Bool(true)
+	w.Opaque(inodeToHandle(inode))
+}
+
+// sattr3, the attributes to set
+type setAttributes struct {
+	mode, uid, gid *uint32
+	size           *uint64
+	atime, mtime   *int64
+}
+
+func readSetAttributes(r *xdrReader) *setAttributes {
+	sa := &setAttributes{}
+	readUint32 := func() *uint32 {
+		if !r.Bool() {
+			return nil
+		}
+		v := r.Uint32()
+		return &v
+	}
+	readTime := func() *int64 {
+		var v int64
+		switch r.Uint32() {
+		case timeSetToServer:
+			v = time.Now().Unix()
+		case timeSetToClient:
+			v = readNfsTime(r)
+		default:
+			return nil
+		}
+		return &v
+	}
+	sa.mode = readUint32()
+	sa.uid = readUint32()
+	sa.gid = readUint32()
+	if r.Bool() {
+		size := r.Uint64()
+		sa.size = &size
+	}
+	sa.atime = readTime()
+	sa.mtime = readTime()
+	return sa
+}
+
+// apply sets the attributes except the size
+func (sa *setAttributes) apply(entry *filer_pb.Entry) {
+	if sa.mode != nil {
+		entry.Attributes.FileMode = chmod(entry.Attributes.FileMode, *sa.mode)
+	}
+	if sa.uid != nil {
+		entry.Attributes.Uid = *sa.uid
+	}
+	if sa.gid != nil {
+		entry.Attributes.Gid = *sa.gid
+	}
+	if sa.mtime != nil {
+		entry.Attributes.Mtime = *sa.mtime
+	}
+}
+
+// caller is the squashed identity of the client user
+type caller struct {
+	uid  uint32
+	gid  uint32
+	gids []uint32
+}
+
+func (c *caller) inGroup(gid uint32) bool {
+	if c.gid == gid {
+		return true
+	}
+	for _, g := range c.gids {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// permissions returns the rwx bits of the entry for the caller
+func (c *caller) permissions(entry *filer_pb.Entry) uint32 {
+	mode := entry.Attributes.GetFileMode() & 0777
+	if c.uid == 0 {
+		perm := uint32(06)
+		if entry.IsDirectory || mode&0111 != 0 {
+			perm |= 01
+		}
+		return perm
+	}
+	switch {
+	case c.uid == entry.Attributes.GetUid():
+		return mode >> 6 & 07
+	case c.inGroup(entry.Attributes.GetGid()):
+		return mode >> 3 & 07
+	}
+	return mode & 07
+}
+
+func (c *caller) isOwner(entry *filer_pb.Entry) bool {
+	return c.uid == 0 || c.uid == entry.Attributes.GetUid()
+}
+
+// access computes the ACCESS3 bits granted to the caller
+func (c *caller) access(entry *filer_pb.Entry, readOnly bool) (access uint32) {
+	perm := c.permissions(entry)
+	if perm&04 != 0 {
+		access |= access3Read
+	}
+	if perm&02 != 0 && !readOnly {
+		access |= access3Modify | access3Extend
+		if entry.IsDirectory {
+			access |= access3Delete
+		}
+	}
+	if perm&01 != 0 {
+		if entry.IsDirectory {
+			access |= access3Lookup
+		} else {
+			access |= access3Execute
+		}
+	}
+	return
+}
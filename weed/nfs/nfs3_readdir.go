@@ -0,0 +1,192 @@
+package nfs
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+// The directory listing is kept for the following READDIR requests of the same client,
+// so the cookies are the positions in the listing, and the cookie verifier identifies the listing.
+
+const (
+	dirListTtl = 30 * time.Second
+
+	// the approximate sizes of the encoded results
+	readDirHeaderSize    = 128
+	readDirEntrySize     = 24
+	readDirPlusEntrySize = readDirEntrySize + 4 + 84 + 4 + 4 + handleSize
+)
+
+type dirList struct {
+	verifier uint64
+	entries  []*filer_pb.Entry
+	listedAt time.Time
+}
+
+type dirListCache struct {
+	sync.Mutex
+	lists        map[uint64]*dirList
+	lastVerifier uint64
+}
+
+func newDirListCache() *dirListCache {
+	return &dirListCache{
+		lists:        make(map[uint64]*dirList),
+		lastVerifier: uint64(time.Now().UnixNano()),
+	}
+}
+
+func (c *dirListCache) get(inode uint64, verifier uint64) *dirList {
+	c.Lock()
+	defer c.Unlock()
+	list, found := c.lists[inode]
+	if !found || list.verifier != verifier || time.Since(list.listedAt) > dirListTtl {
+		return nil
+	}
+	return list
+}
+
+func (c *dirListCache) put(inode uint64, entries []*filer_pb.Entry) *dirList {
+	c.Lock()
+	defer c.Unlock()
+	for i, list := range c.lists {
+		if time.Since(list.listedAt) > dirListTtl {
+			delete(c.lists, i)
+		}
+	}
+	c.lastVerifier++
+	list := &dirList{
+		verifier: c.lastVerifier,
+		entries:  entries,
+		listedAt: time.Now(),
+	}
+	c.lists[inode] = list
+	return list
+}
+
+func (c *dirListCache) invalidate(inode uint64) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.lists, inode)
+}
+
+func (s *NfsServer) nfsReadDir(req *rpcRequest) error {
+	return s.readDir(req, false)
+}
+
+func (s *NfsServer) nfsReadDirPlus(req *rpcRequest) error {
+	return s.readDir(req, true)
+}
+
+func (s *NfsServer) readDir(req *rpcRequest, plus bool) error {
+	handle := req.args.Opaque(nfsMaxHandleBytes)
+	cookie := req.args.Uint64()
+	cookieVerifier := req.args.FixedOpaque(8)
+	if plus {
+		req.args.Uint32() // dircount
+	}
+	maxCount := req.args.Uint32()
+	if req.args.err != nil {
+		return errGarbageArgs
+	}
+
+	dir, status := s.resolve(req, handle)
+	if status == nfs3Ok && !dir.entry.IsDirectory {
+		status = nfs3ErrNotDir
+	}
+	if status == nfs3Ok && dir.caller.permissions(dir.entry)&04 == 0 {
+		status = nfs3ErrAcces
+	}
+	var list *dirList
+	if status == nfs3Ok {
+		if cookie > 0 {
+			list = s.dirLists.get(dir.inode, binary.BigEndian.Uint64(cookieVerifier))
+		}
+		if list == nil {
+			var entries []*filer_pb.Entry
+			if err := filer_pb.ReadDirAllEntries(s, dir.path, "", func(entry *filer_pb.Entry, isLast bool) error {
+				if entry.Attributes == nil {
+					entry.Attributes = &filer_pb.FuseAttributes{}
+				}
+				entries = append(entries, entry)
+				return nil
+			}); err != nil {
+				glog.Errorf("nfs readdir %s: %v", dir.path, err)
+				status = toNfsStatus(err)
+			} else {
+				list = s.dirLists.put(dir.inode, entries)
+			}
+		}
+	}
+	if status != nfs3Ok {
+		req.results.Uint32(uint32(status))
+		if dir == nil {
+			s.writePostOpAttr(req.results, 0, nil)
+		} else {
+			s.writePostOpAttr(req.results, dir.inode, dir.entry)
+		}
+		return nil
+	}
+
+	// the positions 0 and 1 are "." and "..", followed by the listed entries
+	entries := &xdrWriter{}
+	size, eof := readDirHeaderSize, true
+	for position := cookie; position < uint64(len(list.entries))+2; position++ {
+		var name string
+		var inode uint64
+		var entry *filer_pb.Entry
+		switch position {
+		case 0:
+			name, inode, entry = ".", dir.inode, dir.entry
+		case 1:
+			var err error
+			if inode, entry, err = s.lookupChild(dir, ".."); err != nil {
+				inode, entry = dir.inode, dir.entry
+			}
+			name = ".."
+		default:
+			entry = list.entries[position-2]
+			name = entry.Name
+			inode = s.inodeToPath.Lookup(dir.path.Child(name))
+		}
+
+		entrySize := readDirEntrySize + len(name) + xdrPadding(len(name))
+		if plus {
+			entrySize = readDirPlusEntrySize + len(name) + xdrPadding(len(name))
+		}
+		if size+entrySize > int(maxCount) {
+			eof = false
+			break
+		}
+		size += entrySize
+
+		entries.Bool(true)
+		entries.Uint64(inode)
+		entries.String(name)
+		entries.Uint64(position + 1)
+		if plus {
+			if dirtyEntry := s.files.dirtyEntry(inode); dirtyEntry != nil {
+				entry = dirtyEntry
+			}
+			s.writePostOpAttr(entries, inode, entry)
+			writePostOpHandle(entries, inode)
+		}
+	}
+	if entries.Len() == 0 && !eof {
+		req.results.Uint32(uint32(nfs3ErrTooSmall))
+		s.writePostOpAttr(req.results, dir.inode, dir.entry)
+		return nil
+	}
+
+	req.results.Uint32(uint32(nfs3Ok))
+	s.writePostOpAttr(req.results, dir.inode, dir.entry)
+	req.results.FixedOpaque(binary.BigEndian.AppendUint64(nil, list.verifier))
+	req.results.FixedOpaque(entries.Bytes())
+	req.results.Bool(false) // no more entries in this reply
+	req.results.Bool(eof)
+	return nil
+}
@@ -0,0 +1,440 @@
+package nfs
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/util/chunk_cache"
+	"github.com/seaweedfs/seaweedfs/weed/wdclient"
+)
+
+type NfsOption struct {
+	Filer          pb.ServerAddress
+	GrpcDialOption grpc.DialOption
+	Port           int
+	Exports        *Exports
+	Collection     string
+	Replication    string
+	DiskType       string
+	Cipher         bool
+	CacheDir       string
+	CacheSizeMB    int64
+}
+
+type NfsServer struct {
+	option      *NfsOption
+	signature   int32
+	startTime   time.Time
+	fsid        uint64
+	verifier    []byte // changes on restart, so the clients resend the uncommitted writes
+	inodeToPath *InodeToPath
+	chunkCache  *chunk_cache.TieredChunkCache
+	lookupFn    wdclient.LookupFileIdFunctionType
+	files       *openFiles
+	dirLists    *dirListCache
+	locks       *nlmLocks
+	rpc         *rpcServer
+}
+
+var _ = filer_pb.FilerClient(&NfsServer{})
+
+func NewNfsServer(option *NfsOption) (*NfsServer, error) {
+
+	cacheUniqueId := util.Md5String([]byte("nfs" + string(option.Filer) + util.Version()))[0:8]
+	cacheDir := path.Join(option.CacheDir, cacheUniqueId)
+	if err := os.MkdirAll(cacheDir, os.FileMode(0755)); err != nil {
+		return nil, fmt.Errorf("create cache dir %s: %v", cacheDir, err)
+	}
+	// the handles are kept across restarts, also after upgrading
+	handlesUniqueId := util.Md5String([]byte("nfs" + string(option.Filer)))[0:8]
+	inodeToPath, err := NewInodeToPath(path.Join(option.CacheDir, "handles", handlesUniqueId), DefaultMaxHandles)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &NfsServer{
+		option:      option,
+		signature:   util.RandomInt32(),
+		startTime:   time.Now(),
+		fsid:        uint64(util.HashStringToLong(string(option.Filer))),
+		verifier:    make([]byte, 8),
+		inodeToPath: inodeToPath,
+		chunkCache:  chunk_cache.NewTieredChunkCache(256, cacheDir, option.CacheSizeMB, 1024*1024),
+		files:       newOpenFiles(),
+		dirLists:    newDirListCache(),
+		rpc:         newRpcServer(),
+	}
+	if _, err := io.ReadFull(rand.Reader, s.verifier); err != nil {
+		return nil, err
+	}
+	s.lookupFn = filer.LookupFn(s)
+	s.locks = newNlmLocks(s)
+
+	// allocate the handles of the export roots first, so they do not change after restarts
+	for _, export := range option.Exports.List() {
+		s.inodeToPath.LookupRoot(export.Path)
+	}
+
+	s.rpc.register(portmapProgram, portmapVersion, portmapVersion, s.portmapProcedures())
+	s.rpc.register(mountProgram, mountVersion, mountVersion, s.mountProcedures())
+	s.rpc.register(nfsProgram, nfsVersion, nfsVersion, s.nfsProcedures())
+	s.rpc.register(nlmProgram, nlmVersion, nlmVersion, s.nlmProcedures())
+
+	go s.loopFlushFiles()
+	go s.locks.loopRenewLocks()
+
+	return s, nil
+}
+
+// Serve serves the port mapper, MOUNT, NFS and NLM programs on the listener.
+// It can be called for more than one listener.
+func (s *NfsServer) Serve(listener net.Listener) error {
+	return s.rpc.serve(listener)
+}
+
+func (s *NfsServer) WithFilerClient(streamingMode bool, fn func(filer_pb.SeaweedFilerClient) error) error {
+
+	return pb.WithGrpcClient(streamingMode, s.signature, func(grpcConnection *grpc.ClientConn) error {
+		client := filer_pb.NewSeaweedFilerClient(grpcConnection)
+		return fn(client)
+	}, s.option.Filer.ToGrpcAddress(), false, s.option.GrpcDialOption)
+
+}
+func (s *NfsServer) AdjustedUrl(location *filer_pb.Location) string {
+	return location.Url
+}
+func (s *NfsServer) GetDataCenter() string {
+	return ""
+}
+
+func (s *NfsServer) saveDataAsChunk(fullPath util.FullPath) filer.SaveDataAsChunkFunctionType {
+
+	return func(reader io.Reader, filename string, offset int64, tsNs int64) (chunk *filer_pb.FileChunk, err error) {
+		uploader, uploaderErr := operation.NewUploader()
+		if uploaderErr != nil {
+			return nil, fmt.Errorf("upload data: %v", uploaderErr)
+		}
+
+		fileId, uploadResult, flushErr, _ := uploader.UploadWithRetry(
+			s,
+			&filer_pb.AssignVolumeRequest{
+				Count:       1,
+				Replication: s.option.Replication,
+				Collection:  s.option.Collection,
+				DiskType:    s.option.DiskType,
+				Path:        string(fullPath),
+			},
+			&operation.UploadOption{
+				Filename:          filename,
+				Cipher:            s.option.Cipher,
+				IsInputCompressed: false,
+				MimeType:          "",
+				PairMap:           nil,
+			},
+			func(host, fileId string) string {
+				return fmt.Sprintf("http://%s/%s", host, fileId)
+			},
+			reader,
+		)
+
+		if flushErr != nil {
+			glog.V(0).Infof("upload data %v: %v", fullPath, flushErr)
+			return nil, fmt.Errorf("upload data: %v", flushErr)
+		}
+		if uploadResult.Error != "" {
+			glog.V(0).Infof("upload failure %v: %v", fullPath, uploadResult.Error)
+			return nil, fmt.Errorf("upload result: %v", uploadResult.Error)
+		}
+		return uploadResult.ToPbFileChunk(fileId, offset, tsNs), nil
+	}
+}
+
+// the files being read or written, with the uncommitted writes
+
+const (
+	fileRevalidateInterval = time.Second
+	fileFlushDelay         = 3 * time.Second
+	fileEvictDelay         = 30 * time.Second
+)
+
+type openFile struct {
+	sync.Mutex
+	path       util.FullPath
+	entry      *filer_pb.Entry
+	chunkGroup *filer.ChunkGroup
+	dirty      bool
+	loadedAt   time.Time
+	modifiedAt time.Time
+	accessedAt time.Time
+}
+
+type openFiles struct {
+	sync.Mutex
+	files map[uint64]*openFile
+}
+
+func newOpenFiles() *openFiles {
+	return &openFiles{
+		files: make(map[uint64]*openFile),
+	}
+}
+
+func (of *openFiles) get(inode uint64) *openFile {
+	of.Lock()
+	defer of.Unlock()
+	return of.files[inode]
+}
+
+// dirtyEntry returns a copy of the entry with the uncommitted writes
+func (of *openFiles) dirtyEntry(inode uint64) *filer_pb.Entry {
+	file := of.get(inode)
+	if file == nil {
+		return nil
+	}
+	file.Lock()
+	defer file.Unlock()
+	if !file.dirty {
+		return nil
+	}
+	return file.snapshot()
+}
+
+func (file *openFile) getEntry() *filer_pb.Entry {
+	file.Lock()
+	defer file.Unlock()
+	return file.snapshot()
+}
+
+func (file *openFile) snapshot() *filer_pb.Entry {
+	return proto.Clone(file.entry).(*filer_pb.Entry)
+}
+
+// openFile loads the file for reading or writing. Without uncommitted writes,
+// the entry is reloaded from the filer to see the changes by other clients.
+func (s *NfsServer) openFile(inode uint64, fullPath util.FullPath) (*openFile, error) {
+	s.files.Lock()
+	file, found := s.files.files[inode]
+	if !found {
+		file = &openFile{path: fullPath}
+		s.files.files[inode] = file
+	}
+	s.files.Unlock()
+
+	file.Lock()
+	defer file.Unlock()
+	file.path = fullPath
+	file.accessedAt = time.Now()
+	if file.dirty || time.Since(file.loadedAt) < fileRevalidateInterval {
+		return file, nil
+	}
+
+	entry, err := filer_pb.GetEntry(s, fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry.IsDirectory {
+		return nil, errIsDir
+	}
+	if entry.Attributes == nil {
+		entry.Attributes = &filer_pb.FuseAttributes{}
+	}
+	if file.chunkGroup == nil || !isSameData(file.entry, entry) {
+		if file.chunkGroup, err = filer.NewChunkGroup(s.lookupFn, s.chunkCache, entry.GetChunks()); err != nil {
+			return nil, err
+		}
+	}
+	file.entry = entry
+	file.loadedAt = time.Now()
+	return file, nil
+}
+
+func isSameData(a, b *filer_pb.Entry) bool {
+	return a.Attributes.GetFileSize() == b.Attributes.GetFileSize() &&
+		len(a.Chunks) == len(b.Chunks) &&
+		filer.ETagChunks(a.Chunks) == filer.ETagChunks(b.Chunks) &&
+		string(a.Content) == string(b.Content)
+}
+
+func (s *NfsServer) readFile(file *openFile, buff []byte, offset int64) (n int, eof bool, err error) {
+	file.Lock()
+	defer file.Unlock()
+
+	fileSize := int64(filer.FileSize(file.entry))
+	if offset >= fileSize {
+		return 0, true, nil
+	}
+	if int64(len(buff)) > fileSize-offset {
+		buff = buff[:fileSize-offset]
+	}
+	if len(file.entry.Content) > 0 {
+		n = copy(buff, file.entry.Content[min(offset, int64(len(file.entry.Content))):])
+		for i := n; i < len(buff); i++ {
+			buff[i] = 0
+		}
+		n = len(buff)
+	} else {
+		n, _, err = file.chunkGroup.ReadDataAt(fileSize, buff, offset)
+		if err == io.EOF {
+			err = nil
+		}
+	}
+	return n, offset+int64(n) >= fileSize, err
+}
+
+// writeFile uploads the data as a chunk, and keeps the entry uncommitted until the client commits it
+func (s *NfsServer) writeFile(file *openFile, data []byte, offset int64) error {
+	tsNs := time.Now().UnixNano()
+	chunk, err := s.saveDataAsChunk(file.path)(util.NewBytesReader(data), file.path.Name(), offset, tsNs)
+	if err != nil {
+		return err
+	}
+
+	file.Lock()
+	defer file.Unlock()
+
+	if len(file.entry.Content) > 0 {
+		// move the inline content to a chunk, before the newer writes
+		contentChunk, err := s.saveDataAsChunk(file.path)(util.NewBytesReader(file.entry.Content), file.path.Name(), 0, tsNs-1)
+		if err != nil {
+			return err
+		}
+		file.entry.Content = nil
+		file.entry.Chunks = append(file.entry.Chunks, contentChunk)
+		file.chunkGroup.AddChunk(contentChunk)
+	}
+
+	file.entry.Chunks = append(file.entry.Chunks, chunk)
+	file.chunkGroup.AddChunk(chunk)
+	file.entry.Attributes.FileSize = uint64(max(int64(file.entry.Attributes.FileSize), offset+int64(len(data))))
+	file.entry.Attributes.Mtime = time.Now().Unix()
+	file.dirty = true
+	file.modifiedAt = time.Now()
+	return nil
+}
+
+// setFileAttributes changes the file size and the attributes, and commits the entry
+func (s *NfsServer) setFileAttributes(file *openFile, sa *setAttributes) (*filer_pb.Entry, error) {
+	file.Lock()
+	defer file.Unlock()
+
+	entry := file.entry
+	if sa.size != nil {
+		size := *sa.size
+		if size < filer.FileSize(entry) {
+			if uint64(len(entry.Content)) > size {
+				entry.Content = entry.Content[:size]
+			}
+			var chunks []*filer_pb.FileChunk
+			for _, chunk := range entry.GetChunks() {
+				int64Size := int64(chunk.Size)
+				if chunk.Offset+int64Size > int64(size) {
+					// this chunk is truncated
+					int64Size = int64(size) - chunk.Offset
+					if int64Size > 0 {
+						chunks = append(chunks, chunk)
+						chunk.Size = uint64(int64Size)
+					}
+				} else {
+					chunks = append(chunks, chunk)
+				}
+			}
+			entry.Chunks = chunks
+			file.chunkGroup.SetChunks(chunks)
+		}
+		entry.Attributes.FileSize = size
+		entry.Attributes.Mtime = time.Now().Unix()
+	}
+	sa.apply(entry)
+	delete(entry.Extended, createVerifierKey)
+
+	file.dirty = true
+	if err := s.doFlushFile(file); err != nil {
+		return nil, err
+	}
+	return file.snapshot(), nil
+}
+
+func (s *NfsServer) flushFile(file *openFile) error {
+	file.Lock()
+	defer file.Unlock()
+	return s.doFlushFile(file)
+}
+
+func (s *NfsServer) doFlushFile(file *openFile) error {
+	if !file.dirty {
+		return nil
+	}
+	entry := file.entry
+	dir, name := file.path.DirAndName()
+	entry.Name = name
+
+	manifestChunks, nonManifestChunks := filer.SeparateManifestChunks(entry.GetChunks())
+	chunks, _ := filer.CompactFileChunks(s.lookupFn, nonManifestChunks)
+	chunks, manifestErr := filer.MaybeManifestize(s.saveDataAsChunk(file.path), chunks)
+	if manifestErr != nil {
+		// not good, but should be ok
+		glog.V(0).Infof("MaybeManifestize %s: %v", file.path, manifestErr)
+	}
+	entry.Chunks = append(chunks, manifestChunks...)
+
+	if err := s.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		return filer_pb.CreateEntry(client, &filer_pb.CreateEntryRequest{
+			Directory:                dir,
+			Entry:                    entry,
+			Signatures:               []int32{s.signature},
+			SkipCheckParentDirectory: true,
+		})
+	}); err != nil {
+		return fmt.Errorf("flush %s: %v", file.path, err)
+	}
+	file.dirty = false
+	file.loadedAt = time.Now()
+	return nil
+}
+
+// loopFlushFiles commits the idle files, so other clients can see the writes
+// even if the client does not commit, and forgets the files not accessed recently
+func (s *NfsServer) loopFlushFiles() {
+	for {
+		time.Sleep(time.Second)
+		s.files.Lock()
+		var files []*openFile
+		for inode, file := range s.files.files {
+			file.Lock()
+			if !file.dirty && time.Since(file.accessedAt) > fileEvictDelay {
+				delete(s.files.files, inode)
+			} else if file.dirty && time.Since(file.modifiedAt) > fileFlushDelay {
+				files = append(files, file)
+			}
+			file.Unlock()
+		}
+		s.files.Unlock()
+		for _, file := range files {
+			if err := s.flushFile(file); err != nil {
+				glog.Errorf("%v", err)
+			}
+		}
+	}
+}
+
+// forgetFile drops the uncommitted writes of a deleted file
+func (s *NfsServer) forgetFile(inode uint64) {
+	s.files.Lock()
+	defer s.files.Unlock()
+	delete(s.files.files, inode)
+}
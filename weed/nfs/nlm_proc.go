@@ -0,0 +1,402 @@
+package nfs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/seaweedfs/seaweedfs/weed/cluster/lock_manager"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// the network lock manager version 4, for the byte range locks of the NFS version 3 clients.
+// The locks are kept by the distributed lock manager of the filers with the same names as the mount,
+// so the locks are seen by both the NFS clients and the mounts.
+
+const (
+	nlmProgram = 100021
+	nlmVersion = 4
+
+	nlmProcNull    = 0
+	nlmProcTest    = 1
+	nlmProcLock    = 2
+	nlmProcCancel  = 3
+	nlmProcUnlock  = 4
+	nlmProcShare   = 20
+	nlmProcUnshare = 21
+	nlmProcNmLock  = 22
+	nlmProcFreeAll = 23
+
+	nlm4Granted = 0
+	nlm4Denied  = 1
+	nlm4Blocked = 3
+	nlm4StaleFh = 7
+	nlm4Failed  = 9
+
+	nlmMaxStringLength = 1024
+
+	posixLockPrefix = "mount.posix:"
+
+	// a blocked lock is retried for a while before the client is told to retry later
+	nlmBlockingWait    = 5 * time.Second
+	nlmLockWaitInitial = 10 * time.Millisecond
+	nlmLockWaitMaximum = time.Second
+)
+
+// nlmLock is the nlm4_lock argument
+type nlmLock struct {
+	callerName string
+	handle     []byte
+	owner      []byte
+	svid       uint32
+	offset     uint64
+	length     uint64
+}
+
+func readNlmLock(r *xdrReader) *nlmLock {
+	return &nlmLock{
+		callerName: r.String(nlmMaxStringLength),
+		handle:     r.Opaque(nlmMaxStringLength),
+		owner:      r.Opaque(nlmMaxStringLength),
+		svid:       r.Uint32(),
+		offset:     r.Uint64(),
+		length:     r.Uint64(),
+	}
+}
+
+// lockOwner identifies the process of the client holding the lock
+func (l *nlmLock) lockOwner() uint64 {
+	return uint64(util.HashStringToLong(fmt.Sprintf("%s/%x/%d", l.callerName, l.owner, l.svid)))
+}
+
+func (l *nlmLock) end() uint64 {
+	if l.length == 0 || l.offset+l.length < l.offset {
+		return math.MaxUint64
+	}
+	return l.offset + l.length - 1
+}
+
+type nlmLocks struct {
+	sync.Mutex
+	server  *NfsServer
+	session string
+	held    map[string]map[uint64]string // lock name to the lock owners and their clients
+}
+
+func newNlmLocks(server *NfsServer) *nlmLocks {
+	return &nlmLocks{
+		server:  server,
+		session: uuid.New().String(),
+		held:    make(map[string]map[uint64]string),
+	}
+}
+
+func (nl *nlmLocks) add(name string, owner uint64, callerName string) {
+	nl.Lock()
+	defer nl.Unlock()
+	if nl.held[name] == nil {
+		nl.held[name] = make(map[uint64]string)
+	}
+	nl.held[name][owner] = callerName
+}
+
+func (nl *nlmLocks) names() (names []string) {
+	nl.Lock()
+	defer nl.Unlock()
+	for name := range nl.held {
+		names = append(names, name)
+	}
+	return
+}
+
+func (s *NfsServer) nlmProcedures() map[uint32]rpcProcedure {
+	return map[uint32]rpcProcedure{
+		nlmProcNull: func(req *rpcRequest) error {
+			return nil
+		},
+		nlmProcTest:    s.nlmTest,
+		nlmProcLock:    s.nlmLock,
+		nlmProcCancel:  s.nlmCancel,
+		nlmProcUnlock:  s.nlmUnlock,
+		nlmProcShare:   s.nlmShare,
+		nlmProcUnshare: s.nlmShare,
+		nlmProcNmLock:  s.nlmLock,
+		nlmProcFreeAll: s.nlmFreeAll,
+	}
+}
+
+// lockName resolves the file handle of the lock
+func (s *NfsServer) lockName(req *rpcRequest, handle []byte) (string, uint32) {
+	inode, ok := handleToInode(handle)
+	if !ok {
+		return "", nlm4StaleFh
+	}
+	fullPath, found := s.inodeToPath.GetPath(inode)
+	if !found {
+		return "", nlm4StaleFh
+	}
+	if _, rule := s.option.Exports.Match(fullPath, req.client); rule == nil {
+		return "", nlm4Failed
+	}
+	entry, err := s.getEntry(inode, fullPath)
+	if err == filer_pb.ErrNotFound {
+		return "", nlm4StaleFh
+	}
+	if err != nil {
+		glog.Errorf("nfs lock %s: %v", fullPath, err)
+		return "", nlm4Failed
+	}
+	return posixLockPrefix + entry.LockKey(fullPath), nlm4Granted
+}
+
+func (s *NfsServer) nlmTest(req *rpcRequest) error {
+	cookie := req.args.Opaque(nlmMaxStringLength)
+	exclusive := req.args.Bool()
+	lock := readNlmLock(req.args)
+	if req.args.err != nil {
+		return errGarbageArgs
+	}
+
+	name, status := s.lockName(req, lock.handle)
+	var conflict *filer_pb.RangeLock
+	if status == nlm4Granted {
+		var err error
+		if conflict, err = s.locks.tryLock(name, lock, exclusive, true); err != nil {
+			glog.Errorf("nlm test lock %s: %v", name, err)
+			status = nlm4Failed
+		} else if conflict != nil {
+			status = nlm4Denied
+		}
+	}
+
+	req.results.Opaque(cookie)
+	req.results.Uint32(status)
+	if status == nlm4Denied {
+		// nlm4_holder
+		req.results.Bool(conflict.IsExclusive)
+		req.results.Uint32(conflict.Pid)
+		req.results.Opaque(nil)
+		req.results.Uint64(conflict.Start)
+		if conflict.End == math.MaxUint64 {
+			req.results.Uint64(0)
+		} else {
+			req.results.Uint64(conflict.End - conflict.Start + 1)
+		}
+	}
+	return nil
+}
+
+func (s *NfsServer) nlmLock(req *rpcRequest) error {
+	cookie := req.args.Opaque(nlmMaxStringLength)
+	block := req.args.Bool()
+	exclusive := req.args.Bool()
+	lock := readNlmLock(req.args)
+	if req.proc == nlmProcLock {
+		req.args.Bool()   // reclaim
+		req.args.Uint32() // state
+	}
+	if req.args.err != nil {
+		return errGarbageArgs
+	}
+
+	name, status := s.lockName(req, lock.handle)
+	waitTime, deadline := nlmLockWaitInitial, time.Now().Add(nlmBlockingWait)
+	for status == nlm4Granted {
+		conflict, err := s.locks.tryLock(name, lock, exclusive, false)
+		if err != nil {
+			glog.Errorf("nlm lock %s: %v", name, err)
+			status = nlm4Failed
+			break
+		}
+		if conflict == nil {
+			s.locks.add(name, lock.lockOwner(), lock.callerName)
+			break
+		}
+		if !block {
+			status = nlm4Denied
+			break
+		}
+		if time.Now().After(deadline) {
+			// the client polls again for the blocked lock
+			status = nlm4Blocked
+			break
+		}
+		time.Sleep(waitTime)
+		if waitTime *= 2; waitTime > nlmLockWaitMaximum {
+			waitTime = nlmLockWaitMaximum
+		}
+	}
+
+	req.results.Opaque(cookie)
+	req.results.Uint32(status)
+	return nil
+}
+
+// nlmCancel has nothing to cancel, since the blocked locks are not queued
+func (s *NfsServer) nlmCancel(req *rpcRequest) error {
+	cookie := req.args.Opaque(nlmMaxStringLength)
+	req.args.Bool() // block
+	req.args.Bool() // exclusive
+	readNlmLock(req.args)
+	if req.args.err != nil {
+		return errGarbageArgs
+	}
+	req.results.Opaque(cookie)
+	req.results.Uint32(nlm4Granted)
+	return nil
+}
+
+func (s *NfsServer) nlmUnlock(req *rpcRequest) error {
+	cookie := req.args.Opaque(nlmMaxStringLength)
+	lock := readNlmLock(req.args)
+	if req.args.err != nil {
+		return errGarbageArgs
+	}
+
+	name, status := s.lockName(req, lock.handle)
+	if status == nlm4Granted {
+		if err := s.locks.unlock(name, lock.lockOwner(), lock.offset, lock.end()); err != nil {
+			glog.Errorf("nlm unlock %s: %v", name, err)
+			status = nlm4Failed
+		}
+	}
+	if status == nlm4StaleFh {
+		// nothing is locked on a file not known any more
+		status = nlm4Granted
+	}
+
+	req.results.Opaque(cookie)
+	req.results.Uint32(status)
+	return nil
+}
+
+// nlmShare grants the DOS share reservations, which are not enforced
+func (s *NfsServer) nlmShare(req *rpcRequest) error {
+	cookie := req.args.Opaque(nlmMaxStringLength)
+	if req.args.err != nil {
+		return errGarbageArgs
+	}
+	req.results.Opaque(cookie)
+	req.results.Uint32(nlm4Granted)
+	req.results.Uint32(0) // sequence
+	return nil
+}
+
+// nlmFreeAll releases the locks of a rebooted client
+func (s *NfsServer) nlmFreeAll(req *rpcRequest) error {
+	callerName := req.args.String(nlmMaxStringLength)
+	req.args.Uint32() // state
+	if req.args.err != nil {
+		return errGarbageArgs
+	}
+	s.locks.freeAll(callerName)
+	return nil
+}
+
+func (nl *nlmLocks) tryLock(name string, lock *nlmLock, exclusive, isTest bool) (conflict *filer_pb.RangeLock, err error) {
+	err = nl.server.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.DistributedRangeLock(context.Background(), &filer_pb.RangeLockRequest{
+			Name: name,
+			Lock: &filer_pb.RangeLock{
+				Session:     nl.session,
+				Owner:       lock.lockOwner(),
+				Pid:         lock.svid,
+				Start:       lock.offset,
+				End:         lock.end(),
+				IsExclusive: exclusive,
+			},
+			SecondsToLock: int64(lock_manager.LiveLockTTL.Seconds()),
+			IsTest:        isTest,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("%s", resp.Error)
+		}
+		conflict = resp.Conflict
+		return nil
+	})
+	return
+}
+
+func (nl *nlmLocks) unlock(name string, owner uint64, start, end uint64) error {
+	return nl.server.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.DistributedRangeUnlock(context.Background(), &filer_pb.RangeUnlockRequest{
+			Name:    name,
+			Session: nl.session,
+			Owner:   owner,
+			Start:   start,
+			End:     end,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("%s", resp.Error)
+		}
+		if !resp.SessionHasLocks {
+			nl.Lock()
+			delete(nl.held, name)
+			nl.Unlock()
+		}
+		return nil
+	})
+}
+
+func (nl *nlmLocks) freeAll(callerName string) {
+	type nameOwner struct {
+		name  string
+		owner uint64
+	}
+	var locks []nameOwner
+	nl.Lock()
+	for name, owners := range nl.held {
+		for owner, c := range owners {
+			if c == callerName {
+				locks = append(locks, nameOwner{name, owner})
+				delete(owners, owner)
+			}
+		}
+	}
+	nl.Unlock()
+	for _, l := range locks {
+		if err := nl.unlock(l.name, l.owner, 0, math.MaxUint64); err != nil {
+			glog.Errorf("nlm free locks of %s on %s: %v", callerName, l.name, err)
+		}
+	}
+	glog.V(0).Infof("nlm freed %d lock owners of %s", len(locks), callerName)
+}
+
+// loopRenewLocks keeps the lease of the locks held by the clients
+func (nl *nlmLocks) loopRenewLocks() {
+	for {
+		time.Sleep(lock_manager.RenewInterval)
+		names := nl.names()
+		if len(names) == 0 {
+			continue
+		}
+		if err := nl.server.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+			resp, err := client.RenewRangeLocks(context.Background(), &filer_pb.RenewRangeLocksRequest{
+				Names:         names,
+				Session:       nl.session,
+				SecondsToLock: int64(lock_manager.LiveLockTTL.Seconds()),
+			})
+			if err != nil {
+				return err
+			}
+			if resp.Error != "" {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			return nil
+		}); err != nil {
+			glog.Errorf("renew %d nlm locks: %v", len(names), err)
+		}
+	}
+}
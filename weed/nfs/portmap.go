@@ -0,0 +1,62 @@
+package nfs
+
+// the port mapper, version 2 as in RFC 1833, so the clients can find the MOUNT and NLM programs
+
+const (
+	portmapProgram = 100000
+	portmapVersion = 2
+
+	portmapProcNull    = 0
+	portmapProcGetPort = 3
+	portmapProcDump    = 4
+
+	ipProtoTcp = 6
+)
+
+type portMapping struct {
+	prog, vers, prot, port uint32
+}
+
+func (s *NfsServer) portMappings() (mappings []portMapping) {
+	port := uint32(s.option.Port)
+	for _, p := range []struct{ prog, vers uint32 }{
+		{portmapProgram, portmapVersion},
+		{mountProgram, mountVersion},
+		{nfsProgram, nfsVersion},
+		{nlmProgram, nlmVersion},
+	} {
+		mappings = append(mappings, portMapping{prog: p.prog, vers: p.vers, prot: ipProtoTcp, port: port})
+	}
+	return
+}
+
+func (s *NfsServer) portmapProcedures() map[uint32]rpcProcedure {
+	return map[uint32]rpcProcedure{
+		portmapProcNull: func(req *rpcRequest) error {
+			return nil
+		},
+		portmapProcGetPort: func(req *rpcRequest) error {
+			prog, vers, prot := req.args.Uint32(), req.args.Uint32(), req.args.Uint32()
+			req.args.Uint32() // port
+			var port uint32
+			for _, m := range s.portMappings() {
+				if m.prog == prog && m.vers == vers && m.prot == prot {
+					port = m.port
+				}
+			}
+			req.results.Uint32(port)
+			return nil
+		},
+		portmapProcDump: func(req *rpcRequest) error {
+			for _, m := range s.portMappings() {
+				req.results.Bool(true)
+				req.results.Uint32(m.prog)
+				req.results.Uint32(m.vers)
+				req.results.Uint32(m.prot)
+				req.results.Uint32(m.port)
+			}
+			req.results.Bool(false)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,275 @@
+package nfs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+)
+
+// ONC RPC version 2 as in RFC 5531, over TCP with record marking
+
+const (
+	rpcVersion = 2
+
+	rpcCall  = 0
+	rpcReply = 1
+
+	rpcMsgAccepted = 0
+	rpcMsgDenied   = 1
+
+	rpcSuccess      = 0
+	rpcProgUnavail  = 1
+	rpcProgMismatch = 2
+	rpcProcUnavail  = 3
+	rpcGarbageArgs  = 4
+	rpcSystemErr    = 5
+
+	rpcMismatch  = 0
+	rpcAuthError = 1
+
+	rpcAuthNone = 0
+	rpcAuthUnix = 1
+
+	rpcAuthBadCred = 1
+
+	rpcLastFragment   = 1 << 31
+	rpcMaxRecordSize  = 8 * 1024 * 1024
+	rpcMaxConcurrency = 64
+)
+
+var errGarbageArgs = errors.New("garbage arguments")
+
+// rpcCredential is the AUTH_UNIX credential of the caller
+type rpcCredential struct {
+	flavor  uint32
+	machine string
+	uid     uint32
+	gid     uint32
+	gids    []uint32
+}
+
+type rpcRequest struct {
+	xid     uint32
+	prog    uint32
+	vers    uint32
+	proc    uint32
+	cred    rpcCredential
+	client  net.IP
+	args    *xdrReader
+	results *xdrWriter
+}
+
+// rpcProcedure decodes the arguments from the request and encodes the results.
+// errGarbageArgs is returned if the arguments can not be decoded.
+type rpcProcedure func(req *rpcRequest) error
+
+type rpcProgram struct {
+	low, high  uint32
+	procedures map[uint32]rpcProcedure
+}
+
+type rpcServer struct {
+	programs map[uint32]*rpcProgram
+}
+
+func newRpcServer() *rpcServer {
+	return &rpcServer{
+		programs: make(map[uint32]*rpcProgram),
+	}
+}
+
+func (s *rpcServer) register(prog, low, high uint32, procedures map[uint32]rpcProcedure) {
+	s.programs[prog] = &rpcProgram{low: low, high: high, procedures: procedures}
+}
+
+func (s *rpcServer) serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *rpcServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	var clientIp net.IP
+	if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		clientIp = tcpAddr.IP
+	}
+	glog.V(2).Infof("nfs connection from %v", conn.RemoteAddr())
+
+	reader := bufio.NewReader(conn)
+	var writeLock sync.Mutex
+	limiter := make(chan struct{}, rpcMaxConcurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		record, err := readRecord(reader)
+		if err != nil {
+			if err != io.EOF {
+				glog.V(1).Infof("nfs connection from %v: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		limiter <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+			reply := s.handle(record, clientIp)
+			if reply == nil {
+				return
+			}
+			writeLock.Lock()
+			defer writeLock.Unlock()
+			if err := writeRecord(conn, reply); err != nil {
+				glog.V(1).Infof("nfs reply to %v: %v", conn.RemoteAddr(), err)
+				conn.Close()
+			}
+		}()
+	}
+}
+
+// readRecord reads the fragments of one record
+func readRecord(reader io.Reader) ([]byte, error) {
+	var record []byte
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return nil, err
+		}
+		marker := binary.BigEndian.Uint32(header[:])
+		size := int(marker &^ rpcLastFragment)
+		if len(record)+size > rpcMaxRecordSize {
+			return nil, fmt.Errorf("record size %d exceeds %d", len(record)+size, rpcMaxRecordSize)
+		}
+		fragment := make([]byte, size)
+		if _, err := io.ReadFull(reader, fragment); err != nil {
+			return nil, err
+		}
+		record = append(record, fragment...)
+		if marker&rpcLastFragment != 0 {
+			return record, nil
+		}
+	}
+}
+
+func writeRecord(writer io.Writer, record []byte) error {
+	buf := make([]byte, 4, 4+len(record))
+	binary.BigEndian.PutUint32(buf, uint32(len(record))|rpcLastFragment)
+	_, err := writer.Write(append(buf, record...))
+	return err
+}
+
+// handle processes one call message, and returns the reply message
+func (s *rpcServer) handle(record []byte, clientIp net.IP) []byte {
+	args := newXdrReader(record)
+	req := &rpcRequest{
+		xid:    args.Uint32(),
+		client: clientIp,
+		args:   args,
+	}
+	if msgType := args.Uint32(); args.err != nil || msgType != rpcCall {
+		// not a call, and there is nobody to reply to
+		return nil
+	}
+
+	reply := &xdrWriter{}
+	reply.Uint32(req.xid)
+	reply.Uint32(rpcReply)
+
+	if rpcVers := args.Uint32(); rpcVers != rpcVersion {
+		reply.Uint32(rpcMsgDenied)
+		reply.Uint32(rpcMismatch)
+		reply.Uint32(rpcVersion)
+		reply.Uint32(rpcVersion)
+		return reply.Bytes()
+	}
+	req.prog, req.vers, req.proc = args.Uint32(), args.Uint32(), args.Uint32()
+
+	credFlavor := args.Uint32()
+	credBody := args.Opaque(400)
+	args.Uint32() // verifier flavor
+	args.Opaque(400)
+	if args.err != nil {
+		return nil
+	}
+	req.cred.flavor = credFlavor
+	if credFlavor == rpcAuthUnix {
+		if err := req.cred.decodeAuthUnix(credBody); err != nil {
+			reply.Uint32(rpcMsgDenied)
+			reply.Uint32(rpcAuthError)
+			reply.Uint32(rpcAuthBadCred)
+			return reply.Bytes()
+		}
+	}
+
+	reply.Uint32(rpcMsgAccepted)
+	// AUTH_NONE verifier
+	reply.Uint32(rpcAuthNone)
+	reply.Uint32(0)
+
+	program, found := s.programs[req.prog]
+	if !found {
+		reply.Uint32(rpcProgUnavail)
+		return reply.Bytes()
+	}
+	if req.vers < program.low || req.vers > program.high {
+		reply.Uint32(rpcProgMismatch)
+		reply.Uint32(program.low)
+		reply.Uint32(program.high)
+		return reply.Bytes()
+	}
+	procedure, found := program.procedures[req.proc]
+	if !found {
+		reply.Uint32(rpcProcUnavail)
+		return reply.Bytes()
+	}
+
+	req.args = newXdrReader(args.Remaining())
+	req.results = &xdrWriter{}
+	err := procedure(req)
+	if err == nil && req.args.err != nil {
+		err = errGarbageArgs
+	}
+	switch {
+	case err == errGarbageArgs:
+		glog.V(1).Infof("rpc prog %d proc %d from %v: %v", req.prog, req.proc, clientIp, err)
+		reply.Uint32(rpcGarbageArgs)
+	case err != nil:
+		glog.Errorf("rpc prog %d proc %d from %v: %v", req.prog, req.proc, clientIp, err)
+		reply.Uint32(rpcSystemErr)
+	default:
+		reply.Uint32(rpcSuccess)
+		reply.FixedOpaque(req.results.Bytes())
+	}
+	return reply.Bytes()
+}
+
+func (cred *rpcCredential) decodeAuthUnix(body []byte) error {
+	r := newXdrReader(body)
+	r.Uint32() // stamp
+	cred.machine = r.String(255)
+	cred.uid = r.Uint32()
+	cred.gid = r.Uint32()
+	count := r.Uint32()
+	if count > 16 {
+		return errGarbageArgs
+	}
+	for i := uint32(0); i < count; i++ {
+		cred.gids = append(cred.gids, r.Uint32())
+	}
+	return r.err
+}
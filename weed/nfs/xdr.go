@@ -0,0 +1,130 @@
+package nfs
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// XDR encoding as in RFC 4506: big endian, every item padded to 4 bytes
+
+var errXdrShort = errors.New("xdr: short buffer")
+
+type xdrReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func newXdrReader(buf []byte) *xdrReader {
+	return &xdrReader{buf: buf}
+}
+
+func (r *xdrReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.buf) {
+		r.err = errXdrShort
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *xdrReader) Uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *xdrReader) Uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *xdrReader) Bool() bool {
+	return r.Uint32() != 0
+}
+
+// FixedOpaque reads n bytes and the padding
+func (r *xdrReader) FixedOpaque(n int) []byte {
+	b := r.next(n)
+	r.next(xdrPadding(n))
+	return b
+}
+
+// Opaque reads variable length bytes, up to maxSize
+func (r *xdrReader) Opaque(maxSize int) []byte {
+	n := r.Uint32()
+	if r.err == nil && int64(n) > int64(maxSize) {
+		r.err = errXdrShort
+		return nil
+	}
+	return r.FixedOpaque(int(n))
+}
+
+func (r *xdrReader) String(maxSize int) string {
+	return string(r.Opaque(maxSize))
+}
+
+// Remaining returns the unread bytes
+func (r *xdrReader) Remaining() []byte {
+	if r.err != nil {
+		return nil
+	}
+	return r.buf[r.pos:]
+}
+
+type xdrWriter struct {
+	buf []byte
+}
+
+func (w *xdrWriter) Bytes() []byte {
+	return w.buf
+}
+
+func (w *xdrWriter) Len() int {
+	return len(w.buf)
+}
+
+func (w *xdrWriter) Uint32(v uint32) {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+}
+
+func (w *xdrWriter) Uint64(v uint64) {
+	w.buf = binary.BigEndian.AppendUint64(w.buf, v)
+}
+
+func (w *xdrWriter) Bool(v bool) {
+	if v {
+		w.Uint32(1)
+	} else {
+		w.Uint32(0)
+	}
+}
+
+func (w *xdrWriter) FixedOpaque(b []byte) {
+	w.buf = append(w.buf, b...)
+	for i := 0; i < xdrPadding(len(b)); i++ {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *xdrWriter) Opaque(b []byte) {
+	w.Uint32(uint32(len(b)))
+	w.FixedOpaque(b)
+}
+
+func (w *xdrWriter) String(s string) {
+	w.Opaque([]byte(s))
+}
+
+func xdrPadding(n int) int {
+	return (4 - n%4) % 4
+}
@@ -0,0 +1,65 @@
+package nfs
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestXdrRoundTrip(t *testing.T) {
+	w := &xdrWriter{}
+	w.Uint32(7)
+	w.Uint64(1 << 40)
+	w.Bool(true)
+	w.Opaque([]byte{1, 2, 3, 4, 5})
+	w.String("abc")
+	w.FixedOpaque([]byte{9, 9})
+
+	// each item is padded to 4 bytes
+	if w.Len() != 4+8+4+(4+8)+(4+4)+4 {
+		t.Fatalf("encoded length %d", w.Len())
+	}
+
+	r := newXdrReader(w.Bytes())
+	if v := r.Uint32(); v != 7 {
+		t.Errorf("uint32 %d", v)
+	}
+	if v := r.Uint64(); v != 1<<40 {
+		t.Errorf("uint64 %d", v)
+	}
+	if v := r.Bool(); !v {
+		t.Errorf("bool %v", v)
+	}
+	if v := r.Opaque(16); !bytes.Equal(v, []byte{1, 2, 3, 4, 5}) {
+		t.Errorf("opaque %v", v)
+	}
+	if v := r.String(16); v != "abc" {
+		t.Errorf("string %q", v)
+	}
+	if v := r.FixedOpaque(2); !bytes.Equal(v, []byte{9, 9}) {
+		t.Errorf("fixed opaque %v", v)
+	}
+	if r.err != nil {
+		t.Fatalf("decode: %v", r.err)
+	}
+	if len(r.Remaining()) != 0 {
+		t.Errorf("remaining %d bytes", len(r.Remaining()))
+	}
+}
+
+func TestXdrShort(t *testing.T) {
+	w := &xdrWriter{}
+	w.Opaque([]byte("0123456789"))
+
+	r := newXdrReader(w.Bytes())
+	if r.Opaque(8); r.err != errXdrShort {
+		t.Errorf("opaque over the maximum size: %v", r.err)
+	}
+
+	r = newXdrReader(w.Bytes()[:8])
+	if r.Opaque(16); r.err != errXdrShort {
+		t.Errorf("truncated opaque: %v", r.err)
+	}
+	if v := r.Uint32(); v != 0 || r.err != errXdrShort {
+		t.Errorf("read after error: %d %v", v, r.err)
+	}
+}
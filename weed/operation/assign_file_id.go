@@ -8,6 +8,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/security"
 	"github.com/seaweedfs/seaweedfs/weed/stats"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 	"google.golang.org/grpc"
 	"sync"
 )
@@ -244,7 +245,12 @@ type StorageOption struct {
 	VolumeGrowthCount uint32
 	MaxFileNameLength uint32
 	Fsync             bool
+	GroupCommitMs     uint32 // fsync the writes in groups, waiting at most this long
 	SaveInside        bool
+	PackMaxFileSize   int64                   // pack files up to this size into shared container chunks, 0 to disable
+	Dedup             bool                    // split into content-defined chunks, and store identical chunks once
+	Compression       *util.CompressionPolicy // nil to compress by the content type
+	EcScheme          string                  // write the chunks erasure coded directly with this scheme, empty to replicate
 }
 
 func (so *StorageOption) TtlString() string {
@@ -70,9 +70,14 @@ func TailVolumeFromSource(volumeServer pb.ServerAddress, grpcDialOption grpc.Dia
 				needleBody = append(needleBody, resp.NeedleBody...)
 			}
 
+			// the older volume servers do not send the version
+			version := needle.CurrentVersion
+			if resp.Version != 0 {
+				version = needle.Version(resp.Version)
+			}
 			n := new(needle.Needle)
 			n.ParseNeedleHeader(needleHeader)
-			err = n.ReadNeedleBodyBytes(needleBody, needle.CurrentVersion)
+			err = n.ReadNeedleBodyBytes(needleBody, version)
 			if err != nil {
 				return err
 			}
@@ -30,6 +30,8 @@ type UploadOption struct {
 	Filename          string
 	Cipher            bool
 	IsInputCompressed bool
+	InputCodec        string                  // codec of the compressed input, gzip if empty
+	Compression       *util.CompressionPolicy // nil to compress by the content type
 	MimeType          string
 	PairMap           map[string]string
 	Jwt               security.EncodedJwt
@@ -194,8 +196,14 @@ func (uploader *Uploader) retriedUploadData(data []byte, option *UploadOption) (
 
 func (uploader *Uploader) doUploadData(data []byte, option *UploadOption) (uploadResult *UploadResult, err error) {
 	contentIsGzipped := option.IsInputCompressed
+	codec := option.InputCodec
 	shouldGzipNow := false
-	if !option.IsInputCompressed {
+	usePolicy := !option.IsInputCompressed && option.Compression != nil && option.Compression.Codec != ""
+	if usePolicy && option.Compression.Codec == util.CompressionNone && !option.Cipher {
+		// avoid the volume server compressing by the content type
+		option.Filename, option.MimeType = "", "application/octet-stream"
+	}
+	if !option.IsInputCompressed && !usePolicy {
 		if option.MimeType == "" {
 			option.MimeType = http.DetectContentType(data)
 			// println("detect1 mimetype to", MimeType)
@@ -221,7 +229,15 @@ func (uploader *Uploader) doUploadData(data []byte, option *UploadOption) (uploa
 	// this could be double copying
 	clearDataLen = len(data)
 	clearData := data
-	if shouldGzipNow {
+	if usePolicy {
+		if compressed, compressedCodec := option.Compression.Compress(data); compressedCodec != "" {
+			data = compressed
+			contentIsGzipped = true
+			if compressedCodec != util.CompressionGzip {
+				codec = compressedCodec
+			}
+		}
+	} else if shouldGzipNow {
 		compressed, compressErr := util.GzipData(data)
 		// fmt.Printf("data is compressed from %d ==> %d\n", len(data), len(compressed))
 		if compressErr == nil {
@@ -280,6 +296,7 @@ func (uploader *Uploader) doUploadData(data []byte, option *UploadOption) (uploa
 			Filename:          option.Filename,
 			Cipher:            false,
 			IsInputCompressed: contentIsGzipped,
+			InputCodec:        codec,
 			MimeType:          option.MimeType,
 			PairMap:           option.PairMap,
 			Jwt:               option.Jwt,
@@ -321,7 +338,7 @@ func (uploader *Uploader) upload_content(fillBufferFunction func(w io.Writer) er
 		h.Set("Content-Type", option.MimeType)
 	}
 	if option.IsInputCompressed {
-		h.Set("Content-Encoding", "gzip")
+		h.Set("Content-Encoding", util.Nvl(option.InputCodec, "gzip"))
 	}
 	if option.Md5 != "" {
 		h.Set("Content-MD5", option.Md5)
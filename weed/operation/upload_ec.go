@@ -0,0 +1,229 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+type EcUploadOption struct {
+	Collection string
+	DataCenter string
+	DiskType   string
+	Scheme     erasure_coding.EcScheme
+	Filename   string
+	MimeType   string
+	Cipher     bool
+}
+
+type EcAssignResult struct {
+	Fid            string
+	Offset         int64
+	Scheme         erasure_coding.EcScheme
+	ShardLocations [][]Location
+}
+
+// AssignEc asks the master for a file id in an ec volume written directly, with the rows reserved for the needle size
+func AssignEc(masterFn GetMasterFn, grpcDialOption grpc.DialOption, option *EcUploadOption, size int64) (ret *EcAssignResult, err error) {
+
+	err = WithMasterServerClient(false, masterFn(context.Background()), grpcDialOption, func(masterClient master_pb.SeaweedClient) error {
+		resp, grpcErr := masterClient.AssignEc(context.Background(), &master_pb.AssignEcRequest{
+			Collection:   option.Collection,
+			DataCenter:   option.DataCenter,
+			DiskType:     option.DiskType,
+			DataShards:   uint32(option.Scheme.DataShards),
+			ParityShards: uint32(option.Scheme.ParityShards),
+			Size:         uint64(size),
+		})
+		if grpcErr != nil {
+			return grpcErr
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("assign ec: %v", resp.Error)
+		}
+
+		ret = &EcAssignResult{
+			Fid:            resp.Fid,
+			Offset:         resp.Offset,
+			Scheme:         erasure_coding.NewEcScheme(resp.DataShards, resp.ParityShards),
+			ShardLocations: make([][]Location, erasure_coding.MaxShardCount),
+		}
+		for _, shardIdLocation := range resp.ShardIdLocations {
+			if shardIdLocation.ShardId >= erasure_coding.MaxShardCount {
+				return fmt.Errorf("assign ec: unexpected shard id %d", shardIdLocation.ShardId)
+			}
+			for _, loc := range shardIdLocation.Locations {
+				ret.ShardLocations[shardIdLocation.ShardId] = append(ret.ShardLocations[shardIdLocation.ShardId], Location{
+					Url:        loc.Url,
+					PublicUrl:  loc.PublicUrl,
+					DataCenter: loc.DataCenter,
+					GrpcPort:   int(loc.GrpcPort),
+				})
+			}
+		}
+		ret.ShardLocations = ret.ShardLocations[:ret.Scheme.TotalShards()]
+		for shardId, locations := range ret.ShardLocations {
+			if len(locations) == 0 {
+				return fmt.Errorf("assign ec %s: no location for shard %d", resp.Fid, shardId)
+			}
+		}
+		return nil
+	})
+
+	return
+}
+
+// UploadEc writes the data as a needle erasure coded by the client, to the servers holding the shards of an ec volume.
+// The needle starts at a new row of data shards x 1MB, so it suits large immutable chunks.
+// The data is neither compressed nor replicated, and is readable after it is committed on all the servers.
+// Every shard server must be up, since a server missing the rows of a needle would serve zeros for them, and the
+// master skips the ec volumes with a shard server down. The rows reserved by a failed write are not reused, and are
+// counted by the ec_write_wasted_bytes metric.
+func UploadEc(masterFn GetMasterFn, grpcDialOption grpc.DialOption, option *EcUploadOption, data []byte) (fileId string, uploadResult *UploadResult, err error) {
+
+	uploadResult = &UploadResult{
+		Name:       option.Filename,
+		Mime:       option.MimeType,
+		Size:       uint32(len(data)),
+		ContentMd5: util.Base64Md5(data),
+	}
+	if option.Cipher {
+		cipherKey := util.GenCipherKey()
+		encryptedData, encryptionErr := util.Encrypt(data, cipherKey)
+		if encryptionErr != nil {
+			return "", nil, fmt.Errorf("encrypt input: %v", encryptionErr)
+		}
+		data = encryptedData
+		uploadResult.CipherKey = cipherKey
+	}
+
+	n := new(needle.Needle)
+	n.Data = data
+	if option.Filename != "" && len(option.Filename) < 256 {
+		n.Name = []byte(option.Filename)
+		n.SetHasName()
+	}
+	if option.MimeType != "" && len(option.MimeType) < 256 {
+		n.Mime = []byte(option.MimeType)
+		n.SetHasMime()
+	}
+	n.LastModified = uint64(time.Now().Unix())
+	n.SetHasLastModifiedDate()
+	n.Checksum = needle.NewCRC(n.Data)
+	n.AppendAtNs = uint64(time.Now().UnixNano())
+
+	// the needle size does not depend on the file id
+	blob, err := n.ToBytes(needle.CurrentVersion)
+	if err != nil {
+		return "", nil, err
+	}
+	assignResult, err := AssignEc(masterFn, grpcDialOption, option, int64(len(blob)))
+	if err != nil {
+		return "", nil, err
+	}
+	fid, err := needle.ParseFileIdFromString(assignResult.Fid)
+	if err != nil {
+		return "", nil, err
+	}
+	n.Id, n.Cookie = fid.Key, fid.Cookie
+	if blob, err = n.ToBytes(needle.CurrentVersion); err != nil {
+		return "", nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			rowsSize := assignResult.Scheme.RowsSize(int64(len(blob)))
+			glog.Warningf("ec volume %d rows [%d,%d) of the failed write %s are not reused", fid.VolumeId, assignResult.Offset, assignResult.Offset+rowsSize, assignResult.Fid)
+			stats.FilerEcWriteWastedBytesCounter.Add(float64(rowsSize))
+		}
+	}()
+
+	shards, err := erasure_coding.EncodeRows(assignResult.Scheme, blob)
+	if err != nil {
+		return "", nil, err
+	}
+	if err = writeEcShards(grpcDialOption, fid.VolumeId, assignResult, shards); err != nil {
+		return "", nil, err
+	}
+	if err = commitEcNeedle(grpcDialOption, fid.VolumeId, assignResult, n); err != nil {
+		return "", nil, err
+	}
+
+	return assignResult.Fid, uploadResult, nil
+}
+
+func writeEcShards(grpcDialOption grpc.DialOption, vid needle.VolumeId, assignResult *EcAssignResult, shards [][]byte) (err error) {
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	for shardId, locations := range assignResult.ShardLocations {
+		for _, location := range locations {
+			wg.Add(1)
+			go func(shardId int, server pb.ServerAddress) {
+				defer wg.Done()
+				writeErr := WithVolumeServerClient(false, server, grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+					_, writeErr := client.VolumeEcShardWrite(context.Background(), &volume_server_pb.VolumeEcShardWriteRequest{
+						VolumeId: uint32(vid),
+						ShardId:  uint32(shardId),
+						Offset:   assignResult.Offset / int64(assignResult.Scheme.DataShards),
+						Size:     int64(len(shards[shardId])),
+						Data:     erasure_coding.TrimZeros(shards[shardId]),
+					})
+					return writeErr
+				})
+				if writeErr != nil {
+					errLock.Lock()
+					err = fmt.Errorf("write ec shard %d.%d to %s: %v", vid, shardId, server, writeErr)
+					errLock.Unlock()
+				}
+			}(shardId, location.ServerAddress())
+		}
+	}
+	wg.Wait()
+	return
+}
+
+func commitEcNeedle(grpcDialOption grpc.DialOption, vid needle.VolumeId, assignResult *EcAssignResult, n *needle.Needle) (err error) {
+	servers := make(map[pb.ServerAddress]bool)
+	for _, locations := range assignResult.ShardLocations {
+		for _, location := range locations {
+			servers[location.ServerAddress()] = true
+		}
+	}
+
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	for server := range servers {
+		wg.Add(1)
+		go func(server pb.ServerAddress) {
+			defer wg.Done()
+			commitErr := WithVolumeServerClient(false, server, grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+				_, commitErr := client.VolumeEcBlobCommit(context.Background(), &volume_server_pb.VolumeEcBlobCommitRequest{
+					VolumeId: uint32(vid),
+					FileKey:  uint64(n.Id),
+					Offset:   assignResult.Offset,
+					Size:     uint32(n.Size),
+				})
+				return commitErr
+			})
+			if commitErr != nil {
+				errLock.Lock()
+				err = fmt.Errorf("commit ec needle %d,%s to %s: %v", vid, n.Id, server, commitErr)
+				errLock.Unlock()
+			}
+		}(server)
+	}
+	wg.Wait()
+	return
+}
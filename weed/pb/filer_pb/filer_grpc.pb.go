@@ -24,6 +24,7 @@ const (
 	SeaweedFiler_CreateEntry_FullMethodName                     = "/filer_pb.SeaweedFiler/CreateEntry"
 	SeaweedFiler_UpdateEntry_FullMethodName                     = "/filer_pb.SeaweedFiler/UpdateEntry"
 	SeaweedFiler_AppendToEntry_FullMethodName                   = "/filer_pb.SeaweedFiler/AppendToEntry"
+	SeaweedFiler_CloneEntry_FullMethodName                      = "/filer_pb.SeaweedFiler/CloneEntry"
 	SeaweedFiler_DeleteEntry_FullMethodName                     = "/filer_pb.SeaweedFiler/DeleteEntry"
 	SeaweedFiler_AtomicRenameEntry_FullMethodName               = "/filer_pb.SeaweedFiler/AtomicRenameEntry"
 	SeaweedFiler_StreamRenameEntry_FullMethodName               = "/filer_pb.SeaweedFiler/StreamRenameEntry"
@@ -43,7 +44,11 @@ const (
 	SeaweedFiler_DistributedLock_FullMethodName                 = "/filer_pb.SeaweedFiler/DistributedLock"
 	SeaweedFiler_DistributedUnlock_FullMethodName               = "/filer_pb.SeaweedFiler/DistributedUnlock"
 	SeaweedFiler_FindLockOwner_FullMethodName                   = "/filer_pb.SeaweedFiler/FindLockOwner"
+	SeaweedFiler_DistributedRangeLock_FullMethodName            = "/filer_pb.SeaweedFiler/DistributedRangeLock"
+	SeaweedFiler_DistributedRangeUnlock_FullMethodName          = "/filer_pb.SeaweedFiler/DistributedRangeUnlock"
+	SeaweedFiler_RenewRangeLocks_FullMethodName                 = "/filer_pb.SeaweedFiler/RenewRangeLocks"
 	SeaweedFiler_TransferLocks_FullMethodName                   = "/filer_pb.SeaweedFiler/TransferLocks"
+	SeaweedFiler_GetQuotaUsage_FullMethodName                   = "/filer_pb.SeaweedFiler/GetQuotaUsage"
 )
 
 // SeaweedFilerClient is the client API for SeaweedFiler service.
@@ -55,6 +60,7 @@ type SeaweedFilerClient interface {
 	CreateEntry(ctx context.Context, in *CreateEntryRequest, opts ...grpc.CallOption) (*CreateEntryResponse, error)
 	UpdateEntry(ctx context.Context, in *UpdateEntryRequest, opts ...grpc.CallOption) (*UpdateEntryResponse, error)
 	AppendToEntry(ctx context.Context, in *AppendToEntryRequest, opts ...grpc.CallOption) (*AppendToEntryResponse, error)
+	CloneEntry(ctx context.Context, in *CloneEntryRequest, opts ...grpc.CallOption) (*CloneEntryResponse, error)
 	DeleteEntry(ctx context.Context, in *DeleteEntryRequest, opts ...grpc.CallOption) (*DeleteEntryResponse, error)
 	AtomicRenameEntry(ctx context.Context, in *AtomicRenameEntryRequest, opts ...grpc.CallOption) (*AtomicRenameEntryResponse, error)
 	StreamRenameEntry(ctx context.Context, in *StreamRenameEntryRequest, opts ...grpc.CallOption) (SeaweedFiler_StreamRenameEntryClient, error)
@@ -74,8 +80,12 @@ type SeaweedFilerClient interface {
 	DistributedLock(ctx context.Context, in *LockRequest, opts ...grpc.CallOption) (*LockResponse, error)
 	DistributedUnlock(ctx context.Context, in *UnlockRequest, opts ...grpc.CallOption) (*UnlockResponse, error)
 	FindLockOwner(ctx context.Context, in *FindLockOwnerRequest, opts ...grpc.CallOption) (*FindLockOwnerResponse, error)
+	DistributedRangeLock(ctx context.Context, in *RangeLockRequest, opts ...grpc.CallOption) (*RangeLockResponse, error)
+	DistributedRangeUnlock(ctx context.Context, in *RangeUnlockRequest, opts ...grpc.CallOption) (*RangeUnlockResponse, error)
+	RenewRangeLocks(ctx context.Context, in *RenewRangeLocksRequest, opts ...grpc.CallOption) (*RenewRangeLocksResponse, error)
 	// distributed lock management internal use only
 	TransferLocks(ctx context.Context, in *TransferLocksRequest, opts ...grpc.CallOption) (*TransferLocksResponse, error)
+	GetQuotaUsage(ctx context.Context, in *GetQuotaUsageRequest, opts ...grpc.CallOption) (*GetQuotaUsageResponse, error)
 }
 
 type seaweedFilerClient struct {
@@ -154,6 +164,15 @@ func (c *seaweedFilerClient) AppendToEntry(ctx context.Context, in *AppendToEntr
 	return out, nil
 }
 
+func (c *seaweedFilerClient) CloneEntry(ctx context.Context, in *CloneEntryRequest, opts ...grpc.CallOption) (*CloneEntryResponse, error) {
+	out := new(CloneEntryResponse)
+	err := c.cc.Invoke(ctx, SeaweedFiler_CloneEntry_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *seaweedFilerClient) DeleteEntry(ctx context.Context, in *DeleteEntryRequest, opts ...grpc.CallOption) (*DeleteEntryResponse, error) {
 	out := new(DeleteEntryResponse)
 	err := c.cc.Invoke(ctx, SeaweedFiler_DeleteEntry_FullMethodName, in, out, opts...)
@@ -417,6 +436,33 @@ func (c *seaweedFilerClient) FindLockOwner(ctx context.Context, in *FindLockOwne
 	return out, nil
 }
 
+func (c *seaweedFilerClient) DistributedRangeLock(ctx context.Context, in *RangeLockRequest, opts ...grpc.CallOption) (*RangeLockResponse, error) {
+	out := new(RangeLockResponse)
+	err := c.cc.Invoke(ctx, SeaweedFiler_DistributedRangeLock_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) DistributedRangeUnlock(ctx context.Context, in *RangeUnlockRequest, opts ...grpc.CallOption) (*RangeUnlockResponse, error) {
+	out := new(RangeUnlockResponse)
+	err := c.cc.Invoke(ctx, SeaweedFiler_DistributedRangeUnlock_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedFilerClient) RenewRangeLocks(ctx context.Context, in *RenewRangeLocksRequest, opts ...grpc.CallOption) (*RenewRangeLocksResponse, error) {
+	out := new(RenewRangeLocksResponse)
+	err := c.cc.Invoke(ctx, SeaweedFiler_RenewRangeLocks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *seaweedFilerClient) TransferLocks(ctx context.Context, in *TransferLocksRequest, opts ...grpc.CallOption) (*TransferLocksResponse, error) {
 	out := new(TransferLocksResponse)
 	err := c.cc.Invoke(ctx, SeaweedFiler_TransferLocks_FullMethodName, in, out, opts...)
@@ -426,6 +472,15 @@ func (c *seaweedFilerClient) TransferLocks(ctx context.Context, in *TransferLock
 	return out, nil
 }
 
+func (c *seaweedFilerClient) GetQuotaUsage(ctx context.Context, in *GetQuotaUsageRequest, opts ...grpc.CallOption) (*GetQuotaUsageResponse, error) {
+	out := new(GetQuotaUsageResponse)
+	err := c.cc.Invoke(ctx, SeaweedFiler_GetQuotaUsage_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SeaweedFilerServer is the server API for SeaweedFiler service.
 // All implementations must embed UnimplementedSeaweedFilerServer
 // for forward compatibility
@@ -435,6 +490,7 @@ type SeaweedFilerServer interface {
 	CreateEntry(context.Context, *CreateEntryRequest) (*CreateEntryResponse, error)
 	UpdateEntry(context.Context, *UpdateEntryRequest) (*UpdateEntryResponse, error)
 	AppendToEntry(context.Context, *AppendToEntryRequest) (*AppendToEntryResponse, error)
+	CloneEntry(context.Context, *CloneEntryRequest) (*CloneEntryResponse, error)
 	DeleteEntry(context.Context, *DeleteEntryRequest) (*DeleteEntryResponse, error)
 	AtomicRenameEntry(context.Context, *AtomicRenameEntryRequest) (*AtomicRenameEntryResponse, error)
 	StreamRenameEntry(*StreamRenameEntryRequest, SeaweedFiler_StreamRenameEntryServer) error
@@ -454,8 +510,12 @@ type SeaweedFilerServer interface {
 	DistributedLock(context.Context, *LockRequest) (*LockResponse, error)
 	DistributedUnlock(context.Context, *UnlockRequest) (*UnlockResponse, error)
 	FindLockOwner(context.Context, *FindLockOwnerRequest) (*FindLockOwnerResponse, error)
+	DistributedRangeLock(context.Context, *RangeLockRequest) (*RangeLockResponse, error)
+	DistributedRangeUnlock(context.Context, *RangeUnlockRequest) (*RangeUnlockResponse, error)
+	RenewRangeLocks(context.Context, *RenewRangeLocksRequest) (*RenewRangeLocksResponse, error)
 	// distributed lock management internal use only
 	TransferLocks(context.Context, *TransferLocksRequest) (*TransferLocksResponse, error)
+	GetQuotaUsage(context.Context, *GetQuotaUsageRequest) (*GetQuotaUsageResponse, error)
 	mustEmbedUnimplementedSeaweedFilerServer()
 }
 
@@ -478,6 +538,9 @@ func (UnimplementedSeaweedFilerServer) UpdateEntry(context.Context, *UpdateEntry
 func (UnimplementedSeaweedFilerServer) AppendToEntry(context.Context, *AppendToEntryRequest) (*AppendToEntryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AppendToEntry not implemented")
 }
+func (UnimplementedSeaweedFilerServer) CloneEntry(context.Context, *CloneEntryRequest) (*CloneEntryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CloneEntry not implemented")
+}
 func (UnimplementedSeaweedFilerServer) DeleteEntry(context.Context, *DeleteEntryRequest) (*DeleteEntryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteEntry not implemented")
 }
@@ -535,9 +598,21 @@ func (UnimplementedSeaweedFilerServer) DistributedUnlock(context.Context, *Unloc
 func (UnimplementedSeaweedFilerServer) FindLockOwner(context.Context, *FindLockOwnerRequest) (*FindLockOwnerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FindLockOwner not implemented")
 }
+func (UnimplementedSeaweedFilerServer) DistributedRangeLock(context.Context, *RangeLockRequest) (*RangeLockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DistributedRangeLock not implemented")
+}
+func (UnimplementedSeaweedFilerServer) DistributedRangeUnlock(context.Context, *RangeUnlockRequest) (*RangeUnlockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DistributedRangeUnlock not implemented")
+}
+func (UnimplementedSeaweedFilerServer) RenewRangeLocks(context.Context, *RenewRangeLocksRequest) (*RenewRangeLocksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenewRangeLocks not implemented")
+}
 func (UnimplementedSeaweedFilerServer) TransferLocks(context.Context, *TransferLocksRequest) (*TransferLocksResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method TransferLocks not implemented")
 }
+func (UnimplementedSeaweedFilerServer) GetQuotaUsage(context.Context, *GetQuotaUsageRequest) (*GetQuotaUsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetQuotaUsage not implemented")
+}
 func (UnimplementedSeaweedFilerServer) mustEmbedUnimplementedSeaweedFilerServer() {}
 
 // UnsafeSeaweedFilerServer may be embedded to opt out of forward compatibility for this service.
@@ -644,6 +719,24 @@ func _SeaweedFiler_AppendToEntry_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SeaweedFiler_CloneEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CloneEntryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).CloneEntry(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedFiler_CloneEntry_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).CloneEntry(ctx, req.(*CloneEntryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SeaweedFiler_DeleteEntry_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteEntryRequest)
 	if err := dec(in); err != nil {
@@ -998,6 +1091,60 @@ func _SeaweedFiler_FindLockOwner_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SeaweedFiler_DistributedRangeLock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RangeLockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).DistributedRangeLock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedFiler_DistributedRangeLock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).DistributedRangeLock(ctx, req.(*RangeLockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_DistributedRangeUnlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RangeUnlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).DistributedRangeUnlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedFiler_DistributedRangeUnlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).DistributedRangeUnlock(ctx, req.(*RangeUnlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedFiler_RenewRangeLocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewRangeLocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).RenewRangeLocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedFiler_RenewRangeLocks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).RenewRangeLocks(ctx, req.(*RenewRangeLocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SeaweedFiler_TransferLocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(TransferLocksRequest)
 	if err := dec(in); err != nil {
@@ -1016,6 +1163,24 @@ func _SeaweedFiler_TransferLocks_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SeaweedFiler_GetQuotaUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetQuotaUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedFilerServer).GetQuotaUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedFiler_GetQuotaUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedFilerServer).GetQuotaUsage(ctx, req.(*GetQuotaUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SeaweedFiler_ServiceDesc is the grpc.ServiceDesc for SeaweedFiler service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -1039,6 +1204,10 @@ var SeaweedFiler_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "AppendToEntry",
 			Handler:    _SeaweedFiler_AppendToEntry_Handler,
 		},
+		{
+			MethodName: "CloneEntry",
+			Handler:    _SeaweedFiler_CloneEntry_Handler,
+		},
 		{
 			MethodName: "DeleteEntry",
 			Handler:    _SeaweedFiler_DeleteEntry_Handler,
@@ -1099,10 +1268,26 @@ var SeaweedFiler_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "FindLockOwner",
 			Handler:    _SeaweedFiler_FindLockOwner_Handler,
 		},
+		{
+			MethodName: "DistributedRangeLock",
+			Handler:    _SeaweedFiler_DistributedRangeLock_Handler,
+		},
+		{
+			MethodName: "DistributedRangeUnlock",
+			Handler:    _SeaweedFiler_DistributedRangeUnlock_Handler,
+		},
+		{
+			MethodName: "RenewRangeLocks",
+			Handler:    _SeaweedFiler_RenewRangeLocks_Handler,
+		},
 		{
 			MethodName: "TransferLocks",
 			Handler:    _SeaweedFiler_TransferLocks_Handler,
 		},
+		{
+			MethodName: "GetQuotaUsage",
+			Handler:    _SeaweedFiler_GetQuotaUsage_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -2,14 +2,17 @@ package filer_pb
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 	"github.com/viant/ptrie"
 	"google.golang.org/protobuf/proto"
 )
@@ -31,6 +34,20 @@ func (entry *Entry) FileMode() (fileMode os.FileMode) {
 	return
 }
 
+// LockKey identifies the file of the posix locks and flock the same way on all mounts and nfs servers:
+// by the hard link id, or by the inode kept in the entry. The entries written without an inode
+// fall back to the inode derived from the path and the creation time.
+func (entry *Entry) LockKey(fullPath util.FullPath) string {
+	if len(entry.HardLinkId) > 0 {
+		return "hardlink." + hex.EncodeToString(entry.HardLinkId)
+	}
+	inode := entry.GetAttributes().GetInode()
+	if inode == 0 {
+		inode = fullPath.AsInode(entry.GetAttributes().GetCrtime())
+	}
+	return "inode." + strconv.FormatUint(inode, 10)
+}
+
 func (entry *Entry) IsOlderDir() bool {
 	return entry.IsDirectory && entry.Attributes != nil && entry.Attributes.Mime == "" && entry.Attributes.GetCrtime() <= time.Now().Unix()-cutoffTimeNewEmptyDir
 }
@@ -63,6 +80,32 @@ func (c *FileChunk) GetFileIdString() string {
 	return ""
 }
 
+// IsPacked is true if the chunk data is a range of a container chunk shared with other small files
+func (c *FileChunk) IsPacked() bool {
+	return c.PackedContainerSize > 0
+}
+
+// IsDeduplicated is true if the chunk data may be shared with other chunks of the same content
+func (c *FileChunk) IsDeduplicated() bool {
+	return len(c.Fingerprint) > 0
+}
+
+// NeedleSize is the size of the stored chunk, which is the container for packed chunks
+func (c *FileChunk) NeedleSize() uint64 {
+	if c.IsPacked() {
+		return c.PackedContainerSize
+	}
+	if c.StoredSize > 0 {
+		return c.StoredSize
+	}
+	return c.Size
+}
+
+// DataOffset is the offset of the chunk data in the stored chunk
+func (c *FileChunk) DataOffset() int64 {
+	return c.PackedOffset + c.TrimmedOffset
+}
+
 func BeforeEntrySerialization(chunks []*FileChunk) {
 
 	for _, chunk := range chunks {
@@ -29,6 +29,7 @@ const (
 	Seaweed_CollectionDelete_FullMethodName       = "/master_pb.Seaweed/CollectionDelete"
 	Seaweed_VolumeList_FullMethodName             = "/master_pb.Seaweed/VolumeList"
 	Seaweed_LookupEcVolume_FullMethodName         = "/master_pb.Seaweed/LookupEcVolume"
+	Seaweed_AssignEc_FullMethodName               = "/master_pb.Seaweed/AssignEc"
 	Seaweed_VacuumVolume_FullMethodName           = "/master_pb.Seaweed/VacuumVolume"
 	Seaweed_DisableVacuum_FullMethodName          = "/master_pb.Seaweed/DisableVacuum"
 	Seaweed_EnableVacuum_FullMethodName           = "/master_pb.Seaweed/EnableVacuum"
@@ -58,6 +59,7 @@ type SeaweedClient interface {
 	CollectionDelete(ctx context.Context, in *CollectionDeleteRequest, opts ...grpc.CallOption) (*CollectionDeleteResponse, error)
 	VolumeList(ctx context.Context, in *VolumeListRequest, opts ...grpc.CallOption) (*VolumeListResponse, error)
 	LookupEcVolume(ctx context.Context, in *LookupEcVolumeRequest, opts ...grpc.CallOption) (*LookupEcVolumeResponse, error)
+	AssignEc(ctx context.Context, in *AssignEcRequest, opts ...grpc.CallOption) (*AssignEcResponse, error)
 	VacuumVolume(ctx context.Context, in *VacuumVolumeRequest, opts ...grpc.CallOption) (*VacuumVolumeResponse, error)
 	DisableVacuum(ctx context.Context, in *DisableVacuumRequest, opts ...grpc.CallOption) (*DisableVacuumResponse, error)
 	EnableVacuum(ctx context.Context, in *EnableVacuumRequest, opts ...grpc.CallOption) (*EnableVacuumResponse, error)
@@ -237,6 +239,15 @@ func (c *seaweedClient) LookupEcVolume(ctx context.Context, in *LookupEcVolumeRe
 	return out, nil
 }
 
+func (c *seaweedClient) AssignEc(ctx context.Context, in *AssignEcRequest, opts ...grpc.CallOption) (*AssignEcResponse, error) {
+	out := new(AssignEcResponse)
+	err := c.cc.Invoke(ctx, Seaweed_AssignEc_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *seaweedClient) VacuumVolume(ctx context.Context, in *VacuumVolumeRequest, opts ...grpc.CallOption) (*VacuumVolumeResponse, error) {
 	out := new(VacuumVolumeResponse)
 	err := c.cc.Invoke(ctx, Seaweed_VacuumVolume_FullMethodName, in, out, opts...)
@@ -368,6 +379,7 @@ type SeaweedServer interface {
 	CollectionDelete(context.Context, *CollectionDeleteRequest) (*CollectionDeleteResponse, error)
 	VolumeList(context.Context, *VolumeListRequest) (*VolumeListResponse, error)
 	LookupEcVolume(context.Context, *LookupEcVolumeRequest) (*LookupEcVolumeResponse, error)
+	AssignEc(context.Context, *AssignEcRequest) (*AssignEcResponse, error)
 	VacuumVolume(context.Context, *VacuumVolumeRequest) (*VacuumVolumeResponse, error)
 	DisableVacuum(context.Context, *DisableVacuumRequest) (*DisableVacuumResponse, error)
 	EnableVacuum(context.Context, *EnableVacuumRequest) (*EnableVacuumResponse, error)
@@ -418,6 +430,9 @@ func (UnimplementedSeaweedServer) VolumeList(context.Context, *VolumeListRequest
 func (UnimplementedSeaweedServer) LookupEcVolume(context.Context, *LookupEcVolumeRequest) (*LookupEcVolumeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method LookupEcVolume not implemented")
 }
+func (UnimplementedSeaweedServer) AssignEc(context.Context, *AssignEcRequest) (*AssignEcResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AssignEc not implemented")
+}
 func (UnimplementedSeaweedServer) VacuumVolume(context.Context, *VacuumVolumeRequest) (*VacuumVolumeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method VacuumVolume not implemented")
 }
@@ -674,6 +689,24 @@ func _Seaweed_LookupEcVolume_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Seaweed_AssignEc_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AssignEcRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedServer).AssignEc(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Seaweed_AssignEc_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedServer).AssignEc(ctx, req.(*AssignEcRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Seaweed_VacuumVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(VacuumVolumeRequest)
 	if err := dec(in); err != nil {
@@ -943,6 +976,10 @@ var Seaweed_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "LookupEcVolume",
 			Handler:    _Seaweed_LookupEcVolume_Handler,
 		},
+		{
+			MethodName: "AssignEc",
+			Handler:    _Seaweed_AssignEc_Handler,
+		},
 		{
 			MethodName: "VacuumVolume",
 			Handler:    _Seaweed_VacuumVolume_Handler,
@@ -19,7 +19,16 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	SeaweedMount_Configure_FullMethodName = "/messaging_pb.SeaweedMount/Configure"
+	SeaweedMount_Configure_FullMethodName      = "/messaging_pb.SeaweedMount/Configure"
+	SeaweedMount_GetReaderStats_FullMethodName = "/messaging_pb.SeaweedMount/GetReaderStats"
+	SeaweedMount_ListHandles_FullMethodName    = "/messaging_pb.SeaweedMount/ListHandles"
+	SeaweedMount_FlushAll_FullMethodName       = "/messaging_pb.SeaweedMount/FlushAll"
+	SeaweedMount_DropCaches_FullMethodName     = "/messaging_pb.SeaweedMount/DropCaches"
+	SeaweedMount_GetCacheStats_FullMethodName  = "/messaging_pb.SeaweedMount/GetCacheStats"
+	SeaweedMount_Tune_FullMethodName           = "/messaging_pb.SeaweedMount/Tune"
+	SeaweedMount_Pin_FullMethodName            = "/messaging_pb.SeaweedMount/Pin"
+	SeaweedMount_Unpin_FullMethodName          = "/messaging_pb.SeaweedMount/Unpin"
+	SeaweedMount_ListPins_FullMethodName       = "/messaging_pb.SeaweedMount/ListPins"
 )
 
 // SeaweedMountClient is the client API for SeaweedMount service.
@@ -27,6 +36,15 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type SeaweedMountClient interface {
 	Configure(ctx context.Context, in *ConfigureRequest, opts ...grpc.CallOption) (*ConfigureResponse, error)
+	GetReaderStats(ctx context.Context, in *GetReaderStatsRequest, opts ...grpc.CallOption) (*GetReaderStatsResponse, error)
+	ListHandles(ctx context.Context, in *ListHandlesRequest, opts ...grpc.CallOption) (*ListHandlesResponse, error)
+	FlushAll(ctx context.Context, in *FlushAllRequest, opts ...grpc.CallOption) (*FlushAllResponse, error)
+	DropCaches(ctx context.Context, in *DropCachesRequest, opts ...grpc.CallOption) (*DropCachesResponse, error)
+	GetCacheStats(ctx context.Context, in *GetCacheStatsRequest, opts ...grpc.CallOption) (*GetCacheStatsResponse, error)
+	Tune(ctx context.Context, in *TuneRequest, opts ...grpc.CallOption) (*TuneResponse, error)
+	Pin(ctx context.Context, in *PinRequest, opts ...grpc.CallOption) (*PinResponse, error)
+	Unpin(ctx context.Context, in *UnpinRequest, opts ...grpc.CallOption) (*UnpinResponse, error)
+	ListPins(ctx context.Context, in *ListPinsRequest, opts ...grpc.CallOption) (*ListPinsResponse, error)
 }
 
 type seaweedMountClient struct {
@@ -46,11 +64,101 @@ func (c *seaweedMountClient) Configure(ctx context.Context, in *ConfigureRequest
 	return out, nil
 }
 
+func (c *seaweedMountClient) GetReaderStats(ctx context.Context, in *GetReaderStatsRequest, opts ...grpc.CallOption) (*GetReaderStatsResponse, error) {
+	out := new(GetReaderStatsResponse)
+	err := c.cc.Invoke(ctx, SeaweedMount_GetReaderStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedMountClient) ListHandles(ctx context.Context, in *ListHandlesRequest, opts ...grpc.CallOption) (*ListHandlesResponse, error) {
+	out := new(ListHandlesResponse)
+	err := c.cc.Invoke(ctx, SeaweedMount_ListHandles_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedMountClient) FlushAll(ctx context.Context, in *FlushAllRequest, opts ...grpc.CallOption) (*FlushAllResponse, error) {
+	out := new(FlushAllResponse)
+	err := c.cc.Invoke(ctx, SeaweedMount_FlushAll_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedMountClient) DropCaches(ctx context.Context, in *DropCachesRequest, opts ...grpc.CallOption) (*DropCachesResponse, error) {
+	out := new(DropCachesResponse)
+	err := c.cc.Invoke(ctx, SeaweedMount_DropCaches_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedMountClient) GetCacheStats(ctx context.Context, in *GetCacheStatsRequest, opts ...grpc.CallOption) (*GetCacheStatsResponse, error) {
+	out := new(GetCacheStatsResponse)
+	err := c.cc.Invoke(ctx, SeaweedMount_GetCacheStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedMountClient) Tune(ctx context.Context, in *TuneRequest, opts ...grpc.CallOption) (*TuneResponse, error) {
+	out := new(TuneResponse)
+	err := c.cc.Invoke(ctx, SeaweedMount_Tune_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedMountClient) Pin(ctx context.Context, in *PinRequest, opts ...grpc.CallOption) (*PinResponse, error) {
+	out := new(PinResponse)
+	err := c.cc.Invoke(ctx, SeaweedMount_Pin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedMountClient) Unpin(ctx context.Context, in *UnpinRequest, opts ...grpc.CallOption) (*UnpinResponse, error) {
+	out := new(UnpinResponse)
+	err := c.cc.Invoke(ctx, SeaweedMount_Unpin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seaweedMountClient) ListPins(ctx context.Context, in *ListPinsRequest, opts ...grpc.CallOption) (*ListPinsResponse, error) {
+	out := new(ListPinsResponse)
+	err := c.cc.Invoke(ctx, SeaweedMount_ListPins_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SeaweedMountServer is the server API for SeaweedMount service.
 // All implementations must embed UnimplementedSeaweedMountServer
 // for forward compatibility
 type SeaweedMountServer interface {
 	Configure(context.Context, *ConfigureRequest) (*ConfigureResponse, error)
+	GetReaderStats(context.Context, *GetReaderStatsRequest) (*GetReaderStatsResponse, error)
+	ListHandles(context.Context, *ListHandlesRequest) (*ListHandlesResponse, error)
+	FlushAll(context.Context, *FlushAllRequest) (*FlushAllResponse, error)
+	DropCaches(context.Context, *DropCachesRequest) (*DropCachesResponse, error)
+	GetCacheStats(context.Context, *GetCacheStatsRequest) (*GetCacheStatsResponse, error)
+	Tune(context.Context, *TuneRequest) (*TuneResponse, error)
+	Pin(context.Context, *PinRequest) (*PinResponse, error)
+	Unpin(context.Context, *UnpinRequest) (*UnpinResponse, error)
+	ListPins(context.Context, *ListPinsRequest) (*ListPinsResponse, error)
 	mustEmbedUnimplementedSeaweedMountServer()
 }
 
@@ -61,6 +169,33 @@ type UnimplementedSeaweedMountServer struct {
 func (UnimplementedSeaweedMountServer) Configure(context.Context, *ConfigureRequest) (*ConfigureResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Configure not implemented")
 }
+func (UnimplementedSeaweedMountServer) GetReaderStats(context.Context, *GetReaderStatsRequest) (*GetReaderStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReaderStats not implemented")
+}
+func (UnimplementedSeaweedMountServer) ListHandles(context.Context, *ListHandlesRequest) (*ListHandlesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListHandles not implemented")
+}
+func (UnimplementedSeaweedMountServer) FlushAll(context.Context, *FlushAllRequest) (*FlushAllResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FlushAll not implemented")
+}
+func (UnimplementedSeaweedMountServer) DropCaches(context.Context, *DropCachesRequest) (*DropCachesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DropCaches not implemented")
+}
+func (UnimplementedSeaweedMountServer) GetCacheStats(context.Context, *GetCacheStatsRequest) (*GetCacheStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCacheStats not implemented")
+}
+func (UnimplementedSeaweedMountServer) Tune(context.Context, *TuneRequest) (*TuneResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Tune not implemented")
+}
+func (UnimplementedSeaweedMountServer) Pin(context.Context, *PinRequest) (*PinResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Pin not implemented")
+}
+func (UnimplementedSeaweedMountServer) Unpin(context.Context, *UnpinRequest) (*UnpinResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unpin not implemented")
+}
+func (UnimplementedSeaweedMountServer) ListPins(context.Context, *ListPinsRequest) (*ListPinsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPins not implemented")
+}
 func (UnimplementedSeaweedMountServer) mustEmbedUnimplementedSeaweedMountServer() {}
 
 // UnsafeSeaweedMountServer may be embedded to opt out of forward compatibility for this service.
@@ -92,6 +227,168 @@ func _SeaweedMount_Configure_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SeaweedMount_GetReaderStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReaderStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedMountServer).GetReaderStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedMount_GetReaderStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedMountServer).GetReaderStats(ctx, req.(*GetReaderStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedMount_ListHandles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListHandlesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedMountServer).ListHandles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedMount_ListHandles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedMountServer).ListHandles(ctx, req.(*ListHandlesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedMount_FlushAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlushAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedMountServer).FlushAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedMount_FlushAll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedMountServer).FlushAll(ctx, req.(*FlushAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedMount_DropCaches_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DropCachesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedMountServer).DropCaches(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedMount_DropCaches_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedMountServer).DropCaches(ctx, req.(*DropCachesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedMount_GetCacheStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCacheStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedMountServer).GetCacheStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedMount_GetCacheStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedMountServer).GetCacheStats(ctx, req.(*GetCacheStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedMount_Tune_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TuneRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedMountServer).Tune(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedMount_Tune_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedMountServer).Tune(ctx, req.(*TuneRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedMount_Pin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedMountServer).Pin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedMount_Pin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedMountServer).Pin(ctx, req.(*PinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedMount_Unpin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnpinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedMountServer).Unpin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedMount_Unpin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedMountServer).Unpin(ctx, req.(*UnpinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeaweedMount_ListPins_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPinsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeaweedMountServer).ListPins(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SeaweedMount_ListPins_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeaweedMountServer).ListPins(ctx, req.(*ListPinsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SeaweedMount_ServiceDesc is the grpc.ServiceDesc for SeaweedMount service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -103,6 +400,42 @@ var SeaweedMount_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Configure",
 			Handler:    _SeaweedMount_Configure_Handler,
 		},
+		{
+			MethodName: "GetReaderStats",
+			Handler:    _SeaweedMount_GetReaderStats_Handler,
+		},
+		{
+			MethodName: "ListHandles",
+			Handler:    _SeaweedMount_ListHandles_Handler,
+		},
+		{
+			MethodName: "FlushAll",
+			Handler:    _SeaweedMount_FlushAll_Handler,
+		},
+		{
+			MethodName: "DropCaches",
+			Handler:    _SeaweedMount_DropCaches_Handler,
+		},
+		{
+			MethodName: "GetCacheStats",
+			Handler:    _SeaweedMount_GetCacheStats_Handler,
+		},
+		{
+			MethodName: "Tune",
+			Handler:    _SeaweedMount_Tune_Handler,
+		},
+		{
+			MethodName: "Pin",
+			Handler:    _SeaweedMount_Pin_Handler,
+		},
+		{
+			MethodName: "Unpin",
+			Handler:    _SeaweedMount_Unpin_Handler,
+		},
+		{
+			MethodName: "ListPins",
+			Handler:    _SeaweedMount_ListPins_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "mount.proto",
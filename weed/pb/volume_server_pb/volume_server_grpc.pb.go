@@ -35,6 +35,8 @@ const (
 	VolumeServer_VolumeMarkWritable_FullMethodName          = "/volume_server_pb.VolumeServer/VolumeMarkWritable"
 	VolumeServer_VolumeConfigure_FullMethodName             = "/volume_server_pb.VolumeServer/VolumeConfigure"
 	VolumeServer_VolumeStatus_FullMethodName                = "/volume_server_pb.VolumeServer/VolumeStatus"
+	VolumeServer_VolumeNeedleMapSwitch_FullMethodName       = "/volume_server_pb.VolumeServer/VolumeNeedleMapSwitch"
+	VolumeServer_VolumeRotateKey_FullMethodName             = "/volume_server_pb.VolumeServer/VolumeRotateKey"
 	VolumeServer_VolumeCopy_FullMethodName                  = "/volume_server_pb.VolumeServer/VolumeCopy"
 	VolumeServer_ReadVolumeFileStatus_FullMethodName        = "/volume_server_pb.VolumeServer/ReadVolumeFileStatus"
 	VolumeServer_CopyFile_FullMethodName                    = "/volume_server_pb.VolumeServer/CopyFile"
@@ -53,6 +55,9 @@ const (
 	VolumeServer_VolumeEcShardRead_FullMethodName           = "/volume_server_pb.VolumeServer/VolumeEcShardRead"
 	VolumeServer_VolumeEcBlobDelete_FullMethodName          = "/volume_server_pb.VolumeServer/VolumeEcBlobDelete"
 	VolumeServer_VolumeEcShardsToVolume_FullMethodName      = "/volume_server_pb.VolumeServer/VolumeEcShardsToVolume"
+	VolumeServer_VolumeEcShardsAllocate_FullMethodName      = "/volume_server_pb.VolumeServer/VolumeEcShardsAllocate"
+	VolumeServer_VolumeEcShardWrite_FullMethodName          = "/volume_server_pb.VolumeServer/VolumeEcShardWrite"
+	VolumeServer_VolumeEcBlobCommit_FullMethodName          = "/volume_server_pb.VolumeServer/VolumeEcBlobCommit"
 	VolumeServer_VolumeTierMoveDatToRemote_FullMethodName   = "/volume_server_pb.VolumeServer/VolumeTierMoveDatToRemote"
 	VolumeServer_VolumeTierMoveDatFromRemote_FullMethodName = "/volume_server_pb.VolumeServer/VolumeTierMoveDatFromRemote"
 	VolumeServer_VolumeServerStatus_FullMethodName          = "/volume_server_pb.VolumeServer/VolumeServerStatus"
@@ -84,6 +89,8 @@ type VolumeServerClient interface {
 	VolumeMarkWritable(ctx context.Context, in *VolumeMarkWritableRequest, opts ...grpc.CallOption) (*VolumeMarkWritableResponse, error)
 	VolumeConfigure(ctx context.Context, in *VolumeConfigureRequest, opts ...grpc.CallOption) (*VolumeConfigureResponse, error)
 	VolumeStatus(ctx context.Context, in *VolumeStatusRequest, opts ...grpc.CallOption) (*VolumeStatusResponse, error)
+	VolumeNeedleMapSwitch(ctx context.Context, in *VolumeNeedleMapSwitchRequest, opts ...grpc.CallOption) (*VolumeNeedleMapSwitchResponse, error)
+	VolumeRotateKey(ctx context.Context, in *VolumeRotateKeyRequest, opts ...grpc.CallOption) (*VolumeRotateKeyResponse, error)
 	// copy the .idx .dat files, and mount this volume
 	VolumeCopy(ctx context.Context, in *VolumeCopyRequest, opts ...grpc.CallOption) (VolumeServer_VolumeCopyClient, error)
 	ReadVolumeFileStatus(ctx context.Context, in *ReadVolumeFileStatusRequest, opts ...grpc.CallOption) (*ReadVolumeFileStatusResponse, error)
@@ -104,6 +111,9 @@ type VolumeServerClient interface {
 	VolumeEcShardRead(ctx context.Context, in *VolumeEcShardReadRequest, opts ...grpc.CallOption) (VolumeServer_VolumeEcShardReadClient, error)
 	VolumeEcBlobDelete(ctx context.Context, in *VolumeEcBlobDeleteRequest, opts ...grpc.CallOption) (*VolumeEcBlobDeleteResponse, error)
 	VolumeEcShardsToVolume(ctx context.Context, in *VolumeEcShardsToVolumeRequest, opts ...grpc.CallOption) (*VolumeEcShardsToVolumeResponse, error)
+	VolumeEcShardsAllocate(ctx context.Context, in *VolumeEcShardsAllocateRequest, opts ...grpc.CallOption) (*VolumeEcShardsAllocateResponse, error)
+	VolumeEcShardWrite(ctx context.Context, in *VolumeEcShardWriteRequest, opts ...grpc.CallOption) (*VolumeEcShardWriteResponse, error)
+	VolumeEcBlobCommit(ctx context.Context, in *VolumeEcBlobCommitRequest, opts ...grpc.CallOption) (*VolumeEcBlobCommitResponse, error)
 	// tiered storage
 	VolumeTierMoveDatToRemote(ctx context.Context, in *VolumeTierMoveDatToRemoteRequest, opts ...grpc.CallOption) (VolumeServer_VolumeTierMoveDatToRemoteClient, error)
 	VolumeTierMoveDatFromRemote(ctx context.Context, in *VolumeTierMoveDatFromRemoteRequest, opts ...grpc.CallOption) (VolumeServer_VolumeTierMoveDatFromRemoteClient, error)
@@ -315,6 +325,24 @@ func (c *volumeServerClient) VolumeStatus(ctx context.Context, in *VolumeStatusR
 	return out, nil
 }
 
+func (c *volumeServerClient) VolumeNeedleMapSwitch(ctx context.Context, in *VolumeNeedleMapSwitchRequest, opts ...grpc.CallOption) (*VolumeNeedleMapSwitchResponse, error) {
+	out := new(VolumeNeedleMapSwitchResponse)
+	err := c.cc.Invoke(ctx, VolumeServer_VolumeNeedleMapSwitch_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *volumeServerClient) VolumeRotateKey(ctx context.Context, in *VolumeRotateKeyRequest, opts ...grpc.CallOption) (*VolumeRotateKeyResponse, error) {
+	out := new(VolumeRotateKeyResponse)
+	err := c.cc.Invoke(ctx, VolumeServer_VolumeRotateKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *volumeServerClient) VolumeCopy(ctx context.Context, in *VolumeCopyRequest, opts ...grpc.CallOption) (VolumeServer_VolumeCopyClient, error) {
 	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[2], VolumeServer_VolumeCopy_FullMethodName, opts...)
 	if err != nil {
@@ -592,6 +620,33 @@ func (c *volumeServerClient) VolumeEcShardsToVolume(ctx context.Context, in *Vol
 	return out, nil
 }
 
+func (c *volumeServerClient) VolumeEcShardsAllocate(ctx context.Context, in *VolumeEcShardsAllocateRequest, opts ...grpc.CallOption) (*VolumeEcShardsAllocateResponse, error) {
+	out := new(VolumeEcShardsAllocateResponse)
+	err := c.cc.Invoke(ctx, VolumeServer_VolumeEcShardsAllocate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *volumeServerClient) VolumeEcShardWrite(ctx context.Context, in *VolumeEcShardWriteRequest, opts ...grpc.CallOption) (*VolumeEcShardWriteResponse, error) {
+	out := new(VolumeEcShardWriteResponse)
+	err := c.cc.Invoke(ctx, VolumeServer_VolumeEcShardWrite_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *volumeServerClient) VolumeEcBlobCommit(ctx context.Context, in *VolumeEcBlobCommitRequest, opts ...grpc.CallOption) (*VolumeEcBlobCommitResponse, error) {
+	out := new(VolumeEcBlobCommitResponse)
+	err := c.cc.Invoke(ctx, VolumeServer_VolumeEcBlobCommit_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *volumeServerClient) VolumeTierMoveDatToRemote(ctx context.Context, in *VolumeTierMoveDatToRemoteRequest, opts ...grpc.CallOption) (VolumeServer_VolumeTierMoveDatToRemoteClient, error) {
 	stream, err := c.cc.NewStream(ctx, &VolumeServer_ServiceDesc.Streams[7], VolumeServer_VolumeTierMoveDatToRemote_FullMethodName, opts...)
 	if err != nil {
@@ -754,6 +809,8 @@ type VolumeServerServer interface {
 	VolumeMarkWritable(context.Context, *VolumeMarkWritableRequest) (*VolumeMarkWritableResponse, error)
 	VolumeConfigure(context.Context, *VolumeConfigureRequest) (*VolumeConfigureResponse, error)
 	VolumeStatus(context.Context, *VolumeStatusRequest) (*VolumeStatusResponse, error)
+	VolumeNeedleMapSwitch(context.Context, *VolumeNeedleMapSwitchRequest) (*VolumeNeedleMapSwitchResponse, error)
+	VolumeRotateKey(context.Context, *VolumeRotateKeyRequest) (*VolumeRotateKeyResponse, error)
 	// copy the .idx .dat files, and mount this volume
 	VolumeCopy(*VolumeCopyRequest, VolumeServer_VolumeCopyServer) error
 	ReadVolumeFileStatus(context.Context, *ReadVolumeFileStatusRequest) (*ReadVolumeFileStatusResponse, error)
@@ -774,6 +831,9 @@ type VolumeServerServer interface {
 	VolumeEcShardRead(*VolumeEcShardReadRequest, VolumeServer_VolumeEcShardReadServer) error
 	VolumeEcBlobDelete(context.Context, *VolumeEcBlobDeleteRequest) (*VolumeEcBlobDeleteResponse, error)
 	VolumeEcShardsToVolume(context.Context, *VolumeEcShardsToVolumeRequest) (*VolumeEcShardsToVolumeResponse, error)
+	VolumeEcShardsAllocate(context.Context, *VolumeEcShardsAllocateRequest) (*VolumeEcShardsAllocateResponse, error)
+	VolumeEcShardWrite(context.Context, *VolumeEcShardWriteRequest) (*VolumeEcShardWriteResponse, error)
+	VolumeEcBlobCommit(context.Context, *VolumeEcBlobCommitRequest) (*VolumeEcBlobCommitResponse, error)
 	// tiered storage
 	VolumeTierMoveDatToRemote(*VolumeTierMoveDatToRemoteRequest, VolumeServer_VolumeTierMoveDatToRemoteServer) error
 	VolumeTierMoveDatFromRemote(*VolumeTierMoveDatFromRemoteRequest, VolumeServer_VolumeTierMoveDatFromRemoteServer) error
@@ -840,6 +900,12 @@ func (UnimplementedVolumeServerServer) VolumeConfigure(context.Context, *VolumeC
 func (UnimplementedVolumeServerServer) VolumeStatus(context.Context, *VolumeStatusRequest) (*VolumeStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method VolumeStatus not implemented")
 }
+func (UnimplementedVolumeServerServer) VolumeNeedleMapSwitch(context.Context, *VolumeNeedleMapSwitchRequest) (*VolumeNeedleMapSwitchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeNeedleMapSwitch not implemented")
+}
+func (UnimplementedVolumeServerServer) VolumeRotateKey(context.Context, *VolumeRotateKeyRequest) (*VolumeRotateKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeRotateKey not implemented")
+}
 func (UnimplementedVolumeServerServer) VolumeCopy(*VolumeCopyRequest, VolumeServer_VolumeCopyServer) error {
 	return status.Errorf(codes.Unimplemented, "method VolumeCopy not implemented")
 }
@@ -894,6 +960,15 @@ func (UnimplementedVolumeServerServer) VolumeEcBlobDelete(context.Context, *Volu
 func (UnimplementedVolumeServerServer) VolumeEcShardsToVolume(context.Context, *VolumeEcShardsToVolumeRequest) (*VolumeEcShardsToVolumeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method VolumeEcShardsToVolume not implemented")
 }
+func (UnimplementedVolumeServerServer) VolumeEcShardsAllocate(context.Context, *VolumeEcShardsAllocateRequest) (*VolumeEcShardsAllocateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeEcShardsAllocate not implemented")
+}
+func (UnimplementedVolumeServerServer) VolumeEcShardWrite(context.Context, *VolumeEcShardWriteRequest) (*VolumeEcShardWriteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeEcShardWrite not implemented")
+}
+func (UnimplementedVolumeServerServer) VolumeEcBlobCommit(context.Context, *VolumeEcBlobCommitRequest) (*VolumeEcBlobCommitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeEcBlobCommit not implemented")
+}
 func (UnimplementedVolumeServerServer) VolumeTierMoveDatToRemote(*VolumeTierMoveDatToRemoteRequest, VolumeServer_VolumeTierMoveDatToRemoteServer) error {
 	return status.Errorf(codes.Unimplemented, "method VolumeTierMoveDatToRemote not implemented")
 }
@@ -1225,6 +1300,42 @@ func _VolumeServer_VolumeStatus_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VolumeServer_VolumeNeedleMapSwitch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeNeedleMapSwitchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VolumeServerServer).VolumeNeedleMapSwitch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VolumeServer_VolumeNeedleMapSwitch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VolumeServerServer).VolumeNeedleMapSwitch(ctx, req.(*VolumeNeedleMapSwitchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VolumeServer_VolumeRotateKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeRotateKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VolumeServerServer).VolumeRotateKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VolumeServer_VolumeRotateKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VolumeServerServer).VolumeRotateKey(ctx, req.(*VolumeRotateKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _VolumeServer_VolumeCopy_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(VolumeCopyRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -1564,6 +1675,60 @@ func _VolumeServer_VolumeEcShardsToVolume_Handler(srv interface{}, ctx context.C
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VolumeServer_VolumeEcShardsAllocate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeEcShardsAllocateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VolumeServerServer).VolumeEcShardsAllocate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VolumeServer_VolumeEcShardsAllocate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VolumeServerServer).VolumeEcShardsAllocate(ctx, req.(*VolumeEcShardsAllocateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VolumeServer_VolumeEcShardWrite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeEcShardWriteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VolumeServerServer).VolumeEcShardWrite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VolumeServer_VolumeEcShardWrite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VolumeServerServer).VolumeEcShardWrite(ctx, req.(*VolumeEcShardWriteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VolumeServer_VolumeEcBlobCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeEcBlobCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VolumeServerServer).VolumeEcBlobCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VolumeServer_VolumeEcBlobCommit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VolumeServerServer).VolumeEcBlobCommit(ctx, req.(*VolumeEcBlobCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _VolumeServer_VolumeTierMoveDatToRemote_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(VolumeTierMoveDatToRemoteRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -1780,6 +1945,14 @@ var VolumeServer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "VolumeStatus",
 			Handler:    _VolumeServer_VolumeStatus_Handler,
 		},
+		{
+			MethodName: "VolumeNeedleMapSwitch",
+			Handler:    _VolumeServer_VolumeNeedleMapSwitch_Handler,
+		},
+		{
+			MethodName: "VolumeRotateKey",
+			Handler:    _VolumeServer_VolumeRotateKey_Handler,
+		},
 		{
 			MethodName: "ReadVolumeFileStatus",
 			Handler:    _VolumeServer_ReadVolumeFileStatus_Handler,
@@ -1832,6 +2005,18 @@ var VolumeServer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "VolumeEcShardsToVolume",
 			Handler:    _VolumeServer_VolumeEcShardsToVolume_Handler,
 		},
+		{
+			MethodName: "VolumeEcShardsAllocate",
+			Handler:    _VolumeServer_VolumeEcShardsAllocate_Handler,
+		},
+		{
+			MethodName: "VolumeEcShardWrite",
+			Handler:    _VolumeServer_VolumeEcShardWrite_Handler,
+		},
+		{
+			MethodName: "VolumeEcBlobCommit",
+			Handler:    _VolumeServer_VolumeEcBlobCommit_Handler,
+		},
 		{
 			MethodName: "VolumeServerStatus",
 			Handler:    _VolumeServer_VolumeServerStatus_Handler,
@@ -1,9 +1,11 @@
 package filersink
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/schollz/progressbar/v3"
 	"github.com/seaweedfs/seaweedfs/weed/util"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -66,6 +68,10 @@ func (fs *FilerSink) replicateChunks(sourceChunks []*filer_pb.FileChunk, path st
 
 func (fs *FilerSink) replicateOneChunk(sourceChunk *filer_pb.FileChunk, path string) (*filer_pb.FileChunk, error) {
 
+	if sourceChunk.IsPacked() {
+		return fs.replicatePackedChunk(sourceChunk, path)
+	}
+
 	fileId, err := fs.fetchAndWrite(sourceChunk, path)
 	if err != nil {
 		return nil, fmt.Errorf("copy %s: %v", sourceChunk.GetFileIdString(), err)
@@ -80,6 +86,41 @@ func (fs *FilerSink) replicateOneChunk(sourceChunk *filer_pb.FileChunk, path str
 		SourceFileId: sourceChunk.GetFileIdString(),
 		CipherKey:    sourceChunk.CipherKey,
 		IsCompressed: sourceChunk.IsCompressed,
+		// the whole stored chunk is copied
+		TrimmedOffset: sourceChunk.TrimmedOffset,
+		StoredSize:    sourceChunk.StoredSize,
+	}, nil
+}
+
+// replicatePackedChunk copies only the range of a small file in a shared container, as a normal chunk.
+// The containers are neither compressed nor encrypted.
+func (fs *FilerSink) replicatePackedChunk(sourceChunk *filer_pb.FileChunk, path string) (*filer_pb.FileChunk, error) {
+
+	fileUrls, err := fs.filerSource.LookupFileId(sourceChunk.GetFileIdString())
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s: %v", sourceChunk.GetFileIdString(), err)
+	}
+	data := make([]byte, sourceChunk.Size)
+	n, err := util_http.RetriedFetchChunkData(data, fileUrls, nil, false, false, sourceChunk.DataOffset())
+	if err != nil {
+		return nil, fmt.Errorf("read %s at %d: %v", sourceChunk.GetFileIdString(), sourceChunk.DataOffset(), err)
+	}
+	if n != len(data) {
+		return nil, fmt.Errorf("read %s at %d: %d of %d bytes", sourceChunk.GetFileIdString(), sourceChunk.DataOffset(), n, len(data))
+	}
+
+	fileId, err := fs.write(sourceChunk, path, "", "application/octet-stream", false, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("copy %s: %v", sourceChunk.GetFileIdString(), err)
+	}
+
+	return &filer_pb.FileChunk{
+		FileId:       fileId,
+		Offset:       sourceChunk.Offset,
+		Size:         sourceChunk.Size,
+		ModifiedTsNs: sourceChunk.ModifiedTsNs,
+		ETag:         sourceChunk.ETag,
+		SourceFileId: sourceChunk.GetFileIdString(),
 	}, nil
 }
 
@@ -91,6 +132,11 @@ func (fs *FilerSink) fetchAndWrite(sourceChunk *filer_pb.FileChunk, path string)
 	}
 	defer util_http.CloseResponse(resp)
 
+	return fs.write(sourceChunk, path, filename, header.Get("Content-Type"), "gzip" == header.Get("Content-Encoding"), resp.Body)
+}
+
+func (fs *FilerSink) write(sourceChunk *filer_pb.FileChunk, path, filename, mimeType string, isInputCompressed bool, reader io.Reader) (fileId string, err error) {
+
 	uploader, err := operation.NewUploader()
 	if err != nil {
 		glog.V(0).Infof("upload source data %v: %v", sourceChunk.GetFileIdString(), err)
@@ -111,8 +157,8 @@ func (fs *FilerSink) fetchAndWrite(sourceChunk *filer_pb.FileChunk, path string)
 		&operation.UploadOption{
 			Filename:          filename,
 			Cipher:            false,
-			IsInputCompressed: "gzip" == header.Get("Content-Encoding"),
-			MimeType:          header.Get("Content-Type"),
+			IsInputCompressed: isInputCompressed,
+			MimeType:          mimeType,
 			PairMap:           nil,
 		},
 		func(host, fileId string) string {
@@ -120,10 +166,10 @@ func (fs *FilerSink) fetchAndWrite(sourceChunk *filer_pb.FileChunk, path string)
 			if fs.writeChunkByFiler {
 				fileUrl = fmt.Sprintf("http://%s/?proxyChunkId=%s", fs.address, fileId)
 			}
-			glog.V(4).Infof("replicating %s to %s header:%+v", filename, fileUrl, header)
+			glog.V(4).Infof("replicating %s to %s", sourceChunk.GetFileIdString(), fileUrl)
 			return fileUrl
 		},
-		resp.Body,
+		reader,
 	)
 
 	if err != nil {
@@ -21,9 +21,10 @@ type SeaweedFileIdClaims struct {
 }
 
 // SeaweedFilerClaims is created e.g. by S3 proxy server and consumed by Filer server.
-// Right now, it only contains the standard claims; but this might be extended later
-// for more fine-grained permissions.
+// The optional uid and gids are the identity checked against the acls of the entries.
 type SeaweedFilerClaims struct {
+	Uid  *uint32  `json:"uid,omitempty"`
+	Gids []uint32 `json:"gids,omitempty"` // the first one is the primary gid
 	jwt.RegisteredClaims
 }
 
@@ -51,13 +52,23 @@ func GenJwtForVolumeServer(signingKey SigningKey, expiresAfterSec int, fileId st
 // GenJwtForFilerServer creates a JSON-web-token for using the authenticated Filer API. Used f.e. inside
 // the S3 API
 func GenJwtForFilerServer(signingKey SigningKey, expiresAfterSec int) EncodedJwt {
+	return genJwtForFilerServer(signingKey, expiresAfterSec, SeaweedFilerClaims{})
+}
+
+// GenJwtForFilerServerAs creates a JSON-web-token for the Filer API carrying the identity of a user,
+// to check the acls of the entries
+func GenJwtForFilerServerAs(signingKey SigningKey, expiresAfterSec int, uid uint32, gids []uint32) EncodedJwt {
+	return genJwtForFilerServer(signingKey, expiresAfterSec, SeaweedFilerClaims{
+		Uid:  &uid,
+		Gids: gids,
+	})
+}
+
+func genJwtForFilerServer(signingKey SigningKey, expiresAfterSec int, claims SeaweedFilerClaims) EncodedJwt {
 	if len(signingKey) == 0 {
 		return ""
 	}
 
-	claims := SeaweedFilerClaims{
-		jwt.RegisteredClaims{},
-	}
 	if expiresAfterSec > 0 {
 		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Second * time.Duration(expiresAfterSec)))
 	}
@@ -0,0 +1,160 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// MasterKeyProvider wraps the data keys, which encrypt the volume data at rest, with a master key kept out of the volumes
+type MasterKeyProvider interface {
+	// WrapKey encrypts the data key with the current master key
+	WrapKey(dataKey []byte) (wrappedKey []byte, masterKeyId string, err error)
+	// UnwrapKey decrypts the data key with the master key it was wrapped with
+	UnwrapKey(wrappedKey []byte, masterKeyId string) (dataKey []byte, err error)
+}
+
+// LoadMasterKeyProvider loads the master key provider of the component, e.g. "volume.encryption",
+// from either a local key file or a key service. It returns nil if neither is configured.
+func LoadMasterKeyProvider(config *util.ViperProxy, component string) (MasterKeyProvider, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if keyFile := config.GetString(component + ".key_file"); keyFile != "" {
+		provider := &keyFileMasterKeyProvider{keyFile: keyFile}
+		// fail early on a missing or malformed key file
+		if _, _, err := provider.loadKeys(); err != nil {
+			return nil, err
+		}
+		return provider, nil
+	}
+	if url := config.GetString(component + ".kms.url"); url != "" {
+		return &kmsMasterKeyProvider{
+			url:        strings.TrimSuffix(url, "/"),
+			httpClient: &http.Client{Timeout: 10 * time.Second},
+		}, nil
+	}
+	return nil, nil
+}
+
+// keyFileMasterKeyProvider reads the master keys from a local file, one "<key id> <base64 encoded 32 byte key>" per line.
+// The last key is the current one, and the earlier ones are kept to unwrap the data keys not re-wrapped yet.
+// The file is read on every use, so a new master key can be appended without restarting.
+type keyFileMasterKeyProvider struct {
+	keyFile string
+}
+
+func (p *keyFileMasterKeyProvider) loadKeys() (keys map[string]util.CipherKey, currentKeyId string, err error) {
+	f, err := os.Open(p.keyFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("open master key file: %v", err)
+	}
+	defer f.Close()
+
+	keys = make(map[string]util.CipherKey)
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, "", fmt.Errorf("%s line %d: expecting <key id> <base64 key>", p.keyFile, lineNumber)
+		}
+		key, decodeErr := base64.StdEncoding.DecodeString(fields[1])
+		if decodeErr != nil || len(key) != 32 {
+			return nil, "", fmt.Errorf("%s line %d: the key is not a base64 encoded 32 byte key", p.keyFile, lineNumber)
+		}
+		keys[fields[0]] = key
+		currentKeyId = fields[0]
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("read master key file %s: %v", p.keyFile, err)
+	}
+	if currentKeyId == "" {
+		return nil, "", fmt.Errorf("no master key in %s", p.keyFile)
+	}
+	return keys, currentKeyId, nil
+}
+
+func (p *keyFileMasterKeyProvider) WrapKey(dataKey []byte) ([]byte, string, error) {
+	keys, currentKeyId, err := p.loadKeys()
+	if err != nil {
+		return nil, "", err
+	}
+	wrappedKey, err := util.Encrypt(dataKey, keys[currentKeyId])
+	return wrappedKey, currentKeyId, err
+}
+
+func (p *keyFileMasterKeyProvider) UnwrapKey(wrappedKey []byte, masterKeyId string) ([]byte, error) {
+	keys, _, err := p.loadKeys()
+	if err != nil {
+		return nil, err
+	}
+	key, found := keys[masterKeyId]
+	if !found {
+		return nil, fmt.Errorf("master key %s is not in %s", masterKeyId, p.keyFile)
+	}
+	return util.Decrypt(wrappedKey, key)
+}
+
+// kmsMasterKeyProvider asks a key service to wrap and unwrap the data keys, so the master keys never leave it.
+// It stands in for a KMIP server, with the Encrypt and Decrypt operations as JSON over HTTP:
+//
+//	POST <url>/wrap   {"plaintext": <base64>}                     => {"key_id": "...", "ciphertext": <base64>}
+//	POST <url>/unwrap {"key_id": "...", "ciphertext": <base64>}   => {"plaintext": <base64>}
+type kmsMasterKeyProvider struct {
+	url        string
+	httpClient *http.Client
+}
+
+type kmsRequest struct {
+	KeyId      string `json:"key_id,omitempty"`
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+func (p *kmsMasterKeyProvider) call(operation string, request *kmsRequest) (response *kmsRequest, err error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Post(p.url+"/"+operation, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("key service %s: %v", operation, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("key service %s: %s", operation, resp.Status)
+	}
+	response = &kmsRequest{}
+	if err = json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, fmt.Errorf("key service %s response: %v", operation, err)
+	}
+	return response, nil
+}
+
+func (p *kmsMasterKeyProvider) WrapKey(dataKey []byte) ([]byte, string, error) {
+	response, err := p.call("wrap", &kmsRequest{Plaintext: dataKey})
+	if err != nil {
+		return nil, "", err
+	}
+	return response.Ciphertext, response.KeyId, nil
+}
+
+func (p *kmsMasterKeyProvider) UnwrapKey(wrappedKey []byte, masterKeyId string) ([]byte, error) {
+	response, err := p.call("unwrap", &kmsRequest{KeyId: masterKeyId, Ciphertext: wrappedKey})
+	if err != nil {
+		return nil, err
+	}
+	return response.Plaintext, nil
+}
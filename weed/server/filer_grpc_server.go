@@ -8,8 +8,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/seaweedfs/seaweedfs/weed/cluster"
-
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
@@ -142,6 +140,11 @@ func (fs *FilerServer) CreateEntry(ctx context.Context, req *filer_pb.CreateEntr
 
 	resp = &filer_pb.CreateEntryResponse{}
 
+	if !req.Entry.IsDirectory {
+		unlock := fs.filer.LockEntries(util.NewFullPath(req.Directory, req.Entry.Name))
+		defer unlock()
+	}
+
 	chunks, garbage, err2 := fs.cleanupChunks(util.Join(req.Directory, req.Entry.Name), nil, req.Entry)
 	if err2 != nil {
 		return &filer_pb.CreateEntryResponse{}, fmt.Errorf("CreateEntry cleanupChunks %s %s: %v", req.Directory, req.Entry.Name, err2)
@@ -172,6 +175,10 @@ func (fs *FilerServer) UpdateEntry(ctx context.Context, req *filer_pb.UpdateEntr
 	glog.V(4).Infof("UpdateEntry %v", req)
 
 	fullpath := util.Join(req.Directory, req.Entry.Name)
+	if !req.Entry.IsDirectory {
+		unlock := fs.filer.LockEntries(util.FullPath(fullpath))
+		defer unlock()
+	}
 	entry, err := fs.filer.FindEntry(ctx, util.FullPath(fullpath))
 	if err != nil {
 		return &filer_pb.UpdateEntryResponse{}, fmt.Errorf("not found %s: %v", fullpath, err)
@@ -189,6 +196,10 @@ func (fs *FilerServer) UpdateEntry(ctx context.Context, req *filer_pb.UpdateEntr
 		return &filer_pb.UpdateEntryResponse{}, err
 	}
 
+	if err = fs.filer.CheckQuota(ctx, entry, newEntry); err != nil {
+		return &filer_pb.UpdateEntryResponse{}, err
+	}
+
 	if err = fs.filer.UpdateEntry(ctx, entry, newEntry); err == nil {
 		fs.filer.DeleteChunksNotRecursive(garbage)
 
@@ -244,9 +255,8 @@ func (fs *FilerServer) AppendToEntry(ctx context.Context, req *filer_pb.AppendTo
 	glog.V(4).Infof("AppendToEntry %v", req)
 	fullpath := util.NewFullPath(req.Directory, req.EntryName)
 
-	lockClient := cluster.NewLockClient(fs.grpcDialOption, fs.option.Host)
-	lock := lockClient.NewShortLivedLock(string(fullpath), string(fs.option.Host))
-	defer lock.StopShortLivedLock()
+	unlock := fs.filer.LockEntries(fullpath)
+	defer unlock()
 
 	var offset int64 = 0
 	entry, err := fs.filer.FindEntry(ctx, fullpath)
@@ -311,6 +321,13 @@ func (fs *FilerServer) AssignVolume(ctx context.Context, req *filer_pb.AssignVol
 		return &filer_pb.AssignVolumeResponse{Error: fmt.Sprintf("assign volume: %v", err)}, nil
 	}
 
+	if req.Path != "" {
+		if err = fs.filer.CheckDirectoryQuota(ctx, util.FullPath(req.Path)); err != nil {
+			glog.V(3).Infof("AssignVolume: %v", err)
+			return &filer_pb.AssignVolumeResponse{Error: fmt.Sprintf("assign volume: %v", err)}, nil
+		}
+	}
+
 	assignRequest, altRequest := so.ToAssignRequests(int(req.Count))
 
 	assignResult, err := operation.Assign(fs.filer.GetMaster, fs.grpcDialOption, assignRequest, altRequest)
@@ -0,0 +1,196 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// CloneEntry creates the target entry referencing the chunks of the source entry, or
+// clones the chunks fully inside the byte range into the existing target entry.
+func (fs *FilerServer) CloneEntry(ctx context.Context, req *filer_pb.CloneEntryRequest) (*filer_pb.CloneEntryResponse, error) {
+
+	glog.V(4).Infof("CloneEntry %v", req)
+
+	resp := &filer_pb.CloneEntryResponse{}
+	if err := fs.cloneEntry(ctx, req, resp); err != nil {
+		glog.V(3).Infof("CloneEntry %s/%s => %s/%s: %v", req.SourceDirectory, req.SourceName, req.TargetDirectory, req.TargetName, err)
+		resp.Error = err.Error()
+	}
+	return resp, nil
+}
+
+func (fs *FilerServer) cloneEntry(ctx context.Context, req *filer_pb.CloneEntryRequest, resp *filer_pb.CloneEntryResponse) error {
+
+	sourcePath := util.NewFullPath(req.SourceDirectory, req.SourceName)
+	targetPath := util.NewFullPath(req.TargetDirectory, req.TargetName)
+	if !req.IsRange && sourcePath == targetPath {
+		return fmt.Errorf("clone %s to itself", sourcePath)
+	}
+	if req.IsRange && (req.SourceOffset < 0 || req.TargetOffset < 0 || req.Length <= 0) {
+		return fmt.Errorf("invalid range [%d,%d) => %d", req.SourceOffset, req.SourceOffset+req.Length, req.TargetOffset)
+	}
+
+	// the writers of both entries wait for the clone, so they read the shared chunks before saving
+	unlock := fs.filer.LockEntries(sourcePath, targetPath)
+	defer unlock()
+
+	source, err := fs.filer.FindEntry(ctx, sourcePath)
+	if err != nil {
+		return fmt.Errorf("find %s: %v", sourcePath, err)
+	}
+	if source.IsDirectory() {
+		return fmt.Errorf("%s is a directory", sourcePath)
+	}
+	if source.Remote != nil {
+		return fmt.Errorf("%s is a remote entry", sourcePath)
+	}
+
+	dataChunks, manifestChunks, err := filer.ResolveChunkManifest(fs.lookupFileId, source.GetChunks(), 0, math.MaxInt64)
+	if err != nil {
+		return fmt.Errorf("resolve chunks of %s: %v", sourcePath, err)
+	}
+
+	// mark the cloned chunks as shared in the source entry first
+	var updated, shared []*filer_pb.FileChunk
+	var changed bool
+	if req.IsRange {
+		updated, shared, changed = filer.ShareChunkRange(dataChunks, req.SourceOffset, req.SourceOffset+req.Length)
+	} else {
+		updated, shared, changed = filer.ShareAllChunks(dataChunks)
+	}
+	if changed {
+		if err = fs.saveSharedChunks(ctx, source, updated, manifestChunks, req.Signatures); err != nil {
+			return err
+		}
+		if source, err = fs.filer.FindEntry(ctx, sourcePath); err != nil {
+			return fmt.Errorf("find %s: %v", sourcePath, err)
+		}
+	}
+	resp.SourceEntry = source.ToProtoEntry()
+
+	if req.IsRange {
+		return fs.cloneEntryRange(ctx, req, resp, targetPath, shared)
+	}
+
+	so, err := fs.detectStorageOption(string(targetPath), "", "", source.TtlSec, "", "", "", "")
+	if err != nil {
+		return err
+	}
+	chunks := make([]*filer_pb.FileChunk, 0, len(shared))
+	for _, chunk := range shared {
+		chunks = append(chunks, proto.Clone(chunk).(*filer_pb.FileChunk))
+	}
+	chunks, err = filer.MaybeManifestize(fs.saveAsChunk(so), chunks)
+	if err != nil {
+		// not good, but should be ok
+		glog.V(0).Infof("MaybeManifestize: %v", err)
+	}
+
+	now := time.Now()
+	target := &filer.Entry{
+		FullPath: targetPath,
+		Attr:     source.Attr,
+		Extended: source.Extended,
+		Chunks:   chunks,
+		Content:  source.Content,
+	}
+	target.Crtime, target.Mtime = now, now
+	target.Inode = 0
+	if err = fs.filer.CreateEntry(ctx, target, false, false, req.Signatures, false, fs.filer.MaxFilenameLength); err != nil {
+		return err
+	}
+	resp.Entry = target.ToProtoEntry()
+	return nil
+}
+
+// saveSharedChunks updates the source entry with the shared chunks, merging the chunks into new manifests if needed
+func (fs *FilerServer) saveSharedChunks(ctx context.Context, source *filer.Entry, dataChunks, manifestChunks []*filer_pb.FileChunk, signatures []int32) (err error) {
+	newSource := source.ShallowClone()
+	newSource.Chunks = dataChunks
+	if len(manifestChunks) > 0 {
+		so, err := fs.detectStorageOption(string(source.FullPath), "", "", source.TtlSec, "", "", "", "")
+		if err != nil {
+			return err
+		}
+		if newSource.Chunks, err = filer.MaybeManifestize(fs.saveAsChunk(so), dataChunks); err != nil {
+			// not good, but should be ok
+			glog.V(0).Infof("MaybeManifestize: %v", err)
+		}
+	}
+	if err = fs.filer.UpdateEntry(ctx, source, newSource); err != nil {
+		return fmt.Errorf("update %s: %v", source.FullPath, err)
+	}
+	fs.filer.NotifyUpdateEvent(ctx, source, newSource, true, false, signatures)
+	fs.filer.DeleteChunksNotRecursive(manifestChunks)
+	return nil
+}
+
+func (fs *FilerServer) cloneEntryRange(ctx context.Context, req *filer_pb.CloneEntryRequest, resp *filer_pb.CloneEntryResponse, targetPath util.FullPath, shared []*filer_pb.FileChunk) error {
+
+	target, err := fs.filer.FindEntry(ctx, targetPath)
+	if err != nil {
+		return fmt.Errorf("find %s: %v", targetPath, err)
+	}
+	if target.IsDirectory() {
+		return fmt.Errorf("%s is a directory", targetPath)
+	}
+	if len(shared) == 0 || len(target.Content) > 0 {
+		// the inlined content is copied by the client
+		resp.Entry = target.ToProtoEntry()
+		return nil
+	}
+
+	// the cloned chunks are newer than the existing data in the target range
+	tsNs := time.Now().UnixNano()
+	delta := req.TargetOffset - req.SourceOffset
+	pbTarget := target.ToProtoEntry()
+	pbTarget.Chunks = append([]*filer_pb.FileChunk{}, pbTarget.Chunks...)
+	sort.Slice(shared, func(i, j int) bool {
+		return shared[i].Offset < shared[j].Offset
+	})
+	for _, chunk := range shared {
+		cloned := proto.Clone(chunk).(*filer_pb.FileChunk)
+		cloned.Offset += delta
+		cloned.ModifiedTsNs = tsNs
+		pbTarget.Chunks = append(pbTarget.Chunks, cloned)
+
+		if n := len(resp.ClonedRanges); n > 0 && resp.ClonedRanges[n-1].Offset+resp.ClonedRanges[n-1].Size == chunk.Offset {
+			resp.ClonedRanges[n-1].Size += int64(chunk.Size)
+		} else {
+			resp.ClonedRanges = append(resp.ClonedRanges, &filer_pb.ClonedRange{Offset: chunk.Offset, Size: int64(chunk.Size)})
+		}
+		if stop := uint64(cloned.Offset) + cloned.Size; stop > pbTarget.Attributes.FileSize {
+			pbTarget.Attributes.FileSize = stop
+		}
+	}
+	pbTarget.Attributes.Mtime = time.Now().Unix()
+
+	chunks, garbage, err := fs.cleanupChunks(string(targetPath), target, pbTarget)
+	if err != nil {
+		return fmt.Errorf("cleanup chunks %s: %v", targetPath, err)
+	}
+	newTarget := filer.FromPbEntry(req.TargetDirectory, pbTarget)
+	newTarget.Chunks = chunks
+
+	if err = fs.filer.CheckQuota(ctx, target, newTarget); err != nil {
+		return err
+	}
+	if err = fs.filer.UpdateEntry(ctx, target, newTarget); err != nil {
+		return fmt.Errorf("update %s: %v", targetPath, err)
+	}
+	fs.filer.DeleteChunksNotRecursive(garbage)
+	fs.filer.NotifyUpdateEvent(ctx, target, newTarget, true, false, req.Signatures)
+
+	resp.Entry = newTarget.ToProtoEntry()
+	return nil
+}
@@ -0,0 +1,121 @@
+package weed_server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/filer/leveldb"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/spf13/viper"
+)
+
+// newLockingTestFilerServer serves the distributed locks of its own entries
+func newLockingTestFilerServer(t *testing.T) *FilerServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	host := pb.NewServerAddressWithGrpcPort("127.0.0.1:1", listener.Addr().(*net.TCPAddr).Port)
+	grpcDialOption := grpc.WithTransportCredentials(insecure.NewCredentials())
+
+	f := filer.NewFiler(pb.ServerDiscovery{}, grpcDialOption, host, "", "", "", "", 255, nil)
+	f.Dlm.LockRing.SetSnapshot([]pb.ServerAddress{host})
+	config := viper.New()
+	config.Set("leveldb.dir", t.TempDir())
+	store := &leveldb.LevelDBStore{}
+	if err := store.Initialize(config, "leveldb."); err != nil {
+		t.Fatalf("initialize store: %v", err)
+	}
+	f.SetStore(store)
+
+	fs := &FilerServer{
+		filer:          f,
+		option:         &FilerOption{Host: host},
+		grpcDialOption: grpcDialOption,
+	}
+	grpcServer := grpc.NewServer()
+	filer_pb.RegisterSeaweedFilerServer(grpcServer, fs)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+	return fs
+}
+
+func TestWriterKeepsChunksSharedByClone(t *testing.T) {
+	fs := newLockingTestFilerServer(t)
+	ctx := context.Background()
+
+	chunk := &filer_pb.FileChunk{FileId: "1,0101", Offset: 0, Size: 10, ModifiedTsNs: 1}
+	source := &filer.Entry{
+		FullPath: "/dir/source",
+		Attr:     filer.Attr{Mode: 0644, Mtime: time.Now(), FileSize: 10},
+		Chunks:   []*filer_pb.FileChunk{chunk},
+	}
+	if err := fs.filer.CreateEntry(ctx, source, false, false, nil, false, fs.filer.MaxFilenameLength); err != nil {
+		t.Fatalf("create source: %v", err)
+	}
+
+	// a clone is marking the chunks as shared
+	unlock := fs.filer.LockEntries(source.FullPath)
+
+	// a mount flushes the chunks it read before the clone
+	done := make(chan error, 1)
+	go func() {
+		resp, err := fs.CreateEntry(ctx, &filer_pb.CreateEntryRequest{
+			Directory: "/dir",
+			Entry: &filer_pb.Entry{
+				Name:       "source",
+				Attributes: &filer_pb.FuseAttributes{FileMode: 0644, Mtime: time.Now().Unix(), FileSize: 20},
+				Chunks: []*filer_pb.FileChunk{
+					{FileId: "1,0101", Offset: 0, Size: 10, ModifiedTsNs: 1},
+					{FileId: "1,0202", Offset: 10, Size: 10, ModifiedTsNs: 2},
+				},
+			},
+		})
+		if err == nil && resp.Error != "" {
+			err = errors.New(resp.Error)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("the writer did not wait for the clone: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	updated, _, changed := filer.ShareAllChunks(source.GetChunks())
+	if !changed {
+		t.Fatalf("no chunk shared")
+	}
+	shared := source.ShallowClone()
+	shared.Chunks = updated
+	if err := fs.filer.UpdateEntry(ctx, source, shared); err != nil {
+		t.Fatalf("share chunks: %v", err)
+	}
+	unlock()
+
+	if err := <-done; err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	entry, err := fs.filer.FindEntry(ctx, source.FullPath)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(entry.GetChunks()) != 2 {
+		t.Fatalf("the write is lost: %d chunks", len(entry.GetChunks()))
+	}
+	for _, c := range entry.GetChunks() {
+		if c.GetFileIdString() == "1,0101" && !bytes.Equal(c.Fingerprint, filer.CloneFingerprint("1,0101")) {
+			t.Errorf("the shared chunk is not counted any more: %x", c.Fingerprint)
+		}
+	}
+}
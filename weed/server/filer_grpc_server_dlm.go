@@ -112,18 +112,178 @@ func (fs *FilerServer) FindLockOwner(ctx context.Context, req *filer_pb.FindLock
 	}, nil
 }
 
+func toRangeLock(lock *filer_pb.RangeLock) *lock_manager.RangeLock {
+	return &lock_manager.RangeLock{
+		Session:     lock.Session,
+		Owner:       lock.Owner,
+		Pid:         lock.Pid,
+		Start:       lock.Start,
+		End:         lock.End,
+		IsExclusive: lock.IsExclusive,
+		ExpiredAtNs: lock.ExpiredAtNs,
+	}
+}
+
+func toPbRangeLock(lock *lock_manager.RangeLock) *filer_pb.RangeLock {
+	return &filer_pb.RangeLock{
+		Session:     lock.Session,
+		Owner:       lock.Owner,
+		Pid:         lock.Pid,
+		Start:       lock.Start,
+		End:         lock.End,
+		IsExclusive: lock.IsExclusive,
+		ExpiredAtNs: lock.ExpiredAtNs,
+		Name:        lock.Key,
+	}
+}
+
+// DistributedRangeLock is a grpc handler to acquire or test a byte range lock
+func (fs *FilerServer) DistributedRangeLock(ctx context.Context, req *filer_pb.RangeLockRequest) (resp *filer_pb.RangeLockResponse, err error) {
+
+	resp = &filer_pb.RangeLockResponse{}
+	if req.Lock == nil {
+		resp.Error = "missing lock"
+		return resp, nil
+	}
+
+	lock := toRangeLock(req.Lock)
+	lock.ExpiredAtNs = time.Now().Add(time.Duration(req.SecondsToLock) * time.Second).UnixNano()
+	conflict, movedTo, err := fs.filer.Dlm.RangeLock(req.Name, lock, req.IsTest)
+	glog.V(3).Infof("range lock %s [%d,%d] %v test=%v, isMoved=%v %v", req.Name, lock.Start, lock.End, lock.IsExclusive, req.IsTest, req.IsMoved, movedTo)
+	if movedTo != "" && movedTo != fs.option.Host && !req.IsMoved {
+		err = pb.WithFilerClient(false, 0, movedTo, fs.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+			secondResp, err := client.DistributedRangeLock(context.Background(), &filer_pb.RangeLockRequest{
+				Name:          req.Name,
+				Lock:          req.Lock,
+				SecondsToLock: req.SecondsToLock,
+				IsTest:        req.IsTest,
+				IsMoved:       true,
+			})
+			if err == nil {
+				resp.Conflict = secondResp.Conflict
+				resp.Error = secondResp.Error
+			}
+			return err
+		})
+	} else if conflict != nil {
+		resp.Conflict = toPbRangeLock(conflict)
+	}
+
+	if err != nil {
+		resp.Error = fmt.Sprintf("%v", err)
+	}
+	if movedTo != "" {
+		resp.LockHostMovedTo = string(movedTo)
+	}
+
+	return resp, nil
+}
+
+// DistributedRangeUnlock is a grpc handler to release a byte range, or all locks of a session
+func (fs *FilerServer) DistributedRangeUnlock(ctx context.Context, req *filer_pb.RangeUnlockRequest) (resp *filer_pb.RangeUnlockResponse, err error) {
+
+	resp = &filer_pb.RangeUnlockResponse{}
+
+	var movedTo pb.ServerAddress
+	resp.SessionHasLocks, movedTo, err = fs.filer.Dlm.RangeUnlock(req.Name, req.Session, req.Owner, req.AllOwners, req.Start, req.End)
+
+	if !req.IsMoved && movedTo != "" && movedTo != fs.option.Host {
+		err = pb.WithFilerClient(false, 0, movedTo, fs.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+			secondResp, err := client.DistributedRangeUnlock(context.Background(), &filer_pb.RangeUnlockRequest{
+				Name:      req.Name,
+				Session:   req.Session,
+				Owner:     req.Owner,
+				AllOwners: req.AllOwners,
+				Start:     req.Start,
+				End:       req.End,
+				IsMoved:   true,
+			})
+			if err == nil {
+				resp.SessionHasLocks = secondResp.SessionHasLocks
+				resp.Error = secondResp.Error
+			}
+			return err
+		})
+	}
+
+	if err != nil {
+		resp.Error = fmt.Sprintf("%v", err)
+	}
+	if movedTo != "" {
+		resp.MovedTo = string(movedTo)
+	}
+
+	return resp, nil
+}
+
+// RenewRangeLocks is a grpc handler to extend the range locks of a session, forwarding to the filers owning the locks.
+// It returns the names the session holds no lock on any more, so the client can fail the affected lock holders.
+func (fs *FilerServer) RenewRangeLocks(ctx context.Context, req *filer_pb.RenewRangeLocksRequest) (resp *filer_pb.RenewRangeLocksResponse, err error) {
+
+	resp = &filer_pb.RenewRangeLocksResponse{}
+
+	expiredAtNs := time.Now().Add(time.Duration(req.SecondsToLock) * time.Second).UnixNano()
+	movedNames := make(map[pb.ServerAddress][]string)
+	for _, name := range req.Names {
+		renewed, movedTo, renewErr := fs.filer.Dlm.RenewRangeLocks(name, req.Session, expiredAtNs)
+		if renewErr != nil {
+			err = renewErr
+			continue
+		}
+		if movedTo == fs.filer.Dlm.Host {
+			if !renewed {
+				resp.LostNames = append(resp.LostNames, name)
+			}
+		} else if movedTo != "" && !req.IsMoved {
+			movedNames[movedTo] = append(movedNames[movedTo], name)
+		}
+	}
+
+	for movedTo, names := range movedNames {
+		if forwardErr := pb.WithFilerClient(false, 0, movedTo, fs.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+			secondResp, err := client.RenewRangeLocks(context.Background(), &filer_pb.RenewRangeLocksRequest{
+				Names:         names,
+				Session:       req.Session,
+				SecondsToLock: req.SecondsToLock,
+				IsMoved:       true,
+			})
+			if err != nil {
+				return err
+			}
+			resp.LostNames = append(resp.LostNames, secondResp.LostNames...)
+			if secondResp.Error != "" {
+				return fmt.Errorf("%s", secondResp.Error)
+			}
+			return nil
+		}); forwardErr != nil {
+			err = forwardErr
+		}
+	}
+
+	if err != nil {
+		resp.Error = fmt.Sprintf("%v", err)
+	}
+
+	return resp, nil
+}
+
 // TransferLocks is a grpc handler to handle FilerServer's TransferLocksRequest
 func (fs *FilerServer) TransferLocks(ctx context.Context, req *filer_pb.TransferLocksRequest) (*filer_pb.TransferLocksResponse, error) {
 
 	for _, lock := range req.Locks {
 		fs.filer.Dlm.InsertLock(lock.Name, lock.ExpiredAtNs, lock.RenewToken, lock.Owner)
 	}
+	for _, lock := range req.RangeLocks {
+		fs.filer.Dlm.InsertRangeLock(lock.Name, toRangeLock(lock))
+	}
 
 	return &filer_pb.TransferLocksResponse{}, nil
 
 }
 
 func (fs *FilerServer) OnDlmChangeSnapshot(snapshot []pb.ServerAddress) {
+	fs.transferRangeLocks(snapshot)
+
 	locks := fs.filer.Dlm.SelectNotOwnedLocks(snapshot)
 	if len(locks) == 0 {
 		return
@@ -150,3 +310,27 @@ func (fs *FilerServer) OnDlmChangeSnapshot(snapshot []pb.ServerAddress) {
 	}
 
 }
+
+func (fs *FilerServer) transferRangeLocks(snapshot []pb.ServerAddress) {
+	locks := fs.filer.Dlm.SelectNotOwnedRangeLocks(snapshot)
+	if len(locks) == 0 {
+		return
+	}
+
+	serverLocks := make(map[pb.ServerAddress][]*filer_pb.RangeLock)
+	for _, lock := range locks {
+		server := fs.filer.Dlm.CalculateTargetServer(lock.Key, snapshot)
+		serverLocks[server] = append(serverLocks[server], toPbRangeLock(lock))
+	}
+	for server, rangeLocks := range serverLocks {
+		if err := pb.WithFilerClient(false, 0, server, fs.grpcDialOption, func(client filer_pb.SeaweedFilerClient) error {
+			_, err := client.TransferLocks(context.Background(), &filer_pb.TransferLocksRequest{
+				RangeLocks: rangeLocks,
+			})
+			return err
+		}); err != nil {
+			// the range locks are lost, as if the sessions expired
+			glog.Errorf("transfer %d range locks to %v: %v", len(rangeLocks), server, err)
+		}
+	}
+}
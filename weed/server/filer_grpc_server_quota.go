@@ -0,0 +1,20 @@
+package weed_server
+
+import (
+	"context"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+)
+
+func (fs *FilerServer) GetQuotaUsage(ctx context.Context, req *filer_pb.GetQuotaUsageRequest) (*filer_pb.GetQuotaUsageResponse, error) {
+
+	items, err := fs.filer.ListQuotaUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filer_pb.GetQuotaUsageResponse{
+		Items: items,
+	}, nil
+
+}
@@ -34,6 +34,11 @@ func (fs *FilerServer) AtomicRenameEntry(ctx context.Context, req *filer_pb.Atom
 		return nil, fmt.Errorf("%s/%s not found: %v", req.OldDirectory, req.OldName, err)
 	}
 
+	if err := fs.filer.CheckRenameQuota(ctx, oldEntry, newParent.Child(req.NewName)); err != nil {
+		fs.filer.RollbackTransaction(ctx)
+		return nil, err
+	}
+
 	moveErr := fs.moveEntry(ctx, nil, oldParent, oldEntry, newParent, req.NewName, req.Signatures)
 	if moveErr != nil {
 		fs.filer.RollbackTransaction(ctx)
@@ -72,6 +77,11 @@ func (fs *FilerServer) StreamRenameEntry(req *filer_pb.StreamRenameEntryRequest,
 		return fmt.Errorf("%s/%s not found: %v", req.OldDirectory, req.OldName, err)
 	}
 
+	if err := fs.filer.CheckRenameQuota(ctx, oldEntry, newParent.Child(req.NewName)); err != nil {
+		fs.filer.RollbackTransaction(ctx)
+		return err
+	}
+
 	if oldEntry.IsDirectory() {
 		// follow https://pubs.opengroup.org/onlinepubs/000095399/functions/rename.html
 		targetDir := newParent.Child(req.NewName)
@@ -173,7 +183,8 @@ func (fs *FilerServer) moveSelfEntry(ctx context.Context, stream filer_pb.Seawee
 		Remote:          entry.Remote,
 		Quota:           entry.Quota,
 	}
-	if createErr := fs.filer.CreateEntry(ctx, newEntry, false, false, signatures, false, fs.filer.MaxFilenameLength); createErr != nil {
+	// renames are not rejected by quotas, the usage just moves along with the entry
+	if createErr := fs.filer.CreateEntry(context.WithValue(ctx, "OP", "MV"), newEntry, false, false, signatures, false, fs.filer.MaxFilenameLength); createErr != nil {
 		return createErr
 	}
 	if stream != nil {
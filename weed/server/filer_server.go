@@ -207,6 +207,15 @@ func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption)
 
 	fs.filer.LoadFilerConf()
 
+	fs.filer.LoadQuotaConf()
+
+	go fs.filer.Packer.LoopFlushRefs()
+	go fs.filer.Packer.LoopCompaction()
+	go fs.filer.Packer.LoopAdoption()
+	go fs.filer.Deduper.LoopFlushRefs()
+	go fs.filer.Deduper.LoopRelease()
+	go fs.filer.LoopFlushQuotaUsage()
+
 	fs.filer.LoadRemoteStorageConfAndMapping()
 
 	grace.OnInterrupt(func() {
@@ -75,6 +75,11 @@ func (fs *FilerServer) filerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !fs.checkAclAuthorization(r) {
+		writeJsonError(w, r, http.StatusForbidden, errors.New("permission denied"))
+		return
+	}
+
 	w.Header().Set("Server", "SeaweedFS "+util.VERSION)
 
 	switch r.Method {
@@ -163,6 +168,11 @@ func (fs *FilerServer) readonlyFilerHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !fs.checkAclAuthorization(r) {
+		writeJsonError(w, r, http.StatusForbidden, errors.New("permission denied"))
+		return
+	}
+
 	w.Header().Set("Server", "SeaweedFS "+util.VERSION)
 
 	switch r.Method {
@@ -188,20 +198,9 @@ func OptionsHandler(w http.ResponseWriter, r *http.Request, isReadOnly bool) {
 // maybeCheckJwtAuthorization returns true if access should be granted, false if it should be denied
 func (fs *FilerServer) maybeCheckJwtAuthorization(r *http.Request, isWrite bool) bool {
 
-	var signingKey security.SigningKey
-
-	if isWrite {
-		if len(fs.filerGuard.SigningKey) == 0 {
-			return true
-		} else {
-			signingKey = fs.filerGuard.SigningKey
-		}
-	} else {
-		if len(fs.filerGuard.ReadSigningKey) == 0 {
-			return true
-		} else {
-			signingKey = fs.filerGuard.ReadSigningKey
-		}
+	signingKey := fs.jwtSigningKey(isWrite)
+	if len(signingKey) == 0 {
+		return true
 	}
 
 	tokenStr := security.GetJwt(r)
@@ -223,6 +222,13 @@ func (fs *FilerServer) maybeCheckJwtAuthorization(r *http.Request, isWrite bool)
 	}
 }
 
+func (fs *FilerServer) jwtSigningKey(isWrite bool) security.SigningKey {
+	if isWrite {
+		return fs.filerGuard.SigningKey
+	}
+	return fs.filerGuard.ReadSigningKey
+}
+
 func (fs *FilerServer) filerHealthzHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Server", "SeaweedFS "+util.VERSION)
 	if _, err := fs.filer.Store.FindEntry(context.Background(), filer.TopicsDir); err != nil && err != filer_pb.ErrNotFound {
@@ -0,0 +1,88 @@
+package weed_server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/security"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// aclIdentityFromRequest returns the identity signed in the jwt of the request, or nil if there is none.
+// The identity is only trusted from a jwt verified with the filer signing key.
+func (fs *FilerServer) aclIdentityFromRequest(r *http.Request) *filer.AclIdentity {
+	isWrite := r.Method != http.MethodGet && r.Method != http.MethodHead
+	signingKey := fs.jwtSigningKey(isWrite)
+	if len(signingKey) == 0 {
+		return nil
+	}
+	tokenStr := security.GetJwt(r)
+	if tokenStr == "" {
+		return nil
+	}
+	claims := &security.SeaweedFilerClaims{}
+	token, err := security.DecodeJwt(signingKey, tokenStr, claims)
+	if err != nil || !token.Valid || claims.Uid == nil {
+		return nil
+	}
+	return &filer.AclIdentity{
+		Uid:  *claims.Uid,
+		Gids: claims.Gids,
+	}
+}
+
+// checkAclAuthorization returns true if access should be granted.
+// Only the requests signed with an identity are checked against the entries carrying a POSIX or NFSv4 acl.
+// The other requests are either not authenticated at all, or come from the services holding the signing key,
+// like the S3 gateway, which apply their own policies. The mount and WebDAV check the acls with their own identity.
+// The reads are checked by GetOrHeadHandler, with the entry it looks up anyway.
+func (fs *FilerServer) checkAclAuthorization(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	identity := fs.aclIdentityFromRequest(r)
+	if identity == nil {
+		return true
+	}
+
+	path := r.URL.Path
+	isDirectoryPath := strings.HasSuffix(path, "/")
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	dir, _ := util.FullPath(path).DirAndName()
+
+	switch r.Method {
+	case http.MethodDelete:
+		return fs.isAclAllowed(r.Context(), util.FullPath(dir), identity, filer.AccessWrite|filer.AccessExecute)
+	case http.MethodPost, http.MethodPut:
+		if isDirectoryPath {
+			// the file name comes from the multipart form
+			return fs.isAclAllowed(r.Context(), util.FullPath(path), identity, filer.AccessWrite|filer.AccessExecute)
+		}
+		if entry, err := fs.filer.FindEntry(r.Context(), util.FullPath(path)); err == nil && !entry.IsDirectory() {
+			return !filer.HasAcl(entry.Extended) || entry.CheckAccess(identity, filer.AccessWrite)
+		}
+		return fs.isAclAllowed(r.Context(), util.FullPath(dir), identity, filer.AccessWrite|filer.AccessExecute)
+	}
+	return true
+}
+
+func (fs *FilerServer) isAclAllowed(ctx context.Context, fullpath util.FullPath, identity *filer.AclIdentity, want uint32) bool {
+	entry, err := fs.filer.FindEntry(ctx, fullpath)
+	if err != nil || !filer.HasAcl(entry.Extended) {
+		return true
+	}
+	return entry.CheckAccess(identity, want)
+}
+
+// isAclReadAllowed checks the entry read by a request signed with an identity
+func (fs *FilerServer) isAclReadAllowed(r *http.Request, entry *filer.Entry) bool {
+	if !filer.HasAcl(entry.Extended) {
+		return true
+	}
+	identity := fs.aclIdentityFromRequest(r)
+	return identity == nil || entry.CheckAccess(identity, filer.AccessRead)
+}
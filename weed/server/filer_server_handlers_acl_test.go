@@ -0,0 +1,142 @@
+package weed_server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/filer/leveldb"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/security"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	aclTestOwner = 1000
+	aclTestOther = 1001
+	aclTestGroup = 100
+)
+
+// newAclTestFilerServer serves /dir/file, both with an acl only allowing the owner
+func newAclTestFilerServer(t *testing.T, signingKey string) *FilerServer {
+	f := filer.NewFiler(pb.ServerDiscovery{}, nil, "", "", "", "", "", 255, nil)
+	config := viper.New()
+	config.Set("leveldb.dir", t.TempDir())
+	store := &leveldb.LevelDBStore{}
+	if err := store.Initialize(config, "leveldb."); err != nil {
+		t.Fatalf("initialize store: %v", err)
+	}
+	f.SetStore(store)
+
+	acl := filer.PosixAcl{
+		{Tag: filer.PosixAclUserObj, Perm: 7},
+		{Tag: filer.PosixAclGroupObj, Perm: 0},
+		{Tag: filer.PosixAclOther, Perm: 0},
+	}.Bytes()
+	ctx := context.Background()
+	for _, entry := range []*filer.Entry{
+		{
+			FullPath: "/dir",
+			Attr:     filer.Attr{Mode: os.ModeDir | 0700, Uid: aclTestOwner, Gid: aclTestGroup, Mtime: time.Now()},
+			Extended: map[string][]byte{filer.XattrPrefix + filer.PosixAclAccessXattr: acl},
+		},
+		{
+			FullPath: "/dir/file",
+			Attr:     filer.Attr{Mode: 0600, Uid: aclTestOwner, Gid: aclTestGroup, Mtime: time.Now()},
+			Extended: map[string][]byte{filer.XattrPrefix + filer.PosixAclAccessXattr: acl},
+			Content:  []byte("data"),
+		},
+	} {
+		if err := f.CreateEntry(ctx, entry, false, false, nil, false, f.MaxFilenameLength); err != nil {
+			t.Fatalf("create %s: %v", entry.FullPath, err)
+		}
+	}
+
+	return &FilerServer{
+		filer:      f,
+		option:     &FilerOption{},
+		filerGuard: security.NewGuard(nil, signingKey, 0, signingKey, 0),
+	}
+}
+
+func TestFilerHttpAcl(t *testing.T) {
+	signingKey := "secret"
+	fs := newAclTestFilerServer(t, signingKey)
+
+	request := func(method, path string, jwt security.EncodedJwt) int {
+		r := httptest.NewRequest(method, path, nil)
+		if jwt != "" {
+			r.Header.Set("Authorization", "Bearer "+string(jwt))
+		}
+		w := httptest.NewRecorder()
+		fs.filerHandler(w, r)
+		return w.Code
+	}
+	owner := security.GenJwtForFilerServerAs(security.SigningKey(signingKey), 60, aclTestOwner, []uint32{aclTestGroup})
+	other := security.GenJwtForFilerServerAs(security.SigningKey(signingKey), 60, aclTestOther, []uint32{aclTestGroup})
+	service := security.GenJwtForFilerServer(security.SigningKey(signingKey), 60)
+
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/dir/file", owner))
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/dir/file", other))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/dir/file", service), "a jwt without an identity is not checked")
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/dir/file", ""))
+
+	assert.Equal(t, http.StatusForbidden, request(http.MethodDelete, "/dir/file", other))
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/dir/new", other))
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPut, "/dir/file", other))
+	assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/dir/file", owner))
+}
+
+func TestFilerHttpAclWithoutSigningKey(t *testing.T) {
+	fs := newAclTestFilerServer(t, "")
+
+	r := httptest.NewRequest(http.MethodGet, "/dir/file", nil)
+	w := httptest.NewRecorder()
+	fs.filerHandler(w, r)
+	assert.Equal(t, http.StatusOK, w.Code, "the acls are not checked without an identity source")
+	assert.Equal(t, "data", w.Body.String())
+}
+
+func TestWebDavAcl(t *testing.T) {
+	fs := newAclTestFilerServer(t, "")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	filer_pb.RegisterSeaweedFilerServer(grpcServer, fs)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	newWebDav := func(uid uint32) *WebDavFileSystem {
+		return &WebDavFileSystem{
+			option: &WebDavOption{
+				Filer:          pb.NewServerAddressWithGrpcPort("127.0.0.1:1", listener.Addr().(*net.TCPAddr).Port),
+				GrpcDialOption: grpc.WithTransportCredentials(insecure.NewCredentials()),
+				Uid:            uid,
+				Gid:            aclTestGroup,
+			},
+		}
+	}
+	owner, other := newWebDav(aclTestOwner), newWebDav(aclTestOther)
+	ctx := context.Background()
+
+	_, err = owner.OpenFile(ctx, "/dir/file", os.O_RDONLY, 0)
+	assert.Nil(t, err)
+	_, err = other.OpenFile(ctx, "/dir/file", os.O_RDONLY, 0)
+	assert.Equal(t, os.ErrPermission, err)
+
+	assert.Equal(t, os.ErrPermission, other.Mkdir(ctx, "/dir/sub", 0755))
+	assert.Equal(t, os.ErrPermission, other.RemoveAll(ctx, "/dir/file"))
+	assert.Nil(t, owner.Mkdir(ctx, "/dir/sub", 0755))
+}
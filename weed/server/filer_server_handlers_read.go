@@ -114,6 +114,11 @@ func (fs *FilerServer) GetOrHeadHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !fs.isAclReadAllowed(r, entry) {
+		writeJsonError(w, r, http.StatusForbidden, errors.New("permission denied"))
+		return
+	}
+
 	query := r.URL.Query()
 
 	if entry.IsDirectory() {
@@ -21,6 +21,8 @@ func (fs *FilerServer) PutTaggingHandler(w http.ResponseWriter, r *http.Request)
 		path = path[:len(path)-1]
 	}
 
+	unlock := fs.filer.LockEntries(util.FullPath(path))
+	defer unlock()
 	existingEntry, err := fs.filer.FindEntry(ctx, util.FullPath(path))
 	if err != nil {
 		writeJsonError(w, r, http.StatusNotFound, err)
@@ -64,6 +66,8 @@ func (fs *FilerServer) DeleteTaggingHandler(w http.ResponseWriter, r *http.Reque
 		path = path[:len(path)-1]
 	}
 
+	unlock := fs.filer.LockEntries(util.FullPath(path))
+	defer unlock()
 	existingEntry, err := fs.filer.FindEntry(ctx, util.FullPath(path))
 	if err != nil {
 		writeJsonError(w, r, http.StatusNotFound, err)
@@ -11,6 +11,7 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/s3api/s3_constants"
 
+	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
@@ -62,7 +63,9 @@ func (fs *FilerServer) assignNewFileInfo(so *operation.StorageOption) (fileId, u
 		}
 	}
 	urlLocation = "http://" + assignUrl + "/" + assignResult.Fid
-	if so.Fsync {
+	if so.GroupCommitMs > 0 {
+		urlLocation += fmt.Sprintf("?groupCommitMs=%d", so.GroupCommitMs)
+	} else if so.Fsync {
 		urlLocation += "?fsync=true"
 	}
 	auth = assignResult.Auth
@@ -260,6 +263,15 @@ func (fs *FilerServer) detectStorageOption(requestURI, qCollection, qReplication
 		ttlSeconds = int32(ttl.Minutes()) * 60
 	}
 
+	var compression *util.CompressionPolicy
+	if rule.Compression != "" {
+		if policy, err := util.ParseCompressionPolicy(rule.Compression); err != nil {
+			glog.Errorf("fail to parse %s compression setting %s: %v", rule.LocationPrefix, rule.Compression, err)
+		} else {
+			compression = &policy
+		}
+	}
+
 	return &operation.StorageOption{
 		Replication:       util.Nvl(qReplication, rule.Replication, fs.option.DefaultReplication),
 		Collection:        util.Nvl(qCollection, rule.Collection, bucketDefaultCollection, fs.option.Collection),
@@ -269,8 +281,13 @@ func (fs *FilerServer) detectStorageOption(requestURI, qCollection, qReplication
 		TtlSeconds:        ttlSeconds,
 		DiskType:          util.Nvl(diskType, rule.DiskType),
 		Fsync:             rule.Fsync,
+		GroupCommitMs:     rule.GroupCommitMs,
 		VolumeGrowthCount: rule.VolumeGrowthCount,
 		MaxFileNameLength: rule.MaxFileNameLength,
+		PackMaxFileSize:   filer.PackMaxFileSize(rule),
+		Dedup:             rule.Dedup,
+		Compression:       compression,
+		EcScheme:          rule.EcScheme,
 	}, nil
 }
 
@@ -285,6 +302,7 @@ func (fs *FilerServer) detectStorageOption0(requestURI, qCollection, qReplicatio
 	if so != nil {
 		if fsync == "false" {
 			so.Fsync = false
+			so.GroupCommitMs = 0
 		} else if fsync == "true" {
 			so.Fsync = true
 		}
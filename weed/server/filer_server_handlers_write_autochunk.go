@@ -38,6 +38,10 @@ func (fs *FilerServer) autoChunk(ctx context.Context, w http.ResponseWriter, r *
 	var reply *FilerPostResult
 	var err error
 	var md5bytes []byte
+	if err = fs.filer.CheckDirectoryQuota(ctx, util.FullPath(r.URL.Path)); err != nil {
+		writeJsonError(w, r, http.StatusInsufficientStorage, err)
+		return
+	}
 	if r.Method == http.MethodPost {
 		if r.Header.Get("Content-Type") == "" && strings.HasSuffix(r.URL.Path, "/") {
 			reply, err = fs.mkdir(ctx, w, r, so)
@@ -54,6 +58,8 @@ func (fs *FilerServer) autoChunk(ctx context.Context, w http.ResponseWriter, r *
 			writeJsonError(w, r, util.HttpStatusCancelled, err)
 		} else if strings.HasSuffix(err.Error(), "is a file") || strings.HasSuffix(err.Error(), "already exists") {
 			writeJsonError(w, r, http.StatusConflict, err)
+		} else if filer.IsQuotaExceededError(err) {
+			writeJsonError(w, r, http.StatusInsufficientStorage, err)
 		} else {
 			writeJsonError(w, r, http.StatusInternalServerError, err)
 		}
@@ -103,6 +109,8 @@ func (fs *FilerServer) doPostAutoChunk(ctx context.Context, w http.ResponseWrite
 	if err != nil {
 		return nil, nil, err
 	}
+	// the deduplicated chunks are referenced by the saved entry from now on, or released if it failed to be saved
+	defer fs.filer.Deduper.ReleasePending(ctx, fileChunks)
 
 	md5bytes = md5Hash.Sum(nil)
 	headerMd5 := r.Header.Get("Content-Md5")
@@ -135,6 +143,8 @@ func (fs *FilerServer) doPutAutoChunk(ctx context.Context, w http.ResponseWriter
 	if err != nil {
 		return nil, nil, err
 	}
+	// the deduplicated chunks are referenced by the saved entry from now on, or released if it failed to be saved
+	defer fs.filer.Deduper.ReleasePending(ctx, fileChunks)
 
 	md5bytes = md5Hash.Sum(nil)
 	headerMd5 := r.Header.Get("Content-Md5")
@@ -217,6 +227,8 @@ func (fs *FilerServer) saveMetaData(ctx context.Context, r *http.Request, fileNa
 
 	// fix the path
 	path := fs.fixFilePath(ctx, r, fileName)
+	unlock := fs.filer.LockEntries(util.FullPath(path))
+	defer unlock()
 
 	var entry *filer.Entry
 	var newChunks []*filer_pb.FileChunk
@@ -96,6 +96,8 @@ func (fs *FilerServer) encrypt(ctx context.Context, w http.ResponseWriter, r *ht
 		Size: int64(pu.OriginalDataSize),
 	}
 
+	unlock := fs.filer.LockEntries(entry.FullPath)
+	defer unlock()
 	if dbErr := fs.filer.CreateEntry(ctx, entry, false, false, nil, false, so.MaxFileNameLength); dbErr != nil {
 		fs.filer.DeleteUncommittedChunks(entry.GetChunks())
 		err = dbErr
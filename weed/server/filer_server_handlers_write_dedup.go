@@ -0,0 +1,164 @@
+package weed_server
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"hash"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/util/fastcdc"
+)
+
+// shouldDedup is true under a path configured with dedup. Chunks of expiring files are not shared.
+func (fs *FilerServer) shouldDedup(so *operation.StorageOption) bool {
+	return so.Dedup && so.TtlSeconds == 0
+}
+
+// uploadReaderToDedupChunks splits the content into content-defined chunks, and only uploads the chunks not stored yet
+func (fs *FilerServer) uploadReaderToDedupChunks(reader io.Reader, startOffset int64, fileName, contentType string, isAppend bool, so *operation.StorageOption) (fileChunks []*filer_pb.FileChunk, md5Hash hash.Hash, chunkOffset int64, uploadErr error, smallContent []byte) {
+
+	md5Hash = md5.New()
+	chunkOffset = startOffset
+	chunker, err := fastcdc.NewChunker(io.TeeReader(reader, md5Hash), filer.DedupMinChunkSize, filer.DedupAvgChunkSize, filer.DedupMaxChunkSize)
+	if err != nil {
+		return nil, md5Hash, 0, err, nil
+	}
+
+	var wg sync.WaitGroup
+	var bytesBufferCounter int64 = 4
+	bytesBufferLimitChan := make(chan struct{}, bytesBufferCounter)
+	var fileChunksLock sync.Mutex
+	var uploadErrLock sync.Mutex
+	for {
+		data, err := chunker.Next()
+		if err != nil {
+			if err != io.EOF {
+				uploadErrLock.Lock()
+				if uploadErr == nil {
+					uploadErr = err
+				}
+				uploadErrLock.Unlock()
+			}
+			break
+		}
+		dataSize := int64(len(data))
+
+		// a chunk smaller than the minimum chunk size is the last one
+		if chunkOffset == 0 && !isAppend && dataSize < filer.DedupMinChunkSize {
+			if dataSize < fs.option.SaveToFilerLimit {
+				chunkOffset += dataSize
+				smallContent = append([]byte(nil), data...)
+				stats.FilerHandlerCounter.WithLabelValues(stats.ContentSaveToFiler).Inc()
+				break
+			}
+			if fs.shouldPack(dataSize, filer.DedupMaxChunkSize, so) {
+				chunk, packErr := fs.filer.Packer.Pack(so, data)
+				if packErr != nil {
+					uploadErrLock.Lock()
+					uploadErr = packErr
+					uploadErrLock.Unlock()
+					break
+				}
+				fileChunks = append(fileChunks, chunk)
+				chunkOffset += dataSize
+				stats.FilerHandlerCounter.WithLabelValues(stats.ContentPacked).Inc()
+				break
+			}
+		}
+
+		// need to throttle used byte buffer
+		bytesBufferLimitChan <- struct{}{}
+
+		bytesBuffer := bufPool.Get().(*bytes.Buffer)
+		bytesBuffer.Reset()
+		bytesBuffer.Write(data)
+
+		wg.Add(1)
+		go func(offset int64) {
+			defer func() {
+				bufPool.Put(bytesBuffer)
+				<-bytesBufferLimitChan
+				wg.Done()
+			}()
+
+			chunks, toChunkErr := fs.dedupDataToChunk(fileName, contentType, bytesBuffer.Bytes(), offset, so)
+			if toChunkErr != nil {
+				uploadErrLock.Lock()
+				if uploadErr == nil {
+					uploadErr = toChunkErr
+				}
+				uploadErrLock.Unlock()
+			}
+			if chunks != nil {
+				fileChunksLock.Lock()
+				for _, chunk := range chunks {
+					fileChunks = append(fileChunks, chunk)
+					glog.V(4).Infof("uploaded %s dedup chunk %s [%d,%d)", fileName, chunk.GetFileIdString(), offset, offset+int64(chunk.Size))
+				}
+				fileChunksLock.Unlock()
+			}
+		}(chunkOffset)
+
+		chunkOffset = chunkOffset + dataSize
+	}
+
+	wg.Wait()
+
+	if uploadErr != nil {
+		fs.filer.DeleteUncommittedChunks(fileChunks)
+		fs.filer.Deduper.ReleasePending(context.Background(), fileChunks)
+		return nil, md5Hash, 0, uploadErr, nil
+	}
+	slices.SortFunc(fileChunks, func(a, b *filer_pb.FileChunk) int {
+		return int(a.Offset - b.Offset)
+	})
+	return fileChunks, md5Hash, chunkOffset, nil, smallContent
+}
+
+// dedupDataToChunk reuses the stored chunk with the same content, or uploads the data as a new chunk
+func (fs *FilerServer) dedupDataToChunk(fileName, contentType string, data []byte, chunkOffset int64, so *operation.StorageOption) ([]*filer_pb.FileChunk, error) {
+	ctx := context.Background()
+	fingerprint := filer.DedupFingerprint(data)
+
+	existing, err := fs.filer.Deduper.Lookup(ctx, fingerprint, so.Collection)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		existing.Offset, existing.ModifiedTsNs = chunkOffset, time.Now().UnixNano()
+		stats.FilerHandlerCounter.WithLabelValues(stats.ContentDeduplicated).Inc()
+		return []*filer_pb.FileChunk{existing}, nil
+	}
+
+	stats.FilerHandlerCounter.WithLabelValues(stats.AutoChunk).Inc()
+	chunks, err := fs.dataToChunk(fileName, contentType, data, chunkOffset, so)
+	if err != nil || len(chunks) == 0 {
+		return chunks, err
+	}
+	chunk := chunks[0]
+	chunk.Fingerprint = fingerprint
+	registered, isNew, err := fs.filer.Deduper.Register(ctx, chunk, so.Collection)
+	if err != nil || registered == nil {
+		// not shared, e.g., the same content is stored in another collection
+		chunk.Fingerprint = nil
+		return chunks, err
+	}
+	if !isNew {
+		// the same content was uploaded concurrently
+		chunk.Fingerprint = nil
+		fs.filer.DeleteUncommittedChunks(chunks)
+		registered.Offset, registered.ModifiedTsNs = chunkOffset, chunk.ModifiedTsNs
+		stats.FilerHandlerCounter.WithLabelValues(stats.ContentDeduplicated).Inc()
+	}
+	return []*filer_pb.FileChunk{registered}, nil
+}
@@ -13,6 +13,10 @@ import (
 const MergeChunkMinCount int = 1000
 
 func (fs *FilerServer) maybeMergeChunks(so *operation.StorageOption, inputChunks []*filer_pb.FileChunk) (mergedChunks []*filer_pb.FileChunk, err error) {
+	// the content-defined chunks are shared, and already sized by the content
+	if fs.shouldDedup(so) {
+		return inputChunks, nil
+	}
 	// Only merge small chunks more than half of the file
 	var chunkSize = fs.option.MaxMB * 1024 * 1024
 	var smallChunk, sumChunk int
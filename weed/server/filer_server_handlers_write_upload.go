@@ -18,6 +18,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
 	"github.com/seaweedfs/seaweedfs/weed/security"
 	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
@@ -50,6 +51,10 @@ func (fs *FilerServer) uploadRequestToChunks(w http.ResponseWriter, r *http.Requ
 
 func (fs *FilerServer) uploadReaderToChunks(reader io.Reader, startOffset int64, chunkSize int32, fileName, contentType string, isAppend bool, so *operation.StorageOption) (fileChunks []*filer_pb.FileChunk, md5Hash hash.Hash, chunkOffset int64, uploadErr error, smallContent []byte) {
 
+	if fs.shouldDedup(so) {
+		return fs.uploadReaderToDedupChunks(reader, startOffset, fileName, contentType, isAppend, so)
+	}
+
 	md5Hash = md5.New()
 	chunkOffset = startOffset
 	var partReader = io.NopCloser(io.TeeReader(reader, md5Hash))
@@ -95,6 +100,21 @@ func (fs *FilerServer) uploadReaderToChunks(reader io.Reader, startOffset int64,
 				stats.FilerHandlerCounter.WithLabelValues(stats.ContentSaveToFiler).Inc()
 				break
 			}
+			if fs.shouldPack(dataSize, chunkSize, so) {
+				chunk, packErr := fs.filer.Packer.Pack(so, bytesBuffer.Bytes())
+				bufPool.Put(bytesBuffer)
+				<-bytesBufferLimitChan
+				if packErr != nil {
+					uploadErrLock.Lock()
+					uploadErr = packErr
+					uploadErrLock.Unlock()
+					break
+				}
+				fileChunks = append(fileChunks, chunk)
+				chunkOffset += dataSize
+				stats.FilerHandlerCounter.WithLabelValues(stats.ContentPacked).Inc()
+				break
+			}
 		} else {
 			stats.FilerHandlerCounter.WithLabelValues(stats.AutoChunk).Inc()
 		}
@@ -147,7 +167,16 @@ func (fs *FilerServer) uploadReaderToChunks(reader io.Reader, startOffset int64,
 	return fileChunks, md5Hash, chunkOffset, nil, smallContent
 }
 
-func (fs *FilerServer) doUpload(urlLocation string, limitedReader io.Reader, fileName string, contentType string, pairMap map[string]string, auth security.EncodedJwt) (*operation.UploadResult, error, []byte) {
+// shouldPack is true for a whole small file under a path configured to pack small files
+func (fs *FilerServer) shouldPack(dataSize int64, chunkSize int32, so *operation.StorageOption) bool {
+	if so.PackMaxFileSize <= 0 || dataSize > so.PackMaxFileSize || dataSize >= int64(chunkSize) {
+		return false
+	}
+	// packed files are read by ranges of the shared container, and expire individually
+	return !fs.option.Cipher && so.TtlSeconds == 0
+}
+
+func (fs *FilerServer) doUpload(urlLocation string, limitedReader io.Reader, fileName string, contentType string, pairMap map[string]string, auth security.EncodedJwt, compression *util.CompressionPolicy) (*operation.UploadResult, error, []byte) {
 
 	stats.FilerHandlerCounter.WithLabelValues(stats.ChunkUpload).Inc()
 	start := time.Now()
@@ -160,6 +189,7 @@ func (fs *FilerServer) doUpload(urlLocation string, limitedReader io.Reader, fil
 		Filename:          fileName,
 		Cipher:            fs.option.Cipher,
 		IsInputCompressed: false,
+		Compression:       compression,
 		MimeType:          contentType,
 		PairMap:           pairMap,
 		Jwt:               auth,
@@ -187,7 +217,19 @@ func (fs *FilerServer) dataToChunk(fileName, contentType string, data []byte, ch
 	var uploadResult *operation.UploadResult
 	var failedFileChunks []*filer_pb.FileChunk
 
+	ecScheme, useEc := fs.ecSchemeForChunk(so, len(data))
+
 	err := util.Retry("filerDataToChunk", func() error {
+		if useEc {
+			fileId, uploadResult, uploadErr = fs.doUploadEc(fileName, contentType, data, so, ecScheme)
+			if uploadErr == nil {
+				return nil
+			}
+			// the needle is only committed after all the shard servers wrote it, so it is replicated instead
+			glog.V(0).Infof("replicate the chunk after ec upload error: %v", uploadErr)
+			stats.FilerHandlerCounter.WithLabelValues(stats.ChunkDoUploadRetry).Inc()
+			useEc = false
+		}
 		// assign one file id for one chunk
 		fileId, urlLocation, auth, uploadErr = fs.assignNewFileInfo(so)
 		if uploadErr != nil {
@@ -196,7 +238,7 @@ func (fs *FilerServer) dataToChunk(fileName, contentType string, data []byte, ch
 			return uploadErr
 		}
 		// upload the chunk to the volume server
-		uploadResult, uploadErr, _ = fs.doUpload(urlLocation, dataReader, fileName, contentType, nil, auth)
+		uploadResult, uploadErr, _ = fs.doUpload(urlLocation, dataReader, fileName, contentType, nil, auth, so.Compression)
 		if uploadErr != nil {
 			glog.V(4).Infof("retry later due to upload error: %v", uploadErr)
 			stats.FilerHandlerCounter.WithLabelValues(stats.ChunkDoUploadRetry).Inc()
@@ -222,3 +264,36 @@ func (fs *FilerServer) dataToChunk(fileName, contentType string, data []byte, ch
 	}
 	return []*filer_pb.FileChunk{uploadResult.ToPbFileChunk(fileId, chunkOffset, time.Now().UnixNano())}, nil
 }
+
+// ecSchemeForChunk decides whether the chunk is erasure coded directly.
+// The chunks smaller than a row of the ec volume are replicated, since the parity of a row is about the same size.
+func (fs *FilerServer) ecSchemeForChunk(so *operation.StorageOption, size int) (scheme erasure_coding.EcScheme, useEc bool) {
+	if so.EcScheme == "" {
+		return
+	}
+	scheme, err := erasure_coding.ParseEcScheme(so.EcScheme)
+	if err != nil {
+		glog.Warningf("invalid ec scheme %s: %v", so.EcScheme, err)
+		return
+	}
+	return scheme, int64(size) >= scheme.RowSize()
+}
+
+func (fs *FilerServer) doUploadEc(fileName, contentType string, data []byte, so *operation.StorageOption, scheme erasure_coding.EcScheme) (fileId string, uploadResult *operation.UploadResult, err error) {
+
+	stats.FilerHandlerCounter.WithLabelValues(stats.ChunkUpload).Inc()
+	start := time.Now()
+	defer func() {
+		stats.FilerRequestHistogram.WithLabelValues(stats.ChunkUpload).Observe(time.Since(start).Seconds())
+	}()
+
+	return operation.UploadEc(fs.filer.GetMaster, fs.grpcDialOption, &operation.EcUploadOption{
+		Collection: so.Collection,
+		DataCenter: so.DataCenter,
+		DiskType:   so.DiskType,
+		Scheme:     scheme,
+		Filename:   fileName,
+		MimeType:   contentType,
+		Cipher:     fs.option.Cipher,
+	}, data)
+}
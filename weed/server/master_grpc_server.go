@@ -243,6 +243,26 @@ func (ms *MasterServer) SendHeartbeat(stream master_pb.Seaweed_SendHeartbeatServ
 			}
 
 		}
+
+		if len(heartbeat.CorruptNeedles) > 0 {
+			stats.MasterReceivedHeartbeatCounter.WithLabelValues("corruptNeedles").Inc()
+			for _, c := range heartbeat.CorruptNeedles {
+				state := "unrepaired"
+				if c.Repaired {
+					state = "repaired"
+				}
+				stats.MasterReceivedCorruptNeedleCounter.WithLabelValues(c.Collection, state).Inc()
+				switch {
+				case c.IsEc && c.NeedleId == 0:
+					glog.Warningf("%s ec volume %d shard %d at offset %d on %s %s: %s", state, c.Id, c.ShardId, c.Offset, dn.Url(), c.Disk, c.Error)
+				case c.IsEc:
+					glog.Warningf("%s ec volume %d needle %x at offset %d on %s %s: %s", state, c.Id, c.NeedleId, c.Offset, dn.Url(), c.Disk, c.Error)
+				default:
+					glog.Warningf("%s volume %d needle %x at offset %d on %s %s: %s", state, c.Id, c.NeedleId, c.Offset, dn.Url(), c.Disk, c.Error)
+				}
+			}
+		}
+
 		if len(message.NewVids) > 0 || len(message.DeletedVids) > 0 || len(message.NewEcVids) > 0 || len(message.DeletedEcVids) > 0 {
 			ms.broadcastToClients(&master_pb.KeepConnectedResponse{VolumeLocation: message})
 		}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
 	"github.com/seaweedfs/seaweedfs/weed/security"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
@@ -134,3 +135,53 @@ func (ms *MasterServer) Assign(ctx context.Context, req *master_pb.AssignRequest
 	}
 	return nil, lastErr
 }
+
+// AssignEc assigns a file id to write directly to an ec volume, with the rows reserved for the needle
+func (ms *MasterServer) AssignEc(ctx context.Context, req *master_pb.AssignEcRequest) (*master_pb.AssignEcResponse, error) {
+
+	if !ms.Topo.IsLeader() {
+		return nil, raft.NotLeaderError
+	}
+
+	scheme := erasure_coding.NewEcScheme(req.DataShards, req.ParityShards)
+	if err := scheme.Validate(); err != nil {
+		return nil, err
+	}
+	option := &topology.VolumeGrowOption{
+		Collection: req.Collection,
+		DiskType:   types.ToDiskType(req.DiskType),
+		DataCenter: req.DataCenter,
+	}
+	if !ms.Topo.DataCenterExists(option.DataCenter) {
+		return nil, fmt.Errorf("data center %v not found in topology", option.DataCenter)
+	}
+
+	fid, offset, shardLocations, err := ms.Topo.PickForEcWrite(ms.grpcDialOption, option, scheme, int64(req.Size))
+	if err != nil {
+		glog.V(0).Infof("assign ec %v: %v", req, err)
+		return &master_pb.AssignEcResponse{Error: err.Error()}, nil
+	}
+
+	resp := &master_pb.AssignEcResponse{
+		Fid:          fid,
+		Offset:       offset,
+		DataShards:   uint32(scheme.DataShards),
+		ParityShards: uint32(scheme.ParityShards),
+	}
+	for shardId, dataNodes := range shardLocations {
+		var locations []*master_pb.Location
+		for _, dn := range dataNodes {
+			locations = append(locations, &master_pb.Location{
+				Url:        dn.Url(),
+				PublicUrl:  dn.PublicUrl,
+				GrpcPort:   uint32(dn.GrpcPort),
+				DataCenter: dn.GetDataCenterId(),
+			})
+		}
+		resp.ShardIdLocations = append(resp.ShardIdLocations, &master_pb.LookupEcVolumeResponse_EcShardIdLocation{
+			ShardId:   uint32(shardId),
+			Locations: locations,
+		})
+	}
+	return resp, nil
+}
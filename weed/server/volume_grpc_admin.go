@@ -45,7 +45,7 @@ func (vs *VolumeServer) AllocateVolume(ctx context.Context, req *volume_server_p
 	err := vs.store.AddVolume(
 		needle.VolumeId(req.VolumeId),
 		req.Collection,
-		vs.needleMapKind,
+		vs.store.NeedleMapKind,
 		req.Replication,
 		req.Ttl,
 		req.Preallocate,
@@ -54,6 +54,17 @@ func (vs *VolumeServer) AllocateVolume(ctx context.Context, req *volume_server_p
 		vs.ldbTimout,
 	)
 
+	if err == nil && storage.IsEncryptionEnabled() {
+		// the replicas share the data keys, so the needles copied between them can be decrypted
+		resp.DataKeys, err = vs.store.RotateVolumeDataKeys(needle.VolumeId(req.VolumeId), false, req.DataKeys)
+		if err != nil {
+			// a volume without wrapped data keys can not be written, and is not known to the master
+			if deleteErr := vs.store.DeleteVolume(needle.VolumeId(req.VolumeId), false); deleteErr != nil {
+				glog.Errorf("delete volume %d without data keys: %v", req.VolumeId, deleteErr)
+			}
+		}
+	}
+
 	if err != nil {
 		glog.Errorf("assign volume %v: %v", req, err)
 	} else {
@@ -116,6 +127,29 @@ func (vs *VolumeServer) VolumeConfigure(ctx context.Context, req *volume_server_
 
 	resp := &volume_server_pb.VolumeConfigureResponse{}
 
+	if req.SetGroupCommit {
+		delay := time.Duration(req.GroupCommitMs) * time.Millisecond
+		if err := vs.store.ConfigureVolumeGroupCommit(needle.VolumeId(req.VolumeId), delay); err != nil {
+			glog.Errorf("volume configure group commit %v: %v", req, err)
+			resp.Error = fmt.Sprintf("volume configure group commit %v: %v", req, err)
+			return resp, nil
+		}
+		if req.Replication == "" && req.NeedleVersion == 0 {
+			return resp, nil
+		}
+	}
+
+	if req.NeedleVersion != 0 {
+		if err := vs.store.UpgradeVolume(needle.VolumeId(req.VolumeId), needle.Version(req.NeedleVersion)); err != nil {
+			glog.Errorf("volume configure needle version %v: %v", req, err)
+			resp.Error = fmt.Sprintf("volume configure needle version %v: %v", req, err)
+			return resp, nil
+		}
+		if req.Replication == "" {
+			return resp, nil
+		}
+	}
+
 	// check replication format
 	if _, err := super_block.NewReplicaPlacementFromString(req.Replication); err != nil {
 		resp.Error = fmt.Sprintf("volume configure replication %v: %v", req, err)
@@ -249,6 +283,42 @@ func (vs *VolumeServer) VolumeStatus(ctx context.Context, req *volume_server_pb.
 	return resp, nil
 }
 
+func (vs *VolumeServer) VolumeNeedleMapSwitch(ctx context.Context, req *volume_server_pb.VolumeNeedleMapSwitchRequest) (*volume_server_pb.VolumeNeedleMapSwitchResponse, error) {
+
+	resp := &volume_server_pb.VolumeNeedleMapSwitchResponse{}
+
+	kind, err := storage.ParseNeedleMapKind(req.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	switched, err := vs.store.SwitchNeedleMapKind(needle.VolumeId(req.VolumeId), kind)
+	resp.SwitchedVolumeCount = uint32(switched)
+
+	if err != nil {
+		glog.Errorf("volume needle map switch %v: %v", req, err)
+		return resp, err
+	}
+	glog.V(0).Infof("volume needle map switch %v: %d volumes", req, switched)
+
+	return resp, nil
+}
+
+func (vs *VolumeServer) VolumeRotateKey(ctx context.Context, req *volume_server_pb.VolumeRotateKeyRequest) (*volume_server_pb.VolumeRotateKeyResponse, error) {
+
+	resp := &volume_server_pb.VolumeRotateKeyResponse{}
+
+	dataKeys, err := vs.store.RotateVolumeDataKeys(needle.VolumeId(req.VolumeId), req.RotateDataKey, req.DataKeys)
+	if err != nil {
+		glog.Errorf("volume rotate key %v: %v", req.VolumeId, err)
+		return resp, err
+	}
+	resp.DataKeys = dataKeys
+	glog.V(0).Infof("volume %d rotated key, %d data keys", req.VolumeId, len(dataKeys))
+
+	return resp, nil
+}
+
 func (vs *VolumeServer) VolumeServerStatus(ctx context.Context, req *volume_server_pb.VolumeServerStatusRequest) (*volume_server_pb.VolumeServerStatusResponse, error) {
 
 	resp := &volume_server_pb.VolumeServerStatusResponse{
@@ -238,6 +238,23 @@ func (vs *VolumeServer) doHeartbeat(masterAddress pb.ServerAddress, grpcDialOpti
 				glog.V(0).Infof("Volume Server Failed to update to master %s: %v", masterAddress, err)
 				return "", err
 			}
+		case corruptNeedle := <-vs.store.CorruptNeedlesChan:
+			deltaBeat := &master_pb.Heartbeat{
+				Ip:             ip,
+				Port:           port,
+				DataCenter:     dataCenter,
+				Rack:           rack,
+				CorruptNeedles: []*master_pb.CorruptNeedleMessage{corruptNeedle},
+			}
+			// send the pending reports together
+			for len(deltaBeat.CorruptNeedles) < 1024 && len(vs.store.CorruptNeedlesChan) > 0 {
+				deltaBeat.CorruptNeedles = append(deltaBeat.CorruptNeedles, <-vs.store.CorruptNeedlesChan)
+			}
+			glog.V(0).Infof("volume server %s:%d reports %d corrupt needles", vs.store.Ip, vs.store.Port, len(deltaBeat.CorruptNeedles))
+			if err = stream.Send(deltaBeat); err != nil {
+				glog.V(0).Infof("Volume Server Failed to update to master %s: %v", masterAddress, err)
+				return "", err
+			}
 		case <-volumeTickChan.C:
 			glog.V(4).Infof("volume server %s:%d heartbeat", vs.store.Ip, vs.store.Port)
 			vs.store.MaybeAdjustVolumeMax()
@@ -78,7 +78,7 @@ func (vs *VolumeServer) VolumeCopy(req *volume_server_pb.VolumeCopyRequest, stre
 
 		defer func() {
 			if err != nil {
-				os.Remove(dataBaseFileName + ".dat")
+				backend.RemoveVolumeFile(dataBaseFileName + ".dat")
 				os.Remove(indexBaseFileName + ".idx")
 				os.Remove(dataBaseFileName + ".vif")
 				os.Remove(dataBaseFileName + ".note")
@@ -174,7 +174,7 @@ func (vs *VolumeServer) VolumeCopy(req *volume_server_pb.VolumeCopyRequest, stre
 	defer func() {
 		if err != nil && dataBaseFileName != "" {
 			os.Remove(idxFileName)
-			os.Remove(datFileName)
+			backend.RemoveVolumeFile(datFileName)
 			os.Remove(dataBaseFileName + ".vif")
 		}
 	}()
@@ -245,28 +245,42 @@ func checkCopyFiles(originFileInf *volume_server_pb.ReadVolumeFileStatusResponse
 		return nil
 	}
 
-	stat, err = os.Stat(datFileName)
+	// the .dat file may be on a block device
+	datFile, err := backend.OpenVolumeFile(datFileName, os.O_RDONLY)
 	if err != nil {
 		return fmt.Errorf("get dat file info failed, %v", err)
 	}
-	if originFileInf.DatFileSize != uint64(stat.Size()) {
+	defer datFile.Close()
+	datFileSize, _, err := datFile.GetStat()
+	if err != nil {
+		return fmt.Errorf("get dat file info failed, %v", err)
+	}
+	if originFileInf.DatFileSize != uint64(datFileSize) {
 		return fmt.Errorf("the dat file size [%v] is not same as origin file size [%v]",
-			stat.Size(), originFileInf.DatFileSize)
+			datFileSize, originFileInf.DatFileSize)
 	}
 	return nil
 }
 
 func writeToFile(client volume_server_pb.VolumeServer_CopyFileClient, fileName string, wt *util.WriteThrottler, isAppend bool, progressFn storage.ProgressFunc) (modifiedTsNs int64, err error) {
 	glog.V(4).Infof("writing to %s", fileName)
-	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	// the .dat file may be on a block device
+	var dst backend.BackendStorageFile
 	if isAppend {
-		flags = os.O_WRONLY | os.O_CREATE
+		if dst, err = backend.OpenVolumeFile(fileName, os.O_RDWR); os.IsNotExist(err) {
+			dst, err = backend.CreateVolumeFile(fileName, 0, 0)
+		}
+	} else {
+		dst, err = backend.CreateVolumeFile(fileName, 0, 0)
 	}
-	dst, err := os.OpenFile(fileName, flags, 0644)
 	if err != nil {
 		return modifiedTsNs, nil
 	}
 	defer dst.Close()
+	offset, _, err := dst.GetStat()
+	if err != nil {
+		return modifiedTsNs, fmt.Errorf("stat %s: %v", fileName, err)
+	}
 
 	var progressedBytes int64
 	for {
@@ -280,7 +294,10 @@ func writeToFile(client volume_server_pb.VolumeServer_CopyFileClient, fileName s
 		if receiveErr != nil {
 			return modifiedTsNs, fmt.Errorf("receiving %s: %v", fileName, receiveErr)
 		}
-		dst.Write(resp.FileContent)
+		if _, err = dst.WriteAt(resp.FileContent, offset); err != nil {
+			return modifiedTsNs, fmt.Errorf("write %s: %v", fileName, err)
+		}
+		offset += int64(len(resp.FileContent))
 		progressedBytes += int64(len(resp.FileContent))
 		if progressFn != nil {
 			if !progressFn(progressedBytes) {
@@ -352,20 +369,28 @@ func (vs *VolumeServer) CopyFile(req *volume_server_pb.CopyFileRequest, stream v
 
 	bytesToRead := int64(req.StopOffset)
 
-	file, err := os.Open(fileName)
-	if err != nil {
+	var file io.Reader
+	var fileModTsNs int64
+	if osFile, err := os.Open(fileName); err == nil {
+		defer osFile.Close()
+		fileInfo, err := osFile.Stat()
+		if err != nil {
+			return err
+		}
+		file, fileModTsNs = osFile, fileInfo.ModTime().UnixNano()
+	} else if blockDeviceFile, blockDeviceErr := backend.OpenBlockDeviceFile(fileName); blockDeviceErr == nil {
+		defer blockDeviceFile.Close()
+		fileSize, modTime, err := blockDeviceFile.GetStat()
+		if err != nil {
+			return err
+		}
+		file, fileModTsNs = io.NewSectionReader(blockDeviceFile, 0, fileSize), modTime.UnixNano()
+	} else {
 		if req.IgnoreSourceFileNotFound && err == os.ErrNotExist {
 			return nil
 		}
 		return err
 	}
-	defer file.Close()
-
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return err
-	}
-	fileModTsNs := fileInfo.ModTime().UnixNano()
 
 	buffer := make([]byte, BufferSizeLimit)
 
@@ -379,7 +404,9 @@ func (vs *VolumeServer) CopyFile(req *volume_server_pb.CopyFileRequest, stream v
 				return err
 			}
 			// println(fileName, "read", bytesread, "bytes, with target", bytesToRead, "err", err.Error())
-			break
+			if bytesread == 0 {
+				break
+			}
 		}
 
 		if int64(bytesread) > bytesToRead {
@@ -0,0 +1,40 @@
+package weed_server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCopyFilesOnBlockDevice(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := backend.AttachBlockDevice(dir, filepath.Join(t.TempDir(), "device")+":256MiB", true); err != nil {
+		t.Fatalf("attach block device: %v", err)
+	}
+	defer backend.DetachBlockDevice(dir)
+
+	idxFileName, datFileName := filepath.Join(dir, "1.idx"), filepath.Join(dir, "1.dat")
+	if err := os.WriteFile(idxFileName, make([]byte, 16), 0644); err != nil {
+		t.Fatalf("write idx: %v", err)
+	}
+	datFile, err := backend.CreateVolumeFile(datFileName, 0, 0)
+	if err != nil {
+		t.Fatalf("create dat: %v", err)
+	}
+	if _, err = datFile.WriteAt(make([]byte, 4096), 0); err != nil {
+		t.Fatalf("write dat: %v", err)
+	}
+	datFile.Close()
+
+	assert.Nil(t, checkCopyFiles(&volume_server_pb.ReadVolumeFileStatusResponse{IdxFileSize: 16, DatFileSize: 4096}, false, idxFileName, datFileName))
+	assert.NotNil(t, checkCopyFiles(&volume_server_pb.ReadVolumeFileStatusResponse{IdxFileSize: 16, DatFileSize: 8192}, false, idxFileName, datFileName))
+
+	// the cleanup of a failed copy
+	assert.Nil(t, backend.RemoveVolumeFile(datFileName))
+	assert.NotNil(t, checkCopyFiles(&volume_server_pb.ReadVolumeFileStatusResponse{IdxFileSize: 16, DatFileSize: 4096}, false, idxFileName, datFileName))
+	assert.False(t, backend.FindBlockDevice(dir).HasFile("1.dat"))
+}
@@ -50,19 +50,24 @@ func (vs *VolumeServer) VolumeEcShardsGenerate(ctx context.Context, req *volume_
 		return nil, fmt.Errorf("existing collection:%v unexpected input: %v", v.Collection, req.Collection)
 	}
 
+	scheme := erasure_coding.NewEcScheme(req.DataShards, req.ParityShards)
+	if err := scheme.Validate(); err != nil {
+		return nil, err
+	}
+
 	shouldCleanup := true
 	defer func() {
 		if !shouldCleanup {
 			return
 		}
-		for i := 0; i < erasure_coding.TotalShardsCount; i++ {
-			os.Remove(fmt.Sprintf("%s.ec%2d", baseFileName, i))
+		for i := 0; i < scheme.TotalShards(); i++ {
+			os.Remove(baseFileName + erasure_coding.ToExt(i))
 		}
 		os.Remove(v.IndexFileName() + ".ecx")
 	}()
 
-	// write .ec00 ~ .ec13 files
-	if err := erasure_coding.WriteEcFiles(baseFileName); err != nil {
+	// write .ec00 ~ .ec13 files, or as many as the scheme has shards
+	if err := erasure_coding.WriteEcFiles(baseFileName, scheme); err != nil {
 		return nil, fmt.Errorf("WriteEcFiles %s: %v", baseFileName, err)
 	}
 
@@ -87,6 +92,11 @@ func (vs *VolumeServer) VolumeEcShardsGenerate(ctx context.Context, req *volume_
 	}
 	datSize, _, _ := v.FileStat()
 	volumeInfo.DatFileSize = int64(datSize)
+	volumeInfo.DataKeys = v.DataKeys()
+	if !scheme.IsDefault() {
+		volumeInfo.EcDataShards = uint32(scheme.DataShards)
+		volumeInfo.EcParityShards = uint32(scheme.ParityShards)
+	}
 	if err := volume_info.SaveVolumeInfo(baseFileName+".vif", volumeInfo); err != nil {
 		return nil, fmt.Errorf("SaveVolumeInfo %s: %v", baseFileName, err)
 	}
@@ -118,7 +128,11 @@ func (vs *VolumeServer) VolumeEcShardsRebuild(ctx context.Context, req *volume_s
 		if util.FileExists(path.Join(location.IdxDirectory, baseFileName+".ecx")) {
 			// write .ec00 ~ .ec13 files
 			dataBaseFileName := path.Join(location.Directory, baseFileName)
-			if generatedShardIds, err := erasure_coding.RebuildEcFiles(dataBaseFileName); err != nil {
+			scheme := erasure_coding.DefaultEcScheme
+			if volumeInfo, _, found, _ := volume_info.MaybeLoadVolumeInfo(dataBaseFileName + ".vif"); found {
+				scheme = erasure_coding.NewEcScheme(volumeInfo.EcDataShards, volumeInfo.EcParityShards)
+			}
+			if generatedShardIds, err := erasure_coding.RebuildEcFiles(dataBaseFileName, scheme); err != nil {
 				return nil, fmt.Errorf("RebuildEcFiles %s: %v", dataBaseFileName, err)
 			} else {
 				rebuiltShardIds = generatedShardIds
@@ -434,8 +448,8 @@ func (vs *VolumeServer) VolumeEcShardsToVolume(ctx context.Context, req *volume_
 
 	glog.V(0).Infof("VolumeEcShardsToVolume: %v", req)
 
-	// collect .ec00 ~ .ec09 files
-	shardFileNames := make([]string, erasure_coding.DataShardsCount)
+	// collect .ec00 ~ .ec09 files, or as many as the scheme has data shards
+	shardFileNames := make([]string, erasure_coding.MaxShardCount)
 	v, found := vs.store.CollectEcShards(needle.VolumeId(req.VolumeId), shardFileNames)
 	if !found {
 		return nil, fmt.Errorf("ec volume %d not found", req.VolumeId)
@@ -445,7 +459,8 @@ func (vs *VolumeServer) VolumeEcShardsToVolume(ctx context.Context, req *volume_
 		return nil, fmt.Errorf("existing collection:%v unexpected input: %v", v.Collection, req.Collection)
 	}
 
-	for shardId := 0; shardId < erasure_coding.DataShardsCount; shardId++ {
+	shardFileNames = shardFileNames[:v.Scheme.DataShards]
+	for shardId := 0; shardId < v.Scheme.DataShards; shardId++ {
 		if shardFileNames[shardId] == "" {
 			return nil, fmt.Errorf("ec volume %d missing shard %d", req.VolumeId, shardId)
 		}
@@ -470,3 +485,44 @@ func (vs *VolumeServer) VolumeEcShardsToVolume(ctx context.Context, req *volume_
 
 	return &volume_server_pb.VolumeEcShardsToVolumeResponse{}, nil
 }
+
+// VolumeEcShardsAllocate creates and mounts the empty shards of an ec volume, which the clients write directly
+func (vs *VolumeServer) VolumeEcShardsAllocate(ctx context.Context, req *volume_server_pb.VolumeEcShardsAllocateRequest) (*volume_server_pb.VolumeEcShardsAllocateResponse, error) {
+
+	glog.V(0).Infof("VolumeEcShardsAllocate: %v", req)
+
+	scheme := erasure_coding.NewEcScheme(req.DataShards, req.ParityShards)
+	if err := scheme.Validate(); err != nil {
+		return nil, err
+	}
+	var shardIds []erasure_coding.ShardId
+	for _, shardId := range req.ShardIds {
+		shardIds = append(shardIds, erasure_coding.ShardId(shardId))
+	}
+
+	if err := vs.store.AllocateEcShards(req.Collection, needle.VolumeId(req.VolumeId), scheme, shardIds, types.ToDiskType(req.DiskType)); err != nil {
+		return nil, fmt.Errorf("allocate ec shards %d.%v: %v", req.VolumeId, req.ShardIds, err)
+	}
+
+	return &volume_server_pb.VolumeEcShardsAllocateResponse{}, nil
+}
+
+// VolumeEcShardWrite writes the rows of a needle to one shard of an ec volume written directly
+func (vs *VolumeServer) VolumeEcShardWrite(ctx context.Context, req *volume_server_pb.VolumeEcShardWriteRequest) (*volume_server_pb.VolumeEcShardWriteResponse, error) {
+
+	if err := vs.store.WriteEcShardRows(needle.VolumeId(req.VolumeId), erasure_coding.ShardId(req.ShardId), req.Data, req.Offset, req.Size); err != nil {
+		return nil, fmt.Errorf("write ec shard %d.%d: %v", req.VolumeId, req.ShardId, err)
+	}
+
+	return &volume_server_pb.VolumeEcShardWriteResponse{}, nil
+}
+
+// VolumeEcBlobCommit adds the needle to the .ecx file, after its rows are written to all the shards
+func (vs *VolumeServer) VolumeEcBlobCommit(ctx context.Context, req *volume_server_pb.VolumeEcBlobCommitRequest) (*volume_server_pb.VolumeEcBlobCommitResponse, error) {
+
+	if err := vs.store.CommitEcNeedle(needle.VolumeId(req.VolumeId), types.NeedleId(req.FileKey), req.Offset, types.Size(req.Size)); err != nil {
+		return nil, fmt.Errorf("commit ec needle %d,%x: %v", req.VolumeId, req.FileKey, err)
+	}
+
+	return &volume_server_pb.VolumeEcBlobCommitResponse{}, nil
+}
@@ -47,7 +47,7 @@ func (vs *VolumeServer) FetchAndWriteNeedle(ctx context.Context, req *volume_ser
 		n.Checksum = needle.NewCRC(n.Data)
 		n.LastModified = uint64(time.Now().Unix())
 		n.SetHasLastModifiedDate()
-		if _, localWriteErr := vs.store.WriteVolumeNeedle(v.Id, n, true, false); localWriteErr != nil {
+		if _, localWriteErr := vs.store.WriteVolumeNeedle(v.Id, n, true, false, 0); localWriteErr != nil {
 			if err == nil {
 				err = fmt.Errorf("local write needle %d size %d: %v", req.NeedleId, req.Size, localWriteErr)
 			}
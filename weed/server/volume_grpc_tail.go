@@ -70,7 +70,8 @@ func sendNeedlesSince(stream volume_server_pb.VolumeServer_VolumeTailSenderServe
 	}
 
 	scanner := &VolumeFileScanner4Tailing{
-		stream: stream,
+		stream:  stream,
+		version: v.Version(),
 	}
 
 	err = storage.ScanVolumeFileFrom(v.Version(), v.DataBackend, foundOffset.ToActualOffset(), scanner)
@@ -91,7 +92,7 @@ func (vs *VolumeServer) VolumeTailReceiver(ctx context.Context, req *volume_serv
 	defer glog.V(1).Infof("receive tailing volume %d finished", v.Id)
 
 	return resp, operation.TailVolumeFromSource(pb.ServerAddress(req.SourceVolumeServer), vs.grpcDialOption, v.Id, req.SinceNs, int(req.IdleTimeoutSeconds), func(n *needle.Needle) error {
-		_, err := vs.store.WriteVolumeNeedle(v.Id, n, false, false)
+		_, err := vs.store.WriteVolumeNeedle(v.Id, n, false, false, 0)
 		return err
 	})
 
@@ -100,6 +101,7 @@ func (vs *VolumeServer) VolumeTailReceiver(ctx context.Context, req *volume_serv
 // generate the volume idx
 type VolumeFileScanner4Tailing struct {
 	stream                   volume_server_pb.VolumeServer_VolumeTailSenderServer
+	version                  needle.Version
 	lastProcessedTimestampNs uint64
 }
 
@@ -126,6 +128,7 @@ func (scanner *VolumeFileScanner4Tailing) VisitNeedle(n *needle.Needle, offset i
 			NeedleHeader: needleHeader,
 			NeedleBody:   needleBody[i:stopOffset],
 			IsLastChunk:  isLastChunk,
+			Version:      uint32(scanner.version),
 		})
 		if sendErr != nil {
 			return sendErr
@@ -2,7 +2,6 @@ package weed_server
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
@@ -88,7 +87,7 @@ func (vs *VolumeServer) VolumeTierMoveDatToRemote(req *volume_server_pb.VolumeTi
 	}
 
 	if !req.KeepLocalDatFile {
-		os.Remove(v.FileName(".dat"))
+		backend.RemoveVolumeFile(v.FileName(".dat"))
 	}
 
 	return nil
@@ -27,7 +27,11 @@ func (vs *VolumeServer) VacuumVolumeCheck(ctx context.Context, req *volume_serve
 
 	if err != nil {
 		glog.V(3).Infof("check volume %d: %v", req.VolumeId, err)
+		return resp, err
 	}
+	resp.NeedsUpgrade = vs.store.CheckUpgradeVolume(needle.VolumeId(req.VolumeId))
+
+	resp.CompactSize, resp.FreeSize, err = vs.store.CheckCompactVolumeSpace(needle.VolumeId(req.VolumeId))
 
 	return resp, err
 
@@ -44,7 +48,7 @@ func (vs *VolumeServer) VacuumVolumeCompact(req *volume_server_pb.VacuumVolumeCo
 	nextReportTarget := reportInterval
 	fs, fsErr := procfs.NewDefaultFS()
 	var sendErr error
-	err := vs.store.CompactVolume(needle.VolumeId(req.VolumeId), req.Preallocate, vs.compactionBytePerSecond, func(processed int64) bool {
+	err := vs.store.CompactVolume(needle.VolumeId(req.VolumeId), req.Preallocate, req.InPlace, vs.compactionBytePerSecond, func(processed int64) bool {
 		if processed > nextReportTarget {
 			resp.ProcessedBytes = processed
 			if fsErr == nil && numCPU > 0 {
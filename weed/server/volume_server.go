@@ -70,6 +70,9 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 	hasSlowRead bool,
 	readBufferSizeMB int,
 	ldbTimeout int64,
+	scrubMBPerSecond int,
+	scrubInterval time.Duration,
+	scrubRepair bool,
 ) *VolumeServer {
 
 	v := util.GetViper()
@@ -107,6 +110,15 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 
 	vs.checkWithMaster()
 
+	// the master key unwraps the volume data keys when loading the volumes
+	masterKeyProvider, err := security.LoadMasterKeyProvider(v, "volume.encryption")
+	if err != nil {
+		glog.Fatalf("load volume encryption master key: %v", err)
+	}
+	if masterKeyProvider != nil {
+		storage.SetMasterKeyProvider(masterKeyProvider)
+	}
+
 	vs.store = storage.NewStore(vs.grpcDialOption, ip, port, grpcPort, publicUrl, folders, maxCounts, minFreeSpaces, idxFolder, vs.needleMapKind, diskTypes, ldbTimeout)
 	vs.guard = security.NewGuard(whiteList, signingKey, expiresAfterSec, readSigningKey, readExpiresAfterSec)
 
@@ -129,6 +141,10 @@ func NewVolumeServer(adminMux, publicMux *http.ServeMux, ip string,
 		publicMux.HandleFunc("/", vs.publicReadOnlyHandler)
 	}
 
+	if scrubMBPerSecond > 0 {
+		vs.store.StartScrubbing(int64(scrubMBPerSecond)*1024*1024, scrubInterval, scrubRepair)
+	}
+
 	go vs.heartbeat()
 	go stats.LoopPushingMetric("volumeServer", util.JoinHostPort(ip, port), vs.metricsAddress, vs.metricsIntervalSec)
 
@@ -0,0 +1,94 @@
+package weed_server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/security"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	util_http "github.com/seaweedfs/seaweedfs/weed/util/http"
+	"github.com/seaweedfs/seaweedfs/weed/wdclient"
+)
+
+type testFileIdLookup wdclient.LookupFileIdFunctionType
+
+func (l testFileIdLookup) GetLookupFileIdFunction() wdclient.LookupFileIdFunctionType {
+	return wdclient.LookupFileIdFunctionType(l)
+}
+
+// TestRangedReadOverZstdChunks reads ranges of a file whose chunks are stored compressed with zstd,
+// as the filer and the s3 gateway stream them from the volume server
+func TestRangedReadOverZstdChunks(t *testing.T) {
+	util_http.InitGlobalHttpClient()
+
+	store := storage.NewStore(nil, "127.0.0.1", 0, 0, "", []string{t.TempDir()}, []int32{1},
+		[]util.MinFreeSpace{{}}, "", storage.NeedleMapInMemory, []types.DiskType{types.HardDriveType}, 0)
+	defer store.Close()
+	if err := store.AddVolume(1, "", storage.NeedleMapInMemory, "000", "", 0, 0, types.HardDriveType, 0); err != nil {
+		t.Fatalf("add volume: %v", err)
+	}
+	vs := &VolumeServer{
+		store:    store,
+		guard:    security.NewGuard(nil, "", 0, "", 0),
+		ReadMode: "local",
+
+		inFlightDownloadDataLimitCond: sync.NewCond(new(sync.Mutex)),
+	}
+	server := httptest.NewServer(http.HandlerFunc(vs.GetOrHeadHandler))
+	defer server.Close()
+
+	var content []byte
+	var chunks []*filer_pb.FileChunk
+	for i := 1; i <= 3; i++ {
+		data := []byte(strings.Repeat(fmt.Sprintf("chunk %d line of compressible text\n", i), 2000))
+		compressed, err := util.ZstdData(data, 0)
+		if err != nil {
+			t.Fatalf("compress: %v", err)
+		}
+		n := &needle.Needle{Id: types.NeedleId(i), Cookie: 0x12345678, Data: compressed, Checksum: needle.NewCRC(compressed)}
+		n.SetIsCompressed()
+		if _, err = store.WriteVolumeNeedle(1, n, true, false, 0); err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+		chunks = append(chunks, &filer_pb.FileChunk{
+			FileId:       needle.NewFileId(1, uint64(i), 0x12345678).String(),
+			Offset:       int64(len(content)),
+			Size:         uint64(len(data)),
+			ModifiedTsNs: int64(i),
+			IsCompressed: true,
+		})
+		content = append(content, data...)
+	}
+	lookup := testFileIdLookup(func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	})
+
+	chunkSize := int64(chunks[0].Size)
+	for _, r := range []struct {
+		offset, size int64
+	}{
+		{0, int64(len(content))},       // whole chunks
+		{100, 1000},                    // inside a chunk
+		{chunkSize - 10, 20},           // across two chunks
+		{chunkSize / 2, 2 * chunkSize}, // partial, whole, and partial chunks
+		{int64(len(content)) - 7, 7},   // the end of the file
+	} {
+		var buf bytes.Buffer
+		if err := filer.StreamContent(lookup, &buf, chunks, r.offset, r.size); err != nil {
+			t.Fatalf("read [%d,%d): %v", r.offset, r.offset+r.size, err)
+		}
+		if !bytes.Equal(buf.Bytes(), content[r.offset:r.offset+r.size]) {
+			t.Errorf("read [%d,%d): unexpected %d bytes", r.offset, r.offset+r.size, buf.Len())
+		}
+	}
+}
@@ -100,6 +100,7 @@ type FileInfo struct {
 	etag         string
 	isDirectory  bool
 	err          error
+	entry        *filer_pb.Entry // to check the acl without looking up the entry again
 }
 
 func (fi *FileInfo) Name() string       { return fi.name }
@@ -190,6 +191,10 @@ func (fs *WebDavFileSystem) Mkdir(ctx context.Context, fullDirPath string, perm
 		return os.ErrExist
 	}
 
+	if err = fs.checkParentAcl(fullDirPath); err != nil {
+		return err
+	}
+
 	return fs.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
 		dir, name := util.FullPath(fullDirPath).DirAndName()
 		request := &filer_pb.CreateEntryRequest{
@@ -230,6 +235,9 @@ func (fs *WebDavFileSystem) OpenFile(ctx context.Context, fullFilePath string, f
 		if strings.HasSuffix(fullFilePath, "/") {
 			return nil, os.ErrInvalid
 		}
+		if err = fs.checkParentAcl(fullFilePath); err != nil {
+			return nil, err
+		}
 		_, err = fs.stat(ctx, fullFilePath)
 		if err == nil {
 			if flag&os.O_EXCL != 0 {
@@ -281,6 +289,13 @@ func (fs *WebDavFileSystem) OpenFile(ctx context.Context, fullFilePath string, f
 	if !strings.HasSuffix(fullFilePath, "/") && fi.IsDir() {
 		fullFilePath += "/"
 	}
+	want := uint32(filer.AccessRead)
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		want |= filer.AccessWrite
+	}
+	if err = fs.checkEntryAcl(fi.(*FileInfo).entry, want); err != nil {
+		return nil, err
+	}
 
 	return &WebDavFile{
 		fs:          fs,
@@ -307,6 +322,10 @@ func (fs *WebDavFileSystem) RemoveAll(ctx context.Context, name string) error {
 
 	glog.V(2).Infof("WebDavFileSystem.RemoveAll %v", name)
 
+	if err := fs.checkParentAcl(name); err != nil {
+		return err
+	}
+
 	return fs.removeAll(ctx, name)
 }
 
@@ -340,6 +359,13 @@ func (fs *WebDavFileSystem) Rename(ctx context.Context, oldName, newName string)
 		return os.ErrExist
 	}
 
+	if err = fs.checkParentAcl(oldName); err != nil {
+		return err
+	}
+	if err = fs.checkParentAcl(newName); err != nil {
+		return err
+	}
+
 	oldDir, oldBaseName := util.FullPath(oldName).DirAndName()
 	newDir, newBaseName := util.FullPath(newName).DirAndName()
 
@@ -362,6 +388,37 @@ func (fs *WebDavFileSystem) Rename(ctx context.Context, oldName, newName string)
 	})
 }
 
+// checkAcl only rejects the access if the entry carries an acl not allowing the webdav uid and gid
+func (fs *WebDavFileSystem) checkAcl(fullFilePath string, want uint32) error {
+	fullpath := util.FullPath(strings.TrimRight(fullFilePath, "/"))
+	if fullpath == "" {
+		return nil
+	}
+	entry, err := filer_pb.GetEntry(fs, fullpath)
+	if err != nil {
+		return nil
+	}
+	return fs.checkEntryAcl(entry, want)
+}
+
+// checkEntryAcl checks an entry already looked up
+func (fs *WebDavFileSystem) checkEntryAcl(entry *filer_pb.Entry, want uint32) error {
+	if entry == nil || !filer.HasAcl(entry.Extended) {
+		return nil
+	}
+	identity := &filer.AclIdentity{Uid: fs.option.Uid, Gids: []uint32{fs.option.Gid}}
+	if !filer.CheckPbEntryAccess(entry, identity, want) {
+		return os.ErrPermission
+	}
+	return nil
+}
+
+// checkParentAcl checks the permission to add or remove entries in the parent directory
+func (fs *WebDavFileSystem) checkParentAcl(fullFilePath string) error {
+	dir, _ := util.FullPath(strings.TrimRight(fullFilePath, "/")).DirAndName()
+	return fs.checkAcl(dir, filer.AccessWrite|filer.AccessExecute)
+}
+
 func (fs *WebDavFileSystem) stat(ctx context.Context, fullFilePath string) (os.FileInfo, error) {
 	var err error
 	if fullFilePath, err = clearName(fullFilePath); err != nil {
@@ -388,6 +445,7 @@ func (fs *WebDavFileSystem) stat(ctx context.Context, fullFilePath string) (os.F
 	fi.modifiedTime = time.Unix(entry.Attributes.Mtime, 0)
 	fi.etag = filer.ETag(entry)
 	fi.isDirectory = entry.IsDirectory
+	fi.entry = entry
 
 	if fi.name == "/" {
 		fi.modifiedTime = time.Now()
@@ -202,7 +202,7 @@ func deleteDuplicatedEcShards(commandEnv *CommandEnv, allEcNodes []*EcNode, coll
 func doDeduplicateEcShards(commandEnv *CommandEnv, collection string, vid needle.VolumeId, locations []*EcNode, applyBalancing bool) error {
 
 	// check whether this volume has ecNodes that are over average
-	shardToLocations := make([][]*EcNode, erasure_coding.TotalShardsCount)
+	shardToLocations := make([][]*EcNode, erasure_coding.MaxShardCount)
 	for _, ecNode := range locations {
 		shardBits := findEcVolumeShards(ecNode, vid)
 		for _, shardId := range shardBits.ShardIds() {
@@ -248,7 +248,7 @@ func balanceEcShardsAcrossRacks(commandEnv *CommandEnv, allEcNodes []*EcNode, ra
 func doBalanceEcShardsAcrossRacks(commandEnv *CommandEnv, collection string, vid needle.VolumeId, locations []*EcNode, racks map[RackId]*EcRack, applyBalancing bool) error {
 
 	// calculate average number of shards an ec rack should have for one volume
-	averageShardsPerEcRack := ceilDivide(erasure_coding.TotalShardsCount, len(racks))
+	averageShardsPerEcRack := ceilDivide(findEcVolumeScheme(locations, vid).TotalShards(), len(racks))
 
 	// see the volume's shards are in how many racks, and how many in each rack
 	rackToShardCount := groupByCount(locations, func(ecNode *EcNode) (id string, count int) {
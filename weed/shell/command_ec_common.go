@@ -50,7 +50,7 @@ func moveMountedShardToEcNode(commandEnv *CommandEnv, existingLocation *EcNode,
 
 	}
 
-	destinationEcNode.addEcVolumeShards(vid, collection, copiedShardIds)
+	destinationEcNode.addEcVolumeShards(vid, collection, findEcVolumeScheme([]*EcNode{existingLocation}, vid), copiedShardIds)
 	existingLocation.deleteEcVolumeShards(vid, copiedShardIds)
 
 	return nil
@@ -159,14 +159,6 @@ func swap(data []*CandidateEcNode, i, j int) {
 	data[j] = t
 }
 
-func countShards(ecShardInfos []*master_pb.VolumeEcShardInformationMessage) (count int) {
-	for _, ecShardInfo := range ecShardInfos {
-		shardBits := erasure_coding.ShardBits(ecShardInfo.EcIndexBits)
-		count += shardBits.ShardIdCount()
-	}
-	return
-}
-
 func countFreeShardSlots(dn *master_pb.DataNodeInfo, diskType types.DiskType) (count int) {
 	if dn.DiskInfos == nil {
 		return 0
@@ -175,7 +167,17 @@ func countFreeShardSlots(dn *master_pb.DataNodeInfo, diskType types.DiskType) (c
 	if diskInfo == nil {
 		return 0
 	}
-	return int(diskInfo.MaxVolumeCount-diskInfo.VolumeCount)*erasure_coding.DataShardsCount - countShards(diskInfo.EcShardInfos)
+	// counted in the shards of the default scheme, with the existing shards weighted by the data shards of their schemes
+	freeSlotUnits := (diskInfo.MaxVolumeCount-diskInfo.VolumeCount)*erasure_coding.VolumeSlotUnits - countShardSlotUnits(diskInfo.EcShardInfos)
+	return int(freeSlotUnits / erasure_coding.DefaultEcScheme.ShardSlotUnits(1))
+}
+
+func countShardSlotUnits(ecShardInfos []*master_pb.VolumeEcShardInformationMessage) (units int64) {
+	for _, ecShardInfo := range ecShardInfos {
+		scheme := erasure_coding.NewEcScheme(ecShardInfo.DataShards, ecShardInfo.ParityShards)
+		units += scheme.ShardSlotUnits(erasure_coding.ShardBits(ecShardInfo.EcIndexBits).ShardIdCount())
+	}
+	return
 }
 
 type RackId string
@@ -299,7 +301,21 @@ func findEcVolumeShards(ecNode *EcNode, vid needle.VolumeId) erasure_coding.Shar
 	return 0
 }
 
-func (ecNode *EcNode) addEcVolumeShards(vid needle.VolumeId, collection string, shardIds []uint32) *EcNode {
+// findEcVolumeScheme finds the erasure coding scheme of the ec volume from any of its shards
+func findEcVolumeScheme(ecNodes []*EcNode, vid needle.VolumeId) erasure_coding.EcScheme {
+	for _, ecNode := range ecNodes {
+		for _, diskInfo := range ecNode.info.DiskInfos {
+			for _, shardInfo := range diskInfo.EcShardInfos {
+				if needle.VolumeId(shardInfo.Id) == vid {
+					return erasure_coding.NewEcScheme(shardInfo.DataShards, shardInfo.ParityShards)
+				}
+			}
+		}
+	}
+	return erasure_coding.DefaultEcScheme
+}
+
+func (ecNode *EcNode) addEcVolumeShards(vid needle.VolumeId, collection string, scheme erasure_coding.EcScheme, shardIds []uint32) *EcNode {
 
 	foundVolume := false
 	diskInfo, found := ecNode.info.DiskInfos[string(types.HardDriveType)]
@@ -330,10 +346,12 @@ func (ecNode *EcNode) addEcVolumeShards(vid needle.VolumeId, collection string,
 			newShardBits = newShardBits.AddShardId(erasure_coding.ShardId(shardId))
 		}
 		diskInfo.EcShardInfos = append(diskInfo.EcShardInfos, &master_pb.VolumeEcShardInformationMessage{
-			Id:          uint32(vid),
-			Collection:  collection,
-			EcIndexBits: uint32(newShardBits),
-			DiskType:    string(types.HardDriveType),
+			Id:           uint32(vid),
+			Collection:   collection,
+			EcIndexBits:  uint32(newShardBits),
+			DiskType:     string(types.HardDriveType),
+			DataShards:   uint32(scheme.DataShards),
+			ParityShards: uint32(scheme.ParityShards),
 		})
 		ecNode.freeEcSlot -= len(shardIds)
 	}
@@ -38,13 +38,14 @@ func (c *commandEcEncode) Help() string {
 
 	ec.encode [-collection=""] [-fullPercent=95 -quietFor=1h]
 	ec.encode [-collection=""] [-volumeId=<volume_id>]
+	ec.encode [-collection="archive"] [-scheme=16+4]
 
 	This command will:
 	1. freeze one volume
 	2. apply erasure coding to the volume
 	3. move the encoded shards to multiple volume servers
 
-	The erasure coding is 10.4. So ideally you have more than 14 volume servers, and you can afford
+	The erasure coding is 10.4 by default. So ideally you have more than 14 volume servers, and you can afford
 	to lose 4 volume servers.
 
 	If the number of volumes are not high, the worst case is that you only have 4 volume servers,
@@ -53,6 +54,10 @@ func (c *commandEcEncode) Help() string {
 	If you only have less than 4 volume servers, with erasure coding, at least you can afford to
 	have 4 corrupted shard files.
 
+	The -scheme sets the data and parity shards of the encoded volumes, e.g. 6+3 for small clusters,
+	or 16+4 for cold archives, up to 32 shards in total. It is kept with each ec volume,
+	and used by ec.rebuild, ec.balance and ec.decode, so each collection can have its own scheme.
+
 `
 }
 
@@ -69,9 +74,14 @@ func (c *commandEcEncode) Do(args []string, commandEnv *CommandEnv, writer io.Wr
 	quietPeriod := encodeCommand.Duration("quietFor", time.Hour, "select volumes without no writes for this period")
 	parallelCopy := encodeCommand.Bool("parallelCopy", true, "copy shards in parallel")
 	forceChanges := encodeCommand.Bool("force", false, "force the encoding even if the cluster has less than recommended 4 nodes")
+	schemeString := encodeCommand.String("scheme", erasure_coding.DefaultEcScheme.String(), "the data and parity shards count, e.g. 6+3 or 16+4")
 	if err = encodeCommand.Parse(args); err != nil {
 		return nil
 	}
+	scheme, err := erasure_coding.ParseEcScheme(*schemeString)
+	if err != nil {
+		return err
+	}
 
 	if err = commandEnv.confirmIsLocked(args); err != nil {
 		return
@@ -88,8 +98,8 @@ func (c *commandEcEncode) Do(args []string, commandEnv *CommandEnv, writer io.Wr
 		eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
 			nodeCount++
 		})
-		if nodeCount < erasure_coding.ParityShardsCount {
-			glog.V(0).Infof("skip erasure coding with %d nodes, less than recommended %d nodes", nodeCount, erasure_coding.ParityShardsCount)
+		if nodeCount < scheme.ParityShards {
+			glog.V(0).Infof("skip erasure coding with %d nodes, less than recommended %d nodes", nodeCount, scheme.ParityShards)
 			return nil
 		}
 	}
@@ -98,7 +108,7 @@ func (c *commandEcEncode) Do(args []string, commandEnv *CommandEnv, writer io.Wr
 
 	// volumeId is provided
 	if vid != 0 {
-		return doEcEncode(commandEnv, *collection, vid, *parallelCopy)
+		return doEcEncode(commandEnv, *collection, vid, scheme, *parallelCopy)
 	}
 
 	// apply to all volumes in the collection
@@ -108,7 +118,7 @@ func (c *commandEcEncode) Do(args []string, commandEnv *CommandEnv, writer io.Wr
 	}
 	fmt.Printf("ec encode volumes: %v\n", volumeIds)
 	for _, vid := range volumeIds {
-		if err = doEcEncode(commandEnv, *collection, vid, *parallelCopy); err != nil {
+		if err = doEcEncode(commandEnv, *collection, vid, scheme, *parallelCopy); err != nil {
 			return err
 		}
 	}
@@ -116,7 +126,7 @@ func (c *commandEcEncode) Do(args []string, commandEnv *CommandEnv, writer io.Wr
 	return nil
 }
 
-func doEcEncode(commandEnv *CommandEnv, collection string, vid needle.VolumeId, parallelCopy bool) (err error) {
+func doEcEncode(commandEnv *CommandEnv, collection string, vid needle.VolumeId, scheme erasure_coding.EcScheme, parallelCopy bool) (err error) {
 	if !commandEnv.isLocked() {
 		return fmt.Errorf("lock is lost")
 	}
@@ -136,13 +146,13 @@ func doEcEncode(commandEnv *CommandEnv, collection string, vid needle.VolumeId,
 	}
 
 	// generate ec shards
-	err = generateEcShards(commandEnv.option.GrpcDialOption, vid, collection, locations[0].ServerAddress())
+	err = generateEcShards(commandEnv.option.GrpcDialOption, vid, collection, scheme, locations[0].ServerAddress())
 	if err != nil {
 		return fmt.Errorf("generate ec shards for volume %d on %s: %v", vid, locations[0].Url, err)
 	}
 
 	// balance the ec shards to current cluster
-	err = spreadEcShards(commandEnv, vid, collection, locations, parallelCopy)
+	err = spreadEcShards(commandEnv, vid, collection, scheme, locations, parallelCopy)
 	if err != nil {
 		return fmt.Errorf("spread ec shards for volume %d from %s: %v", vid, locations[0].Url, err)
 	}
@@ -150,14 +160,16 @@ func doEcEncode(commandEnv *CommandEnv, collection string, vid needle.VolumeId,
 	return nil
 }
 
-func generateEcShards(grpcDialOption grpc.DialOption, volumeId needle.VolumeId, collection string, sourceVolumeServer pb.ServerAddress) error {
+func generateEcShards(grpcDialOption grpc.DialOption, volumeId needle.VolumeId, collection string, scheme erasure_coding.EcScheme, sourceVolumeServer pb.ServerAddress) error {
 
-	fmt.Printf("generateEcShards %s %d on %s ...\n", collection, volumeId, sourceVolumeServer)
+	fmt.Printf("generateEcShards %s %d with %s on %s ...\n", collection, volumeId, scheme, sourceVolumeServer)
 
 	err := operation.WithVolumeServerClient(false, sourceVolumeServer, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
 		_, genErr := volumeServerClient.VolumeEcShardsGenerate(context.Background(), &volume_server_pb.VolumeEcShardsGenerateRequest{
-			VolumeId:   uint32(volumeId),
-			Collection: collection,
+			VolumeId:     uint32(volumeId),
+			Collection:   collection,
+			DataShards:   uint32(scheme.DataShards),
+			ParityShards: uint32(scheme.ParityShards),
 		})
 		return genErr
 	})
@@ -166,26 +178,26 @@ func generateEcShards(grpcDialOption grpc.DialOption, volumeId needle.VolumeId,
 
 }
 
-func spreadEcShards(commandEnv *CommandEnv, volumeId needle.VolumeId, collection string, existingLocations []wdclient.Location, parallelCopy bool) (err error) {
+func spreadEcShards(commandEnv *CommandEnv, volumeId needle.VolumeId, collection string, scheme erasure_coding.EcScheme, existingLocations []wdclient.Location, parallelCopy bool) (err error) {
 
 	allEcNodes, totalFreeEcSlots, err := collectEcNodes(commandEnv, "")
 	if err != nil {
 		return err
 	}
 
-	if totalFreeEcSlots < erasure_coding.TotalShardsCount {
+	if totalFreeEcSlots < scheme.TotalShards() {
 		return fmt.Errorf("not enough free ec shard slots. only %d left", totalFreeEcSlots)
 	}
 	allocatedDataNodes := allEcNodes
-	if len(allocatedDataNodes) > erasure_coding.TotalShardsCount {
-		allocatedDataNodes = allocatedDataNodes[:erasure_coding.TotalShardsCount]
+	if len(allocatedDataNodes) > scheme.TotalShards() {
+		allocatedDataNodes = allocatedDataNodes[:scheme.TotalShards()]
 	}
 
 	// calculate how many shards to allocate for these servers
-	allocatedEcIds := balancedEcDistribution(allocatedDataNodes)
+	allocatedEcIds := balancedEcDistribution(allocatedDataNodes, scheme.TotalShards())
 
 	// ask the data nodes to copy from the source volume server
-	copiedShardIds, err := parallelCopyEcShardsFromSource(commandEnv.option.GrpcDialOption, allocatedDataNodes, allocatedEcIds, volumeId, collection, existingLocations[0], parallelCopy)
+	copiedShardIds, err := parallelCopyEcShardsFromSource(commandEnv.option.GrpcDialOption, allocatedDataNodes, allocatedEcIds, volumeId, collection, scheme, existingLocations[0], parallelCopy)
 	if err != nil {
 		return err
 	}
@@ -215,7 +227,7 @@ func spreadEcShards(commandEnv *CommandEnv, volumeId needle.VolumeId, collection
 
 }
 
-func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServers []*EcNode, allocatedEcIds [][]uint32, volumeId needle.VolumeId, collection string, existingLocation wdclient.Location, parallelCopy bool) (actuallyCopied []uint32, err error) {
+func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServers []*EcNode, allocatedEcIds [][]uint32, volumeId needle.VolumeId, collection string, scheme erasure_coding.EcScheme, existingLocation wdclient.Location, parallelCopy bool) (actuallyCopied []uint32, err error) {
 
 	fmt.Printf("parallelCopyEcShardsFromSource %d %s\n", volumeId, existingLocation.Url)
 
@@ -229,7 +241,7 @@ func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServer
 			err = copyErr
 		} else {
 			shardIdChan <- copiedShardIds
-			server.addEcVolumeShards(volumeId, collection, copiedShardIds)
+			server.addEcVolumeShards(volumeId, collection, scheme, copiedShardIds)
 		}
 	}
 	cleanupFunc := func(server *EcNode, allocatedEcShardIds []uint32) {
@@ -274,11 +286,11 @@ func parallelCopyEcShardsFromSource(grpcDialOption grpc.DialOption, targetServer
 	return
 }
 
-func balancedEcDistribution(servers []*EcNode) (allocated [][]uint32) {
+func balancedEcDistribution(servers []*EcNode, totalShards int) (allocated [][]uint32) {
 	allocated = make([][]uint32, len(servers))
 	allocatedShardIdIndex := uint32(0)
 	serverIndex := rand.Intn(len(servers))
-	for allocatedShardIdIndex < erasure_coding.TotalShardsCount {
+	for allocatedShardIdIndex < uint32(totalShards) {
 		if servers[serverIndex].freeEcSlot > 0 {
 			allocated[serverIndex] = append(allocated[serverIndex], allocatedShardIdIndex)
 			allocatedShardIdIndex++
@@ -111,21 +111,22 @@ func rebuildEcVolumes(commandEnv *CommandEnv, allEcNodes []*EcNode, collection s
 	}
 
 	for vid, locations := range ecShardMap {
+		scheme := findEcVolumeScheme(allEcNodes, vid)
 		shardCount := locations.shardCount()
-		if shardCount == erasure_coding.TotalShardsCount {
+		if shardCount == scheme.TotalShards() {
 			continue
 		}
-		if shardCount < erasure_coding.DataShardsCount {
-			return fmt.Errorf("ec volume %d is unrepairable with %d shards\n", vid, shardCount)
+		if shardCount < scheme.DataShards {
+			return fmt.Errorf("ec volume %d is unrepairable with %d shards of %s\n", vid, shardCount, scheme)
 		}
 
 		sortEcNodesByFreeslotsDescending(allEcNodes)
 
-		if allEcNodes[0].freeEcSlot < erasure_coding.TotalShardsCount {
+		if allEcNodes[0].freeEcSlot < scheme.TotalShards() {
 			return fmt.Errorf("disk space is not enough")
 		}
 
-		if err := rebuildOneEcVolume(commandEnv, allEcNodes[0], collection, vid, locations, writer, applyChanges); err != nil {
+		if err := rebuildOneEcVolume(commandEnv, allEcNodes[0], collection, vid, scheme, locations, writer, applyChanges); err != nil {
 			return err
 		}
 	}
@@ -133,7 +134,7 @@ func rebuildEcVolumes(commandEnv *CommandEnv, allEcNodes []*EcNode, collection s
 	return nil
 }
 
-func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, locations EcShardLocations, writer io.Writer, applyChanges bool) error {
+func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, scheme erasure_coding.EcScheme, locations EcShardLocations, writer io.Writer, applyChanges bool) error {
 
 	if !commandEnv.isLocked() {
 		return fmt.Errorf("lock is lost")
@@ -143,7 +144,7 @@ func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection st
 
 	// collect shard files to rebuilder local disk
 	var generatedShardIds []uint32
-	copiedShardIds, _, err := prepareDataToRecover(commandEnv, rebuilder, collection, volumeId, locations, writer, applyChanges)
+	copiedShardIds, _, err := prepareDataToRecover(commandEnv, rebuilder, collection, volumeId, scheme, locations, writer, applyChanges)
 	if err != nil {
 		return err
 	}
@@ -174,7 +175,7 @@ func rebuildOneEcVolume(commandEnv *CommandEnv, rebuilder *EcNode, collection st
 		return err
 	}
 
-	rebuilder.addEcVolumeShards(volumeId, collection, generatedShardIds)
+	rebuilder.addEcVolumeShards(volumeId, collection, scheme, generatedShardIds)
 
 	return nil
 }
@@ -194,7 +195,7 @@ func generateMissingShards(grpcDialOption grpc.DialOption, collection string, vo
 	return
 }
 
-func prepareDataToRecover(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, locations EcShardLocations, writer io.Writer, applyBalancing bool) (copiedShardIds []uint32, localShardIds []uint32, err error) {
+func prepareDataToRecover(commandEnv *CommandEnv, rebuilder *EcNode, collection string, volumeId needle.VolumeId, scheme erasure_coding.EcScheme, locations EcShardLocations, writer io.Writer, applyBalancing bool) (copiedShardIds []uint32, localShardIds []uint32, err error) {
 
 	needEcxFile := true
 	var localShardBits erasure_coding.ShardBits
@@ -247,7 +248,7 @@ func prepareDataToRecover(commandEnv *CommandEnv, rebuilder *EcNode, collection
 
 	}
 
-	if len(copiedShardIds)+len(localShardIds) >= erasure_coding.DataShardsCount {
+	if len(copiedShardIds)+len(localShardIds) >= scheme.DataShards {
 		return copiedShardIds, localShardIds, nil
 	}
 
@@ -264,7 +265,7 @@ func (ecShardMap EcShardMap) registerEcNode(ecNode *EcNode, collection string) {
 			if shardInfo.Collection == collection {
 				existing, found := ecShardMap[needle.VolumeId(shardInfo.Id)]
 				if !found {
-					existing = make([][]*EcNode, erasure_coding.TotalShardsCount)
+					existing = make([][]*EcNode, erasure_coding.NewEcScheme(shardInfo.DataShards, shardInfo.ParityShards).TotalShards())
 					ecShardMap[needle.VolumeId(shardInfo.Id)] = existing
 				}
 				for _, shardId := range erasure_coding.ShardBits(shardInfo.EcIndexBits).ShardIds() {
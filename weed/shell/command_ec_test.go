@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 )
 
@@ -15,7 +16,7 @@ func TestCommandEcDistribution(t *testing.T) {
 		newEcNode("dc1", "rack2", "dn2", 100),
 	}
 
-	allocated := balancedEcDistribution(allEcNodes)
+	allocated := balancedEcDistribution(allEcNodes, erasure_coding.TotalShardsCount)
 
 	fmt.Printf("allocated: %+v", allocated)
 }
@@ -136,5 +137,5 @@ func newEcNode(dc string, rack string, dataNodeId string, freeEcSlot int) *EcNod
 }
 
 func (ecNode *EcNode) addEcVolumeAndShardsForTest(vid uint32, collection string, shardIds []uint32) *EcNode {
-	return ecNode.addEcVolumeShards(needle.VolumeId(vid), collection, shardIds)
+	return ecNode.addEcVolumeShards(needle.VolumeId(vid), collection, erasure_coding.DefaultEcScheme, shardIds)
 }
@@ -10,7 +10,9 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 func init() {
@@ -37,6 +39,22 @@ func (c *commandFsConfigure) Help() string {
 	# example: configure adding only 1 physical volume for each bucket collection
 	fs.configure -locationPrefix=/buckets/ -volumeGrowthCount=1
 
+	# example: pack files up to 16KiB into shared container chunks
+	fs.configure -locationPrefix=/my/small/files -packSmallFiles -packMaxFileSize=16KiB
+
+	# example: store identical content once, with content-defined chunking
+	fs.configure -locationPrefix=/my/backups -dedup
+
+	# example: compress with zstd level 9, or only the data that compresses well
+	fs.configure -locationPrefix=/my/logs -compression=zstd:9
+	fs.configure -locationPrefix=/my/mixed -compression=auto
+
+	# example: write the chunks erasure coded with 10 data and 4 parity shards, instead of replicated.
+	# Each chunk is padded to whole rows of 10 x 1MB, so it suits large files uploaded with large chunks.
+	# The chunks smaller than one row are still replicated, and so are all the chunks when the ec shards can not be
+	# written, e.g. with less than total/parity shards data nodes, or with volume encryption at rest configured.
+	fs.configure -locationPrefix=/my/backups -ecScheme=10+4
+
 	# apply the changes
 	fs.configure -locationPrefix=/my/folder -collection=abc -apply
 
@@ -59,6 +77,7 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 	ttl := fsConfigureCommand.String("ttl", "", "assign writes with this ttl (e.g., 1m, 1h, 1d, 1w, 1y)")
 	diskType := fsConfigureCommand.String("disk", "", "[hdd|ssd|<tag>] hard drive or solid state drive or any tag")
 	fsync := fsConfigureCommand.Bool("fsync", false, "fsync for the writes")
+	groupCommitMs := fsConfigureCommand.Uint("groupCommitMs", 0, "fsync the writes in groups, each write waiting at most this many milliseconds for the others")
 	isReadOnly := fsConfigureCommand.Bool("readOnly", false, "disable writes")
 	worm := fsConfigureCommand.Bool("worm", false, "worm mode, If true, a file can only be changed once, after which it becomes readonly and undeletable, see https://en.wikipedia.org/wiki/Write_once_read_many")
 	maxFileNameLength := fsConfigureCommand.Uint("maxFileNameLength", 0, "file name length limits in bytes for compatibility with Unix-based systems")
@@ -66,6 +85,11 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 	rack := fsConfigureCommand.String("rack", "", "assign writes to this rack")
 	dataNode := fsConfigureCommand.String("dataNode", "", "assign writes to this dataNode")
 	volumeGrowthCount := fsConfigureCommand.Int("volumeGrowthCount", 0, "the number of physical volumes to add if no writable volumes")
+	packSmallFiles := fsConfigureCommand.Bool("packSmallFiles", false, "pack small files into shared container chunks, to reduce the number of needles")
+	packMaxFileSize := fsConfigureCommand.String("packMaxFileSize", "0", "pack files up to this size, e.g., 16KiB, 0 means the default 64KiB")
+	dedup := fsConfigureCommand.Bool("dedup", false, "split uploads into content-defined chunks, and store identical chunks only once")
+	compression := fsConfigureCommand.String("compression", "", "[none|gzip|zstd|zstd:<level>|auto] compress the chunks, auto compresses with zstd by entropy sampling, empty to compress by the content type")
+	ecScheme := fsConfigureCommand.String("ecScheme", "", "write the chunks erasure coded directly with this data+parity scheme, e.g. 10+4, instead of replicated")
 	isDelete := fsConfigureCommand.Bool("delete", false, "delete the configuration by locationPrefix")
 	apply := fsConfigureCommand.Bool("apply", false, "update and apply filer configuration")
 	if err = fsConfigureCommand.Parse(args); err != nil {
@@ -79,12 +103,17 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 
 	if *locationPrefix != "" {
 		infoAboutSimulationMode(writer, *apply, "-apply")
+		packMaxFileSizeBytes, err := util.ParseBytes(*packMaxFileSize)
+		if err != nil {
+			return fmt.Errorf("parse packMaxFileSize %s: %v", *packMaxFileSize, err)
+		}
 		locConf := &filer_pb.FilerConf_PathConf{
 			LocationPrefix:    *locationPrefix,
 			Collection:        *collection,
 			Replication:       *replication,
 			Ttl:               *ttl,
 			Fsync:             *fsync,
+			GroupCommitMs:     uint32(*groupCommitMs),
 			MaxFileNameLength: uint32(*maxFileNameLength),
 			DiskType:          *diskType,
 			VolumeGrowthCount: uint32(*volumeGrowthCount),
@@ -93,6 +122,11 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 			Rack:              *rack,
 			DataNode:          *dataNode,
 			Worm:              *worm,
+			PackSmallFiles:    *packSmallFiles,
+			PackMaxFileSize:   packMaxFileSizeBytes,
+			Dedup:             *dedup,
+			Compression:       *compression,
+			EcScheme:          *ecScheme,
 		}
 
 		// check collection
@@ -111,6 +145,24 @@ func (c *commandFsConfigure) Do(args []string, commandEnv *CommandEnv, writer io
 			}
 		}
 
+		// check compression
+		if _, err := util.ParseCompressionPolicy(*compression); err != nil {
+			return err
+		}
+
+		// check ec scheme
+		if *ecScheme != "" {
+			if _, err := erasure_coding.ParseEcScheme(*ecScheme); err != nil {
+				return err
+			}
+			if *replication != "" {
+				return fmt.Errorf("the chunks written erasure coded are not replicated")
+			}
+			if *ttl != "" {
+				return fmt.Errorf("the chunks written erasure coded do not expire")
+			}
+		}
+
 		// check ttl
 		if *ttl != "" {
 			regex := "^(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)[mhdwMy]$"
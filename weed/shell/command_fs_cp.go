@@ -0,0 +1,98 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsCp{})
+}
+
+type commandFsCp struct {
+}
+
+func (c *commandFsCp) Name() string {
+	return "fs.cp"
+}
+
+func (c *commandFsCp) Help() string {
+	return `copy a file by referencing the same data chunks, without copying the data
+
+	fs.cp  <source file> <destination entry>
+
+	fs.cp /dir/file_name /dir2/filename2
+	fs.cp /dir/file_name /dir2
+
+	The chunks are shared by the source and the copied files, and are deleted after both files are deleted.
+`
+}
+
+func (c *commandFsCp) HasTag(CommandTag) bool {
+	return false
+}
+
+func (c *commandFsCp) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	if len(args) != 2 {
+		return fmt.Errorf("need to have 2 arguments")
+	}
+
+	sourcePath, err := commandEnv.parseUrl(args[0])
+	if err != nil {
+		return err
+	}
+
+	destinationPath, err := commandEnv.parseUrl(args[1])
+	if err != nil {
+		return err
+	}
+
+	sourceDir, sourceName := util.FullPath(sourcePath).DirAndName()
+
+	destinationDir, destinationName := util.FullPath(destinationPath).DirAndName()
+
+	return commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+
+		// collect destination entry info
+		respDestinationLookupEntry, err := filer_pb.LookupEntry(client, &filer_pb.LookupDirectoryEntryRequest{
+			Directory: destinationDir,
+			Name:      destinationName,
+		})
+
+		var targetDir, targetName string
+
+		if err == nil && respDestinationLookupEntry.Entry.IsDirectory {
+			// to a directory
+			targetDir = util.Join(destinationDir, destinationName)
+			targetName = sourceName
+		} else {
+			// to a file
+			targetDir = destinationDir
+			targetName = destinationName
+		}
+
+		resp, err := client.CloneEntry(context.Background(), &filer_pb.CloneEntryRequest{
+			SourceDirectory: sourceDir,
+			SourceName:      sourceName,
+			TargetDirectory: targetDir,
+			TargetName:      targetName,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Error != "" {
+			return fmt.Errorf("copy %s: %s", sourcePath, resp.Error)
+		}
+
+		fmt.Fprintf(writer, "copy: %s => %s\n", sourcePath, util.NewFullPath(targetDir, targetName))
+
+		return nil
+
+	})
+
+}
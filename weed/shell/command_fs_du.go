@@ -3,10 +3,12 @@ package shell
 import (
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/seaweedfs/seaweedfs/weed/filer"
 	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
 	"github.com/seaweedfs/seaweedfs/weed/util"
+	"github.com/seaweedfs/seaweedfs/weed/wdclient"
 )
 
 func init() {
@@ -23,6 +25,9 @@ func (c *commandFsDu) Name() string {
 func (c *commandFsDu) Help() string {
 	return `show disk usage
 
+	The logical size is the file size. The physical size counts the stored chunks,
+	and counts the deduplicated chunks shared by the files only once.
+
 	fs.du /dir
 	fs.du /dir/file_name
 	fs.du /dir/file_prefix
@@ -44,45 +49,71 @@ func (c *commandFsDu) Do(args []string, commandEnv *CommandEnv, writer io.Writer
 		path = path + "/"
 	}
 
-	var blockCount, byteCount uint64
+	var blockCount, byteCount, physicalCount uint64
 	dir, name := util.FullPath(path).DirAndName()
-	blockCount, byteCount, err = duTraverseDirectory(writer, commandEnv, dir, name)
+	seen := make(map[string]bool)
+	blockCount, byteCount, physicalCount, err = duTraverseDirectory(writer, commandEnv, commandEnv.MasterClient.GetLookupFileIdFunction(), seen, dir, name)
 
 	if name == "" && err == nil {
-		fmt.Fprintf(writer, "block:%4d\tlogical size:%10d\t%s\n", blockCount, byteCount, dir)
+		fmt.Fprintf(writer, "block:%4d\tlogical size:%10d\tphysical size:%10d\t%s\n", blockCount, byteCount, physicalCount, dir)
 	}
 
 	return
 
 }
 
-func duTraverseDirectory(writer io.Writer, filerClient filer_pb.FilerClient, dir, name string) (blockCount, byteCount uint64, err error) {
+func duTraverseDirectory(writer io.Writer, filerClient filer_pb.FilerClient, lookupFn wdclient.LookupFileIdFunctionType, seen map[string]bool, dir, name string) (blockCount, byteCount, physicalCount uint64, err error) {
 
 	err = filer_pb.ReadDirAllEntries(filerClient, util.FullPath(dir), name, func(entry *filer_pb.Entry, isLast bool) error {
 
-		var fileBlockCount, fileByteCount uint64
+		var fileBlockCount, fileByteCount, filePhysicalCount uint64
 
 		if entry.IsDirectory {
 			subDir := fmt.Sprintf("%s/%s", dir, entry.Name)
 			if dir == "/" {
 				subDir = "/" + entry.Name
 			}
-			numBlock, numByte, err := duTraverseDirectory(writer, filerClient, subDir, "")
+			numBlock, numByte, numPhysical, err := duTraverseDirectory(writer, filerClient, lookupFn, seen, subDir, "")
 			if err == nil {
 				blockCount += numBlock
 				byteCount += numByte
+				physicalCount += numPhysical
 			}
 		} else {
 			fileBlockCount = uint64(len(entry.GetChunks()))
 			fileByteCount = filer.FileSize(entry)
+			filePhysicalCount = duPhysicalSize(lookupFn, seen, entry)
 			blockCount += fileBlockCount
 			byteCount += fileByteCount
+			physicalCount += filePhysicalCount
 		}
 
 		if name != "" && !entry.IsDirectory {
-			fmt.Fprintf(writer, "block:%4d\tlogical size:%10d\t%s/%s\n", fileBlockCount, fileByteCount, dir, entry.Name)
+			fmt.Fprintf(writer, "block:%4d\tlogical size:%10d\tphysical size:%10d\t%s/%s\n", fileBlockCount, fileByteCount, filePhysicalCount, dir, entry.Name)
 		}
 		return nil
 	})
 	return
 }
+
+// duPhysicalSize counts the stored bytes of the entry, except the deduplicated chunks already counted
+func duPhysicalSize(lookupFn wdclient.LookupFileIdFunctionType, seen map[string]bool, entry *filer_pb.Entry) (physicalCount uint64) {
+	physicalCount = uint64(len(entry.Content))
+	chunks := entry.GetChunks()
+	if filer.HasChunkManifest(chunks) {
+		if dataChunks, _, err := filer.ResolveChunkManifest(lookupFn, chunks, 0, math.MaxInt64); err == nil {
+			chunks = dataChunks
+		}
+	}
+	for _, chunk := range chunks {
+		if chunk.IsDeduplicated() {
+			fileId := chunk.GetFileIdString()
+			if seen[fileId] {
+				continue
+			}
+			seen[fileId] = true
+		}
+		physicalCount += chunk.Size
+	}
+	return
+}
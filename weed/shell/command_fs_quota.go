@@ -0,0 +1,177 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/filer"
+	"github.com/seaweedfs/seaweedfs/weed/pb/filer_pb"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func init() {
+	Commands = append(Commands, &commandFsQuota{})
+}
+
+type commandFsQuota struct {
+}
+
+func (c *commandFsQuota) Name() string {
+	return "fs.quota"
+}
+
+func (c *commandFsQuota) Help() string {
+	return `configure and show byte and inode quotas for directory trees, users and groups
+
+	# show all quotas and their current usage
+	fs.quota
+
+	# limit a directory tree to 10GiB and 100000 files and directories
+	fs.quota -dir=/home/chris -maxBytes=10GiB -maxInodes=100000 -apply
+
+	# limit everything owned by a uid or gid, anywhere in the namespace
+	fs.quota -uid=1000 -maxBytes=1TiB -apply
+	fs.quota -gid=100 -maxInodes=1000000 -apply
+
+	# remove a quota
+	fs.quota -dir=/home/chris -delete -apply
+
+	The usage is tracked by the filer. For a new quota, the filer first walks
+	the directory tree, or the whole namespace for uid and gid quotas, to
+	calculate the existing usage. Writes exceeding a quota fail with EDQUOT.
+	A rename into a quota directory fails with EDQUOT if the moved file, or the
+	usage of a moved directory with its own quota, would exceed it. The other moved
+	directories are charged as they are moved, and only the later writes fail.
+`
+}
+
+func (c *commandFsQuota) HasTag(CommandTag) bool {
+	return false
+}
+
+func (c *commandFsQuota) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	fsQuotaCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	dir := fsQuotaCommand.String("dir", "", "the directory tree to limit")
+	uid := fsQuotaCommand.Int("uid", -1, "the uid to limit")
+	gid := fsQuotaCommand.Int("gid", -1, "the gid to limit")
+	maxBytes := fsQuotaCommand.String("maxBytes", "0", "the byte limit, e.g., 500MiB, 10GiB, 0 means unlimited")
+	maxInodes := fsQuotaCommand.Int64("maxInodes", 0, "the limit of files and directories, 0 means unlimited")
+	isDelete := fsQuotaCommand.Bool("delete", false, "delete the quota")
+	apply := fsQuotaCommand.Bool("apply", false, "update and apply the quota configuration")
+	if err = fsQuotaCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	var limit *filer_pb.QuotaConf_Limit
+	switch {
+	case *dir != "" && *uid < 0 && *gid < 0:
+		dirPath, err := commandEnv.parseUrl(*dir)
+		if err != nil {
+			return err
+		}
+		limit = &filer_pb.QuotaConf_Limit{Kind: filer_pb.QuotaConf_DIRECTORY, Path: dirPath}
+	case *dir == "" && *uid >= 0 && *gid < 0:
+		limit = &filer_pb.QuotaConf_Limit{Kind: filer_pb.QuotaConf_USER, Id: uint32(*uid)}
+	case *dir == "" && *uid < 0 && *gid >= 0:
+		limit = &filer_pb.QuotaConf_Limit{Kind: filer_pb.QuotaConf_GROUP, Id: uint32(*gid)}
+	case *dir == "" && *uid < 0 && *gid < 0:
+		return c.printQuotaUsage(commandEnv, writer)
+	default:
+		return fmt.Errorf("only one of -dir, -uid and -gid can be specified")
+	}
+
+	byteLimit, err := util.ParseBytes(*maxBytes)
+	if err != nil {
+		return fmt.Errorf("parse maxBytes %s: %v", *maxBytes, err)
+	}
+	limit.MaxBytes = int64(byteLimit)
+	limit.MaxInodes = *maxInodes
+	if !*isDelete && limit.MaxBytes <= 0 && limit.MaxInodes <= 0 {
+		return fmt.Errorf("need -maxBytes or -maxInodes, or -delete")
+	}
+
+	var content []byte
+	if err = commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		content, err = filer.ReadInsideFiler(client, filer.DirectoryEtcSeaweedFS, filer.QuotaConfName)
+		return err
+	}); err != nil && err != filer_pb.ErrNotFound {
+		return fmt.Errorf("read %s/%s: %v", filer.DirectoryEtcSeaweedFS, filer.QuotaConfName, err)
+	}
+	conf, err := filer.ParseQuotaConf(content)
+	if err != nil {
+		return fmt.Errorf("parse %s/%s: %v", filer.DirectoryEtcSeaweedFS, filer.QuotaConfName, err)
+	}
+
+	infoAboutSimulationMode(writer, *apply, "-apply")
+
+	key := filer.QuotaLimitKey(limit)
+	var limits []*filer_pb.QuotaConf_Limit
+	for _, existing := range conf.Limits {
+		if filer.QuotaLimitKey(existing) != key {
+			limits = append(limits, existing)
+		}
+	}
+	if !*isDelete {
+		limits = append(limits, limit)
+	}
+	conf.Limits = limits
+
+	var buf bytes.Buffer
+	if err = filer.ProtoToText(&buf, conf); err != nil {
+		return err
+	}
+	fmt.Fprintln(writer, buf.String())
+
+	if *apply {
+		if err = commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+			return filer.SaveInsideFiler(client, filer.DirectoryEtcSeaweedFS, filer.QuotaConfName, buf.Bytes())
+		}); err != nil && err != filer_pb.ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *commandFsQuota) printQuotaUsage(commandEnv *CommandEnv, writer io.Writer) error {
+	return commandEnv.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.GetQuotaUsage(context.Background(), &filer_pb.GetQuotaUsageRequest{})
+		if err != nil {
+			return fmt.Errorf("get quota usage: %v", err)
+		}
+		for _, item := range resp.Items {
+			limit, usage := item.Limit, item.Usage
+			var target string
+			switch limit.Kind {
+			case filer_pb.QuotaConf_USER:
+				target = fmt.Sprintf("uid:%d", limit.Id)
+			case filer_pb.QuotaConf_GROUP:
+				target = fmt.Sprintf("gid:%d", limit.Id)
+			default:
+				target = limit.Path
+			}
+			status := ""
+			if item.IsCalculating {
+				status = "\t(calculating)"
+			}
+			fmt.Fprintf(writer, "%s\tbytes:%s/%s\tinodes:%d/%s%s\n", target,
+				util.BytesToHumanReadable(uint64(usage.Bytes)), formatQuotaLimit(limit.MaxBytes, true),
+				usage.Inodes, formatQuotaLimit(limit.MaxInodes, false), status)
+		}
+		return nil
+	})
+}
+
+func formatQuotaLimit(limit int64, isBytes bool) string {
+	if limit <= 0 {
+		return "unlimited"
+	}
+	if isBytes {
+		return util.BytesToHumanReadable(uint64(limit))
+	}
+	return fmt.Sprintf("%d", limit)
+}
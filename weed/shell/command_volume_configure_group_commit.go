@@ -0,0 +1,114 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeConfigureGroupCommit{})
+}
+
+type commandVolumeConfigureGroupCommit struct {
+}
+
+func (c *commandVolumeConfigureGroupCommit) Name() string {
+	return "volume.configure.groupCommit"
+}
+
+func (c *commandVolumeConfigureGroupCommit) Help() string {
+	return `fsync the writes of volumes in groups
+
+	volume.configure.groupCommit -volumeId 3 -groupCommitMs 2
+	volume.configure.groupCommit -collectionPattern "logs*" -groupCommitMs 5
+	volume.configure.groupCommit -collectionPattern "logs*" -groupCommitMs 0
+
+	With group commit, every write to the volume is fsynced before it is acknowledged.
+	Concurrent writes share one fsync, and each write waits at most groupCommitMs milliseconds for the others.
+	0 disables group commit. The setting is kept in the .vif file of each volume replica.
+
+	The writes to volumes without group commit can also be grouped per path with "fs.configure -groupCommitMs".
+`
+}
+
+func (c *commandVolumeConfigureGroupCommit) HasTag(CommandTag) bool {
+	return false
+}
+
+func (c *commandVolumeConfigureGroupCommit) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	configureCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeId := configureCommand.Uint("volumeId", 0, "the volume id")
+	collectionPattern := configureCommand.String("collectionPattern", "", "match with wildcard characters '*' and '?'")
+	groupCommitMs := configureCommand.Uint("groupCommitMs", 0, "fsync the writes in groups, each write waiting at most this many milliseconds for the others. 0 to disable")
+	if err = configureCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if err = commandEnv.confirmIsLocked(args); err != nil {
+		return
+	}
+
+	if *volumeId == 0 && *collectionPattern == "" {
+		return fmt.Errorf("need to specify -volumeId or -collectionPattern")
+	}
+	if *groupCommitMs > math.MaxUint32 {
+		return fmt.Errorf("groupCommitMs %d is too large", *groupCommitMs)
+	}
+
+	// collect topology information
+	topologyInfo, _, err := collectTopologyInfo(commandEnv, 0)
+	if err != nil {
+		return err
+	}
+
+	eachDataNode(topologyInfo, func(dc string, rack RackId, dn *master_pb.DataNodeInfo) {
+		if err != nil {
+			return
+		}
+		var targetVolumeIds []uint32
+		for _, diskInfo := range dn.DiskInfos {
+			for _, v := range diskInfo.VolumeInfos {
+				if *volumeId > 0 {
+					if v.Id == uint32(*volumeId) {
+						targetVolumeIds = append(targetVolumeIds, v.Id)
+					}
+				} else if matched, _ := filepath.Match(*collectionPattern, v.Collection); matched {
+					targetVolumeIds = append(targetVolumeIds, v.Id)
+				}
+			}
+		}
+		if len(targetVolumeIds) == 0 {
+			return
+		}
+		err = operation.WithVolumeServerClient(false, pb.NewServerAddressFromDataNode(dn), commandEnv.option.GrpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+			for _, targetVolumeId := range targetVolumeIds {
+				resp, configureErr := volumeServerClient.VolumeConfigure(context.Background(), &volume_server_pb.VolumeConfigureRequest{
+					VolumeId:       targetVolumeId,
+					SetGroupCommit: true,
+					GroupCommitMs:  uint32(*groupCommitMs),
+				})
+				if configureErr != nil {
+					return configureErr
+				}
+				if resp.Error != "" {
+					return errors.New(resp.Error)
+				}
+				fmt.Fprintf(writer, "volume %d on %s: group commit %dms\n", targetVolumeId, dn.Id, *groupCommitMs)
+			}
+			return nil
+		})
+	})
+
+	return err
+}
@@ -0,0 +1,76 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeIndexSwitch{})
+}
+
+type commandVolumeIndexSwitch struct {
+}
+
+func (c *commandVolumeIndexSwitch) Name() string {
+	return "volume.index.switch"
+}
+
+func (c *commandVolumeIndexSwitch) Help() string {
+	return `switch the needle index type of volumes, while they stay online
+
+	volume.index.switch -node <volume server host:port> -index lsm
+	volume.index.switch -node <volume server host:port> -index memory -volumeId <volume id>
+
+	The index is rebuilt from the existing .idx file, of one volume or of all volumes on the volume server.
+	Switching all volumes also changes the index type of the volumes created later.
+	Writes to a volume are only blocked while its new index catches up with the last writes.
+
+	The index types are the same as the volume server -index option: memory, leveldb, leveldbMedium, leveldbLarge, lsm.
+	Restarting the volume server loads the volumes with its -index option again, so also change the option to keep the new type.
+`
+}
+
+func (c *commandVolumeIndexSwitch) HasTag(CommandTag) bool {
+	return false
+}
+
+func (c *commandVolumeIndexSwitch) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	switchCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeServer := switchCommand.String("node", "", "<host>:<port> of the volume server")
+	indexType := switchCommand.String("index", "", "[memory|leveldb|leveldbMedium|leveldbLarge|lsm]")
+	volumeId := switchCommand.Uint("volumeId", 0, "the volume id, or 0 for all volumes")
+	if err = switchCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if err = commandEnv.confirmIsLocked(args); err != nil {
+		return
+	}
+
+	if *volumeServer == "" {
+		return fmt.Errorf("need to specify volume server by -node=<host>:<port>")
+	}
+	if _, err = storage.ParseNeedleMapKind(*indexType); err != nil {
+		return err
+	}
+
+	return operation.WithVolumeServerClient(false, pb.ServerAddress(*volumeServer), commandEnv.option.GrpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+		resp, switchErr := volumeServerClient.VolumeNeedleMapSwitch(context.Background(), &volume_server_pb.VolumeNeedleMapSwitchRequest{
+			VolumeId: uint32(*volumeId),
+			Index:    *indexType,
+		})
+		if resp != nil {
+			fmt.Fprintf(writer, "switched %d volumes on %s to %s index\n", resp.SwitchedVolumeCount, *volumeServer, *indexType)
+		}
+		return switchErr
+	})
+}
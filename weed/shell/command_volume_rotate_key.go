@@ -0,0 +1,120 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeRotateKey{})
+}
+
+type commandVolumeRotateKey struct {
+}
+
+func (c *commandVolumeRotateKey) Name() string {
+	return "volume.rotateKey"
+}
+
+func (c *commandVolumeRotateKey) Help() string {
+	return `re-wrap the data keys of encrypted volumes with the current master key
+
+	volume.rotateKey -volumeId 3
+	volume.rotateKey -collectionPattern "secrets*" -newDataKey
+
+	The volume servers encrypt the needle data at rest when "volume.encryption" is configured in security.toml.
+	Each volume has its own data keys, kept in its .vif file wrapped by the master key.
+
+	After adding a new master key, this command re-wraps the data keys with it, so the old master key can be retired.
+	A volume without a data key, e.g. created before encryption was enabled, gets one, and encrypts its new writes.
+	With -newDataKey, a new data key is also added for the new writes.
+	The needles written with the earlier data keys, or not encrypted, are re-encrypted when the volume is vacuumed,
+	after which the earlier data keys are dropped.
+
+	The data keys are rotated on one replica, and copied to the other replicas, so all replicas share the same keys.
+`
+}
+
+func (c *commandVolumeRotateKey) HasTag(CommandTag) bool {
+	return false
+}
+
+func (c *commandVolumeRotateKey) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	rotateCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeId := rotateCommand.Uint("volumeId", 0, "the volume id")
+	collectionPattern := rotateCommand.String("collectionPattern", "", "match with wildcard characters '*' and '?'")
+	newDataKey := rotateCommand.Bool("newDataKey", false, "also add a new data key to encrypt the new writes")
+	if err = rotateCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if err = commandEnv.confirmIsLocked(args); err != nil {
+		return
+	}
+
+	if *volumeId == 0 && *collectionPattern == "" {
+		return fmt.Errorf("need to specify -volumeId or -collectionPattern")
+	}
+
+	// collect topology information
+	topologyInfo, _, err := collectTopologyInfo(commandEnv, 0)
+	if err != nil {
+		return err
+	}
+	volumeReplicas, _ := collectVolumeReplicaLocations(topologyInfo)
+
+	var targetVolumeIds []uint32
+	for vid, replicas := range volumeReplicas {
+		if *volumeId > 0 {
+			if vid == uint32(*volumeId) {
+				targetVolumeIds = append(targetVolumeIds, vid)
+			}
+		} else if matched, _ := filepath.Match(*collectionPattern, replicas[0].info.Collection); matched {
+			targetVolumeIds = append(targetVolumeIds, vid)
+		}
+	}
+	if len(targetVolumeIds) == 0 {
+		return fmt.Errorf("no volume found")
+	}
+	slices.Sort(targetVolumeIds)
+
+	for _, vid := range targetVolumeIds {
+		var dataKeys []*volume_server_pb.VolumeDataKey
+		for i, replica := range volumeReplicas[vid] {
+			err = operation.WithVolumeServerClient(false, pb.NewServerAddressFromDataNode(replica.location.dataNode), commandEnv.option.GrpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+				resp, rotateErr := volumeServerClient.VolumeRotateKey(context.Background(), &volume_server_pb.VolumeRotateKeyRequest{
+					VolumeId:      vid,
+					RotateDataKey: *newDataKey && i == 0,
+					DataKeys:      dataKeys,
+				})
+				if rotateErr != nil {
+					return rotateErr
+				}
+				if i == 0 {
+					dataKeys = resp.DataKeys
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("rotate key of volume %d on %s: %v", vid, replica.location.dataNode.Id, err)
+			}
+		}
+		currentKeyId := uint32(0)
+		if len(dataKeys) > 0 {
+			currentKeyId = dataKeys[len(dataKeys)-1].KeyId
+		}
+		fmt.Fprintf(writer, "volume %d: %d replicas encrypt with data key %d of %d keys\n", vid, len(volumeReplicas[vid]), currentKeyId, len(dataKeys))
+	}
+
+	return nil
+}
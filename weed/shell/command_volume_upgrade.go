@@ -0,0 +1,139 @@
+package shell
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func init() {
+	Commands = append(Commands, &commandVolumeUpgrade{})
+}
+
+type commandVolumeUpgrade struct {
+}
+
+func (c *commandVolumeUpgrade) Name() string {
+	return "volume.upgrade"
+}
+
+func (c *commandVolumeUpgrade) Help() string {
+	return `convert volumes to a newer needle version during vacuum
+
+	volume.upgrade -volumeId 3
+	volume.upgrade -collectionPattern "logs*" -version 4 -vacuum
+
+	The target needle version is kept in the .vif file of each replica.
+	The next vacuum converts the volume, regardless of its garbage ratio,
+	re-writing all its needles in the target version when the volume is compacted to a copy.
+	The needles written during the vacuum are converted when the compaction is committed.
+	With -vacuum, the master is asked to vacuum the volumes right away.
+
+	Version 4 lets each needle choose its checksum, with the "checksum" upload parameter
+	set to crc32c, xxhash64 or sha256, and keeps its content encoding and encryption info.
+	The volumes can not be downgraded.
+`
+}
+
+func (c *commandVolumeUpgrade) HasTag(CommandTag) bool {
+	return false
+}
+
+func (c *commandVolumeUpgrade) Do(args []string, commandEnv *CommandEnv, writer io.Writer) (err error) {
+
+	upgradeCommand := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	volumeId := upgradeCommand.Uint("volumeId", 0, "the volume id")
+	collectionPattern := upgradeCommand.String("collectionPattern", "", "match with wildcard characters '*' and '?'")
+	version := upgradeCommand.Uint("version", uint(needle.LatestVersion), "the target needle version")
+	vacuum := upgradeCommand.Bool("vacuum", false, "ask the master to vacuum the volumes now")
+	if err = upgradeCommand.Parse(args); err != nil {
+		return nil
+	}
+
+	if err = commandEnv.confirmIsLocked(args); err != nil {
+		return
+	}
+
+	if *volumeId == 0 && *collectionPattern == "" {
+		return fmt.Errorf("need to specify -volumeId or -collectionPattern")
+	}
+	if *version > 255 || !needle.IsSupportedVersion(needle.Version(*version)) {
+		return fmt.Errorf("unsupported needle version %d", *version)
+	}
+
+	// collect topology information
+	topologyInfo, _, err := collectTopologyInfo(commandEnv, 0)
+	if err != nil {
+		return err
+	}
+	volumeReplicas, _ := collectVolumeReplicaLocations(topologyInfo)
+
+	var targetVolumeIds []uint32
+	for vid, replicas := range volumeReplicas {
+		if *volumeId > 0 {
+			if vid == uint32(*volumeId) {
+				targetVolumeIds = append(targetVolumeIds, vid)
+			}
+		} else if matched, _ := filepath.Match(*collectionPattern, replicas[0].info.Collection); matched {
+			targetVolumeIds = append(targetVolumeIds, vid)
+		}
+	}
+	if len(targetVolumeIds) == 0 {
+		return fmt.Errorf("no volume found")
+	}
+	slices.Sort(targetVolumeIds)
+
+	for _, vid := range targetVolumeIds {
+		replicas := volumeReplicas[vid]
+		if replicas[0].info.Version >= uint32(*version) {
+			fmt.Fprintf(writer, "volume %d: already at needle version %d\n", vid, replicas[0].info.Version)
+			continue
+		}
+		for _, replica := range replicas {
+			err = operation.WithVolumeServerClient(false, pb.NewServerAddressFromDataNode(replica.location.dataNode), commandEnv.option.GrpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
+				resp, configureErr := volumeServerClient.VolumeConfigure(context.Background(), &volume_server_pb.VolumeConfigureRequest{
+					VolumeId:      vid,
+					NeedleVersion: uint32(*version),
+				})
+				if configureErr != nil {
+					return configureErr
+				}
+				if resp.Error != "" {
+					return fmt.Errorf("%s", resp.Error)
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("upgrade volume %d on %s: %v", vid, replica.location.dataNode.Id, err)
+			}
+		}
+		fmt.Fprintf(writer, "volume %d: %d replicas to convert from needle version %d to %d\n", vid, len(replicas), replicas[0].info.Version, *version)
+
+		if !*vacuum {
+			continue
+		}
+		err = commandEnv.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+			_, vacuumErr := client.VacuumVolume(context.Background(), &master_pb.VacuumVolumeRequest{
+				GarbageThreshold: 1,
+				VolumeId:         vid,
+				Collection:       replicas[0].info.Collection,
+			})
+			return vacuumErr
+		})
+		if err != nil {
+			return fmt.Errorf("vacuum volume %d: %v", vid, err)
+		}
+	}
+
+	return nil
+}
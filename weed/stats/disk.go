@@ -1,15 +1,44 @@
 package stats
 
 import (
+	"path/filepath"
+	"sync"
+
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
 )
 
+// directory => func() (all, free uint64), for the directories whose data is not on their file system
+var diskSpaceFuncs sync.Map
+
+// SetDiskSpaceFunc reports the space of the directory with fn instead of its file system, e.g. for a block device
+// holding the volumes of the directory. A nil fn reverts to the file system.
+func SetDiskSpaceFunc(dir string, fn func() (all, free uint64)) {
+	dir = diskSpaceKey(dir)
+	if fn == nil {
+		diskSpaceFuncs.Delete(dir)
+		return
+	}
+	diskSpaceFuncs.Store(dir, fn)
+}
+
 func NewDiskStatus(path string) (disk *volume_server_pb.DiskStatus) {
 	disk = &volume_server_pb.DiskStatus{Dir: path}
-	fillInDiskStatus(disk)
+	if fn, found := diskSpaceFuncs.Load(diskSpaceKey(path)); found {
+		disk.All, disk.Free = fn.(func() (uint64, uint64))()
+		calculateDiskRemaining(disk)
+	} else {
+		fillInDiskStatus(disk)
+	}
 	if disk.PercentUsed > 95 {
 		glog.V(0).Infof("disk status: %v", disk)
 	}
 	return
 }
+
+func diskSpaceKey(dir string) string {
+	if absDir, err := filepath.Abs(dir); err == nil {
+		return absDir
+	}
+	return filepath.Clean(dir)
+}
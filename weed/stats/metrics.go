@@ -62,6 +62,14 @@ var (
 			Help:      "Counter of master received heartbeat.",
 		}, []string{"type"})
 
+	MasterReceivedCorruptNeedleCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "master",
+			Name:      "received_corrupt_needles",
+			Help:      "Counter of corrupt needles reported by volume servers.",
+		}, []string{"collection", "type"})
+
 	MasterReplicaPlacementMismatch = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: Namespace,
@@ -127,6 +135,14 @@ var (
 			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 24),
 		}, []string{"type"})
 
+	FilerEcWriteWastedBytesCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "filer",
+			Name:      "ec_write_wasted_bytes",
+			Help:      "Counter of the ec volume rows reserved by the failed direct ec writes, which are not reused.",
+		})
+
 	FilerServerLastSendTsOfSubscribeGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: Namespace,
@@ -250,6 +266,30 @@ var (
 			Help:      "Resource usage",
 		}, []string{"name", "type"})
 
+	VolumeServerScrubBytesCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "scrub_bytes",
+			Help:      "Counter of bytes verified by the background scrubbing.",
+		}, []string{"disk", "type"})
+
+	VolumeServerScrubNeedleCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "scrub_needles",
+			Help:      "Counter of needles verified by the background scrubbing.",
+		}, []string{"disk", "type"})
+
+	VolumeServerScrubLastPassGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "volumeServer",
+			Name:      "scrub_last_pass_timestamp_seconds",
+			Help:      "The time when the last background scrubbing pass of the disk was completed.",
+		}, []string{"disk"})
+
 	S3RequestCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: Namespace,
@@ -287,6 +327,7 @@ func init() {
 	Gather.MustRegister(MasterRaftIsleader)
 	Gather.MustRegister(MasterAdminLock)
 	Gather.MustRegister(MasterReceivedHeartbeatCounter)
+	Gather.MustRegister(MasterReceivedCorruptNeedleCounter)
 	Gather.MustRegister(MasterLeaderChangeCounter)
 	Gather.MustRegister(MasterReplicaPlacementMismatch)
 	Gather.MustRegister(MasterVolumeLayoutWritable)
@@ -295,6 +336,7 @@ func init() {
 	Gather.MustRegister(FilerRequestCounter)
 	Gather.MustRegister(FilerHandlerCounter)
 	Gather.MustRegister(FilerRequestHistogram)
+	Gather.MustRegister(FilerEcWriteWastedBytesCounter)
 	Gather.MustRegister(FilerStoreCounter)
 	Gather.MustRegister(FilerStoreHistogram)
 	Gather.MustRegister(FilerSyncOffsetGauge)
@@ -313,6 +355,9 @@ func init() {
 	Gather.MustRegister(VolumeServerReadOnlyVolumeGauge)
 	Gather.MustRegister(VolumeServerDiskSizeGauge)
 	Gather.MustRegister(VolumeServerResourceGauge)
+	Gather.MustRegister(VolumeServerScrubBytesCounter)
+	Gather.MustRegister(VolumeServerScrubNeedleCounter)
+	Gather.MustRegister(VolumeServerScrubLastPassGauge)
 
 	Gather.MustRegister(S3RequestCounter)
 	Gather.MustRegister(S3HandlerCounter)
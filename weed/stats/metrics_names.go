@@ -27,13 +27,15 @@ const (
 	Failed                = "failed"
 
 	// filer handler
-	DirList            = "dirList"
-	ContentSaveToFiler = "contentSaveToFiler"
-	AutoChunk          = "autoChunk"
-	ChunkProxy         = "chunkProxy"
-	ChunkAssign        = "chunkAssign"
-	ChunkUpload        = "chunkUpload"
-	ChunkMerge         = "chunkMerge"
+	DirList             = "dirList"
+	ContentSaveToFiler  = "contentSaveToFiler"
+	ContentPacked       = "contentPacked"
+	ContentDeduplicated = "contentDeduplicated"
+	AutoChunk           = "autoChunk"
+	ChunkProxy          = "chunkProxy"
+	ChunkAssign         = "chunkAssign"
+	ChunkUpload         = "chunkUpload"
+	ChunkMerge          = "chunkMerge"
 
 	ChunkDoUploadRetry       = "chunkDoUploadRetry"
 	ChunkUploadRetry         = "chunkUploadRetry"
@@ -0,0 +1,759 @@
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// A block device, or a large preallocated file, can hold the .dat files of one volume directory without a file system.
+// The .idx and .vif files stay in the directory.
+//
+// The device is split into fixed size extents, allocated to the files as they grow:
+//
+//	header | allocation table slot 0 | allocation table slot 1 | size log | extent 0 | extent 1 | ...
+//
+// The allocation table lists the files with their sizes and extents. It is written to the two slots in turn,
+// so a torn write leaves the previous table intact, whenever the files or their extents change.
+// When a file is synced, its new size is appended to the size log in one block, and replayed over the table
+// of the same generation when loading. The table is persisted again when the size log is full.
+//
+// The device is opened with O_DIRECT where supported, so all IO is done in aligned blocks.
+const (
+	BlockDeviceDefaultExtentSize = 64 * 1024 * 1024
+	blockDeviceBlockSize         = 4096
+	blockDeviceMagic             = "SWFSBLK1"
+	blockDeviceTableHeaderSize   = 16      // generation, payload length, payload crc
+	blockDeviceTableReserve      = 1 << 20 // for the file names and sizes
+	blockDeviceSizeLogBlocks     = 256
+	noExtent                     = math.MaxUint32
+)
+
+var (
+	blockDevices     = make(map[string]*BlockDevice)
+	blockDevicesLock sync.RWMutex
+)
+
+type BlockDevice struct {
+	path          string
+	dir           string
+	file          *os.File
+	extentSize    int64
+	extentCount   uint32
+	tableOffset   int64
+	tableSlotSize int64
+	sizeLogOffset int64
+	sizeLogBlocks int64 // 0 for the devices formatted without a size log
+	dataOffset    int64
+
+	lock        sync.Mutex
+	generation  uint64
+	files       map[string]*blockDeviceFileEntry
+	freeExtents []uint32
+	sizeLogNext int64 // the next block of the size log to write
+	isClosed    bool
+}
+
+type blockDeviceFileEntry struct {
+	sync.RWMutex
+	name      string
+	size      int64
+	modTime   time.Time
+	extents   []uint32
+	isDirty   bool // the size or modification time is not persisted yet
+	isRemoved bool
+
+	// the last partially written block, to append without reading it back
+	tailBlock       []byte
+	tailBlockOffset int64
+}
+
+// AttachBlockDevice keeps the .dat files of the directory on the block device.
+// The spec is the device path, optionally with the size to preallocate a regular file, e.g. /data/volumes.img:2TiB.
+// An empty device is only formatted if format is set.
+func AttachBlockDevice(dir string, spec string, format bool) (*BlockDevice, error) {
+	devicePath, size := spec, int64(0)
+	if i := strings.LastIndex(spec, ":"); i > 0 {
+		if parsedSize, err := util.ParseBytes(spec[i+1:]); err == nil {
+			devicePath, size = spec[:i], int64(parsedSize)
+		}
+	}
+	dir = blockDeviceKey(dir)
+
+	blockDevicesLock.Lock()
+	defer blockDevicesLock.Unlock()
+	if _, found := blockDevices[dir]; found {
+		return nil, fmt.Errorf("directory %s already has a block device", dir)
+	}
+	for _, d := range blockDevices {
+		if d.path == devicePath {
+			return nil, fmt.Errorf("block device %s is already attached to %s", devicePath, d.dir)
+		}
+	}
+	d, err := OpenBlockDevice(devicePath, size, BlockDeviceDefaultExtentSize, format)
+	if err != nil {
+		return nil, err
+	}
+	d.dir = dir
+	blockDevices[dir] = d
+	stats.SetDiskSpaceFunc(dir, d.Space)
+	glog.V(0).Infof("directory %s stores volume data on %s, %d extents of %d MiB", dir, devicePath, d.extentCount, d.extentSize/1024/1024)
+	return d, nil
+}
+
+func DetachBlockDevice(dir string) error {
+	dir = blockDeviceKey(dir)
+	blockDevicesLock.Lock()
+	defer blockDevicesLock.Unlock()
+	d, found := blockDevices[dir]
+	if !found {
+		return nil
+	}
+	delete(blockDevices, dir)
+	stats.SetDiskSpaceFunc(dir, nil)
+	return d.Close()
+}
+
+func FindBlockDevice(dir string) *BlockDevice {
+	blockDevicesLock.RLock()
+	defer blockDevicesLock.RUnlock()
+	return blockDevices[blockDeviceKey(dir)]
+}
+
+func blockDeviceKey(dir string) string {
+	if absDir, err := filepath.Abs(util.ResolvePath(dir)); err == nil {
+		return absDir
+	}
+	return filepath.Clean(dir)
+}
+
+// blockDeviceOf finds the block device for a volume data file, only .dat and .cpd files are kept on block devices
+func blockDeviceOf(fileName string) (*BlockDevice, string) {
+	if ext := filepath.Ext(fileName); ext != ".dat" && ext != ".cpd" {
+		return nil, ""
+	}
+	d := FindBlockDevice(filepath.Dir(fileName))
+	if d == nil {
+		return nil, ""
+	}
+	return d, filepath.Base(fileName)
+}
+
+// OpenBlockDeviceFile opens an existing volume data file on the block device of its directory
+func OpenBlockDeviceFile(fileName string) (*BlockDeviceFile, error) {
+	d, name := blockDeviceOf(fileName)
+	if d == nil {
+		return nil, &os.PathError{Op: "open", Path: fileName, Err: os.ErrNotExist}
+	}
+	f, err := d.OpenFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f.name = fileName
+	return f, nil
+}
+
+// OpenVolumeFile opens a volume file in the directory, or on the block device of the directory if it is not in the directory
+func OpenVolumeFile(fileName string, flag int) (BackendStorageFile, error) {
+	file, err := os.OpenFile(fileName, flag, 0644)
+	if err == nil {
+		return NewDiskFile(file), nil
+	}
+	if os.IsNotExist(err) {
+		if blockDeviceFile, blockDeviceErr := OpenBlockDeviceFile(fileName); blockDeviceErr == nil {
+			return blockDeviceFile, nil
+		}
+	}
+	return nil, err
+}
+
+func createBlockDeviceFile(fileName string) (file BackendStorageFile, isOnBlockDevice bool, err error) {
+	d, name := blockDeviceOf(fileName)
+	if d == nil {
+		return nil, false, nil
+	}
+	f, err := d.CreateFile(name)
+	if err != nil {
+		return nil, true, err
+	}
+	f.name = fileName
+	return f, true, nil
+}
+
+// RenameVolumeFile renames a volume file, on the block device of its directory if it is there
+func RenameVolumeFile(oldName, newName string) error {
+	if d, name := blockDeviceOf(oldName); d != nil && d.HasFile(name) {
+		if filepath.Dir(oldName) != filepath.Dir(newName) {
+			return fmt.Errorf("rename %s to another directory %s", oldName, newName)
+		}
+		return d.Rename(name, filepath.Base(newName))
+	}
+	return os.Rename(oldName, newName)
+}
+
+// RemoveVolumeFile removes a volume file from the directory, and from the block device of the directory
+func RemoveVolumeFile(fileName string) error {
+	err := os.Remove(fileName)
+	if d, name := blockDeviceOf(fileName); d != nil && d.HasFile(name) {
+		return d.Remove(name)
+	}
+	return err
+}
+
+// OpenBlockDevice opens the device, and formats it if format is set and its first block is empty.
+// A regular file is created or extended to the size if it is smaller.
+func OpenBlockDevice(devicePath string, size int64, extentSize int64, format bool) (*BlockDevice, error) {
+	if extentSize <= 0 || extentSize%blockDeviceBlockSize != 0 {
+		return nil, fmt.Errorf("extent size %d is not a multiple of %d", extentSize, blockDeviceBlockSize)
+	}
+	file, err := openBlockDeviceFile(devicePath, size)
+	if err != nil {
+		return nil, fmt.Errorf("open block device %s: %v", devicePath, err)
+	}
+	d := &BlockDevice{
+		path:  devicePath,
+		dir:   filepath.Dir(devicePath),
+		file:  file,
+		files: make(map[string]*blockDeviceFileEntry),
+	}
+	if err = d.load(extentSize, format); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("load block device %s: %v", devicePath, err)
+	}
+	return d, nil
+}
+
+func (d *BlockDevice) load(extentSize int64, format bool) error {
+	header := alignedBuffer(blockDeviceBlockSize)
+	if _, err := d.file.ReadAt(header, 0); err != nil && err != io.EOF {
+		return err
+	}
+	if bytes.Equal(header, make([]byte, blockDeviceBlockSize)) {
+		if !format {
+			return fmt.Errorf("not formatted, set the format option to format it")
+		}
+		return d.format(extentSize)
+	}
+	if string(header[0:8]) != blockDeviceMagic {
+		return fmt.Errorf("unknown data in the first block, clear the first %d bytes to format it", blockDeviceBlockSize)
+	}
+	if crc32.ChecksumIEEE(header[0:56]) != util.BytesToUint32(header[56:60]) {
+		return fmt.Errorf("corrupted header")
+	}
+	d.extentSize = int64(util.BytesToUint64(header[16:24]))
+	d.extentCount = util.BytesToUint32(header[24:28])
+	d.tableOffset = int64(util.BytesToUint64(header[32:40]))
+	d.sizeLogBlocks = int64(util.BytesToUint32(header[28:32]))
+	d.tableSlotSize = int64(util.BytesToUint64(header[40:48]))
+	d.dataOffset = int64(util.BytesToUint64(header[48:56]))
+	d.sizeLogOffset = d.tableOffset + 2*d.tableSlotSize
+	if err := d.loadTable(); err != nil {
+		return err
+	}
+	return d.replaySizeLog()
+}
+
+func (d *BlockDevice) format(extentSize int64) error {
+	deviceSize, err := d.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	estimatedExtentCount := (deviceSize - blockDeviceBlockSize) / extentSize
+	d.extentSize = extentSize
+	d.tableOffset = blockDeviceBlockSize
+	d.tableSlotSize = alignUp(blockDeviceTableHeaderSize+blockDeviceTableReserve+4*estimatedExtentCount, blockDeviceBlockSize)
+	d.sizeLogOffset = d.tableOffset + 2*d.tableSlotSize
+	d.sizeLogBlocks = blockDeviceSizeLogBlocks
+	d.dataOffset = d.sizeLogOffset + d.sizeLogBlocks*blockDeviceBlockSize
+	if deviceSize < d.dataOffset+extentSize {
+		return fmt.Errorf("%d bytes is too small for extents of %d bytes", deviceSize, extentSize)
+	}
+	d.extentCount = uint32((deviceSize - d.dataOffset) / extentSize)
+	glog.V(0).Infof("formatting block device %s: %d extents of %d bytes", d.path, d.extentCount, extentSize)
+
+	header := alignedBuffer(blockDeviceBlockSize)
+	copy(header[0:8], blockDeviceMagic)
+	util.Uint32toBytes(header[8:12], blockDeviceBlockSize)
+	util.Uint64toBytes(header[16:24], uint64(d.extentSize))
+	util.Uint32toBytes(header[24:28], d.extentCount)
+	util.Uint32toBytes(header[28:32], uint32(d.sizeLogBlocks))
+	util.Uint64toBytes(header[32:40], uint64(d.tableOffset))
+	util.Uint64toBytes(header[40:48], uint64(d.tableSlotSize))
+	util.Uint64toBytes(header[48:56], uint64(d.dataOffset))
+	util.Uint32toBytes(header[56:60], crc32.ChecksumIEEE(header[0:56]))
+
+	d.freeExtents = d.freeExtents[:0]
+	for i := d.extentCount; i > 0; i-- {
+		d.freeExtents = append(d.freeExtents, i-1)
+	}
+	// the size log may have entries of an earlier format
+	if _, err = d.file.WriteAt(alignedBuffer(int(d.sizeLogBlocks*blockDeviceBlockSize)), d.sizeLogOffset); err != nil {
+		return err
+	}
+	// write the table before the header, so an interrupted format is started over
+	if err = d.persistTable(); err != nil {
+		return err
+	}
+	if _, err = d.file.WriteAt(header, 0); err != nil {
+		return err
+	}
+	return d.file.Sync()
+}
+
+func (d *BlockDevice) loadTable() error {
+	var payload []byte
+	for slot := int64(0); slot < 2; slot++ {
+		data := alignedBuffer(int(d.tableSlotSize))
+		if _, err := d.file.ReadAt(data, d.tableOffset+slot*d.tableSlotSize); err != nil && err != io.EOF {
+			return err
+		}
+		generation := util.BytesToUint64(data[0:8])
+		length := int64(util.BytesToUint32(data[8:12]))
+		if length > d.tableSlotSize-blockDeviceTableHeaderSize {
+			continue
+		}
+		slotPayload := data[blockDeviceTableHeaderSize : blockDeviceTableHeaderSize+length]
+		if crc32.ChecksumIEEE(slotPayload) != util.BytesToUint32(data[12:16]) {
+			glog.Warningf("block device %s: allocation table slot %d is corrupted", d.path, slot)
+			continue
+		}
+		if payload == nil || generation > d.generation {
+			payload, d.generation = slotPayload, generation
+		}
+	}
+	if payload == nil {
+		return fmt.Errorf("no valid allocation table")
+	}
+
+	used := make([]bool, d.extentCount)
+	fileCount := util.BytesToUint32(payload[0:4])
+	p := payload[4:]
+	for i := uint32(0); i < fileCount; i++ {
+		if len(p) < 2 {
+			return fmt.Errorf("truncated allocation table")
+		}
+		nameLength := int(util.BytesToUint16(p[0:2]))
+		if len(p) < 2+nameLength+20 {
+			return fmt.Errorf("truncated allocation table")
+		}
+		e := &blockDeviceFileEntry{
+			name:            string(p[2 : 2+nameLength]),
+			tailBlockOffset: -1,
+		}
+		p = p[2+nameLength:]
+		e.size = int64(util.BytesToUint64(p[0:8]))
+		e.modTime = time.Unix(0, int64(util.BytesToUint64(p[8:16])))
+		extentCount := int(util.BytesToUint32(p[16:20]))
+		p = p[20:]
+		if len(p) < 4*extentCount {
+			return fmt.Errorf("truncated allocation table")
+		}
+		for j := 0; j < extentCount; j++ {
+			extent := util.BytesToUint32(p[4*j : 4*j+4])
+			if extent != noExtent {
+				if extent >= d.extentCount || used[extent] {
+					return fmt.Errorf("file %s has invalid extent %d", e.name, extent)
+				}
+				used[extent] = true
+			}
+			e.extents = append(e.extents, extent)
+		}
+		p = p[4*extentCount:]
+		d.files[e.name] = e
+	}
+	for i := d.extentCount; i > 0; i-- {
+		if !used[i-1] {
+			d.freeExtents = append(d.freeExtents, i-1)
+		}
+	}
+	return nil
+}
+
+// persistTable writes the allocation table to the older slot. It is called with the lock held.
+func (d *BlockDevice) persistTable() error {
+	if d.isClosed {
+		return os.ErrClosed
+	}
+	var payload bytes.Buffer
+	b := make([]byte, 20)
+	util.Uint32toBytes(b[0:4], uint32(len(d.files)))
+	payload.Write(b[0:4])
+	for _, e := range d.files {
+		util.Uint16toBytes(b[0:2], uint16(len(e.name)))
+		payload.Write(b[0:2])
+		payload.WriteString(e.name)
+		util.Uint64toBytes(b[0:8], uint64(e.size))
+		util.Uint64toBytes(b[8:16], uint64(e.modTime.UnixNano()))
+		util.Uint32toBytes(b[16:20], uint32(len(e.extents)))
+		payload.Write(b[0:20])
+		for _, extent := range e.extents {
+			util.Uint32toBytes(b[0:4], extent)
+			payload.Write(b[0:4])
+		}
+	}
+	if int64(payload.Len()) > d.tableSlotSize-blockDeviceTableHeaderSize {
+		return fmt.Errorf("block device %s: allocation table of %d bytes exceeds %d bytes", d.path, payload.Len(), d.tableSlotSize-blockDeviceTableHeaderSize)
+	}
+
+	generation := d.generation + 1
+	data := alignedBuffer(int(alignUp(int64(blockDeviceTableHeaderSize+payload.Len()), blockDeviceBlockSize)))
+	util.Uint64toBytes(data[0:8], generation)
+	util.Uint32toBytes(data[8:12], uint32(payload.Len()))
+	util.Uint32toBytes(data[12:16], crc32.ChecksumIEEE(payload.Bytes()))
+	copy(data[blockDeviceTableHeaderSize:], payload.Bytes())
+	if _, err := d.file.WriteAt(data, d.tableOffset+int64(generation%2)*d.tableSlotSize); err != nil {
+		return err
+	}
+	if err := d.file.Sync(); err != nil {
+		return err
+	}
+	d.generation = generation
+	d.sizeLogNext = 0
+	for _, e := range d.files {
+		e.isDirty = false
+	}
+	return nil
+}
+
+// appendSizeLog persists the size and the modification time of the file in the next block of the size log,
+// or persists the table if the size log is full. It is called with the lock held.
+//
+//	table generation | size | modification time | name length | name | ... | crc
+func (d *BlockDevice) appendSizeLog(e *blockDeviceFileEntry) error {
+	if d.sizeLogNext >= d.sizeLogBlocks || 26+len(e.name) > blockDeviceBlockSize-4 {
+		return d.persistTable()
+	}
+	block := alignedBuffer(blockDeviceBlockSize)
+	util.Uint64toBytes(block[0:8], d.generation)
+	util.Uint64toBytes(block[8:16], uint64(e.size))
+	util.Uint64toBytes(block[16:24], uint64(e.modTime.UnixNano()))
+	util.Uint16toBytes(block[24:26], uint16(len(e.name)))
+	copy(block[26:], e.name)
+	util.Uint32toBytes(block[blockDeviceBlockSize-4:], crc32.ChecksumIEEE(block[:blockDeviceBlockSize-4]))
+	if _, err := d.file.WriteAt(block, d.sizeLogOffset+d.sizeLogNext*blockDeviceBlockSize); err != nil {
+		return err
+	}
+	if err := d.file.Sync(); err != nil {
+		return err
+	}
+	d.sizeLogNext++
+	e.isDirty = false
+	return nil
+}
+
+// replaySizeLog applies the file sizes logged after the loaded table was persisted, and persists them in the table,
+// so the size log is started over without the blocks of this generation written before.
+func (d *BlockDevice) replaySizeLog() error {
+	if d.sizeLogBlocks == 0 {
+		return nil
+	}
+	data := alignedBuffer(int(d.sizeLogBlocks * blockDeviceBlockSize))
+	if _, err := d.file.ReadAt(data, d.sizeLogOffset); err != nil && err != io.EOF {
+		return err
+	}
+	replayed := 0
+	for i := int64(0); i < d.sizeLogBlocks; i++ {
+		block := data[i*blockDeviceBlockSize : (i+1)*blockDeviceBlockSize]
+		if util.BytesToUint64(block[0:8]) != d.generation || crc32.ChecksumIEEE(block[:blockDeviceBlockSize-4]) != util.BytesToUint32(block[blockDeviceBlockSize-4:]) {
+			continue
+		}
+		nameLength := int(util.BytesToUint16(block[24:26]))
+		if 26+nameLength > blockDeviceBlockSize-4 {
+			continue
+		}
+		if e, found := d.files[string(block[26:26+nameLength])]; found {
+			e.size = int64(util.BytesToUint64(block[8:16]))
+			e.modTime = time.Unix(0, int64(util.BytesToUint64(block[16:24])))
+			replayed++
+		}
+	}
+	if replayed == 0 {
+		return nil
+	}
+	glog.V(0).Infof("block device %s: replayed %d file sizes", d.path, replayed)
+	return d.persistTable()
+}
+
+func (d *BlockDevice) CreateFile(name string) (*BlockDeviceFile, error) {
+	entries := d.lockFileEntries(name)
+	defer d.unlockFileEntries(entries)
+	for _, old := range entries {
+		d.releaseEntry(old)
+	}
+	e := &blockDeviceFileEntry{name: name, modTime: time.Now(), tailBlockOffset: -1}
+	d.files[name] = e
+	if err := d.persistTable(); err != nil {
+		delete(d.files, name)
+		return nil, err
+	}
+	return &BlockDeviceFile{device: d, entry: e, name: filepath.Join(d.dir, name)}, nil
+}
+
+func (d *BlockDevice) OpenFile(name string) (*BlockDeviceFile, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	e, found := d.files[name]
+	if !found {
+		return nil, &os.PathError{Op: "open", Path: filepath.Join(d.dir, name), Err: os.ErrNotExist}
+	}
+	return &BlockDeviceFile{device: d, entry: e, name: filepath.Join(d.dir, name)}, nil
+}
+
+func (d *BlockDevice) HasFile(name string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	_, found := d.files[name]
+	return found
+}
+
+// FileNames lists the files on the device, sorted by name
+func (d *BlockDevice) FileNames() (names []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for name := range d.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Rename replaces the file newName if it exists. The opened files keep working with the renamed file.
+func (d *BlockDevice) Rename(oldName, newName string) error {
+	if oldName == newName {
+		return nil
+	}
+	entries := d.lockFileEntries(oldName, newName)
+	defer d.unlockFileEntries(entries)
+	e, found := d.files[oldName]
+	if !found {
+		return &os.PathError{Op: "rename", Path: filepath.Join(d.dir, oldName), Err: os.ErrNotExist}
+	}
+	if old, found := d.files[newName]; found {
+		d.releaseEntry(old)
+	}
+	delete(d.files, oldName)
+	e.name = newName
+	d.files[newName] = e
+	return d.persistTable()
+}
+
+func (d *BlockDevice) Remove(name string) error {
+	entries := d.lockFileEntries(name)
+	defer d.unlockFileEntries(entries)
+	if len(entries) == 0 {
+		return &os.PathError{Op: "remove", Path: filepath.Join(d.dir, name), Err: os.ErrNotExist}
+	}
+	d.releaseEntry(entries[0])
+	return d.persistTable()
+}
+
+// lockFileEntries locks the existing files of the names, and then the device.
+// The file IO locks the file before the device, so the files are locked in the same order here.
+func (d *BlockDevice) lockFileEntries(names ...string) (entries []*blockDeviceFileEntry) {
+	sort.Strings(names)
+	for {
+		d.lock.Lock()
+		entries = entries[:0]
+		for _, name := range names {
+			if e, found := d.files[name]; found {
+				entries = append(entries, e)
+			}
+		}
+		d.lock.Unlock()
+
+		for _, e := range entries {
+			e.Lock()
+		}
+		d.lock.Lock()
+		isChanged := false
+		for _, e := range entries {
+			isChanged = isChanged || e.isRemoved || !slices.Contains(names, e.name)
+		}
+		for _, name := range names {
+			if e, found := d.files[name]; found && !containsFileEntry(entries, e) {
+				isChanged = true
+			}
+		}
+		if !isChanged {
+			return entries
+		}
+		d.unlockFileEntries(entries)
+	}
+}
+
+func (d *BlockDevice) unlockFileEntries(entries []*blockDeviceFileEntry) {
+	d.lock.Unlock()
+	for _, e := range entries {
+		e.Unlock()
+	}
+}
+
+func containsFileEntry(entries []*blockDeviceFileEntry, e *blockDeviceFileEntry) bool {
+	for _, x := range entries {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseEntry frees the extents of the file, and fails the IO of its opened files.
+// It is called with the file and the device locked.
+func (d *BlockDevice) releaseEntry(e *blockDeviceFileEntry) {
+	delete(d.files, e.name)
+	e.isRemoved = true
+	for _, extent := range e.extents {
+		if extent != noExtent {
+			d.freeExtents = append(d.freeExtents, extent)
+		}
+	}
+	e.extents = nil
+}
+
+// truncate frees the extents beyond the size. It is called with the file locked.
+func (d *BlockDevice) truncate(e *blockDeviceFileEntry, size int64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	extentCount := int(alignUp(size, d.extentSize) / d.extentSize)
+	for i := extentCount; i < len(e.extents); i++ {
+		if e.extents[i] != noExtent {
+			d.freeExtents = append(d.freeExtents, e.extents[i])
+		}
+	}
+	if extentCount < len(e.extents) {
+		e.extents = e.extents[:extentCount]
+	}
+	e.size = size
+	e.modTime = time.Now()
+	return d.persistTable()
+}
+
+// sync flushes the written data, and persists the file size if it has changed. It is called with the file locked.
+func (d *BlockDevice) sync(e *blockDeviceFileEntry) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.isClosed {
+		return os.ErrClosed
+	}
+	if e.isDirty {
+		if d.sizeLogBlocks == 0 {
+			return d.persistTable()
+		}
+		return d.appendSizeLog(e)
+	}
+	return d.file.Sync()
+}
+
+// Space reports the data area of the device and its unallocated part
+func (d *BlockDevice) Space() (all, free uint64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return uint64(d.extentCount) * uint64(d.extentSize), uint64(len(d.freeExtents)) * uint64(d.extentSize)
+}
+
+func (d *BlockDevice) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.isClosed {
+		return nil
+	}
+	err := d.persistTable()
+	if closeErr := d.file.Close(); err == nil {
+		err = closeErr
+	}
+	d.isClosed = true
+	return err
+}
+
+// allocateExtents makes sure the extents of the file range are allocated.
+// It is called with the file entry locked.
+func (d *BlockDevice) allocateExtents(e *blockDeviceFileEntry, start, stop int64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	allocated := false
+	for i := start / d.extentSize; i*d.extentSize < stop; i++ {
+		for int64(len(e.extents)) <= i {
+			e.extents = append(e.extents, noExtent)
+		}
+		if e.extents[i] != noExtent {
+			continue
+		}
+		if len(d.freeExtents) == 0 {
+			if allocated {
+				d.persistTable()
+			}
+			return fmt.Errorf("block device %s is full", d.path)
+		}
+		e.extents[i] = d.freeExtents[len(d.freeExtents)-1]
+		d.freeExtents = d.freeExtents[:len(d.freeExtents)-1]
+		allocated = true
+	}
+	if !allocated {
+		return nil
+	}
+	return d.persistTable()
+}
+
+// doIO reads or writes the aligned range of the file, extent by extent. Unallocated extents are read as zeros.
+func (d *BlockDevice) doIO(e *blockDeviceFileEntry, buf []byte, offset int64, isWrite bool) error {
+	for len(buf) > 0 {
+		extentIndex, offsetInExtent := offset/d.extentSize, offset%d.extentSize
+		length := d.extentSize - offsetInExtent
+		if length > int64(len(buf)) {
+			length = int64(len(buf))
+		}
+		extent := uint32(noExtent)
+		if extentIndex < int64(len(e.extents)) {
+			extent = e.extents[extentIndex]
+		}
+		deviceOffset := d.dataOffset + int64(extent)*d.extentSize + offsetInExtent
+		var err error
+		switch {
+		case isWrite && extent == noExtent:
+			err = fmt.Errorf("write to unallocated extent %d of %s", extentIndex, e.name)
+		case isWrite:
+			_, err = d.file.WriteAt(buf[:length], deviceOffset)
+		case extent == noExtent:
+			clear(buf[:length])
+		default:
+			_, err = d.file.ReadAt(buf[:length], deviceOffset)
+		}
+		if err != nil {
+			return err
+		}
+		buf, offset = buf[length:], offset+length
+	}
+	return nil
+}
+
+func alignUp(x, alignment int64) int64 {
+	return (x + alignment - 1) / alignment * alignment
+}
+
+func alignDown(x, alignment int64) int64 {
+	return x / alignment * alignment
+}
+
+// alignedBuffer allocates a buffer starting at a block boundary in memory, as required by O_DIRECT
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+blockDeviceBlockSize)
+	shift := int(uintptr(unsafe.Pointer(&buf[0])) & (blockDeviceBlockSize - 1))
+	if shift != 0 {
+		shift = blockDeviceBlockSize - shift
+	}
+	return buf[shift : shift+size : shift+size]
+}
@@ -0,0 +1,191 @@
+package backend
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	_ BackendStorageFile = &BlockDeviceFile{}
+)
+
+// BlockDeviceFile is an opened file on a block device. The unaligned IO is done with read-modify-write of the blocks.
+type BlockDeviceFile struct {
+	device   *BlockDevice
+	entry    *blockDeviceFileEntry
+	name     string
+	isClosed bool
+}
+
+func (f *BlockDeviceFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if f.isClosed {
+		return 0, os.ErrClosed
+	}
+	e := f.entry
+	e.RLock()
+	defer e.RUnlock()
+	if e.isRemoved {
+		return 0, os.ErrNotExist
+	}
+	if off >= e.size {
+		return 0, io.EOF
+	}
+	stop := off + int64(len(p))
+	if stop > e.size {
+		stop = e.size
+		err = io.EOF
+	}
+	alignedStart, alignedStop := alignDown(off, blockDeviceBlockSize), alignUp(stop, blockDeviceBlockSize)
+	buf := alignedBuffer(int(alignedStop - alignedStart))
+	if ioErr := f.device.doIO(e, buf, alignedStart, false); ioErr != nil {
+		return 0, ioErr
+	}
+	n = copy(p, buf[off-alignedStart:stop-alignedStart])
+	return n, err
+}
+
+func (f *BlockDeviceFile) WriteAt(p []byte, off int64) (n int, err error) {
+	if f.isClosed {
+		return 0, os.ErrClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	e := f.entry
+	e.Lock()
+	defer e.Unlock()
+	if e.isRemoved {
+		return 0, os.ErrNotExist
+	}
+
+	stop := off + int64(len(p))
+	alignedStart, alignedStop := alignDown(off, blockDeviceBlockSize), alignUp(stop, blockDeviceBlockSize)
+	if err = f.device.allocateExtents(e, alignedStart, alignedStop); err != nil {
+		return 0, err
+	}
+	buf := alignedBuffer(int(alignedStop - alignedStart))
+	if off != alignedStart {
+		if err = f.readBlock(buf[:blockDeviceBlockSize], alignedStart); err != nil {
+			return 0, err
+		}
+	}
+	lastBlockOffset := alignedStop - blockDeviceBlockSize
+	if stop != alignedStop && (lastBlockOffset != alignedStart || off == alignedStart) {
+		if err = f.readBlock(buf[lastBlockOffset-alignedStart:], lastBlockOffset); err != nil {
+			return 0, err
+		}
+	}
+	copy(buf[off-alignedStart:], p)
+	if err = f.device.doIO(e, buf, alignedStart, true); err != nil {
+		return 0, err
+	}
+
+	f.device.lock.Lock()
+	if stop > e.size {
+		e.size = stop
+	}
+	e.modTime = time.Now()
+	e.isDirty = true
+	f.device.lock.Unlock()
+
+	if e.tailBlockOffset >= alignedStart && e.tailBlockOffset < alignedStop {
+		e.tailBlockOffset = -1
+	}
+	if tailBlockOffset := alignDown(e.size, blockDeviceBlockSize); e.size != tailBlockOffset && tailBlockOffset >= alignedStart && tailBlockOffset < alignedStop {
+		if e.tailBlock == nil {
+			e.tailBlock = make([]byte, blockDeviceBlockSize)
+		}
+		copy(e.tailBlock, buf[tailBlockOffset-alignedStart:])
+		e.tailBlockOffset = tailBlockOffset
+	}
+	return len(p), nil
+}
+
+// readBlock reads the current content of one block, to be partially overwritten. It is called with the entry locked.
+func (f *BlockDeviceFile) readBlock(block []byte, offset int64) error {
+	e := f.entry
+	if offset == e.tailBlockOffset {
+		copy(block, e.tailBlock)
+		return nil
+	}
+	if offset >= e.size {
+		clear(block)
+		return nil
+	}
+	return f.device.doIO(e, block, offset, false)
+}
+
+func (f *BlockDeviceFile) Truncate(off int64) error {
+	if f.isClosed {
+		return os.ErrClosed
+	}
+	e := f.entry
+	e.Lock()
+	defer e.Unlock()
+	if e.isRemoved {
+		return os.ErrNotExist
+	}
+	if off < e.size && off%blockDeviceBlockSize != 0 {
+		// keep the rest of the last block as zeros, in case the file is extended again
+		tailBlockOffset := alignDown(off, blockDeviceBlockSize)
+		block := alignedBuffer(blockDeviceBlockSize)
+		if err := f.readBlock(block, tailBlockOffset); err != nil {
+			return err
+		}
+		clear(block[off-tailBlockOffset:])
+		if err := f.device.doIO(e, block, tailBlockOffset, true); err != nil {
+			return err
+		}
+		if e.tailBlock == nil {
+			e.tailBlock = make([]byte, blockDeviceBlockSize)
+		}
+		copy(e.tailBlock, block)
+		e.tailBlockOffset = tailBlockOffset
+	} else if e.tailBlockOffset >= off {
+		e.tailBlockOffset = -1
+	}
+	return f.device.truncate(e, off)
+}
+
+func (f *BlockDeviceFile) Close() error {
+	if f.isClosed {
+		return nil
+	}
+	err := f.Sync()
+	f.isClosed = true
+	if err == os.ErrNotExist {
+		return nil
+	}
+	return err
+}
+
+func (f *BlockDeviceFile) GetStat() (datSize int64, modTime time.Time, err error) {
+	if f.isClosed {
+		return 0, time.Time{}, os.ErrClosed
+	}
+	e := f.entry
+	e.RLock()
+	defer e.RUnlock()
+	if e.isRemoved {
+		err = os.ErrNotExist
+	}
+	return e.size, e.modTime, err
+}
+
+func (f *BlockDeviceFile) Name() string {
+	return f.name
+}
+
+func (f *BlockDeviceFile) Sync() error {
+	if f.isClosed {
+		return os.ErrClosed
+	}
+	e := f.entry
+	e.RLock()
+	defer e.RUnlock()
+	if e.isRemoved {
+		return os.ErrNotExist
+	}
+	return f.device.sync(e)
+}
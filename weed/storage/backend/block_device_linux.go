@@ -0,0 +1,34 @@
+//go:build linux
+// +build linux
+
+package backend
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openBlockDeviceFile opens the device with O_DIRECT, or without it on the file systems not supporting it, e.g. tmpfs.
+// A regular file smaller than the size is extended with preallocated space.
+func openBlockDeviceFile(devicePath string, size int64) (*os.File, error) {
+	file, err := os.OpenFile(devicePath, os.O_RDWR|os.O_CREATE|syscall.O_DIRECT, 0644)
+	if errors.Is(err, syscall.EINVAL) {
+		file, err = os.OpenFile(devicePath, os.O_RDWR|os.O_CREATE, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		if stat, statErr := file.Stat(); statErr == nil && stat.Mode().IsRegular() && stat.Size() < size {
+			if err = syscall.Fallocate(int(file.Fd()), 0, 0, size); err != nil {
+				err = file.Truncate(size)
+			}
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+	}
+	return file, nil
+}
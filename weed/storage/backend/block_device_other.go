@@ -0,0 +1,26 @@
+//go:build !linux
+// +build !linux
+
+package backend
+
+import (
+	"os"
+)
+
+// openBlockDeviceFile opens the device, without O_DIRECT which is only used on linux.
+// A regular file smaller than the size is extended.
+func openBlockDeviceFile(devicePath string, size int64) (*os.File, error) {
+	file, err := os.OpenFile(devicePath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		if stat, statErr := file.Stat(); statErr == nil && stat.Mode().IsRegular() && stat.Size() < size {
+			if err = file.Truncate(size); err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+	}
+	return file, nil
+}
@@ -0,0 +1,218 @@
+package backend
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testExtentSize = 64 * 1024
+
+func openTestBlockDevice(t *testing.T, devicePath string) *BlockDevice {
+	d, err := OpenBlockDevice(devicePath, 8*1024*1024, testExtentSize, true)
+	if err != nil {
+		t.Fatalf("open block device: %v", err)
+	}
+	return d
+}
+
+func checkBlockDeviceFile(t *testing.T, f *BlockDeviceFile, expected []byte) {
+	size, _, err := f.GetStat()
+	if err != nil || size != int64(len(expected)) {
+		t.Fatalf("file size %d, expected %d: %v", size, len(expected), err)
+	}
+	actual := make([]byte, len(expected))
+	if n, err := f.ReadAt(actual, 0); n != len(expected) || err != nil && err != io.EOF {
+		t.Fatalf("read %d bytes: %v", n, err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("file content mismatch")
+	}
+	for i := 0; i < 100; i++ {
+		offset := rand.Int63n(int64(len(expected)))
+		length := rand.Int63n(3 * testExtentSize)
+		buf := make([]byte, length)
+		n, err := f.ReadAt(buf, offset)
+		if offset+length > int64(len(expected)) {
+			if err != io.EOF || int64(n) != int64(len(expected))-offset {
+				t.Fatalf("read %d bytes at %d: %d %v", length, offset, n, err)
+			}
+		} else if err != nil || int64(n) != length {
+			t.Fatalf("read %d bytes at %d: %d %v", length, offset, n, err)
+		}
+		if !bytes.Equal(buf[:n], expected[offset:offset+int64(n)]) {
+			t.Fatalf("read %d bytes at %d: content mismatch", length, offset)
+		}
+	}
+}
+
+func TestBlockDeviceFile(t *testing.T) {
+	devicePath := filepath.Join(t.TempDir(), "volumes.img")
+	d := openTestBlockDevice(t, devicePath)
+	all, free := d.Space()
+	if all == 0 || all != free || all%testExtentSize != 0 {
+		t.Fatalf("unexpected space %d %d", all, free)
+	}
+
+	f, err := d.CreateFile("1.dat")
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	var expected []byte
+	for _, length := range []int{8, 1000, 3, testExtentSize + 5, 4096, 7, 3 * testExtentSize} {
+		data := make([]byte, length)
+		rand.Read(data)
+		if _, err = f.WriteAt(data, int64(len(expected))); err != nil {
+			t.Fatalf("append %d bytes: %v", length, err)
+		}
+		expected = append(expected, data...)
+	}
+	// overwrite across the extent boundary
+	data := make([]byte, 10000)
+	rand.Read(data)
+	if _, err = f.WriteAt(data, testExtentSize-5000); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	copy(expected[testExtentSize-5000:], data)
+	checkBlockDeviceFile(t, f, expected)
+
+	if err = f.Truncate(2*testExtentSize + 100); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	expected = expected[:2*testExtentSize+100]
+	checkBlockDeviceFile(t, f, expected)
+	if _, free = d.Space(); free != all-3*testExtentSize {
+		t.Errorf("free space %d after truncation, expected %d", free, all-3*testExtentSize)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("close file: %v", err)
+	}
+	if err = d.Close(); err != nil {
+		t.Fatalf("close device: %v", err)
+	}
+
+	// the files and their sizes are loaded from the allocation table
+	d = openTestBlockDevice(t, devicePath)
+	f, err = d.OpenFile("1.dat")
+	if err != nil {
+		t.Fatalf("open file: %v", err)
+	}
+	checkBlockDeviceFile(t, f, expected)
+
+	if err = d.Rename("1.dat", "2.dat"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if d.HasFile("1.dat") || !d.HasFile("2.dat") {
+		t.Fatalf("unexpected files after rename: %v", d.FileNames())
+	}
+	checkBlockDeviceFile(t, f, expected)
+	if err = d.Remove("2.dat"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err = f.ReadAt(make([]byte, 1), 0); err == nil {
+		t.Errorf("read removed file")
+	}
+	if _, free = d.Space(); free != all {
+		t.Errorf("free space %d after removal, expected %d", free, all)
+	}
+	if _, err = d.OpenFile("2.dat"); !os.IsNotExist(err) {
+		t.Errorf("open removed file: %v", err)
+	}
+	d.Close()
+}
+
+func TestBlockDeviceTornAllocationTable(t *testing.T) {
+	devicePath := filepath.Join(t.TempDir(), "volumes.img")
+	d := openTestBlockDevice(t, devicePath)
+	for _, name := range []string{"1.dat", "2.dat"} {
+		f, err := d.CreateFile(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if _, err = f.WriteAt([]byte("data"), 0); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		f.Close()
+	}
+	slotOffset := d.tableOffset + int64(d.generation%2)*d.tableSlotSize
+	d.Close()
+
+	// corrupt the last written allocation table
+	file, err := os.OpenFile(devicePath, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("open device file: %v", err)
+	}
+	if _, err = file.WriteAt([]byte("torn"), slotOffset+blockDeviceTableHeaderSize); err != nil {
+		t.Fatalf("corrupt allocation table: %v", err)
+	}
+	file.Close()
+
+	d = openTestBlockDevice(t, devicePath)
+	defer d.Close()
+	if !d.HasFile("1.dat") || !d.HasFile("2.dat") {
+		t.Errorf("files are lost with the previous allocation table: %v", d.FileNames())
+	}
+}
+
+func TestBlockDeviceNotFormatted(t *testing.T) {
+	devicePath := filepath.Join(t.TempDir(), "volumes.img")
+	if err := os.WriteFile(devicePath, bytes.Repeat([]byte("x"), 8*1024*1024), 0644); err != nil {
+		t.Fatalf("write device file: %v", err)
+	}
+	if _, err := OpenBlockDevice(devicePath, 0, testExtentSize, true); err == nil {
+		t.Errorf("formatted a device with unknown data")
+	}
+
+	emptyDevicePath := filepath.Join(t.TempDir(), "empty.img")
+	if _, err := OpenBlockDevice(emptyDevicePath, 8*1024*1024, testExtentSize, false); err == nil {
+		t.Errorf("formatted an empty device without the format option")
+	}
+}
+
+func TestBlockDeviceSizeLog(t *testing.T) {
+	devicePath := filepath.Join(t.TempDir(), "volumes.img")
+	d := openTestBlockDevice(t, devicePath)
+	f, err := d.CreateFile("1.dat")
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	if _, err = f.WriteAt(make([]byte, 100), 0); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	generation := d.generation
+
+	// appending within the allocated extent only logs the sizes, until the size log is full
+	var expected []byte
+	for i := 0; i < blockDeviceSizeLogBlocks+10; i++ {
+		data := make([]byte, 7)
+		rand.Read(data)
+		if _, err = f.WriteAt(data, int64(len(expected))); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		expected = append(expected, data...)
+		if err = f.Sync(); err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+		if i == blockDeviceSizeLogBlocks-1 && d.generation != generation {
+			t.Fatalf("allocation table persisted %d times by syncing", d.generation-generation)
+		}
+	}
+	if d.generation != generation+1 {
+		t.Errorf("allocation table persisted %d times after the size log is full", d.generation-generation)
+	}
+
+	// crash without persisting the allocation table
+	d.file.Close()
+	d = openTestBlockDevice(t, devicePath)
+	defer d.Close()
+	if f, err = d.OpenFile("1.dat"); err != nil {
+		t.Fatalf("open file: %v", err)
+	}
+	checkBlockDeviceFile(t, f, expected)
+	if d.sizeLogNext != 0 {
+		t.Errorf("size log is not started over after replaying")
+	}
+}
@@ -43,14 +43,14 @@ func (df *DiskFile) ReadAt(p []byte, off int64) (n int, err error) {
 	if df.File == nil {
 		return 0, os.ErrClosed
 	}
-	return df.File.ReadAt(p, off)
+	return readFileAt(df.File, p, off)
 }
 
 func (df *DiskFile) WriteAt(p []byte, off int64) (n int, err error) {
 	if df.File == nil {
 		return 0, os.ErrClosed
 	}
-	n, err = df.File.WriteAt(p, off)
+	n, err = WriteFileAt(df.File, p, off)
 	if err == nil {
 		waterMark := off + int64(n)
 		if waterMark > df.fileSize {
@@ -116,5 +116,5 @@ func (df *DiskFile) Sync() error {
 	if isMac {
 		return nil
 	}
-	return df.File.Sync()
+	return syncFile(df.File)
 }
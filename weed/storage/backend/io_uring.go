@@ -0,0 +1,71 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+)
+
+// IoUringDefaultEntries is the submission queue size of the shared io_uring instance
+const IoUringDefaultEntries = 256
+
+var (
+	// the io_uring instance shared by all disk files, nil to use the regular system calls
+	sharedIoUring atomic.Pointer[ioUring]
+
+	// the io_uring instance is closed, so the regular system calls are used instead
+	errIoUringClosed = errors.New("io_uring is closed")
+)
+
+// EnableIoUring routes the disk file reads, writes and syncs through one shared io_uring instance,
+// so the concurrent requests are submitted to the kernel in batches.
+// It fails if the kernel does not support io_uring, and the regular system calls are still used.
+func EnableIoUring(entries uint32) error {
+	r, err := newIoUring(entries)
+	if err != nil {
+		return err
+	}
+	if old := sharedIoUring.Swap(r); old != nil {
+		old.close()
+	}
+	return nil
+}
+
+// DisableIoUring switches the disk files back to the regular system calls
+func DisableIoUring() {
+	if old := sharedIoUring.Swap(nil); old != nil {
+		old.close()
+	}
+}
+
+func IoUringEnabled() bool {
+	return sharedIoUring.Load() != nil
+}
+
+// WriteFileAt writes to the file through io_uring if it is enabled, e.g. to append to the .idx files
+func WriteFileAt(file *os.File, p []byte, off int64) (n int, err error) {
+	if r := sharedIoUring.Load(); r != nil {
+		if n, err = r.writeAt(file, p, off); err != errIoUringClosed {
+			return n, err
+		}
+	}
+	return file.WriteAt(p, off)
+}
+
+func readFileAt(file *os.File, p []byte, off int64) (n int, err error) {
+	if r := sharedIoUring.Load(); r != nil {
+		if n, err = r.readAt(file, p, off); err != errIoUringClosed {
+			return n, err
+		}
+	}
+	return file.ReadAt(p, off)
+}
+
+func syncFile(file *os.File) error {
+	if r := sharedIoUring.Load(); r != nil {
+		if err := r.fsync(file); err != errIoUringClosed {
+			return err
+		}
+	}
+	return file.Sync()
+}
@@ -0,0 +1,449 @@
+//go:build linux
+// +build linux
+
+package backend
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"golang.org/x/sys/unix"
+)
+
+// the io_uring ABI, from include/uapi/linux/io_uring.h
+const (
+	ioUringOpNop   = 0
+	ioUringOpFsync = 3
+	ioUringOpRead  = 22 // since linux 5.6
+	ioUringOpWrite = 23
+
+	ioUringEnterGetEvents = 1
+
+	ioUringOffSqRing = 0
+	ioUringOffCqRing = 0x8000000
+	ioUringOffSqes   = 0x10000000
+)
+
+type ioUringSqringOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type ioUringCqringOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type ioUringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCpu, sqThreadIdle, features, wqFd uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  ioUringSqringOffsets
+	cqOff                                                                  ioUringCqringOffsets
+}
+
+type ioUringSqe struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	opFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	addr3       uint64
+	_           uint64
+}
+
+type ioUringCqe struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+type ioUringRequest struct {
+	opcode uint8
+	fd     int32
+	buf    []byte
+	offset int64
+	res    int32
+	done   chan struct{}
+}
+
+var ioUringRequestPool = sync.Pool{
+	New: func() interface{} {
+		return &ioUringRequest{done: make(chan struct{}, 1)}
+	},
+}
+
+// the user data of the no-op submitted last, to wake up the reaper when the io_uring instance is closed
+const ioUringWakeupUserData = ^uint64(0)
+
+// ioUring is one io_uring instance. The requests from all goroutines are queued to the submitter,
+// which submits all queued requests with one system call without waiting for their completion.
+// The reaper waits for the completions, and wakes up the goroutines as their requests complete,
+// so a slow request, e.g. an fsync, does not hold back the requests queued after it.
+type ioUring struct {
+	fd                   int
+	sqRing, cqRing, sqes []byte
+
+	sqHead, sqTail, sqMask, sqArray *uint32
+	sqEntries                       uint32
+	cqHead, cqTail, cqMask          *uint32
+	cqes                            []ioUringCqe
+	sqeSlice                        []ioUringSqe
+
+	requests     chan *ioUringRequest
+	freeSlots    chan uint64 // the user data of the submissions, bounding the inflight requests by the completion queue
+	inflightLock sync.Mutex
+	inflight     []*ioUringRequest // by the user data of the submissions
+	closeLock    sync.RWMutex
+	isClosed     bool
+	loops        sync.WaitGroup
+}
+
+func newIoUring(entries uint32) (*ioUring, error) {
+	var params ioUringParams
+	fd, _, errno := syscall.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&params)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %v", errno)
+	}
+	r := &ioUring{fd: int(fd)}
+	if err := r.mapRings(&params); err != nil {
+		r.unmap()
+		return nil, err
+	}
+	r.requests = make(chan *ioUringRequest, params.cqEntries)
+	// one completion is left for the wakeup no-op
+	slotCount := params.cqEntries - 1
+	r.inflight = make([]*ioUringRequest, slotCount)
+	r.freeSlots = make(chan uint64, slotCount)
+	for i := uint64(0); i < uint64(slotCount); i++ {
+		r.freeSlots <- i
+	}
+	r.loops.Add(2)
+	go r.submitLoop()
+	go r.reapLoop()
+
+	if err := r.probe(); err != nil {
+		r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *ioUring) mapRings(params *ioUringParams) (err error) {
+	sqRingSize := int(params.sqOff.array + params.sqEntries*4)
+	if r.sqRing, err = unix.Mmap(r.fd, ioUringOffSqRing, sqRingSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		return fmt.Errorf("mmap io_uring submission queue: %v", err)
+	}
+	cqRingSize := int(params.cqOff.cqes + params.cqEntries*uint32(unsafe.Sizeof(ioUringCqe{})))
+	if r.cqRing, err = unix.Mmap(r.fd, ioUringOffCqRing, cqRingSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		return fmt.Errorf("mmap io_uring completion queue: %v", err)
+	}
+	sqesSize := int(params.sqEntries * uint32(unsafe.Sizeof(ioUringSqe{})))
+	if r.sqes, err = unix.Mmap(r.fd, ioUringOffSqes, sqesSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE); err != nil {
+		return fmt.Errorf("mmap io_uring submission entries: %v", err)
+	}
+
+	r.sqHead = (*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.head]))
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.tail]))
+	r.sqMask = (*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.ringMask]))
+	r.sqArray = (*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.array]))
+	r.sqEntries = params.sqEntries
+	r.sqeSlice = unsafe.Slice((*ioUringSqe)(unsafe.Pointer(&r.sqes[0])), params.sqEntries)
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[params.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[params.cqOff.tail]))
+	r.cqMask = (*uint32)(unsafe.Pointer(&r.cqRing[params.cqOff.ringMask]))
+	r.cqes = unsafe.Slice((*ioUringCqe)(unsafe.Pointer(&r.cqRing[params.cqOff.cqes])), params.cqEntries)
+	return nil
+}
+
+func (r *ioUring) unmap() {
+	for _, m := range [][]byte{r.sqRing, r.cqRing, r.sqes} {
+		if m != nil {
+			unix.Munmap(m)
+		}
+	}
+	syscall.Close(r.fd)
+}
+
+// probe reads a temporary file, since the kernels before 5.6 support io_uring without the read and write operations
+func (r *ioUring) probe() error {
+	file, err := os.CreateTemp("", "io_uring_probe")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if _, err = file.Write([]byte("probe")); err != nil {
+		return err
+	}
+	buf := make([]byte, 5)
+	res, err := r.do(ioUringOpRead, file, buf, 0)
+	if err != nil {
+		return err
+	}
+	if res < 0 {
+		return fmt.Errorf("io_uring read: %v", syscall.Errno(-res))
+	}
+	if string(buf[:res]) != "probe" {
+		return fmt.Errorf("io_uring read %q", buf[:res])
+	}
+	return nil
+}
+
+func (r *ioUring) readAt(file *os.File, p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		res, err := r.do(ioUringOpRead, file, p[n:], off+int64(n))
+		if err == errIoUringClosed && n > 0 {
+			read, err := file.ReadAt(p[n:], off+int64(n))
+			return n + read, err
+		}
+		if err != nil {
+			return n, err
+		}
+		if res < 0 {
+			if syscall.Errno(-res) == syscall.EINTR || syscall.Errno(-res) == syscall.EAGAIN {
+				continue
+			}
+			return n, &os.PathError{Op: "read", Path: file.Name(), Err: syscall.Errno(-res)}
+		}
+		if res == 0 {
+			return n, io.EOF
+		}
+		n += int(res)
+	}
+	return n, nil
+}
+
+func (r *ioUring) writeAt(file *os.File, p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		res, err := r.do(ioUringOpWrite, file, p[n:], off+int64(n))
+		if err == errIoUringClosed && n > 0 {
+			written, err := file.WriteAt(p[n:], off+int64(n))
+			return n + written, err
+		}
+		if err != nil {
+			return n, err
+		}
+		if res < 0 {
+			if syscall.Errno(-res) == syscall.EINTR || syscall.Errno(-res) == syscall.EAGAIN {
+				continue
+			}
+			return n, &os.PathError{Op: "write", Path: file.Name(), Err: syscall.Errno(-res)}
+		}
+		n += int(res)
+	}
+	return n, nil
+}
+
+func (r *ioUring) fsync(file *os.File) error {
+	res, err := r.do(ioUringOpFsync, file, nil, 0)
+	if err != nil {
+		return err
+	}
+	if res < 0 {
+		return &os.PathError{Op: "sync", Path: file.Name(), Err: syscall.Errno(-res)}
+	}
+	return nil
+}
+
+// do runs one operation on the file, and returns errIoUringClosed if the io_uring instance is closed.
+// The file descriptor is referenced until the operation completes, so closing the file meanwhile
+// does not let the descriptor number be reused by another file.
+func (r *ioUring) do(opcode uint8, file *os.File, buf []byte, offset int64) (res int32, err error) {
+	rawConn, err := file.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	if controlErr := rawConn.Control(func(fd uintptr) {
+		res, err = r.submitAndWait(opcode, int32(fd), buf, offset)
+	}); controlErr != nil {
+		return 0, controlErr
+	}
+	return res, err
+}
+
+// submitAndWait queues one operation and waits for its result
+func (r *ioUring) submitAndWait(opcode uint8, fd int32, buf []byte, offset int64) (res int32, err error) {
+	req := ioUringRequestPool.Get().(*ioUringRequest)
+	req.opcode, req.fd, req.buf, req.offset = opcode, fd, buf, offset
+
+	r.closeLock.RLock()
+	if r.isClosed {
+		r.closeLock.RUnlock()
+		req.buf = nil
+		ioUringRequestPool.Put(req)
+		return 0, errIoUringClosed
+	}
+	r.requests <- req
+	r.closeLock.RUnlock()
+
+	<-req.done
+	res = req.res
+	// the buffer is used by the kernel until the request is done
+	runtime.KeepAlive(buf)
+	req.buf = nil
+	ioUringRequestPool.Put(req)
+	return res, nil
+}
+
+func (r *ioUring) close() {
+	r.closeLock.Lock()
+	if !r.isClosed {
+		r.isClosed = true
+		close(r.requests)
+	}
+	r.closeLock.Unlock()
+	r.loops.Wait()
+	r.unmap()
+}
+
+// submitLoop submits the queued requests in batches, as many as there are free slots and submission entries
+func (r *ioUring) submitLoop() {
+	defer r.loops.Done()
+	for {
+		// block for a request only if there is nothing to submit
+		req, ok := <-r.requests
+		if !ok {
+			break
+		}
+		r.prepare(req, <-r.freeSlots)
+		toSubmit, isClosed := uint32(1), false
+	collect:
+		for len(r.freeSlots) > 0 && *r.sqTail-atomic.LoadUint32(r.sqHead) < r.sqEntries {
+			select {
+			case req, ok := <-r.requests:
+				if !ok {
+					isClosed = true
+					break collect
+				}
+				r.prepare(req, <-r.freeSlots)
+				toSubmit++
+			default:
+				break collect
+			}
+		}
+		r.submit(toSubmit)
+		if isClosed {
+			break
+		}
+	}
+
+	// the reaper exits after the completion of the no-op, and of all the requests submitted before it
+	tail := *r.sqTail
+	index := tail & *r.sqMask
+	r.sqeSlice[index] = ioUringSqe{opcode: ioUringOpNop, userData: ioUringWakeupUserData}
+	*(*uint32)(unsafe.Add(unsafe.Pointer(r.sqArray), 4*uintptr(index))) = index
+	atomic.StoreUint32(r.sqTail, tail+1)
+	r.submit(1)
+}
+
+// submit passes the prepared submissions to the kernel, without waiting for their completion
+func (r *ioUring) submit(toSubmit uint32) {
+	for toSubmit > 0 {
+		submitted, _, errno := syscall.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), uintptr(toSubmit), 0, 0, 0, 0)
+		switch errno {
+		case 0:
+			toSubmit -= uint32(submitted)
+		case syscall.EINTR:
+		case syscall.EAGAIN, syscall.EBUSY:
+			// the completion queue is full, wait for the reaper
+			time.Sleep(time.Millisecond)
+		default:
+			r.failUnsubmitted(errno)
+			return
+		}
+	}
+}
+
+// reapLoop waits for the completions, until the wakeup no-op completes with no requests in flight
+func (r *ioUring) reapLoop() {
+	defer r.loops.Done()
+	isClosed := false
+	for {
+		_, _, errno := syscall.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), 0, 1, ioUringEnterGetEvents, 0, 0)
+		if errno != 0 && errno != syscall.EINTR && errno != syscall.EAGAIN && errno != syscall.EBUSY {
+			glog.Errorf("io_uring wait for completions: %v", errno)
+			time.Sleep(time.Millisecond)
+		}
+		if r.reap() {
+			isClosed = true
+		}
+		if isClosed && len(r.freeSlots) == cap(r.freeSlots) {
+			return
+		}
+	}
+}
+
+func (r *ioUring) prepare(req *ioUringRequest, slot uint64) {
+	tail := *r.sqTail
+	index := tail & *r.sqMask
+	sqe := &r.sqeSlice[index]
+	*sqe = ioUringSqe{
+		opcode:   req.opcode,
+		fd:       req.fd,
+		off:      uint64(req.offset),
+		userData: slot,
+	}
+	if len(req.buf) > 0 {
+		sqe.addr = uint64(uintptr(unsafe.Pointer(&req.buf[0])))
+		sqe.len = uint32(len(req.buf))
+	}
+	*(*uint32)(unsafe.Add(unsafe.Pointer(r.sqArray), 4*uintptr(index))) = index
+	atomic.StoreUint32(r.sqTail, tail+1)
+
+	// registered after the last use of the request by the submitter, since the reaper hands it back to its goroutine
+	r.inflightLock.Lock()
+	r.inflight[slot] = req
+	r.inflightLock.Unlock()
+}
+
+// reap wakes up the goroutines of the completed requests, and tells whether the wakeup no-op completed
+func (r *ioUring) reap() (isWakeup bool) {
+	head := atomic.LoadUint32(r.cqHead)
+	tail := atomic.LoadUint32(r.cqTail)
+	for ; head != tail; head++ {
+		cqe := r.cqes[head&*r.cqMask]
+		if cqe.userData == ioUringWakeupUserData {
+			isWakeup = true
+			continue
+		}
+		r.complete(cqe.userData, cqe.res)
+	}
+	atomic.StoreUint32(r.cqHead, head)
+	return
+}
+
+// failUnsubmitted takes back the submissions not consumed by the kernel, and fails them
+func (r *ioUring) failUnsubmitted(errno syscall.Errno) {
+	head, tail := atomic.LoadUint32(r.sqHead), *r.sqTail
+	for i := head; i != tail; i++ {
+		if userData := r.sqeSlice[i&*r.sqMask].userData; userData != ioUringWakeupUserData {
+			r.complete(userData, -int32(errno))
+		}
+	}
+	atomic.StoreUint32(r.sqTail, head)
+}
+
+func (r *ioUring) complete(slot uint64, res int32) {
+	r.inflightLock.Lock()
+	req := r.inflight[slot]
+	r.inflight[slot] = nil
+	r.inflightLock.Unlock()
+	req.res = res
+	req.done <- struct{}{}
+	r.freeSlots <- slot
+}
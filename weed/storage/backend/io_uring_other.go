@@ -0,0 +1,30 @@
+//go:build !linux
+// +build !linux
+
+package backend
+
+import (
+	"fmt"
+	"os"
+)
+
+type ioUring struct{}
+
+func newIoUring(entries uint32) (*ioUring, error) {
+	return nil, fmt.Errorf("io_uring is only supported on linux")
+}
+
+func (r *ioUring) readAt(file *os.File, p []byte, off int64) (n int, err error) {
+	return 0, errIoUringClosed
+}
+
+func (r *ioUring) writeAt(file *os.File, p []byte, off int64) (n int, err error) {
+	return 0, errIoUringClosed
+}
+
+func (r *ioUring) fsync(file *os.File) error {
+	return errIoUringClosed
+}
+
+func (r *ioUring) close() {
+}
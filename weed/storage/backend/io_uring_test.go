@@ -0,0 +1,123 @@
+package backend
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIoUringDiskFile(t *testing.T) {
+	if err := EnableIoUring(8); err != nil {
+		t.Skipf("io_uring is not supported: %v", err)
+	}
+	defer DisableIoUring()
+
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "1.dat"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+
+	// more concurrent requests than the queue size
+	const count, size = 100, 1000
+	expected := make([]byte, count*size)
+	rand.Read(expected)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if n, err := WriteFileAt(file, expected[i*size:(i+1)*size], int64(i*size)); n != size || err != nil {
+				t.Errorf("write %d: %d %v", i, n, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	df := NewDiskFile(file)
+	defer df.Close()
+	if err = df.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			buf := make([]byte, size)
+			if n, err := df.ReadAt(buf, int64(i*size)); n != size || err != nil {
+				t.Errorf("read %d: %d %v", i, n, err)
+			}
+			if !bytes.Equal(buf, expected[i*size:(i+1)*size]) {
+				t.Errorf("read %d: content mismatch", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	buf := make([]byte, 2*size)
+	if n, err := df.ReadAt(buf, (count-1)*size); n != size || err != io.EOF {
+		t.Errorf("read beyond the end: %d %v", n, err)
+	}
+
+	// switching back to the system calls
+	DisableIoUring()
+	if n, err := df.ReadAt(buf[:size], 0); n != size || err != nil || !bytes.Equal(buf[:size], expected[:size]) {
+		t.Errorf("read after disabling io_uring: %d %v", n, err)
+	}
+}
+
+func TestIoUringPendingRequest(t *testing.T) {
+	if err := EnableIoUring(8); err != nil {
+		t.Skipf("io_uring is not supported: %v", err)
+	}
+	defer DisableIoUring()
+
+	// a read from an empty pipe stays in flight until the pipe is written
+	var fds [2]int
+	if err := syscall.Pipe(fds[:]); err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	pipeReader, pipeWriter := os.NewFile(uintptr(fds[0]), "pipe"), os.NewFile(uintptr(fds[1]), "pipe")
+	defer pipeReader.Close()
+	defer pipeWriter.Close()
+	pending := make(chan error, 1)
+	go func() {
+		_, err := readFileAt(pipeReader, make([]byte, 1), 0)
+		pending <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "1.dat"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	defer file.Close()
+	done := make(chan error, 1)
+	go func() {
+		if _, err := WriteFileAt(file, []byte("data"), 0); err != nil {
+			done <- err
+			return
+		}
+		done <- syncFile(file)
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatalf("write while a request is pending: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("write is held back by the pending request")
+	}
+
+	if _, err = pipeWriter.Write([]byte{1}); err != nil {
+		t.Fatalf("write pipe: %v", err)
+	}
+	if err = <-pending; err != nil {
+		t.Fatalf("read pipe: %v", err)
+	}
+}
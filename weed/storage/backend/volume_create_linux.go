@@ -11,6 +11,9 @@ import (
 )
 
 func CreateVolumeFile(fileName string, preallocate int64, memoryMapSizeMB uint32) (BackendStorageFile, error) {
+	if file, isOnBlockDevice, err := createBlockDeviceFile(fileName); isOnBlockDevice {
+		return file, err
+	}
 	file, e := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 	if e != nil {
 		return nil, e
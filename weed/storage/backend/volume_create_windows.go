@@ -12,6 +12,9 @@ import (
 )
 
 func CreateVolumeFile(fileName string, preallocate int64, memoryMapSizeMB uint32) (BackendStorageFile, error) {
+	if file, isOnBlockDevice, err := createBlockDeviceFile(fileName); isOnBlockDevice {
+		return file, err
+	}
 	if preallocate > 0 {
 		glog.V(0).Infof("Preallocated disk space for %s is not supported", fileName)
 	}
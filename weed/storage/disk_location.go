@@ -13,6 +13,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
@@ -237,6 +238,7 @@ func (l *DiskLocation) loadExistingVolumes(needleMapKind NeedleMapKind, ldbTimeo
 	}
 	l.concurrentLoadingVolumes(needleMapKind, workerNum, ldbTimeout)
 	glog.V(0).Infof("Store started on dir: %s with %d volumes max %d", l.Directory, len(l.volumes), l.MaxVolumeCount)
+	l.checkBlockDeviceFiles()
 
 	l.loadAllEcShards()
 	glog.V(0).Infof("Store started on dir: %s with %d ec shards", l.Directory, len(l.ecVolumes))
@@ -397,10 +399,37 @@ func (l *DiskLocation) Close() {
 	}
 	l.ecVolumesLock.Unlock()
 
+	if err := backend.DetachBlockDevice(l.Directory); err != nil {
+		glog.Errorf("close block device of %s: %v", l.Directory, err)
+	}
+
 	close(l.closeCh)
 	return
 }
 
+// checkBlockDeviceFiles removes the compaction files left on the block device of the directory,
+// and reports the volume data files not loaded as volumes
+func (l *DiskLocation) checkBlockDeviceFiles() {
+	device := backend.FindBlockDevice(l.Directory)
+	if device == nil {
+		return
+	}
+	for _, name := range device.FileNames() {
+		switch filepath.Ext(name) {
+		case ".cpd":
+			glog.V(0).Infof("remove leftover %s from the block device of %s", name, l.Directory)
+			if err := device.Remove(name); err != nil {
+				glog.Warningf("remove leftover %s from the block device of %s: %v", name, l.Directory, err)
+			}
+		case ".dat":
+			_, vid, err := parseCollectionVolumeId(strings.TrimSuffix(name, ".dat"))
+			if _, found := l.FindVolume(vid); err != nil || !found {
+				glog.Warningf("block device of %s has %s not loaded as a volume", l.Directory, name)
+			}
+		}
+	}
+}
+
 func (l *DiskLocation) LocateVolume(vid needle.VolumeId) (os.DirEntry, bool) {
 	// println("LocateVolume", vid, "on", l.Directory)
 	if dirEntries, err := os.ReadDir(l.Directory); err == nil {
@@ -89,6 +89,12 @@ func (l *DiskLocation) LoadEcShard(collection string, vid needle.VolumeId, shard
 		if err != nil {
 			return nil, fmt.Errorf("failed to create ec volume %d: %v", vid, err)
 		}
+		if len(ecVolume.WrappedDataKeys) > 0 {
+			if ecVolume.DataKeys, _, err = unwrapDataKeys(ecVolume.WrappedDataKeys); err != nil {
+				ecVolume.Close()
+				return nil, fmt.Errorf("ec volume %d: %v", vid, err)
+			}
+		}
 		l.ecVolumes[vid] = ecVolume
 	}
 	ecVolume.AddEcVolumeShard(ecVolumeShard)
@@ -218,6 +224,17 @@ func (l *DiskLocation) unmountEcVolumeByCollection(collectionName string) map[ne
 	return deltaVols
 }
 
+// EcShardSlotUnits is the part of the volume slots taken by the ec shards, in erasure_coding.VolumeSlotUnits
+func (l *DiskLocation) EcShardSlotUnits() (units int64) {
+	l.ecVolumesLock.RLock()
+	defer l.ecVolumesLock.RUnlock()
+
+	for _, ecVolume := range l.ecVolumes {
+		units += ecVolume.Scheme.ShardSlotUnits(len(ecVolume.Shards))
+	}
+	return
+}
+
 func (l *DiskLocation) EcShardCount() int {
 	l.ecVolumesLock.RLock()
 	defer l.ecVolumesLock.RUnlock()
@@ -150,34 +150,41 @@ func iterateEcjFile(baseFileName string, processNeedleFn func(key types.NeedleId
 
 }
 
-// WriteDatFile generates .dat from .ec00 ~ .ec09 files
-func WriteDatFile(baseFileName string, datFileSize int64, shardFileNames []string) error {
+// WriteDatFile generates .dat from the data shard files, .ec00 ~ .ec09 for the default scheme.
+// The .dat file is created on the block device of the directory if it has one.
+func WriteDatFile(baseFileName string, datFileSize int64, shardFileNames []string) (err error) {
+	dataShards := len(shardFileNames)
 
-	datFile, openErr := os.OpenFile(baseFileName+".dat", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	datBackend, openErr := backend.CreateVolumeFile(baseFileName+".dat", 0, 0)
 	if openErr != nil {
 		return fmt.Errorf("cannot write volume %s.dat: %v", baseFileName, openErr)
 	}
-	defer datFile.Close()
+	defer func() {
+		if closeErr := datBackend.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("close volume %s.dat: %v", baseFileName, closeErr)
+		}
+	}()
+	datFile := io.NewOffsetWriter(datBackend, 0)
 
-	inputFiles := make([]*os.File, DataShardsCount)
+	inputFiles := make([]*os.File, dataShards)
 
 	defer func() {
-		for shardId := 0; shardId < DataShardsCount; shardId++ {
+		for shardId := 0; shardId < dataShards; shardId++ {
 			if inputFiles[shardId] != nil {
 				inputFiles[shardId].Close()
 			}
 		}
 	}()
 
-	for shardId := 0; shardId < DataShardsCount; shardId++ {
+	for shardId := 0; shardId < dataShards; shardId++ {
 		inputFiles[shardId], openErr = os.OpenFile(shardFileNames[shardId], os.O_RDONLY, 0)
 		if openErr != nil {
 			return openErr
 		}
 	}
 
-	for datFileSize >= DataShardsCount*ErasureCodingLargeBlockSize {
-		for shardId := 0; shardId < DataShardsCount; shardId++ {
+	for datFileSize >= int64(dataShards)*ErasureCodingLargeBlockSize {
+		for shardId := 0; shardId < dataShards; shardId++ {
 			w, err := io.CopyN(datFile, inputFiles[shardId], ErasureCodingLargeBlockSize)
 			if w != ErasureCodingLargeBlockSize {
 				return fmt.Errorf("copy %s large block %d: %v", baseFileName, shardId, err)
@@ -187,7 +194,7 @@ func WriteDatFile(baseFileName string, datFileSize int64, shardFileNames []strin
 	}
 
 	for datFileSize > 0 {
-		for shardId := 0; shardId < DataShardsCount; shardId++ {
+		for shardId := 0; shardId < dataShards; shardId++ {
 			toRead := min(datFileSize, ErasureCodingSmallBlockSize)
 			w, err := io.CopyN(datFile, inputFiles[shardId], toRead)
 			if w != toRead {
@@ -0,0 +1,197 @@
+package erasure_coding
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
+)
+
+/*
+
+Steps to write an ec volume directly, without a replicated volume first
+1. client asks the master with AssignEc for a file id and a row aligned offset of the needle
+2.   master allocates the ec volume with VolumeEcShardsAllocate on the servers to hold the shards when needed
+3. client erasure codes the rows of the needle with EncodeRows
+4. client calls VolumeEcShardWrite to write each shard of the rows to its server
+5. client calls VolumeEcBlobCommit on each server to add the needle to its .ecx file
+
+Each needle starts at a new row of small blocks, so the parity of its rows does not depend on other needles,
+and the .dat size is limited to one large block per data shard, so the layout is the same as encoding
+a volume of that size. The reads are the same as the ec volumes encoded from replicated volumes.
+The .vif file marks the ec volume as written directly, and the heartbeats report the shard sizes, so a new leader
+continues writing after the rows written so far.
+
+*/
+
+// RowSize is the .dat size of one row of small blocks across the data shards
+func (s EcScheme) RowSize() int64 {
+	return int64(s.DataShards) * ErasureCodingSmallBlockSize
+}
+
+// RowsSize rounds up the size to whole rows
+func (s EcScheme) RowsSize(size int64) int64 {
+	rowSize := s.RowSize()
+	return (size + rowSize - 1) / rowSize * rowSize
+}
+
+// DirectWriteLimit is the largest .dat size of an ec volume written directly, which has only small blocks
+func (s EcScheme) DirectWriteLimit() int64 {
+	return int64(s.DataShards) * ErasureCodingLargeBlockSize
+}
+
+// EncodeRows pads the data to whole rows of small blocks, and computes the parity of each row.
+// The data written at a row aligned offset of the .dat file is in each shard file at offset/DataShards.
+func EncodeRows(scheme EcScheme, data []byte) (shards [][]byte, err error) {
+	enc, err := scheme.NewEncoder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encoder: %v", err)
+	}
+
+	rowCount := int(scheme.RowsSize(int64(len(data))) / scheme.RowSize())
+	shards = make([][]byte, scheme.TotalShards())
+	for i := range shards {
+		shards[i] = make([]byte, rowCount*ErasureCodingSmallBlockSize)
+	}
+
+	blocks := make([][]byte, scheme.TotalShards())
+	for row := 0; row < rowCount; row++ {
+		for i := range blocks {
+			blocks[i] = shards[i][row*ErasureCodingSmallBlockSize : (row+1)*ErasureCodingSmallBlockSize]
+		}
+		for i := 0; i < scheme.DataShards; i++ {
+			start := int64(row)*scheme.RowSize() + int64(i)*ErasureCodingSmallBlockSize
+			if start < int64(len(data)) {
+				copy(blocks[i], data[start:])
+			}
+		}
+		if err = enc.Encode(blocks); err != nil {
+			return nil, fmt.Errorf("encode row %d: %v", row, err)
+		}
+	}
+
+	return shards, nil
+}
+
+// TrimZeros drops the trailing zeros of the shard rows, which are not written but left as a sparse hole
+func TrimZeros(data []byte) []byte {
+	end := len(data)
+	for end > 0 && data[end-1] == 0 {
+		end--
+	}
+	return data[:end]
+}
+
+// WriteEmptyEcFiles creates the shard files of an ec volume written directly, with the super block in the first row,
+// and the empty .ecx file and the .vif file
+func WriteEmptyEcFiles(baseFileName, indexBaseFileName string, scheme EcScheme, version needle.Version, shardIds []ShardId) (err error) {
+	if err = scheme.Validate(); err != nil {
+		return err
+	}
+
+	superBlock := super_block.SuperBlock{
+		Version:          version,
+		ReplicaPlacement: &super_block.ReplicaPlacement{},
+		Ttl:              needle.EMPTY_TTL,
+	}
+	shards, err := EncodeRows(scheme, superBlock.Bytes())
+	if err != nil {
+		return err
+	}
+
+	var createdFileNames []string
+	defer func() {
+		if err != nil {
+			for _, fileName := range createdFileNames {
+				os.Remove(fileName)
+			}
+		}
+	}()
+
+	for _, shardId := range shardIds {
+		if int(shardId) >= scheme.TotalShards() {
+			return fmt.Errorf("ec shard %d is out of the scheme %s", shardId, scheme)
+		}
+		shardFileName := baseFileName + ToExt(int(shardId))
+		if err = writeEmptyEcShard(shardFileName, shards[shardId]); err != nil {
+			return err
+		}
+		createdFileNames = append(createdFileNames, shardFileName)
+	}
+
+	ecxFile, err := os.OpenFile(indexBaseFileName+".ecx", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("create %s.ecx: %v", indexBaseFileName, err)
+	}
+	ecxFile.Close()
+	createdFileNames = append(createdFileNames, indexBaseFileName+".ecx")
+
+	volumeInfo := &volume_server_pb.VolumeInfo{Version: uint32(version), EcDirectWrite: true}
+	if !scheme.IsDefault() {
+		volumeInfo.EcDataShards = uint32(scheme.DataShards)
+		volumeInfo.EcParityShards = uint32(scheme.ParityShards)
+	}
+	err = volume_info.SaveVolumeInfo(baseFileName+".vif", volumeInfo)
+	return err
+}
+
+func writeEmptyEcShard(fileName string, row []byte) error {
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("create ec shard %s: %v", fileName, err)
+	}
+	defer f.Close()
+	if _, err = f.Write(TrimZeros(row)); err != nil {
+		return fmt.Errorf("write ec shard %s: %v", fileName, err)
+	}
+	return f.Truncate(int64(len(row)))
+}
+
+// AddNeedleToEcx inserts the needle committed to an ec volume written directly into the sorted .ecx file.
+// The file ids are assigned in order, so the entry is mostly appended.
+func (ev *EcVolume) AddNeedleToEcx(needleId types.NeedleId, offset types.Offset, size types.Size) error {
+	ev.ecxFileAccessLock.Lock()
+	defer ev.ecxFileAccessLock.Unlock()
+
+	// find the first entry not less than the needle id
+	buf := make([]byte, types.NeedleMapEntrySize)
+	l, h := int64(0), ev.ecxFileSize/types.NeedleMapEntrySize
+	for l < h {
+		m := (l + h) / 2
+		if _, err := ev.ecxFile.ReadAt(buf, m*types.NeedleMapEntrySize); err != nil {
+			return fmt.Errorf("ecx file %d read at %d: %v", ev.ecxFileSize, m*types.NeedleMapEntrySize, err)
+		}
+		if key, _, _ := idx.IdxFileEntry(buf); key < needleId {
+			l = m + 1
+		} else {
+			h = m
+		}
+	}
+	position := l * types.NeedleMapEntrySize
+	entry := needle_map.ToBytes(needleId, offset, size)
+
+	tail := make([]byte, ev.ecxFileSize-position)
+	if _, err := ev.ecxFile.ReadAt(tail, position); err != nil {
+		return fmt.Errorf("ecx file %d read at %d: %v", ev.ecxFileSize, position, err)
+	}
+	if len(tail) > 0 {
+		if key, _, _ := idx.IdxFileEntry(tail); key == needleId {
+			// the needle is committed again by a retry
+			if _, err := ev.ecxFile.WriteAt(entry, position); err != nil {
+				return fmt.Errorf("ecx file write at %d: %v", position, err)
+			}
+			return nil
+		}
+	}
+	if _, err := ev.ecxFile.WriteAt(append(entry, tail...), position); err != nil {
+		return fmt.Errorf("ecx file write at %d: %v", position, err)
+	}
+	ev.ecxFileSize += types.NeedleMapEntrySize
+	return nil
+}
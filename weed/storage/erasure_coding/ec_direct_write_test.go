@@ -0,0 +1,124 @@
+package erasure_coding
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func TestDirectWriteEcVolume(t *testing.T) {
+	dir := t.TempDir()
+	scheme := NewEcScheme(4, 2)
+	vid := needle.VolumeId(7)
+
+	var shardIds []ShardId
+	for shardId := 0; shardId < scheme.TotalShards(); shardId++ {
+		shardIds = append(shardIds, ShardId(shardId))
+	}
+	baseFileName := EcShardFileName("", dir, int(vid))
+	if err := WriteEmptyEcFiles(baseFileName, baseFileName, scheme, needle.CurrentVersion, shardIds); err != nil {
+		t.Fatalf("write empty ec files: %v", err)
+	}
+	if err := WriteEmptyEcFiles(baseFileName, baseFileName, scheme, needle.CurrentVersion, shardIds); err == nil {
+		t.Fatalf("overwrite existing ec files")
+	}
+
+	ev, err := NewEcVolume(types.HardDriveType, dir, dir, "", vid)
+	if err != nil {
+		t.Fatalf("new ec volume: %v", err)
+	}
+	defer ev.Close()
+	if ev.Scheme != scheme || !ev.IsDirectWrite {
+		t.Fatalf("ec volume scheme %s direct write %v, expected %s", ev.Scheme, ev.IsDirectWrite, scheme)
+	}
+	for _, shardId := range shardIds {
+		shard, err := NewEcVolumeShard(types.HardDriveType, dir, "", vid, shardId)
+		if err != nil {
+			t.Fatalf("new ec shard %d: %v", shardId, err)
+		}
+		ev.AddEcVolumeShard(shard)
+	}
+
+	// the second needle spans several rows, and is committed before the first one
+	needles := []*needle.Needle{
+		{Id: 9, Cookie: 0x1234, Data: bytes.Repeat([]byte("a"), 100)},
+		{Id: 5, Cookie: 0x5678, Data: bytes.Repeat([]byte("bcd"), int(scheme.RowSize()))},
+	}
+	offset := scheme.RowSize()
+	var offsets []int64
+	for _, n := range needles {
+		n.Checksum = needle.NewCRC(n.Data)
+		blob, err := n.ToBytes(needle.CurrentVersion)
+		if err != nil {
+			t.Fatalf("needle to bytes: %v", err)
+		}
+		shards, err := EncodeRows(scheme, blob)
+		if err != nil {
+			t.Fatalf("encode rows: %v", err)
+		}
+		for shardId, data := range shards {
+			shard, _ := ev.FindEcVolumeShard(ShardId(shardId))
+			if err = shard.WriteRows(TrimZeros(data), offset/int64(scheme.DataShards), int64(len(data))); err != nil {
+				t.Fatalf("write rows of shard %d: %v", shardId, err)
+			}
+		}
+		offsets = append(offsets, offset)
+		offset += scheme.RowsSize(int64(len(blob)))
+	}
+	for i := len(needles) - 1; i >= 0; i-- {
+		if err := ev.AddNeedleToEcx(needles[i].Id, types.ToOffset(offsets[i]), needles[i].Size); err != nil {
+			t.Fatalf("add needle %s to ecx: %v", needles[i].Id, err)
+		}
+	}
+	// committing again does not add an entry
+	if err := ev.AddNeedleToEcx(needles[0].Id, types.ToOffset(offsets[0]), needles[0].Size); err != nil {
+		t.Fatalf("add needle %s to ecx again: %v", needles[0].Id, err)
+	}
+	if ev.ecxFileSize != int64(len(needles))*types.NeedleMapEntrySize {
+		t.Fatalf("ecx file size %d, expected %d entries", ev.ecxFileSize, len(needles))
+	}
+
+	for _, n := range needles {
+		_, size, intervals, err := ev.LocateEcShardNeedle(n.Id, ev.Version)
+		if err != nil {
+			t.Fatalf("locate needle %s: %v", n.Id, err)
+		}
+		var blob []byte
+		for _, interval := range intervals {
+			shardId, shardOffset := interval.ToShardIdAndOffset(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize)
+			shard, _ := ev.FindEcVolumeShard(shardId)
+			buf := make([]byte, interval.Size)
+			if _, err = shard.ReadAt(buf, shardOffset); err != nil {
+				t.Fatalf("read shard %d at %d: %v", shardId, shardOffset, err)
+			}
+			blob = append(blob, buf...)
+		}
+		read := new(needle.Needle)
+		if err = read.ReadBytes(blob, 0, size, ev.Version); err != nil {
+			t.Fatalf("read needle %s: %v", n.Id, err)
+		}
+		if read.Cookie != n.Cookie || !bytes.Equal(read.Data, n.Data) {
+			t.Fatalf("needle %s read back differently", n.Id)
+		}
+	}
+
+	// the shard files keep the same size, so a lost shard can be rebuilt
+	for _, shardId := range shardIds {
+		shard, _ := ev.FindEcVolumeShard(shardId)
+		if shard.Size() != offset/int64(scheme.DataShards) {
+			t.Fatalf("shard %d size %d", shardId, shard.Size())
+		}
+	}
+	// the master rebuilds the rows reserved from the shard sizes
+	for _, m := range ev.ToVolumeEcShardInformationMessage() {
+		if !m.IsDirectWrite || len(m.ShardSizes) != scheme.TotalShards() || m.ShardSizes[0] != offset/int64(scheme.DataShards) {
+			t.Fatalf("heartbeat direct write %v shard sizes %v", m.IsDirectWrite, m.ShardSizes)
+		}
+	}
+	if matches, _ := filepath.Glob(baseFileName + ".ec*"); len(matches) != scheme.TotalShards()+2 {
+		t.Fatalf("ec files %v", matches)
+	}
+}
@@ -10,10 +10,11 @@ type Interval struct {
 	Size                types.Size
 	IsLargeBlock        bool // whether the block is a large block or a small block
 	LargeBlockRowsCount int
+	DataShards          int // the data shards count of the ec volume scheme
 }
 
-func LocateData(largeBlockLength, smallBlockLength int64, shardDatSize int64, offset int64, size types.Size) (intervals []Interval) {
-	blockIndex, isLargeBlock, nLargeBlockRows, innerBlockOffset := locateOffset(largeBlockLength, smallBlockLength, shardDatSize, offset)
+func LocateData(largeBlockLength, smallBlockLength int64, dataShards int, shardDatSize int64, offset int64, size types.Size) (intervals []Interval) {
+	blockIndex, isLargeBlock, nLargeBlockRows, innerBlockOffset := locateOffset(largeBlockLength, smallBlockLength, dataShards, shardDatSize, offset)
 
 	for size > 0 {
 		interval := Interval{
@@ -21,6 +22,7 @@ func LocateData(largeBlockLength, smallBlockLength int64, shardDatSize int64, of
 			InnerBlockOffset:    innerBlockOffset,
 			IsLargeBlock:        isLargeBlock,
 			LargeBlockRowsCount: int(nLargeBlockRows),
+			DataShards:          dataShards,
 		}
 
 		blockRemaining := largeBlockLength - innerBlockOffset
@@ -38,7 +40,7 @@ func LocateData(largeBlockLength, smallBlockLength int64, shardDatSize int64, of
 
 		size -= interval.Size
 		blockIndex += 1
-		if isLargeBlock && blockIndex == interval.LargeBlockRowsCount*DataShardsCount {
+		if isLargeBlock && blockIndex == interval.LargeBlockRowsCount*dataShards {
 			isLargeBlock = false
 			blockIndex = 0
 		}
@@ -48,8 +50,8 @@ func LocateData(largeBlockLength, smallBlockLength int64, shardDatSize int64, of
 	return
 }
 
-func locateOffset(largeBlockLength, smallBlockLength int64, shardDatSize int64, offset int64) (blockIndex int, isLargeBlock bool, nLargeBlockRows int64, innerBlockOffset int64) {
-	largeRowSize := largeBlockLength * DataShardsCount
+func locateOffset(largeBlockLength, smallBlockLength int64, dataShards int, shardDatSize int64, offset int64) (blockIndex int, isLargeBlock bool, nLargeBlockRows int64, innerBlockOffset int64) {
+	largeRowSize := largeBlockLength * int64(dataShards)
 	nLargeBlockRows = (shardDatSize-1)/ largeBlockLength
 
 	// if offset is within the large block area
@@ -73,12 +75,12 @@ func locateOffsetWithinBlocks(blockLength int64, offset int64) (blockIndex int,
 
 func (interval Interval) ToShardIdAndOffset(largeBlockSize, smallBlockSize int64) (ShardId, int64) {
 	ecFileOffset := interval.InnerBlockOffset
-	rowIndex := interval.BlockIndex / DataShardsCount
+	rowIndex := interval.BlockIndex / interval.DataShards
 	if interval.IsLargeBlock {
 		ecFileOffset += int64(rowIndex) * largeBlockSize
 	} else {
 		ecFileOffset += int64(interval.LargeBlockRowsCount)*largeBlockSize + int64(rowIndex)*smallBlockSize
 	}
-	ecFileIndex := interval.BlockIndex % DataShardsCount
+	ecFileIndex := interval.BlockIndex % interval.DataShards
 	return ShardId(ecFileIndex), ecFileOffset
 }
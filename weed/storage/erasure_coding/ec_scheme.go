@@ -0,0 +1,92 @@
+package erasure_coding
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// MaxShardCount is the most shards of an ec volume, limited by the bits of ShardBits
+const MaxShardCount = 32
+
+// EcScheme is the data and parity shards count of an ec volume, kept in its .vif file.
+// The ec volumes encoded before the scheme was configurable use the default 10+4.
+type EcScheme struct {
+	DataShards   int
+	ParityShards int
+}
+
+var DefaultEcScheme = EcScheme{DataShards: DataShardsCount, ParityShards: ParityShardsCount}
+
+// NewEcScheme takes the shards counts from the .vif file or the protobuf messages, 0 for the default
+func NewEcScheme(dataShards, parityShards uint32) EcScheme {
+	if dataShards == 0 || parityShards == 0 {
+		return DefaultEcScheme
+	}
+	return EcScheme{DataShards: int(dataShards), ParityShards: int(parityShards)}
+}
+
+// ParseEcScheme parses the scheme in the form of "6+3"
+func ParseEcScheme(s string) (scheme EcScheme, err error) {
+	if s == "" {
+		return DefaultEcScheme, nil
+	}
+	dataShards, parityShards, found := strings.Cut(s, "+")
+	if !found {
+		return scheme, fmt.Errorf("ec scheme %s should be in the form of data+parity, e.g. 10+4", s)
+	}
+	if scheme.DataShards, err = strconv.Atoi(strings.TrimSpace(dataShards)); err != nil {
+		return scheme, fmt.Errorf("ec scheme %s data shards: %v", s, err)
+	}
+	if scheme.ParityShards, err = strconv.Atoi(strings.TrimSpace(parityShards)); err != nil {
+		return scheme, fmt.Errorf("ec scheme %s parity shards: %v", s, err)
+	}
+	return scheme, scheme.Validate()
+}
+
+func (s EcScheme) Validate() error {
+	if s.DataShards < 1 || s.ParityShards < 1 {
+		return fmt.Errorf("ec scheme %s needs at least 1 data shard and 1 parity shard", s)
+	}
+	if s.TotalShards() > MaxShardCount {
+		return fmt.Errorf("ec scheme %s has more than %d shards", s, MaxShardCount)
+	}
+	return nil
+}
+
+func (s EcScheme) TotalShards() int {
+	return s.DataShards + s.ParityShards
+}
+
+func (s EcScheme) IsDefault() bool {
+	return s == DefaultEcScheme
+}
+
+func (s EcScheme) String() string {
+	return fmt.Sprintf("%d+%d", s.DataShards, s.ParityShards)
+}
+
+func (s EcScheme) NewEncoder() (reedsolomon.Encoder, error) {
+	return reedsolomon.New(s.DataShards, s.ParityShards)
+}
+
+// VolumeSlotUnits divides a volume slot, so the shards of the schemes with up to 16 data shards take whole units
+const VolumeSlotUnits = 720720
+
+// ShardSlotUnits is the part of a volume slot taken by the shards, each holding 1/DataShards of the volume data
+func (s EcScheme) ShardSlotUnits(shardCount int) int64 {
+	if s.DataShards <= 0 {
+		s = DefaultEcScheme
+	}
+	return int64(shardCount) * (VolumeSlotUnits / int64(s.DataShards))
+}
+
+// ShardBits has all the shards of the scheme
+func (s EcScheme) ShardBits() (b ShardBits) {
+	for i := 0; i < s.TotalShards(); i++ {
+		b = b.AddShardId(ShardId(i))
+	}
+	return
+}
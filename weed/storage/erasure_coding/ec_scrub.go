@@ -0,0 +1,28 @@
+package erasure_coding
+
+import (
+	"bytes"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// FindCorruptedShard locates the one shard that does not agree with the others, when the shards fail the parity check.
+// Each shard is rebuilt from the other shards in turn. The rebuilt shard is returned if the shards then pass the check.
+// It returns false if the shards are consistent, or if more than one shard is corrupted.
+func FindCorruptedShard(enc reedsolomon.Encoder, shards [][]byte) (shardId ShardId, rebuilt []byte, found bool) {
+	candidate := make([][]byte, len(shards))
+	for i := range shards {
+		copy(candidate, shards)
+		candidate[i] = nil
+		if err := enc.Reconstruct(candidate); err != nil {
+			continue
+		}
+		if ok, err := enc.Verify(candidate); err != nil || !ok {
+			continue
+		}
+		if !bytes.Equal(candidate[i], shards[i]) {
+			return ShardId(i), candidate[i], true
+		}
+	}
+	return 0, nil, false
+}
@@ -6,6 +6,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/seaweedfs/seaweedfs/weed/stats"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
@@ -22,6 +23,8 @@ type EcVolumeShard struct {
 	ecdFile     *os.File
 	ecdFileSize int64
 	DiskType    types.DiskType
+
+	ecdFileSizeLock sync.Mutex // the shard grows with the rows written directly
 }
 
 func NewEcVolumeShard(diskType types.DiskType, dirname string, collection string, id needle.VolumeId, shardId ShardId) (v *EcVolumeShard, e error) {
@@ -50,6 +53,8 @@ func NewEcVolumeShard(diskType types.DiskType, dirname string, collection string
 }
 
 func (shard *EcVolumeShard) Size() int64 {
+	shard.ecdFileSizeLock.Lock()
+	defer shard.ecdFileSizeLock.Unlock()
 	return shard.ecdFileSize
 }
 
@@ -96,3 +101,59 @@ func (shard *EcVolumeShard) ReadAt(buf []byte, offset int64) (int, error) {
 	return shard.ecdFile.ReadAt(buf, offset)
 
 }
+
+// WriteAt overwrites a part of the shard file, to repair the data in place
+func (shard *EcVolumeShard) WriteAt(buf []byte, offset int64) (int, error) {
+	if offset+int64(len(buf)) > shard.ecdFileSize {
+		return 0, fmt.Errorf("write %d bytes at %d beyond ec shard %d.%d size %d", len(buf), offset, shard.VolumeId, shard.ShardId, shard.ecdFileSize)
+	}
+	f, err := os.OpenFile(shard.FileName()+ToExt(int(shard.ShardId)), os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := f.WriteAt(buf, offset)
+	if err == nil {
+		err = f.Sync()
+	}
+	return n, err
+}
+
+// WriteRows writes the rows of a needle written directly to the ec volume. The data leaves out the trailing zeros
+// of the rows, which are kept as a sparse hole when the shard file is extended to offset+size.
+func (shard *EcVolumeShard) WriteRows(data []byte, offset int64, size int64) error {
+	if offset%ErasureCodingSmallBlockSize != 0 || size%ErasureCodingSmallBlockSize != 0 || int64(len(data)) > size {
+		return fmt.Errorf("write %d bytes at %d not aligned to the ec blocks", size, offset)
+	}
+	if offset+size > ErasureCodingLargeBlockSize {
+		return fmt.Errorf("write %d bytes at %d beyond ec shard %d.%d limit %d", size, offset, shard.VolumeId, shard.ShardId, ErasureCodingLargeBlockSize)
+	}
+	f, err := os.OpenFile(shard.FileName()+ToExt(int(shard.ShardId)), os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.WriteAt(data, offset); err != nil {
+		return err
+	}
+	// extend the file by its last byte, so it is never truncated under the rows written concurrently
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < offset+size {
+		if _, err = f.WriteAt([]byte{0}, offset+size-1); err != nil {
+			return err
+		}
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+
+	shard.ecdFileSizeLock.Lock()
+	if shard.ecdFileSize < offset+size {
+		shard.ecdFileSize = offset + size
+	}
+	shard.ecdFileSizeLock.Unlock()
+	return nil
+}
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/klauspost/reedsolomon"
 
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 )
@@ -23,7 +26,7 @@ func TestEncodingDecoding(t *testing.T) {
 	bufferSize := 50
 	baseFileName := "1"
 
-	err := generateEcFiles(baseFileName, bufferSize, largeBlockSize, smallBlockSize)
+	err := generateEcFiles(baseFileName, DefaultEcScheme, bufferSize, largeBlockSize, smallBlockSize)
 	if err != nil {
 		t.Logf("generateEcFiles: %v", err)
 	}
@@ -60,7 +63,7 @@ func validateFiles(baseFileName string) error {
 		return fmt.Errorf("failed to stat dat file: %v", err)
 	}
 
-	ecFiles, err := openEcFiles(baseFileName, true)
+	ecFiles, err := openEcFiles(baseFileName, TotalShardsCount, true)
 	if err != nil {
 		return fmt.Errorf("error opening ec files: %w", err)
 	}
@@ -111,7 +114,7 @@ func readDatFile(datFile *os.File, offset types.Offset, size types.Size) ([]byte
 
 func readEcFile(shardDatSize int64, ecFiles []*os.File, offset types.Offset, size types.Size) (data []byte, err error) {
 
-	intervals := LocateData(largeBlockSize, smallBlockSize, shardDatSize, offset.ToActualOffset(), size)
+	intervals := LocateData(largeBlockSize, smallBlockSize, DataShardsCount, shardDatSize, offset.ToActualOffset(), size)
 
 	for i, interval := range intervals {
 		if d, e := readOneInterval(interval, ecFiles); e != nil {
@@ -193,15 +196,15 @@ func removeGeneratedFiles(baseFileName string) {
 }
 
 func TestLocateData(t *testing.T) {
-	intervals := LocateData(largeBlockSize, smallBlockSize, largeBlockSize+1, DataShardsCount*largeBlockSize, 1)
+	intervals := LocateData(largeBlockSize, smallBlockSize, DataShardsCount, largeBlockSize+1, DataShardsCount*largeBlockSize, 1)
 	if len(intervals) != 1 {
 		t.Errorf("unexpected interval size %d", len(intervals))
 	}
-	if !intervals[0].sameAs(Interval{0, 0, 1, false, 1}) {
+	if !intervals[0].sameAs(Interval{0, 0, 1, false, 1, DataShardsCount}) {
 		t.Errorf("unexpected interval %+v", intervals[0])
 	}
 
-	intervals = LocateData(largeBlockSize, smallBlockSize, largeBlockSize+1, DataShardsCount*largeBlockSize/2+100, DataShardsCount*largeBlockSize+1-DataShardsCount*largeBlockSize/2-100)
+	intervals = LocateData(largeBlockSize, smallBlockSize, DataShardsCount, largeBlockSize+1, DataShardsCount*largeBlockSize/2+100, DataShardsCount*largeBlockSize+1-DataShardsCount*largeBlockSize/2-100)
 	fmt.Printf("%+v\n", intervals)
 }
 
@@ -213,22 +216,205 @@ func (this Interval) sameAs(that Interval) bool {
 }
 
 func TestLocateData2(t *testing.T) {
-	intervals := LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, 3221225472, 21479557912, 4194339)
+	intervals := LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, DataShardsCount, 3221225472, 21479557912, 4194339)
 	assert.Equal(t, intervals, []Interval{
-		{BlockIndex: 4, InnerBlockOffset: 527128, Size: 521448, IsLargeBlock: false, LargeBlockRowsCount: 2},
-		{BlockIndex: 5, InnerBlockOffset: 0, Size: 1048576, IsLargeBlock: false, LargeBlockRowsCount: 2},
-		{BlockIndex: 6, InnerBlockOffset: 0, Size: 1048576, IsLargeBlock: false, LargeBlockRowsCount: 2},
-		{BlockIndex: 7, InnerBlockOffset: 0, Size: 1048576, IsLargeBlock: false, LargeBlockRowsCount: 2},
-		{BlockIndex: 8, InnerBlockOffset: 0, Size: 527163, IsLargeBlock: false, LargeBlockRowsCount: 2},
+		{BlockIndex: 4, InnerBlockOffset: 527128, Size: 521448, IsLargeBlock: false, LargeBlockRowsCount: 2, DataShards: DataShardsCount},
+		{BlockIndex: 5, InnerBlockOffset: 0, Size: 1048576, IsLargeBlock: false, LargeBlockRowsCount: 2, DataShards: DataShardsCount},
+		{BlockIndex: 6, InnerBlockOffset: 0, Size: 1048576, IsLargeBlock: false, LargeBlockRowsCount: 2, DataShards: DataShardsCount},
+		{BlockIndex: 7, InnerBlockOffset: 0, Size: 1048576, IsLargeBlock: false, LargeBlockRowsCount: 2, DataShards: DataShardsCount},
+		{BlockIndex: 8, InnerBlockOffset: 0, Size: 527163, IsLargeBlock: false, LargeBlockRowsCount: 2, DataShards: DataShardsCount},
 	})
 }
 
 func TestLocateData3(t *testing.T) {
-	intervals := LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, 3221225472, 30782909808, 112568)
+	intervals := LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, DataShardsCount, 3221225472, 30782909808, 112568)
 	for _, interval := range intervals {
 		fmt.Printf("%+v\n", interval)
 	}
 	assert.Equal(t, intervals, []Interval{
-		{BlockIndex: 8876, InnerBlockOffset: 912752, Size: 112568, IsLargeBlock: false, LargeBlockRowsCount: 2},
+		{BlockIndex: 8876, InnerBlockOffset: 912752, Size: 112568, IsLargeBlock: false, LargeBlockRowsCount: 2, DataShards: DataShardsCount},
 	})
 }
+
+func TestFindCorruptedShard(t *testing.T) {
+	enc, err := reedsolomon.New(DataShardsCount, ParityShardsCount)
+	if err != nil {
+		t.Fatalf("failed to create encoder: %v", err)
+	}
+	shards := make([][]byte, TotalShardsCount)
+	for i := range shards {
+		shards[i] = make([]byte, smallBlockSize)
+		if i < DataShardsCount {
+			rand.Read(shards[i])
+		}
+	}
+	if err = enc.Encode(shards); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	if _, _, found := FindCorruptedShard(enc, shards); found {
+		t.Fatalf("found corruption in consistent shards")
+	}
+
+	for _, corrupted := range []int{3, DataShardsCount + 1} {
+		original := bytes.Clone(shards[corrupted])
+		shards[corrupted][17] ^= 0xff
+		shardId, rebuilt, found := FindCorruptedShard(enc, shards)
+		assert.True(t, found)
+		assert.Equal(t, ShardId(corrupted), shardId)
+		assert.Equal(t, original, rebuilt)
+		shards[corrupted] = original
+	}
+
+	shards[1][5] ^= 0xff
+	shards[2][5] ^= 0xff
+	if _, _, found := FindCorruptedShard(enc, shards); found {
+		t.Errorf("expected two corrupted shards to be unrepairable")
+	}
+}
+
+func TestEncodingDecodingScheme(t *testing.T) {
+	baseFileName := "1"
+	scheme := EcScheme{DataShards: 6, ParityShards: 3}
+	defer func() {
+		for i := 0; i < scheme.TotalShards(); i++ {
+			os.Remove(baseFileName + ToExt(i))
+		}
+		os.Remove(baseFileName + "_decoded.dat")
+	}()
+
+	if err := WriteEcFiles(baseFileName, scheme); err != nil {
+		t.Fatalf("WriteEcFiles: %v", err)
+	}
+	if _, err := os.Stat(baseFileName + ToExt(scheme.TotalShards())); !os.IsNotExist(err) {
+		t.Fatalf("generated more than %d shards", scheme.TotalShards())
+	}
+	datData, err := os.ReadFile(baseFileName + ".dat")
+	if err != nil {
+		t.Fatalf("read dat file: %v", err)
+	}
+
+	// locate the needles in the 6 data shards
+	ecFiles, err := openEcFiles(baseFileName, scheme.TotalShards(), true)
+	if err != nil {
+		t.Fatalf("open ec files: %v", err)
+	}
+	nm, err := readNeedleMap(baseFileName)
+	if err != nil {
+		t.Fatalf("readNeedleMap: %v", err)
+	}
+	shardDatSize := int64(len(datData)) / int64(scheme.DataShards)
+	err = nm.AscendingVisit(func(value needle_map.NeedleValue) error {
+		var ecData []byte
+		for _, interval := range LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, scheme.DataShards, shardDatSize, value.Offset.ToActualOffset(), value.Size) {
+			shardId, shardOffset := interval.ToShardIdAndOffset(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize)
+			data := make([]byte, interval.Size)
+			if err := readFromFile(ecFiles[shardId], data, shardOffset); err != nil {
+				return err
+			}
+			ecData = append(ecData, data...)
+		}
+		offset := value.Offset.ToActualOffset()
+		if !bytes.Equal(datData[offset:offset+int64(value.Size)], ecData) {
+			return fmt.Errorf("needle %d read from ec shards differs", value.Key)
+		}
+		return nil
+	})
+	nm.Close()
+	closeEcFiles(ecFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lose as many shards as the parity shards, and rebuild them
+	lostShards := []int{0, 4, 7}
+	var lostData [][]byte
+	for _, shardId := range lostShards {
+		data, _ := os.ReadFile(baseFileName + ToExt(shardId))
+		lostData = append(lostData, data)
+		os.Remove(baseFileName + ToExt(shardId))
+	}
+	rebuiltShardIds, err := RebuildEcFiles(baseFileName, scheme)
+	if err != nil {
+		t.Fatalf("RebuildEcFiles: %v", err)
+	}
+	assert.Equal(t, []uint32{0, 4, 7}, rebuiltShardIds)
+	for i, shardId := range lostShards {
+		data, _ := os.ReadFile(baseFileName + ToExt(shardId))
+		assert.Equal(t, lostData[i], data, "rebuilt shard %d", shardId)
+	}
+
+	// decode from the data shards
+	var shardFileNames []string
+	for i := 0; i < scheme.DataShards; i++ {
+		shardFileNames = append(shardFileNames, baseFileName+ToExt(i))
+	}
+	if err = WriteDatFile(baseFileName+"_decoded", int64(len(datData)), shardFileNames); err != nil {
+		t.Fatalf("WriteDatFile: %v", err)
+	}
+	decoded, _ := os.ReadFile(baseFileName + "_decoded.dat")
+	assert.True(t, bytes.Equal(datData, decoded), "decoded dat file differs")
+}
+
+func TestParseEcScheme(t *testing.T) {
+	scheme, err := ParseEcScheme("16+4")
+	assert.Nil(t, err)
+	assert.Equal(t, EcScheme{DataShards: 16, ParityShards: 4}, scheme)
+	assert.Equal(t, 20, scheme.TotalShards())
+
+	scheme, err = ParseEcScheme("")
+	assert.Nil(t, err)
+	assert.True(t, scheme.IsDefault())
+
+	for _, s := range []string{"10", "0+4", "6+0", "30+3", "a+b"} {
+		if _, err = ParseEcScheme(s); err == nil {
+			t.Errorf("ec scheme %s is accepted", s)
+		}
+	}
+}
+
+func TestShardSlotUnits(t *testing.T) {
+	assert.Equal(t, int64(VolumeSlotUnits), DefaultEcScheme.ShardSlotUnits(DataShardsCount))
+	assert.Equal(t, int64(VolumeSlotUnits), NewEcScheme(4, 2).ShardSlotUnits(4))
+	assert.Equal(t, int64(3*VolumeSlotUnits/2), NewEcScheme(4, 2).ShardSlotUnits(6))
+	assert.Equal(t, int64(0), NewEcScheme(16, 4).ShardSlotUnits(0))
+}
+
+func TestWriteDatFileOnBlockDevice(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := backend.AttachBlockDevice(dir, filepath.Join(t.TempDir(), "device")+":256MiB", true); err != nil {
+		t.Fatalf("attach block device: %v", err)
+	}
+	defer backend.DetachBlockDevice(dir)
+
+	var shardFileNames []string
+	var expected []byte
+	for shardId := 0; shardId < 2; shardId++ {
+		data := make([]byte, ErasureCodingSmallBlockSize)
+		rand.Read(data)
+		expected = append(expected, data...)
+		shardFileName := filepath.Join(dir, "1"+ToExt(shardId))
+		if err := os.WriteFile(shardFileName, data, 0644); err != nil {
+			t.Fatalf("write shard: %v", err)
+		}
+		shardFileNames = append(shardFileNames, shardFileName)
+	}
+
+	baseFileName := filepath.Join(dir, "1")
+	if err := WriteDatFile(baseFileName, int64(len(expected)), shardFileNames); err != nil {
+		t.Fatalf("WriteDatFile: %v", err)
+	}
+	if _, err := os.Stat(baseFileName + ".dat"); !os.IsNotExist(err) {
+		t.Fatalf("the .dat file is written to the directory: %v", err)
+	}
+	datFile, err := backend.OpenVolumeFile(baseFileName+".dat", os.O_RDONLY)
+	if err != nil {
+		t.Fatalf("open .dat on the block device: %v", err)
+	}
+	defer datFile.Close()
+	decoded := make([]byte, len(expected))
+	if n, err := datFile.ReadAt(decoded, 0); n != len(expected) || err != nil && err != io.EOF {
+		t.Fatalf("read %d bytes: %v", n, err)
+	}
+	assert.True(t, bytes.Equal(expected, decoded), "decoded dat file differs")
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 var (
@@ -33,16 +34,21 @@ type EcVolume struct {
 	ecxFile                   *os.File
 	ecxFileSize               int64
 	ecxCreatedAt              time.Time
+	ecxFileAccessLock         sync.RWMutex // the .ecx entries are inserted for the ec volumes written directly
 	Shards                    []*EcVolumeShard
 	ShardLocations            map[ShardId][]pb.ServerAddress
 	ShardLocationsRefreshTime time.Time
 	ShardLocationsLock        sync.RWMutex
 	Version                   needle.Version
+	Scheme                    EcScheme
 	ecjFile                   *os.File
 	ecjFileAccessLock         sync.Mutex
 	diskType                  types.DiskType
 	datFileSize               int64
 	DestroyTime               uint64 //ec volume destroy time, calculated from the ec volume was created
+	WrappedDataKeys           []*volume_server_pb.VolumeDataKey
+	DataKeys                  map[uint32]util.CipherKey // the unwrapped WrappedDataKeys, to decrypt the needles
+	IsDirectWrite             bool                      // the rows are written directly by the clients, see ec_direct_write.go
 }
 
 func NewEcVolume(diskType types.DiskType, dir string, dirIdx string, collection string, vid needle.VolumeId) (ev *EcVolume, err error) {
@@ -70,10 +76,14 @@ func NewEcVolume(diskType types.DiskType, dir string, dirIdx string, collection
 
 	// read volume info
 	ev.Version = needle.Version3
+	ev.Scheme = DefaultEcScheme
 	if volumeInfo, _, found, _ := volume_info.MaybeLoadVolumeInfo(dataBaseFileName + ".vif"); found {
 		ev.Version = needle.Version(volumeInfo.Version)
 		ev.datFileSize = volumeInfo.DatFileSize
 		ev.DestroyTime = volumeInfo.DestroyTime
+		ev.WrappedDataKeys = volumeInfo.DataKeys
+		ev.Scheme = NewEcScheme(volumeInfo.EcDataShards, volumeInfo.EcParityShards)
+		ev.IsDirectWrite = volumeInfo.EcDirectWrite
 	} else {
 		glog.Warningf("vif file not found,volumeId:%d, filename:%s", vid, dataBaseFileName)
 		volume_info.SaveVolumeInfo(dataBaseFileName+".vif", &volume_server_pb.VolumeInfo{Version: uint32(ev.Version)})
@@ -203,15 +213,19 @@ func (ev *EcVolume) ToVolumeEcShardInformationMessage() (messages []*master_pb.V
 	for _, s := range ev.Shards {
 		if s.VolumeId != prevVolumeId {
 			m = &master_pb.VolumeEcShardInformationMessage{
-				Id:          uint32(s.VolumeId),
-				Collection:  s.Collection,
-				DiskType:    string(ev.diskType),
-				DestroyTime: ev.DestroyTime,
+				Id:            uint32(s.VolumeId),
+				Collection:    s.Collection,
+				DiskType:      string(ev.diskType),
+				DestroyTime:   ev.DestroyTime,
+				DataShards:    uint32(ev.Scheme.DataShards),
+				ParityShards:  uint32(ev.Scheme.ParityShards),
+				IsDirectWrite: ev.IsDirectWrite,
 			}
 			messages = append(messages, m)
 		}
 		prevVolumeId = s.VolumeId
 		m.EcIndexBits = uint32(ShardBits(m.EcIndexBits).AddShardId(s.ShardId))
+		m.ShardSizes = append(m.ShardSizes, s.Size())
 	}
 	return
 }
@@ -237,18 +251,27 @@ func (ev *EcVolume) LocateEcShardNeedleInterval(version needle.Version, offset i
 	if ev.datFileSize > 0 {
 		// To get the correct LargeBlockRowsCount
 		// use datFileSize to calculate the shardSize to match the EC encoding logic.
-		shardSize = ev.datFileSize / DataShardsCount
+		shardSize = ev.datFileSize / int64(ev.Scheme.DataShards)
 	}
 	// calculate the locations in the ec shards
-	intervals = LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, shardSize, offset, types.Size(needle.GetActualSize(size, version)))
+	intervals = LocateData(ErasureCodingLargeBlockSize, ErasureCodingSmallBlockSize, ev.Scheme.DataShards, shardSize, offset, types.Size(needle.GetActualSize(size, version)))
 
 	return
 }
 
 func (ev *EcVolume) FindNeedleFromEcx(needleId types.NeedleId) (offset types.Offset, size types.Size, err error) {
+	ev.ecxFileAccessLock.RLock()
+	defer ev.ecxFileAccessLock.RUnlock()
 	return SearchNeedleFromSortedIndex(ev.ecxFile, ev.ecxFileSize, needleId, nil)
 }
 
+// WalkIndex visits the .ecx entries in the order of the needle ids, including the deleted ones
+func (ev *EcVolume) WalkIndex(processNeedleFn func(key types.NeedleId, offset types.Offset, size types.Size) error) error {
+	ev.ecxFileAccessLock.RLock()
+	defer ev.ecxFileAccessLock.RUnlock()
+	return idx.WalkIndexFile(ev.ecxFile, 0, processNeedleFn)
+}
+
 func SearchNeedleFromSortedIndex(ecxFile *os.File, ecxFileSize int64, needleId types.NeedleId, processNeedleFn func(file *os.File, offset int64) error) (offset types.Offset, size types.Size, err error) {
 	var key types.NeedleId
 	buf := make([]byte, types.NeedleMapEntrySize)
@@ -26,7 +26,9 @@ var (
 
 func (ev *EcVolume) DeleteNeedleFromEcx(needleId types.NeedleId) (err error) {
 
+	ev.ecxFileAccessLock.Lock()
 	_, _, err = SearchNeedleFromSortedIndex(ev.ecxFile, ev.ecxFileSize, needleId, MarkNeedleDeleted)
+	ev.ecxFileAccessLock.Unlock()
 
 	if err != nil {
 		if err == NotFoundError {
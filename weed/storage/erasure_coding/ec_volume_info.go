@@ -10,15 +10,17 @@ type EcVolumeInfo struct {
 	VolumeId    needle.VolumeId
 	Collection  string
 	ShardBits   ShardBits
+	Scheme      EcScheme
 	DiskType    string
 	DestroyTime uint64 //ec volume destroy time, calculated from the ec volume was created
 }
 
-func NewEcVolumeInfo(diskType string, collection string, vid needle.VolumeId, shardBits ShardBits, destroyTime uint64) *EcVolumeInfo {
+func NewEcVolumeInfo(diskType string, collection string, vid needle.VolumeId, shardBits ShardBits, scheme EcScheme, destroyTime uint64) *EcVolumeInfo {
 	return &EcVolumeInfo{
 		Collection:  collection,
 		VolumeId:    vid,
 		ShardBits:   shardBits,
+		Scheme:      scheme,
 		DiskType:    diskType,
 		DestroyTime: destroyTime,
 	}
@@ -49,6 +51,7 @@ func (ecInfo *EcVolumeInfo) Minus(other *EcVolumeInfo) *EcVolumeInfo {
 		VolumeId:   ecInfo.VolumeId,
 		Collection: ecInfo.Collection,
 		ShardBits:  ecInfo.ShardBits.Minus(other.ShardBits),
+		Scheme:     ecInfo.Scheme,
 		DiskType:   ecInfo.DiskType,
 	}
 
@@ -57,11 +60,13 @@ func (ecInfo *EcVolumeInfo) Minus(other *EcVolumeInfo) *EcVolumeInfo {
 
 func (ecInfo *EcVolumeInfo) ToVolumeEcShardInformationMessage() (ret *master_pb.VolumeEcShardInformationMessage) {
 	return &master_pb.VolumeEcShardInformationMessage{
-		Id:          uint32(ecInfo.VolumeId),
-		EcIndexBits: uint32(ecInfo.ShardBits),
-		Collection:  ecInfo.Collection,
-		DiskType:    ecInfo.DiskType,
-		DestroyTime: ecInfo.DestroyTime,
+		Id:           uint32(ecInfo.VolumeId),
+		EcIndexBits:  uint32(ecInfo.ShardBits),
+		Collection:   ecInfo.Collection,
+		DiskType:     ecInfo.DiskType,
+		DestroyTime:  ecInfo.DestroyTime,
+		DataShards:   uint32(ecInfo.Scheme.DataShards),
+		ParityShards: uint32(ecInfo.Scheme.ParityShards),
 	}
 }
 
@@ -80,7 +85,7 @@ func (b ShardBits) HasShardId(id ShardId) bool {
 }
 
 func (b ShardBits) ShardIds() (ret []ShardId) {
-	for i := ShardId(0); i < TotalShardsCount; i++ {
+	for i := ShardId(0); i < MaxShardCount; i++ {
 		if b.HasShardId(i) {
 			ret = append(ret, i)
 		}
@@ -89,7 +94,7 @@ func (b ShardBits) ShardIds() (ret []ShardId) {
 }
 
 func (b ShardBits) ToUint32Slice() (ret []uint32) {
-	for i := uint32(0); i < TotalShardsCount; i++ {
+	for i := uint32(0); i < MaxShardCount; i++ {
 		if b.HasShardId(ShardId(i)) {
 			ret = append(ret, i)
 		}
@@ -112,8 +117,8 @@ func (b ShardBits) Plus(other ShardBits) ShardBits {
 	return b | other
 }
 
-func (b ShardBits) MinusParityShards() ShardBits {
-	for i := DataShardsCount; i < TotalShardsCount; i++ {
+func (b ShardBits) MinusParityShards(scheme EcScheme) ShardBits {
+	for i := scheme.DataShards; i < scheme.TotalShards(); i++ {
 		b = b.RemoveShardId(ShardId(i))
 	}
 	return b
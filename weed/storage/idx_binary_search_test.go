@@ -26,7 +26,7 @@ func TestFirstInvalidIndex(t *testing.T) {
 	for i := 1; i <= 30; i++ {
 		n := newRandomNeedle(uint64(i))
 		n.Flags = 0x08
-		_, _, _, err := v.writeNeedle2(n, true, false)
+		_, _, _, err := v.writeNeedle2(n, true, false, 0)
 		if err != nil {
 			t.Fatalf("write needle %d: %v", i, err)
 		}
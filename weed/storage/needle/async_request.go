@@ -1,14 +1,18 @@
 package needle
 
+import "time"
+
 type AsyncRequest struct {
 	N              *Needle
 	IsWriteRequest bool
 	ActualSize     int64
-	offset         uint64
-	size           uint64
-	doneChan       chan interface{}
-	isUnchanged    bool
-	err            error
+	// wait at most this long for other requests to share the fsync, 0 to fsync as soon as the queued requests are written
+	GroupCommitDelay time.Duration
+	offset           uint64
+	size             uint64
+	doneChan         chan interface{}
+	isUnchanged      bool
+	err              error
 }
 
 func NewAsyncRequest(n *Needle, isWriteRequest bool) *AsyncRequest {
@@ -39,6 +39,11 @@ type Needle struct {
 	LastModified uint64 //only store LastModifiedBytesLength bytes, which is 5 bytes to disk
 	Ttl          *TTL
 
+	ChecksumType    ChecksumType //version4
+	Digest          []byte       `comment:"xxhash64 or sha256 of the data, by the checksum type"` //version4
+	ContentEncoding []byte       `comment:"gzip or zstd, maximum 255 characters"`                 //version4
+	EncryptionKeyId uint32       `comment:"the volume data key encrypting the data"`              //version4
+
 	Checksum   CRC    `comment:"CRC32 to check integrity"`
 	AppendAtNs uint64 `comment:"append timestamp in nano seconds"` //version3
 	Padding    []byte `comment:"Aligned to 8 bytes"`
@@ -60,6 +65,7 @@ func CreateNeedleFromRequest(r *http.Request, fixJpgOrientation bool, sizeLimit
 	n.LastModified = pu.ModifiedTime
 	n.Ttl = pu.Ttl
 	contentMd5 = pu.ContentMd5
+	n.ChecksumType = pu.ChecksumType
 
 	if len(pu.FileName) < 256 {
 		n.Name = []byte(pu.FileName)
@@ -82,9 +88,14 @@ func CreateNeedleFromRequest(r *http.Request, fixJpgOrientation bool, sizeLimit
 			n.SetHasPairs()
 		}
 	}
-	if pu.IsGzipped {
+	if pu.IsGzipped || pu.IsZstd {
 		// println(r.URL.Path, "is set to compressed", pu.FileName, pu.IsGzipped, "dataSize", pu.OriginalDataSize)
 		n.SetIsCompressed()
+		if pu.IsZstd {
+			n.ContentEncoding = []byte("zstd")
+		} else {
+			n.ContentEncoding = []byte("gzip")
+		}
 	}
 	if n.LastModified == 0 {
 		n.LastModified = uint64(time.Now().Unix())
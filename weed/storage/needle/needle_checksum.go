@@ -0,0 +1,74 @@
+package needle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// ChecksumType is the checksum of a Version4 needle data, chosen per needle.
+// The CRC32-C is always kept after the needle body, for the ETag and the older tools.
+// With xxhash64 or sha256, their digest is also kept in the needle body, and verified when reading.
+type ChecksumType byte
+
+const (
+	ChecksumCrc32c   = ChecksumType(0)
+	ChecksumXxhash64 = ChecksumType(1)
+	ChecksumSha256   = ChecksumType(2)
+)
+
+func ParseChecksumType(s string) (ChecksumType, error) {
+	switch s {
+	case "", "crc32c":
+		return ChecksumCrc32c, nil
+	case "xxhash", "xxhash64":
+		return ChecksumXxhash64, nil
+	case "sha256":
+		return ChecksumSha256, nil
+	}
+	return ChecksumCrc32c, fmt.Errorf("unknown checksum %s, expecting crc32c, xxhash64 or sha256", s)
+}
+
+func (t ChecksumType) String() string {
+	switch t {
+	case ChecksumCrc32c:
+		return "crc32c"
+	case ChecksumXxhash64:
+		return "xxhash64"
+	case ChecksumSha256:
+		return "sha256"
+	}
+	return fmt.Sprintf("checksum%d", byte(t))
+}
+
+// DigestSize is the size of the digest kept in the needle body, 0 for the CRC32-C kept after the body
+func (t ChecksumType) DigestSize() int {
+	switch t {
+	case ChecksumXxhash64:
+		return 8
+	case ChecksumSha256:
+		return sha256.Size
+	}
+	return 0
+}
+
+func (t ChecksumType) Digest(data []byte) []byte {
+	switch t {
+	case ChecksumXxhash64:
+		digest := make([]byte, 8)
+		util.Uint64toBytes(digest, xxhash.Sum64(data))
+		return digest
+	case ChecksumSha256:
+		digest := sha256.Sum256(data)
+		return digest[:]
+	}
+	return nil
+}
+
+func (t ChecksumType) Verify(data, digest []byte) bool {
+	return bytes.Equal(t.Digest(data), digest)
+}
@@ -18,19 +18,20 @@ import (
 )
 
 type ParsedUpload struct {
-	FileName    string
-	Data        []byte
-	bytesBuffer *bytes.Buffer
-	MimeType    string
-	PairMap     map[string]string
-	IsGzipped   bool
-	// IsZstd           bool
+	FileName         string
+	Data             []byte
+	bytesBuffer      *bytes.Buffer
+	MimeType         string
+	PairMap          map[string]string
+	IsGzipped        bool
+	IsZstd           bool
 	OriginalDataSize int
 	ModifiedTime     uint64
 	Ttl              *TTL
 	IsChunkedFile    bool
 	UncompressedData []byte
 	ContentMd5       string
+	ChecksumType     ChecksumType
 }
 
 func ParseUpload(r *http.Request, sizeLimit int64, bytesBuffer *bytes.Buffer) (pu *ParsedUpload, e error) {
@@ -62,11 +63,14 @@ func ParseUpload(r *http.Request, sizeLimit int64, bytesBuffer *bytes.Buffer) (p
 
 	pu.ModifiedTime, _ = strconv.ParseUint(r.FormValue("ts"), 10, 64)
 	pu.Ttl, _ = ReadTTL(r.FormValue("ttl"))
+	if pu.ChecksumType, e = ParseChecksumType(r.FormValue("checksum")); e != nil {
+		return
+	}
 
 	pu.OriginalDataSize = len(pu.Data)
 	pu.UncompressedData = pu.Data
 	// println("received data", len(pu.Data), "isGzipped", pu.IsGzipped, "mime", pu.MimeType, "name", pu.FileName)
-	if pu.IsGzipped {
+	if pu.IsGzipped || pu.IsZstd {
 		if unzipped, e := util.DecompressData(pu.Data); e == nil {
 			pu.OriginalDataSize = len(unzipped)
 			pu.UncompressedData = unzipped
@@ -110,7 +114,7 @@ func ParseUpload(r *http.Request, sizeLimit int64, bytesBuffer *bytes.Buffer) (p
 
 func parsePut(r *http.Request, sizeLimit int64, pu *ParsedUpload) error {
 	pu.IsGzipped = r.Header.Get("Content-Encoding") == "gzip"
-	// pu.IsZstd = r.Header.Get("Content-Encoding") == "zstd"
+	pu.IsZstd = r.Header.Get("Content-Encoding") == "zstd"
 	pu.MimeType = r.Header.Get("Content-Type")
 	pu.FileName = ""
 	dataSize, err := pu.bytesBuffer.ReadFrom(io.LimitReader(r.Body, sizeLimit+1))
@@ -209,7 +213,7 @@ func parseMultipart(r *http.Request, sizeLimit int64, pu *ParsedUpload) (e error
 
 	}
 	pu.IsGzipped = part.Header.Get("Content-Encoding") == "gzip"
-	// pu.IsZstd = part.Header.Get("Content-Encoding") == "zstd"
+	pu.IsZstd = part.Header.Get("Content-Encoding") == "zstd"
 
 	return
 }
@@ -271,7 +275,7 @@ func parseRawPost(r *http.Request, sizeLimit int64, pu *ParsedUpload) (e error)
 
 	}
 	pu.IsGzipped = r.Header.Get("Content-Encoding") == "gzip"
-	// pu.IsZstd = r.Header.Get("Content-Encoding") == "zstd"
+	pu.IsZstd = r.Header.Get("Content-Encoding") == "zstd"
 
 	return
 }
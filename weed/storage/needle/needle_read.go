@@ -18,6 +18,7 @@ const (
 	FlagHasLastModifiedDate = 0x08
 	FlagHasTtl              = 0x10
 	FlagHasPairs            = 0x20
+	FlagIsEncrypted         = 0x40
 	FlagIsChunkManifest     = 0x80
 	LastModifiedBytesLength = 5
 	TtlBytesLength          = 2
@@ -64,8 +65,8 @@ func (n *Needle) ReadBytes(bytes []byte, offset int64, size Size, version Versio
 	switch version {
 	case Version1:
 		n.Data = bytes[NeedleHeaderSize : NeedleHeaderSize+size]
-	case Version2, Version3:
-		err = n.readNeedleDataVersion2(bytes[NeedleHeaderSize : NeedleHeaderSize+int(n.Size)])
+	case Version2, Version3, Version4:
+		err = n.readNeedleDataVersion2(bytes[NeedleHeaderSize:NeedleHeaderSize+int(n.Size)], version)
 	}
 	if err != nil && err != io.EOF {
 		return err
@@ -79,8 +80,12 @@ func (n *Needle) ReadBytes(bytes []byte, offset int64, size Size, version Versio
 			return errors.New("CRC error! Data On Disk Corrupted")
 		}
 		n.Checksum = newChecksum
+		if len(n.Digest) > 0 && !n.ChecksumType.Verify(n.Data, n.Digest) {
+			stats.VolumeServerHandlerCounter.WithLabelValues(stats.ErrorCRC).Inc()
+			return fmt.Errorf("%s error! Data On Disk Corrupted", n.ChecksumType)
+		}
 	}
-	if version == Version3 {
+	if version == Version3 || version == Version4 {
 		tsOffset := NeedleHeaderSize + size + NeedleChecksumSize
 		n.AppendAtNs = util.BytesToUint64(bytes[tsOffset : tsOffset+TimestampSize])
 	}
@@ -112,7 +117,7 @@ func (n *Needle) ParseNeedleHeader(bytes []byte) {
 	n.Size = BytesToSize(bytes[CookieSize+NeedleIdSize : NeedleHeaderSize])
 }
 
-func (n *Needle) readNeedleDataVersion2(bytes []byte) (err error) {
+func (n *Needle) readNeedleDataVersion2(bytes []byte, version Version) (err error) {
 	index, lenBytes := 0, len(bytes)
 	if index < lenBytes {
 		n.DataSize = util.BytesToUint32(bytes[index : index+4])
@@ -124,10 +129,10 @@ func (n *Needle) readNeedleDataVersion2(bytes []byte) (err error) {
 		n.Data = bytes[index : index+int(n.DataSize)]
 		index = index + int(n.DataSize)
 	}
-	_, err = n.readNeedleDataVersion2NonData(bytes[index:])
+	_, err = n.readNeedleDataVersion2NonData(bytes[index:], version)
 	return
 }
-func (n *Needle) readNeedleDataVersion2NonData(bytes []byte) (index int, err error) {
+func (n *Needle) readNeedleDataVersion2NonData(bytes []byte, version Version) (index int, err error) {
 	lenBytes := len(bytes)
 	if index < lenBytes {
 		n.Flags = bytes[index]
@@ -184,12 +189,48 @@ func (n *Needle) readNeedleDataVersion2NonData(bytes []byte) (index int, err err
 		n.Pairs = bytes[index:end]
 		index = end
 	}
+	if index < lenBytes && version == Version4 {
+		return n.readNeedleDataVersion4NonData(bytes, index)
+	}
+	return index, nil
+}
+
+func (n *Needle) readNeedleDataVersion4NonData(bytes []byte, index int) (int, error) {
+	lenBytes := len(bytes)
+	n.ChecksumType = ChecksumType(bytes[index])
+	index = index + 1
+	if digestSize := n.ChecksumType.DigestSize(); digestSize > 0 {
+		if digestSize+index > lenBytes {
+			stats.VolumeServerHandlerCounter.WithLabelValues(stats.ErrorIndexOutOfRange).Inc()
+			return index, fmt.Errorf("index out of range %d", 8)
+		}
+		n.Digest = bytes[index : index+digestSize]
+		index = index + digestSize
+	}
+	if index < lenBytes {
+		contentEncodingSize := int(bytes[index])
+		index = index + 1
+		if contentEncodingSize+index > lenBytes {
+			stats.VolumeServerHandlerCounter.WithLabelValues(stats.ErrorIndexOutOfRange).Inc()
+			return index, fmt.Errorf("index out of range %d", 9)
+		}
+		n.ContentEncoding = bytes[index : index+contentEncodingSize]
+		index = index + contentEncodingSize
+	}
+	if index < lenBytes && n.IsEncrypted() {
+		if DataKeyIdSize+index > lenBytes {
+			stats.VolumeServerHandlerCounter.WithLabelValues(stats.ErrorIndexOutOfRange).Inc()
+			return index, fmt.Errorf("index out of range %d", 10)
+		}
+		n.EncryptionKeyId = util.BytesToUint32(bytes[index : index+DataKeyIdSize])
+		index = index + DataKeyIdSize
+	}
 	return index, nil
 }
 
 func ReadNeedleHeader(r backend.BackendStorageFile, version Version, offset int64) (n *Needle, bytes []byte, bodyLength int64, err error) {
 	n = new(Needle)
-	if version == Version1 || version == Version2 || version == Version3 {
+	if IsSupportedVersion(version) {
 		bytes = make([]byte, NeedleHeaderSize)
 
 		var count int
@@ -206,7 +247,7 @@ func ReadNeedleHeader(r backend.BackendStorageFile, version Version, offset int6
 }
 
 func PaddingLength(needleSize Size, version Version) Size {
-	if version == Version3 {
+	if version == Version3 || version == Version4 {
 		// this is same value as version2, but just listed here for clarity
 		return NeedlePaddingSize - ((NeedleHeaderSize + needleSize + NeedleChecksumSize + TimestampSize) % NeedlePaddingSize)
 	}
@@ -214,7 +255,7 @@ func PaddingLength(needleSize Size, version Version) Size {
 }
 
 func NeedleBodyLength(needleSize Size, version Version) int64 {
-	if version == Version3 {
+	if version == Version3 || version == Version4 {
 		return int64(needleSize) + NeedleChecksumSize + TimestampSize + int64(PaddingLength(needleSize, version))
 	}
 	return int64(needleSize) + NeedleChecksumSize + int64(PaddingLength(needleSize, version))
@@ -246,11 +287,11 @@ func (n *Needle) ReadNeedleBodyBytes(needleBody []byte, version Version) (err er
 	case Version1:
 		n.Data = needleBody[:n.Size]
 		n.Checksum = NewCRC(n.Data)
-	case Version2, Version3:
-		err = n.readNeedleDataVersion2(needleBody[0:n.Size])
+	case Version2, Version3, Version4:
+		err = n.readNeedleDataVersion2(needleBody[0:n.Size], version)
 		n.Checksum = NewCRC(n.Data)
 
-		if version == Version3 {
+		if version == Version3 || version == Version4 {
 			tsOffset := n.Size + NeedleChecksumSize
 			n.AppendAtNs = util.BytesToUint64(needleBody[tsOffset : tsOffset+TimestampSize])
 		}
@@ -299,6 +340,50 @@ func (n *Needle) SetIsChunkManifest() {
 	n.Flags = n.Flags | FlagIsChunkManifest
 }
 
+func (n *Needle) IsEncrypted() bool {
+	return n.Flags&FlagIsEncrypted > 0
+}
+
+func (n *Needle) SetIsEncrypted() {
+	n.Flags = n.Flags | FlagIsEncrypted
+}
+
+// DataKeyId is the id of the volume data key the needle data is encrypted with
+func (n *Needle) DataKeyId() uint32 {
+	if !n.IsEncrypted() {
+		return 0
+	}
+	if n.EncryptionKeyId != 0 {
+		return n.EncryptionKeyId
+	}
+	if len(n.Data) < DataKeyIdSize {
+		return 0
+	}
+	return util.BytesToUint32(n.Data[0:DataKeyIdSize])
+}
+
+// DecryptData decrypts the data encrypted by EncryptData, and sets the checksum of the decrypted data
+func (n *Needle) DecryptData(key util.CipherKey) error {
+	ciphertext := n.Data
+	if n.EncryptionKeyId == 0 {
+		if len(n.Data) < DataKeyIdSize {
+			return fmt.Errorf("encrypted needle %s is too short", n.Id)
+		}
+		ciphertext = n.Data[DataKeyIdSize:]
+	}
+	data, err := util.Decrypt(ciphertext, key)
+	if err != nil {
+		return fmt.Errorf("decrypt needle %s: %v", n.Id, err)
+	}
+	n.Data = data
+	n.DataSize = uint32(len(data))
+	n.Checksum = NewCRC(data)
+	n.Digest = nil
+	n.EncryptionKeyId = 0
+	n.Flags = n.Flags &^ FlagIsEncrypted
+	return nil
+}
+
 func (n *Needle) HasPairs() bool {
 	return n.Flags&FlagHasPairs != 0
 }
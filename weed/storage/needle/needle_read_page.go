@@ -69,11 +69,11 @@ func (n *Needle) ReadNeedleMeta(r backend.BackendStorageFile, offset int64, size
 
 	var index int
 	if size.IsValid() {
-		index, err = n.readNeedleDataVersion2NonData(metaSlice)
+		index, err = n.readNeedleDataVersion2NonData(metaSlice, version)
 	}
 
 	n.Checksum = CRC(util.BytesToUint32(metaSlice[index : index+NeedleChecksumSize]))
-	if version == Version3 {
+	if version == Version3 || version == Version4 {
 		n.AppendAtNs = util.BytesToUint64(metaSlice[index+NeedleChecksumSize : index+NeedleChecksumSize+TimestampSize])
 	}
 	return err
@@ -11,6 +11,32 @@ import (
 	"math"
 )
 
+// DataKeyIdSize is the size of the data key id prefixing the encrypted needle data, or following the needle metadata in Version4
+const DataKeyIdSize = 4
+
+// EncryptData encrypts the needle data with the volume data key. The key id to find the key for decryption
+// prefixes the encrypted data, or is kept in the needle metadata since Version4.
+// The checksum is set to the one of the encrypted data, which is verified when reading the needle.
+func (n *Needle) EncryptData(keyId uint32, key util.CipherKey, version Version) error {
+	ciphertext, err := util.Encrypt(n.Data, key)
+	if err != nil {
+		return fmt.Errorf("encrypt needle %s: %v", n.Id, err)
+	}
+	data := ciphertext
+	if version == Version4 {
+		n.EncryptionKeyId = keyId
+	} else {
+		data = make([]byte, DataKeyIdSize+len(ciphertext))
+		util.Uint32toBytes(data[0:DataKeyIdSize], keyId)
+		copy(data[DataKeyIdSize:], ciphertext)
+	}
+	n.Data = data
+	n.DataSize = uint32(len(data))
+	n.Checksum = NewCRC(data)
+	n.SetIsEncrypted()
+	return nil
+}
+
 func (n *Needle) prepareWriteBuffer(version Version, writeBytes *bytes.Buffer) (Size, int64, error) {
 	writeBytes.Reset()
 	switch version {
@@ -28,7 +54,7 @@ func (n *Needle) prepareWriteBuffer(version Version, writeBytes *bytes.Buffer) (
 		util.Uint32toBytes(header[0:NeedleChecksumSize], uint32(n.Checksum))
 		writeBytes.Write(header[0 : NeedleChecksumSize+padding])
 		return size, actualSize, nil
-	case Version2, Version3:
+	case Version2, Version3, Version4:
 		header := make([]byte, NeedleHeaderSize+TimestampSize) // adding timestamp to reuse it and avoid extra allocation
 		CookieToBytes(header[0:CookieSize], n.Cookie)
 		NeedleIdToBytes(header[CookieSize:CookieSize+NeedleIdSize], n.Id)
@@ -55,6 +81,12 @@ func (n *Needle) prepareWriteBuffer(version Version, writeBytes *bytes.Buffer) (
 			if n.HasPairs() {
 				n.Size += 2 + Size(n.PairsSize)
 			}
+			if version == Version4 {
+				if err := n.prepareVersion4Metadata(); err != nil {
+					return 0, 0, err
+				}
+				n.Size += Size(n.version4MetadataSize())
+			}
 		} else {
 			n.Size = 0
 		}
@@ -89,13 +121,16 @@ func (n *Needle) prepareWriteBuffer(version Version, writeBytes *bytes.Buffer) (
 				writeBytes.Write(header[0:2])
 				writeBytes.Write(n.Pairs)
 			}
+			if version == Version4 {
+				n.writeVersion4Metadata(writeBytes)
+			}
 		}
 		padding := PaddingLength(n.Size, version)
 		util.Uint32toBytes(header[0:NeedleChecksumSize], uint32(n.Checksum))
 		if version == Version2 {
 			writeBytes.Write(header[0 : NeedleChecksumSize+padding])
 		} else {
-			// version3
+			// version3 and version4
 			util.Uint64toBytes(header[NeedleChecksumSize:NeedleChecksumSize+TimestampSize], n.AppendAtNs)
 			writeBytes.Write(header[0 : NeedleChecksumSize+TimestampSize+padding])
 		}
@@ -106,6 +141,50 @@ func (n *Needle) prepareWriteBuffer(version Version, writeBytes *bytes.Buffer) (
 	return 0, 0, fmt.Errorf("Unsupported Version! (%d)", version)
 }
 
+// prepareVersion4Metadata validates the Version4 metadata, and computes the digest of the data to write
+func (n *Needle) prepareVersion4Metadata() error {
+	if n.ChecksumType > ChecksumSha256 {
+		return fmt.Errorf("unsupported checksum type %d", n.ChecksumType)
+	}
+	if len(n.ContentEncoding) > math.MaxUint8 {
+		return fmt.Errorf("content encoding %q is longer than %d", n.ContentEncoding, math.MaxUint8)
+	}
+	n.Digest = n.ChecksumType.Digest(n.Data)
+	return nil
+}
+
+// version4MetadataSize is the size of the metadata following the pairs in Version4:
+// checksum type, digest, content encoding size, content encoding, and the encryption key id if encrypted
+func (n *Needle) version4MetadataSize() int {
+	size := 1 + len(n.Digest) + 1 + len(n.ContentEncoding)
+	if n.IsEncrypted() {
+		size += DataKeyIdSize
+	}
+	return size
+}
+
+func (n *Needle) writeVersion4Metadata(writeBytes *bytes.Buffer) {
+	writeBytes.WriteByte(byte(n.ChecksumType))
+	writeBytes.Write(n.Digest)
+	writeBytes.WriteByte(uint8(len(n.ContentEncoding)))
+	writeBytes.Write(n.ContentEncoding)
+	if n.IsEncrypted() {
+		keyId := make([]byte, DataKeyIdSize)
+		util.Uint32toBytes(keyId, n.EncryptionKeyId)
+		writeBytes.Write(keyId)
+	}
+}
+
+// ToBytes lays out the needle as it is written to the .dat file, and sets n.Size for the index.
+// It is used to erasure code the needle directly into the ec shards.
+func (n *Needle) ToBytes(version Version) ([]byte, error) {
+	var writeBytes bytes.Buffer
+	if _, _, err := n.prepareWriteBuffer(version, &writeBytes); err != nil {
+		return nil, err
+	}
+	return writeBytes.Bytes(), nil
+}
+
 func (n *Needle) Append(w backend.BackendStorageFile, version Version) (offset uint64, size Size, actualSize int64, err error) {
 
 	if end, _, e := w.GetStat(); e == nil {
@@ -154,7 +233,7 @@ func WriteNeedleBlob(w backend.BackendStorageFile, dataSlice []byte, size Size,
 		return
 	}
 
-	if version == Version3 {
+	if version == Version3 || version == Version4 {
 		tsOffset := NeedleHeaderSize + size + NeedleChecksumSize
 		util.Uint64toBytes(dataSlice[tsOffset:tsOffset+TimestampSize], appendAtNs)
 	}
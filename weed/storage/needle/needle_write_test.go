@@ -1,11 +1,13 @@
 package needle
 
 import (
+	"bytes"
 	"os"
 	"testing"
 
 	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 func TestAppend(t *testing.T) {
@@ -62,3 +64,68 @@ func TestAppend(t *testing.T) {
 		t.Errorf("Fail to Append Needle.")
 	}
 }
+
+func TestAppendVersion4(t *testing.T) {
+	tempFile, err := os.CreateTemp("", ".dat")
+	if err != nil {
+		t.Fatalf("Fail TempFile. %v", err)
+	}
+	defer func() {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+	}()
+	datBackend := backend.NewDiskFile(tempFile)
+	defer datBackend.Close()
+
+	key := util.GenCipherKey()
+	for _, checksumType := range []ChecksumType{ChecksumCrc32c, ChecksumXxhash64, ChecksumSha256} {
+		data := []byte("some data to keep in a version 4 needle, " + checksumType.String())
+		n := &Needle{
+			Cookie:          types.Cookie(123),
+			Id:              types.NeedleId(123),
+			Data:            data,
+			ChecksumType:    checksumType,
+			ContentEncoding: []byte("gzip"),
+		}
+		n.SetIsCompressed()
+		if err = n.EncryptData(7, key, Version4); err != nil {
+			t.Fatalf("encrypt: %v", err)
+		}
+		offset, _, _, err := n.Append(datBackend, Version4)
+		if err != nil {
+			t.Fatalf("append %s: %v", checksumType, err)
+		}
+
+		size := n.Size
+		blob, err := ReadNeedleBlob(datBackend, int64(offset), size, Version4)
+		if err != nil {
+			t.Fatalf("read blob %s: %v", checksumType, err)
+		}
+		readNeedle := new(Needle)
+		if err = readNeedle.ReadBytes(blob, int64(offset), size, Version4); err != nil {
+			t.Fatalf("read %s: %v", checksumType, err)
+		}
+		if readNeedle.ChecksumType != checksumType || len(readNeedle.Digest) != checksumType.DigestSize() {
+			t.Errorf("read checksum %s with %d bytes digest, expected %s", readNeedle.ChecksumType, len(readNeedle.Digest), checksumType)
+		}
+		if string(readNeedle.ContentEncoding) != "gzip" || readNeedle.EncryptionKeyId != 7 || readNeedle.DataKeyId() != 7 {
+			t.Errorf("read content encoding %q, encryption key %d", readNeedle.ContentEncoding, readNeedle.EncryptionKeyId)
+		}
+		if err = readNeedle.DecryptData(key); err != nil {
+			t.Fatalf("decrypt %s: %v", checksumType, err)
+		}
+		if !bytes.Equal(readNeedle.Data, data) {
+			t.Errorf("read %s data %q, expected %q", checksumType, readNeedle.Data, data)
+		}
+
+		if checksumType == ChecksumCrc32c {
+			continue
+		}
+		// the CRC32-C only covers the data, the digest catches the corrupted data in its own copy
+		digestAt := bytes.Index(blob, n.Digest)
+		blob[digestAt] ^= 0xff
+		if err = new(Needle).ReadBytes(blob, int64(offset), size, Version4); err == nil {
+			t.Errorf("corrupted %s digest is not detected", checksumType)
+		}
+	}
+}
@@ -3,8 +3,17 @@ package needle
 type Version uint8
 
 const (
-	Version1       = Version(1)
-	Version2       = Version(2)
-	Version3       = Version(3)
+	Version1 = Version(1)
+	Version2 = Version(2)
+	Version3 = Version(3)
+	// Version4 adds the checksum type, the content encoding and the encryption key id to the needle body
+	Version4       = Version(4)
 	CurrentVersion = Version3
+	// LatestVersion is the latest version this server can read and write. The volumes are upgraded to it by vacuum.
+	LatestVersion = Version4
 )
+
+// IsSupportedVersion tells whether the version in the super block of a volume can be read
+func IsSupportedVersion(version Version) bool {
+	return Version1 <= version && version <= LatestVersion
+}
@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"sync"
 
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
 	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
@@ -18,8 +20,31 @@ const (
 	NeedleMapLevelDb                     // small memory footprint, 4MB total, 1 write buffer, 3 block buffer
 	NeedleMapLevelDbMedium               // medium memory footprint, 8MB total, 3 write buffer, 5 block buffer
 	NeedleMapLevelDbLarge                // large memory footprint, 12MB total, 4write buffer, 8 block buffer
+	NeedleMapLsm                         // one LevelDB shared by all volumes of a disk, 80MB total
 )
 
+var needleMapKindNames = map[NeedleMapKind]string{
+	NeedleMapInMemory:      "memory",
+	NeedleMapLevelDb:       "leveldb",
+	NeedleMapLevelDbMedium: "leveldbMedium",
+	NeedleMapLevelDbLarge:  "leveldbLarge",
+	NeedleMapLsm:           "lsm",
+}
+
+func (kind NeedleMapKind) String() string {
+	return needleMapKindNames[kind]
+}
+
+// ParseNeedleMapKind converts the -index option value
+func ParseNeedleMapKind(name string) (NeedleMapKind, error) {
+	for kind, kindName := range needleMapKindNames {
+		if kindName == name {
+			return kind, nil
+		}
+	}
+	return NeedleMapInMemory, fmt.Errorf("unknown index type %q", name)
+}
+
 type NeedleMapper interface {
 	Put(key NeedleId, offset Offset, size Size) error
 	Get(key NeedleId) (element *needle_map.NeedleValue, ok bool)
@@ -63,13 +88,27 @@ func (nm *baseNeedleMapper) appendToIndexFile(key NeedleId, offset Offset, size
 
 	nm.indexFileAccessLock.Lock()
 	defer nm.indexFileAccessLock.Unlock()
-	written, err := nm.indexFile.WriteAt(bytes, nm.indexFileOffset)
+	written, err := backend.WriteFileAt(nm.indexFile, bytes, nm.indexFileOffset)
 	if err == nil {
 		nm.indexFileOffset += int64(written)
 	}
 	return err
 }
 
+// switchIndexFile moves the needle map built from a copy of the .idx file to the .idx file, closing the copy
+func (nm *baseNeedleMapper) switchIndexFile(indexFile *os.File) error {
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return err
+	}
+	nm.indexFileAccessLock.Lock()
+	defer nm.indexFileAccessLock.Unlock()
+	nm.indexFile.Close()
+	nm.indexFile = indexFile
+	nm.indexFileOffset = stat.Size()
+	return nil
+}
+
 func (nm *baseNeedleMapper) Sync() error {
 	return nm.indexFile.Sync()
 }
@@ -26,6 +26,29 @@ const watermarkBatchSize = 10000
 
 var watermarkKey = []byte("idx_entry_watermark")
 
+// levelDbNeedleMapOptions trades memory for performance, depending on the LevelDB needle map kind
+func levelDbNeedleMapOptions(kind NeedleMapKind) *opt.Options {
+	switch kind {
+	case NeedleMapLevelDbMedium:
+		return &opt.Options{
+			BlockCacheCapacity:            4 * 1024 * 1024, // default value is 8MiB
+			WriteBuffer:                   2 * 1024 * 1024, // default value is 4MiB
+			CompactionTableSizeMultiplier: 10,              // default value is 1
+		}
+	case NeedleMapLevelDbLarge:
+		return &opt.Options{
+			BlockCacheCapacity:            8 * 1024 * 1024, // default value is 8MiB
+			WriteBuffer:                   4 * 1024 * 1024, // default value is 4MiB
+			CompactionTableSizeMultiplier: 10,              // default value is 1
+		}
+	}
+	return &opt.Options{
+		BlockCacheCapacity:            2 * 1024 * 1024, // default value is 8MiB
+		WriteBuffer:                   1 * 1024 * 1024, // default value is 4MiB
+		CompactionTableSizeMultiplier: 10,              // default value is 1
+	}
+}
+
 type LevelDbNeedleMap struct {
 	baseNeedleMapper
 	dbFileName    string
@@ -40,6 +63,11 @@ type LevelDbNeedleMap struct {
 }
 
 func NewLevelDbNeedleMap(dbFileName string, indexFile *os.File, opts *opt.Options, ldbTimeout int64) (m *LevelDbNeedleMap, err error) {
+	return newLevelDbNeedleMap(dbFileName, indexFile, opts, ldbTimeout, nil)
+}
+
+// newLevelDbNeedleMap opens the LevelDB needle map, with the known metrics of the .idx file if not nil
+func newLevelDbNeedleMap(dbFileName string, indexFile *os.File, opts *opt.Options, ldbTimeout int64, metric *mapMetric) (m *LevelDbNeedleMap, err error) {
 	m = &LevelDbNeedleMap{dbFileName: dbFileName}
 	m.indexFile = indexFile
 	if !isLevelDbFresh(dbFileName, indexFile) {
@@ -72,11 +100,12 @@ func NewLevelDbNeedleMap(dbFileName string, indexFile *os.File, opts *opt.Option
 			return
 		}
 	}
-	mm, indexLoadError := newNeedleMapMetricFromIndexFile(indexFile)
-	if indexLoadError != nil {
-		return nil, indexLoadError
+	if metric == nil {
+		if metric, err = newNeedleMapMetricFromIndexFile(indexFile); err != nil {
+			return nil, err
+		}
 	}
-	m.mapMetric = *mm
+	m.mapMetric = *metric
 	m.ldbTimeout = ldbTimeout
 	if m.ldbTimeout > 0 {
 		m.ldbOpts = opts
@@ -153,7 +182,6 @@ func (m *LevelDbNeedleMap) Get(key NeedleId) (element *needle_map.NeedleValue, o
 
 func (m *LevelDbNeedleMap) Put(key NeedleId, offset Offset, size Size) error {
 	var oldSize Size
-	var watermark uint64
 	if m.ldbTimeout > 0 {
 		m.ldbAccessLock.RLock()
 		defer m.ldbAccessLock.RUnlock()
@@ -170,6 +198,12 @@ func (m *LevelDbNeedleMap) Put(key NeedleId, offset Offset, size Size) error {
 	if err := m.appendToIndexFile(key, offset, size); err != nil {
 		return fmt.Errorf("cannot write to indexfile %s: %v", m.indexFile.Name(), err)
 	}
+	return m.writeIndexEntry(key, offset, size)
+}
+
+// writeIndexEntry writes the needle of an .idx entry, and moves the watermark every batch of entries
+func (m *LevelDbNeedleMap) writeIndexEntry(key NeedleId, offset Offset, size Size) error {
+	var watermark uint64
 	m.recordCount++
 	if m.recordCount%watermarkBatchSize != 0 {
 		watermark = 0
@@ -180,6 +214,32 @@ func (m *LevelDbNeedleMap) Put(key NeedleId, offset Offset, size Size) error {
 	return levelDbWrite(m.db, key, offset, size, watermark == 0, watermark)
 }
 
+// applyIndexEntry updates the map by an entry already in the .idx file
+func (m *LevelDbNeedleMap) applyIndexEntry(key NeedleId, offset Offset, size Size) error {
+	if m.ldbTimeout > 0 {
+		m.ldbAccessLock.RLock()
+		defer m.ldbAccessLock.RUnlock()
+		if loadErr := reloadLdb(m); loadErr != nil {
+			return loadErr
+		}
+	}
+	oldNeedle, found := m.Get(key)
+	if !offset.IsZero() && size.IsValid() {
+		var oldSize Size
+		if found {
+			oldSize = oldNeedle.Size
+		}
+		m.logPut(key, oldSize, size)
+		return m.writeIndexEntry(key, offset, size)
+	}
+	if !found || oldNeedle.Size.IsDeleted() {
+		m.recordCount++
+		return nil
+	}
+	m.logDelete(oldNeedle.Size)
+	return m.writeIndexEntry(key, oldNeedle.Offset, -oldNeedle.Size)
+}
+
 func getWatermark(db *leveldb.DB) uint64 {
 	data, err := db.Get(watermarkKey, nil)
 	if err != nil || len(data) != 8 {
@@ -220,7 +280,6 @@ func levelDbDelete(db *leveldb.DB, key NeedleId) error {
 }
 
 func (m *LevelDbNeedleMap) Delete(key NeedleId, offset Offset) error {
-	var watermark uint64
 	if m.ldbTimeout > 0 {
 		m.ldbAccessLock.RLock()
 		defer m.ldbAccessLock.RUnlock()
@@ -239,13 +298,7 @@ func (m *LevelDbNeedleMap) Delete(key NeedleId, offset Offset) error {
 	if err := m.appendToIndexFile(key, offset, TombstoneFileSize); err != nil {
 		return err
 	}
-	m.recordCount++
-	if m.recordCount%watermarkBatchSize != 0 {
-		watermark = 0
-	} else {
-		watermark = (m.recordCount / watermarkBatchSize) * watermarkBatchSize
-	}
-	return levelDbWrite(m.db, key, oldNeedle.Offset, -oldNeedle.Size, watermark == 0, watermark)
+	return m.writeIndexEntry(key, oldNeedle.Offset, -oldNeedle.Size)
 }
 
 func (m *LevelDbNeedleMap) Close() {
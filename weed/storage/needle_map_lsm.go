@@ -0,0 +1,429 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	leveldb_util "github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// The needle maps of all volumes in one index directory share one LevelDB,
+// so the memory used is bounded by its block cache and write buffer, instead of growing with the number of needles or volumes.
+//
+// Each volume's entries are prefixed by the volume id and the compaction revision:
+//
+//	'n' + vid + revision + needle id => offset + size
+//	'w' + vid + revision => number of .idx entries applied + the last applied .idx entry
+//
+// The .idx file is still written first and stays the source of truth. The watermark tells how much of it
+// is already applied, and its last entry detects a .idx file replaced by another one.
+const (
+	LsmIndexDbName        = "needle_index.ldb"
+	lsmNeedleKeyPrefix    = 'n'
+	lsmWatermarkKeyPrefix = 'w'
+	lsmVolumePrefixSize   = 4 + 2
+	lsmLoadingBatchSize   = 10000
+)
+
+var (
+	lsmIndexDbOptions = &opt.Options{
+		BlockCacheCapacity:            64 * 1024 * 1024, // default value is 8MiB
+		WriteBuffer:                   16 * 1024 * 1024, // default value is 4MiB
+		CompactionTableSizeMultiplier: 10,               // default value is 1
+		Filter:                        filter.NewBloomFilter(10),
+	}
+	lsmIndexDbs     = make(map[string]*lsmIndexDb)
+	lsmIndexDbsLock sync.Mutex
+)
+
+type lsmIndexDb struct {
+	dbFileName string
+	db         *leveldb.DB
+	refCount   int
+}
+
+func acquireLsmIndexDb(dirIdx string) (*lsmIndexDb, error) {
+	dbFileName := filepath.Join(dirIdx, LsmIndexDbName)
+	lsmIndexDbsLock.Lock()
+	defer lsmIndexDbsLock.Unlock()
+	if d, found := lsmIndexDbs[dbFileName]; found {
+		d.refCount++
+		return d, nil
+	}
+	glog.V(0).Infof("opening shared needle index %s", dbFileName)
+	db, err := leveldb.OpenFile(dbFileName, lsmIndexDbOptions)
+	if err != nil {
+		if errors.IsCorrupted(err) {
+			db, err = leveldb.RecoverFile(dbFileName, lsmIndexDbOptions)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("open needle index %s: %v", dbFileName, err)
+		}
+	}
+	d := &lsmIndexDb{dbFileName: dbFileName, db: db, refCount: 1}
+	lsmIndexDbs[dbFileName] = d
+	return d, nil
+}
+
+func (d *lsmIndexDb) release() {
+	lsmIndexDbsLock.Lock()
+	defer lsmIndexDbsLock.Unlock()
+	d.refCount--
+	if d.refCount > 0 {
+		return
+	}
+	delete(lsmIndexDbs, d.dbFileName)
+	glog.V(0).Infof("closing shared needle index %s", d.dbFileName)
+	if err := d.db.Close(); err != nil {
+		glog.Warningf("close needle index %s: %v", d.dbFileName, err)
+	}
+}
+
+// deleteRange removes the keys in [start, limit), in batches
+func (d *lsmIndexDb) deleteRange(start, limit []byte) (deleted int, err error) {
+	iter := d.db.NewIterator(&leveldb_util.Range{Start: start, Limit: limit}, nil)
+	defer iter.Release()
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		batch.Delete(bytes.Clone(iter.Key()))
+		deleted++
+		if batch.Len() >= lsmLoadingBatchSize {
+			if err = d.db.Write(batch, nil); err != nil {
+				return
+			}
+			batch.Reset()
+		}
+	}
+	if err = iter.Error(); err != nil {
+		return
+	}
+	if batch.Len() > 0 {
+		err = d.db.Write(batch, nil)
+	}
+	return
+}
+
+type LsmNeedleMap struct {
+	baseNeedleMapper
+	indexDb      *lsmIndexDb
+	volumeId     needle.VolumeId
+	revision     uint16
+	volumePrefix []byte
+	recordCount  uint64 // the number of .idx entries applied
+}
+
+func newLsmNeedleMap(dirIdx string, volumeId needle.VolumeId, revision uint16) (*LsmNeedleMap, error) {
+	indexDb, err := acquireLsmIndexDb(dirIdx)
+	if err != nil {
+		return nil, err
+	}
+	m := &LsmNeedleMap{
+		indexDb:      indexDb,
+		volumeId:     volumeId,
+		revision:     revision,
+		volumePrefix: make([]byte, lsmVolumePrefixSize),
+	}
+	util.Uint32toBytes(m.volumePrefix[0:4], uint32(volumeId))
+	util.Uint16toBytes(m.volumePrefix[4:6], revision)
+	return m, nil
+}
+
+// NewLsmNeedleMap loads the volume's needle map in the shared needle index of the index directory,
+// applying the .idx entries written after the last time.
+func NewLsmNeedleMap(dirIdx string, volumeId needle.VolumeId, revision uint16, indexFile *os.File) (m *LsmNeedleMap, err error) {
+	if m, err = newLsmNeedleMap(dirIdx, volumeId, revision); err != nil {
+		return nil, err
+	}
+	if err = m.attachIndexFile(indexFile); err != nil {
+		m.indexDb.release()
+		return nil, err
+	}
+	if err = m.deleteOtherRevisions(); err != nil {
+		glog.Warningf("delete stale needle index of volume %d: %v", volumeId, err)
+	}
+	return m, nil
+}
+
+// attachIndexFile catches up with the .idx file, and loads the metrics from it
+func (m *LsmNeedleMap) attachIndexFile(indexFile *os.File) error {
+	m.indexFile = indexFile
+	stat, err := indexFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat file %s: %v", indexFile.Name(), err)
+	}
+	m.indexFileOffset = stat.Size()
+	entryCount := uint64(stat.Size() / NeedleMapEntrySize)
+
+	watermark, isFresh := m.readWatermark(indexFile, entryCount)
+	if !isFresh {
+		glog.V(0).Infof("rebuilding needle index of volume %d from %s", m.volumeId, indexFile.Name())
+		if err = m.deleteEntries(m.revision, m.revision); err != nil {
+			return err
+		}
+		watermark = 0
+	}
+	if watermark < entryCount {
+		glog.V(0).Infof("loading %d entries of %s to needle index", entryCount-watermark, indexFile.Name())
+		if err = m.applyIndexFile(indexFile, watermark); err != nil {
+			return fmt.Errorf("load %s to needle index: %v", indexFile.Name(), err)
+		}
+	}
+	m.recordCount = entryCount
+
+	mm, err := newNeedleMapMetricFromIndexFile(indexFile)
+	if err != nil {
+		return err
+	}
+	m.mapMetric = *mm
+	return nil
+}
+
+// readWatermark checks the number of applied .idx entries, and whether the last applied entry is still in the .idx file
+func (m *LsmNeedleMap) readWatermark(indexFile *os.File, entryCount uint64) (watermark uint64, isFresh bool) {
+	data, err := m.indexDb.db.Get(m.watermarkKey(), nil)
+	if err == leveldb.ErrNotFound {
+		return 0, true
+	}
+	if err != nil || len(data) != 8+NeedleMapEntrySize {
+		return 0, false
+	}
+	watermark = util.BytesToUint64(data[0:8])
+	if watermark == 0 {
+		return 0, true
+	}
+	if watermark > entryCount {
+		return 0, false
+	}
+	entry, err := readIndexEntryAtOffset(indexFile, int64(watermark-1)*NeedleMapEntrySize)
+	if err != nil || !bytes.Equal(entry, data[8:]) {
+		return 0, false
+	}
+	return watermark, true
+}
+
+func (m *LsmNeedleMap) applyIndexFile(indexFile *os.File, startFrom uint64) error {
+	batch := new(leveldb.Batch)
+	count := startFrom
+	var lastEntry []byte
+	err := idx.WalkIndexFile(indexFile, startFrom, func(key NeedleId, offset Offset, size Size) error {
+		if !offset.IsZero() && size.IsValid() {
+			batch.Put(m.needleKey(key), needle_map.ToBytes(key, offset, size)[NeedleIdSize:])
+		} else {
+			batch.Delete(m.needleKey(key))
+		}
+		count++
+		lastEntry = needle_map.ToBytes(key, offset, size)
+		if batch.Len() >= lsmLoadingBatchSize {
+			batch.Put(m.watermarkKey(), watermarkValue(count, lastEntry))
+			if err := m.indexDb.db.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if batch.Len() > 0 {
+		batch.Put(m.watermarkKey(), watermarkValue(count, lastEntry))
+		return m.indexDb.db.Write(batch, nil)
+	}
+	return nil
+}
+
+func (m *LsmNeedleMap) Get(key NeedleId) (element *needle_map.NeedleValue, ok bool) {
+	data, err := m.indexDb.db.Get(m.needleKey(key), nil)
+	if err != nil || len(data) != OffsetSize+SizeSize {
+		return nil, false
+	}
+	offset := BytesToOffset(data[0:OffsetSize])
+	size := BytesToSize(data[OffsetSize : OffsetSize+SizeSize])
+	return &needle_map.NeedleValue{Key: key, Offset: offset, Size: size}, true
+}
+
+func (m *LsmNeedleMap) Put(key NeedleId, offset Offset, size Size) error {
+	var oldSize Size
+	if oldNeedle, ok := m.Get(key); ok {
+		oldSize = oldNeedle.Size
+	}
+	m.logPut(key, oldSize, size)
+	// write to index file first
+	if err := m.appendToIndexFile(key, offset, size); err != nil {
+		return fmt.Errorf("cannot write to indexfile %s: %v", m.indexFile.Name(), err)
+	}
+	return m.write(key, offset, size, needle_map.ToBytes(key, offset, size))
+}
+
+func (m *LsmNeedleMap) Delete(key NeedleId, offset Offset) error {
+	oldNeedle, found := m.Get(key)
+	if !found || oldNeedle.Size.IsDeleted() {
+		return nil
+	}
+	m.logDelete(oldNeedle.Size)
+
+	// write to index file first
+	if err := m.appendToIndexFile(key, offset, TombstoneFileSize); err != nil {
+		return err
+	}
+	// keep the deleted size, as the LevelDB needle map does
+	return m.write(key, oldNeedle.Offset, -oldNeedle.Size, needle_map.ToBytes(key, offset, TombstoneFileSize))
+}
+
+// applyIndexEntry updates the map by an entry already in the .idx file
+func (m *LsmNeedleMap) applyIndexEntry(key NeedleId, offset Offset, size Size) error {
+	oldNeedle, found := m.Get(key)
+	if !offset.IsZero() && size.IsValid() {
+		var oldSize Size
+		if found {
+			oldSize = oldNeedle.Size
+		}
+		m.logPut(key, oldSize, size)
+		return m.write(key, offset, size, needle_map.ToBytes(key, offset, size))
+	}
+	if !found || oldNeedle.Size.IsDeleted() {
+		m.recordCount++
+		return nil
+	}
+	m.logDelete(oldNeedle.Size)
+	return m.write(key, oldNeedle.Offset, -oldNeedle.Size, needle_map.ToBytes(key, offset, size))
+}
+
+// write updates the needle, and the watermark to the appended .idx entry, together
+func (m *LsmNeedleMap) write(key NeedleId, offset Offset, size Size, indexEntry []byte) error {
+	m.recordCount++
+	batch := new(leveldb.Batch)
+	batch.Put(m.needleKey(key), needle_map.ToBytes(key, offset, size)[NeedleIdSize:])
+	batch.Put(m.watermarkKey(), watermarkValue(m.recordCount, indexEntry))
+	if err := m.indexDb.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("failed to write needle index: %v", err)
+	}
+	return nil
+}
+
+func (m *LsmNeedleMap) Close() {
+	if m.indexFile != nil {
+		indexFileName := m.indexFile.Name()
+		if err := m.indexFile.Sync(); err != nil {
+			glog.Warningf("sync file %s failed: %v", indexFileName, err)
+		}
+		if err := m.indexFile.Close(); err != nil {
+			glog.Warningf("close index file %s failed: %v", indexFileName, err)
+		}
+	}
+	if m.indexDb != nil {
+		m.indexDb.release()
+		m.indexDb = nil
+	}
+}
+
+func (m *LsmNeedleMap) Destroy() error {
+	if err := m.deleteEntries(0, 0xffff); err != nil {
+		glog.Warningf("delete needle index of volume %d: %v", m.volumeId, err)
+	}
+	m.Close()
+	return os.Remove(m.indexFile.Name())
+}
+
+// deleteAllEntries removes the volume from the shared needle index, keeping the .idx file
+func (m *LsmNeedleMap) deleteAllEntries() error {
+	return m.deleteEntries(0, 0xffff)
+}
+
+// deleteOtherRevisions removes the entries left by the earlier compactions, or by an unfinished one
+func (m *LsmNeedleMap) deleteOtherRevisions() error {
+	if m.revision > 0 {
+		if err := m.deleteEntries(0, m.revision-1); err != nil {
+			return err
+		}
+	}
+	if m.revision < 0xffff {
+		return m.deleteEntries(m.revision+1, 0xffff)
+	}
+	return nil
+}
+
+// deleteEntries removes the needles and watermarks of the volume, for the compaction revisions in [from, to]
+func (m *LsmNeedleMap) deleteEntries(from, to uint16) error {
+	for _, keyPrefix := range []byte{lsmNeedleKeyPrefix, lsmWatermarkKeyPrefix} {
+		start := lsmVolumeKey(keyPrefix, m.volumeId, from)
+		limit := leveldb_util.BytesPrefix(lsmVolumeKey(keyPrefix, m.volumeId, to)).Limit
+		deleted, err := m.indexDb.deleteRange(start, limit)
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			glog.V(1).Infof("deleted %d %c entries of volume %d revision %d~%d from %s", deleted, keyPrefix, m.volumeId, from, to, m.indexDb.dbFileName)
+		}
+	}
+	return nil
+}
+
+// DoOffsetLoading loads the .cpx entries of the compacted volume under its new compaction revision,
+// so the current needle map keeps working until the compaction is committed.
+func (m *LsmNeedleMap) DoOffsetLoading(v *Volume, indexFile *os.File, startFrom uint64) error {
+	glog.V(0).Infof("loading idx to needle index from offset %d for file: %s", startFrom, indexFile.Name())
+	if startFrom == 0 {
+		if err := m.deleteEntries(m.revision, m.revision); err != nil {
+			return err
+		}
+	}
+	return m.applyIndexFile(indexFile, startFrom)
+}
+
+func (m *LsmNeedleMap) UpdateNeedleMap(v *Volume, indexFile *os.File, opts *opt.Options, ldbTimeout int64) error {
+	if v.nm != nil {
+		v.nm.Close()
+		v.nm = nil
+	}
+	if err := m.attachIndexFile(indexFile); err != nil {
+		return err
+	}
+	if err := m.deleteOtherRevisions(); err != nil {
+		glog.Warningf("delete stale needle index of volume %d: %v", m.volumeId, err)
+	}
+	v.nm = m
+	v.tmpNm = nil
+	return nil
+}
+
+func (m *LsmNeedleMap) needleKey(key NeedleId) []byte {
+	b := make([]byte, 1+lsmVolumePrefixSize+NeedleIdSize)
+	b[0] = lsmNeedleKeyPrefix
+	copy(b[1:], m.volumePrefix)
+	NeedleIdToBytes(b[1+lsmVolumePrefixSize:], key)
+	return b
+}
+
+func (m *LsmNeedleMap) watermarkKey() []byte {
+	return lsmVolumeKey(lsmWatermarkKeyPrefix, m.volumeId, m.revision)
+}
+
+func lsmVolumeKey(keyPrefix byte, volumeId needle.VolumeId, revision uint16) []byte {
+	b := make([]byte, 1+lsmVolumePrefixSize)
+	b[0] = keyPrefix
+	util.Uint32toBytes(b[1:5], uint32(volumeId))
+	util.Uint16toBytes(b[5:7], revision)
+	return b
+}
+
+func watermarkValue(count uint64, lastEntry []byte) []byte {
+	b := make([]byte, 8+NeedleMapEntrySize)
+	util.Uint64toBytes(b[0:8], count)
+	copy(b[8:], lastEntry)
+	return b
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func TestLsmNeedleMapSharedIndex(t *testing.T) {
+	dir := t.TempDir()
+	v1, err := NewVolume(dir, dir, "", 1, NeedleMapLsm, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume 1 creation: %v", err)
+	}
+	v2, err := NewVolume(dir, dir, "", 2, NeedleMapLsm, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume 2 creation: %v", err)
+	}
+	defer v2.Close()
+
+	for i := 1; i <= 100; i++ {
+		if _, _, _, err := v1.writeNeedle2(newRandomNeedle(uint64(i)), true, false, 0); err != nil {
+			t.Fatalf("write volume 1 needle %d: %v", i, err)
+		}
+		if i%2 == 0 {
+			if _, _, _, err := v2.writeNeedle2(newRandomNeedle(uint64(i)), true, false, 0); err != nil {
+				t.Fatalf("write volume 2 needle %d: %v", i, err)
+			}
+		}
+	}
+	for i := 1; i <= 10; i++ {
+		if _, err := v1.deleteNeedle2(newEmptyNeedle(uint64(i))); err != nil {
+			t.Fatalf("delete volume 1 needle %d: %v", i, err)
+		}
+	}
+	if v1.FileCount() != 100 || v1.DeletedCount() != 10 || v2.FileCount() != 50 {
+		t.Fatalf("unexpected counts: %d %d %d", v1.FileCount(), v1.DeletedCount(), v2.FileCount())
+	}
+	if _, found := v2.nm.Get(1); found {
+		t.Errorf("volume 2 sees needle 1 of volume 1")
+	}
+
+	// append to the .idx file while the volume is closed, with another needle map kind
+	v1.Close()
+	v1, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume 1 reloading in memory: %v", err)
+	}
+	for i := 101; i <= 110; i++ {
+		if _, _, _, err := v1.writeNeedle2(newRandomNeedle(uint64(i)), true, false, 0); err != nil {
+			t.Fatalf("write volume 1 needle %d: %v", i, err)
+		}
+	}
+	v1.Close()
+	v1, err = NewVolume(dir, dir, "", 1, NeedleMapLsm, nil, nil, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume 1 reloading: %v", err)
+	}
+	if v1.nm.(*LsmNeedleMap).recordCount != 120 {
+		t.Errorf("applied %d .idx entries", v1.nm.(*LsmNeedleMap).recordCount)
+	}
+	for i := 1; i <= 110; i++ {
+		nv, found := v1.nm.Get(types.NeedleId(i))
+		if isLive := found && !nv.Size.IsDeleted(); isLive != (i > 10) {
+			t.Errorf("volume 1 needle %d is live: %v", i, isLive)
+		}
+	}
+
+	// replace the .idx file with a different one of the same length
+	v1.Close()
+	idxFileName := filepath.Join(dir, "1.idx")
+	idxData, err := os.ReadFile(idxFileName)
+	if err != nil {
+		t.Fatalf("read %s: %v", idxFileName, err)
+	}
+	copy(idxData[len(idxData)-types.NeedleMapEntrySize:], idxData[:types.NeedleMapEntrySize])
+	replacedIdxFileName := filepath.Join(t.TempDir(), "1.idx")
+	if err = os.WriteFile(replacedIdxFileName, idxData, 0644); err != nil {
+		t.Fatalf("write %s: %v", replacedIdxFileName, err)
+	}
+	replacedIdxFile, err := os.OpenFile(replacedIdxFileName, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("open %s: %v", replacedIdxFileName, err)
+	}
+	m, err := NewLsmNeedleMap(dir, 1, 0, replacedIdxFile)
+	if err != nil {
+		t.Fatalf("load replaced .idx file: %v", err)
+	}
+	if _, found := m.Get(110); found {
+		t.Errorf("stale needle 110 is found after the .idx file is replaced")
+	}
+	m.Close()
+
+	v1, err = NewVolume(dir, dir, "", 1, NeedleMapLsm, nil, nil, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume 1 reloading: %v", err)
+	}
+	if _, found := v1.nm.Get(110); !found {
+		t.Errorf("needle 110 is not found after the .idx file is restored")
+	}
+
+	if err = v1.Destroy(false); err != nil {
+		t.Fatalf("destroy volume 1: %v", err)
+	}
+	if _, found := v2.nm.Get(2); !found {
+		t.Errorf("volume 2 needle 2 is lost")
+	}
+	v2.Close()
+
+	// the shared index is closed with the last volume
+	lsmIndexDbsLock.Lock()
+	defer lsmIndexDbsLock.Unlock()
+	if _, found := lsmIndexDbs[filepath.Join(dir, LsmIndexDbName)]; found {
+		t.Errorf("shared index is still open")
+	}
+}
+
+func TestSwitchNeedleMap(t *testing.T) {
+	dir := t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+
+	infos := make([]*needleInfo, 600)
+	for i := 1; i <= 100; i++ {
+		doSomeWritesDeletes(i, v, t, infos)
+	}
+
+	for j, kind := range []NeedleMapKind{NeedleMapLsm, NeedleMapLevelDb, NeedleMapLevelDbLarge, NeedleMapLsm, NeedleMapInMemory} {
+		if err = v.SwitchNeedleMap(kind); err != nil {
+			t.Fatalf("switch to %s: %v", kind, err)
+		}
+		if v.needleMapKind != kind {
+			t.Fatalf("needle map kind is %s instead of %s", v.needleMapKind, kind)
+		}
+		if _, err = os.Stat(v.FileName(".ldb")); isLevelDbNeedleMapKind(kind) != (err == nil) {
+			t.Errorf("%s: .ldb stat: %v", kind, err)
+		}
+		lastId := 100 * (j + 1)
+		for i := lastId + 1; i <= lastId+100; i++ {
+			doSomeWritesDeletes(i, v, t, infos)
+		}
+		for i := 1; i <= lastId+100; i++ {
+			n := newEmptyNeedle(uint64(i))
+			size, err := v.readNeedle(n, nil, nil)
+			if infos[i-1].size == 0 {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: read needle %d: %v", kind, i, err)
+			}
+			if infos[i-1].size != types.Size(size) || infos[i-1].crc != n.Checksum {
+				t.Fatalf("%s: needle %d mismatch", kind, i)
+			}
+		}
+	}
+
+	// the volume is no longer in the shared index
+	m, err := newLsmNeedleMap(dir, 1, v.SuperBlock.CompactionRevision)
+	if err != nil {
+		t.Fatalf("open shared index: %v", err)
+	}
+	defer m.Close()
+	if _, found := m.Get(1); found {
+		t.Errorf("shared index still has the volume after switching away")
+	}
+}
+
+func TestSwitchNeedleMapCatchUp(t *testing.T) {
+	dir := t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+
+	infos := make([]*needleInfo, 300)
+	lastId := 0
+	for _, kind := range []NeedleMapKind{NeedleMapLsm, NeedleMapLevelDb, NeedleMapInMemory} {
+		oldKind := v.needleMapKind
+		for i := lastId + 1; i <= lastId+50; i++ {
+			doSomeWritesDeletes(i, v, t, infos)
+		}
+		switched, err := v.buildSwitchedNeedleMap(kind)
+		if err != nil {
+			t.Fatalf("build %s: %v", kind, err)
+		}
+		// written while the new map is built, and deleted from the copied part
+		for i := lastId + 51; i <= lastId+100; i++ {
+			doSomeWritesDeletes(i, v, t, infos)
+		}
+		if _, err = v.deleteNeedle2(newEmptyNeedle(uint64(lastId + 1))); err != nil {
+			t.Fatalf("delete needle %d: %v", lastId+1, err)
+		}
+		infos[lastId].size = 0
+		lastId += 100
+		if err = v.commitSwitchedNeedleMap(oldKind, switched); err != nil {
+			t.Fatalf("switch to %s: %v", kind, err)
+		}
+		if v.nm.MaxFileKey() != types.NeedleId(lastId) {
+			t.Errorf("%s: max file key %d", kind, v.nm.MaxFileKey())
+		}
+		for i := 1; i <= lastId; i++ {
+			n := newEmptyNeedle(uint64(i))
+			size, err := v.readNeedle(n, nil, nil)
+			if infos[i-1].size == 0 {
+				if err == nil {
+					t.Fatalf("%s: deleted needle %d is read", kind, i)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: read needle %d: %v", kind, i, err)
+			}
+			if infos[i-1].size != types.Size(size) || infos[i-1].crc != n.Checksum {
+				t.Fatalf("%s: needle %d mismatch", kind, i)
+			}
+		}
+	}
+	if _, err = os.Stat(v.FileName(".idx.switch")); err == nil {
+		t.Errorf("the copy of the .idx file is kept")
+	}
+
+	// the writes after switching are appended to the .idx file
+	if _, _, _, err = v.writeNeedle2(newRandomNeedle(uint64(lastId+1)), true, false, 0); err != nil {
+		t.Fatalf("write needle %d: %v", lastId+1, err)
+	}
+	v.Close()
+	reloaded, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume reloading: %v", err)
+	}
+	defer reloaded.Close()
+	if _, found := reloaded.nm.Get(types.NeedleId(lastId + 1)); !found {
+		t.Errorf("needle %d is not in the .idx file", lastId+1)
+	}
+}
@@ -60,6 +60,18 @@ func (nm *NeedleMap) Put(key NeedleId, offset Offset, size Size) error {
 	nm.logPut(key, oldSize, size)
 	return nm.appendToIndexFile(key, offset, size)
 }
+
+// applyIndexEntry updates the map by an entry already in the .idx file
+func (nm *NeedleMap) applyIndexEntry(key NeedleId, offset Offset, size Size) error {
+	if !offset.IsZero() && size.IsValid() {
+		_, oldSize := nm.m.Set(key, offset, size)
+		nm.logPut(key, oldSize, size)
+	} else {
+		nm.logDelete(nm.m.Delete(key))
+	}
+	return nil
+}
+
 func (nm *NeedleMap) Get(key NeedleId) (element *needle_map.NeedleValue, ok bool) {
 	element, ok = nm.m.Get(NeedleId(key))
 	return
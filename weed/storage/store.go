@@ -1,12 +1,14 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/seaweedfs/seaweedfs/weed/pb"
 	"github.com/seaweedfs/seaweedfs/weed/storage/volume_info"
@@ -16,6 +18,7 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
 	"github.com/seaweedfs/seaweedfs/weed/stats"
 	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
@@ -35,7 +38,8 @@ type ReadOption struct {
 	// response
 	IsMetaOnly     bool // read status
 	VolumeRevision uint16
-	IsOutOfRange   bool // whether read over MaxPossibleVolumeSize
+	SlideRevision  uint32 // the needles moved by an in place compaction
+	IsOutOfRange   bool   // whether read over MaxPossibleVolumeSize
 
 	// If HasSlowRead is set to true:
 	//  * read requests and write requests compete for the lock.
@@ -72,6 +76,7 @@ type Store struct {
 	DeletedVolumesChan  chan master_pb.VolumeShortInformationMessage
 	NewEcShardsChan     chan master_pb.VolumeEcShardInformationMessage
 	DeletedEcShardsChan chan master_pb.VolumeEcShardInformationMessage
+	CorruptNeedlesChan  chan *master_pb.CorruptNeedleMessage
 	isStopping          bool
 }
 
@@ -105,6 +110,8 @@ func NewStore(grpcDialOption grpc.DialOption, ip string, port int, grpcPort int,
 	s.NewEcShardsChan = make(chan master_pb.VolumeEcShardInformationMessage, 3)
 	s.DeletedEcShardsChan = make(chan master_pb.VolumeEcShardInformationMessage, 3)
 
+	s.CorruptNeedlesChan = make(chan *master_pb.CorruptNeedleMessage, 1024)
+
 	return
 }
 func (s *Store) AddVolume(volumeId needle.VolumeId, collection string, needleMapKind NeedleMapKind, replicaPlacement string, ttlString string, preallocate int64, MemoryMapMaxSizeMb uint32, diskType DiskType, ldbTimeout int64) error {
@@ -140,7 +147,7 @@ func (s *Store) findVolume(vid needle.VolumeId) *Volume {
 	return nil
 }
 func (s *Store) FindFreeLocation(filterFn func(location *DiskLocation) bool) (ret *DiskLocation) {
-	max := int32(0)
+	max := int64(0)
 	for _, location := range s.Locations {
 		if filterFn != nil && !filterFn(location) {
 			continue
@@ -148,10 +155,10 @@ func (s *Store) FindFreeLocation(filterFn func(location *DiskLocation) bool) (re
 		if location.isDiskSpaceLow {
 			continue
 		}
-		currentFreeCount := location.MaxVolumeCount - int32(location.VolumesLen())
-		currentFreeCount *= erasure_coding.DataShardsCount
-		currentFreeCount -= int32(location.EcShardCount())
-		currentFreeCount /= erasure_coding.DataShardsCount
+		currentFreeCount := int64(location.MaxVolumeCount - int32(location.VolumesLen()))
+		currentFreeCount *= erasure_coding.VolumeSlotUnits
+		currentFreeCount -= location.EcShardSlotUnits()
+		currentFreeCount /= erasure_coding.VolumeSlotUnits
 		if currentFreeCount > max {
 			max = currentFreeCount
 			ret = location
@@ -418,13 +425,13 @@ func (s *Store) Close() {
 	}
 }
 
-func (s *Store) WriteVolumeNeedle(i needle.VolumeId, n *needle.Needle, checkCookie bool, fsync bool) (isUnchanged bool, err error) {
+func (s *Store) WriteVolumeNeedle(i needle.VolumeId, n *needle.Needle, checkCookie bool, fsync bool, groupCommitDelay time.Duration) (isUnchanged bool, err error) {
 	if v := s.findVolume(i); v != nil {
 		if v.IsReadOnly() {
 			err = fmt.Errorf("volume %d is read only", i)
 			return
 		}
-		_, _, isUnchanged, err = v.writeNeedle2(n, checkCookie, fsync && s.isStopping)
+		_, _, isUnchanged, err = v.writeNeedle2(n, checkCookie, fsync && s.isStopping, groupCommitDelay)
 		return
 	}
 	glog.V(0).Infoln("volume", i, "not found!")
@@ -602,6 +609,40 @@ func (s *Store) ConfigureVolume(i needle.VolumeId, replication string) error {
 	return fmt.Errorf("volume %d not found on disk", i)
 }
 
+// ConfigureVolumeGroupCommit changes the group commit delay of the volume, taking effect for the next writes
+func (s *Store) ConfigureVolumeGroupCommit(i needle.VolumeId, delay time.Duration) error {
+	v := s.findVolume(i)
+	if v == nil {
+		return fmt.Errorf("volume %d not found", i)
+	}
+	if delay < 0 || delay > 0 && delay < time.Millisecond {
+		return fmt.Errorf("group commit delay %v should be 0 or at least 1ms", delay)
+	}
+	return v.PersistGroupCommitDelay(delay)
+}
+
+// UpgradeVolume converts the volume to the needle version when it is vacuumed next time
+func (s *Store) UpgradeVolume(i needle.VolumeId, version needle.Version) error {
+	v := s.findVolume(i)
+	if v == nil {
+		return fmt.Errorf("volume %d not found", i)
+	}
+	return v.PersistUpgradeVersion(version)
+}
+
+// RotateVolumeDataKeys re-wraps the data keys of the volume with the current master key, adopting the data keys of the
+// other replicas if given, and returns the wrapped data keys
+func (s *Store) RotateVolumeDataKeys(i needle.VolumeId, rotateDataKey bool, replicaKeys []*volume_server_pb.VolumeDataKey) ([]*volume_server_pb.VolumeDataKey, error) {
+	v := s.findVolume(i)
+	if v == nil {
+		return nil, fmt.Errorf("volume %d not found", i)
+	}
+	if err := v.RotateDataKeys(rotateDataKey, replicaKeys); err != nil {
+		return nil, err
+	}
+	return v.DataKeys(), nil
+}
+
 func (s *Store) SetVolumeSizeLimit(x uint64) {
 	atomic.StoreUint64(&s.volumeSizeLimit, x)
 }
@@ -635,8 +676,8 @@ func (s *Store) MaybeAdjustVolumeMax() (hasChanges bool) {
 				unclaimedSpaces -= int64(unusedSpace)
 			}
 			volCount := diskLocation.VolumesLen()
-			ecShardCount := diskLocation.EcShardCount()
-			maxVolumeCount := int32(volCount) + int32((ecShardCount+erasure_coding.DataShardsCount)/erasure_coding.DataShardsCount)
+			ecShardSlotUnits := diskLocation.EcShardSlotUnits()
+			maxVolumeCount := int32(volCount) + int32((ecShardSlotUnits+erasure_coding.VolumeSlotUnits)/erasure_coding.VolumeSlotUnits)
 			if unclaimedSpaces > int64(volumeSizeLimit) {
 				maxVolumeCount += int32(uint64(unclaimedSpaces)/volumeSizeLimit) - 1
 			}
@@ -652,3 +693,38 @@ func (s *Store) MaybeAdjustVolumeMax() (hasChanges bool) {
 	stats.VolumeServerMaxVolumeCounter.Set(float64(newMaxVolumeCount))
 	return
 }
+
+// SwitchNeedleMapKind switches the needle map of one volume, or of all volumes and the new ones if vid is 0.
+// Volumes loaded later, e.g. after a restart, still use the -index option.
+func (s *Store) SwitchNeedleMapKind(vid needle.VolumeId, kind NeedleMapKind) (switched int, err error) {
+	if vid != 0 {
+		v := s.findVolume(vid)
+		if v == nil {
+			return 0, fmt.Errorf("volume %d not found", vid)
+		}
+		if err = v.SwitchNeedleMap(kind); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	s.NeedleMapKind = kind
+	var volumes []*Volume
+	for _, location := range s.Locations {
+		location.volumesLock.RLock()
+		for _, v := range location.volumes {
+			volumes = append(volumes, v)
+		}
+		location.volumesLock.RUnlock()
+	}
+	var errs []error
+	for _, v := range volumes {
+		if switchErr := v.SwitchNeedleMap(kind); switchErr != nil {
+			glog.Warningf("switch volume %d needle map to %s: %v", v.Id, kind, switchErr)
+			errs = append(errs, switchErr)
+			continue
+		}
+		switched++
+	}
+	return switched, errors.Join(errs...)
+}
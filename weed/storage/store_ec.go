@@ -10,8 +10,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/klauspost/reedsolomon"
-
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
@@ -56,11 +54,13 @@ func (s *Store) MountEcShards(collection string, vid needle.VolumeId, shardId er
 			var shardBits erasure_coding.ShardBits
 
 			s.NewEcShardsChan <- master_pb.VolumeEcShardInformationMessage{
-				Id:          uint32(vid),
-				Collection:  collection,
-				EcIndexBits: uint32(shardBits.AddShardId(shardId)),
-				DiskType:    string(location.DiskType),
-				DestroyTime: ecVolume.DestroyTime,
+				Id:           uint32(vid),
+				Collection:   collection,
+				EcIndexBits:  uint32(shardBits.AddShardId(shardId)),
+				DiskType:     string(location.DiskType),
+				DestroyTime:  ecVolume.DestroyTime,
+				DataShards:   uint32(ecVolume.Scheme.DataShards),
+				ParityShards: uint32(ecVolume.Scheme.ParityShards),
 			}
 			return nil
 		} else if err == os.ErrNotExist {
@@ -167,6 +167,9 @@ func (s *Store) ReadEcShardNeedle(vid needle.VolumeId, n *needle.Needle, onReadS
 			if err != nil {
 				return 0, fmt.Errorf("readbytes: %v", err)
 			}
+			if err = decryptNeedle(n, localEcVolume.DataKeys); err != nil {
+				return 0, err
+			}
 
 			return len(bytes), nil
 		}
@@ -239,11 +242,11 @@ func forgetShardId(ecVolume *erasure_coding.EcVolume, shardId erasure_coding.Sha
 func (s *Store) cachedLookupEcShardLocations(ecVolume *erasure_coding.EcVolume) (err error) {
 
 	shardCount := len(ecVolume.ShardLocations)
-	if shardCount < erasure_coding.DataShardsCount &&
+	if shardCount < ecVolume.Scheme.DataShards &&
 		ecVolume.ShardLocationsRefreshTime.Add(11*time.Second).After(time.Now()) ||
-		shardCount == erasure_coding.TotalShardsCount &&
+		shardCount == ecVolume.Scheme.TotalShards() &&
 			ecVolume.ShardLocationsRefreshTime.Add(37*time.Minute).After(time.Now()) ||
-		shardCount >= erasure_coding.DataShardsCount &&
+		shardCount >= ecVolume.Scheme.DataShards &&
 			ecVolume.ShardLocationsRefreshTime.Add(7*time.Minute).After(time.Now()) {
 		// still fresh
 		return nil
@@ -259,8 +262,8 @@ func (s *Store) cachedLookupEcShardLocations(ecVolume *erasure_coding.EcVolume)
 		if err != nil {
 			return fmt.Errorf("lookup ec volume %d: %v", ecVolume.VolumeId, err)
 		}
-		if len(resp.ShardIdLocations) < erasure_coding.DataShardsCount {
-			return fmt.Errorf("only %d shards found but %d required", len(resp.ShardIdLocations), erasure_coding.DataShardsCount)
+		if len(resp.ShardIdLocations) < ecVolume.Scheme.DataShards {
+			return fmt.Errorf("only %d shards found but %d required", len(resp.ShardIdLocations), ecVolume.Scheme.DataShards)
 		}
 
 		ecVolume.ShardLocationsLock.Lock()
@@ -340,12 +343,12 @@ func (s *Store) doReadRemoteEcShardInterval(sourceDataNode pb.ServerAddress, nee
 func (s *Store) recoverOneRemoteEcShardInterval(needleId types.NeedleId, ecVolume *erasure_coding.EcVolume, shardIdToRecover erasure_coding.ShardId, buf []byte, offset int64) (n int, is_deleted bool, err error) {
 	glog.V(3).Infof("recover ec shard %d.%d from other locations", ecVolume.VolumeId, shardIdToRecover)
 
-	enc, err := reedsolomon.New(erasure_coding.DataShardsCount, erasure_coding.ParityShardsCount)
+	enc, err := ecVolume.Scheme.NewEncoder()
 	if err != nil {
 		return 0, false, fmt.Errorf("failed to create encoder: %v", err)
 	}
 
-	bufs := make([][]byte, erasure_coding.TotalShardsCount)
+	bufs := make([][]byte, ecVolume.Scheme.TotalShards())
 
 	var wg sync.WaitGroup
 	ecVolume.ShardLocationsLock.RLock()
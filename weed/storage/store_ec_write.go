@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+// AllocateEcShards creates and mounts the empty shards of an ec volume, which the clients write directly
+func (s *Store) AllocateEcShards(collection string, vid needle.VolumeId, scheme erasure_coding.EcScheme, shardIds []erasure_coding.ShardId, diskType DiskType) error {
+	if IsEncryptionEnabled() {
+		// the needles are encoded by the clients, which have no data keys to encrypt them at rest
+		return fmt.Errorf("ec volumes written directly are not encrypted at rest")
+	}
+	if s.findVolume(vid) != nil {
+		return fmt.Errorf("volume %d already exists", vid)
+	}
+	if _, found := s.FindEcVolume(vid); found {
+		return fmt.Errorf("ec volume %d already exists", vid)
+	}
+	location := s.FindFreeLocation(func(location *DiskLocation) bool {
+		return location.DiskType == diskType
+	})
+	if location == nil {
+		return fmt.Errorf("no more free space left")
+	}
+
+	glog.V(0).Infof("In dir %s adds ec volume:%v collection:%s shards:%v scheme:%s", location.Directory, vid, collection, shardIds, scheme)
+	baseFileName := erasure_coding.EcShardFileName(collection, location.Directory, int(vid))
+	indexBaseFileName := erasure_coding.EcShardFileName(collection, location.IdxDirectory, int(vid))
+	if err := erasure_coding.WriteEmptyEcFiles(baseFileName, indexBaseFileName, scheme, needle.CurrentVersion, shardIds); err != nil {
+		return err
+	}
+
+	for _, shardId := range shardIds {
+		if err := s.MountEcShards(collection, vid, shardId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteEcShardRows writes the rows of a needle to a local shard of an ec volume written directly
+func (s *Store) WriteEcShardRows(vid needle.VolumeId, shardId erasure_coding.ShardId, data []byte, offset int64, size int64) error {
+	shard, found := s.findEcShard(vid, shardId)
+	if !found {
+		return fmt.Errorf("ec shard %d.%d not found", vid, shardId)
+	}
+	return shard.WriteRows(data, offset, size)
+}
+
+// CommitEcNeedle makes the needle readable after its rows are written to all the shards
+func (s *Store) CommitEcNeedle(vid needle.VolumeId, needleId NeedleId, offset int64, size Size) error {
+	ecVolume, found := s.FindEcVolume(vid)
+	if !found {
+		return fmt.Errorf("ec volume %d not found", vid)
+	}
+	if offset%ecVolume.Scheme.RowSize() != 0 || offset+needle.GetActualSize(size, ecVolume.Version) > ecVolume.Scheme.DirectWriteLimit() {
+		return fmt.Errorf("ec volume %d needle %s at %d size %d is not in the rows written directly", vid, needleId, offset, size)
+	}
+	return ecVolume.AddNeedleToEcx(needleId, ToOffset(offset), size)
+}
@@ -0,0 +1,439 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/klauspost/reedsolomon"
+	"golang.org/x/exp/slices"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/operation"
+	"github.com/seaweedfs/seaweedfs/weed/pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+const (
+	scrubStartDelay      = time.Minute
+	scrubParityBlockSize = erasure_coding.ErasureCodingSmallBlockSize
+)
+
+// StartScrubbing verifies the needle CRCs of the volumes and ec shards, and the ec parity, in the background.
+// Each disk is scrubbed once every interval, reading at most bytesPerSecond.
+// The corrupt needles are reported to the master, after repairing them from the other replicas or ec shards if repair is set.
+func (s *Store) StartScrubbing(bytesPerSecond int64, interval time.Duration, repair bool) {
+	for _, location := range s.Locations {
+		sc := &scrubber{
+			store:          s,
+			location:       location,
+			bytesPerSecond: bytesPerSecond,
+			repair:         repair,
+		}
+		go sc.loop(interval)
+	}
+}
+
+type scrubber struct {
+	store          *Store
+	location       *DiskLocation
+	bytesPerSecond int64
+	repair         bool
+	throttler      *util.WriteThrottler
+}
+
+func (sc *scrubber) loop(interval time.Duration) {
+	timer := time.NewTimer(scrubStartDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-sc.location.closeCh:
+			return
+		case <-timer.C:
+		}
+		sc.scrubOnce()
+		timer.Reset(interval)
+	}
+}
+
+func (sc *scrubber) shouldStop() bool {
+	if sc.store.isStopping {
+		return true
+	}
+	select {
+	case <-sc.location.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sc *scrubber) scrubOnce() {
+	startTime := time.Now()
+	sc.throttler = util.NewWriteThrottler(sc.bytesPerSecond)
+
+	var volumes []*Volume
+	sc.location.volumesLock.RLock()
+	for _, v := range sc.location.volumes {
+		volumes = append(volumes, v)
+	}
+	sc.location.volumesLock.RUnlock()
+	slices.SortFunc(volumes, func(a, b *Volume) int {
+		return int(a.Id) - int(b.Id)
+	})
+
+	var ecVolumes []*erasure_coding.EcVolume
+	sc.location.ecVolumesLock.RLock()
+	for _, ev := range sc.location.ecVolumes {
+		ecVolumes = append(ecVolumes, ev)
+	}
+	sc.location.ecVolumesLock.RUnlock()
+	slices.SortFunc(ecVolumes, func(a, b *erasure_coding.EcVolume) int {
+		return int(a.VolumeId) - int(b.VolumeId)
+	})
+
+	for _, v := range volumes {
+		if sc.shouldStop() {
+			return
+		}
+		sc.scrubVolume(v)
+	}
+	for _, ev := range ecVolumes {
+		if sc.shouldStop() {
+			return
+		}
+		sc.scrubEcVolume(ev)
+	}
+
+	stats.VolumeServerScrubLastPassGauge.WithLabelValues(sc.location.Directory).SetToCurrentTime()
+	glog.V(0).Infof("scrubbed %d volumes and %d ec volumes in %s in %v", len(volumes), len(ecVolumes), sc.location.Directory, time.Since(startTime))
+}
+
+func (sc *scrubber) scrubVolume(v *Volume) {
+	if v.HasRemoteFile() {
+		// the data is kept in the remote storage
+		return
+	}
+	scannedBytes, needleCount, err := v.ScrubNeedles(sc.throttler, sc.shouldStop, func(needleId types.NeedleId, offset int64, verifyErr error) {
+		glog.Errorf("scrub volume %d needle %s at offset %d: %v", v.Id, needleId, offset, verifyErr)
+		message := &master_pb.CorruptNeedleMessage{
+			Id:         uint32(v.Id),
+			Collection: v.Collection,
+			NeedleId:   uint64(needleId),
+			Offset:     offset,
+			Error:      verifyErr.Error(),
+		}
+		if sc.repair {
+			if err := sc.store.repairVolumeNeedle(v, needleId, offset); err != nil {
+				glog.Errorf("repair volume %d needle %s: %v", v.Id, needleId, err)
+			} else {
+				glog.V(0).Infof("repaired volume %d needle %s from another replica", v.Id, needleId)
+				message.Repaired = true
+			}
+		}
+		sc.report(message)
+	})
+	stats.VolumeServerScrubBytesCounter.WithLabelValues(sc.location.Directory, "volume").Add(float64(scannedBytes))
+	stats.VolumeServerScrubNeedleCounter.WithLabelValues(sc.location.Directory, "verified").Add(float64(needleCount))
+	if err != nil {
+		glog.V(0).Infof("scrub volume %d stopped after %d needles: %v", v.Id, needleCount, err)
+	}
+}
+
+// repairVolumeNeedle replaces the corrupt needle with the copy of another replica
+func (s *Store) repairVolumeNeedle(v *Volume, needleId types.NeedleId, offset int64) error {
+	if v.ReplicaPlacement.GetCopyCount() <= 1 {
+		return fmt.Errorf("volume %d has no other replica", v.Id)
+	}
+	lookup, err := operation.LookupVolumeId(func(_ context.Context) pb.ServerAddress {
+		return s.MasterAddress
+	}, s.grpcDialOption, v.Id.String())
+	if err != nil {
+		return fmt.Errorf("lookup volume %d: %v", v.Id, err)
+	}
+	self := util.JoinHostPort(s.Ip, s.Port)
+	err = fmt.Errorf("volume %d has no other replica", v.Id)
+	for _, location := range lookup.Locations {
+		if location.Url == self {
+			continue
+		}
+		var needleBlob []byte
+		err = operation.WithVolumeServerClient(false, location.ServerAddress(), s.grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+			resp, readErr := client.ReadNeedleBlob(context.Background(), &volume_server_pb.ReadNeedleBlobRequest{
+				VolumeId: uint32(v.Id),
+				NeedleId: uint64(needleId),
+			})
+			if readErr != nil {
+				return readErr
+			}
+			needleBlob = resp.NeedleBlob
+			return nil
+		})
+		if err == nil {
+			err = v.RepairNeedle(needleId, offset, needleBlob)
+		}
+		if err == nil {
+			return nil
+		}
+		err = fmt.Errorf("copy from %s: %v", location.Url, err)
+		glog.V(1).Infof("repair volume %d needle %s: %v", v.Id, needleId, err)
+	}
+	return err
+}
+
+func (sc *scrubber) scrubEcVolume(ev *erasure_coding.EcVolume) {
+	if err := sc.store.cachedLookupEcShardLocations(ev); err != nil {
+		glog.V(0).Infof("skip scrubbing ec volume %d: %v", ev.VolumeId, err)
+		return
+	}
+	sc.scrubEcNeedles(ev)
+	if !sc.shouldStop() {
+		sc.scrubEcParity(ev)
+	}
+}
+
+// scrubEcNeedles verifies the CRC of the needles starting in the local ec shards
+func (sc *scrubber) scrubEcNeedles(ev *erasure_coding.EcVolume) {
+	var scannedBytes int64
+	var needleCount int
+	errStopped := fmt.Errorf("stopped")
+	err := ev.WalkIndex(func(needleId types.NeedleId, offset types.Offset, size types.Size) error {
+		if sc.shouldStop() {
+			return errStopped
+		}
+		if offset.IsZero() || !size.IsValid() {
+			return nil
+		}
+		actualOffset := offset.ToActualOffset()
+		intervals := ev.LocateEcShardNeedleInterval(ev.Version, actualOffset, size)
+		if len(intervals) == 0 {
+			return nil
+		}
+		if shardId, _ := intervals[0].ToShardIdAndOffset(erasure_coding.ErasureCodingLargeBlockSize, erasure_coding.ErasureCodingSmallBlockSize); !hasEcShard(ev, shardId) {
+			// verified by the server with the shard
+			return nil
+		}
+		actualSize := needle.GetActualSize(size, ev.Version)
+		scannedBytes += actualSize
+		needleCount++
+		sc.throttler.MaybeSlowdown(actualSize)
+
+		data, isDeleted, readErr := sc.store.readEcShardIntervals(ev.VolumeId, needleId, ev, intervals)
+		if readErr == nil && isDeleted {
+			return nil
+		}
+		if readErr == nil {
+			n := new(needle.Needle)
+			readErr = n.ReadBytes(data, actualOffset, size, ev.Version)
+		}
+		if readErr == nil {
+			return nil
+		}
+
+		glog.Errorf("scrub ec volume %d needle %s at offset %d: %v", ev.VolumeId, needleId, actualOffset, readErr)
+		message := &master_pb.CorruptNeedleMessage{
+			Id:         uint32(ev.VolumeId),
+			Collection: ev.Collection,
+			NeedleId:   uint64(needleId),
+			Offset:     actualOffset,
+			IsEc:       true,
+			Error:      readErr.Error(),
+		}
+		if sc.repair {
+			if shardIds, err := sc.store.repairEcNeedle(ev, needleId, actualOffset, size, intervals); err != nil {
+				glog.Errorf("repair ec volume %d needle %s: %v", ev.VolumeId, needleId, err)
+			} else {
+				glog.V(0).Infof("repaired ec volume %d needle %s in shards %v", ev.VolumeId, needleId, shardIds)
+				message.Repaired = true
+			}
+		}
+		sc.report(message)
+		return nil
+	})
+	stats.VolumeServerScrubBytesCounter.WithLabelValues(sc.location.Directory, "ec").Add(float64(scannedBytes))
+	stats.VolumeServerScrubNeedleCounter.WithLabelValues(sc.location.Directory, "verified").Add(float64(needleCount))
+	if err != nil && err != errStopped {
+		glog.V(0).Infof("scrub ec volume %d stopped after %d needles: %v", ev.VolumeId, needleCount, err)
+	}
+}
+
+// repairEcNeedle rebuilds the parts of the needle in the local shards from the other shards,
+// and writes them back if the rebuilt needle passes the CRC check.
+func (s *Store) repairEcNeedle(ev *erasure_coding.EcVolume, needleId types.NeedleId, offset int64, size types.Size, intervals []erasure_coding.Interval) (repairedShardIds []erasure_coding.ShardId, err error) {
+	type shardPatch struct {
+		shard  *erasure_coding.EcVolumeShard
+		offset int64
+		data   []byte
+	}
+	var patches []shardPatch
+	var data []byte
+	for _, interval := range intervals {
+		shardId, shardOffset := interval.ToShardIdAndOffset(erasure_coding.ErasureCodingLargeBlockSize, erasure_coding.ErasureCodingSmallBlockSize)
+		shard, isLocal := ev.FindEcVolumeShard(shardId)
+		if !isLocal {
+			d, _, readErr := s.readOneEcShardInterval(needleId, ev, interval)
+			if readErr != nil {
+				return nil, readErr
+			}
+			data = append(data, d...)
+			continue
+		}
+		buf := make([]byte, interval.Size)
+		if _, _, err = s.recoverOneRemoteEcShardInterval(needleId, ev, shardId, buf, shardOffset); err != nil {
+			return nil, fmt.Errorf("rebuild ec shard %d.%d at %d: %v", ev.VolumeId, shardId, shardOffset, err)
+		}
+		patches = append(patches, shardPatch{shard: shard, offset: shardOffset, data: buf})
+		data = append(data, buf...)
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no local shard to repair")
+	}
+
+	n := new(needle.Needle)
+	if err = n.ReadBytes(data, offset, size, ev.Version); err != nil {
+		return nil, fmt.Errorf("rebuilt needle is still corrupted: %v", err)
+	}
+	for _, patch := range patches {
+		if _, err = patch.shard.WriteAt(patch.data, patch.offset); err != nil {
+			return repairedShardIds, fmt.Errorf("write ec shard %d.%d at %d: %v", ev.VolumeId, patch.shard.ShardId, patch.offset, err)
+		}
+		repairedShardIds = append(repairedShardIds, patch.shard.ShardId)
+	}
+	return repairedShardIds, nil
+}
+
+// scrubEcParity verifies that the parity shards match the data shards.
+// It is done by the server with the first shard of the ec volume, reading the other shards remotely.
+func (sc *scrubber) scrubEcParity(ev *erasure_coding.EcVolume) {
+	localShardIds := ev.ShardIdList()
+	if len(localShardIds) == 0 || !isFirstEcShard(ev, localShardIds) {
+		return
+	}
+	shardSize := int64(ev.ShardSize())
+
+	enc, err := ev.Scheme.NewEncoder()
+	if err != nil {
+		glog.Errorf("failed to create encoder: %v", err)
+		return
+	}
+
+	var scannedBytes int64
+	defer func() {
+		stats.VolumeServerScrubBytesCounter.WithLabelValues(sc.location.Directory, "ec_parity").Add(float64(scannedBytes))
+	}()
+
+	totalShards := int64(ev.Scheme.TotalShards())
+	buffers := make([][]byte, totalShards)
+	for i := range buffers {
+		buffers[i] = make([]byte, scrubParityBlockSize)
+	}
+	shards := make([][]byte, totalShards)
+	for offset := int64(0); offset < shardSize; offset += scrubParityBlockSize {
+		if sc.shouldStop() {
+			return
+		}
+		blockSize := shardSize - offset
+		if blockSize > scrubParityBlockSize {
+			blockSize = scrubParityBlockSize
+		}
+		for i := range shards {
+			shards[i] = buffers[i][:blockSize]
+			if err = sc.store.readEcShardAt(ev, erasure_coding.ShardId(i), shards[i], offset); err != nil {
+				glog.V(0).Infof("skip ec volume %d parity check: %v", ev.VolumeId, err)
+				return
+			}
+		}
+		scannedBytes += blockSize * totalShards
+		sc.throttler.MaybeSlowdown(blockSize * totalShards)
+
+		if ok, verifyErr := enc.Verify(shards); verifyErr == nil && ok {
+			continue
+		}
+		sc.onEcParityMismatch(ev, enc, shards, offset)
+	}
+}
+
+func (sc *scrubber) onEcParityMismatch(ev *erasure_coding.EcVolume, enc reedsolomon.Encoder, shards [][]byte, offset int64) {
+	message := &master_pb.CorruptNeedleMessage{
+		Id:         uint32(ev.VolumeId),
+		Collection: ev.Collection,
+		Offset:     offset,
+		IsEc:       true,
+	}
+	shardId, rebuilt, found := erasure_coding.FindCorruptedShard(enc, shards)
+	if !found {
+		message.Error = "ec parity mismatch in more than one shard"
+		glog.Errorf("scrub ec volume %d at shard offset %d: %s", ev.VolumeId, offset, message.Error)
+		sc.report(message)
+		return
+	}
+	message.ShardId = uint32(shardId)
+	message.Error = fmt.Sprintf("ec shard %d does not match the parity", shardId)
+	glog.Errorf("scrub ec volume %d at shard offset %d: %s", ev.VolumeId, offset, message.Error)
+
+	if shard, isLocal := ev.FindEcVolumeShard(shardId); isLocal && sc.repair {
+		if _, err := shard.WriteAt(rebuilt, offset); err != nil {
+			glog.Errorf("repair ec shard %d.%d at %d: %v", ev.VolumeId, shardId, offset, err)
+		} else {
+			glog.V(0).Infof("repaired ec shard %d.%d at %d", ev.VolumeId, shardId, offset)
+			message.Repaired = true
+		}
+	}
+	sc.report(message)
+}
+
+// readEcShardAt reads the shard locally, or from the server with the shard
+func (s *Store) readEcShardAt(ev *erasure_coding.EcVolume, shardId erasure_coding.ShardId, buf []byte, offset int64) error {
+	if shard, found := ev.FindEcVolumeShard(shardId); found {
+		n, err := shard.ReadAt(buf, offset)
+		if n == len(buf) {
+			return nil
+		}
+		return fmt.Errorf("read ec shard %d.%d at %d: %d bytes, %v", ev.VolumeId, shardId, offset, n, err)
+	}
+	ev.ShardLocationsLock.RLock()
+	sourceDataNodes := ev.ShardLocations[shardId]
+	ev.ShardLocationsLock.RUnlock()
+	n, _, err := s.readRemoteEcShardInterval(sourceDataNodes, 0, ev.VolumeId, shardId, buf, offset)
+	if err == nil && n != len(buf) {
+		err = fmt.Errorf("read ec shard %d.%d at %d: %d bytes", ev.VolumeId, shardId, offset, n)
+	}
+	return err
+}
+
+func hasEcShard(ev *erasure_coding.EcVolume, shardId erasure_coding.ShardId) bool {
+	_, found := ev.FindEcVolumeShard(shardId)
+	return found
+}
+
+// isFirstEcShard checks whether the first of the local shards is the first shard of the ec volume
+func isFirstEcShard(ev *erasure_coding.EcVolume, localShardIds []erasure_coding.ShardId) bool {
+	firstShardId := slices.Min(localShardIds)
+	ev.ShardLocationsLock.RLock()
+	defer ev.ShardLocationsLock.RUnlock()
+	for shardId, locations := range ev.ShardLocations {
+		if shardId < firstShardId && len(locations) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (sc *scrubber) report(message *master_pb.CorruptNeedleMessage) {
+	message.Disk = sc.location.Directory
+	stats.VolumeServerScrubNeedleCounter.WithLabelValues(sc.location.Directory, "corrupt").Inc()
+	if message.Repaired {
+		stats.VolumeServerScrubNeedleCounter.WithLabelValues(sc.location.Directory, "repaired").Inc()
+	}
+	select {
+	case sc.store.CorruptNeedlesChan <- message:
+	default:
+		glog.Warningf("drop the report of corrupt volume %d needle %d: too many pending reports", message.Id, message.NeedleId)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 )
 
 func (s *Store) CheckCompactVolume(volumeId needle.VolumeId) (float64, error) {
@@ -16,8 +17,52 @@ func (s *Store) CheckCompactVolume(volumeId needle.VolumeId) (float64, error) {
 	}
 	return 0, fmt.Errorf("volume id %d is not found during check compact", volumeId)
 }
-func (s *Store) CompactVolume(vid needle.VolumeId, preallocate int64, compactionBytePerSecond int64, progressFn ProgressFunc) error {
+
+// CheckUpgradeVolume tells whether the volume is to be converted to a newer needle version by vacuum
+func (s *Store) CheckUpgradeVolume(volumeId needle.VolumeId) bool {
+	if v := s.findVolume(volumeId); v != nil {
+		return v.NeedsUpgrade()
+	}
+	return false
+}
+
+// CheckCompactVolumeSpace returns the bytes a compaction copy of the volume would take,
+// and the free bytes of its disk above the minimum free space
+func (s *Store) CheckCompactVolumeSpace(volumeId needle.VolumeId) (compactSize uint64, freeSize uint64, err error) {
+	v := s.findVolume(volumeId)
+	if v == nil {
+		return 0, 0, fmt.Errorf("volume id %d is not found during check compact", volumeId)
+	}
+	if contentSize, deletedSize := v.ContentSize(), v.DeletedSize(); contentSize > deletedSize {
+		compactSize = contentSize - deletedSize
+	}
+	disk := stats.NewDiskStatus(v.dir)
+	var reserved uint64
+	if v.location != nil {
+		switch minFreeSpace := v.location.MinFreeSpace; minFreeSpace.Type {
+		case util.AsPercent:
+			reserved = uint64(float64(disk.All) * float64(minFreeSpace.Percent) / 100)
+		case util.AsBytes:
+			reserved = minFreeSpace.Bytes
+		}
+	}
+	if disk.Free > reserved {
+		freeSize = disk.Free - reserved
+	}
+	return compactSize, freeSize, nil
+}
+
+func (s *Store) CompactVolume(vid needle.VolumeId, preallocate int64, inPlace bool, compactionBytePerSecond int64, progressFn ProgressFunc) error {
 	if v := s.findVolume(vid); v != nil {
+		if inPlace {
+			if v.canCompactInPlace() {
+				if v.NeedsUpgrade() {
+					glog.V(0).Infof("volume %d is compacted in place, keeping needle version %d until compacted to a copy", vid, v.Version())
+				}
+				return v.CompactInPlace(compactionBytePerSecond, progressFn)
+			}
+			glog.V(0).Infof("read only volume %d can not be compacted in place, compacting to a copy", vid)
+		}
 		s := stats.NewDiskStatus(v.dir)
 		if int64(s.Free) < preallocate {
 			return fmt.Errorf("free space: %d bytes, not enough for %d bytes", s.Free, preallocate)
@@ -15,7 +15,7 @@ const (
 
 /*
 * Super block currently has 8 bytes allocated for each volume.
-* Byte 0: version, 1 or 2
+* Byte 0: version, 1 to 4, see needle.Version
 * Byte 1: Replica Placement strategy, 000, 001, 002, 010, etc
 * Byte 2 and byte 3: Time to live. See TTL for definition
 * Byte 4 and byte 5: The number of times the volume has been compacted.
@@ -32,7 +32,7 @@ type SuperBlock struct {
 
 func (s *SuperBlock) BlockSize() int {
 	switch s.Version {
-	case needle.Version2, needle.Version3:
+	case needle.Version2, needle.Version3, needle.Version4:
 		return SuperBlockSize + int(s.ExtraSize)
 	}
 	return SuperBlockSize
@@ -21,6 +21,10 @@ func ReadSuperBlock(datBackend backend.BackendStorageFile) (superBlock SuperBloc
 	}
 
 	superBlock.Version = needle.Version(header[0])
+	if !needle.IsSupportedVersion(superBlock.Version) {
+		err = fmt.Errorf("volume %s version %d is newer than the supported version %d", datBackend.Name(), superBlock.Version, needle.LatestVersion)
+		return
+	}
 	if superBlock.ReplicaPlacement, err = NewReplicaPlacementFromByte(header[1]); err != nil {
 		err = fmt.Errorf("cannot read replica type: %s", err.Error())
 		return
@@ -14,6 +14,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
 
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 )
@@ -38,21 +39,28 @@ type Volume struct {
 	dataFileAccessLock    sync.RWMutex
 	superBlockAccessLock  sync.Mutex
 	asyncRequestsChan     chan *needle.AsyncRequest
+	asyncBatchCount       uint64 // the number of fsyncs shared by the async requests, accessed atomically
 	lastModifiedTsSeconds uint64 // unix time in seconds
 	lastAppendAtNs        uint64 // unix time in nanoseconds
 
 	lastCompactIndexOffset uint64
 	lastCompactRevision    uint16
+	lastCompactDataKeyId   uint32 // the data key re-encrypting the needles when compacting
 	ldbTimeout             int64
 
-	isCompacting       bool
-	isCommitCompacting bool
+	isCompacting         bool
+	isCommitCompacting   bool
+	inPlaceCompactOffset int64  // the end of the needles slid by CompactInPlace, to be committed
+	slideRevision        uint32 // changed in memory whenever CompactInPlace moves needles
 
 	volumeInfoRWLock sync.RWMutex
 	volumeInfo       *volume_server_pb.VolumeInfo
 	location         *DiskLocation
 
 	lastIoError error
+
+	dataKeys  map[uint32]util.CipherKey // the unwrapped data keys by id, guarded by dataFileAccessLock
+	dataKeyId uint32                    // the data key encrypting the new writes, 0 if not encrypted
 }
 
 func NewVolume(dirname string, dirIdx string, collection string, id needle.VolumeId, needleMapKind NeedleMapKind, replicaPlacement *super_block.ReplicaPlacement, ttl *needle.TTL, preallocate int64, memoryMapMaxSizeMb uint32, ldbTimeout int64) (v *Volume, e error) {
@@ -366,3 +374,59 @@ func (v *Volume) PersistReadOnly(readOnly bool) {
 	v.volumeInfo.ReadOnly = readOnly
 	v.SaveVolumeInfo()
 }
+
+// GroupCommitDelay is how long a write waits for other writes to share its fsync, 0 if not configured
+func (v *Volume) GroupCommitDelay() time.Duration {
+	v.volumeInfoRWLock.RLock()
+	defer v.volumeInfoRWLock.RUnlock()
+	return time.Duration(v.volumeInfo.GetGroupCommitMs()) * time.Millisecond
+}
+
+func (v *Volume) PersistGroupCommitDelay(delay time.Duration) error {
+	v.volumeInfoRWLock.Lock()
+	defer v.volumeInfoRWLock.Unlock()
+	v.volumeInfo.GroupCommitMs = uint32(delay / time.Millisecond)
+	return v.SaveVolumeInfo()
+}
+
+// NeedsUpgrade tells whether the volume is to be converted to a newer needle version when vacuumed
+func (v *Volume) NeedsUpgrade() bool {
+	return v.compactVersion() != v.Version()
+}
+
+// PersistUpgradeVersion keeps the needle version to convert the volume to, when it is vacuumed next time
+func (v *Volume) PersistUpgradeVersion(version needle.Version) error {
+	if !needle.IsSupportedVersion(version) {
+		return fmt.Errorf("needle version %d is not supported, the latest version is %d", version, needle.LatestVersion)
+	}
+	if version < v.Version() {
+		return fmt.Errorf("volume %d needle version %d is newer than %d", v.Id, v.Version(), version)
+	}
+	v.volumeInfoRWLock.Lock()
+	defer v.volumeInfoRWLock.Unlock()
+	v.volumeInfo.UpgradeVersion = uint32(version)
+	return v.SaveVolumeInfo()
+}
+
+// compactVersion is the needle version of the compacted volume
+func (v *Volume) compactVersion() needle.Version {
+	version := v.Version()
+	v.volumeInfoRWLock.RLock()
+	defer v.volumeInfoRWLock.RUnlock()
+	if upgradeVersion := needle.Version(v.volumeInfo.GetUpgradeVersion()); upgradeVersion > version && needle.IsSupportedVersion(upgradeVersion) {
+		return upgradeVersion
+	}
+	return version
+}
+
+// finishUpgrade clears the upgrade version once the volume is converted to it, and saves the new needle version
+func (v *Volume) finishUpgrade() error {
+	v.volumeInfoRWLock.Lock()
+	defer v.volumeInfoRWLock.Unlock()
+	if v.volumeInfo.UpgradeVersion == 0 || v.volumeInfo.UpgradeVersion > v.volumeInfo.Version {
+		return nil
+	}
+	glog.V(0).Infof("volume %d is upgraded to needle version %d", v.Id, v.volumeInfo.Version)
+	v.volumeInfo.UpgradeVersion = 0
+	return v.SaveVolumeInfo()
+}
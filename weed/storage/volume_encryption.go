@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/security"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+	"google.golang.org/protobuf/proto"
+)
+
+// the master key provider wrapping the volume data keys, nil if encryption at rest is not configured
+var masterKeyProvider security.MasterKeyProvider
+
+// SetMasterKeyProvider enables encryption at rest. It is set before loading the volumes, whose data keys it unwraps.
+func SetMasterKeyProvider(provider security.MasterKeyProvider) {
+	masterKeyProvider = provider
+}
+
+func IsEncryptionEnabled() bool {
+	return masterKeyProvider != nil
+}
+
+// loadDataKeys unwraps the data keys saved in the .vif file
+func (v *Volume) loadDataKeys() error {
+	v.dataKeys, v.dataKeyId = nil, 0
+	if len(v.volumeInfo.DataKeys) == 0 {
+		return nil
+	}
+	dataKeys, dataKeyId, err := unwrapDataKeys(v.volumeInfo.DataKeys)
+	if err != nil {
+		return fmt.Errorf("volume %d: %v", v.Id, err)
+	}
+	v.dataKeys, v.dataKeyId = dataKeys, dataKeyId
+	return nil
+}
+
+// unwrapDataKeys returns the unwrapped data keys by id, and the id of the last key, used for the new writes
+func unwrapDataKeys(wrappedKeys []*volume_server_pb.VolumeDataKey) (dataKeys map[uint32]util.CipherKey, dataKeyId uint32, err error) {
+	if masterKeyProvider == nil {
+		return nil, 0, fmt.Errorf("the data is encrypted, but no master key is configured")
+	}
+	dataKeys = make(map[uint32]util.CipherKey)
+	for _, wrappedKey := range wrappedKeys {
+		dataKey, unwrapErr := masterKeyProvider.UnwrapKey(wrappedKey.WrappedKey, wrappedKey.MasterKeyId)
+		if unwrapErr != nil {
+			return nil, 0, fmt.Errorf("unwrap data key %d with master key %s: %v", wrappedKey.KeyId, wrappedKey.MasterKeyId, unwrapErr)
+		}
+		dataKeys[wrappedKey.KeyId] = dataKey
+		dataKeyId = wrappedKey.KeyId
+	}
+	return dataKeys, dataKeyId, nil
+}
+
+// DataKeys returns the wrapped data keys, to be shared with the other replicas
+func (v *Volume) DataKeys() []*volume_server_pb.VolumeDataKey {
+	v.volumeInfoRWLock.RLock()
+	defer v.volumeInfoRWLock.RUnlock()
+	var dataKeys []*volume_server_pb.VolumeDataKey
+	for _, dataKey := range v.volumeInfo.DataKeys {
+		dataKeys = append(dataKeys, proto.Clone(dataKey).(*volume_server_pb.VolumeDataKey))
+	}
+	return dataKeys
+}
+
+// RotateDataKeys re-wraps the data keys with the current master key. With replicaKeys, it switches to the data keys
+// of the other replicas, so the needles copied between the replicas can be decrypted. Otherwise if rotateDataKey is set,
+// or if the volume has no data key yet, it adds a new data key to encrypt the new writes.
+// The needles encrypted with the earlier keys, or not encrypted, are re-encrypted when the volume is vacuumed.
+func (v *Volume) RotateDataKeys(rotateDataKey bool, replicaKeys []*volume_server_pb.VolumeDataKey) error {
+	if masterKeyProvider == nil {
+		return fmt.Errorf("no master key is configured")
+	}
+
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+	v.volumeInfoRWLock.Lock()
+	defer v.volumeInfoRWLock.Unlock()
+
+	dataKeys, dataKeyId := v.dataKeys, v.dataKeyId
+	if len(replicaKeys) > 0 {
+		replicaDataKeys, replicaDataKeyId, err := unwrapDataKeys(replicaKeys)
+		if err != nil {
+			return err
+		}
+		// keep the local keys not known by the replicas, to read the needles written with them
+		for keyId, dataKey := range dataKeys {
+			if replicaDataKey, found := replicaDataKeys[keyId]; !found {
+				replicaDataKeys[keyId] = dataKey
+			} else if !bytes.Equal(replicaDataKey, dataKey) {
+				return fmt.Errorf("volume %d data key %d differs from the one of the replicas", v.Id, keyId)
+			}
+		}
+		dataKeys, dataKeyId = replicaDataKeys, replicaDataKeyId
+	} else if rotateDataKey || dataKeyId == 0 {
+		rotatedDataKeys := make(map[uint32]util.CipherKey, len(dataKeys)+1)
+		for keyId, dataKey := range dataKeys {
+			rotatedDataKeys[keyId] = dataKey
+			dataKeyId = max(dataKeyId, keyId)
+		}
+		dataKeyId++
+		rotatedDataKeys[dataKeyId] = util.GenCipherKey()
+		dataKeys = rotatedDataKeys
+	}
+
+	wrappedKeys, err := wrapDataKeys(dataKeys, dataKeyId)
+	if err != nil {
+		return err
+	}
+	v.volumeInfo.DataKeys = wrappedKeys
+	if err = v.SaveVolumeInfo(); err != nil {
+		return fmt.Errorf("save data keys of volume %d: %v", v.Id, err)
+	}
+	v.dataKeys, v.dataKeyId = dataKeys, dataKeyId
+	glog.V(0).Infof("volume %d encrypts with data key %d of %d keys", v.Id, dataKeyId, len(dataKeys))
+	return nil
+}
+
+// wrapDataKeys wraps the data keys with the current master key, ordered by id with the current data key last
+func wrapDataKeys(dataKeys map[uint32]util.CipherKey, dataKeyId uint32) (wrappedKeys []*volume_server_pb.VolumeDataKey, err error) {
+	keyIds := make([]uint32, 0, len(dataKeys))
+	for keyId := range dataKeys {
+		if keyId != dataKeyId {
+			keyIds = append(keyIds, keyId)
+		}
+	}
+	slices.Sort(keyIds)
+	for _, keyId := range append(keyIds, dataKeyId) {
+		wrappedKey, masterKeyId, wrapErr := masterKeyProvider.WrapKey(dataKeys[keyId])
+		if wrapErr != nil {
+			return nil, fmt.Errorf("wrap data key %d: %v", keyId, wrapErr)
+		}
+		wrappedKeys = append(wrappedKeys, &volume_server_pb.VolumeDataKey{
+			KeyId:       keyId,
+			WrappedKey:  wrappedKey,
+			MasterKeyId: masterKeyId,
+		})
+	}
+	return wrappedKeys, nil
+}
+
+// compactDataKeys snapshots the data keys for a compaction to re-encrypt the needles with the current data key
+func (v *Volume) compactDataKeys() (dataKeys map[uint32]util.CipherKey, dataKeyId uint32) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+	v.lastCompactDataKeyId = v.dataKeyId
+	return v.dataKeys, v.dataKeyId
+}
+
+// dropDataKeysBefore removes the data keys older than keyId, after the needles are re-encrypted by a compaction.
+// The caller holds the dataFileAccessLock.
+func (v *Volume) dropDataKeysBefore(keyId uint32) error {
+	v.volumeInfoRWLock.Lock()
+	defer v.volumeInfoRWLock.Unlock()
+
+	var wrappedKeys []*volume_server_pb.VolumeDataKey
+	for _, wrappedKey := range v.volumeInfo.DataKeys {
+		if wrappedKey.KeyId >= keyId {
+			wrappedKeys = append(wrappedKeys, wrappedKey)
+		}
+	}
+	if len(wrappedKeys) == len(v.volumeInfo.DataKeys) {
+		return nil
+	}
+	glog.V(0).Infof("volume %d drops %d data keys re-encrypted by compaction", v.Id, len(v.volumeInfo.DataKeys)-len(wrappedKeys))
+	v.volumeInfo.DataKeys = wrappedKeys
+	for id := range v.dataKeys {
+		if id < keyId {
+			delete(v.dataKeys, id)
+		}
+	}
+	return v.SaveVolumeInfo()
+}
+
+// appendNeedle appends the needle, with its data encrypted if the volume has a data key.
+// The needle is left unencrypted afterwards, e.g. to be replicated. The caller holds the dataFileAccessLock.
+func (v *Volume) appendNeedle(n *needle.Needle) (offset uint64, size Size, actualSize int64, err error) {
+	version := v.Version()
+	if n.IsEncrypted() {
+		// tailed from a replica, which may have another needle version
+		if err = reencryptNeedle(n, version, v.dataKeys, v.dataKeyId); err != nil {
+			return
+		}
+		return n.Append(v.DataBackend, version)
+	}
+	if v.dataKeyId == 0 || len(n.Data) == 0 || version == needle.Version1 {
+		return n.Append(v.DataBackend, version)
+	}
+	data, checksum, flags := n.Data, n.Checksum, n.Flags
+	defer func() {
+		n.Data, n.Checksum, n.Flags, n.DataSize, n.EncryptionKeyId = data, checksum, flags, uint32(len(data)), 0
+	}()
+	if err = n.EncryptData(v.dataKeyId, v.dataKeys[v.dataKeyId], version); err != nil {
+		return
+	}
+	offset, _, actualSize, err = n.Append(v.DataBackend, version)
+	return offset, Size(len(data)), actualSize, err
+}
+
+// decryptNeedle decrypts the needle data read from the volume. The caller holds the dataFileAccessLock.
+func (v *Volume) decryptNeedle(n *needle.Needle) error {
+	return decryptNeedle(n, v.dataKeys)
+}
+
+func decryptNeedle(n *needle.Needle, dataKeys map[uint32]util.CipherKey) error {
+	if !n.IsEncrypted() {
+		return nil
+	}
+	dataKey, found := dataKeys[n.DataKeyId()]
+	if !found {
+		return fmt.Errorf("no data key %d for needle %s", n.DataKeyId(), n.Id)
+	}
+	return n.DecryptData(dataKey)
+}
+
+// reencryptNeedle encrypts the needle data with the current data key when compacting to the version, if it is not yet.
+// A needle upgraded to Version4 is also re-encrypted, to move the key id from the data to the needle metadata.
+func reencryptNeedle(n *needle.Needle, version needle.Version, dataKeys map[uint32]util.CipherKey, dataKeyId uint32) error {
+	if dataKeyId == 0 || n.DataSize == 0 || version == needle.Version1 {
+		return nil
+	}
+	if n.DataKeyId() == dataKeyId && (n.EncryptionKeyId != 0) == (version == needle.Version4) {
+		return nil
+	}
+	if err := decryptNeedle(n, dataKeys); err != nil {
+		return err
+	}
+	return n.EncryptData(dataKeyId, dataKeys[dataKeyId], version)
+}
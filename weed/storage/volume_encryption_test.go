@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+type testMasterKeyProvider struct {
+	keys         map[string]util.CipherKey
+	currentKeyId string
+}
+
+func (p *testMasterKeyProvider) WrapKey(dataKey []byte) ([]byte, string, error) {
+	wrappedKey, err := util.Encrypt(dataKey, p.keys[p.currentKeyId])
+	return wrappedKey, p.currentKeyId, err
+}
+
+func (p *testMasterKeyProvider) UnwrapKey(wrappedKey []byte, masterKeyId string) ([]byte, error) {
+	key, found := p.keys[masterKeyId]
+	if !found {
+		return nil, fmt.Errorf("master key %s not found", masterKeyId)
+	}
+	return util.Decrypt(wrappedKey, key)
+}
+
+func TestVolumeEncryption(t *testing.T) {
+	provider := &testMasterKeyProvider{
+		keys:         map[string]util.CipherKey{"m1": util.GenCipherKey()},
+		currentKeyId: "m1",
+	}
+	SetMasterKeyProvider(provider)
+	defer SetMasterKeyProvider(nil)
+
+	dir := t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+
+	// written before the volume has a data key
+	data := make(map[uint64][]byte)
+	write := func(id uint64) {
+		n := newRandomNeedle(id)
+		n.Data = append(n.Data, byte(id))
+		n.Checksum = needle.NewCRC(n.Data)
+		data[id] = n.Data
+		if _, _, _, err := v.writeNeedle2(n, true, false, 0); err != nil {
+			t.Fatalf("write needle %d: %v", id, err)
+		}
+		assert.Equal(t, data[id], n.Data, "the written needle is left unencrypted")
+	}
+	readRaw := func(id uint64) *needle.Needle {
+		nv, _ := v.nm.Get(newEmptyNeedle(id).Id)
+		n := newEmptyNeedle(id)
+		if err := n.ReadData(v.DataBackend, nv.Offset.ToActualOffset(), nv.Size, v.Version()); err != nil {
+			t.Fatalf("read raw needle %d: %v", id, err)
+		}
+		return n
+	}
+	verify := func() {
+		for id, expected := range data {
+			n := newEmptyNeedle(id)
+			if _, err := v.readNeedle(n, nil, nil); err != nil {
+				t.Fatalf("read needle %d: %v", id, err)
+			}
+			assert.Equal(t, expected, n.Data, "needle %d", id)
+			assert.Equal(t, needle.NewCRC(expected), n.Checksum, "needle %d", id)
+		}
+	}
+	write(1)
+
+	if err = v.RotateDataKeys(false, nil); err != nil {
+		t.Fatalf("add data key: %v", err)
+	}
+	write(2)
+	write(3)
+	assert.False(t, readRaw(1).IsEncrypted())
+	raw := readRaw(2)
+	assert.True(t, raw.IsEncrypted())
+	assert.Equal(t, uint32(1), raw.DataKeyId())
+	assert.False(t, bytes.Contains(raw.Data, data[2]))
+	verify()
+
+	// a new master key re-wraps the data keys, and a new data key encrypts the new writes
+	provider.keys["m2"], provider.currentKeyId = util.GenCipherKey(), "m2"
+	if err = v.RotateDataKeys(true, nil); err != nil {
+		t.Fatalf("rotate data key: %v", err)
+	}
+	for _, dataKey := range v.DataKeys() {
+		assert.Equal(t, "m2", dataKey.MasterKeyId)
+	}
+	delete(provider.keys, "m1")
+	write(4)
+	assert.Equal(t, uint32(2), readRaw(4).DataKeyId())
+	verify()
+
+	// the other replicas adopt the data keys
+	replicaDir := t.TempDir()
+	replica, err := NewVolume(replicaDir, replicaDir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("replica creation: %v", err)
+	}
+	if err = replica.RotateDataKeys(false, v.DataKeys()); err != nil {
+		t.Fatalf("adopt data keys: %v", err)
+	}
+	assert.Equal(t, v.dataKeys, replica.dataKeys)
+	assert.Equal(t, v.dataKeyId, replica.dataKeyId)
+	replica.Close()
+
+	// vacuum re-encrypts with the current data key, and drops the earlier ones
+	if err = v.Compact2(0, 0, nil); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	write(5)
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit compact: %v", err)
+	}
+	for id := range data {
+		assert.Equal(t, uint32(2), readRaw(id).DataKeyId(), "needle %d", id)
+	}
+	assert.Equal(t, 1, len(v.DataKeys()))
+	verify()
+
+	v.Close()
+	v, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume reloading: %v", err)
+	}
+	defer v.Close()
+	verify()
+}
@@ -6,8 +6,6 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 
-	"github.com/syndtr/goleveldb/leveldb/opt"
-
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/stats"
 	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
@@ -42,6 +40,9 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 	}()
 
 	hasVolumeInfoFile := v.maybeLoadVolumeInfo()
+	if err = v.loadDataKeys(); err != nil {
+		return err
+	}
 
 	if v.volumeInfo.ReadOnly && !v.HasRemoteFile() {
 		// this covers the case where the volume is marked as read-only and has no remote file
@@ -72,6 +73,13 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 			alreadyHasSuperBlock = true
 		}
 		v.DataBackend = backend.NewDiskFile(dataFile)
+	} else if blockDeviceFile, openErr := backend.OpenBlockDeviceFile(v.FileName(".dat")); openErr == nil {
+		datSize, modifiedTime, _ := blockDeviceFile.GetStat()
+		v.lastModifiedTsSeconds = uint64(modifiedTime.Unix())
+		if datSize >= super_block.SuperBlockSize {
+			alreadyHasSuperBlock = true
+		}
+		v.DataBackend = blockDeviceFile
 	} else {
 		if createDatIfMissing {
 			v.DataBackend, err = backend.CreateVolumeFile(v.FileName(".dat"), preallocate, v.MemoryMapMaxSizeMb)
@@ -158,11 +166,7 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 					}
 				}
 			case NeedleMapLevelDb:
-				opts := &opt.Options{
-					BlockCacheCapacity:            2 * 1024 * 1024, // default value is 8MiB
-					WriteBuffer:                   1 * 1024 * 1024, // default value is 4MiB
-					CompactionTableSizeMultiplier: 10,              // default value is 1
-				}
+				opts := levelDbNeedleMapOptions(needleMapKind)
 				if v.tmpNm != nil {
 					glog.V(0).Infoln("updating leveldb index", v.FileName(".ldb"))
 					err = v.tmpNm.UpdateNeedleMap(v, indexFile, opts, v.ldbTimeout)
@@ -173,11 +177,7 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 					}
 				}
 			case NeedleMapLevelDbMedium:
-				opts := &opt.Options{
-					BlockCacheCapacity:            4 * 1024 * 1024, // default value is 8MiB
-					WriteBuffer:                   2 * 1024 * 1024, // default value is 4MiB
-					CompactionTableSizeMultiplier: 10,              // default value is 1
-				}
+				opts := levelDbNeedleMapOptions(needleMapKind)
 				if v.tmpNm != nil {
 					glog.V(0).Infoln("updating leveldb medium index", v.FileName(".ldb"))
 					err = v.tmpNm.UpdateNeedleMap(v, indexFile, opts, v.ldbTimeout)
@@ -188,11 +188,7 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 					}
 				}
 			case NeedleMapLevelDbLarge:
-				opts := &opt.Options{
-					BlockCacheCapacity:            8 * 1024 * 1024, // default value is 8MiB
-					WriteBuffer:                   4 * 1024 * 1024, // default value is 4MiB
-					CompactionTableSizeMultiplier: 10,              // default value is 1
-				}
+				opts := levelDbNeedleMapOptions(needleMapKind)
 				if v.tmpNm != nil {
 					glog.V(0).Infoln("updating leveldb large index", v.FileName(".ldb"))
 					err = v.tmpNm.UpdateNeedleMap(v, indexFile, opts, v.ldbTimeout)
@@ -202,6 +198,16 @@ func (v *Volume) load(alsoLoadIndex bool, createDatIfMissing bool, needleMapKind
 						glog.V(0).Infof("loading leveldb %s error: %v", v.FileName(".ldb"), err)
 					}
 				}
+			case NeedleMapLsm:
+				if v.tmpNm != nil {
+					glog.V(0).Infoln("updating shared needle index for", v.FileName(".idx"))
+					err = v.tmpNm.UpdateNeedleMap(v, indexFile, nil, 0)
+				} else {
+					glog.V(0).Infoln("loading", v.FileName(".idx"), "to shared needle index")
+					if v.nm, err = NewLsmNeedleMap(v.dirIdx, v.Id, v.SuperBlock.CompactionRevision, indexFile); err != nil {
+						glog.V(0).Infof("loading %s to shared needle index error: %v", v.FileName(".idx"), err)
+					}
+				}
 			}
 		}
 	}
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/idx"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+func isLevelDbNeedleMapKind(kind NeedleMapKind) bool {
+	return kind == NeedleMapLevelDb || kind == NeedleMapLevelDbMedium || kind == NeedleMapLevelDbLarge
+}
+
+// newNeedleMap loads the .idx file into a new needle map of the kind, with its own file handle
+func (v *Volume) newNeedleMap(kind NeedleMapKind) (NeedleMapper, error) {
+	indexFile, err := os.OpenFile(v.FileName(".idx"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot write Volume Index %s: %v", v.FileName(".idx"), err)
+	}
+	return v.loadNeedleMap(kind, indexFile)
+}
+
+// loadNeedleMap loads the index file into a new needle map of the kind, which owns the file handle
+func (v *Volume) loadNeedleMap(kind NeedleMapKind, indexFile *os.File) (NeedleMapper, error) {
+	var nm NeedleMapper
+	var err error
+	switch kind {
+	case NeedleMapInMemory:
+		nm, err = LoadCompactNeedleMap(indexFile)
+	case NeedleMapLevelDb, NeedleMapLevelDbMedium, NeedleMapLevelDbLarge:
+		nm, err = NewLevelDbNeedleMap(v.FileName(".ldb"), indexFile, levelDbNeedleMapOptions(kind), v.ldbTimeout)
+	case NeedleMapLsm:
+		nm, err = NewLsmNeedleMap(v.dirIdx, v.Id, v.SuperBlock.CompactionRevision, indexFile)
+	default:
+		err = fmt.Errorf("unknown needle map kind %d", kind)
+	}
+	if err != nil {
+		indexFile.Close()
+		return nil, err
+	}
+	return nm, nil
+}
+
+// SwitchNeedleMap rebuilds the needle map of the volume as another kind from the .idx file, while the volume is online.
+// The new map is built from a copy of the .idx file without blocking the writes,
+// and only catches up with the .idx entries written meanwhile under the exclusive lock.
+func (v *Volume) SwitchNeedleMap(kind NeedleMapKind) error {
+	v.dataFileAccessLock.RLock()
+	oldKind, _, err := v.checkNeedleMapSwitchable()
+	v.dataFileAccessLock.RUnlock()
+	if err != nil || oldKind == kind {
+		return err
+	}
+
+	// the LevelDB kinds share the .ldb directory, and only differ in the memory used
+	if isLevelDbNeedleMapKind(oldKind) && isLevelDbNeedleMapKind(kind) {
+		return v.reopenLevelDbNeedleMap(oldKind, kind)
+	}
+
+	switched, err := v.buildSwitchedNeedleMap(kind)
+	if err != nil {
+		return fmt.Errorf("build %s needle map of volume %d: %v", kind, v.Id, err)
+	}
+	return v.commitSwitchedNeedleMap(oldKind, switched)
+}
+
+type switchedNeedleMap struct {
+	kind       NeedleMapKind
+	nm         NeedleMapper
+	indexFile  *os.File // the .idx file, for the new map after catching up
+	entryCount uint64   // the .idx entries already in the new map
+}
+
+// buildSwitchedNeedleMap builds the needle map from a copy of the .idx file taken without the lock
+func (v *Volume) buildSwitchedNeedleMap(kind NeedleMapKind) (*switchedNeedleMap, error) {
+	indexFile, err := os.OpenFile(v.FileName(".idx"), os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := indexFile.Stat()
+	if err != nil {
+		indexFile.Close()
+		return nil, err
+	}
+	entryCount := uint64(stat.Size() / NeedleMapEntrySize)
+
+	snapshotFileName := v.FileName(".idx.switch")
+	snapshot, err := os.OpenFile(snapshotFileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		indexFile.Close()
+		return nil, err
+	}
+	defer os.Remove(snapshotFileName)
+	if _, err = io.Copy(snapshot, io.NewSectionReader(indexFile, 0, int64(entryCount)*NeedleMapEntrySize)); err != nil {
+		snapshot.Close()
+		indexFile.Close()
+		return nil, fmt.Errorf("copy %s: %v", indexFile.Name(), err)
+	}
+
+	nm, err := v.loadNeedleMap(kind, snapshot)
+	if err != nil {
+		indexFile.Close()
+		return nil, err
+	}
+	return &switchedNeedleMap{kind: kind, nm: nm, indexFile: indexFile, entryCount: entryCount}, nil
+}
+
+// commitSwitchedNeedleMap catches up with the .idx entries written meanwhile, and replaces the needle map
+func (v *Volume) commitSwitchedNeedleMap(oldKind NeedleMapKind, switched *switchedNeedleMap) error {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	currentKind, _, err := v.checkNeedleMapSwitchable()
+	if err == nil && currentKind != oldKind {
+		err = fmt.Errorf("volume %d needle map is switched to %s meanwhile", v.Id, currentKind)
+	}
+	if err == nil {
+		if syncErr := v.nm.Sync(); syncErr != nil {
+			glog.Warningf("sync volume %d idx: %v", v.Id, syncErr)
+		}
+		err = catchUpNeedleMap(switched.nm, switched.indexFile, switched.entryCount)
+	}
+	if err != nil {
+		v.destroyUnusedNeedleMap(switched.kind, switched.nm)
+		switched.indexFile.Close()
+		return err
+	}
+
+	oldNm := v.nm
+	v.nm = switched.nm
+	v.needleMapKind = switched.kind
+	v.destroyUnusedNeedleMap(oldKind, oldNm)
+	glog.V(0).Infof("volume %d switched needle map from %s to %s", v.Id, oldKind, switched.kind)
+	return nil
+}
+
+// reopenLevelDbNeedleMap reopens the .ldb directory with the memory settings of the other LevelDB kind,
+// keeping the metrics of the needle map instead of loading them from the .idx file again
+func (v *Volume) reopenLevelDbNeedleMap(oldKind, kind NeedleMapKind) error {
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	currentKind, _, err := v.checkNeedleMapSwitchable()
+	if err == nil && currentKind != oldKind {
+		err = fmt.Errorf("volume %d needle map is switched to %s meanwhile", v.Id, currentKind)
+	}
+	if err != nil {
+		return err
+	}
+	oldNm, ok := v.nm.(*LevelDbNeedleMap)
+	if !ok {
+		return fmt.Errorf("volume %d needle map is not a LevelDB map", v.Id)
+	}
+	indexFile, err := os.OpenFile(v.FileName(".idx"), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	metric := oldNm.mapMetric
+	oldNm.Close()
+	nm, err := newLevelDbNeedleMap(v.FileName(".ldb"), indexFile, levelDbNeedleMapOptions(kind), v.ldbTimeout, &metric)
+	if err != nil {
+		indexFile.Close()
+		v.nm, _ = v.newNeedleMap(oldKind)
+		return fmt.Errorf("reopen %s needle map of volume %d: %v", kind, v.Id, err)
+	}
+	v.nm = nm
+	v.needleMapKind = kind
+	glog.V(0).Infof("volume %d switched needle map from %s to %s", v.Id, oldKind, kind)
+	return nil
+}
+
+// indexEntryApplier is a needle map catching up with the entries already in the .idx file
+type indexEntryApplier interface {
+	applyIndexEntry(key NeedleId, offset Offset, size Size) error
+	switchIndexFile(indexFile *os.File) error
+}
+
+// catchUpNeedleMap applies the .idx entries from startFrom, and moves the needle map to the .idx file
+func catchUpNeedleMap(nm NeedleMapper, indexFile *os.File, startFrom uint64) error {
+	applier, ok := nm.(indexEntryApplier)
+	if !ok {
+		return fmt.Errorf("needle map %T can not catch up with the .idx file", nm)
+	}
+	if err := idx.WalkIndexFile(indexFile, startFrom, applier.applyIndexEntry); err != nil {
+		return fmt.Errorf("catch up with %s: %v", indexFile.Name(), err)
+	}
+	return applier.switchIndexFile(indexFile)
+}
+
+func (v *Volume) checkNeedleMapSwitchable() (kind NeedleMapKind, indexFileSize uint64, err error) {
+	if v.nm == nil {
+		return 0, 0, fmt.Errorf("volume %d is not loaded", v.Id)
+	}
+	if _, isSorted := v.nm.(*SortedFileNeedleMap); isSorted {
+		return 0, 0, fmt.Errorf("volume %d is read only with a sorted index", v.Id)
+	}
+	if v.isCompacting || v.isCommitCompacting || v.tmpNm != nil {
+		return 0, 0, fmt.Errorf("volume %d is being compacted", v.Id)
+	}
+	return v.needleMapKind, v.nm.IndexFileSize(), nil
+}
+
+// destroyUnusedNeedleMap closes the needle map and removes what it keeps beside the .idx file
+func (v *Volume) destroyUnusedNeedleMap(kind NeedleMapKind, nm NeedleMapper) {
+	switch {
+	case kind == NeedleMapLsm:
+		if err := nm.(*LsmNeedleMap).deleteAllEntries(); err != nil {
+			glog.Warningf("delete volume %d from shared needle index: %v", v.Id, err)
+		}
+		nm.Close()
+	case isLevelDbNeedleMapKind(kind):
+		nm.Close()
+		if err := os.RemoveAll(v.FileName(".ldb")); err != nil {
+			glog.Warningf("remove %s: %v", v.FileName(".ldb"), err)
+		}
+	default:
+		nm.Close()
+	}
+}
@@ -42,6 +42,7 @@ func (v *Volume) readNeedle(n *needle.Needle, readOption *ReadOption, onReadSize
 	}
 	if readOption != nil && readOption.AttemptMetaOnly && readSize > PagedReadLimit {
 		readOption.VolumeRevision = v.SuperBlock.CompactionRevision
+		readOption.SlideRevision = v.slideRevision
 		err = n.ReadNeedleMeta(v.DataBackend, nv.Offset.ToActualOffset(), readSize, v.Version())
 		if err == needle.ErrorSizeMismatch && OffsetSize == 4 {
 			readOption.IsOutOfRange = true
@@ -50,7 +51,7 @@ func (v *Volume) readNeedle(n *needle.Needle, readOption *ReadOption, onReadSize
 		if err != nil {
 			return 0, err
 		}
-		if !n.IsCompressed() && !n.IsChunkedManifest() {
+		if !n.IsCompressed() && !n.IsChunkedManifest() && !n.IsEncrypted() {
 			readOption.IsMetaOnly = true
 		}
 	}
@@ -60,6 +61,9 @@ func (v *Volume) readNeedle(n *needle.Needle, readOption *ReadOption, onReadSize
 		if err != nil {
 			return 0, err
 		}
+		if err = v.decryptNeedle(n); err != nil {
+			return 0, err
+		}
 	}
 	count = int(n.DataSize)
 	if !n.HasTtl() {
@@ -144,7 +148,7 @@ func (v *Volume) readNeedleDataInto(n *needle.Needle, readOption *ReadOption, wr
 			v.dataFileAccessLock.RLock()
 		}
 		// possibly re-read needle offset if volume is compacted
-		if readOption.VolumeRevision != v.SuperBlock.CompactionRevision {
+		if readOption.VolumeRevision != v.SuperBlock.CompactionRevision || readOption.SlideRevision != v.slideRevision {
 			// the volume is compacted
 			nv, ok = v.nm.Get(n.Id)
 			if !ok || nv.Offset.IsZero() {
@@ -155,6 +159,7 @@ func (v *Volume) readNeedleDataInto(n *needle.Needle, readOption *ReadOption, wr
 			}
 			actualOffset = nv.Offset.ToActualOffset()
 			readOption.VolumeRevision = v.SuperBlock.CompactionRevision
+			readOption.SlideRevision = v.slideRevision
 		}
 		count, err := n.ReadNeedleData(v.DataBackend, actualOffset, buf, x)
 		if readOption.HasSlowRead {
@@ -210,6 +215,21 @@ func (v *Volume) ReadNeedleBlob(offset int64, size Size) ([]byte, error) {
 	return needle.ReadNeedleBlob(v.DataBackend, offset, size, v.Version())
 }
 
+// ReadNeedleBlobById reads the current needle with the id, for another replica to copy
+func (v *Volume) ReadNeedleBlobById(needleId NeedleId) ([]byte, error) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	nv, ok := v.nm.Get(needleId)
+	if !ok || nv.Offset.IsZero() {
+		return nil, ErrorNotFound
+	}
+	if nv.Size.IsDeleted() {
+		return nil, ErrorDeleted
+	}
+	return needle.ReadNeedleBlob(v.DataBackend, nv.Offset.ToActualOffset(), nv.Size, v.Version())
+}
+
 type VolumeFileScanner interface {
 	VisitSuperBlock(super_block.SuperBlock) error
 	ReadNeedleBody() bool
@@ -29,6 +29,13 @@ func (scanner *VolumeFileScanner4ReadAll) VisitNeedle(n *needle.Needle, offset i
 		return nil
 	}
 
+	scanner.V.dataFileAccessLock.RLock()
+	err := scanner.V.decryptNeedle(n)
+	scanner.V.dataFileAccessLock.RUnlock()
+	if err != nil {
+		return err
+	}
+
 	sendErr := scanner.Stream.Send(&volume_server_pb.ReadAllNeedlesResponse{
 		VolumeId:             uint32(scanner.V.Id),
 		NeedleId:             uint64(n.Id),
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// ScrubNeedles reads every live needle of the volume in the order of the index file and verifies its CRC.
+// The throttler limits the read speed. The corrupted needles are passed to onCorruptFn.
+func (v *Volume) ScrubNeedles(throttler *util.WriteThrottler, shouldStop func() bool, onCorruptFn func(needleId NeedleId, offset int64, err error)) (scannedBytes int64, needleCount int, err error) {
+	v.dataFileAccessLock.RLock()
+	if v.nm == nil {
+		v.dataFileAccessLock.RUnlock()
+		return 0, 0, fmt.Errorf("volume %d is not loaded", v.Id)
+	}
+	entryCount := int64(v.nm.IndexFileSize()) / NeedleMapEntrySize
+	compactionRevision := v.SuperBlock.CompactionRevision
+	v.dataFileAccessLock.RUnlock()
+
+	for i := int64(0); i < entryCount; i++ {
+		if shouldStop() {
+			return
+		}
+		needleId, offset, size, isLive, verifyErr, scrubErr := v.scrubIndexEntry(i, compactionRevision)
+		if scrubErr != nil {
+			return scannedBytes, needleCount, scrubErr
+		}
+		if !isLive {
+			continue
+		}
+		needleCount++
+		actualSize := needle.GetActualSize(size, v.Version())
+		scannedBytes += actualSize
+		throttler.MaybeSlowdown(actualSize)
+		if verifyErr != nil {
+			onCorruptFn(needleId, offset, verifyErr)
+		}
+	}
+	return
+}
+
+// scrubIndexEntry verifies the needle of the index entry, if the entry is still the current location of the needle
+func (v *Volume) scrubIndexEntry(entry int64, compactionRevision uint16) (needleId NeedleId, offset int64, size Size, isLive bool, verifyErr, err error) {
+	v.dataFileAccessLock.RLock()
+	defer v.dataFileAccessLock.RUnlock()
+
+	if v.nm == nil || v.SuperBlock.CompactionRevision != compactionRevision {
+		return 0, 0, 0, false, nil, fmt.Errorf("volume %d is changed", v.Id)
+	}
+	needleId, entryOffset, size, err := v.nm.ReadIndexEntry(entry)
+	if err != nil {
+		return 0, 0, 0, false, nil, fmt.Errorf("read volume %d index entry %d: %v", v.Id, entry, err)
+	}
+	if entryOffset.IsZero() || !size.IsValid() {
+		return
+	}
+	nv, found := v.nm.Get(needleId)
+	if !found || nv.Offset != entryOffset || nv.Size != size {
+		// overwritten or deleted later
+		return
+	}
+	offset, isLive = entryOffset.ToActualOffset(), true
+
+	blob, readErr := needle.ReadNeedleBlob(v.DataBackend, offset, size, v.Version())
+	if readErr != nil {
+		verifyErr = readErr
+		return
+	}
+	n := new(needle.Needle)
+	if verifyErr = n.ReadBytes(blob, offset, size, v.Version()); verifyErr == nil && n.Id != needleId {
+		verifyErr = fmt.Errorf("found needle %d instead of %d", n.Id, needleId)
+	}
+	return
+}
+
+// RepairNeedle appends a good copy of a corrupted needle, usually read from another replica.
+// The needle is left alone if it is changed after it was found corrupted at corruptOffset.
+func (v *Volume) RepairNeedle(needleId NeedleId, corruptOffset int64, needleBlob []byte) error {
+	version := v.Version()
+	if len(needleBlob) < NeedleHeaderSize {
+		return fmt.Errorf("needle %d copy of %d bytes is too short", needleId, len(needleBlob))
+	}
+	n := new(needle.Needle)
+	n.ParseNeedleHeader(needleBlob)
+	if n.Id != needleId {
+		return fmt.Errorf("copy of needle %d has id %d", needleId, n.Id)
+	}
+	if actualSize := needle.GetActualSize(n.Size, version); int64(len(needleBlob)) != actualSize {
+		return fmt.Errorf("copy of needle %d has %d bytes, expected %d", needleId, len(needleBlob), actualSize)
+	}
+	if err := n.ReadBytes(needleBlob, corruptOffset, n.Size, version); err != nil {
+		return fmt.Errorf("verify copy of needle %d: %v", needleId, err)
+	}
+
+	v.dataFileAccessLock.Lock()
+	defer v.dataFileAccessLock.Unlock()
+
+	if v.nm == nil {
+		return fmt.Errorf("volume %d is not loaded", v.Id)
+	}
+	nv, found := v.nm.Get(needleId)
+	if !found || nv.Offset.ToActualOffset() != corruptOffset {
+		return fmt.Errorf("needle %d is changed since found corrupted", needleId)
+	}
+	return v.doWriteNeedleBlob(needleId, needleBlob, n.Size)
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+func TestScrubAndRepairNeedles(t *testing.T) {
+	dir, replicaDir := t.TempDir(), t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+	replica, err := NewVolume(replicaDir, replicaDir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("replica creation: %v", err)
+	}
+	defer replica.Close()
+
+	offsets := make(map[types.NeedleId]int64)
+	for i := 1; i <= 30; i++ {
+		// the first 10 needles are updated, and needle 20 is deleted
+		n := newRandomNeedle(uint64((i-1)%20 + 1))
+		n.Data = append(n.Data, byte(i))
+		n.Checksum = needle.NewCRC(n.Data)
+		offset, _, _, err := v.writeNeedle2(n, true, false, 0)
+		if err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+		if _, _, _, err = replica.writeNeedle2(n, true, false, 0); err != nil {
+			t.Fatalf("write replica needle %d: %v", i, err)
+		}
+		offsets[n.Id] = int64(offset)
+	}
+	v.deleteNeedle2(newEmptyNeedle(20))
+
+	throttler := util.NewWriteThrottler(0)
+	neverStop := func() bool { return false }
+	scrub := func() (corrupt map[types.NeedleId]int64, needleCount int) {
+		corrupt = make(map[types.NeedleId]int64)
+		_, needleCount, err := v.ScrubNeedles(throttler, neverStop, func(needleId types.NeedleId, offset int64, err error) {
+			corrupt[needleId] = offset
+		})
+		if err != nil {
+			t.Fatalf("scrub: %v", err)
+		}
+		return
+	}
+
+	if corrupt, needleCount := scrub(); len(corrupt) != 0 || needleCount != 19 {
+		t.Fatalf("scrub clean volume: %d corrupt needles, %d needles", len(corrupt), needleCount)
+	}
+
+	// flip one byte of the data of needle 5
+	b := make([]byte, 1)
+	dataOffset := offsets[5] + types.NeedleHeaderSize + 4
+	if _, err = v.DataBackend.ReadAt(b, dataOffset); err != nil {
+		t.Fatalf("read data: %v", err)
+	}
+	b[0] ^= 0xff
+	if _, err = v.DataBackend.WriteAt(b, dataOffset); err != nil {
+		t.Fatalf("corrupt data: %v", err)
+	}
+
+	corrupt, _ := scrub()
+	if len(corrupt) != 1 || corrupt[5] != offsets[5] {
+		t.Fatalf("expected needle 5 to be corrupt, found %v", corrupt)
+	}
+
+	blob, err := replica.ReadNeedleBlobById(5)
+	if err != nil {
+		t.Fatalf("read replica needle: %v", err)
+	}
+	if err = v.RepairNeedle(5, offsets[6], blob); err == nil {
+		t.Errorf("repaired the needle at a wrong offset")
+	}
+	if err = v.RepairNeedle(5, corrupt[5], blob[:len(blob)-8]); err == nil {
+		t.Errorf("repaired the needle with a truncated copy")
+	}
+	if err = v.RepairNeedle(5, corrupt[5], blob); err != nil {
+		t.Fatalf("repair needle 5: %v", err)
+	}
+
+	if corrupt, needleCount := scrub(); len(corrupt) != 0 || needleCount != 19 {
+		t.Fatalf("scrub repaired volume: %d corrupt needles, %d needles", len(corrupt), needleCount)
+	}
+	n, expected := newEmptyNeedle(5), newEmptyNeedle(5)
+	if _, err = v.readNeedle(n, nil, nil); err != nil {
+		t.Fatalf("read repaired needle: %v", err)
+	}
+	if _, err = replica.readNeedle(expected, nil, nil); err != nil {
+		t.Fatalf("read replica needle: %v", err)
+	}
+	if !bytes.Equal(n.Data, expected.Data) {
+		t.Errorf("repaired needle data mismatch")
+	}
+
+	if _, err = v.ReadNeedleBlobById(20); err != ErrorDeleted {
+		t.Errorf("read deleted needle: %v", err)
+	}
+}
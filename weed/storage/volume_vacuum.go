@@ -54,9 +54,11 @@ func (v *Volume) Compact(preallocate int64, compactionBytePerSecond int64) error
 	defer func() {
 		v.isCompacting = false
 	}()
+	v.inPlaceCompactOffset = 0
 
 	v.lastCompactIndexOffset = v.IndexFileSize()
 	v.lastCompactRevision = v.SuperBlock.CompactionRevision
+	dataKeys, dataKeyId := v.compactDataKeys()
 	glog.V(3).Infof("creating copies for volume %d ,last offset %d...", v.Id, v.lastCompactIndexOffset)
 	if err := v.DataBackend.Sync(); err != nil {
 		glog.V(0).Infof("compact failed to sync volume %d", v.Id)
@@ -64,7 +66,7 @@ func (v *Volume) Compact(preallocate int64, compactionBytePerSecond int64) error
 	if err := v.nm.Sync(); err != nil {
 		glog.V(0).Infof("compact failed to sync volume idx %d", v.Id)
 	}
-	return v.copyDataAndGenerateIndexFile(v.FileName(".cpd"), v.FileName(".cpx"), preallocate, compactionBytePerSecond)
+	return v.copyDataAndGenerateIndexFile(v.FileName(".cpd"), v.FileName(".cpx"), v.compactVersion(), preallocate, compactionBytePerSecond, dataKeys, dataKeyId)
 }
 
 // compact a volume based on deletions in .idx files
@@ -83,9 +85,11 @@ func (v *Volume) Compact2(preallocate int64, compactionBytePerSecond int64, prog
 	defer func() {
 		v.isCompacting = false
 	}()
+	v.inPlaceCompactOffset = 0
 
 	v.lastCompactIndexOffset = v.IndexFileSize()
 	v.lastCompactRevision = v.SuperBlock.CompactionRevision
+	dataKeys, dataKeyId := v.compactDataKeys()
 	glog.V(3).Infof("creating copies for volume %d ...", v.Id)
 	if v.DataBackend == nil {
 		return fmt.Errorf("volume %d backend is empty remote:%v", v.Id, v.HasRemoteFile())
@@ -96,14 +100,18 @@ func (v *Volume) Compact2(preallocate int64, compactionBytePerSecond int64, prog
 	if err := v.nm.Sync(); err != nil {
 		glog.V(0).Infof("compact2 failed to sync volume idx %d: %v", v.Id, err)
 	}
+	sb := v.SuperBlock
+	sb.Version = v.compactVersion()
 	return v.copyDataBasedOnIndexFile(
 		v.FileName(".dat"), v.FileName(".idx"),
 		v.FileName(".cpd"), v.FileName(".cpx"),
-		v.SuperBlock,
+		sb,
 		v.Version(),
 		preallocate,
 		compactionBytePerSecond,
 		progressFn,
+		dataKeys,
+		dataKeyId,
 	)
 }
 
@@ -126,6 +134,9 @@ func (v *Volume) CommitCompact() error {
 	defer v.dataFileAccessLock.Unlock()
 
 	glog.V(3).Infof("Got volume %d committing lock...", v.Id)
+	if v.inPlaceCompactOffset != 0 {
+		return v.commitCompactInPlace()
+	}
 	if v.nm != nil {
 		v.nm.Close()
 		v.nm = nil
@@ -139,9 +150,11 @@ func (v *Volume) CommitCompact() error {
 	stats.VolumeServerVolumeGauge.WithLabelValues(v.Collection, "volume").Dec()
 
 	var e error
+	reencrypted := true
 	if e = v.makeupDiff(v.FileName(".cpd"), v.FileName(".cpx"), v.FileName(".dat"), v.FileName(".idx")); e != nil {
 		glog.V(0).Infof("makeupDiff in CommitCompact volume %d failed %v", v.Id, e)
-		e = os.Remove(v.FileName(".cpd"))
+		reencrypted = false
+		e = backend.RemoveVolumeFile(v.FileName(".cpd"))
 		if e != nil {
 			return e
 		}
@@ -161,7 +174,7 @@ func (v *Volume) CommitCompact() error {
 			}
 		}
 		var e error
-		if e = os.Rename(v.FileName(".cpd"), v.FileName(".dat")); e != nil {
+		if e = backend.RenameVolumeFile(v.FileName(".cpd"), v.FileName(".dat")); e != nil {
 			return fmt.Errorf("rename %s: %v", v.FileName(".cpd"), e)
 		}
 		if e = os.Rename(v.FileName(".cpx"), v.FileName(".idx")); e != nil {
@@ -178,14 +191,23 @@ func (v *Volume) CommitCompact() error {
 	if e = v.load(true, false, v.needleMapKind, 0); e != nil {
 		return e
 	}
+	if e = v.finishUpgrade(); e != nil {
+		glog.Warningf("volume %d failed to save the upgraded needle version: %v", v.Id, e)
+	}
+	if reencrypted && v.lastCompactDataKeyId != 0 {
+		if e = v.dropDataKeysBefore(v.lastCompactDataKeyId); e != nil {
+			glog.Warningf("volume %d failed to drop the re-encrypted data keys: %v", v.Id, e)
+		}
+	}
 	glog.V(3).Infof("Finish committing volume %d", v.Id)
 	return nil
 }
 
 func (v *Volume) cleanupCompact() error {
 	glog.V(0).Infof("Cleaning up volume %d vacuuming...", v.Id)
+	v.inPlaceCompactOffset = 0
 
-	e1 := os.Remove(v.FileName(".cpd"))
+	e1 := backend.RemoveVolumeFile(v.FileName(".cpd"))
 	e2 := os.Remove(v.FileName(".cpx"))
 	e3 := os.RemoveAll(v.FileName(".cpldb"))
 	if e1 != nil && !os.IsNotExist(e1) {
@@ -218,11 +240,10 @@ func (v *Volume) makeupDiff(newDatFileName, newIdxFileName, oldDatFileName, oldI
 	}
 	defer oldIdxFile.Close()
 
-	oldDatFile, err := os.Open(oldDatFileName)
+	oldDatBackend, err := backend.OpenVolumeFile(oldDatFileName, os.O_RDONLY)
 	if err != nil {
 		return fmt.Errorf("makeupDiff open %s failed: %v", oldDatFileName, err)
 	}
-	oldDatBackend := backend.NewDiskFile(oldDatFile)
 	defer oldDatBackend.Close()
 
 	// skip if the old .idx file has not changed
@@ -236,7 +257,7 @@ func (v *Volume) makeupDiff(newDatFileName, newIdxFileName, oldDatFileName, oldI
 	// fail if the old .dat file has changed to a new revision
 	oldDatCompactRevision, err := fetchCompactRevisionFromDatFile(oldDatBackend)
 	if err != nil {
-		return fmt.Errorf("fetchCompactRevisionFromDatFile src %s failed: %v", oldDatFile.Name(), err)
+		return fmt.Errorf("fetchCompactRevisionFromDatFile src %s failed: %v", oldDatFileName, err)
 	}
 	if oldDatCompactRevision != v.lastCompactRevision {
 		return fmt.Errorf("current old dat file's compact revision %d is not the expected one %d", oldDatCompactRevision, v.lastCompactRevision)
@@ -269,13 +290,11 @@ func (v *Volume) makeupDiff(newDatFileName, newIdxFileName, oldDatFileName, oldI
 	}
 
 	// deal with updates during commit step
-	var (
-		dst, idx *os.File
-	)
-	if dst, err = os.OpenFile(newDatFileName, os.O_RDWR, 0644); err != nil {
+	var idx *os.File
+	dstDatBackend, err := backend.OpenVolumeFile(newDatFileName, os.O_RDWR)
+	if err != nil {
 		return fmt.Errorf("open dat file %s failed: %v", newDatFileName, err)
 	}
-	dstDatBackend := backend.NewDiskFile(dst)
 	defer dstDatBackend.Close()
 
 	if idx, err = os.OpenFile(newIdxFileName, os.O_RDWR, 0644); err != nil {
@@ -293,11 +312,11 @@ func (v *Volume) makeupDiff(newDatFileName, newIdxFileName, oldDatFileName, oldI
 	}
 	idxSize := stat.Size()
 
-	var newDatCompactRevision uint16
-	newDatCompactRevision, err = fetchCompactRevisionFromDatFile(dstDatBackend)
+	newSuperBlock, err := super_block.ReadSuperBlock(dstDatBackend)
 	if err != nil {
-		return fmt.Errorf("fetchCompactRevisionFromDatFile dst %s failed: %v", dst.Name(), err)
+		return fmt.Errorf("ReadSuperBlock dst %s failed: %v", newDatFileName, err)
 	}
+	newDatCompactRevision := newSuperBlock.CompactionRevision
 	if oldDatCompactRevision+1 != newDatCompactRevision {
 		return fmt.Errorf("oldDatFile %s 's compact revision is %d while newDatFile %s 's compact revision is %d", oldDatFileName, oldDatCompactRevision, newDatFileName, newDatCompactRevision)
 	}
@@ -307,17 +326,13 @@ func (v *Volume) makeupDiff(newDatFileName, newIdxFileName, oldDatFileName, oldI
 		idxEntryBytes := needle_map.ToBytes(key, increIdxEntry.offset, increIdxEntry.size)
 
 		var offset int64
-		if offset, err = dst.Seek(0, 2); err != nil {
-			glog.V(0).Infof("failed to seek the end of file: %v", err)
+		if offset, _, err = dstDatBackend.GetStat(); err != nil {
+			glog.V(0).Infof("failed to stat the end of file: %v", err)
 			return
 		}
 		//ensure file writing starting from aligned positions
 		if offset%NeedlePaddingSize != 0 {
 			offset = offset + (NeedlePaddingSize - offset%NeedlePaddingSize)
-			if offset, err = dst.Seek(offset, 0); err != nil {
-				glog.V(0).Infof("failed to align in datafile %s: %v", dst.Name(), err)
-				return
-			}
 		}
 		//updated needle
 		if !increIdxEntry.offset.IsZero() && increIdxEntry.size != 0 && increIdxEntry.size.IsValid() {
@@ -326,20 +341,29 @@ func (v *Volume) makeupDiff(newDatFileName, newIdxFileName, oldDatFileName, oldI
 			var needleBytes []byte
 			needleBytes, err = needle.ReadNeedleBlob(oldDatBackend, increIdxEntry.offset.ToActualOffset(), increIdxEntry.size, v.Version())
 			if err != nil {
-				return fmt.Errorf("ReadNeedleBlob %s key %d offset %d size %d failed: %v", oldDatFile.Name(), key, increIdxEntry.offset.ToActualOffset(), increIdxEntry.size, err)
+				return fmt.Errorf("ReadNeedleBlob %s key %d offset %d size %d failed: %v", oldDatFileName, key, increIdxEntry.offset.ToActualOffset(), increIdxEntry.size, err)
+			}
+			if newSuperBlock.Version != v.Version() {
+				// the volume is upgraded to a new needle version
+				if idxEntryBytes, err = v.convertNeedleBlob(dstDatBackend, key, needleBytes, increIdxEntry.offset, increIdxEntry.size, newSuperBlock.Version); err != nil {
+					return err
+				}
+			} else {
+				if _, err = dstDatBackend.WriteAt(needleBytes, offset); err != nil {
+					return fmt.Errorf("cannot write needle %s: %v", dstDatBackend.Name(), err)
+				}
+				util.Uint32toBytes(idxEntryBytes[8:12], uint32(offset/NeedlePaddingSize))
 			}
-			dstDatBackend.Write(needleBytes)
 			if err := dstDatBackend.Sync(); err != nil {
-				return fmt.Errorf("cannot sync needle %s: %v", dstDatBackend.File.Name(), err)
+				return fmt.Errorf("cannot sync needle %s: %v", dstDatBackend.Name(), err)
 			}
-			util.Uint32toBytes(idxEntryBytes[8:12], uint32(offset/NeedlePaddingSize))
 		} else { //deleted needle
 			//fakeDelNeedle's default Data field is nil
 			fakeDelNeedle := new(needle.Needle)
 			fakeDelNeedle.Id = key
 			fakeDelNeedle.Cookie = 0x12345678
 			fakeDelNeedle.AppendAtNs = uint64(time.Now().UnixNano())
-			_, _, _, err = fakeDelNeedle.Append(dstDatBackend, v.Version())
+			_, _, _, err = fakeDelNeedle.Append(dstDatBackend, newSuperBlock.Version)
 			if err != nil {
 				return fmt.Errorf("append deleted %d failed: %v", key, err)
 			}
@@ -359,6 +383,23 @@ func (v *Volume) makeupDiff(newDatFileName, newIdxFileName, oldDatFileName, oldI
 	return v.tmpNm.DoOffsetLoading(v, idx, uint64(idxSize)/NeedleMapEntrySize)
 }
 
+// convertNeedleBlob appends the needle blob in the old version to the new .dat file in the upgraded version,
+// and returns its new .idx entry
+func (v *Volume) convertNeedleBlob(dstDatBackend backend.BackendStorageFile, key NeedleId, needleBytes []byte, offset Offset, size Size, version needle.Version) ([]byte, error) {
+	n := new(needle.Needle)
+	if err := n.ReadBytes(needleBytes, offset.ToActualOffset(), size, v.Version()); err != nil {
+		return nil, fmt.Errorf("read needle %d to upgrade: %v", key, err)
+	}
+	if err := reencryptNeedle(n, version, v.dataKeys, v.dataKeyId); err != nil {
+		return nil, fmt.Errorf("cannot re-encrypt needle %d: %v", key, err)
+	}
+	newOffset, _, _, err := n.Append(dstDatBackend, version)
+	if err != nil {
+		return nil, fmt.Errorf("cannot append needle %d to %s: %v", key, dstDatBackend.Name(), err)
+	}
+	return needle_map.ToBytes(key, ToOffset(int64(newOffset)), n.Size), nil
+}
+
 type VolumeFileScanner4Vacuum struct {
 	version        needle.Version
 	v              *Volume
@@ -367,10 +408,12 @@ type VolumeFileScanner4Vacuum struct {
 	newOffset      int64
 	now            uint64
 	writeThrottler *util.WriteThrottler
+	dataKeys       map[uint32]util.CipherKey
+	dataKeyId      uint32
 }
 
 func (scanner *VolumeFileScanner4Vacuum) VisitSuperBlock(superBlock super_block.SuperBlock) error {
-	scanner.version = superBlock.Version
+	superBlock.Version = scanner.version
 	superBlock.CompactionRevision++
 	_, err := scanner.dstBackend.WriteAt(superBlock.Bytes(), 0)
 	scanner.newOffset = int64(superBlock.BlockSize())
@@ -388,12 +431,15 @@ func (scanner *VolumeFileScanner4Vacuum) VisitNeedle(n *needle.Needle, offset in
 	nv, ok := scanner.v.nm.Get(n.Id)
 	glog.V(4).Infoln("needle expected offset ", offset, "ok", ok, "nv", nv)
 	if ok && nv.Offset.ToActualOffset() == offset && nv.Size > 0 && nv.Size.IsValid() {
-		if err := scanner.nm.Set(n.Id, ToOffset(scanner.newOffset), n.Size); err != nil {
-			return fmt.Errorf("cannot put needle: %s", err)
+		if err := reencryptNeedle(n, scanner.version, scanner.dataKeys, scanner.dataKeyId); err != nil {
+			return fmt.Errorf("cannot re-encrypt needle: %s", err)
 		}
-		if _, _, _, err := n.Append(scanner.dstBackend, scanner.v.Version()); err != nil {
+		if _, _, _, err := n.Append(scanner.dstBackend, scanner.version); err != nil {
 			return fmt.Errorf("cannot append needle: %s", err)
 		}
+		if err := scanner.nm.Set(n.Id, ToOffset(scanner.newOffset), n.Size); err != nil {
+			return fmt.Errorf("cannot put needle: %s", err)
+		}
 		delta := n.DiskSize(scanner.version)
 		scanner.newOffset += delta
 		scanner.writeThrottler.MaybeSlowdown(delta)
@@ -402,7 +448,7 @@ func (scanner *VolumeFileScanner4Vacuum) VisitNeedle(n *needle.Needle, offset in
 	return nil
 }
 
-func (v *Volume) copyDataAndGenerateIndexFile(dstName, idxName string, preallocate int64, compactionBytePerSecond int64) (err error) {
+func (v *Volume) copyDataAndGenerateIndexFile(dstName, idxName string, version needle.Version, preallocate int64, compactionBytePerSecond int64, dataKeys map[uint32]util.CipherKey, dataKeyId uint32) (err error) {
 	var dst backend.BackendStorageFile
 	if dst, err = backend.CreateVolumeFile(dstName, preallocate, 0); err != nil {
 		return err
@@ -413,11 +459,14 @@ func (v *Volume) copyDataAndGenerateIndexFile(dstName, idxName string, prealloca
 	defer nm.Close()
 
 	scanner := &VolumeFileScanner4Vacuum{
+		version:        version,
 		v:              v,
 		now:            uint64(time.Now().Unix()),
 		nm:             nm,
 		dstBackend:     dst,
 		writeThrottler: util.NewWriteThrottler(compactionBytePerSecond),
+		dataKeys:       dataKeys,
+		dataKeyId:      dataKeyId,
 	}
 	err = ScanVolumeFile(v.dir, v.Collection, v.Id, v.needleMapKind, scanner)
 	if err != nil {
@@ -427,10 +476,9 @@ func (v *Volume) copyDataAndGenerateIndexFile(dstName, idxName string, prealloca
 	return nm.SaveToIdx(idxName)
 }
 
-func (v *Volume) copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, datIdxName string, sb super_block.SuperBlock, version needle.Version, preallocate, compactionBytePerSecond int64, progressFn ProgressFunc) (err error) {
+func (v *Volume) copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, datIdxName string, sb super_block.SuperBlock, version needle.Version, preallocate, compactionBytePerSecond int64, progressFn ProgressFunc, dataKeys map[uint32]util.CipherKey, dataKeyId uint32) (err error) {
 	var (
 		srcDatBackend, dstDatBackend backend.BackendStorageFile
-		dataFile                     *os.File
 	)
 	if dstDatBackend, err = backend.CreateVolumeFile(dstDatName, preallocate, 0); err != nil {
 		return err
@@ -447,10 +495,9 @@ func (v *Volume) copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, da
 	if err = oldNm.LoadFromIdx(srcIdxName); err != nil {
 		return err
 	}
-	if dataFile, err = os.Open(srcDatName); err != nil {
+	if srcDatBackend, err = backend.OpenVolumeFile(srcDatName, os.O_RDONLY); err != nil {
 		return err
 	}
-	srcDatBackend = backend.NewDiskFile(dataFile)
 	defer srcDatBackend.Close()
 
 	now := uint64(time.Now().Unix())
@@ -483,13 +530,16 @@ func (v *Volume) copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, da
 			return nil
 		}
 
-		if err = newNm.Set(n.Id, ToOffset(newOffset), n.Size); err != nil {
-			return fmt.Errorf("cannot put needle: %s", err)
+		if err = reencryptNeedle(n, sb.Version, dataKeys, dataKeyId); err != nil {
+			return fmt.Errorf("cannot re-encrypt needle: %s", err)
 		}
 		if _, _, _, err = n.Append(dstDatBackend, sb.Version); err != nil {
 			return fmt.Errorf("cannot append needle: %s", err)
 		}
-		delta := n.DiskSize(version)
+		if err = newNm.Set(n.Id, ToOffset(newOffset), n.Size); err != nil {
+			return fmt.Errorf("cannot put needle: %s", err)
+		}
+		delta := n.DiskSize(sb.Version)
 		newOffset += delta
 		writeThrottler.MaybeSlowdown(delta)
 		glog.V(4).Infoln("saving key", n.Id, "volume offset", offset, "=>", newOffset, "data_size", n.Size)
@@ -542,6 +592,13 @@ func (v *Volume) copyDataBasedOnIndexFile(srcDatName, srcIdxName, dstDatName, da
 		//can be optimized, filling nm in oldNm.AscendingVisit
 		err = v.tmpNm.DoOffsetLoading(nil, indexFile, 0)
 		return err
+	} else if v.needleMapKind == NeedleMapLsm {
+		m, err := newLsmNeedleMap(v.dirIdx, v.Id, sb.CompactionRevision)
+		if err != nil {
+			return err
+		}
+		v.tmpNm = m
+		return v.tmpNm.DoOffsetLoading(v, indexFile, 0)
 	} else {
 		dbFileName := v.FileName(".ldb")
 		m := &LevelDbNeedleMap{dbFileName: dbFileName}
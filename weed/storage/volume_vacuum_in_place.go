@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/stats"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
+	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
+	"github.com/seaweedfs/seaweedfs/weed/util"
+)
+
+// the .dat file is compacted in place one segment at a time, holding the write lock only for one segment
+const inPlaceCompactionSegmentSize = 4 * 1024 * 1024
+
+type slidingNeedle struct {
+	key    NeedleId
+	offset int64
+	size   Size
+}
+
+// slidNeedle is written to its new offset, and its .idx entry is not updated yet
+type slidNeedle struct {
+	slidingNeedle
+	oldOffset int64
+}
+
+// CompactInPlace compacts the volume without a .cpd copy, so it needs no extra disk space.
+// The live needles are slid towards the start of the .dat file segment by segment, while reads and writes continue
+// between the segments. A needle is only written over the stale data before it, and the .idx entry of its new
+// location is synced before its old location can be reused, so the volume stays consistent if interrupted.
+// The needles written during the compaction are slid, and the .dat file is truncated, by CommitCompact.
+func (v *Volume) CompactInPlace(compactionBytePerSecond int64, progressFn ProgressFunc) error {
+
+	if v.MemoryMapMaxSizeMb != 0 { //it makes no sense to compact in memory
+		return nil
+	}
+	glog.V(3).Infof("CompactInPlace volume %d ...", v.Id)
+
+	if v.isCompacting || v.isCommitCompacting {
+		glog.V(0).Infof("Volume %d is already compacting in place ...", v.Id)
+		return nil
+	}
+	v.isCompacting = true
+	defer func() {
+		v.isCompacting = false
+	}()
+	v.inPlaceCompactOffset = 0
+
+	if v.DataBackend == nil {
+		return fmt.Errorf("volume %d backend is empty remote:%v", v.Id, v.HasRemoteFile())
+	}
+	if !v.canCompactInPlace() {
+		return fmt.Errorf("volume %d is read only", v.Id)
+	}
+
+	v.dataFileAccessLock.Lock()
+	if err := v.nm.Sync(); err != nil {
+		v.dataFileAccessLock.Unlock()
+		return fmt.Errorf("sync volume %d idx: %v", v.Id, err)
+	}
+	v.lastCompactIndexOffset = v.nm.IndexFileSize()
+	datSize, _, err := v.DataBackend.GetStat()
+	if err == nil {
+		// the .dat file is changed by this compaction, persisted once for the replicas and the copies to notice
+		v.SuperBlock.CompactionRevision++
+		if _, err = v.DataBackend.WriteAt(v.SuperBlock.Bytes(), 0); err != nil {
+			err = fmt.Errorf("write super block: %v", err)
+		}
+	}
+	writeOffset := int64(v.SuperBlock.BlockSize())
+	v.dataFileAccessLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// the needles written from now on are found in the .idx entries after lastCompactIndexOffset
+	liveNm := needle_map.NewMemDb()
+	defer liveNm.Close()
+	if err = liveNm.LoadFromIdx(v.FileName(".idx")); err != nil {
+		return err
+	}
+	var needles []slidingNeedle
+	err = liveNm.AscendingVisit(func(value needle_map.NeedleValue) error {
+		if !value.Offset.IsZero() && !value.Size.IsDeleted() && value.Offset.ToActualOffset() < datSize {
+			needles = append(needles, slidingNeedle{key: value.Key, offset: value.Offset.ToActualOffset(), size: value.Size})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(needles, func(i, j int) bool {
+		return needles[i].offset < needles[j].offset
+	})
+
+	writeThrottler := util.NewWriteThrottler(compactionBytePerSecond)
+	for len(needles) > 0 {
+		segmentEnd := needles[0].offset + inPlaceCompactionSegmentSize
+		count := sort.Search(len(needles), func(i int) bool {
+			return needles[i].offset >= segmentEnd
+		})
+		count = max(count, 1)
+
+		if progressFn != nil {
+			if !progressFn(needles[0].offset) {
+				return fmt.Errorf("interrupted")
+			}
+		}
+
+		var moved int64
+		v.dataFileAccessLock.Lock()
+		writeOffset, moved, err = v.slideNeedles(needles[:count], writeOffset)
+		v.dataFileAccessLock.Unlock()
+		if err != nil {
+			return err
+		}
+		writeThrottler.MaybeSlowdown(moved)
+		needles = needles[count:]
+	}
+
+	v.inPlaceCompactOffset = writeOffset
+	glog.V(0).Infof("volume %d compacted in place from %d to %d bytes", v.Id, datSize, writeOffset)
+	return nil
+}
+
+// canCompactInPlace tells whether the needle map of the volume can be updated, unlike the sorted .sdx file of a read only volume
+func (v *Volume) canCompactInPlace() bool {
+	v.noWriteLock.RLock()
+	defer v.noWriteLock.RUnlock()
+	return !v.noWriteOrDelete && !v.noWriteCanDelete
+}
+
+// slideNeedles moves the live needles, sorted by offset, to the write offset, and returns the new write offset.
+// The caller holds the dataFileAccessLock.
+func (v *Volume) slideNeedles(needles []slidingNeedle, writeOffset int64) (newWriteOffset int64, moved int64, err error) {
+	version := v.Version()
+
+	// the stale needles must be stale on the disk before being overwritten
+	if err = v.DataBackend.Sync(); err != nil {
+		return writeOffset, 0, err
+	}
+	if err = v.nm.Sync(); err != nil {
+		return writeOffset, 0, err
+	}
+
+	// the needles are slid in the order of their old offsets, so the first pending one has the lowest old offset
+	var pending []slidNeedle
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		if err := v.DataBackend.Sync(); err != nil {
+			return err
+		}
+		// the slow reads re-locate the needles when the slide revision changes
+		v.slideRevision++
+		for _, n := range pending {
+			if err := v.nm.Put(n.key, ToOffset(n.offset), n.size); err != nil {
+				return fmt.Errorf("put needle %d: %v", n.key, err)
+			}
+		}
+		pending = pending[:0]
+		return v.nm.Sync()
+	}
+
+	for _, n := range needles {
+		nv, ok := v.nm.Get(n.key)
+		if !ok || nv.Offset.ToActualOffset() != n.offset || nv.Size != n.size {
+			// deleted or overwritten
+			continue
+		}
+		actualSize := needle.GetActualSize(n.size, version)
+		// the old locations of the pending needles are still referenced by the .idx file
+		if len(pending) > 0 && writeOffset+actualSize > pending[0].oldOffset {
+			if err = flush(); err != nil {
+				return writeOffset, moved, err
+			}
+		}
+		if writeOffset+actualSize > n.offset {
+			// not moving a needle over itself, leaving the gap before it
+			writeOffset = n.offset + actualSize
+			continue
+		}
+		needleBytes, readErr := needle.ReadNeedleBlob(v.DataBackend, n.offset, n.size, version)
+		if readErr != nil {
+			return writeOffset, moved, fmt.Errorf("read needle %d at %d: %v", n.key, n.offset, readErr)
+		}
+		if _, err = v.DataBackend.WriteAt(needleBytes, writeOffset); err != nil {
+			return writeOffset, moved, fmt.Errorf("write needle %d at %d: %v", n.key, writeOffset, err)
+		}
+		glog.V(4).Infoln("sliding key", n.key, "volume offset", n.offset, "=>", writeOffset)
+		pending = append(pending, slidNeedle{slidingNeedle: slidingNeedle{key: n.key, offset: writeOffset, size: n.size}, oldOffset: n.offset})
+		writeOffset += actualSize
+		moved += actualSize
+	}
+	return writeOffset, moved, flush()
+}
+
+// commitCompactInPlace slides the needles written during the in place compaction, truncates the .dat file,
+// and reloads the volume with a compact .idx file. The caller holds the dataFileAccessLock.
+func (v *Volume) commitCompactInPlace() error {
+	writeOffset := v.inPlaceCompactOffset
+	v.inPlaceCompactOffset = 0
+
+	// the needles written or overwritten during the compaction
+	var needles []slidingNeedle
+	seen := make(map[NeedleId]bool)
+	for i := int64(v.lastCompactIndexOffset / NeedleMapEntrySize); i < int64(v.nm.IndexFileSize()/NeedleMapEntrySize); i++ {
+		key, _, _, err := v.nm.ReadIndexEntry(i)
+		if err != nil {
+			return fmt.Errorf("read index entry %d: %v", i, err)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if nv, ok := v.nm.Get(key); ok && !nv.Offset.IsZero() && !nv.Size.IsDeleted() && nv.Offset.ToActualOffset() >= writeOffset {
+			needles = append(needles, slidingNeedle{key: key, offset: nv.Offset.ToActualOffset(), size: nv.Size})
+		}
+	}
+	sort.Slice(needles, func(i, j int) bool {
+		return needles[i].offset < needles[j].offset
+	})
+	writeOffset, _, err := v.slideNeedles(needles, writeOffset)
+	if err != nil {
+		return err
+	}
+	if err = v.DataBackend.Truncate(writeOffset); err != nil {
+		return fmt.Errorf("truncate %s to %d: %v", v.FileName(".dat"), writeOffset, err)
+	}
+	if err = v.DataBackend.Sync(); err != nil {
+		return err
+	}
+
+	compactNm := needle_map.NewMemDb()
+	defer compactNm.Close()
+	if err = compactNm.LoadFromIdx(v.FileName(".idx")); err != nil {
+		return err
+	}
+	if err = compactNm.SaveToIdx(v.FileName(".cpx")); err != nil {
+		return err
+	}
+
+	v.nm.Close()
+	v.nm = nil
+	if err = v.DataBackend.Close(); err != nil {
+		glog.V(0).Infof("failed to close volume %d", v.Id)
+	}
+	v.DataBackend = nil
+	stats.VolumeServerVolumeGauge.WithLabelValues(v.Collection, "volume").Dec()
+
+	if err = os.Rename(v.FileName(".cpx"), v.FileName(".idx")); err != nil {
+		return fmt.Errorf("rename %s: %v", v.FileName(".cpx"), err)
+	}
+	os.RemoveAll(v.FileName(".ldb"))
+
+	glog.V(3).Infof("Loading volume %d compacted in place ...", v.Id)
+	if err = v.load(true, false, v.needleMapKind, 0); err != nil {
+		return err
+	}
+	glog.V(3).Infof("Finish committing volume %d compacted in place", v.Id)
+	return nil
+}
@@ -1,11 +1,15 @@
 package storage
 
 import (
+	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"github.com/seaweedfs/seaweedfs/weed/storage/super_block"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
@@ -69,6 +73,10 @@ func TestLDBIndexCompaction(t *testing.T) {
 	testCompaction(t, NeedleMapLevelDb)
 }
 
+func TestLsmIndexCompaction(t *testing.T) {
+	testCompaction(t, NeedleMapLsm)
+}
+
 func testCompaction(t *testing.T, needleMapKind NeedleMapKind) {
 	dir := t.TempDir()
 
@@ -145,9 +153,220 @@ func testCompaction(t *testing.T, needleMapKind NeedleMapKind) {
 	}
 
 }
+func TestBlockDeviceCompaction(t *testing.T) {
+	dir := t.TempDir()
+	devicePath := filepath.Join(t.TempDir(), "volumes.img")
+	device, err := backend.OpenBlockDevice(devicePath, 16*1024*1024, 64*1024, true)
+	if err != nil {
+		t.Fatalf("format block device: %v", err)
+	}
+	device.Close()
+	if device, err = backend.AttachBlockDevice(dir, devicePath, false); err != nil {
+		t.Fatalf("attach block device: %v", err)
+	}
+	defer backend.DetachBlockDevice(dir)
+
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	if _, isOnBlockDevice := v.DataBackend.(*backend.BlockDeviceFile); !isOnBlockDevice {
+		t.Fatalf("volume data is not on the block device")
+	}
+
+	infos := make([]*needleInfo, 2100)
+	for i := 1; i <= 1000; i++ {
+		doSomeWritesDeletes(i, v, t, infos)
+	}
+	if err = v.Compact2(0, 0, nil); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	for i := 1; i <= 2000; i++ {
+		doSomeWritesDeletes(i, v, t, infos)
+	}
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit compaction: %v", err)
+	}
+	if names := device.FileNames(); len(names) != 1 || names[0] != "1.dat" {
+		t.Fatalf("block device files after compaction: %v", names)
+	}
+	v.Close()
+
+	v, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume reloading: %v", err)
+	}
+	for i := 1; i <= 2000; i++ {
+		if infos[i-1].size == 0 {
+			continue
+		}
+		n := newEmptyNeedle(uint64(i))
+		size, err := v.readNeedle(n, nil, nil)
+		if err != nil {
+			t.Fatalf("read file %d: %v", i, err)
+		}
+		if infos[i-1].size != types.Size(size) || infos[i-1].crc != n.Checksum {
+			t.Fatalf("read file %d mismatch", i)
+		}
+	}
+
+	if err = v.Destroy(false); err != nil {
+		t.Fatalf("destroy volume: %v", err)
+	}
+	if all, free := device.Space(); all != free || len(device.FileNames()) != 0 {
+		t.Errorf("block device is not empty after the volume is destroyed: %v", device.FileNames())
+	}
+}
+
+func TestInPlaceCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+
+	infos := make([]*needleInfo, 6000)
+	for i := 1; i <= 4000; i++ {
+		doSomeWritesDeletes(i, v, t, infos)
+	}
+	// overwrite half of the files
+	for i := 1; i <= 4000; i += 2 {
+		doSomeWritesDeletes(i, v, t, infos)
+	}
+	datSize, _, _ := v.FileStat()
+	compactionRevision := v.SuperBlock.CompactionRevision
+
+	// read while compacting
+	stop := make(chan struct{})
+	readErrs := make(chan error, 1)
+	go func() {
+		defer close(readErrs)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			i := rand.Intn(4000) + 1
+			if infos[i-1].size == 0 {
+				continue
+			}
+			n := newEmptyNeedle(uint64(i))
+			if _, err := v.readNeedle(n, nil, nil); err != nil || n.Checksum != infos[i-1].crc {
+				readErrs <- fmt.Errorf("read file %d while compacting: %v", i, err)
+				return
+			}
+		}
+	}()
+	err = v.CompactInPlace(0, nil)
+	close(stop)
+	if readErr := <-readErrs; readErr != nil {
+		t.Fatal(readErr)
+	}
+	if err != nil {
+		t.Fatalf("compact in place: %v", err)
+	}
+	if _, err = os.Stat(v.FileName(".cpd")); !os.IsNotExist(err) {
+		t.Fatalf("compacting in place created %s", v.FileName(".cpd"))
+	}
+	if v.inPlaceCompactOffset >= int64(datSize) {
+		t.Errorf("volume size %d is not less than %d after compaction", v.inPlaceCompactOffset, datSize)
+	}
+	if v.SuperBlock.CompactionRevision != compactionRevision+1 {
+		t.Errorf("compaction revision %d, expected %d", v.SuperBlock.CompactionRevision, compactionRevision+1)
+	}
+
+	// update & delete original objects, upload & delete new objects
+	for i := 1; i <= 6000; i++ {
+		doSomeWritesDeletes(i, v, t, infos)
+	}
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit compaction: %v", err)
+	}
+	if v.DeletedSize() != 0 {
+		t.Errorf("%d deleted bytes after compaction", v.DeletedSize())
+	}
+	v.Close()
+
+	v, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume reloading: %v", err)
+	}
+	defer v.Close()
+	for i := 1; i <= 6000; i++ {
+		if infos[i-1].size == 0 {
+			continue
+		}
+		n := newEmptyNeedle(uint64(i))
+		size, err := v.readNeedle(n, nil, nil)
+		if err != nil {
+			t.Fatalf("read file %d: %v", i, err)
+		}
+		if infos[i-1].size != types.Size(size) || infos[i-1].crc != n.Checksum {
+			t.Fatalf("read file %d mismatch", i)
+		}
+	}
+}
+
+// syncCountingFile counts the fsyncs of the .dat file
+type syncCountingFile struct {
+	backend.BackendStorageFile
+	syncs int
+}
+
+func (f *syncCountingFile) Sync() error {
+	f.syncs++
+	return f.BackendStorageFile.Sync()
+}
+
+func TestInPlaceCompactionSyncs(t *testing.T) {
+	dir := t.TempDir()
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	defer v.Close()
+
+	// the needles of the same size slide over the space of the deleted ones before them
+	const needleCount, deletedCount = 40, 8
+	for i := 1; i <= needleCount; i++ {
+		n := newEmptyNeedle(uint64(i))
+		n.Data = make([]byte, 1000)
+		rand.Read(n.Data)
+		n.Checksum = needle.NewCRC(n.Data)
+		if _, _, _, err = v.writeNeedle2(n, true, false, 0); err != nil {
+			t.Fatalf("write file %d: %v", i, err)
+		}
+	}
+	for i := 1; i <= deletedCount; i++ {
+		if _, err = v.deleteNeedle2(newEmptyNeedle(uint64(i))); err != nil {
+			t.Fatalf("delete file %d: %v", i, err)
+		}
+	}
+
+	dataBackend := &syncCountingFile{BackendStorageFile: v.DataBackend}
+	v.DataBackend = dataBackend
+	if err = v.CompactInPlace(0, nil); err != nil {
+		t.Fatalf("compact in place: %v", err)
+	}
+	v.DataBackend = dataBackend.BackendStorageFile
+
+	// one sync before sliding, and one for each batch of needles fitting in the freed space
+	expected := 1 + (needleCount-deletedCount)/deletedCount
+	if dataBackend.syncs != expected {
+		t.Errorf("%d syncs to slide %d needles, expected %d", dataBackend.syncs, needleCount-deletedCount, expected)
+	}
+	for i := deletedCount + 1; i <= needleCount; i++ {
+		if _, err = v.readNeedle(newEmptyNeedle(uint64(i)), nil, nil); err != nil {
+			t.Fatalf("read file %d: %v", i, err)
+		}
+	}
+}
+
 func doSomeWritesDeletes(i int, v *Volume, t *testing.T, infos []*needleInfo) {
 	n := newRandomNeedle(uint64(i))
-	_, size, _, err := v.writeNeedle2(n, true, false)
+	_, size, _, err := v.writeNeedle2(n, true, false, 0)
 	if err != nil {
 		t.Fatalf("write file %d: %v", i, err)
 	}
@@ -188,3 +407,73 @@ func newEmptyNeedle(id uint64) *needle.Needle {
 	n.Id = types.Uint64ToNeedleId(id)
 	return n
 }
+
+func TestUpgradeCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	if v.Version() != needle.Version3 {
+		t.Fatalf("volume created in version %d", v.Version())
+	}
+
+	infos := make([]*needleInfo, 2100)
+	for i := 1; i <= 1000; i++ {
+		doSomeWritesDeletes(i, v, t, infos)
+	}
+	sha256Needle := newRandomNeedle(2101)
+	sha256Needle.ChecksumType = needle.ChecksumSha256
+	if _, _, _, err = v.writeNeedle2(sha256Needle, true, false, 0); err == nil {
+		t.Errorf("sha256 checksum is accepted by version 3")
+	}
+	if err = v.PersistUpgradeVersion(needle.Version2); err == nil {
+		t.Errorf("downgrade to version 2 is accepted")
+	}
+	if err = v.PersistUpgradeVersion(needle.Version4); err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	if !v.NeedsUpgrade() {
+		t.Fatalf("volume does not need upgrade")
+	}
+
+	if err = v.Compact2(0, 0, nil); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	// written in version 3, and converted when committing
+	for i := 1; i <= 2000; i++ {
+		doSomeWritesDeletes(i, v, t, infos)
+	}
+	if err = v.CommitCompact(); err != nil {
+		t.Fatalf("commit compaction: %v", err)
+	}
+	if v.Version() != needle.Version4 || v.NeedsUpgrade() {
+		t.Errorf("volume version %d after upgrade, needs upgrade %v", v.Version(), v.NeedsUpgrade())
+	}
+	for i := 2001; i <= 2100; i++ {
+		doSomeWritesDeletes(i, v, t, infos)
+	}
+	v.Close()
+
+	v, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume reloading: %v", err)
+	}
+	defer v.Close()
+	if v.Version() != needle.Version4 || v.NeedsUpgrade() {
+		t.Errorf("reloaded volume version %d, needs upgrade %v", v.Version(), v.NeedsUpgrade())
+	}
+	for i, info := range infos {
+		if info == nil || info.size == 0 {
+			continue
+		}
+		n := newEmptyNeedle(uint64(i + 1))
+		if _, err := v.readNeedle(n, nil, nil); err != nil {
+			t.Fatalf("read file %d: %v", i+1, err)
+		}
+		if n.Checksum != info.crc {
+			t.Fatalf("read file %d checksum mismatch", i+1)
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	. "github.com/seaweedfs/seaweedfs/weed/storage/types"
 	"os"
+	"sync/atomic"
+	"time"
 )
 
 var ErrorNotFound = errors.New("not found")
@@ -38,6 +40,9 @@ func (v *Volume) isFileUnchanged(n *needle.Needle) bool {
 	if ok && !nv.Offset.IsZero() && nv.Size.IsValid() {
 		oldNeedle := new(needle.Needle)
 		err := oldNeedle.ReadData(v.DataBackend, nv.Offset.ToActualOffset(), nv.Size, v.Version())
+		if err == nil {
+			err = v.decryptNeedle(oldNeedle)
+		}
 		if err != nil {
 			glog.V(0).Infof("Failed to check updated file at offset %d size %d: %v", nv.Offset.ToActualOffset(), nv.Size, err)
 			return false
@@ -87,13 +92,13 @@ func (v *Volume) Destroy(onlyEmpty bool) (err error) {
 
 func removeVolumeFiles(filename string) {
 	// basic
-	os.Remove(filename + ".dat")
+	backend.RemoveVolumeFile(filename + ".dat")
 	os.Remove(filename + ".idx")
 	os.Remove(filename + ".vif")
 	// sorted index file
 	os.Remove(filename + ".sdx")
 	// compaction
-	os.Remove(filename + ".cpd")
+	backend.RemoveVolumeFile(filename + ".cpd")
 	os.Remove(filename + ".cpx")
 	// level db index file
 	os.RemoveAll(filename + ".ldb")
@@ -113,19 +118,30 @@ func (v *Volume) syncWrite(n *needle.Needle, checkCookie bool) (offset uint64, s
 	return v.doWriteRequest(n, checkCookie)
 }
 
-func (v *Volume) writeNeedle2(n *needle.Needle, checkCookie bool, fsync bool) (offset uint64, size Size, isUnchanged bool, err error) {
+// writeNeedle2 fsyncs the write if fsync is set, or if the volume or the request asks for group commit.
+// The group commit delay is the shorter one of the volume's and the request's.
+func (v *Volume) writeNeedle2(n *needle.Needle, checkCookie bool, fsync bool, groupCommitDelay time.Duration) (offset uint64, size Size, isUnchanged bool, err error) {
 	// glog.V(4).Infof("writing needle %s", needle.NewFileIdFromNeedle(v.Id, n).String())
 	if n.Ttl == needle.EMPTY_TTL && v.Ttl != needle.EMPTY_TTL {
 		n.SetHasTtl()
 		n.Ttl = v.Ttl
 	}
+	if n.ChecksumType != needle.ChecksumCrc32c && v.Version() < needle.Version4 {
+		err = fmt.Errorf("volume %d of needle version %d only keeps crc32c checksums, not %s", v.Id, v.Version(), n.ChecksumType)
+		return
+	}
 
-	if !fsync {
+	if volumeDelay := v.GroupCommitDelay(); volumeDelay > 0 && (groupCommitDelay == 0 || volumeDelay < groupCommitDelay) {
+		groupCommitDelay = volumeDelay
+	}
+
+	if !fsync && groupCommitDelay == 0 {
 		return v.syncWrite(n, checkCookie)
 	} else {
 		asyncRequest := needle.NewAsyncRequest(n, true)
 		// using len(n.Data) here instead of n.Size before n.Size is populated in n.Append()
 		asyncRequest.ActualSize = needle.GetActualSize(Size(len(n.Data)), v.Version())
+		asyncRequest.GroupCommitDelay = groupCommitDelay
 
 		v.asyncRequestAppend(asyncRequest)
 		offset, _, isUnchanged, err = asyncRequest.WaitComplete()
@@ -150,12 +166,14 @@ func (v *Volume) doWriteRequest(n *needle.Needle, checkCookie bool) (offset uint
 			err = fmt.Errorf("reading existing needle: %v", existingNeedleReadErr)
 			return
 		}
-		if n.Cookie == 0 && !checkCookie {
+		// the space of a deleted needle may have been reused by an in place compaction
+		isReused := existingNeedle.Id != n.Id && nv.Size.IsDeleted()
+		if n.Cookie == 0 && !checkCookie && !isReused {
 			// this is from batch deletion, and read back again when tailing a remote volume
 			// which only happens when checkCookie == false and fsync == false
 			n.Cookie = existingNeedle.Cookie
 		}
-		if existingNeedle.Cookie != n.Cookie {
+		if existingNeedle.Cookie != n.Cookie && !isReused {
 			glog.V(0).Infof("write cookie mismatch: existing %s, new %s",
 				needle.NewFileIdFromNeedle(v.Id, existingNeedle), needle.NewFileIdFromNeedle(v.Id, n))
 			err = fmt.Errorf("mismatching cookie %x", n.Cookie)
@@ -165,7 +183,7 @@ func (v *Volume) doWriteRequest(n *needle.Needle, checkCookie bool) (offset uint
 
 	// append to dat file
 	n.UpdateAppendAtNs(v.lastAppendAtNs)
-	offset, size, _, err = n.Append(v.DataBackend, v.Version())
+	offset, size, _, err = v.appendNeedle(n)
 	v.checkReadWriteError(err)
 	if err != nil {
 		return
@@ -249,8 +267,24 @@ func (v *Volume) startWorker() {
 			}
 			currentRequests := make([]*needle.AsyncRequest, 0, 128)
 			currentBytesToWrite := int64(0)
+			var commitDeadline time.Time
 			for {
-				request, ok := <-v.asyncRequestsChan
+				var request *needle.AsyncRequest
+				ok := true
+				if len(currentRequests) == 0 {
+					request, ok = <-v.asyncRequestsChan
+				} else {
+					// group commit, wait for more requests until the earliest deadline of the current requests
+					timer := time.NewTimer(time.Until(commitDeadline))
+					select {
+					case request, ok = <-v.asyncRequestsChan:
+					case <-timer.C:
+					}
+					timer.Stop()
+					if ok && request == nil {
+						break
+					}
+				}
 				// volume may be closed
 				if !ok {
 					chanClosed = true
@@ -263,9 +297,12 @@ func (v *Volume) startWorker() {
 				}
 				currentRequests = append(currentRequests, request)
 				currentBytesToWrite += request.ActualSize
+				if deadline := time.Now().Add(request.GroupCommitDelay); len(currentRequests) == 1 || deadline.Before(commitDeadline) {
+					commitDeadline = deadline
+				}
 				// submit at most 4M bytes or 128 requests at one time to decrease request delay.
-				// it also need to break if there is no data in channel to avoid io hang.
-				if currentBytesToWrite >= 4*1024*1024 || len(currentRequests) >= 128 || len(v.asyncRequestsChan) == 0 {
+				// it also need to break if there is no data in channel and no group commit delay to avoid io hang.
+				if currentBytesToWrite >= 4*1024*1024 || len(currentRequests) >= 128 || len(v.asyncRequestsChan) == 0 && !time.Now().Before(commitDeadline) {
 					break
 				}
 			}
@@ -305,6 +342,7 @@ func (v *Volume) startWorker() {
 					}
 				}
 			}
+			atomic.AddUint64(&v.asyncBatchCount, 1)
 
 			for i := 0; i < len(currentRequests); i++ {
 				currentRequests[i].Submit()
@@ -319,6 +357,11 @@ func (v *Volume) WriteNeedleBlob(needleId NeedleId, needleBlob []byte, size Size
 	v.dataFileAccessLock.Lock()
 	defer v.dataFileAccessLock.Unlock()
 
+	return v.doWriteNeedleBlob(needleId, needleBlob, size)
+}
+
+func (v *Volume) doWriteNeedleBlob(needleId NeedleId, needleBlob []byte, size Size) error {
+
 	if MaxPossibleVolumeSize < v.nm.ContentSize()+uint64(len(needleBlob)) {
 		return fmt.Errorf("volume size limit %d exceeded! current size is %d", MaxPossibleVolumeSize, v.nm.ContentSize())
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -25,7 +26,7 @@ func TestSearchVolumesWithDeletedNeedles(t *testing.T) {
 
 	for i := 1; i < count; i++ {
 		n := newRandomNeedle(uint64(i))
-		_, _, _, err := v.writeNeedle2(n, true, false)
+		_, _, _, err := v.writeNeedle2(n, true, false, 0)
 		if err != nil {
 			t.Fatalf("write needle %d: %v", i, err)
 		}
@@ -121,7 +122,7 @@ func TestDestroyNonemptyVolumeWithOnlyEmpty(t *testing.T) {
 	path := v.DataBackend.Name()
 
 	// should return "volume not empty" error and do not delete file when Destroy non-empty volume
-	_, _, _, err = v.writeNeedle2(newRandomNeedle(1), true, false)
+	_, _, _, err = v.writeNeedle2(newRandomNeedle(1), true, false, 0)
 	if err != nil {
 		t.Fatalf("write needle: %v", err)
 	}
@@ -133,7 +134,7 @@ func TestDestroyNonemptyVolumeWithOnlyEmpty(t *testing.T) {
 	assertFileExist(t, true, path)
 
 	// should keep working after "volume not empty"
-	_, _, _, err = v.writeNeedle2(newRandomNeedle(2), true, false)
+	_, _, _, err = v.writeNeedle2(newRandomNeedle(2), true, false, 0)
 	if err != nil {
 		t.Fatalf("write needle: %v", err)
 	}
@@ -151,7 +152,7 @@ func TestDestroyNonemptyVolumeWithoutOnlyEmpty(t *testing.T) {
 	path := v.DataBackend.Name()
 
 	// should can Destroy non-empty volume without onlyEmpty
-	_, _, _, err = v.writeNeedle2(newRandomNeedle(1), true, false)
+	_, _, _, err = v.writeNeedle2(newRandomNeedle(1), true, false, 0)
 	if err != nil {
 		t.Fatalf("write needle: %v", err)
 	}
@@ -164,3 +165,58 @@ func TestDestroyNonemptyVolumeWithoutOnlyEmpty(t *testing.T) {
 	}
 	assertFileExist(t, false, path)
 }
+
+// newGroupCommitNeedle is never empty, since an empty needle is not found when read back
+func newGroupCommitNeedle(id uint64) *needle.Needle {
+	n := newRandomNeedle(id)
+	n.Data = append(n.Data, byte(id))
+	n.Checksum = needle.NewCRC(n.Data)
+	return n
+}
+
+func TestGroupCommit(t *testing.T) {
+	dir := t.TempDir()
+
+	v, err := NewVolume(dir, dir, "", 1, NeedleMapInMemory, &super_block.ReplicaPlacement{}, &needle.TTL{}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume creation: %v", err)
+	}
+	if err = v.PersistGroupCommitDelay(time.Minute); err != nil {
+		t.Fatalf("configure group commit: %v", err)
+	}
+
+	// the request can ask for a shorter delay, so each of the sequential writes is fsynced alone
+	for i := 1; i <= 3; i++ {
+		if _, _, _, err = v.writeNeedle2(newGroupCommitNeedle(uint64(i)), true, false, time.Millisecond); err != nil {
+			t.Fatalf("write needle %d: %v", i, err)
+		}
+	}
+	assert.Equal(t, uint64(3), atomic.LoadUint64(&v.asyncBatchCount))
+
+	// the concurrent writes wait for each other, and share one fsync when the batch is full
+	errs := make(chan error, 128)
+	for i := 4; i < 4+128; i++ {
+		go func(id uint64) {
+			_, _, _, err := v.writeNeedle2(newGroupCommitNeedle(id), true, false, 0)
+			errs <- err
+		}(uint64(i))
+	}
+	for i := 0; i < 128; i++ {
+		assert.NoError(t, <-errs)
+	}
+	assert.Equal(t, uint64(4), atomic.LoadUint64(&v.asyncBatchCount))
+	assert.Equal(t, uint64(3+128), v.FileCount())
+
+	v.Close()
+	v, err = NewVolume(dir, dir, "", 1, NeedleMapInMemory, nil, nil, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("volume reloading: %v", err)
+	}
+	defer v.Close()
+	assert.Equal(t, time.Minute, v.GroupCommitDelay())
+	for i := 1; i < 4+128; i++ {
+		if _, err = v.readNeedle(newEmptyNeedle(uint64(i)), nil, nil); err != nil {
+			t.Fatalf("read needle %d: %v", i, err)
+		}
+	}
+}
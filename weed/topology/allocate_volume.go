@@ -5,6 +5,7 @@ import (
 
 	"github.com/seaweedfs/seaweedfs/weed/operation"
 	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
 	"google.golang.org/grpc"
 )
@@ -13,11 +14,13 @@ type AllocateVolumeResult struct {
 	Error string
 }
 
-func AllocateVolume(dn *DataNode, grpcDialOption grpc.DialOption, vid needle.VolumeId, option *VolumeGrowOption) error {
+// AllocateVolume creates the volume on the data node. With encryption at rest, the volume server returns the
+// wrapped data keys of the volume, and the dataKeys from an earlier replica are passed so all replicas share them.
+func AllocateVolume(dn *DataNode, grpcDialOption grpc.DialOption, vid needle.VolumeId, option *VolumeGrowOption, dataKeys []*volume_server_pb.VolumeDataKey) (volumeDataKeys []*volume_server_pb.VolumeDataKey, err error) {
 
-	return operation.WithVolumeServerClient(false, dn.ServerAddress(), grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+	err = operation.WithVolumeServerClient(false, dn.ServerAddress(), grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
 
-		_, allocateErr := client.AllocateVolume(context.Background(), &volume_server_pb.AllocateVolumeRequest{
+		resp, allocateErr := client.AllocateVolume(context.Background(), &volume_server_pb.AllocateVolumeRequest{
 			VolumeId:           uint32(vid),
 			Collection:         option.Collection,
 			Replication:        option.ReplicaPlacement.String(),
@@ -25,9 +28,14 @@ func AllocateVolume(dn *DataNode, grpcDialOption grpc.DialOption, vid needle.Vol
 			Preallocate:        option.Preallocate,
 			MemoryMapMaxSizeMb: option.MemoryMapMaxSizeMb,
 			DiskType:           string(option.DiskType),
+			DataKeys:           dataKeys,
 		})
+		if allocateErr == nil {
+			volumeDataKeys = resp.DataKeys
+		}
 		return allocateErr
 	})
+	return
 
 }
 
@@ -42,3 +50,41 @@ func DeleteVolume(dn *DataNode, grpcDialOption grpc.DialOption, vid needle.Volum
 	})
 
 }
+
+// AllocateEcShards creates the empty shards of an ec volume on the data node, to be written directly by the clients
+func AllocateEcShards(dn *DataNode, grpcDialOption grpc.DialOption, vid needle.VolumeId, option *VolumeGrowOption, scheme erasure_coding.EcScheme, shardIds []uint32) error {
+
+	return operation.WithVolumeServerClient(false, dn.ServerAddress(), grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+
+		_, allocateErr := client.VolumeEcShardsAllocate(context.Background(), &volume_server_pb.VolumeEcShardsAllocateRequest{
+			VolumeId:     uint32(vid),
+			Collection:   option.Collection,
+			ShardIds:     shardIds,
+			DataShards:   uint32(scheme.DataShards),
+			ParityShards: uint32(scheme.ParityShards),
+			DiskType:     string(option.DiskType),
+		})
+		return allocateErr
+	})
+
+}
+
+func DeleteEcShards(dn *DataNode, grpcDialOption grpc.DialOption, vid needle.VolumeId, collection string, shardIds []uint32) error {
+
+	return operation.WithVolumeServerClient(false, dn.ServerAddress(), grpcDialOption, func(client volume_server_pb.VolumeServerClient) error {
+
+		if _, unmountErr := client.VolumeEcShardsUnmount(context.Background(), &volume_server_pb.VolumeEcShardsUnmountRequest{
+			VolumeId: uint32(vid),
+			ShardIds: shardIds,
+		}); unmountErr != nil {
+			return unmountErr
+		}
+		_, deleteErr := client.VolumeEcShardsDelete(context.Background(), &volume_server_pb.VolumeEcShardsDeleteRequest{
+			VolumeId:   uint32(vid),
+			Collection: collection,
+			ShardIds:   shardIds,
+		})
+		return deleteErr
+	})
+
+}
@@ -26,8 +26,8 @@ func (dn *DataNode) UpdateEcShards(actualShards []*erasure_coding.EcVolumeInfo)
 	existingEcShards := dn.GetEcShards()
 
 	// find out the newShards and deletedShards
-	var newShardCount, deletedShardCount int
 	for _, ecShards := range existingEcShards {
+		var newShardCount, deletedShardCount int
 
 		disk := dn.getOrCreateDisk(ecShards.DiskType)
 		deltaDiskUsages := newDiskUsages()
@@ -53,6 +53,7 @@ func (dn *DataNode) UpdateEcShards(actualShards []*erasure_coding.EcVolumeInfo)
 		}
 
 		deltaDiskUsage.ecShardCount = int64(newShardCount - deletedShardCount)
+		deltaDiskUsage.ecShardSlotUnits = ecShards.Scheme.ShardSlotUnits(newShardCount - deletedShardCount)
 		disk.UpAdjustDiskUsageDelta(deltaDiskUsages)
 
 	}
@@ -68,6 +69,7 @@ func (dn *DataNode) UpdateEcShards(actualShards []*erasure_coding.EcVolumeInfo)
 		deltaDiskUsages := newDiskUsages()
 		deltaDiskUsage := deltaDiskUsages.getOrCreateDisk(types.ToDiskType(ecShards.DiskType))
 		deltaDiskUsage.ecShardCount = int64(ecShards.ShardIdCount())
+		deltaDiskUsage.ecShardSlotUnits = ecShards.Scheme.ShardSlotUnits(ecShards.ShardIdCount())
 		disk.UpAdjustDiskUsageDelta(deltaDiskUsages)
 	}
 
@@ -20,6 +20,7 @@ func (d *Disk) AddOrUpdateEcShard(s *erasure_coding.EcVolumeInfo) {
 	defer d.ecShardsLock.Unlock()
 
 	delta := 0
+	scheme := s.Scheme
 	if existing, ok := d.ecShards[s.VolumeId]; !ok {
 		d.ecShards[s.VolumeId] = s
 		delta = s.ShardBits.ShardIdCount()
@@ -27,11 +28,13 @@ func (d *Disk) AddOrUpdateEcShard(s *erasure_coding.EcVolumeInfo) {
 		oldCount := existing.ShardBits.ShardIdCount()
 		existing.ShardBits = existing.ShardBits.Plus(s.ShardBits)
 		delta = existing.ShardBits.ShardIdCount() - oldCount
+		scheme = existing.Scheme
 	}
 
 	deltaDiskUsages := newDiskUsages()
 	deltaDiskUsage := deltaDiskUsages.getOrCreateDisk(types.ToDiskType(string(d.Id())))
 	deltaDiskUsage.ecShardCount = int64(delta)
+	deltaDiskUsage.ecShardSlotUnits = scheme.ShardSlotUnits(delta)
 	d.UpAdjustDiskUsageDelta(deltaDiskUsages)
 
 }
@@ -48,6 +51,7 @@ func (d *Disk) DeleteEcShard(s *erasure_coding.EcVolumeInfo) {
 		deltaDiskUsages := newDiskUsages()
 		deltaDiskUsage := deltaDiskUsages.getOrCreateDisk(types.ToDiskType(string(d.Id())))
 		deltaDiskUsage.ecShardCount = int64(delta)
+		deltaDiskUsage.ecShardSlotUnits = existing.Scheme.ShardSlotUnits(delta)
 		d.UpAdjustDiskUsageDelta(deltaDiskUsages)
 
 		if existing.ShardBits.ShardIdCount() == 0 {
@@ -158,9 +158,8 @@ func (n *NodeImpl) getOrCreateDisk(diskType types.DiskType) *DiskUsageCounts {
 func (n *NodeImpl) AvailableSpaceFor(option *VolumeGrowOption) int64 {
 	t := n.getOrCreateDisk(option.DiskType)
 	freeVolumeSlotCount := atomic.LoadInt64(&t.maxVolumeCount) + atomic.LoadInt64(&t.remoteVolumeCount) - atomic.LoadInt64(&t.volumeCount)
-	ecShardCount := atomic.LoadInt64(&t.ecShardCount)
-	if ecShardCount > 0 {
-		freeVolumeSlotCount = freeVolumeSlotCount - ecShardCount/erasure_coding.DataShardsCount - 1
+	if atomic.LoadInt64(&t.ecShardCount) > 0 {
+		freeVolumeSlotCount = freeVolumeSlotCount - atomic.LoadInt64(&t.ecShardSlotUnits)/erasure_coding.VolumeSlotUnits - 1
 	}
 	return freeVolumeSlotCount
 }
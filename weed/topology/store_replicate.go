@@ -44,10 +44,14 @@ func ReplicatedWrite(masterFn operation.GetMasterFn, grpcDialOption grpc.DialOpt
 	if r.FormValue("fsync") == "true" {
 		fsync = true
 	}
+	var groupCommitDelay time.Duration
+	if groupCommitMs, parseErr := strconv.ParseUint(r.FormValue("groupCommitMs"), 10, 32); parseErr == nil {
+		groupCommitDelay = time.Duration(groupCommitMs) * time.Millisecond
+	}
 
 	if s.GetVolume(volumeId) != nil {
 		start := time.Now()
-		isUnchanged, err = s.WriteVolumeNeedle(volumeId, n, true, fsync)
+		isUnchanged, err = s.WriteVolumeNeedle(volumeId, n, true, fsync, groupCommitDelay)
 		stats.VolumeServerRequestHistogram.WithLabelValues(stats.WriteToLocalDisk).Observe(time.Since(start).Seconds())
 		if err != nil {
 			stats.VolumeServerHandlerCounter.WithLabelValues(stats.ErrorWriteToLocalDisk).Inc()
@@ -75,6 +79,9 @@ func ReplicatedWrite(masterFn operation.GetMasterFn, grpcDialOption grpc.DialOpt
 			if n.IsChunkedManifest() {
 				q.Set("cm", "true")
 			}
+			if groupCommitDelay > 0 {
+				q.Set("groupCommitMs", r.FormValue("groupCommitMs"))
+			}
 			u.RawQuery = q.Encode()
 
 			pairMap := make(map[string]string)
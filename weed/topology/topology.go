@@ -34,6 +34,10 @@ type Topology struct {
 	ecShardMap     map[needle.VolumeId]*EcShardLocations
 	ecShardMapLock sync.RWMutex
 
+	ecWriteLayouts     map[string]*EcWriteLayout
+	ecWrittenVolumes   map[needle.VolumeId]*ecWrittenVolume
+	ecWriteLayoutsLock sync.Mutex
+
 	pulse int64
 
 	volumeSizeLimit  uint64
@@ -66,6 +70,8 @@ func NewTopology(id string, seq sequence.Sequencer, volumeSizeLimit uint64, puls
 	t.children = make(map[NodeId]Node)
 	t.collectionMap = util.NewConcurrentReadMap()
 	t.ecShardMap = make(map[needle.VolumeId]*EcShardLocations)
+	t.ecWriteLayouts = make(map[string]*EcWriteLayout)
+	t.ecWrittenVolumes = make(map[needle.VolumeId]*ecWrittenVolume)
 	t.pulse = int64(pulse)
 	t.volumeSizeLimit = volumeSizeLimit
 	t.replicationAsMin = replicationAsMin
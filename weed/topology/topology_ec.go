@@ -10,7 +10,8 @@ import (
 
 type EcShardLocations struct {
 	Collection string
-	Locations  [erasure_coding.TotalShardsCount][]*DataNode
+	Scheme     erasure_coding.EcScheme
+	Locations  [erasure_coding.MaxShardCount][]*DataNode
 }
 
 func (t *Topology) SyncDataNodeEcShards(shardInfos []*master_pb.VolumeEcShardInformationMessage, dn *DataNode) (newShards, deletedShards []*erasure_coding.EcVolumeInfo) {
@@ -23,6 +24,7 @@ func (t *Topology) SyncDataNodeEcShards(shardInfos []*master_pb.VolumeEcShardInf
 				shardInfo.Collection,
 				needle.VolumeId(shardInfo.Id),
 				erasure_coding.ShardBits(shardInfo.EcIndexBits),
+				erasure_coding.NewEcScheme(shardInfo.DataShards, shardInfo.ParityShards),
 				shardInfo.DestroyTime))
 	}
 	// find out the delta volumes
@@ -33,6 +35,7 @@ func (t *Topology) SyncDataNodeEcShards(shardInfos []*master_pb.VolumeEcShardInf
 	for _, v := range deletedShards {
 		t.UnRegisterEcShards(v, dn)
 	}
+	t.observeEcWrites(shardInfos)
 	return
 }
 
@@ -45,7 +48,9 @@ func (t *Topology) IncrementalSyncDataNodeEcShards(newEcShards, deletedEcShards
 				shardInfo.DiskType,
 				shardInfo.Collection,
 				needle.VolumeId(shardInfo.Id),
-				erasure_coding.ShardBits(shardInfo.EcIndexBits), shardInfo.DestroyTime))
+				erasure_coding.ShardBits(shardInfo.EcIndexBits),
+				erasure_coding.NewEcScheme(shardInfo.DataShards, shardInfo.ParityShards),
+				shardInfo.DestroyTime))
 	}
 	for _, shardInfo := range deletedEcShards {
 		deletedShards = append(deletedShards,
@@ -53,7 +58,9 @@ func (t *Topology) IncrementalSyncDataNodeEcShards(newEcShards, deletedEcShards
 				shardInfo.DiskType,
 				shardInfo.Collection,
 				needle.VolumeId(shardInfo.Id),
-				erasure_coding.ShardBits(shardInfo.EcIndexBits), shardInfo.DestroyTime))
+				erasure_coding.ShardBits(shardInfo.EcIndexBits),
+				erasure_coding.NewEcScheme(shardInfo.DataShards, shardInfo.ParityShards),
+				shardInfo.DestroyTime))
 	}
 
 	dn.DeltaUpdateEcShards(newShards, deletedShards)
@@ -67,12 +74,18 @@ func (t *Topology) IncrementalSyncDataNodeEcShards(newEcShards, deletedEcShards
 	return
 }
 
-func NewEcShardLocations(collection string) *EcShardLocations {
+func NewEcShardLocations(collection string, scheme erasure_coding.EcScheme) *EcShardLocations {
 	return &EcShardLocations{
 		Collection: collection,
+		Scheme:     scheme,
 	}
 }
 
+// ShardLocations has the locations of each shard of the ec volume scheme
+func (loc *EcShardLocations) ShardLocations() [][]*DataNode {
+	return loc.Locations[:loc.Scheme.TotalShards()]
+}
+
 func (loc *EcShardLocations) AddShard(shardId erasure_coding.ShardId, dn *DataNode) (added bool) {
 	dataNodes := loc.Locations[shardId]
 	for _, n := range dataNodes {
@@ -106,9 +119,10 @@ func (t *Topology) RegisterEcShards(ecShardInfos *erasure_coding.EcVolumeInfo, d
 
 	locations, found := t.ecShardMap[ecShardInfos.VolumeId]
 	if !found {
-		locations = NewEcShardLocations(ecShardInfos.Collection)
+		locations = NewEcShardLocations(ecShardInfos.Collection, ecShardInfos.Scheme)
 		t.ecShardMap[ecShardInfos.VolumeId] = locations
 	}
+	locations.Scheme = ecShardInfos.Scheme
 	for _, shardId := range ecShardInfos.ShardIds() {
 		locations.AddShard(shardId, dn)
 	}
@@ -0,0 +1,319 @@
+package topology
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"google.golang.org/grpc"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+// EcWriteLayout has the ec volumes written directly by the clients, for one collection, disk type and ec scheme.
+// Each needle reserves whole rows of an ec volume, so the clients compute the parity of their own rows.
+// The reservations are only kept in memory, and are dropped when the leader changes. The ec volumes written before
+// are adopted again from the shard sizes in the heartbeats, see adoptEcWritableVolume.
+// The rows of a failed write are never reserved again, since a late write could still land in them, and the trailing
+// zeros of the rows are not written. They are reclaimed by ec.decode and vacuum, like the deleted needles.
+type EcWriteLayout struct {
+	writables         []*ecWritableVolume
+	accessLock        sync.Mutex
+	lastAllocateError error // returned until ecAllocateRetryInterval passes, e.g. when the volume servers refuse the allocation
+	lastAllocateTime  time.Time
+}
+
+const (
+	ecAllocateRetryInterval = 30 * time.Second
+	// the full ec shard heartbeats are sent every 17 pulses, so a write landing after the leader changed is reported by then
+	ecWriteSettleInterval = 5 * time.Minute
+)
+
+type ecWritableVolume struct {
+	vid        needle.VolumeId
+	nextOffset int64       // the next free row in the .dat layout
+	locations  []*DataNode // the allocated location of each shard
+}
+
+// ecWrittenVolume is an ec volume written directly, with the rows written so far as reported by the heartbeats
+type ecWrittenVolume struct {
+	collection string
+	diskType   types.DiskType
+	scheme     erasure_coding.EcScheme
+	highWater  int64     // the .dat size of the rows written, from the largest shard
+	changedAt  time.Time // when the high-water mark last grew
+	claimed    bool      // reserved by the layouts since the leader changed, so it is not adopted
+}
+
+func (t *Topology) getEcWriteLayout(option *VolumeGrowOption, scheme erasure_coding.EcScheme) *EcWriteLayout {
+	key := fmt.Sprintf("%s:%s:%s:%s", option.Collection, option.DiskType, option.DataCenter, scheme)
+
+	t.ecWriteLayoutsLock.Lock()
+	defer t.ecWriteLayoutsLock.Unlock()
+
+	layout, found := t.ecWriteLayouts[key]
+	if !found {
+		layout = &EcWriteLayout{}
+		t.ecWriteLayouts[key] = layout
+	}
+	return layout
+}
+
+// PickForEcWrite reserves the rows for a needle of the size in an ec volume written directly, and assigns its file id.
+// An ec volume is allocated when none has enough rows left.
+func (t *Topology) PickForEcWrite(grpcDialOption grpc.DialOption, option *VolumeGrowOption, scheme erasure_coding.EcScheme, size int64) (fileId string, offset int64, shardLocations [][]*DataNode, err error) {
+	rowsSize := scheme.RowsSize(size)
+	// the first row has the super block
+	if rowsSize+scheme.RowSize() > scheme.DirectWriteLimit() {
+		return "", 0, nil, fmt.Errorf("size %d is over the limit %d of an ec volume %s", size, scheme.DirectWriteLimit()-scheme.RowSize(), scheme)
+	}
+
+	layout := t.getEcWriteLayout(option, scheme)
+	layout.accessLock.Lock()
+	defer layout.accessLock.Unlock()
+
+	var writable *ecWritableVolume
+	for _, w := range layout.writables {
+		// every shard is written, so the volumes with a shard server down are skipped until it is back
+		if w.nextOffset+rowsSize <= scheme.DirectWriteLimit() && t.ecWriteShardLocations(w, scheme) != nil {
+			writable = w
+			break
+		}
+	}
+	if writable == nil {
+		writable = t.adoptEcWritableVolume(option, scheme)
+	}
+	if writable == nil {
+		if layout.lastAllocateError != nil && time.Since(layout.lastAllocateTime) < ecAllocateRetryInterval {
+			return "", 0, nil, layout.lastAllocateError
+		}
+		writable, err = t.allocateEcWritableVolume(grpcDialOption, option, scheme)
+		layout.lastAllocateError, layout.lastAllocateTime = err, time.Now()
+		if err != nil {
+			return "", 0, nil, err
+		}
+		t.claimEcWrittenVolume(writable.vid, option, scheme)
+	}
+	if !slices.Contains(layout.writables, writable) {
+		layout.writables = append(layout.writables, writable)
+	}
+
+	offset = writable.nextOffset
+	writable.nextOffset += rowsSize
+	if writable.nextOffset+scheme.RowSize() > scheme.DirectWriteLimit() {
+		layout.writables = slices.DeleteFunc(layout.writables, func(w *ecWritableVolume) bool {
+			return w == writable
+		})
+		glog.V(0).Infof("ec volume %d written directly is full", writable.vid)
+	}
+
+	fileId = needle.NewFileId(writable.vid, t.Sequence.NextFileId(1), rand.Uint32()).String()
+	return fileId, offset, t.ecWriteShardLocations(writable, scheme), nil
+}
+
+// ecWriteShardLocations prefers the shard locations reported by the heartbeats, in case the shards are moved.
+// It is nil when a reported shard has no location left, e.g. its server is down.
+func (t *Topology) ecWriteShardLocations(writable *ecWritableVolume, scheme erasure_coding.EcScheme) (shardLocations [][]*DataNode) {
+	for _, dn := range writable.locations {
+		shardLocations = append(shardLocations, []*DataNode{dn})
+	}
+	if locations, found := t.LookupEcShards(writable.vid); found && locations.Scheme == scheme {
+		for shardId, dataNodes := range locations.ShardLocations() {
+			if len(dataNodes) == 0 {
+				return nil
+			}
+			shardLocations[shardId] = dataNodes
+		}
+	}
+	return
+}
+
+// ResetEcWriteLayouts drops the reservations when the leader changes, since another leader may have reserved
+// more rows of the same ec volumes in the meantime
+func (t *Topology) ResetEcWriteLayouts() {
+	t.ecWriteLayoutsLock.Lock()
+	defer t.ecWriteLayoutsLock.Unlock()
+
+	t.ecWriteLayouts = make(map[string]*EcWriteLayout)
+	t.ecWrittenVolumes = make(map[needle.VolumeId]*ecWrittenVolume)
+}
+
+// observeEcWrites raises the high-water marks of the ec volumes written directly, from the shard sizes in a full heartbeat
+func (t *Topology) observeEcWrites(shardInfos []*master_pb.VolumeEcShardInformationMessage) {
+	t.ecWriteLayoutsLock.Lock()
+	defer t.ecWriteLayoutsLock.Unlock()
+
+	for _, shardInfo := range shardInfos {
+		if !shardInfo.IsDirectWrite {
+			continue
+		}
+		scheme := erasure_coding.NewEcScheme(shardInfo.DataShards, shardInfo.ParityShards)
+		var highWater int64
+		for _, shardSize := range shardInfo.ShardSizes {
+			highWater = max(highWater, shardSize*int64(scheme.DataShards))
+		}
+		written, found := t.ecWrittenVolumes[needle.VolumeId(shardInfo.Id)]
+		if !found {
+			written = &ecWrittenVolume{
+				collection: shardInfo.Collection,
+				diskType:   types.ToDiskType(shardInfo.DiskType),
+				scheme:     scheme,
+				changedAt:  time.Now(),
+			}
+			t.ecWrittenVolumes[needle.VolumeId(shardInfo.Id)] = written
+		}
+		if highWater > written.highWater {
+			written.highWater, written.changedAt = highWater, time.Now()
+		}
+	}
+}
+
+func (t *Topology) claimEcWrittenVolume(vid needle.VolumeId, option *VolumeGrowOption, scheme erasure_coding.EcScheme) {
+	t.ecWriteLayoutsLock.Lock()
+	defer t.ecWriteLayoutsLock.Unlock()
+
+	t.ecWrittenVolumes[vid] = &ecWrittenVolume{
+		collection: option.Collection,
+		diskType:   option.DiskType,
+		scheme:     scheme,
+		highWater:  scheme.RowSize(),
+		changedAt:  time.Now(),
+		claimed:    true,
+	}
+}
+
+// adoptEcWritableVolume continues an ec volume written directly before the leader changed or the master restarted.
+// The rows after the largest shard are free once the shard sizes stop growing, since the rows reserved by the earlier
+// leader are written or failed by then. A client holding a reservation for longer could still overwrite the rows.
+func (t *Topology) adoptEcWritableVolume(option *VolumeGrowOption, scheme erasure_coding.EcScheme) *ecWritableVolume {
+	settleInterval := max(ecWriteSettleInterval, 2*17*time.Duration(t.pulse)*time.Second)
+
+	t.ecWriteLayoutsLock.Lock()
+	defer t.ecWriteLayoutsLock.Unlock()
+
+	for vid, written := range t.ecWrittenVolumes {
+		if written.claimed || written.collection != option.Collection || written.diskType != option.DiskType || written.scheme != scheme {
+			continue
+		}
+		if time.Since(written.changedAt) < settleInterval || written.highWater+scheme.RowSize() > scheme.DirectWriteLimit() {
+			continue
+		}
+		locations, found := t.LookupEcShards(vid)
+		if !found {
+			delete(t.ecWrittenVolumes, vid)
+			continue
+		}
+		var servers []*DataNode
+		for _, dataNodes := range locations.ShardLocations() {
+			if len(dataNodes) == 0 || option.DataCenter != "" && dataNodes[0].GetDataCenterId() != option.DataCenter {
+				break
+			}
+			servers = append(servers, dataNodes[0])
+		}
+		if len(servers) < scheme.TotalShards() {
+			continue
+		}
+		written.claimed = true
+		glog.V(0).Infof("adopt ec volume %d written directly, from offset %d", vid, written.highWater)
+		return &ecWritableVolume{
+			vid:        vid,
+			nextOffset: written.highWater,
+			locations:  servers,
+		}
+	}
+	return nil
+}
+
+func (t *Topology) allocateEcWritableVolume(grpcDialOption grpc.DialOption, option *VolumeGrowOption, scheme erasure_coding.EcScheme) (*ecWritableVolume, error) {
+	servers, err := t.pickEcShardServers(option, scheme)
+	if err != nil {
+		return nil, err
+	}
+	vid, err := t.NextVolumeId()
+	if err != nil {
+		return nil, err
+	}
+
+	var dataNodes []*DataNode
+	shardIds := make(map[*DataNode][]uint32)
+	for shardId, dn := range servers {
+		if _, found := shardIds[dn]; !found {
+			dataNodes = append(dataNodes, dn)
+		}
+		shardIds[dn] = append(shardIds[dn], uint32(shardId))
+	}
+
+	for i, dn := range dataNodes {
+		if allocateErr := AllocateEcShards(dn, grpcDialOption, vid, option, scheme, shardIds[dn]); allocateErr != nil {
+			glog.Warningf("Failed to allocate ec volume %d on %s: %v", vid, dn.NodeImpl.String(), allocateErr)
+			// cleaning up the allocated ec shards
+			for _, allocated := range dataNodes[:i] {
+				if deleteErr := DeleteEcShards(allocated, grpcDialOption, vid, option.Collection, shardIds[allocated]); deleteErr != nil {
+					glog.Warningf("Failed to clean up ec volume %d on %s: %v", vid, allocated.NodeImpl.String(), deleteErr)
+				}
+			}
+			return nil, fmt.Errorf("failed to allocate ec volume %d on %s: %v", vid, dn.NodeImpl.String(), allocateErr)
+		}
+		glog.V(0).Infof("Created ec volume %d shards %v on %s", vid, shardIds[dn], dn.NodeImpl.String())
+	}
+
+	return &ecWritableVolume{
+		vid:        vid,
+		nextOffset: scheme.RowSize(),
+		locations:  servers,
+	}, nil
+}
+
+// pickEcShardServers spreads the shards over the racks, and over the data nodes with the most free slots in each rack.
+// Like ec.encode, no data node gets more shards than the parity shards, so losing one data node loses no data.
+func (t *Topology) pickEcShardServers(option *VolumeGrowOption, scheme erasure_coding.EcScheme) (servers []*DataNode, err error) {
+	var racks [][]*DataNode
+	var dataNodeCount int
+	for _, dc := range t.Children() {
+		if option.DataCenter != "" && dc.Id() != NodeId(option.DataCenter) {
+			continue
+		}
+		for _, rack := range dc.Children() {
+			var dataNodes []*DataNode
+			for _, n := range rack.Children() {
+				if n.AvailableSpaceFor(option) >= 1 {
+					dataNodes = append(dataNodes, n.(*DataNode))
+				}
+			}
+			if len(dataNodes) == 0 {
+				continue
+			}
+			slices.SortFunc(dataNodes, func(a, b *DataNode) int {
+				return int(b.AvailableSpaceFor(option) - a.AvailableSpaceFor(option))
+			})
+			racks = append(racks, dataNodes)
+			dataNodeCount += len(dataNodes)
+		}
+	}
+	if minDataNodeCount := (scheme.TotalShards() + scheme.ParityShards - 1) / scheme.ParityShards; dataNodeCount < minDataNodeCount {
+		return nil, fmt.Errorf("%d free data nodes for the ec shards of %s, less than %d for ec scheme %s", dataNodeCount, option.String(), minDataNodeCount, scheme)
+	}
+	slices.SortFunc(racks, func(a, b []*DataNode) int {
+		return len(b) - len(a)
+	})
+
+	// take one data node from each rack in turn
+	var dataNodes []*DataNode
+	for i := 0; len(dataNodes) < dataNodeCount; i++ {
+		for _, rack := range racks {
+			if i < len(rack) {
+				dataNodes = append(dataNodes, rack[i])
+			}
+		}
+	}
+	for shardId := 0; shardId < scheme.TotalShards(); shardId++ {
+		servers = append(servers, dataNodes[shardId%len(dataNodes)])
+	}
+	return servers, nil
+}
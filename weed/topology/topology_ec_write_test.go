@@ -0,0 +1,96 @@
+package topology
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/sequence"
+	"github.com/seaweedfs/seaweedfs/weed/storage/erasure_coding"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
+)
+
+func TestAdoptEcWritableVolume(t *testing.T) {
+	topo := NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	rack := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1")
+	maxVolumeCounts := map[string]uint32{"": 25}
+	dn1 := rack.GetOrCreateDataNode("127.0.0.1", 34534, 0, "127.0.0.1", maxVolumeCounts)
+	dn2 := rack.GetOrCreateDataNode("127.0.0.1", 34535, 0, "127.0.0.1", maxVolumeCounts)
+
+	scheme := erasure_coding.DefaultEcScheme
+	option := &VolumeGrowOption{Collection: "c"}
+	heartbeat := func(dn *DataNode, firstShardId, lastShardId int, rows int64) {
+		m := &master_pb.VolumeEcShardInformationMessage{Id: 7, Collection: "c", IsDirectWrite: true}
+		for shardId := firstShardId; shardId <= lastShardId; shardId++ {
+			m.EcIndexBits = uint32(erasure_coding.ShardBits(m.EcIndexBits).AddShardId(erasure_coding.ShardId(shardId)))
+			m.ShardSizes = append(m.ShardSizes, rows*erasure_coding.ErasureCodingSmallBlockSize)
+		}
+		topo.SyncDataNodeEcShards([]*master_pb.VolumeEcShardInformationMessage{m}, dn)
+	}
+	settle := func() {
+		topo.ecWrittenVolumes[7].changedAt = time.Now().Add(-time.Hour)
+	}
+
+	heartbeat(dn1, 0, 6, 3)
+	heartbeat(dn2, 7, 13, 5)
+	if w := topo.adoptEcWritableVolume(option, scheme); w != nil {
+		t.Fatalf("adopted ec volume %d before the shard sizes settle", w.vid)
+	}
+	settle()
+	// a write reserved by the earlier leader lands
+	heartbeat(dn2, 7, 13, 6)
+	if w := topo.adoptEcWritableVolume(option, scheme); w != nil {
+		t.Fatalf("adopted ec volume %d while the shards grow", w.vid)
+	}
+	settle()
+
+	fileId, offset, shardLocations, err := topo.PickForEcWrite(nil, option, scheme, 100)
+	if err != nil {
+		t.Fatalf("pick: %v", err)
+	}
+	if fid, _ := needle.ParseFileIdFromString(fileId); fid == nil || fid.VolumeId != 7 {
+		t.Errorf("unexpected file id %s", fileId)
+	}
+	if offset != 6*scheme.RowSize() {
+		t.Errorf("offset %d, expected after the 6 rows written", offset)
+	}
+	if len(shardLocations) != scheme.TotalShards() {
+		t.Errorf("%d shard locations", len(shardLocations))
+	}
+	if _, offset, _, _ = topo.PickForEcWrite(nil, option, scheme, 100); offset != 7*scheme.RowSize() {
+		t.Errorf("offset %d, expected after the reserved row", offset)
+	}
+
+	// another leader may reserve rows in the meantime
+	topo.ResetEcWriteLayouts()
+	if w := topo.adoptEcWritableVolume(option, scheme); w != nil {
+		t.Fatalf("adopted ec volume %d without heartbeats after the leader changed", w.vid)
+	}
+}
+
+func TestEcWriteSkipsShardServerDown(t *testing.T) {
+	topo := NewTopology("weedfs", sequence.NewMemorySequencer(), 32*1024, 5, false)
+	rack := topo.GetOrCreateDataCenter("dc1").GetOrCreateRack("rack1")
+	maxVolumeCounts := map[string]uint32{"": 25}
+	dn1 := rack.GetOrCreateDataNode("127.0.0.1", 34534, 0, "127.0.0.1", maxVolumeCounts)
+	dn2 := rack.GetOrCreateDataNode("127.0.0.1", 34535, 0, "127.0.0.1", maxVolumeCounts)
+
+	scheme := erasure_coding.DefaultEcScheme
+	writable := &ecWritableVolume{vid: 7, nextOffset: scheme.RowSize()}
+	for shardId := 0; shardId < scheme.TotalShards(); shardId++ {
+		dn := dn1
+		if shardId >= scheme.DataShards {
+			dn = dn2
+		}
+		writable.locations = append(writable.locations, dn)
+		topo.RegisterEcShards(erasure_coding.NewEcVolumeInfo("", "c", 7, erasure_coding.ShardBits(0).AddShardId(erasure_coding.ShardId(shardId)), scheme, 0), dn)
+	}
+	if topo.ecWriteShardLocations(writable, scheme) == nil {
+		t.Fatalf("no shard locations with all the shard servers up")
+	}
+
+	topo.UnRegisterEcShards(erasure_coding.NewEcVolumeInfo("", "c", 7, erasure_coding.ShardBits(0).AddShardId(erasure_coding.ShardId(scheme.DataShards)), scheme, 0), dn2)
+	if locations := topo.ecWriteShardLocations(writable, scheme); locations != nil {
+		t.Errorf("shard locations %v with a shard server down", locations)
+	}
+}
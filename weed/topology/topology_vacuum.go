@@ -19,10 +19,14 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
 )
 
+// batchVacuumVolumeCheck returns the locations to vacuum, whether to vacuum, and whether to compact in place
+// because some location has no free space for a compacted copy
 func (t *Topology) batchVacuumVolumeCheck(grpcDialOption grpc.DialOption, vid needle.VolumeId,
-	locationlist *VolumeLocationList, garbageThreshold float64) (*VolumeLocationList, bool) {
+	locationlist *VolumeLocationList, garbageThreshold float64) (*VolumeLocationList, bool, bool) {
 	ch := make(chan int, locationlist.Length())
 	errCount := int32(0)
+	lackSpaceCount := int32(0)
+	garbageCount := int32(0)
 	for index, dn := range locationlist.list {
 		go func(index int, url pb.ServerAddress, vid needle.VolumeId) {
 			err := operation.WithVolumeServerClient(false, url, grpcDialOption, func(volumeServerClient volume_server_pb.VolumeServerClient) error {
@@ -34,7 +38,18 @@ func (t *Topology) batchVacuumVolumeCheck(grpcDialOption grpc.DialOption, vid ne
 					ch <- -1
 					return err
 				}
-				if resp.GarbageRatio >= garbageThreshold {
+				if resp.GarbageRatio >= garbageThreshold || resp.NeedsUpgrade {
+					if resp.GarbageRatio >= garbageThreshold {
+						atomic.AddInt32(&garbageCount, 1)
+					}
+					if resp.NeedsUpgrade {
+						glog.V(0).Infof("volume %d on %s needs to upgrade its needle version", vid, url)
+					}
+					if resp.FreeSize < resp.CompactSize {
+						glog.V(0).Infof("volume %d on %s needs %d bytes to compact, free %d bytes, compacting in place",
+							vid, url, resp.CompactSize, resp.FreeSize)
+						atomic.AddInt32(&lackSpaceCount, 1)
+					}
 					ch <- index
 				} else {
 					ch <- -1
@@ -58,14 +73,20 @@ func (t *Topology) batchVacuumVolumeCheck(grpcDialOption grpc.DialOption, vid ne
 				vacuumLocationList.list = append(vacuumLocationList.list, locationlist.list[index])
 			}
 		case <-waitTimeout.C:
-			return vacuumLocationList, false
+			return vacuumLocationList, false, false
 		}
 	}
-	return vacuumLocationList, errCount == 0 && len(vacuumLocationList.list) > 0
+	lacksSpace := atomic.LoadInt32(&lackSpaceCount) > 0
+	if lacksSpace && atomic.LoadInt32(&garbageCount) == 0 {
+		// compacting in place keeps the needle version, so vacuuming only to upgrade would repeat every time
+		glog.V(1).Infof("volume %d is not upgraded, lacking space for a compacted copy", vid)
+		return vacuumLocationList, false, true
+	}
+	return vacuumLocationList, errCount == 0 && len(vacuumLocationList.list) > 0, lacksSpace
 }
 
 func (t *Topology) batchVacuumVolumeCompact(grpcDialOption grpc.DialOption, vl *VolumeLayout, vid needle.VolumeId,
-	locationlist *VolumeLocationList, preallocate int64) bool {
+	locationlist *VolumeLocationList, preallocate int64, inPlace bool) bool {
 	vl.accessLock.Lock()
 	vl.removeFromWritable(vid)
 	vl.accessLock.Unlock()
@@ -78,6 +99,7 @@ func (t *Topology) batchVacuumVolumeCompact(grpcDialOption grpc.DialOption, vl *
 				stream, err := volumeServerClient.VacuumVolumeCompact(context.Background(), &volume_server_pb.VacuumVolumeCompactRequest{
 					VolumeId:    uint32(vid),
 					Preallocate: preallocate,
+					InPlace:     inPlace,
 				})
 				if err != nil {
 					return err
@@ -339,9 +361,9 @@ func (t *Topology) vacuumOneVolumeId(grpcDialOption grpc.DialOption, volumeLayou
 	}
 
 	glog.V(1).Infof("check vacuum on collection:%s volume:%d", c.Name, vid)
-	if vacuumLocationList, needVacuum := t.batchVacuumVolumeCheck(
+	if vacuumLocationList, needVacuum, inPlace := t.batchVacuumVolumeCheck(
 		grpcDialOption, vid, locationList, garbageThreshold); needVacuum {
-		if t.batchVacuumVolumeCompact(grpcDialOption, volumeLayout, vid, vacuumLocationList, preallocate) {
+		if t.batchVacuumVolumeCompact(grpcDialOption, volumeLayout, vid, vacuumLocationList, preallocate, inPlace) {
 			t.batchVacuumVolumeCommit(grpcDialOption, volumeLayout, vid, vacuumLocationList, locationList)
 		} else {
 			t.batchVacuumVolumeCleanup(grpcDialOption, volumeLayout, vid, vacuumLocationList)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/seaweedfs/seaweedfs/weed/pb/master_pb"
+	"github.com/seaweedfs/seaweedfs/weed/pb/volume_server_pb"
 	"math/rand/v2"
 	"reflect"
 	"sync"
@@ -249,8 +250,12 @@ func (vg *VolumeGrowth) findEmptySlotsForOneVolume(topo *Topology, option *Volum
 
 func (vg *VolumeGrowth) grow(grpcDialOption grpc.DialOption, topo *Topology, vid needle.VolumeId, option *VolumeGrowOption, servers ...*DataNode) (growErr error) {
 	var createdVolumes []storage.VolumeInfo
+	var dataKeys []*volume_server_pb.VolumeDataKey
 	for _, server := range servers {
-		if err := AllocateVolume(server, grpcDialOption, vid, option); err == nil {
+		if volumeDataKeys, err := AllocateVolume(server, grpcDialOption, vid, option, dataKeys); err == nil {
+			if dataKeys == nil {
+				dataKeys = volumeDataKeys
+			}
 			createdVolumes = append(createdVolumes, storage.VolumeInfo{
 				Id:               vid,
 				Size:             0,
@@ -265,6 +270,10 @@ func (vg *VolumeGrowth) grow(grpcDialOption grpc.DialOption, topo *Topology, vid
 		} else {
 			glog.Warningf("Failed to assign volume %d on %s: %v", vid, server.NodeImpl.String(), err)
 			growErr = fmt.Errorf("failed to assign volume %d on %s: %v", vid, server.NodeImpl.String(), err)
+			// the volume could be created before the error, e.g. failing to wrap its data keys or to reply
+			if deleteErr := DeleteVolume(server, grpcDialOption, vid); deleteErr != nil {
+				glog.V(1).Infof("clean up volume %d on %s: %v", vid, server.NodeImpl.String(), deleteErr)
+			}
 			break
 		}
 	}
@@ -2,7 +2,9 @@ package chunk_cache
 
 import (
 	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/storage/needle"
@@ -17,23 +19,63 @@ type ChunkCache interface {
 	GetMaxFilePartSizeInCache() (answer uint64)
 }
 
+// ZeroCopyChunkCache locates the cached chunk data, so it can be served without copying
+type ZeroCopyChunkCache interface {
+	LocateChunkAt(fileId string, offset uint64, size int) (ref CachedChunkRef, found bool)
+}
+
+// CachedChunkRef is either the in memory chunk data, or the data in a cache file.
+// The data must not be modified.
+type CachedChunkRef struct {
+	Data     []byte
+	Fd       uintptr
+	FdOffset int64
+	Size     int
+}
+
 // a global cache for recently accessed file chunks
 type TieredChunkCache struct {
-	memCache   *ChunkCacheInMemory
-	diskCaches []*OnDiskCacheLayer
+	memCache    *ChunkCacheInMemory
+	diskCaches  []*OnDiskCacheLayer
+	pinnedCache *PinnedCacheRegion // nil until SetPinnedCapacity
+	dir         string
 	sync.RWMutex
 	onDiskCacheSizeLimit0  uint64
 	onDiskCacheSizeLimit1  uint64
 	onDiskCacheSizeLimit2  uint64
 	maxFilePartSizeInCache uint64
+	unitSize               int64
+
+	memoryHits int64
+	diskHits   int64
+	pinnedHits int64
+	misses     int64
+}
+
+// TieredChunkCacheStats reports the hits and the usage of the cache
+type TieredChunkCacheStats struct {
+	MemoryHits        int64
+	DiskHits          int64
+	Misses            int64
+	MemoryEntries     int
+	DiskUsedBytes     int64
+	DiskCapacityBytes int64
+
+	PinnedHits          int64
+	PinnedChunks        int
+	PinnedUsedBytes     int64
+	PinnedCapacityBytes int64
 }
 
 var _ ChunkCache = &TieredChunkCache{}
+var _ ZeroCopyChunkCache = &TieredChunkCache{}
 
 func NewTieredChunkCache(maxEntries int64, dir string, diskSizeInUnit int64, unitSize int64) *TieredChunkCache {
 
 	c := &TieredChunkCache{
 		memCache: NewChunkCacheInMemory(maxEntries),
+		unitSize: unitSize,
+		dir:      dir,
 	}
 	c.diskCaches = make([]*OnDiskCacheLayer, 3)
 	c.onDiskCacheSizeLimit0 = uint64(unitSize)
@@ -76,9 +118,14 @@ func (c *TieredChunkCache) IsInCache(fileId string, lockNeeded bool) (answer boo
 		return false
 	}
 
+	if c.pinnedCache != nil && c.pinnedCache.isPinned(fid.Key) {
+		glog.V(4).Infof("fileId %s is in the pinned cache", fileId)
+		return true
+	}
+
 	for i, diskCacheLayer := range c.diskCaches {
 		for k, v := range diskCacheLayer.diskCaches {
-			_, ok := v.nm.Get(fid.Key)
+			_, ok := v.getNeedleValue(fid.Key)
 			if ok {
 				glog.V(4).Infof("fileId %s is in diskCaches[%d].volume[%d]", fileId, i, k)
 				return true
@@ -103,6 +150,7 @@ func (c *TieredChunkCache) ReadChunkAt(data []byte, fileId string, offset uint64
 			glog.Errorf("failed to read from memcache: %s", err)
 		}
 		if n == int(len(data)) {
+			atomic.AddInt64(&c.memoryHits, 1)
 			return n, nil
 		}
 	}
@@ -110,32 +158,87 @@ func (c *TieredChunkCache) ReadChunkAt(data []byte, fileId string, offset uint64
 	fid, err := needle.ParseFileIdFromString(fileId)
 	if err != nil {
 		glog.Errorf("failed to parse file id %s", fileId)
+		atomic.AddInt64(&c.misses, 1)
 		return 0, nil
 	}
 
+	if c.pinnedCache != nil {
+		n, err = c.pinnedCache.readChunkAt(data, fid.Key, offset)
+		if n == int(len(data)) {
+			atomic.AddInt64(&c.pinnedHits, 1)
+			return
+		}
+	}
+
 	if minSize <= c.onDiskCacheSizeLimit0 {
 		n, err = c.diskCaches[0].readChunkAt(data, fid.Key, offset)
 		if n == int(len(data)) {
+			atomic.AddInt64(&c.diskHits, 1)
 			return
 		}
 	}
 	if minSize <= c.onDiskCacheSizeLimit1 {
 		n, err = c.diskCaches[1].readChunkAt(data, fid.Key, offset)
 		if n == int(len(data)) {
+			atomic.AddInt64(&c.diskHits, 1)
 			return
 		}
 	}
 	{
 		n, err = c.diskCaches[2].readChunkAt(data, fid.Key, offset)
 		if n == int(len(data)) {
+			atomic.AddInt64(&c.diskHits, 1)
 			return
 		}
 	}
 
+	atomic.AddInt64(&c.misses, 1)
 	return 0, nil
 
 }
 
+// LocateChunkAt finds the whole range [offset, offset+size) of the chunk in one cache tier
+func (c *TieredChunkCache) LocateChunkAt(fileId string, offset uint64, size int) (ref CachedChunkRef, found bool) {
+	if c == nil {
+		return
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	minSize := offset + uint64(size)
+	if minSize <= c.onDiskCacheSizeLimit0 {
+		if data, err := c.memCache.getChunkSlice(fileId, offset, uint64(size)); err == nil && len(data) == size {
+			atomic.AddInt64(&c.memoryHits, 1)
+			return CachedChunkRef{Data: data, Size: size}, true
+		}
+	}
+
+	fid, err := needle.ParseFileIdFromString(fileId)
+	if err != nil {
+		return
+	}
+
+	if c.pinnedCache != nil && c.pinnedCache.isPinned(fid.Key) {
+		if fd, fdOffset, err := c.pinnedCache.volume.locateNeedleSlice(fid.Key, offset, size); err == nil {
+			atomic.AddInt64(&c.pinnedHits, 1)
+			return CachedChunkRef{Fd: fd, FdOffset: fdOffset, Size: size}, true
+		}
+	}
+
+	for i, diskCache := range c.diskCaches {
+		if (i == 0 && minSize > c.onDiskCacheSizeLimit0) || (i == 1 && minSize > c.onDiskCacheSizeLimit1) {
+			continue
+		}
+		if fd, fdOffset, found := diskCache.locateChunkAt(fid.Key, offset, size); found {
+			atomic.AddInt64(&c.diskHits, 1)
+			return CachedChunkRef{Fd: fd, FdOffset: fdOffset, Size: size}, true
+		}
+	}
+
+	return
+}
+
 func (c *TieredChunkCache) SetChunk(fileId string, data []byte) {
 	if c == nil {
 		return
@@ -174,6 +277,135 @@ func (c *TieredChunkCache) doSetChunk(fileId string, data []byte) {
 
 }
 
+// DeleteChunk removes the chunk from all the cache tiers
+func (c *TieredChunkCache) DeleteChunk(fileId string) (deleted bool) {
+	if c == nil {
+		return false
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	deleted = c.memCache.cache.Delete(fileId)
+
+	fid, err := needle.ParseFileIdFromString(fileId)
+	if err != nil {
+		glog.Errorf("failed to parse file id %s", fileId)
+		return
+	}
+	for _, diskCache := range c.diskCaches {
+		if diskCache.deleteChunk(fid.Key) {
+			deleted = true
+		}
+	}
+	return
+}
+
+// SetDiskCapacity changes the size of the on disk tiers, in the unit size of the cache
+func (c *TieredChunkCache) SetDiskCapacity(diskSizeInUnit int64) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	diskSize := diskSizeInUnit * c.unitSize
+	c.diskCaches[0].setSizeLimit(diskSize / 8)
+	c.diskCaches[1].setSizeLimit(diskSize/4 + diskSize/8)
+	c.diskCaches[2].setSizeLimit(diskSize / 2)
+	c.maxFilePartSizeInCache = uint64(diskSize) / 4
+}
+
+func (c *TieredChunkCache) Stats() (stats TieredChunkCacheStats) {
+	if c == nil {
+		return
+	}
+	c.RLock()
+	defer c.RUnlock()
+
+	stats.MemoryHits = atomic.LoadInt64(&c.memoryHits)
+	stats.DiskHits = atomic.LoadInt64(&c.diskHits)
+	stats.PinnedHits = atomic.LoadInt64(&c.pinnedHits)
+	stats.Misses = atomic.LoadInt64(&c.misses)
+	stats.MemoryEntries = c.memCache.cache.ItemCount()
+	for _, diskCache := range c.diskCaches {
+		used, capacity := diskCache.usage()
+		stats.DiskUsedBytes += used
+		stats.DiskCapacityBytes += capacity
+	}
+	if c.pinnedCache != nil {
+		stats.PinnedUsedBytes, stats.PinnedCapacityBytes, stats.PinnedChunks = c.pinnedCache.usage()
+	}
+	return
+}
+
+// SetPinnedCapacity creates or resizes the pinned region, in the unit size of the cache.
+// The pinned chunks are not evicted by the LRU tiers, and are only removed by UnpinChunk.
+func (c *TieredChunkCache) SetPinnedCapacity(diskSizeInUnit int64) error {
+	if c == nil {
+		return ErrorPinnedRegionDisabled
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	if c.pinnedCache != nil {
+		c.pinnedCache.setSizeLimit(diskSizeInUnit * c.unitSize)
+		return nil
+	}
+	pinnedCache, err := newPinnedCacheRegion(c.dir, diskSizeInUnit*c.unitSize)
+	if err != nil {
+		return err
+	}
+	c.pinnedCache = pinnedCache
+	return nil
+}
+
+// PinChunk stores the chunk in the pinned region
+func (c *TieredChunkCache) PinChunk(fileId string, data []byte) error {
+	if c == nil || c.pinnedCache == nil {
+		return ErrorPinnedRegionDisabled
+	}
+
+	fid, err := needle.ParseFileIdFromString(fileId)
+	if err != nil {
+		return fmt.Errorf("failed to parse file id %s", fileId)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	return c.pinnedCache.pinChunk(fid.Key, data)
+}
+
+func (c *TieredChunkCache) IsPinned(fileId string) bool {
+	if c == nil || c.pinnedCache == nil {
+		return false
+	}
+
+	fid, err := needle.ParseFileIdFromString(fileId)
+	if err != nil {
+		return false
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	return c.pinnedCache.isPinned(fid.Key)
+}
+
+// UnpinChunk removes the chunk from the pinned region, and returns whether it was pinned
+func (c *TieredChunkCache) UnpinChunk(fileId string) bool {
+	if c == nil || c.pinnedCache == nil {
+		return false
+	}
+
+	fid, err := needle.ParseFileIdFromString(fileId)
+	if err != nil {
+		return false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	return c.pinnedCache.unpinChunk(fid.Key)
+}
+
 func (c *TieredChunkCache) Shutdown() {
 	if c == nil {
 		return
@@ -183,6 +415,9 @@ func (c *TieredChunkCache) Shutdown() {
 	for _, diskCache := range c.diskCaches {
 		diskCache.shutdown()
 	}
+	if c.pinnedCache != nil {
+		c.pinnedCache.shutdown()
+	}
 }
 
 func min(x, y int) int {
@@ -3,6 +3,7 @@ package chunk_cache
 import (
 	"fmt"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/syndtr/goleveldb/leveldb/opt"
@@ -10,6 +11,7 @@ import (
 	"github.com/seaweedfs/seaweedfs/weed/glog"
 	"github.com/seaweedfs/seaweedfs/weed/storage"
 	"github.com/seaweedfs/seaweedfs/weed/storage/backend"
+	"github.com/seaweedfs/seaweedfs/weed/storage/needle_map"
 	"github.com/seaweedfs/seaweedfs/weed/storage/types"
 	"github.com/seaweedfs/seaweedfs/weed/util"
 )
@@ -25,8 +27,12 @@ type ChunkCacheVolume struct {
 	sizeLimit   int64
 	lastModTime time.Time
 	fileSize    int64
+	isLocated   int32 // the data file has been handed out for zero copy reads
 }
 
+// ZeroCopyGracePeriod keeps the closed data files open for a while, for the zero copy reads still in flight
+const ZeroCopyGracePeriod = 10 * time.Second
+
 func LoadOrCreateChunkCacheVolume(fileName string, preallocate int64) (*ChunkCacheVolume, error) {
 
 	v := &ChunkCacheVolume{
@@ -79,7 +85,14 @@ func LoadOrCreateChunkCacheVolume(fileName string, preallocate int64) (*ChunkCac
 
 func (v *ChunkCacheVolume) Shutdown() {
 	if v.DataBackend != nil {
-		v.DataBackend.Close()
+		if atomic.LoadInt32(&v.isLocated) == 1 {
+			dataBackend := v.DataBackend
+			time.AfterFunc(ZeroCopyGracePeriod, func() {
+				dataBackend.Close()
+			})
+		} else {
+			v.DataBackend.Close()
+		}
 		v.DataBackend = nil
 	}
 	if v.nm != nil {
@@ -90,7 +103,8 @@ func (v *ChunkCacheVolume) Shutdown() {
 
 func (v *ChunkCacheVolume) doReset() {
 	v.Shutdown()
-	os.Truncate(v.fileName+".dat", 0)
+	// removed instead of truncated, so the zero copy reads in flight still see the old data
+	os.Remove(v.fileName + ".dat")
 	os.Truncate(v.fileName+".idx", 0)
 	glog.V(4).Infof("cache removeAll %s ...", v.fileName+".ldb")
 	os.RemoveAll(v.fileName + ".ldb")
@@ -102,9 +116,18 @@ func (v *ChunkCacheVolume) Reset() (*ChunkCacheVolume, error) {
 	return LoadOrCreateChunkCacheVolume(v.fileName, v.sizeLimit)
 }
 
+// getNeedleValue skips the deleted needles, which are kept as tombstones in the needle map
+func (v *ChunkCacheVolume) getNeedleValue(key types.NeedleId) (*needle_map.NeedleValue, bool) {
+	nv, ok := v.nm.Get(key)
+	if !ok || nv.Size.IsDeleted() {
+		return nil, false
+	}
+	return nv, true
+}
+
 func (v *ChunkCacheVolume) GetNeedle(key types.NeedleId) ([]byte, error) {
 
-	nv, ok := v.nm.Get(key)
+	nv, ok := v.getNeedleValue(key)
 	if !ok {
 		return nil, storage.ErrorNotFound
 	}
@@ -122,7 +145,7 @@ func (v *ChunkCacheVolume) GetNeedle(key types.NeedleId) ([]byte, error) {
 }
 
 func (v *ChunkCacheVolume) getNeedleSlice(key types.NeedleId, offset, length uint64) ([]byte, error) {
-	nv, ok := v.nm.Get(key)
+	nv, ok := v.getNeedleValue(key)
 	if !ok {
 		return nil, storage.ErrorNotFound
 	}
@@ -145,7 +168,7 @@ func (v *ChunkCacheVolume) getNeedleSlice(key types.NeedleId, offset, length uin
 }
 
 func (v *ChunkCacheVolume) readNeedleSliceAt(data []byte, key types.NeedleId, offset uint64) (n int, err error) {
-	nv, ok := v.nm.Get(key)
+	nv, ok := v.getNeedleValue(key)
 	if !ok {
 		return 0, storage.ErrorNotFound
 	}
@@ -166,6 +189,32 @@ func (v *ChunkCacheVolume) readNeedleSliceAt(data []byte, key types.NeedleId, of
 	return n, nil
 }
 
+// locateNeedleSlice finds the needle slice in the data file, to be read without copying.
+// The data file stays readable for ZeroCopyGracePeriod after the volume is reset.
+func (v *ChunkCacheVolume) locateNeedleSlice(key types.NeedleId, offset uint64, length int) (fd uintptr, fileOffset int64, err error) {
+	nv, ok := v.getNeedleValue(key)
+	if !ok {
+		return 0, 0, storage.ErrorNotFound
+	}
+	if offset+uint64(length) > uint64(nv.Size) {
+		return 0, 0, ErrorOutOfBounds
+	}
+	diskFile, ok := v.DataBackend.(*backend.DiskFile)
+	if !ok {
+		return 0, 0, fmt.Errorf("cache file %s.dat is not a local file", v.fileName)
+	}
+	atomic.StoreInt32(&v.isLocated, 1)
+	return diskFile.File.Fd(), nv.Offset.ToActualOffset() + int64(offset), nil
+}
+
+func (v *ChunkCacheVolume) DeleteNeedle(key types.NeedleId) error {
+	nv, ok := v.getNeedleValue(key)
+	if !ok {
+		return storage.ErrorNotFound
+	}
+	return v.nm.Delete(key, nv.Offset)
+}
+
 func (v *ChunkCacheVolume) WriteNeedle(key types.NeedleId, data []byte) error {
 
 	offset := v.fileSize
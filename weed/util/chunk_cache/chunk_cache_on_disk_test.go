@@ -103,3 +103,44 @@ func TestOnDisk(t *testing.T) {
 	cache.Shutdown()
 
 }
+
+func TestDeleteChunkAndStats(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cache := NewTieredChunkCache(2, tmpDir, 32, 1024)
+	defer cache.Shutdown()
+
+	data := make([]byte, 1024)
+	rand.Read(data)
+	cache.SetChunk("1,1aabbccdd", data)
+
+	buff := make([]byte, len(data))
+	if n, _ := cache.ReadChunkAt(buff, "1,1aabbccdd", 0); n != len(data) || !bytes.Equal(buff, data) {
+		t.Fatalf("read back %d bytes", n)
+	}
+	if !cache.DeleteChunk("1,1aabbccdd") {
+		t.Errorf("chunk is not deleted")
+	}
+	if cache.IsInCache("1,1aabbccdd", true) {
+		t.Errorf("deleted chunk is still in cache")
+	}
+	if n, _ := cache.ReadChunkAt(buff, "1,1aabbccdd", 0); n != 0 {
+		t.Errorf("read %d bytes of a deleted chunk", n)
+	}
+
+	stats := cache.Stats()
+	if stats.MemoryHits != 1 || stats.DiskHits != 0 || stats.Misses != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+	if stats.DiskCapacityBytes != 32*1024 {
+		t.Errorf("disk capacity %d", stats.DiskCapacityBytes)
+	}
+
+	cache.SetDiskCapacity(64)
+	if stats = cache.Stats(); stats.DiskCapacityBytes != 64*1024 {
+		t.Errorf("disk capacity %d after resizing", stats.DiskCapacityBytes)
+	}
+	if cache.GetMaxFilePartSizeInCache() != 16*1024 {
+		t.Errorf("max file part size %d", cache.GetMaxFilePartSizeInCache())
+	}
+}
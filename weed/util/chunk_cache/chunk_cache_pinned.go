@@ -0,0 +1,148 @@
+package chunk_cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/seaweedfs/seaweedfs/weed/glog"
+	"github.com/seaweedfs/seaweedfs/weed/storage/types"
+)
+
+var (
+	ErrorPinnedRegionDisabled = errors.New("pinned cache region is disabled")
+	ErrorPinnedRegionFull     = errors.New("pinned cache region is full")
+)
+
+// PinnedCacheRegion keeps the chunks of the pinned directories on disk, away from the LRU tiers,
+// so one large scan can not evict them.
+// The chunks are appended to one cache volume. The space of the unpinned chunks is
+// reclaimed by copying the remaining chunks to a new cache volume when the volume is full.
+type PinnedCacheRegion struct {
+	dir        string
+	generation int
+	volume     *ChunkCacheVolume
+	chunks     map[types.NeedleId]int64 // the pinned chunks and their sizes
+	liveBytes  int64
+}
+
+func newPinnedCacheRegion(dir string, sizeLimit int64) (*PinnedCacheRegion, error) {
+	r := &PinnedCacheRegion{
+		dir:    dir,
+		chunks: make(map[types.NeedleId]int64),
+	}
+	// the pins do not survive restarts, so neither do their chunks
+	for generation := 0; generation < 2; generation++ {
+		removeChunkCacheVolume(r.volumeFileName(generation))
+	}
+	volume, err := LoadOrCreateChunkCacheVolume(r.volumeFileName(r.generation), sizeLimit)
+	if err != nil {
+		return nil, err
+	}
+	r.volume = volume
+	return r, nil
+}
+
+func (r *PinnedCacheRegion) volumeFileName(generation int) string {
+	return path.Join(r.dir, fmt.Sprintf("pinned_%d", generation%2))
+}
+
+func (r *PinnedCacheRegion) isPinned(needleId types.NeedleId) bool {
+	_, found := r.chunks[needleId]
+	return found
+}
+
+func (r *PinnedCacheRegion) readChunkAt(buffer []byte, needleId types.NeedleId, offset uint64) (n int, err error) {
+	if !r.isPinned(needleId) {
+		return 0, nil
+	}
+	return r.volume.readNeedleSliceAt(buffer, needleId, offset)
+}
+
+func (r *PinnedCacheRegion) pinChunk(needleId types.NeedleId, data []byte) error {
+	if r.isPinned(needleId) {
+		return nil
+	}
+	size := int64(len(data)) + types.NeedlePaddingSize
+	if r.liveBytes+size > r.volume.sizeLimit {
+		return ErrorPinnedRegionFull
+	}
+	if r.volume.fileSize+size > r.volume.sizeLimit {
+		if err := r.compact(); err != nil {
+			return fmt.Errorf("compact pinned cache region: %v", err)
+		}
+	}
+	if err := r.volume.WriteNeedle(needleId, data); err != nil {
+		return err
+	}
+	r.chunks[needleId] = int64(len(data))
+	r.liveBytes += size
+	return nil
+}
+
+func (r *PinnedCacheRegion) unpinChunk(needleId types.NeedleId) bool {
+	size, found := r.chunks[needleId]
+	if !found {
+		return false
+	}
+	if err := r.volume.DeleteNeedle(needleId); err != nil {
+		glog.Warningf("unpin chunk %d from %s: %v", needleId, r.volume.fileName, err)
+	}
+	delete(r.chunks, needleId)
+	r.liveBytes -= size + types.NeedlePaddingSize
+	if len(r.chunks) == 0 {
+		// nothing is left, so the whole volume can be reclaimed cheaply
+		if volume, err := r.volume.Reset(); err != nil {
+			glog.Errorf("reset pinned cache region %s: %v", r.volume.fileName, err)
+		} else {
+			r.volume, r.liveBytes = volume, 0
+		}
+	}
+	return true
+}
+
+// compact copies the pinned chunks to the other cache volume, leaving the unpinned chunks behind
+func (r *PinnedCacheRegion) compact() error {
+	nextFileName := r.volumeFileName(r.generation + 1)
+	removeChunkCacheVolume(nextFileName)
+	next, err := LoadOrCreateChunkCacheVolume(nextFileName, r.volume.sizeLimit)
+	if err != nil {
+		return err
+	}
+	for needleId := range r.chunks {
+		data, readErr := r.volume.GetNeedle(needleId)
+		if readErr == nil {
+			readErr = next.WriteNeedle(needleId, data)
+		}
+		if readErr != nil {
+			next.Shutdown()
+			removeChunkCacheVolume(nextFileName)
+			return fmt.Errorf("copy chunk %d: %v", needleId, readErr)
+		}
+	}
+	glog.V(1).Infof("compacted pinned cache region %s from %d to %d bytes", r.volume.fileName, r.volume.fileSize, next.fileSize)
+	r.volume.Shutdown()
+	removeChunkCacheVolume(r.volume.fileName)
+	r.volume = next
+	r.generation++
+	return nil
+}
+
+func (r *PinnedCacheRegion) setSizeLimit(sizeLimit int64) {
+	r.volume.sizeLimit = sizeLimit
+}
+
+func (r *PinnedCacheRegion) usage() (used, capacity int64, chunkCount int) {
+	return r.liveBytes, r.volume.sizeLimit, len(r.chunks)
+}
+
+func (r *PinnedCacheRegion) shutdown() {
+	r.volume.Shutdown()
+}
+
+func removeChunkCacheVolume(fileName string) {
+	os.Remove(fileName + ".dat")
+	os.Remove(fileName + ".idx")
+	os.RemoveAll(fileName + ".ldb")
+}
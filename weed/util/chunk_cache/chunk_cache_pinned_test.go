@@ -0,0 +1,66 @@
+package chunk_cache
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestPinnedRegion(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cache := NewTieredChunkCache(2, tmpDir, 32, 1024)
+	defer cache.Shutdown()
+
+	if err := cache.PinChunk("1,1aabbccdd", []byte("x")); err != ErrorPinnedRegionDisabled {
+		t.Fatalf("pinned without the pinned region: %v", err)
+	}
+	if err := cache.SetPinnedCapacity(8); err != nil {
+		t.Fatalf("set pinned capacity: %v", err)
+	}
+
+	// fill the pinned region with chunks of 2KB, bigger than the memory tier
+	var chunks [][]byte
+	for i := 0; i < 3; i++ {
+		data := make([]byte, 2048)
+		rand.Read(data)
+		if err := cache.PinChunk(fmt.Sprintf("1,%x1aabbccdd", i+1), data); err != nil {
+			t.Fatalf("pin chunk %d: %v", i, err)
+		}
+		chunks = append(chunks, data)
+	}
+	if err := cache.PinChunk("1,91aabbccdd", make([]byte, 4096)); err != ErrorPinnedRegionFull {
+		t.Fatalf("expected the pinned region to be full: %v", err)
+	}
+
+	// the LRU tiers can not evict the pinned chunks
+	for i := 0; i < 64; i++ {
+		cache.SetChunk(fmt.Sprintf("2,%x1aabbccdd", i+1), make([]byte, 2048))
+	}
+	buff := make([]byte, 2048)
+	for i, data := range chunks {
+		fileId := fmt.Sprintf("1,%x1aabbccdd", i+1)
+		if n, _ := cache.ReadChunkAt(buff, fileId, 0); n != len(data) || !bytes.Equal(buff, data) {
+			t.Fatalf("read back pinned chunk %d: %d bytes", i, n)
+		}
+	}
+
+	// the unpinned space is reclaimed by compaction
+	if !cache.UnpinChunk("1,11aabbccdd") || cache.IsPinned("1,11aabbccdd") {
+		t.Fatalf("chunk is not unpinned")
+	}
+	if err := cache.PinChunk("1,41aabbccdd", make([]byte, 2048)); err != nil {
+		t.Fatalf("pin after unpinning: %v", err)
+	}
+	for i := 1; i < 3; i++ {
+		if n, _ := cache.ReadChunkAt(buff, fmt.Sprintf("1,%x1aabbccdd", i+1), 0); n != len(buff) || !bytes.Equal(buff, chunks[i]) {
+			t.Fatalf("read back pinned chunk %d after compaction: %d bytes", i, n)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.PinnedChunks != 3 || stats.PinnedHits != 5 || stats.PinnedCapacityBytes != 8*1024 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
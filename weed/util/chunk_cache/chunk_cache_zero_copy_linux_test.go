@@ -0,0 +1,118 @@
+package chunk_cache
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/splice"
+)
+
+func TestLocateChunkAt(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cache := NewTieredChunkCache(2, tmpDir, 32, 1024)
+	defer cache.Shutdown()
+
+	small, large := make([]byte, 512), make([]byte, 4096)
+	rand.Read(small)
+	rand.Read(large)
+	cache.SetChunk("1,1aabbccdd", small)
+	cache.SetChunk("1,2aabbccdd", large)
+
+	ref, found := cache.LocateChunkAt("1,1aabbccdd", 100, 200)
+	if !found || !bytes.Equal(ref.Data, small[100:300]) {
+		t.Fatalf("locate in memory chunk: %v", found)
+	}
+
+	ref, found = cache.LocateChunkAt("1,2aabbccdd", 1000, 2000)
+	if !found || ref.Data != nil {
+		t.Fatalf("locate on disk chunk: %v", found)
+	}
+	buf := make([]byte, ref.Size)
+	if n, err := syscall.Pread(int(ref.Fd), buf, ref.FdOffset); err != nil || n != len(buf) || !bytes.Equal(buf, large[1000:3000]) {
+		t.Fatalf("pread located chunk: %d %v", n, err)
+	}
+
+	if _, found = cache.LocateChunkAt("1,2aabbccdd", 4000, 200); found {
+		t.Errorf("located beyond the chunk")
+	}
+	if _, found = cache.LocateChunkAt("1,3aabbccdd", 0, 200); found {
+		t.Errorf("located a missing chunk")
+	}
+
+	// the located data stays readable while the cache volumes are rotated
+	for i := 0; i < 64; i++ {
+		cache.SetChunk(fmt.Sprintf("2,%x1aabbccdd", i+1), make([]byte, 4096))
+	}
+	if cache.IsInCache("1,2aabbccdd", true) {
+		t.Fatalf("expected the chunk to be evicted")
+	}
+	if n, err := syscall.Pread(int(ref.Fd), buf, ref.FdOffset); err != nil || n != len(buf) || !bytes.Equal(buf, large[1000:3000]) {
+		t.Fatalf("pread located chunk after eviction: %d %v", n, err)
+	}
+}
+
+// BenchmarkCachedChunkRead compares serving a 128KB read from the on disk chunk cache
+// by copying into a buffer, as the fuse server writes the reply, against splicing from the cache file.
+func BenchmarkCachedChunkRead(b *testing.B) {
+	tmpDir := b.TempDir()
+
+	const chunkSize, readSize = 4 * 1024 * 1024, 128 * 1024
+	cache := NewTieredChunkCache(2, tmpDir, 64, 1024*1024)
+	defer cache.Shutdown()
+
+	data := make([]byte, chunkSize)
+	rand.Read(data)
+	cache.SetChunk("1,1aabbccdd", data)
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer devNull.Close()
+
+	b.Run("copy", func(b *testing.B) {
+		buf := make([]byte, readSize)
+		b.SetBytes(readSize)
+		for i := 0; i < b.N; i++ {
+			offset := uint64(i%(chunkSize/readSize)) * readSize
+			if n, _ := cache.ReadChunkAt(buf, "1,1aabbccdd", offset); n != readSize {
+				b.Fatalf("read %d bytes", n)
+			}
+			devNull.Write(buf)
+		}
+	})
+
+	b.Run("splice", func(b *testing.B) {
+		if !splice.Resizable() {
+			b.Skip("splice is not supported")
+		}
+		b.SetBytes(readSize)
+		for i := 0; i < b.N; i++ {
+			offset := uint64(i%(chunkSize/readSize)) * readSize
+			ref, found := cache.LocateChunkAt("1,1aabbccdd", offset, readSize)
+			if !found {
+				b.Fatalf("chunk not found")
+			}
+			pair, err := splice.Get()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err = pair.Grow(readSize); err != nil {
+				b.Fatal(err)
+			}
+			n, err := pair.LoadFromAt(ref.Fd, ref.Size, ref.FdOffset)
+			if err != nil || n != readSize {
+				b.Fatalf("splice %d bytes: %v", n, err)
+			}
+			if _, err = pair.WriteTo(devNull.Fd(), n); err != nil {
+				b.Fatal(err)
+			}
+			splice.Done(pair)
+		}
+	})
+}
@@ -126,6 +126,64 @@ func (c *OnDiskCacheLayer) readChunkAt(buffer []byte, needleId types.NeedleId, o
 
 }
 
+func (c *OnDiskCacheLayer) locateChunkAt(needleId types.NeedleId, offset uint64, size int) (fd uintptr, fileOffset int64, found bool) {
+
+	for _, diskCache := range c.diskCaches {
+		fd, fileOffset, err := diskCache.locateNeedleSlice(needleId, offset, size)
+		if err == nil {
+			return fd, fileOffset, true
+		}
+		if err != storage.ErrorNotFound {
+			glog.V(4).Infof("locate cache file %s id %d: %v", diskCache.fileName, needleId, err)
+		}
+	}
+
+	return 0, 0, false
+
+}
+
+func (c *OnDiskCacheLayer) deleteChunk(needleId types.NeedleId) (deleted bool) {
+
+	for _, diskCache := range c.diskCaches {
+		err := diskCache.DeleteNeedle(needleId)
+		if err == storage.ErrorNotFound {
+			continue
+		}
+		if err != nil {
+			glog.Warningf("failed to delete from cache file %s id %d: %v", diskCache.fileName, needleId, err)
+			continue
+		}
+		deleted = true
+	}
+
+	return
+
+}
+
+// setSizeLimit spreads the new layer size over the cache volumes.
+// The volumes over the limit are reset when they are rotated.
+func (c *OnDiskCacheLayer) setSizeLimit(diskSize int64) {
+
+	if len(c.diskCaches) == 0 {
+		return
+	}
+	volumeSize := diskSize / int64(len(c.diskCaches))
+	for _, diskCache := range c.diskCaches {
+		diskCache.sizeLimit = volumeSize
+	}
+
+}
+
+func (c *OnDiskCacheLayer) usage() (used, capacity int64) {
+
+	for _, diskCache := range c.diskCaches {
+		used += diskCache.fileSize
+		capacity += diskCache.sizeLimit
+	}
+	return
+
+}
+
 func (c *OnDiskCacheLayer) shutdown() {
 
 	for _, diskCache := range c.diskCaches {
@@ -3,10 +3,14 @@ package util
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
 
 	"github.com/seaweedfs/seaweedfs/weed/glog"
-	// "github.com/klauspost/compress/zstd"
 )
 
 var (
@@ -60,11 +64,9 @@ func DecompressData(input []byte) ([]byte, error) {
 	if IsGzippedContent(input) {
 		return ungzipData(input)
 	}
-	/*
-		if IsZstdContent(input) {
-			return unzstdData(input)
-		}
-	*/
+	if IsZstdContent(input) {
+		return unzstdData(input)
+	}
 	return input, UnsupportedCompression
 }
 
@@ -75,11 +77,29 @@ func IsGzippedContent(data []byte) bool {
 	return data[0] == 31 && data[1] == 139
 }
 
-/*
-var zstdEncoder, _ = zstd.NewWriter(nil)
+var (
+	zstdEncoders     = make(map[int]*zstd.Encoder)
+	zstdEncodersLock sync.Mutex
+)
 
-func ZstdData(input []byte) ([]byte, error) {
-	return zstdEncoder.EncodeAll(input, nil), nil
+// ZstdData compresses with the zstd level, 0 for the default level
+func ZstdData(input []byte, level int) ([]byte, error) {
+	zstdEncodersLock.Lock()
+	encoder, found := zstdEncoders[level]
+	if !found {
+		encoderLevel := zstd.SpeedDefault
+		if level > 0 {
+			encoderLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		var err error
+		if encoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(encoderLevel)); err != nil {
+			zstdEncodersLock.Unlock()
+			return nil, err
+		}
+		zstdEncoders[level] = encoder
+	}
+	zstdEncodersLock.Unlock()
+	return encoder.EncodeAll(input, nil), nil
 }
 
 var decoder, _ = zstd.NewReader(nil)
@@ -94,7 +114,105 @@ func IsZstdContent(data []byte) bool {
 	}
 	return data[3] == 0xFD && data[2] == 0x2F && data[1] == 0xB5 && data[0] == 0x28
 }
-*/
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionAuto = "auto"
+)
+
+// CompressionPolicy chooses how chunks are compressed. An empty codec compresses by the content type.
+type CompressionPolicy struct {
+	Codec string
+	Level int // zstd level, 0 for the default
+}
+
+// ParseCompressionPolicy parses none, gzip, zstd, zstd:<level> or auto
+func ParseCompressionPolicy(policy string) (p CompressionPolicy, err error) {
+	codec, level, hasLevel := strings.Cut(strings.ToLower(strings.TrimSpace(policy)), ":")
+	switch codec {
+	case "", CompressionNone, CompressionGzip, CompressionAuto:
+		if hasLevel {
+			return p, fmt.Errorf("compression %s has no level", codec)
+		}
+	case CompressionZstd:
+		if hasLevel {
+			if p.Level, err = strconv.Atoi(level); err != nil || p.Level < 1 || p.Level > 22 {
+				return p, fmt.Errorf("invalid zstd level %s", level)
+			}
+		}
+	default:
+		return p, fmt.Errorf("unknown compression %s", policy)
+	}
+	p.Codec = codec
+	return p, nil
+}
+
+// Compress returns the compressed data and the codec, or the input if the policy does not compress it.
+// The data is kept uncompressed unless compression saves at least 10%.
+func (p CompressionPolicy) Compress(input []byte) ([]byte, string) {
+	var compressed []byte
+	var err error
+	codec := p.Codec
+	switch codec {
+	case CompressionGzip:
+		compressed, err = GzipData(input)
+	case CompressionAuto:
+		if !IsCompressibleContent(input) {
+			return input, ""
+		}
+		codec = CompressionZstd
+		compressed, err = ZstdData(input, p.Level)
+	case CompressionZstd:
+		compressed, err = ZstdData(input, p.Level)
+	default:
+		return input, ""
+	}
+	if err != nil || len(compressed)*10 > len(input)*9 {
+		return input, ""
+	}
+	return compressed, codec
+}
+
+const (
+	entropySampleSize  = 4 * 1024
+	entropySampleCount = 4
+	entropyThreshold   = 7.0 // bits per byte, compressed or random data is close to 8
+)
+
+// IsCompressibleContent estimates the entropy of a few samples of the data
+func IsCompressibleContent(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	var histogram [256]int
+	sampled := 0
+	step := len(data) / entropySampleCount
+	for i := 0; i < entropySampleCount; i++ {
+		start := i * step
+		stop := start + entropySampleSize
+		if stop > len(data) {
+			stop = len(data)
+		}
+		for _, b := range data[start:stop] {
+			histogram[b]++
+		}
+		sampled += stop - start
+		if step == 0 {
+			break
+		}
+	}
+	var entropy float64
+	for _, count := range histogram {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / float64(sampled)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy < entropyThreshold
+}
 
 /*
 * Default not to compressed since compression can be done on client side.
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCompressionPolicy(t *testing.T) {
+	p, err := ParseCompressionPolicy("zstd:9")
+	assert.Nil(t, err)
+	assert.Equal(t, CompressionPolicy{Codec: CompressionZstd, Level: 9}, p)
+
+	p, err = ParseCompressionPolicy("Auto")
+	assert.Nil(t, err)
+	assert.Equal(t, CompressionAuto, p.Codec)
+
+	for _, invalid := range []string{"zstd:0", "zstd:x", "gzip:5", "lz4"} {
+		_, err = ParseCompressionPolicy(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestCompressionPolicyCompress(t *testing.T) {
+	text := bytes.Repeat([]byte("seaweedfs stores billions of files fast! "), 1000)
+	random := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	for _, codec := range []string{CompressionGzip, CompressionZstd, CompressionAuto} {
+		compressed, compressedCodec := CompressionPolicy{Codec: codec}.Compress(text)
+		assert.NotEqual(t, "", compressedCodec, codec)
+		assert.Less(t, len(compressed), len(text))
+		decompressed, err := DecompressData(compressed)
+		assert.Nil(t, err)
+		assert.Equal(t, text, decompressed)
+	}
+
+	_, codec := CompressionPolicy{Codec: CompressionAuto}.Compress(random)
+	assert.Equal(t, "", codec, "random data is not compressible")
+	_, codec = CompressionPolicy{Codec: CompressionZstd}.Compress(random)
+	assert.Equal(t, "", codec, "compression must save space")
+	_, codec = CompressionPolicy{Codec: CompressionNone}.Compress(text)
+	assert.Equal(t, "", codec)
+}
@@ -0,0 +1,128 @@
+package fastcdc
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Chunker splits a stream into content-defined chunks with the FastCDC algorithm.
+// The chunk boundaries depend on the content only, so an insertion or a deletion
+// changes only the chunks around it, and identical content results in identical chunks.
+type Chunker struct {
+	reader  io.Reader
+	buf     []byte
+	start   int
+	end     int
+	eof     bool
+	minSize int
+	avgSize int
+	maxSize int
+	maskS   uint64 // harder to match, used before the average size
+	maskL   uint64 // easier to match, used after the average size
+}
+
+func NewChunker(reader io.Reader, minSize, avgSize, maxSize int) (*Chunker, error) {
+	if minSize <= 0 || minSize > avgSize || avgSize > maxSize {
+		return nil, fmt.Errorf("invalid chunk sizes min %d avg %d max %d", minSize, avgSize, maxSize)
+	}
+	avgBits := bits.Len(uint(avgSize)) - 1
+	if avgBits < 4 {
+		return nil, fmt.Errorf("average chunk size %d is too small", avgSize)
+	}
+	return &Chunker{
+		reader:  reader,
+		buf:     make([]byte, maxSize),
+		minSize: minSize,
+		avgSize: avgSize,
+		maxSize: maxSize,
+		maskS:   topBitsMask(avgBits + 2),
+		maskL:   topBitsMask(avgBits - 2),
+	}, nil
+}
+
+// the high bits of the rolling hash depend on the most bytes
+func topBitsMask(n int) uint64 {
+	return ^uint64(0) << (64 - n)
+}
+
+// Next returns the next chunk, or io.EOF after the last one.
+// The returned data is only valid until the next call.
+func (c *Chunker) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+	n := c.cut(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+func (c *Chunker) fill() error {
+	if c.eof || c.end-c.start >= c.maxSize {
+		return nil
+	}
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+	for c.end < len(c.buf) {
+		n, err := c.reader.Read(c.buf[c.end:])
+		c.end += n
+		if err == io.EOF {
+			c.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cut returns the length of the first chunk of the data
+func (c *Chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.minSize {
+		return n
+	}
+	if n > c.maxSize {
+		n = c.maxSize
+	}
+	normalSize := c.avgSize
+	if n < normalSize {
+		normalSize = n
+	}
+
+	var fp uint64
+	i := c.minSize
+	for ; i < normalSize; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// gear is a fixed table of random values, so the chunk boundaries are stable across processes
+var gear [256]uint64
+
+func init() {
+	seed := uint64(0x5eaeed5f5)
+	for i := range gear {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
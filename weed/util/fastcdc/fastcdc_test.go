@@ -0,0 +1,63 @@
+package fastcdc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func splitAll(t *testing.T, data []byte) (chunks [][]byte) {
+	chunker, err := NewChunker(bytes.NewReader(data), 2*1024, 8*1024, 32*1024)
+	assert.Nil(t, err)
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			return
+		}
+		assert.Nil(t, err)
+		chunks = append(chunks, append([]byte(nil), chunk...))
+	}
+}
+
+func TestChunkSizes(t *testing.T) {
+	data := make([]byte, 1024*1024+123)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	chunks := splitAll(t, data)
+	assert.Equal(t, data, bytes.Join(chunks, nil))
+	for i, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), 32*1024)
+		if i < len(chunks)-1 {
+			assert.GreaterOrEqual(t, len(chunk), 2*1024)
+		}
+	}
+	average := len(data) / len(chunks)
+	assert.True(t, average > 4*1024 && average < 16*1024, "average chunk size %d", average)
+
+	assert.Equal(t, 0, len(splitAll(t, nil)))
+	assert.Equal(t, 1, len(splitAll(t, data[:100])))
+}
+
+func TestChunkBoundariesShift(t *testing.T) {
+	data := make([]byte, 512*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	fingerprints := make(map[[32]byte]bool)
+	for _, chunk := range splitAll(t, data) {
+		fingerprints[sha256.Sum256(chunk)] = true
+	}
+
+	shifted := append([]byte("inserted at the beginning"), data...)
+	chunks := splitAll(t, shifted)
+	same := 0
+	for _, chunk := range chunks {
+		if fingerprints[sha256.Sum256(chunk)] {
+			same++
+		}
+	}
+	assert.GreaterOrEqual(t, same, len(chunks)-2, "only the chunks around the change differ")
+}
@@ -1,24 +1,22 @@
 package util
 
+import "sync"
+
 // initial version comes from https://github.com/korovkin/limiter/blob/master/limiter.go
 
 // LimitedConcurrentExecutor object
 type LimitedConcurrentExecutor struct {
-	limit     int
-	tokenChan chan int
+	limit   int
+	running int
+	cond    *sync.Cond
 }
 
 func NewLimitedConcurrentExecutor(limit int) *LimitedConcurrentExecutor {
 
 	// allocate a limiter instance
 	c := &LimitedConcurrentExecutor{
-		limit:     limit,
-		tokenChan: make(chan int, limit),
-	}
-
-	// allocate the tokenChan:
-	for i := 0; i < c.limit; i++ {
-		c.tokenChan <- i
+		limit: limit,
+		cond:  sync.NewCond(&sync.Mutex{}),
 	}
 
 	return c
@@ -29,12 +27,39 @@ func NewLimitedConcurrentExecutor(limit int) *LimitedConcurrentExecutor {
 // launch a new go routine to execute job
 // else wait until a go routine becomes available
 func (c *LimitedConcurrentExecutor) Execute(job func()) {
-	token := <-c.tokenChan
+	c.cond.L.Lock()
+	for c.running >= c.limit {
+		c.cond.Wait()
+	}
+	c.running++
+	c.cond.L.Unlock()
+
 	go func() {
 		defer func() {
-			c.tokenChan <- token
+			c.cond.L.Lock()
+			c.running--
+			c.cond.L.Unlock()
+			c.cond.Signal()
 		}()
 		// run the job
 		job()
 	}()
 }
+
+// SetLimit changes the number of concurrent go routines.
+// The running jobs are not interrupted when the limit is lowered.
+func (c *LimitedConcurrentExecutor) SetLimit(limit int) {
+	if limit <= 0 {
+		return
+	}
+	c.cond.L.Lock()
+	c.limit = limit
+	c.cond.L.Unlock()
+	c.cond.Broadcast()
+}
+
+func (c *LimitedConcurrentExecutor) Limit() int {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+	return c.limit
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimitedConcurrentExecutorSetLimit(t *testing.T) {
+	c := NewLimitedConcurrentExecutor(1)
+
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	job := func() {
+		defer wg.Done()
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	}
+
+	wg.Add(3)
+	for i := 0; i < 3; i++ {
+		c.Execute(job)
+	}
+	wg.Wait()
+	if maxRunning != 1 {
+		t.Errorf("max running %d with limit 1", maxRunning)
+	}
+
+	c.SetLimit(4)
+	if c.Limit() != 4 {
+		t.Errorf("limit %d", c.Limit())
+	}
+	maxRunning = 0
+	wg.Add(4)
+	for i := 0; i < 4; i++ {
+		c.Execute(job)
+	}
+	wg.Wait()
+	if maxRunning != 4 {
+		t.Errorf("max running %d with limit 4", maxRunning)
+	}
+}